@@ -3,20 +3,68 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang-simple-notes/blob"
+	"golang-simple-notes/broker"
+	"golang-simple-notes/debug"
+	"golang-simple-notes/events"
 	"golang-simple-notes/grpc"
-	"golang-simple-notes/model"
+	"golang-simple-notes/inflight"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/metrics"
 	"golang-simple-notes/rest"
+	"golang-simple-notes/scheduler"
+	"golang-simple-notes/service"
 	"golang-simple-notes/storage"
+	"golang-simple-notes/tracing"
+	"golang-simple-notes/ui"
+	"golang-simple-notes/vector"
+	"golang-simple-notes/webhook"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// templatesSuffix is appended to the CouchDB database or MongoDB collection of the notes
+	// to name the one holding the note templates.
+	templatesSuffix = "_templates"
+
+	// collabSuffix is appended to the CouchDB database or MongoDB collection of the notes
+	// to name the one holding the documents of collaborative editing.
+	collabSuffix = "_collab"
+
+	// quotasSuffix is appended to the CouchDB database or MongoDB collection of the notes
+	// to name the one holding the quota usage of the owners of notes.
+	quotasSuffix = "_quotas"
+
+	// viewsSuffix is appended to the CouchDB database or MongoDB collection of the notes
+	// to name the one holding the views of the notes.
+	viewsSuffix = "_views"
+
+	// watchCallbackTimeout is the maximum time allowed for delivering a single watch callback.
+	watchCallbackTimeout = 5 * time.Second
+
+	// dualWriteQueueSize is the maximum number of writes waiting for dual-write verification,
+	// or to be mirrored in asynchronous mode.
+	dualWriteQueueSize = 1000
+
+	// dualWriteVerifyDelay is how long the verifier waits after a write before re-reading it,
+	// giving eventually consistent backends time to settle.
+	dualWriteVerifyDelay = 100 * time.Millisecond
 )
 
 // App represents the main application that coordinates all components:
@@ -25,10 +73,54 @@ import (
 // - gRPC API server
 // It handles initialization, running, and graceful shutdown of these components.
 type App struct {
-	storage    storage.NoteStorage // Interface for storing and retrieving notes
-	restServer *http.Server        // HTTP server for REST API
-	grpcServer *grpc.Server        // gRPC server for gRPC API
-	config     *Config             // Application configuration
+	storage        storage.NoteStorage        // Interface for storing and retrieving notes
+	notes          *service.NoteService       // Business logic of notes, shared by the REST and gRPC APIs
+	templates      *service.TemplateService   // Note templates, stored next to the notes in a namespace of their own
+	templateStore  storage.NoteStorage        // Storage of the note templates
+	collab         *service.CollabService     // Collaborative editing sessions, with documents stored in a namespace of their own
+	collabStore    storage.NoteStorage        // Storage of the documents of collaborative editing
+	search         *service.SearchService     // Text search, and semantic search following every note event, if enabled
+	quotas         *service.Quotas            // Quotas of the owners of notes, if enabled
+	quotaStore     storage.NoteStorage        // Storage of the quota usage, if quotas are enabled
+	views          *service.ViewCounter       // Views of the notes, if view counting is enabled
+	viewStore      storage.NoteStorage        // Storage of the views, if view counting is enabled
+	encrypted      *storage.EncryptedStorage  // Encryption decorator, if encryption at rest is enabled
+	bus            *events.Bus                // Internal event bus receiving every note lifecycle event
+	jobs           *jobs.Runner               // Background jobs: webhook deliveries, asynchronous imports, and cleanups
+	scheduler      *scheduler.Scheduler       // Scheduled backups, purges, and stats recomputes, if any is enabled
+	blobs          blob.BlobStore             // Blob store of exports, downloaded directly from it, if enabled
+	attachments    service.Attachments        // Files attached to notes, stored by the backend itself, if it supports them
+	watchers       *webhook.Watchers          // Per-note watch registry
+	webhooks       *webhook.Hooks             // Webhooks registered by operators, receiving every note event
+	broadcaster    *webhook.Broadcaster       // Stream of every note event for WebSocket clients
+	links          *service.LinkGraph         // Links between notes, following every note event
+	activity       *service.ActivityLog       // Counts of the recent changes of notes, following every note event
+	responses      *rest.ResponseCache        // Cached list, count, and search responses, dropped on every note event, if enabled
+	kafka          *broker.KafkaPublisher     // Publisher of note events to Kafka, if enabled
+	nats           *broker.NATSPublisher      // Publisher of note events to NATS, if enabled
+	rabbitmq       *broker.RabbitMQPublisher  // Publisher of note events to RabbitMQ, if enabled
+	cache          *storage.CachedStorage     // Read-through cache, if enabled
+	redisCache     *storage.RedisCache        // Redis cache shared with other instances, if enabled
+	changes        storage.ChangeFeed         // MongoDB change stream or CouchDB changes feed that feeds the watchers, if available
+	verifier       *storage.Verifier          // Dual-write verifier, if dual-write verification is enabled
+	replicated     *storage.ReplicatedStorage // Asynchronous dual-write storage, if enabled
+	restServer     *http.Server               // HTTP server for REST API
+	debugServer    *http.Server               // HTTP server for pprof and expvar, if enabled
+	redirectServer *http.Server               // HTTP server redirecting to HTTPS, if enabled
+	grpcServer     *grpc.Server               // gRPC server for gRPC API
+	config         *Config                    // Application configuration
+	restSettings   *rest.Settings             // REST settings that can be reloaded at runtime (rate limits, CORS)
+	fallback       *storage.SwitchableStorage // In-memory fallback that is switched back to the configured backend, if reconnection is enabled
+
+	reloadMutex sync.Mutex // Serializes configuration reloads
+	applied     *Config    // Configuration whose reloadable settings are in effect
+
+	hooksMutex sync.Mutex     // Protects hooks
+	hooks      []shutdownHook // Cleanup functions registered with OnShutdown
+
+	restListening atomic.Bool      // Whether the REST server is accepting connections
+	restRequests  inflight.Tracker // REST requests being handled, waited for on shutdown
+	started       atomic.Bool      // Whether startup has finished (servers started, sample notes created)
 }
 
 // NewApp creates a new App instance with the provided configuration.
@@ -37,15 +129,38 @@ type App struct {
 func NewApp(config *Config) *App {
 	return &App{
 		config: config,
+		bus:    events.NewBus(),
 	}
 }
 
 // Initialize sets up the application components in the following order:
-// 1. Initializes the appropriate storage backend based on configuration
-// 2. Sets up the REST server with routes
-// 3. Sets up the gRPC server
+// 1. Sets up OpenTelemetry tracing (configured by the standard OTEL_* environment variables)
+// 2. Initializes the appropriate storage backend based on configuration
+// 3. Sets up the REST server with routes (served over HTTPS if a certificate is configured)
+// 4. Sets up the gRPC server
+// Every component that needs cleanup registers a shutdown hook (see OnShutdown),
+// so it is stopped in reverse order of initialization.
 // This method must be called before Run.
 func (a *App) Initialize(ctx context.Context) error {
+	// Set up tracing first, so storage connections are instrumented from the start
+	tracingEnd, err := tracing.Setup(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	// Flush spans that haven't been exported yet, after everything else has stopped
+	a.OnShutdown("tracing", tracingEnd)
+	if tracing.Enabled() {
+		log.Println("OpenTelemetry tracing enabled")
+	}
+
+	// Webhooks and collaborative editing submit jobs as soon as they are created
+	a.jobs = jobs.NewRunner(a.config.JobWorkers, a.config.JobQueueSize)
+
+	// Exports are uploaded to the blob store, if enabled, by jobs
+	if err := a.initializeBlobStore(ctx); err != nil {
+		return fmt.Errorf("failed to initialize the blob store: %w", err)
+	}
+
 	// Initialize storage backend (in-memory, CouchDB, or MongoDB)
 	// based on the configuration
 	storage, err := a.initializeStorage(ctx)
@@ -53,33 +168,139 @@ func (a *App) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	a.storage = storage
+	if a.quotaStore != nil {
+		a.quotas = service.NewQuotas(a.quotaStore, a.config.quotaLimits())
+		a.OnShutdown("quota storage", a.quotaStore.Close)
+	}
+	if a.viewStore != nil {
+		a.views = service.NewViewCounter(a.viewStore, a.jobs)
+		a.bus.Subscribe("views", a.views)
+		// Hooks run in reverse order, so the last views are written before the storage is closed
+		a.OnShutdown("view storage", a.viewStore.Close)
+		a.OnShutdown("view counting", a.startViewFlushing())
+	}
+	a.notes = a.newNoteService()
+	a.templates = service.NewTemplateService(a.templateStore, a.notes)
+	a.OnShutdown("template storage", a.templateStore.Close)
+	a.collab = service.NewCollabService(a.collabStore, a.notes, a.jobs)
+	a.bus.Subscribe("collaboration", a.collab)
+	a.OnShutdown("collaboration storage", a.collabStore.Close)
+	a.search = a.newSearchService()
+	a.bus.Subscribe("search", a.search)
+	// Hooks run in reverse order, so the shared cache is closed after the storage
+	if a.redisCache != nil {
+		a.OnShutdown("cache", func(context.Context) error { return a.redisCache.Close() })
+	}
+	a.OnShutdown("storage", a.storage.Close)
+
+	// Keep trying to reach the configured backend after a fallback to in-memory storage
+	if a.fallback != nil {
+		a.OnShutdown("storage reconnection", a.startStorageReconnection())
+	}
+
+	// Notify watchers about changes reported by the MongoDB change stream or CouchDB changes feed
+	if a.changes != nil {
+		a.OnShutdown("change stream", a.startChangeStream())
+	}
+
+	// Wait for pending watch callbacks, webhook deliveries, and broker messages,
+	// which may still be delivered after the servers stop; the jobs that are left
+	// are abandoned before the storage they use is closed
+	a.OnShutdown("background jobs", a.jobs.Close)
+	// Scheduled tasks submit jobs, so they stop first (see scheduled.go)
+	if a.scheduler, err = a.newScheduler(); err != nil {
+		return fmt.Errorf("failed to initialize the scheduler: %w", err)
+	}
+	if a.scheduler != nil {
+		a.OnShutdown("scheduler", a.scheduler.Close)
+	}
+	a.OnShutdown("watch callbacks", a.watchers.Wait)
+	a.OnShutdown("webhook deliveries", a.webhooks.Close)
+	if a.kafka != nil {
+		a.OnShutdown("Kafka publisher", a.kafka.Close)
+	}
+	if a.nats != nil {
+		a.OnShutdown("NATS publisher", a.nats.Close)
+	}
+	if a.rabbitmq != nil {
+		a.OnShutdown("RabbitMQ publisher", a.rabbitmq.Close)
+	}
 
 	// Setup the REST and gRPC servers with the initialized storage
 	a.restServer = a.setupRESTServer()
 	a.grpcServer = a.setupGRPCServer()
 
+	// Serve the REST API over HTTPS if a certificate is configured
+	tlsConfig, err := a.loadTLSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+	a.restServer.TLSConfig = tlsConfig
+
+	// Stop accepting requests first and let in-flight ones complete; the publishers,
+	// webhooks, and storage they use are closed afterwards, by the hooks registered above
+	a.OnShutdownWithTimeout("REST and gRPC servers", a.config.ShutdownDrainTimeout, a.drainServers)
+
+	// Shutdown doesn't wait for WebSocket connections, since they are hijacked, so end their streams
+	a.restServer.RegisterOnShutdown(a.broadcaster.Close)
+
+	// Redirect plain HTTP to HTTPS only if it has been enabled
+	if a.config.RESTRedirectAddr != "" {
+		if tlsConfig == nil {
+			return fmt.Errorf("the HTTP to HTTPS redirect requires TLS to be enabled")
+		}
+		a.redirectServer = a.setupRedirectServer()
+		a.OnShutdown("HTTPS redirect server", a.redirectServer.Shutdown)
+	}
+
+	// Setup the debug server only if it has been enabled
+	if a.config.DebugAddr != "" {
+		a.debugServer = a.setupDebugServer()
+		a.OnShutdown("debug server", a.debugServer.Shutdown)
+	}
+
 	return nil
 }
 
 // Run starts the application servers and performs the following steps:
-// 1. Starts the REST and gRPC servers in separate goroutines
-// 2. Creates sample notes in the storage
-// 3. Waits for a shutdown signal (e.g., Ctrl+C)
-// This method blocks until the application is shut down.
+// 1. Waits for the storage and message brokers to be reachable, if enabled
+// 2. Binds the ports of all servers and serves them in separate goroutines
+// 3. Creates sample notes in the storage
+// 4. Waits for a shutdown signal (e.g., Ctrl+C), or for a server to fail
+// This method blocks until the application is shut down. If a server cannot start or
+// fails while running, the application is shut down and the server's error is returned.
 func (a *App) Run(ctx context.Context) error {
-	// Start the REST and gRPC servers in separate goroutines
-	if err := a.startServers(ctx); err != nil {
+	// Don't bind any port before the dependencies are reachable
+	if err := a.waitForDependencies(ctx); err != nil {
+		a.abort(ctx)
+		return fmt.Errorf("dependencies are not ready: %w", err)
+	}
+
+	// The servers run in the group, which is canceled as soon as one of them fails
+	g, groupCtx := errgroup.WithContext(ctx)
+	if err := a.startServers(g); err != nil {
+		a.abort(ctx)
 		return fmt.Errorf("failed to start servers: %w", err)
 	}
 
 	// Create sample notes in the storage for demonstration purposes
-	if err := a.createSampleNotes(ctx); err != nil {
+	if err := a.createSampleNotes(groupCtx); err != nil {
+		a.abort(ctx)
 		return fmt.Errorf("failed to create sample notes: %w", err)
 	}
 
-	// Wait for shutdown signal (context cancellation)
-	// This blocks until the context is canceled (e.g., by Ctrl+C)
-	return a.waitForShutdown(ctx)
+	// Report startup as finished to the startup probe
+	a.started.Store(true)
+
+	// Run the scheduled tasks only once the application is serving
+	if a.scheduler != nil {
+		a.scheduler.Start()
+	}
+
+	// Wait for a shutdown signal (context cancellation) or a failed server, then shut down;
+	// Wait returns the error of the failed server, or the context's error otherwise
+	g.Go(func() error { return a.waitForShutdown(groupCtx) })
+	return g.Wait()
 }
 
 // initializeStorage initializes the storage backend based on the configuration.
@@ -89,77 +310,652 @@ func (a *App) Run(ctx context.Context) error {
 // - Any other value (default): Uses in-memory storage
 //
 // If connecting to CouchDB or MongoDB fails, it falls back to in-memory storage
-// to ensure the application can still run.
+// to ensure the application can still run, unless strict storage mode is enabled,
+// in which case it returns an error. After a fallback, the configured backend is
+// retried in the background, and traffic is switched back once it is reachable.
+//
+// Unless disabled, every operation on CouchDB or MongoDB is bounded by a timeout, and a
+// circuit breaker rejects operations immediately while the backend keeps failing.
+//
+// If a dual-write target is configured, every write is mirrored to that backend as well
+// (and optionally verified), which allows migrating data between backends.
+//
+// The selected backend is wrapped with tracing and logging decorators that tag storage
+// operations with request IDs, and, if encryption keys are configured, with the
+// encryption-at-rest decorator. Note watchers, webhooks, and message brokers subscribe to
+// the event bus, which receives the changes from the note service (see newNoteService), or
+// from the MongoDB change stream or CouchDB changes feed, if available.
 func (a *App) initializeStorage(ctx context.Context) (storage.NoteStorage, error) {
-	var noteStorage storage.NoteStorage
-	var err error
+	// Writing "both" copies to the same database would make verification meaningless
+	if a.config.DualWriteTarget == a.config.StorageType && a.config.DualWriteTarget != "memory" {
+		return nil, fmt.Errorf("dual-write target must differ from the storage type %q", a.config.StorageType)
+	}
+
+	// Connect to the configured backend, falling back to in-memory storage
+	noteStorage, err := a.connectStorage(ctx, a.config.StorageType, a.config.retryPolicy())
+	backend := a.config.StorageType // Name of the backend actually in use, after any fallback
+	if err != nil {
+		// Startup was canceled (e.g., by Ctrl+C) while connecting; the backend may be fine
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("storage connection canceled: %w", err)
+		}
+		if a.config.StorageStrict {
+			return nil, fmt.Errorf("storage is unreachable and strict storage mode is enabled: %w", err)
+		}
+		// If connection fails, log the error and fall back to in-memory storage
+		logStorageFallback(a.config.StorageType, err)
+		memory := storage.NewInMemoryStorage()
+		memory.SetLimits(a.config.memoryLimits())
+		noteStorage = memory
+		backend = "memory"
+
+		// Switch back to the configured backend once it is reachable again (see reconnect.go)
+		if a.config.StorageReconnectInterval > 0 {
+			a.fallback = storage.NewSwitchableStorage(noteStorage)
+			noteStorage = a.fallback
+		}
+	} else if backend != "couchdb" && backend != "mongodb" {
+		backend = "memory"
+	} else {
+		// Learn about changes made by other instances, too (see changestream.go)
+		a.changes = a.openChangeStream(ctx, noteStorage)
+	}
+	reportStorageBackend(backend, a.config.StorageType)
+
+	// Backends that store files with the notes (e.g., as CouchDB document attachments) serve
+	// the attachments directly, bypassing the decorators below. The switchable fallback
+	// storage has none, as its files would be lost when switching back.
+	attachments, _ := noteStorage.(storage.AttachmentStore)
+	if a.config.AttachmentMaxBytes == 0 {
+		attachments = nil
+	}
+
+	// Templates, collaborative documents, quota usage, and views live in the backend in use,
+	// in a database or collection of their own
+	templateStore, err := a.connectNamespaceStorage(ctx, backend, templatesSuffix, a.config.retryPolicy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to template storage: %w", err)
+	}
+	a.templateStore = templateStore
+	collabStore, err := a.connectNamespaceStorage(ctx, backend, collabSuffix, a.config.retryPolicy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to collaboration storage: %w", err)
+	}
+	a.collabStore = collabStore
+	if a.config.quotasEnabled() {
+		quotaStore, err := a.connectNamespaceStorage(ctx, backend, quotasSuffix, a.config.retryPolicy())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to quota storage: %w", err)
+		}
+		a.quotaStore = quotaStore
+	}
+	if a.config.ViewFlushInterval > 0 {
+		viewStore, err := a.connectNamespaceStorage(ctx, backend, viewsSuffix, a.config.retryPolicy())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to view storage: %w", err)
+		}
+		a.viewStore = viewStore
+	}
+
+	// Bound every operation, so that a slow query fails on its own instead of holding the
+	// request; below the circuit breaker, operations that time out count as failures
+	if a.config.StorageOpTimeout > 0 && (backend != "memory" || a.fallback != nil) {
+		noteStorage = storage.NewTimeoutStorage(noteStorage, a.config.StorageOpTimeout)
+	}
+
+	// Fail fast while the backend keeps failing, instead of waiting for driver timeouts;
+	// after a fallback, this protects the backend that reconnection switches back to
+	if a.config.StorageCircuitFailureThreshold > 0 && (backend != "memory" || a.fallback != nil) {
+		noteStorage = storage.NewCircuitBreakerStorage(noteStorage, a.config.StorageType,
+			a.config.StorageCircuitFailureThreshold, a.config.StorageCircuitOpenTimeout)
+	}
+
+	// Mirror writes to a second backend while migrating between backends
+	if a.config.DualWriteTarget != "" {
+		secondary, err := a.connectStorage(ctx, a.config.DualWriteTarget, a.config.retryPolicy())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to dual-write target: %w", err)
+		}
+		if a.config.DualWriteMode == "async" {
+			// The target lags behind, so writes cannot be verified right away; reconciliation repairs it instead
+			log.Printf("Asynchronous dual-write enabled: %s -> %s (reconciliation every %v)",
+				backend, a.config.DualWriteTarget, a.config.DualWriteReconcileInterval)
+			a.replicated = storage.NewReplicatedStorage(noteStorage, secondary, dualWriteQueueSize, a.config.DualWriteReconcileInterval)
+			noteStorage = a.replicated
+		} else {
+			if a.config.DualWriteVerify {
+				a.verifier = storage.NewVerifier(noteStorage, secondary, dualWriteQueueSize, dualWriteVerifyDelay)
+			}
+			log.Printf("Dual-write enabled: %s -> %s (verification: %t)", backend, a.config.DualWriteTarget, a.config.DualWriteVerify)
+			noteStorage = storage.NewDualWriteStorage(noteStorage, secondary, a.verifier)
+		}
+		backend += "+" + a.config.DualWriteTarget
+	}
+
+	// Measure every storage operation, logging slow ones
+	noteStorage = storage.NewInstrumentedStorage(noteStorage, backend, a.config.StorageSlowThreshold)
+
+	// Record a span for every storage operation
+	noteStorage = storage.NewTracingStorage(noteStorage, backend)
+
+	// Log storage operations tagged with the request ID that caused them
+	noteStorage = storage.NewLoggingStorage(noteStorage, backend, a.config.StorageLogOperations)
 
-	// Choose the storage backend based on the configuration
-	switch a.config.StorageType {
+	// Serve repeated reads from the cache; only cache misses appear in the storage logs and traces.
+	// Below encryption, the cache holds notes as they are stored in the backend.
+	if cache := a.newStorageCache(ctx); cache != nil {
+		a.cache = storage.NewCachedStorage(noteStorage, cache)
+		noteStorage = a.cache
+	}
+	if attachments != nil {
+		// Sizes are checked by Validate
+		sizes, _ := a.config.thumbnailSizes()
+		a.attachments = service.NewAttachmentService(attachments, a.cache, service.WithThumbnails(a.jobs, sizes))
+	}
+
+	// Wrap the backend with encryption at rest if keys or a KMS are configured
+	if a.config.encryptionEnabled() {
+		keyring, err := a.config.keyring()
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption settings: %w", err)
+		}
+		if a.config.EncryptionKMS != "" {
+			log.Printf("Encryption at rest enabled with %s envelope encryption, key: %s", a.config.EncryptionKMS, keyring.ActiveKeyID())
+		} else {
+			log.Printf("Encryption at rest enabled, active key: %s", keyring.ActiveKeyID())
+		}
+		a.encrypted = storage.NewEncryptedStorage(noteStorage, keyring, a.config.EncryptionLazyRotation)
+		noteStorage = a.encrypted
+
+		// Publish how much data is still on old keys
+		if _, err := a.encrypted.KeyUsage(ctx); err != nil {
+			log.Printf("Failed to compute encryption key usage: %v", err)
+		}
+	}
+
+	// Per-note watchers, WebSocket clients, and webhooks all consume the event bus
+	a.watchers = webhook.NewWatchers(watchCallbackTimeout)
+	if a.config.WebhookAllowPrivate {
+		a.watchers.AllowPrivateTargets()
+	}
+	a.bus.Subscribe("watchers", a.watchers)
+	a.broadcaster = webhook.NewBroadcaster()
+	a.bus.Subscribe("websockets", a.broadcaster)
+	hooks, err := a.newHooks()
+	if err != nil {
+		return nil, err
+	}
+	a.webhooks = hooks
+	a.bus.Subscribe("webhooks", a.webhooks)
+	// The link graph follows the event bus too, to see the changes of other instances
+	a.links = service.NewLinkGraph()
+	a.bus.Subscribe("links", a.links)
+	// So does the activity log, which counts the changes of all instances with a change feed
+	a.activity = service.NewActivityLog()
+	a.bus.Subscribe("activity", a.activity)
+	// Cached responses are dropped on every change, including those of other instances
+	if a.config.HTTPCacheTTL > 0 {
+		a.responses = rest.NewResponseCache(a.config.HTTPCacheSize, a.config.HTTPCacheTTL)
+		a.bus.Subscribe("response cache", a.responses)
+	}
+
+	// Message brokers receive every note event as well, if configured
+	if brokers := a.config.kafkaBrokers(); len(brokers) > 0 {
+		kafka, err := broker.NewKafkaPublisher(brokers, a.config.KafkaTopic, a.config.KafkaEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kafka publisher: %w", err)
+		}
+		log.Printf("Kafka event publishing enabled: topic %s on %s (%s)", a.config.KafkaTopic, strings.Join(brokers, ","), a.config.KafkaEncoding)
+		a.kafka = kafka
+		a.bus.Subscribe("kafka", a.kafka)
+	}
+	if a.config.NATSURL != "" {
+		stream, mode := "", "core NATS"
+		if a.config.NATSJetStream {
+			stream, mode = a.config.NATSStream, "JetStream stream "+a.config.NATSStream
+		}
+		nats, err := broker.NewNATSPublisher(a.config.NATSURL, a.config.NATSSubject, stream, a.config.NATSEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create NATS publisher: %w", err)
+		}
+		log.Printf("NATS event publishing enabled: subjects %s.> via %s (%s)", a.config.NATSSubject, mode, a.config.NATSEncoding)
+		a.nats = nats
+		a.bus.Subscribe("nats", a.nats)
+	}
+	if a.config.AMQPURL != "" {
+		rabbitmq, err := broker.NewRabbitMQPublisher(a.config.AMQPURL, a.config.AMQPExchange, a.config.AMQPExchangeType,
+			a.config.AMQPRoutingKey, a.config.AMQPEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create RabbitMQ publisher: %w", err)
+		}
+		log.Printf("RabbitMQ event publishing enabled: %s exchange %s at %s (%s)",
+			a.config.AMQPExchangeType, a.config.AMQPExchange, redactURL(a.config.AMQPURL), a.config.AMQPEncoding)
+		a.rabbitmq = rabbitmq
+		a.bus.Subscribe("rabbitmq", a.rabbitmq)
+	}
+
+	return noteStorage, nil
+}
+
+// initializeBlobStore connects to the configured blob store, if any:
+// - "s3": An S3 bucket (or MinIO), from which files are downloaded with presigned URLs
+// - "gridfs": A GridFS bucket in the MongoDB database of the notes, whose files are
+// downloaded through the REST API from URLs signed with BlobURLSecret. Without a secret,
+// a random one is used, so URLs are only valid on the instance that issued them, until it restarts.
+func (a *App) initializeBlobStore(ctx context.Context) error {
+	switch a.config.BlobStore {
+	case "s3":
+		s3, err := blob.NewS3Store(a.config.s3Config())
+		if err != nil {
+			return err
+		}
+		a.blobs = s3
+		log.Printf("S3 blob store enabled: bucket %s at %s", a.config.S3Bucket, a.config.S3Endpoint)
+
+	case "gridfs":
+		secret := []byte(a.config.BlobURLSecret)
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return fmt.Errorf("failed to generate the download URL secret: %w", err)
+			}
+			log.Println("BLOB_URL_SECRET is not set: download URLs are only valid on this instance until it restarts")
+		}
+		signer := blob.NewURLSigner(strings.TrimSuffix(a.config.BlobBaseURL, "/")+"/api/blobs", secret)
+		gridFS, err := blob.NewGridFSStore(ctx, a.config.MongoDBURI, a.config.MongoDBName, a.config.GridFSBucket,
+			signer, a.config.retryPolicy())
+		if err != nil {
+			return err
+		}
+		a.blobs = gridFS
+		a.OnShutdown("blob store", gridFS.Close)
+		log.Printf("GridFS blob store enabled: bucket %s in database %s", a.config.GridFSBucket, a.config.MongoDBName)
+	}
+	return nil
+}
+
+// newNoteService creates the note service shared by the REST and gRPC APIs.
+// It publishes changes made through either API, unless a change feed is available,
+// which publishes all changes instead, including those of other instances (see startChangeStream).
+// If quotas are enabled, it enforces them on every write.
+func (a *App) newNoteService() *service.NoteService {
+	opts := []service.Option{service.WithLinkGraph(a.links)}
+	if a.changes == nil {
+		opts = append(opts, service.WithPublisher(a.bus))
+	}
+	if a.quotas != nil {
+		opts = append(opts, service.WithQuotas(a.quotas))
+	}
+	if summarizer := a.config.newSummarizer(); summarizer != nil {
+		opts = append(opts, service.WithSummarizer(summarizer))
+	}
+	if a.views != nil {
+		opts = append(opts, service.WithViewCounter(a.views))
+	}
+	return service.New(a.storage, opts...)
+}
+
+// startViewFlushing writes the views counted in memory every ViewFlushInterval, until the
+// returned shutdown hook is called.
+//
+// Returns:
+//   - A shutdown hook that stops the periodic writes, and writes the views left
+func (a *App) startViewFlushing() func(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		a.views.Run(ctx, a.config.ViewFlushInterval)
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			_, err := a.views.Flush(shutdownCtx)
+			return err
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// newSearchService creates the search service of the REST API. If semantic search is
+// enabled, it indexes the notes as they change, and an in-memory index, which starts
+// empty, is filled with every note by a "semantic-reindex" job.
+func (a *App) newSearchService() *service.SearchService {
+	embedder := a.config.newEmbedder()
+	if embedder == nil {
+		return service.NewSearchService(a.notes)
+	}
+	search := service.NewSearchService(a.notes, service.WithSemanticSearch(embedder, a.config.newVectorIndex(), a.jobs))
+	log.Printf("Semantic search enabled: %s embedder, %s vector index", a.config.Embedder, a.config.VectorIndex)
+	if a.config.VectorIndex != vector.KindMemory {
+		return search
+	}
+	_, err := a.jobs.Submit(context.Background(), jobs.Task{
+		Kind: "semantic-reindex",
+		Run: func(ctx context.Context) (any, error) {
+			indexed, err := search.Reindex(ctx)
+			return map[string]int{"indexed": indexed}, err
+		},
+		Done: func(result any, err error) {
+			if err != nil {
+				log.Printf("Failed to index the notes for semantic search: %v", err)
+			}
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to index the notes for semantic search: %v", err)
+	}
+	return search
+}
+
+// newStorageCache creates the cache for the storage, according to the configuration:
+// a local LRU cache, Redis shared by all instances, or both (with the LRU cache in front of Redis).
+// If Redis is unreachable, only the local cache is used.
+//
+// Returns:
+//   - The cache, or nil if caching is disabled
+func (a *App) newStorageCache(ctx context.Context) storage.Cache {
+	var local *storage.LRUCache
+	if a.config.StorageCacheSize > 0 {
+		local = storage.NewLRUCache(a.config.StorageCacheSize, a.config.StorageCacheTTL)
+	}
+
+	if a.config.StorageCacheRedisURL != "" {
+		redisCache, err := storage.NewRedisCache(ctx, a.config.StorageCacheRedisURL, a.config.StorageCacheTTL, local)
+		if err == nil {
+			a.redisCache = redisCache
+			log.Printf("Storage cache enabled: Redis at %s, %d local entries, TTL %v",
+				redactURL(a.config.StorageCacheRedisURL), a.config.StorageCacheSize, a.config.StorageCacheTTL)
+			return redisCache
+		}
+		log.Printf("Failed to connect to the Redis cache: %v; caching in memory only", err)
+	}
+
+	if local == nil {
+		return nil
+	}
+	log.Printf("Storage cache enabled: %d entries, TTL %v", a.config.StorageCacheSize, a.config.StorageCacheTTL)
+	return local
+}
+
+// newHooks creates the webhook registry with the webhooks of the configuration.
+// More webhooks can be registered at runtime through the admin API.
+func (a *App) newHooks() (*webhook.Hooks, error) {
+	hooks := webhook.NewHooks(a.config.webhookRetryPolicy(), a.config.WebhookSecret, a.jobs)
+	if a.config.WebhookAllowPrivate {
+		hooks.AllowPrivateTargets()
+	}
+	for _, url := range a.config.webhookURLs() {
+		if _, err := hooks.Add(url, nil, "", "config"); err != nil {
+			return nil, fmt.Errorf("invalid webhook %s: %w", redactURL(url), err)
+		}
+	}
+	if n := len(hooks.List()); n > 0 {
+		log.Printf("Webhooks enabled: %d configured", n)
+	}
+	return hooks, nil
+}
+
+// logStorageFallback reports a fallback to in-memory storage as loudly as possible:
+// notes written from now on are lost on restart, which is easy to miss otherwise.
+func logStorageFallback(storageType string, err error) {
+	log.Printf("Failed to connect to storage: %v, falling back to in-memory storage", err)
+	log.Printf("WARNING: ********************************************************************")
+	log.Printf("WARNING: %s is unreachable; notes are stored IN MEMORY and will be LOST on restart", storageType)
+	log.Printf("WARNING: Set STORAGE_STRICT=true to fail at startup instead")
+	log.Printf("WARNING: ********************************************************************")
+
+	metrics.StorageFallbacks.WithLabelValues(storageType).Inc()
+	metrics.StorageFallbackActive.Set(1)
+}
+
+// reportStorageBackend exposes the backend that notes are stored in next to the configured
+// one, so that a fallback to in-memory storage stands out from in-memory storage by choice.
+func reportStorageBackend(backend, configured string) {
+	if configured != "couchdb" && configured != "mongodb" {
+		configured = "memory"
+	}
+	metrics.StorageBackend.Reset()
+	metrics.StorageBackend.WithLabelValues(backend, configured).Set(1)
+}
+
+// connectStorage connects to a storage backend of the given type:
+// - "couchdb": Uses CouchDB as the storage backend
+// - "mongodb": Uses MongoDB as the storage backend
+// - Any other value: Uses in-memory storage
+// Connections to CouchDB and MongoDB are retried according to the given policy.
+func (a *App) connectStorage(ctx context.Context, storageType string, retry storage.RetryPolicy) (storage.NoteStorage, error) {
+	switch storageType {
 	case "couchdb":
 		// Try to connect to CouchDB
-		log.Printf("Connecting to CouchDB at %s, database: %s", a.config.CouchDBURL, a.config.CouchDBName)
-		noteStorage, err = storage.NewCouchDBStorage(a.config.CouchDBURL, a.config.CouchDBName)
+		log.Printf("Connecting to CouchDB at %s, database: %s", redactURL(a.config.CouchDBURL), a.config.CouchDBName)
+		couchStorage, err := storage.NewCouchDBStorage(ctx, a.config.CouchDBURL, a.config.CouchDBName,
+			a.config.CouchDBUser, a.config.CouchDBPassword, storage.ConflictPolicy(a.config.CouchDBConflictPolicy), retry)
 		if err != nil {
-			// If connection fails, log the error and fall back to in-memory storage
-			log.Printf("Failed to connect to CouchDB: %v, falling back to in-memory storage", err)
-			noteStorage = storage.NewInMemoryStorage()
-		} else {
-			log.Println("Successfully connected to CouchDB")
+			return nil, fmt.Errorf("failed to connect to CouchDB: %w", err)
 		}
+		log.Println("Successfully connected to CouchDB")
+		return couchStorage, nil
 	case "mongodb":
 		// Try to connect to MongoDB
 		log.Printf("Connecting to MongoDB at %s, database: %s, collection: %s",
-			a.config.MongoDBURI, a.config.MongoDBName, a.config.MongoDBCollection)
-		noteStorage, err = storage.NewMongoDBStorage(a.config.MongoDBURI, a.config.MongoDBName, a.config.MongoDBCollection)
+			redactURL(a.config.MongoDBURI), a.config.MongoDBName, a.config.MongoDBCollection)
+		mongoStorage, err := storage.NewMongoDBStorage(ctx, a.config.MongoDBURI, a.config.MongoDBName, a.config.MongoDBCollection,
+			a.config.mongoDBOptions(), retry)
 		if err != nil {
-			// If connection fails, log the error and fall back to in-memory storage
-			log.Printf("Failed to connect to MongoDB: %v, falling back to in-memory storage", err)
-			noteStorage = storage.NewInMemoryStorage()
-		} else {
-			log.Println("Successfully connected to MongoDB")
+			return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 		}
+		log.Println("Successfully connected to MongoDB")
+		return mongoStorage, nil
 	default:
 		// Use in-memory storage by default
 		log.Println("Using in-memory storage")
-		noteStorage = storage.NewInMemoryStorage()
+		memory, err := a.newInMemoryStorage("")
+		if err != nil {
+			return nil, err
+		}
+		memory.SetLimits(a.config.memoryLimits())
+		return memory, nil
 	}
+}
 
-	return noteStorage, nil
+// connectNamespaceStorage connects to a storage next to the notes on the given backend, for
+// data that must not appear among them (e.g., the note templates): the database of the notes
+// with the suffix on CouchDB, the collection of the notes with the suffix on MongoDB, or a
+// separate in-memory storage.
+func (a *App) connectNamespaceStorage(ctx context.Context, backend, suffix string, retry storage.RetryPolicy) (storage.NoteStorage, error) {
+	switch backend {
+	case "couchdb":
+		return storage.NewCouchDBStorage(ctx, a.config.CouchDBURL, a.config.CouchDBName+suffix,
+			a.config.CouchDBUser, a.config.CouchDBPassword, storage.ConflictPolicy(a.config.CouchDBConflictPolicy), retry)
+	case "mongodb":
+		return storage.NewMongoDBStorage(ctx, a.config.MongoDBURI, a.config.MongoDBName, a.config.MongoDBCollection+suffix,
+			a.config.mongoDBOptions(), retry)
+	default:
+		memory, err := a.newInMemoryStorage(suffix)
+		if err != nil {
+			return nil, err
+		}
+		return memory, nil
+	}
+}
+
+// newInMemoryStorage creates an in-memory storage. If snapshots are enabled, it is loaded
+// from and saved to the snapshot file, with the suffix before the extension for namespace
+// storages (e.g., notes_templates.json next to notes.json).
+func (a *App) newInMemoryStorage(suffix string) (*storage.InMemoryStorage, error) {
+	if a.config.StorageSnapshotPath == "" {
+		return storage.NewInMemoryStorage(), nil
+	}
+	path := a.config.StorageSnapshotPath
+	ext := filepath.Ext(path)
+	path = strings.TrimSuffix(path, ext) + suffix + ext
+	s, err := storage.NewInMemoryStorageWithSnapshots(path, a.config.StorageSnapshotInterval)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Snapshots of in-memory storage: %s (interval: %v)", path, a.config.StorageSnapshotInterval)
+	return s, nil
+}
+
+// RotateEncryptionKeys re-encrypts all notes that are not yet encrypted with the
+// active key. It returns an error if encryption at rest is not enabled.
+func (a *App) RotateEncryptionKeys(ctx context.Context) (storage.RotationResult, error) {
+	if a.encrypted == nil {
+		return storage.RotationResult{}, fmt.Errorf("encryption at rest is not enabled")
+	}
+	return a.encrypted.Rotate(ctx)
 }
 
 // setupRESTServer creates and configures the REST API server.
 // It sets up:
-// 1. A new REST handler with the storage backend
-// 2. A Chi router with middleware for logging and panic recovery
-// 3. Routes for the REST API endpoints
-// 4. An HTTP server with the configured port
+//  1. A new REST handler with the storage backend and the watch registry
+//  2. A Chi router with middleware for logging, panic recovery, CORS, and rate limiting
+//  3. Routes for the REST API endpoints, the /metrics endpoint, and the web UI (if enabled)
+//  4. An HTTP server with the configured port, timeouts, and header size limit,
+//     optionally accepting HTTP/2 without TLS (h2c)
 func (a *App) setupRESTServer() *http.Server {
+	// Rate limits and CORS are read from a snapshot that Reload swaps at runtime
+	a.restSettings = rest.NewSettings(a.config.restSettings())
+
+	// Notes are summarized by the note service, if a summarizer is configured
+	var summaries service.Summaries
+	if a.config.Summarizer != "" {
+		summaries = a.notes
+	}
+
 	// Create a new REST handler with the storage backend
-	restHandler := rest.NewHandler(a.storage)
+	restHandler := rest.NewHandler(a.storage,
+		rest.WithNoteService(a.notes),
+		rest.WithSummaries(summaries),
+		rest.WithTemplates(a.templates),
+		rest.WithWatchers(a.watchers),
+		rest.WithBroadcaster(a.broadcaster),
+		rest.WithCollaboration(a.collab),
+		rest.WithSearch(a.search),
+		rest.WithSettings(a.restSettings),
+		rest.WithHooks(a.webhooks),
+		rest.WithJobs(a.jobs),
+		rest.WithBlobStore(a.blobs, a.config.BlobURLExpiry),
+		rest.WithAttachments(a.attachments, int64(a.config.AttachmentMaxBytes)),
+		rest.WithExpander("attachments", rest.AttachmentsExpander(a.attachments)),
+		rest.WithExpander("backlinks", rest.BacklinksExpander(a.notes)),
+		rest.WithAdminToken(a.config.AdminToken),
+		rest.WithPutCreates(a.config.RESTPutCreates),
+		rest.WithVerifier(a.verifier),
+		rest.WithReplication(a.replicated),
+		rest.WithQuotas(a.quotas),
+		rest.WithActivity(a.activity),
+		rest.WithHealthCheck("rest_server", a.checkRESTListening),
+		rest.WithStartupCheck("initialization", a.checkStarted),
+	)
 
 	// Create a new Chi router
 	// Chi is a lightweight, idiomatic and composable router for Go HTTP services
 	r := chi.NewRouter()
 
 	// Add middleware to the router
-	r.Use(middleware.Logger)    // Log all HTTP requests
-	r.Use(middleware.Recoverer) // Recover from panics without crashing the server
+	r.Use(rest.TracingMiddleware)                   // Start a server span for every request
+	r.Use(rest.MetricsMiddleware)                   // Record request rates, errors, and durations by route
+	r.Use(rest.InFlightMiddleware(&a.restRequests)) // Count requests in flight, for the shutdown to wait for
+	r.Use(rest.RequestIDMiddleware)                 // Assign a request ID and return it in X-Request-ID
+	r.Use(middleware.Logger)                        // Log all HTTP requests (including the request ID)
+	r.Use(middleware.Recoverer)                     // Recover from panics without crashing the server
+	r.Use(rest.CORSMiddleware(a.restSettings))      // Allow browsers to call the API from the configured origins
+	r.Use(rest.RateLimitMiddleware(a.restSettings)) // Limit the request rate of each client
+
+	// Reject /api requests beyond the concurrency limits, before any work is spent on them
+	if a.config.loadShedding() {
+		r.Use(rest.LoadSheddingMiddleware(rest.ConcurrencyLimits{
+			Total:      a.config.MaxInFlightRequests,
+			Reads:      a.config.MaxInFlightReads,
+			Writes:     a.config.MaxInFlightWrites,
+			RetryAfter: a.config.LoadShedRetryAfter,
+		}))
+	}
+
+	// Add security headers to every response, including the web UI's
+	if a.config.SecurityHeaders {
+		r.Use(rest.SecurityHeadersMiddleware(rest.SecurityHeaders{
+			FrameOptions:          a.config.SecurityFrameOptions,
+			ReferrerPolicy:        a.config.SecurityReferrerPolicy,
+			ContentSecurityPolicy: a.config.SecurityContentSecurityPolicy,
+			HSTSMaxAge:            a.config.SecurityHSTSMaxAge,
+		}))
+	}
+
+	// Attribute notes to the owner identified by an API key in the quota header
+	if a.quotas != nil {
+		r.Use(rest.OwnerMiddleware(a.config.QuotaOwnerHeader, a.config.quotaAPIKeys()))
+	}
+
+	// Serve repeated list, count, and search requests from memory, with the headers set above
+	if a.responses != nil {
+		r.Use(a.responses.Middleware)
+	}
+
+	// Log sampled request and response bodies while the log level is debug
+	if a.config.BodyLogMaxBytes > 0 {
+		r.Use(rest.BodyLogMiddleware(rest.BodyLogOptions{
+			MaxBytes:      a.config.BodyLogMaxBytes,
+			Rate:          a.config.BodyLogRate,
+			ExcludedPaths: a.config.bodyLogExcludedPaths(),
+		}))
+	}
 
 	// Register the API routes with the router
 	// This sets up endpoints like GET /api/notes, POST /api/notes, etc.
 	restHandler.RegisterRoutes(r)
 
+	// Expose Prometheus metrics
+	r.Handle("/metrics", metrics.Handler())
+
+	// Serve the web UI, which manages notes through the API routes above
+	if a.config.UIEnabled {
+		r.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+		r.Handle("/ui/*", ui.Handler("/ui/"))
+	}
+
 	// Create and return an HTTP server with the configured port and router
-	return &http.Server{
+	server := &http.Server{
 		Addr:    a.config.RESTPort, // Port to listen on (e.g., ":8080")
 		Handler: r,                 // The router that handles requests
+
+		// Limit how long and how much a client may send, so slow or oversized
+		// requests (e.g., slow-loris attacks) cannot exhaust connections and memory
+		ReadHeaderTimeout: a.config.HTTPReadHeaderTimeout,
+		ReadTimeout:       a.config.HTTPReadTimeout,
+		WriteTimeout:      a.config.HTTPWriteTimeout,
+		IdleTimeout:       a.config.HTTPIdleTimeout,
+		MaxHeaderBytes:    a.config.HTTPMaxHeaderBytes,
+
+		// Tune HTTP/2 connections, which multiplex many requests and stay open for long
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: a.config.HTTP2MaxConcurrentStreams,
+			SendPingTimeout:      a.config.HTTP2PingInterval,
+		},
+	}
+
+	// Accept HTTP/2 without TLS (h2c with prior knowledge), for clients behind
+	// internal load balancers that terminate TLS or don't use it at all
+	if a.config.RESTH2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Protocols = protocols
 	}
+
+	return server
 }
 
 // setupGRPCServer creates and configures the gRPC server.
 // It extracts the port number from the configuration and creates a new gRPC server
-// with the storage backend and port.
+// with the note service and port.
 func (a *App) setupGRPCServer() *grpc.Server {
 	// Extract the port number from the configuration
 	// The port might be in the format ":8081", so we need to remove the colon prefix
@@ -171,60 +967,163 @@ func (a *App) setupGRPCServer() *grpc.Server {
 		}
 	}
 
-	// Create and return a new gRPC server with the storage backend and port
-	return grpc.NewServer(a.storage, port)
+	// Create and return a new gRPC server with the note service and port
+	return grpc.NewServer(a.notes, port)
 }
 
-// startServers starts the REST and gRPC servers in separate goroutines.
-// This method doesn't block; it returns immediately after starting the servers.
-// Each server runs in its own goroutine (a lightweight thread) to allow them to run concurrently.
-func (a *App) startServers(ctx context.Context) error {
-	// Start REST server in a separate goroutine
-	go func() {
-		log.Printf("Starting REST server on %s", a.config.RESTPort)
-		// ListenAndServe blocks until the server is stopped or encounters an error
-		if err := a.restServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			// Log any error that isn't just the server being closed normally
-			log.Printf("REST server failed: %v", err)
+// setupDebugServer creates the server for the pprof and expvar endpoints.
+// It listens on its own address, so the endpoints can be kept off the public network
+// (e.g., "localhost:6060"), and optionally requires a bearer token.
+func (a *App) setupDebugServer() *http.Server {
+	if a.config.DebugToken == "" && !isLoopbackAddr(a.config.DebugAddr) {
+		log.Printf("Warning: debug endpoints on %s are not protected; set DEBUG_TOKEN or bind to localhost", a.config.DebugAddr)
+	}
+
+	// Only the header timeout applies: CPU profiles and traces take 30 seconds by default,
+	// which a write timeout would cut short
+	return &http.Server{
+		Addr:              a.config.DebugAddr,
+		Handler:           debug.Handler(a.config.DebugToken),
+		ReadHeaderTimeout: a.config.HTTPReadHeaderTimeout,
+	}
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local connections.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// startServers binds the ports of the REST, gRPC, HTTPS redirect, and debug servers, then
+// serves each of them in a goroutine of the group. The ports are bound before it returns,
+// so a port that cannot be bound (e.g., one already in use) fails the startup before any
+// server accepts requests. A server that fails later returns its error to the group,
+// which cancels the group's context.
+//
+// Parameters:
+//   - g: The group running the servers
+//
+// Returns:
+//   - An error if a port cannot be bound; no server is started in that case
+func (a *App) startServers(g *errgroup.Group) error {
+	restListener, err := net.Listen("tcp", a.restServer.Addr)
+	if err != nil {
+		return fmt.Errorf("REST server cannot listen on %s: %w", a.restServer.Addr, err)
+	}
+	listeners := []net.Listener{restListener}
+	listen := func(name, addr string) (net.Listener, error) {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("%s cannot listen on %s: %w", name, addr, err)
 		}
-	}()
+		listeners = append(listeners, l)
+		return l, nil
+	}
+	var redirectListener, debugListener net.Listener
+	if a.redirectServer != nil {
+		if redirectListener, err = listen("HTTPS redirect server", a.config.RESTRedirectAddr); err != nil {
+			return err
+		}
+	}
+	if a.debugServer != nil {
+		if debugListener, err = listen("debug server", a.config.DebugAddr); err != nil {
+			return err
+		}
+	}
 
-	// Start gRPC server in a separate goroutine
-	go func() {
+	// Report the REST server as listening to the readiness probe while it serves requests
+	a.restListening.Store(true)
+	g.Go(func() error {
+		defer a.restListening.Store(false)
+		scheme := "HTTP"
+		if a.restServer.TLSConfig != nil {
+			scheme = "HTTPS"
+		}
+		log.Printf("Starting REST server on %s (%s)", a.config.RESTPort, scheme)
+
+		// Serve blocks until the server is stopped or encounters an error
+		// The certificate is already loaded into the TLS configuration
+		if a.restServer.TLSConfig != nil {
+			return serverError("REST server", a.restServer.ServeTLS(restListener, "", ""))
+		}
+		return serverError("REST server", a.restServer.Serve(restListener))
+	})
+
+	g.Go(func() error {
 		log.Printf("Starting gRPC server on %s", a.config.GRPCPort)
 		// Start blocks until the server is stopped or encounters an error
-		if err := a.grpcServer.Start(); err != nil {
-			log.Printf("gRPC server failed: %v", err)
-		}
-	}()
+		return serverError("gRPC server", a.grpcServer.Start())
+	})
+
+	if redirectListener != nil {
+		g.Go(func() error {
+			log.Printf("Starting HTTPS redirect server on %s", a.config.RESTRedirectAddr)
+			return serverError("HTTPS redirect server", a.redirectServer.Serve(redirectListener))
+		})
+	}
 
+	if debugListener != nil {
+		g.Go(func() error {
+			log.Printf("Starting debug server on %s", a.config.DebugAddr)
+			return serverError("debug server", a.debugServer.Serve(debugListener))
+		})
+	}
+
+	return nil
+}
+
+// serverError returns the error a server stopped with, or nil if it was shut down normally.
+func serverError(name string, err error) error {
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	log.Printf("%s failed: %v", name, err)
+	return fmt.Errorf("%s failed: %w", name, err)
+}
+
+// checkRESTListening reports whether the REST server is accepting connections.
+// It is used by the readiness probe.
+func (a *App) checkRESTListening(ctx context.Context) error {
+	if !a.restListening.Load() {
+		return errors.New("REST server is not listening")
+	}
+	return nil
+}
+
+// checkStarted reports whether startup has finished.
+// It is used by the startup probe.
+func (a *App) checkStarted(ctx context.Context) error {
+	if !a.started.Load() {
+		return errors.New("initialization in progress")
+	}
 	return nil
 }
 
 // waitForShutdown waits for the context to be canceled (e.g., by an interrupt signal)
-// and then gracefully shuts down the servers.
-// This method blocks until the context is canceled and the servers are shut down.
+// and then gracefully shuts down the application by running the shutdown hooks.
+// This method blocks until the context is canceled and the hooks have finished.
 func (a *App) waitForShutdown(ctx context.Context) error {
 	// Block until the context is canceled (e.g., by Ctrl+C)
 	<-ctx.Done()
 	log.Println("Shutting down servers...")
 
-	// Create a new context with a 5-second timeout for the shutdown process
+	// Create a new context with a timeout for the whole shutdown process
 	// This ensures that shutdown doesn't hang indefinitely
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownDrainTimeout+shutdownTimeout)
 	defer cancel() // Ensure the context is canceled when the function returns
 
-	// Gracefully shut down the REST server
-	// This allows in-flight requests to complete before shutting down
-	if err := a.restServer.Shutdown(shutdownCtx); err != nil {
-		log.Printf("REST server shutdown failed: %v", err)
-	}
-
-	// Close the storage connection
-	// This ensures any database connections are properly closed
-	if err := a.storage.Close(shutdownCtx); err != nil {
-		log.Printf("Storage shutdown failed: %v", err)
-	}
+	// Failures are logged by the hooks runner; shutdown carries on regardless
+	_ = a.Shutdown(shutdownCtx)
 
 	log.Println("Servers stopped")
 	// Return the original context's error (typically context.Canceled)
@@ -255,11 +1154,8 @@ func (a *App) createSampleNotes(ctx context.Context) error {
 
 	// Create each sample note in the storage
 	for _, note := range notes {
-		// Create a new Note object with the title and content
-		n := model.NewNote(note.title, note.content)
-
-		// Try to save the note to the storage
-		err := a.storage.Create(ctx, n)
+		// Try to save the note to the storage; the service generates its ID and timestamps
+		_, err := a.notes.Create(ctx, service.NoteInput{Title: note.title, Content: note.content})
 		if err != nil {
 			// If the note already exists (duplicate key error), skip it and continue
 			if isDuplicateKeyError(err) {
@@ -2,14 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"golang-simple-notes/metrics"
 	"golang-simple-notes/model"
+	"golang-simple-notes/service"
 	"golang-simple-notes/storage"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/sync/errgroup"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -196,6 +206,7 @@ func TestApp_CreateSampleNotes(t *testing.T) {
 
 	// Initialize with mock storage
 	app.storage = storage.NewInMemoryStorage()
+	app.notes = service.New(app.storage)
 
 	err := app.createSampleNotes(ctx)
 	if err != nil {
@@ -250,10 +261,11 @@ func TestApp_CreateSampleNotes(t *testing.T) {
 	}
 
 	// Test error handling with a custom mock that always returns an error
-	app.storage = &ErrorMockStorage{}
+	app.storage = newCreateFailingStorage()
+	app.notes = service.New(app.storage)
 	err = app.createSampleNotes(ctx)
 	if err == nil {
-		t.Error("Expected error from createSampleNotes with ErrorMockStorage")
+		t.Error("Expected error from createSampleNotes when notes cannot be created")
 	}
 }
 
@@ -291,10 +303,6 @@ func TestApp_WaitForShutdown(t *testing.T) {
 
 // TestApp_StorageFallback tests the fallback to in-memory storage when CouchDB/MongoDB fails
 func TestApp_StorageFallback(t *testing.T) {
-	// Speed up failure paths by reducing retry/timeout for external DB clients
-	t.Setenv("COUCHDB_MAX_ATTEMPTS", "1")
-	t.Setenv("COUCHDB_RETRY_DELAY_MS", "100")
-	t.Setenv("MONGODB_CONNECT_TIMEOUT_MS", "500")
 	testCases := []struct {
 		name        string
 		config      *Config
@@ -322,22 +330,43 @@ func TestApp_StorageFallback(t *testing.T) {
 				MongoDBCollection: "notes",
 				RESTPort:          ":8080",
 				GRPCPort:          ":8081",
+
+				// A single, short connection attempt speeds up the failure
+				StorageRetryAttemptTimeout: 500 * time.Millisecond,
 			},
 			shouldFail:  false,
 			description: "Should fallback to in-memory storage when MongoDB is unavailable",
 		},
+		{
+			name: "Strict Mode",
+			config: &Config{
+				StorageType:   "couchdb",
+				CouchDBURL:    "http://invalid-url:5984",
+				CouchDBName:   "notes",
+				StorageStrict: true,
+				RESTPort:      ":8080",
+				GRPCPort:      ":8081",
+			},
+			shouldFail:  true,
+			description: "Should fail instead of falling back when strict storage mode is enabled",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			app := NewApp(tc.config)
 			ctx := context.Background()
+			metrics.StorageFallbackActive.Set(0)
+			fallbacks := testutil.ToFloat64(metrics.StorageFallbacks.WithLabelValues(tc.config.StorageType))
 
 			err := app.Initialize(ctx)
 			if tc.shouldFail {
 				if err == nil {
 					t.Error("Expected initialization to fail")
 				}
+				if testutil.ToFloat64(metrics.StorageFallbackActive) != 0 {
+					t.Error("Expected no fallback to be reported")
+				}
 				return
 			}
 
@@ -345,6 +374,17 @@ func TestApp_StorageFallback(t *testing.T) {
 				t.Fatalf("Failed to initialize app: %v", err)
 			}
 
+			// The fallback is reported in the metrics
+			if got := testutil.ToFloat64(metrics.StorageFallbacks.WithLabelValues(tc.config.StorageType)); got != fallbacks+1 {
+				t.Errorf("Expected the fallback counter to be incremented, got %v", got)
+			}
+			if testutil.ToFloat64(metrics.StorageFallbackActive) != 1 {
+				t.Error("Expected the fallback gauge to be set")
+			}
+			if got := testutil.ToFloat64(metrics.StorageBackend.WithLabelValues("memory", tc.config.StorageType)); got != 1 {
+				t.Errorf("Expected the backend gauge to identify the fallback, got %v", got)
+			}
+
 			// Verify that we're using in-memory storage
 			note := &model.Note{
 				ID:      "test-id",
@@ -374,6 +414,32 @@ func TestApp_StorageFallback(t *testing.T) {
 	}
 }
 
+// TestApp_StorageCanceled tests that canceling startup stops connecting to the storage
+// right away, without falling back to in-memory storage
+func TestApp_StorageCanceled(t *testing.T) {
+	app := NewApp(&Config{
+		StorageType:              "couchdb",
+		CouchDBURL:               "http://invalid-url:5984",
+		CouchDBName:              "notes",
+		StorageRetryMaxAttempts:  10,
+		StorageRetryInitialDelay: time.Minute,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	metrics.StorageFallbackActive.Set(0)
+
+	start := time.Now()
+	if _, err := app.initializeStorage(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected startup to stop right away, took %v", elapsed)
+	}
+	if testutil.ToFloat64(metrics.StorageFallbackActive) != 0 {
+		t.Error("Expected no fallback to be reported")
+	}
+}
+
 // TestApp_GracefulShutdown tests proper cleanup during shutdown
 func TestApp_GracefulShutdown(t *testing.T) {
 	config := &Config{
@@ -570,16 +636,16 @@ func TestApp_StartServers(t *testing.T) {
 	app.restServer = app.setupRESTServer()
 	app.grpcServer = app.setupGRPCServer()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
-
-	err := app.startServers(ctx)
+	var g errgroup.Group
+	err := app.startServers(&g)
 	if err != nil {
 		t.Fatalf("Failed to start servers: %v", err)
 	}
 
-	// Give servers time to start
-	time.Sleep(10 * time.Millisecond)
+	// The port is bound before startServers returns
+	if err := app.checkRESTListening(context.Background()); err != nil {
+		t.Errorf("Expected the REST server to be listening: %v", err)
+	}
 
 	// Clean up
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -587,7 +653,45 @@ func TestApp_StartServers(t *testing.T) {
 	if err := app.restServer.Shutdown(shutdownCtx); err != nil {
 		t.Logf("rest shutdown error: %v", err)
 	}
-	// gRPC server cleanup is handled by the context timeout
+	// A server that is shut down normally doesn't report an error
+	if err := g.Wait(); err != nil {
+		t.Errorf("Expected no server error, got %v", err)
+	}
+}
+
+// TestApp_Run_PortInUse tests that a port that cannot be bound aborts the startup
+func TestApp_Run_PortInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer taken.Close()
+
+	for name, config := range map[string]*Config{
+		"REST":  {StorageType: "memory", RESTPort: taken.Addr().String(), GRPCPort: ":0"},
+		"Debug": {StorageType: "memory", RESTPort: "127.0.0.1:0", GRPCPort: ":0", DebugAddr: taken.Addr().String()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := NewApp(config)
+			if err := app.Initialize(context.Background()); err != nil {
+				t.Fatalf("Failed to initialize app: %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- app.Run(context.Background()) }()
+			select {
+			case err := <-done:
+				if err == nil || errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "cannot listen on") {
+					t.Errorf("Expected the startup to fail, got %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Run didn't return after failing to bind the port")
+			}
+			if app.restListening.Load() {
+				t.Error("Expected the REST server not to be listening")
+			}
+		})
+	}
 }
 
 func TestApp_IsDuplicateKeyError(t *testing.T) {
@@ -615,10 +719,6 @@ func TestApp_IsDuplicateKeyError(t *testing.T) {
 }
 
 func TestApp_InitializeStorageError(t *testing.T) {
-	// Speed up failure paths by reducing retry/timeout for external DB clients
-	t.Setenv("COUCHDB_MAX_ATTEMPTS", "1")
-	t.Setenv("COUCHDB_RETRY_DELAY_MS", "100")
-	t.Setenv("MONGODB_CONNECT_TIMEOUT_MS", "500")
 	testCases := []struct {
 		name        string
 		storageType string
@@ -658,3 +758,285 @@ func TestApp_InitializeStorageError(t *testing.T) {
 		})
 	}
 }
+
+func TestApp_InitializeWithEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	t.Run("Enabled", func(t *testing.T) {
+		app := NewApp(&Config{
+			StorageType:         "memory",
+			RESTPort:            ":8080",
+			GRPCPort:            ":8081",
+			EncryptionKeys:      "k1:" + key,
+			EncryptionActiveKey: "k1",
+		})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		if app.encrypted == nil {
+			t.Fatal("Expected encryption decorator to be installed")
+		}
+
+		result, err := app.RotateEncryptionKeys(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to rotate keys: %v", err)
+		}
+		if result.Failed != 0 {
+			t.Errorf("Expected no rotation failures, got %d", result.Failed)
+		}
+	})
+
+	t.Run("InvalidKeys", func(t *testing.T) {
+		app := NewApp(&Config{
+			StorageType:         "memory",
+			EncryptionKeys:      "k1:" + key,
+			EncryptionActiveKey: "missing",
+		})
+		if err := app.Initialize(context.Background()); err == nil {
+			t.Error("Expected initialization to fail with an unknown active key")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory"})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		if _, err := app.RotateEncryptionKeys(context.Background()); err == nil {
+			t.Error("Expected rotation to fail when encryption is disabled")
+		}
+	})
+}
+
+func TestApp_InitializeWithDualWrite(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		app := NewApp(&Config{
+			StorageType:     "memory",
+			RESTPort:        ":8080",
+			GRPCPort:        ":8081",
+			DualWriteTarget: "memory",
+			DualWriteVerify: true,
+		})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		defer func() { _ = app.storage.Close(context.Background()) }()
+		if app.verifier == nil {
+			t.Fatal("Expected dual-write verifier to be installed")
+		}
+
+		req := httptest.NewRequest("GET", "/api/migration/divergences", nil)
+		w := httptest.NewRecorder()
+		app.restServer.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("SameBackend", func(t *testing.T) {
+		app := NewApp(&Config{
+			StorageType:     "mongodb",
+			DualWriteTarget: "mongodb",
+		})
+		if err := app.Initialize(context.Background()); err == nil {
+			t.Error("Expected dual-write to the same backend to be rejected")
+		}
+	})
+}
+
+func TestApp_DebugServer(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory"})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		if app.debugServer != nil {
+			t.Error("Expected no debug server without DebugAddr")
+		}
+
+		// Debug endpoints are never exposed on the public REST server
+		req := httptest.NewRequest("GET", "/debug/vars", nil)
+		w := httptest.NewRecorder()
+		app.restServer.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d on the REST server, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory", DebugAddr: "localhost:6060", DebugToken: "secret"})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		if app.debugServer == nil || app.debugServer.Addr != "localhost:6060" {
+			t.Fatalf("Expected debug server on localhost:6060, got %+v", app.debugServer)
+		}
+
+		req := httptest.NewRequest("GET", "/debug/vars", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		app.debugServer.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:6060": true,
+		"127.0.0.1:6060": true,
+		"[::1]:6060":     true,
+		":6060":          false,
+		"0.0.0.0:6060":   false,
+		"10.0.0.5:6060":  false,
+		"invalid":        false,
+	}
+	for addr, want := range tests {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("isLoopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// TestApp_HealthProbes tests that the startup and readiness probes follow the application lifecycle
+func TestApp_HealthProbes(t *testing.T) {
+	app := NewApp(&Config{StorageType: "memory", RESTPort: "127.0.0.1:0", GRPCPort: ":0"})
+	if err := app.Initialize(context.Background()); err != nil {
+		t.Fatalf("Failed to initialize app: %v", err)
+	}
+
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		app.restServer.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+
+	// Before Run, the process is alive but neither started nor ready
+	if code := probe("/health/live"); code != http.StatusOK {
+		t.Errorf("Expected live probe to succeed, got %d", code)
+	}
+	if code := probe("/health/startup"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected startup probe to fail before Run, got %d", code)
+	}
+	if code := probe("/health/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected ready probe to fail before the server listens, got %d", code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for probe("/health/startup") != http.StatusOK || probe("/health/ready") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("Probes did not succeed after Run: startup %d, ready %d",
+				probe("/health/startup"), probe("/health/ready"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	if code := probe("/health/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected ready probe to fail after shutdown, got %d", code)
+	}
+}
+
+// TestApp_RESTServerLimits tests that the REST server uses the configured timeouts and limits
+func TestApp_RESTServerLimits(t *testing.T) {
+	app := NewApp(&Config{
+		StorageType:           "memory",
+		RESTPort:              ":8080",
+		HTTPReadHeaderTimeout: 2 * time.Second,
+		HTTPReadTimeout:       10 * time.Second,
+		HTTPWriteTimeout:      20 * time.Second,
+		HTTPIdleTimeout:       time.Minute,
+		HTTPMaxHeaderBytes:    8192,
+		DebugAddr:             "localhost:6060",
+	})
+	if err := app.Initialize(context.Background()); err != nil {
+		t.Fatalf("Failed to initialize app: %v", err)
+	}
+
+	server := app.restServer
+	if server.ReadHeaderTimeout != 2*time.Second || server.ReadTimeout != 10*time.Second ||
+		server.WriteTimeout != 20*time.Second || server.IdleTimeout != time.Minute {
+		t.Errorf("Unexpected REST server timeouts: read header %v, read %v, write %v, idle %v",
+			server.ReadHeaderTimeout, server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+	if server.MaxHeaderBytes != 8192 {
+		t.Errorf("Expected MaxHeaderBytes 8192, got %d", server.MaxHeaderBytes)
+	}
+
+	// Profiles take longer than the write timeout, so the debug server must not use it
+	if app.debugServer.ReadHeaderTimeout != 2*time.Second || app.debugServer.WriteTimeout != 0 {
+		t.Errorf("Unexpected debug server timeouts: read header %v, write %v",
+			app.debugServer.ReadHeaderTimeout, app.debugServer.WriteTimeout)
+	}
+}
+
+// TestApp_RESTServerH2C tests that the REST server accepts HTTP/2 without TLS only when h2c is enabled
+func TestApp_RESTServerH2C(t *testing.T) {
+	for _, h2c := range []bool{true, false} {
+		t.Run(fmt.Sprintf("h2c=%t", h2c), func(t *testing.T) {
+			app := NewApp(&Config{StorageType: "memory", RESTPort: "127.0.0.1:0", RESTH2C: h2c, HTTP2MaxConcurrentStreams: 50})
+			if err := app.Initialize(context.Background()); err != nil {
+				t.Fatalf("Failed to initialize app: %v", err)
+			}
+			if app.restServer.HTTP2 == nil || app.restServer.HTTP2.MaxConcurrentStreams != 50 {
+				t.Errorf("Expected HTTP/2 configuration with 50 streams, got %+v", app.restServer.HTTP2)
+			}
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			go func() { _ = app.restServer.Serve(listener) }()
+			defer func() { _ = app.restServer.Close() }()
+
+			// A client that only speaks HTTP/2 with prior knowledge
+			protocols := new(http.Protocols)
+			protocols.SetUnencryptedHTTP2(true)
+			client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+			resp, err := client.Get("http://" + listener.Addr().String() + "/health/live")
+			if !h2c {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Error("Expected an HTTP/2 request without TLS to fail when h2c is disabled")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HTTP/2 request failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected HTTP/2 200 OK, got %s %d", resp.Proto, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestApp_WebUI tests that the web UI is only served when it is enabled
+func TestApp_WebUI(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			app := NewApp(&Config{StorageType: "memory", RESTPort: "127.0.0.1:0", UIEnabled: enabled})
+			app.storage = storage.NewInMemoryStorage()
+			handler := app.setupRESTServer().Handler
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/ui/", nil))
+			if enabled && (w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "app.js")) {
+				t.Errorf("Expected the UI page, got %d", w.Code)
+			}
+			if !enabled && w.Code != http.StatusNotFound {
+				t.Errorf("Expected 404 Not Found with the UI disabled, got %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Package blob stores large files, such as exports, outside of the note storage, in an
+// object store from which clients download them directly, so that they don't stream
+// through the API server.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when a blob with the specified key doesn't exist.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores files by key (e.g., "exports/notes-20250115-030000.ndjson").
+// Implementations must be safe for concurrent use.
+type BlobStore interface {
+	// Put stores a file, replacing any file with the same key. The size of the body must
+	// be known in advance.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get returns the content of a file, which the caller must close.
+	// It returns ErrNotFound if the file doesn't exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes a file. Removing a file that doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// DownloadURL returns a URL from which the file can be downloaded without credentials
+	// until the expiry has passed.
+	DownloadURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
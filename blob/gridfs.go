@@ -0,0 +1,157 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+
+	"golang-simple-notes/storage"
+)
+
+// GridFSStore is a BlobStore backed by MongoDB GridFS, which splits files into chunks
+// stored in the database, so that deployments running MongoDB need nothing else.
+// MongoDB cannot serve files over HTTP, so downloads go through the API server, from
+// URLs issued by a URLSigner.
+type GridFSStore struct {
+	client *mongo.Client
+	bucket *gridfs.Bucket
+	urls   *URLSigner
+}
+
+// NewGridFSStore connects to MongoDB and opens a GridFS bucket, stored in the collections
+// <bucketName>.files and <bucketName>.chunks of the database.
+//
+// Parameters:
+//   - ctx: The context for connecting; canceling it stops the retries
+//   - uri: The MongoDB connection string
+//   - dbName: The name of the database
+//   - bucketName: The name of the GridFS bucket
+//   - urls: The signer of the download URLs
+//   - retry: The policy for retrying the connection while the server is not reachable
+//
+// Returns:
+//   - A pointer to a new GridFSStore instance
+//   - An error if the connection fails
+func NewGridFSStore(ctx context.Context, uri, dbName, bucketName string, urls *URLSigner, retry storage.RetryPolicy) (*GridFSStore, error) {
+	var client *mongo.Client
+	err := retry.Retry(ctx, "connect to MongoDB GridFS", func(ctx context.Context) error {
+		c, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetMonitor(otelmongo.NewMonitor()))
+		if err != nil {
+			return err
+		}
+		if err := c.Ping(ctx, nil); err != nil {
+			_ = c.Disconnect(context.WithoutCancel(ctx))
+			return fmt.Errorf("failed to ping MongoDB: %w", err)
+		}
+		client = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := gridfs.NewBucket(client.Database(dbName), options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		_ = client.Disconnect(context.WithoutCancel(ctx))
+		return nil, fmt.Errorf("failed to open GridFS bucket: %w", err)
+	}
+	return &GridFSStore{client: client, bucket: bucket, urls: urls}, nil
+}
+
+// Put uploads a file in chunks, then removes the previous files with the same key, so
+// that readers see either the previous or the new file.
+func (s *GridFSStore) Put(ctx context.Context, key string, body io.Reader, _ int64, contentType string) error {
+	upload, err := s.bucket.OpenUploadStream(key, options.GridFSUpload().SetMetadata(bson.D{{Key: "contentType", Value: contentType}}))
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = upload.SetWriteDeadline(deadline)
+	}
+	if _, err := io.Copy(upload, contextReader{ctx: ctx, r: body}); err != nil {
+		_ = upload.Abort()
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	if err := upload.Close(); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return s.deleteFiles(ctx, bson.D{{Key: "filename", Value: key}, {Key: "_id", Value: bson.D{{Key: "$ne", Value: upload.FileID}}}})
+}
+
+// Get returns the latest file with the key.
+func (s *GridFSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	download, err := s.bucket.OpenDownloadStreamByName(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = download.SetReadDeadline(deadline)
+	}
+	return download, nil
+}
+
+// Delete removes every file with the key.
+func (s *GridFSStore) Delete(ctx context.Context, key string) error {
+	return s.deleteFiles(ctx, bson.D{{Key: "filename", Value: key}})
+}
+
+// DownloadURL returns a signed URL of the download endpoint of the API server.
+func (s *GridFSStore) DownloadURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return s.urls.Sign(key, expiry), nil
+}
+
+// VerifyDownload checks a download URL issued by DownloadURL.
+func (s *GridFSStore) VerifyDownload(key string, query url.Values) error {
+	return s.urls.VerifyDownload(key, query)
+}
+
+// Close disconnects from MongoDB.
+func (s *GridFSStore) Close(ctx context.Context) error {
+	if err := s.client.Disconnect(ctx); err != nil {
+		return fmt.Errorf("failed to disconnect from MongoDB: %w", err)
+	}
+	return nil
+}
+
+// deleteFiles removes the files matching a filter of the files collection, with their chunks.
+func (s *GridFSStore) deleteFiles(ctx context.Context, filter bson.D) error {
+	cursor, err := s.bucket.FindContext(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find files: %w", err)
+	}
+	var files []gridfs.File
+	if err := cursor.All(ctx, &files); err != nil {
+		return fmt.Errorf("failed to find files: %w", err)
+	}
+	for _, file := range files {
+		if err := s.bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// contextReader stops reading once the context is done, so that uploads can be canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+
+	"golang-simple-notes/storage"
+)
+
+// TestGridFSStore tests uploads, replacements, downloads, and deletions against a MongoDB container
+func TestGridFSStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping GridFS integration test in short mode")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+	container, err := mongodb.Run(ctx, "mongo:7.0.28-jammy")
+	if err != nil {
+		t.Skipf("MongoDB container not available: %v", err)
+	}
+	t.Cleanup(func() { _ = container.Terminate(context.Background()) })
+	uri, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get the connection string: %v", err)
+	}
+
+	signer := NewURLSigner("/api/blobs", []byte("secret"))
+	store, err := NewGridFSStore(ctx, uri, "test_blobs", "blobs", signer, storage.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second})
+	if err != nil {
+		t.Fatalf("NewGridFSStore failed: %v", err)
+	}
+	defer store.Close(ctx)
+
+	read := func(key string) string {
+		t.Helper()
+		body, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", key, err)
+		}
+		return string(data)
+	}
+
+	// Larger than a chunk (255 KiB), so the file is split
+	large := strings.Repeat("note ", 100000)
+	if err := store.Put(ctx, "exports/a.ndjson", strings.NewReader(large), int64(len(large)), "application/x-ndjson"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := read("exports/a.ndjson"); got != large {
+		t.Errorf("Expected the large file back, got %d bytes", len(got))
+	}
+
+	if err := store.Put(ctx, "exports/a.ndjson", bytes.NewReader([]byte("replaced")), 8, "application/x-ndjson"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := read("exports/a.ndjson"); got != "replaced" {
+		t.Errorf("Expected the replaced file, got %q", got)
+	}
+	if count, err := store.bucket.GetFilesCollection().CountDocuments(ctx, map[string]string{"filename": "exports/a.ndjson"}); err != nil || count != 1 {
+		t.Errorf("Expected the previous file to be removed, got %d files: %v", count, err)
+	}
+
+	if err := store.Delete(ctx, "exports/a.ndjson"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "exports/a.ndjson"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "exports/a.ndjson"); err != nil {
+		t.Errorf("Expected deleting a missing file to succeed, got %v", err)
+	}
+
+	signed, _ := store.DownloadURL(ctx, "exports/a.ndjson", time.Minute)
+	u, _ := url.Parse(signed)
+	if u.Path != "/api/blobs/exports/a.ndjson" || store.VerifyDownload("exports/a.ndjson", u.Query()) != nil {
+		t.Errorf("Expected a valid URL of the download endpoint, got %s", signed)
+	}
+}
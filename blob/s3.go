@@ -0,0 +1,270 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 request signing (AWS Signature Version 4).
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD" // Payloads are streamed, so they aren't hashed
+	signedHeaders    = "host;x-amz-content-sha256;x-amz-date"
+
+	// maxURLExpiry is the longest validity of a presigned URL accepted by S3.
+	maxURLExpiry = 7 * 24 * time.Hour
+)
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	Endpoint  string // URL of the S3 API (e.g., https://s3.eu-west-1.amazonaws.com or http://minio:9000)
+	Region    string // Region of the bucket (e.g., us-east-1, which MinIO uses by default)
+	Bucket    string // Bucket holding the files
+	AccessKey string // Access key ID
+	SecretKey string // Secret access key
+	PathStyle bool   // Address the bucket in the path (http://minio:9000/bucket/key) instead of the host name
+}
+
+// S3Store is a BlobStore backed by Amazon S3 or an S3-compatible service, such as MinIO.
+// Requests are signed with AWS Signature Version 4, and downloads go through presigned URLs.
+type S3Store struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time // Time of the signatures, replaced in tests
+}
+
+// s3Error is the XML body of an S3 error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewS3Store creates a blob store backed by an S3 bucket. No request is sent until the
+// store is used, so a bucket that doesn't exist is only reported then.
+//
+// Returns:
+//   - A pointer to a new S3Store instance
+//   - An error if the configuration is incomplete or the endpoint isn't an http(s) URL
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: must be an http(s) URL", config.Endpoint)
+	}
+	if config.Bucket == "" || config.Region == "" {
+		return nil, fmt.Errorf("the S3 bucket and region are required")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("the S3 access key and secret key are required")
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	return &S3Store{config: config, endpoint: endpoint, client: &http.Client{}, now: time.Now}, nil
+}
+
+// Put uploads a file with a single PUT request.
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return fmt.Errorf("the size of %s is required", key)
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		// An empty body is sent as such, not chunked
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return resp.Body.Close()
+}
+
+// Get downloads a file.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes a file. S3 reports success for files that don't exist.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return resp.Body.Close()
+}
+
+// DownloadURL returns a presigned GET URL of a file, valid for the given expiry (at most
+// seven days). It is computed locally, so it is returned even if the file doesn't exist.
+func (s *S3Store) DownloadURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	if expiry < time.Second || expiry > maxURLExpiry {
+		return "", fmt.Errorf("the expiry of a download URL must be between 1s and %v", maxURLExpiry)
+	}
+	u := s.objectURL(key)
+	now := s.now().UTC()
+	query := map[string]string{
+		"X-Amz-Algorithm":     signingAlgorithm,
+		"X-Amz-Credential":    s.config.AccessKey + "/" + s.scope(now),
+		"X-Amz-Date":          now.Format(amzDateFormat),
+		"X-Amz-Expires":       strconv.Itoa(int(expiry / time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	canonicalQuery := encodeQuery(query)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet, u.RawPath, canonicalQuery, "host:" + u.Host + "\n", "host", unsignedPayload,
+	}, "\n")
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+	return u.String(), nil
+}
+
+// newRequest creates a request for a file, to be signed by do.
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request for %s: %w", key, err)
+	}
+	return req, nil
+}
+
+// do signs and sends a request.
+//
+// Returns:
+//   - The response, if its status code is 2xx
+//   - ErrNotFound for a 404 Not Found, or an error with the code and message of S3 otherwise
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	s.sign(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var body s3Error
+	// HEAD responses and some proxies have no XML body; the status is reported then
+	_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode == http.StatusNotFound && body.Code != "NoSuchBucket" {
+		return nil, ErrNotFound
+	}
+	if body.Code == "" {
+		return nil, fmt.Errorf("S3 responded with %s", resp.Status)
+	}
+	return nil, fmt.Errorf("S3 responded with %s: %s: %s", resp.Status, body.Code, body.Message)
+}
+
+// sign adds the Authorization header of Signature Version 4 to a request, without hashing the payload.
+func (s *S3Store) sign(req *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders, signedHeaders, unsignedPayload,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, s.config.AccessKey, s.scope(now), signedHeaders, s.signature(now, canonicalRequest)))
+}
+
+// signature signs a canonical request made at the given time.
+func (s *S3Store) signature(now time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		signingAlgorithm, now.Format(amzDateFormat), s.scope(now), hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.config.SecretKey)
+	for _, part := range []string{now.Format("20060102"), s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// scope returns the credential scope of a signature made at the given time.
+func (s *S3Store) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+// objectURL returns the URL of a file, with the bucket in the path or in the host name.
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	path := u.Path + "/" + key
+	if s.config.PathStyle {
+		path = u.Path + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+	}
+	u.Path, u.RawPath = path, escape(path, true)
+	return &u
+}
+
+// encodeQuery encodes query parameters in the canonical form of Signature Version 4: sorted by name, and escaped.
+func encodeQuery(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = escape(name, false) + "=" + escape(params[name], false)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escape percent-encodes every byte but the unreserved characters (and slashes, if kept),
+// as Signature Version 4 requires.
+func escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && keepSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
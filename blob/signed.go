@@ -0,0 +1,74 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidURL is returned when a download URL has a wrong signature or has expired.
+var ErrInvalidURL = errors.New("invalid or expired download URL")
+
+// URLVerifier is implemented by blob stores whose download URLs point to the API server
+// instead of the store itself, which serves the files once it has verified the URL.
+type URLVerifier interface {
+	// VerifyDownload checks the query parameters of a download URL of a file.
+	// It returns ErrInvalidURL if the URL wasn't issued by the store, or has expired.
+	VerifyDownload(key string, query url.Values) error
+}
+
+// URLSigner issues download URLs served by the API server, authenticated by an
+// HMAC-SHA256 signature of the key and the expiry time, and verifies them.
+type URLSigner struct {
+	baseURL string // URL the keys are appended to (e.g., https://notes.example.com/api/blobs)
+	secret  []byte
+	now     func() time.Time // Current time, replaced in tests
+}
+
+// NewURLSigner creates a URL signer.
+//
+// Parameters:
+//   - baseURL: The URL of the download endpoint, without a trailing slash; relative URLs are allowed
+//   - secret: The key of the signatures; every instance serving downloads needs the same key
+//
+// Returns:
+//   - A pointer to a new URLSigner instance
+func NewURLSigner(baseURL string, secret []byte) *URLSigner {
+	return &URLSigner{baseURL: baseURL, secret: secret, now: time.Now}
+}
+
+// Sign returns the download URL of a file, valid until the expiry has passed.
+func (s *URLSigner) Sign(key string, expiry time.Duration) string {
+	expires := strconv.FormatInt(s.now().Add(expiry).Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.signature(key, expires)}}
+	return s.baseURL + "/" + escape(key, true) + "?" + query.Encode()
+}
+
+// VerifyDownload checks the signature and the expiry time of a download URL.
+func (s *URLSigner) VerifyDownload(key string, query url.Values) error {
+	expires := query.Get("expires")
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidURL
+	}
+	want, _ := hex.DecodeString(s.signature(key, expires))
+	if !hmac.Equal(signature, want) {
+		return ErrInvalidURL
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > unix {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// signature returns the hex-encoded signature of a key and an expiry time.
+func (s *URLSigner) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,46 @@
+package blob
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestURLSigner tests that signed URLs are accepted until they expire, and only for their key
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("https://notes.example.com/api/blobs", []byte("secret"))
+	now := time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+	signer.now = func() time.Time { return now }
+
+	signed := signer.Sign("exports/notes 1.ndjson", time.Minute)
+	if !strings.HasPrefix(signed, "https://notes.example.com/api/blobs/exports/notes%201.ndjson?expires=") {
+		t.Fatalf("Unexpected URL %s", signed)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("Invalid URL %s: %v", signed, err)
+	}
+	query := u.Query()
+
+	if err := signer.VerifyDownload("exports/notes 1.ndjson", query); err != nil {
+		t.Errorf("Expected the URL to be valid, got %v", err)
+	}
+	if err := signer.VerifyDownload("exports/other.ndjson", query); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Expected the URL to be invalid for another key, got %v", err)
+	}
+	if err := NewURLSigner("", []byte("other")).VerifyDownload("exports/notes 1.ndjson", query); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Expected the URL to be invalid with another secret, got %v", err)
+	}
+
+	extended := url.Values{"expires": {"99999999999"}, "signature": {query.Get("signature")}}
+	if err := signer.VerifyDownload("exports/notes 1.ndjson", extended); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Expected a changed expiry to be rejected, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := signer.VerifyDownload("exports/notes 1.ndjson", query); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Expected the URL to have expired, got %v", err)
+	}
+}
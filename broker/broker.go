@@ -0,0 +1,119 @@
+// Package broker publishes note lifecycle events to message brokers, so event-driven
+// consumers outside the application can react to note changes. Every publisher
+// subscribes to the internal event bus and hands events off to its broker without
+// blocking the write that published them.
+package broker
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/requestid"
+)
+
+// Encodings of published events.
+const (
+	EncodingJSON = "json" // The JSON payload of webhooks (see events.Event)
+	EncodingAvro = "avro" // Avro binary encoding of the NoteEvent schema (see Schema)
+)
+
+// Message headers set on every published event, where the broker supports headers.
+const (
+	HeaderEventType   = "event-type"   // Event type (e.g., "note.created")
+	HeaderContentType = "content-type" // MIME type of the payload
+)
+
+// Publish results recorded in the events_published_total metric.
+const (
+	resultSuccess = "success" // The broker acknowledged the event
+	resultFailure = "failure" // The event could not be published
+	resultDropped = "dropped" // The event was discarded before it reached the broker
+)
+
+// Schema is the Avro schema of published events, for registering with a schema registry.
+// The JSON encoding has the same fields, with RFC 3339 timestamps instead of microseconds.
+//
+//go:embed note_event.avsc
+var Schema string
+
+// encoder turns events into message payloads.
+type encoder struct {
+	contentType string                             // MIME type of the payloads
+	encode      func(events.Event) ([]byte, error) // Encodes a single event
+}
+
+// encoders lists the supported encodings.
+var encoders = map[string]encoder{
+	EncodingJSON: {contentType: "application/json", encode: encodeJSON},
+	EncodingAvro: {contentType: "avro/binary", encode: encodeAvro},
+}
+
+// newEncoder returns the encoder of an encoding; an empty encoding means JSON.
+func newEncoder(encoding string) (encoder, error) {
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	enc, ok := encoders[encoding]
+	if !ok {
+		return encoder{}, fmt.Errorf("unknown event encoding %q (expected %s or %s)", encoding, EncodingJSON, EncodingAvro)
+	}
+	return enc, nil
+}
+
+// headers returns the message headers of an event: its type, the payload's content type,
+// and the ID of the request that changed the note, if any.
+func (e encoder) headers(ctx context.Context, event events.Event) map[string]string {
+	headers := map[string]string{
+		HeaderEventType:   event.Type,
+		HeaderContentType: e.contentType,
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		headers[requestid.Header] = id
+	}
+	return headers
+}
+
+// encodeJSON encodes an event as JSON, exactly like webhook payloads.
+func encodeJSON(event events.Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// encodeAvro encodes an event in the Avro binary encoding of the NoteEvent schema.
+// Longs use zig-zag variable-length encoding, which is what binary.AppendVarint writes.
+func encodeAvro(event events.Event) ([]byte, error) {
+	b := appendAvroString(nil, event.Type)
+	b = appendAvroString(b, event.NoteID)
+	if event.Note == nil {
+		b = binary.AppendVarint(b, 0) // Union branch 0: null
+	} else {
+		b = binary.AppendVarint(b, 1) // Union branch 1: Note
+		b = appendAvroString(b, event.Note.ID)
+		b = appendAvroString(b, event.Note.Title)
+		b = appendAvroString(b, event.Note.Content)
+		b = appendAvroTime(b, event.Note.CreatedAt)
+		b = appendAvroTime(b, event.Note.UpdatedAt)
+	}
+	return appendAvroTime(b, event.Timestamp), nil
+}
+
+// appendAvroString appends an Avro string: its length in bytes, followed by its UTF-8 bytes.
+func appendAvroString(b []byte, s string) []byte {
+	b = binary.AppendVarint(b, int64(len(s)))
+	return append(b, s...)
+}
+
+// appendAvroTime appends a timestamp-micros value.
+func appendAvroTime(b []byte, t time.Time) []byte {
+	return binary.AppendVarint(b, t.UnixMicro())
+}
+
+// record counts a publish result for a broker.
+func record(broker, result string, n int) {
+	metrics.EventsPublished.WithLabelValues(broker, result).Add(float64(n))
+}
@@ -0,0 +1,275 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// avroReader decodes the primitive values of the Avro binary encoding
+type avroReader struct {
+	t *testing.T
+	b []byte
+}
+
+// long decodes a zig-zag encoded long
+func (r *avroReader) long() int64 {
+	r.t.Helper()
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.t.Fatalf("Failed to decode long from %v", r.b)
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+// string decodes a length-prefixed string
+func (r *avroReader) string() string {
+	r.t.Helper()
+	n := int(r.long())
+	if n > len(r.b) {
+		r.t.Fatalf("String length %d exceeds the remaining %d bytes", n, len(r.b))
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// TestEncodeAvro tests that events are encoded in the field order of the Avro schema
+func TestEncodeAvro(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	event := events.Event{
+		Type:      events.NoteUpdated,
+		NoteID:    "note-1",
+		Note:      &model.Note{ID: "note-1", Title: "Título", Content: "Content", CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		Timestamp: created.Add(2 * time.Hour),
+	}
+
+	b, err := encodeAvro(event)
+	if err != nil {
+		t.Fatalf("encodeAvro failed: %v", err)
+	}
+	r := &avroReader{t: t, b: b}
+	if got := r.string(); got != events.NoteUpdated {
+		t.Errorf("Expected event %q, got %q", events.NoteUpdated, got)
+	}
+	if got := r.string(); got != "note-1" {
+		t.Errorf("Expected note_id note-1, got %q", got)
+	}
+	if branch := r.long(); branch != 1 {
+		t.Fatalf("Expected the Note branch of the union, got %d", branch)
+	}
+	for _, want := range []string{"note-1", "Título", "Content"} {
+		if got := r.string(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+	for _, want := range []time.Time{created, created.Add(time.Hour), created.Add(2 * time.Hour)} {
+		if got := r.long(); got != want.UnixMicro() {
+			t.Errorf("Expected timestamp %d, got %d", want.UnixMicro(), got)
+		}
+	}
+	if len(r.b) != 0 {
+		t.Errorf("Expected no trailing bytes, got %v", r.b)
+	}
+
+	// Deleted notes use the null branch
+	deleted, _ := encodeAvro(events.Event{Type: events.NoteDeleted, NoteID: "n", Timestamp: created})
+	r = &avroReader{t: t, b: deleted}
+	r.string()
+	r.string()
+	if branch := r.long(); branch != 0 {
+		t.Errorf("Expected the null branch of the union, got %d", branch)
+	}
+}
+
+// TestSchema tests that the embedded Avro schema is valid JSON describing the NoteEvent record
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Name   string `json:"name"`
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(Schema), &schema); err != nil {
+		t.Fatalf("Failed to parse the schema: %v", err)
+	}
+
+	var names []string
+	for _, field := range schema.Fields {
+		names = append(names, field.Name)
+	}
+	// The JSON encoding has the same top-level fields, in the same order
+	want := []string{"event", "note_id", "note", "timestamp"}
+	if schema.Name != "NoteEvent" || len(names) != len(want) {
+		t.Fatalf("Unexpected schema %s with fields %v", schema.Name, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Expected field %d to be %q, got %q", i, want[i], names[i])
+		}
+	}
+}
+
+// TestEncoderHeaders tests the message headers of an event
+func TestEncoderHeaders(t *testing.T) {
+	enc, err := newEncoder("")
+	if err != nil {
+		t.Fatalf("newEncoder failed: %v", err)
+	}
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	headers := enc.headers(ctx, events.Event{Type: events.NoteCreated})
+	if headers[HeaderEventType] != events.NoteCreated || headers[HeaderContentType] != "application/json" || headers[requestid.Header] != "req-1" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+
+	if _, err := newEncoder("protobuf"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
+
+// TestNewKafkaPublisher tests the validation of the Kafka settings and closing an idle publisher
+func TestNewKafkaPublisher(t *testing.T) {
+	if _, err := NewKafkaPublisher(nil, "notes.events", ""); err == nil {
+		t.Error("Expected an error without brokers")
+	}
+	if _, err := NewKafkaPublisher([]string{"localhost:9092"}, "notes.events", "xml"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+
+	p, err := NewKafkaPublisher([]string{"127.0.0.1:1"}, "notes.events", EncodingAvro)
+	if err != nil {
+		t.Fatalf("NewKafkaPublisher failed: %v", err)
+	}
+	// Nothing listens on port 1
+	if err := p.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail while no broker is reachable")
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+// TestNATSPublisher tests the validation of the NATS settings and publishing while the server is unreachable
+func TestNATSPublisher(t *testing.T) {
+	if got := natsSubject("notes.events", events.Event{Type: events.NoteDeleted}); got != "notes.events.note.deleted" {
+		t.Errorf("Expected subject notes.events.note.deleted, got %q", got)
+	}
+	if _, err := NewNATSPublisher("nats://127.0.0.1:1", "", "", ""); err == nil {
+		t.Error("Expected an error without a subject")
+	}
+	if _, err := NewNATSPublisher("nats://127.0.0.1:1", "notes.events", "", "xml"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+
+	// Nothing listens on port 1, so the event is buffered until the connection is established
+	p, err := NewNATSPublisher("nats://127.0.0.1:1", "notes.events", "", EncodingJSON)
+	if err != nil {
+		t.Fatalf("NewNATSPublisher failed: %v", err)
+	}
+	p.Notify(context.Background(), events.Event{Type: events.NoteCreated, NoteID: "note-1"})
+	if err := p.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail while disconnected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Close(ctx); err == nil {
+		t.Error("Expected an error for events that were never sent")
+	}
+}
+
+// TestNATSPublisherPending tests that events published before the first connection are sent once it is established
+func TestNATSPublisherPending(t *testing.T) {
+	// Find a free port, which nothing listens on yet
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	p, err := NewNATSPublisher("nats://"+addr, "notes.events", "", EncodingJSON)
+	if err != nil {
+		t.Fatalf("NewNATSPublisher failed: %v", err)
+	}
+	defer p.Close(context.Background())
+	p.Notify(context.Background(), events.Event{Type: events.NoteCreated, NoteID: "note-1"})
+
+	// A minimal NATS server, which supports headers and reports the subjects published to
+	listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Port %s was taken in the meantime: %v", addr, err)
+	}
+	defer listener.Close()
+	subjects := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte(`INFO {"server_id":"test","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}` + "\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch fields := strings.Fields(line); {
+			case len(fields) == 0:
+			case fields[0] == "PING":
+				_, _ = conn.Write([]byte("PONG\r\n"))
+			case fields[0] == "HPUB" && len(fields) > 1:
+				subjects <- fields[1]
+			}
+		}
+	}()
+
+	select {
+	case subject := <-subjects:
+		if subject != "notes.events.note.created" {
+			t.Errorf("Expected the pending event on notes.events.note.created, got %q", subject)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the pending event to be published after connecting")
+	}
+}
+
+// TestRabbitMQPublisher tests the validation of the RabbitMQ settings and closing while the broker is unreachable
+func TestRabbitMQPublisher(t *testing.T) {
+	if _, err := NewRabbitMQPublisher("http://localhost", "notes.events", "topic", "", ""); err == nil {
+		t.Error("Expected an error for a URL that isn't an AMQP URL")
+	}
+	if _, err := NewRabbitMQPublisher("amqp://127.0.0.1:1/", "", "topic", "", ""); err == nil {
+		t.Error("Expected an error without an exchange")
+	}
+
+	// Nothing listens on port 1, so the event stays queued until the publisher is closed
+	p, err := NewRabbitMQPublisher("amqp://127.0.0.1:1/", "notes.events", "topic", "", EncodingAvro)
+	if err != nil {
+		t.Fatalf("NewRabbitMQPublisher failed: %v", err)
+	}
+	p.Notify(context.Background(), events.Event{Type: events.NoteCreated, NoteID: "note-1"})
+	if err := p.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail while disconnected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if len(p.queue) != 0 {
+		t.Errorf("Expected the queued event to be dropped, %d left", len(p.queue))
+	}
+}
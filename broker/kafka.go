@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatchTimeout is how long events are collected into a batch before it is sent.
+// Events are sent asynchronously, so this only delays consumers, never writers.
+const kafkaBatchTimeout = 50 * time.Millisecond
+
+// KafkaPublisher publishes note events to a Kafka topic, keyed by note ID, so all events
+// of a note land on the same partition and are consumed in order. Events are batched
+// and sent asynchronously; failures are logged and counted, not retried beyond the
+// writer's attempts. It implements events.Subscriber.
+type KafkaPublisher struct {
+	writer  *kafka.Writer // Asynchronous producer for the topic
+	encoder encoder       // Encoding of the message values
+	brokers []string      // Addresses of the bootstrap brokers, dialed by Ping
+}
+
+// NewKafkaPublisher creates a publisher for a Kafka topic. It doesn't connect to the
+// brokers until the first event is published.
+//
+// Parameters:
+//   - brokers: The addresses of the bootstrap brokers (host:port)
+//   - topic: The topic that receives the events
+//   - encoding: EncodingJSON (the default, if empty) or EncodingAvro
+//
+// Returns:
+//   - A pointer to a new KafkaPublisher instance
+//   - An error if no broker is given or the encoding is unknown
+func NewKafkaPublisher(brokers []string, topic, encoding string) (*KafkaPublisher, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("at least one Kafka broker is required")
+	}
+	enc, err := newEncoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &KafkaPublisher{encoder: enc, brokers: brokers}
+	p.writer = &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},    // Partition by key (the note ID)
+		RequiredAcks: kafka.RequireAll, // Wait for all in-sync replicas, so acknowledged events survive a broker failure
+		Async:        true,
+		BatchTimeout: kafkaBatchTimeout,
+		Completion:   p.completed,
+	}
+	return p, nil
+}
+
+// Notify queues an event for publishing; it doesn't wait for the brokers.
+func (p *KafkaPublisher) Notify(ctx context.Context, event events.Event) {
+	value, err := p.encoder.encode(event)
+	if err != nil {
+		log.Printf("%sFailed to encode %s event for Kafka: %v", requestid.LogPrefix(ctx), event.Type, err)
+		record("kafka", resultDropped, 1)
+		return
+	}
+
+	var headers []kafka.Header
+	for key, value := range p.encoder.headers(ctx, event) {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	// In asynchronous mode, this only queues the message; the outcome is reported to completed
+	err = p.writer.WriteMessages(context.WithoutCancel(ctx), kafka.Message{
+		Key:     []byte(event.NoteID),
+		Value:   value,
+		Headers: headers,
+		Time:    event.Timestamp,
+	})
+	if err != nil {
+		log.Printf("%sFailed to queue %s event of note %s for Kafka: %v", requestid.LogPrefix(ctx), event.Type, event.NoteID, err)
+		record("kafka", resultDropped, 1)
+	}
+}
+
+// completed records the outcome of a batch sent to the brokers.
+func (p *KafkaPublisher) completed(messages []kafka.Message, err error) {
+	if err != nil {
+		log.Printf("Failed to publish %d events to Kafka topic %s: %v", len(messages), p.writer.Topic, err)
+		record("kafka", resultFailure, len(messages))
+		return
+	}
+	record("kafka", resultSuccess, len(messages))
+}
+
+// Ping checks that at least one of the bootstrap brokers accepts connections.
+func (p *KafkaPublisher) Ping(ctx context.Context) error {
+	var errs []error
+	for _, addr := range p.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn.Close()
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("no Kafka broker is reachable: %w", errors.Join(errs...))
+}
+
+// Close flushes the queued events and waits until they have been sent.
+// If the context is done first, the remaining events are abandoned.
+func (p *KafkaPublisher) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.writer.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("flushing Kafka events: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,275 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// natsSetupTimeout bounds setting up the JetStream stream at startup.
+	natsSetupTimeout = 5 * time.Second
+
+	// natsFlushTimeout bounds flushing at shutdown if the context has no deadline.
+	natsFlushTimeout = 5 * time.Second
+
+	// natsPendingSize is the number of events held until the first connection is
+	// established. Events are dropped when it is reached.
+	natsPendingSize = 10000
+)
+
+// NATSPublisher publishes note events to NATS subjects. Each event is published to
+// "<subject>.<event type>" (e.g., "notes.events.note.created"), so consumers can
+// subscribe to all events with "notes.events.>" or pick single event types.
+//
+// With core NATS, events are only delivered to consumers that are subscribed when
+// they are published. With JetStream, they are persisted in a stream and acknowledged
+// by the server, so consumers can catch up on events they missed.
+//
+// The connection is re-established automatically; events published while it is down
+// are buffered by the client and sent after reconnecting. Until the first connection is
+// established, the client can't publish messages with headers (it doesn't know whether
+// the server supports them), so events are held by the publisher and sent once it is
+// connected. It implements events.Subscriber.
+type NATSPublisher struct {
+	conn    *nats.Conn          // Connection to the NATS server
+	js      jetstream.JetStream // JetStream context, or nil for core NATS
+	subject string              // Prefix of the subjects of the events
+	encoder encoder             // Encoding of the message payloads
+
+	mu        sync.Mutex    // Guards connected and pending
+	connected bool          // Whether the first connection was established
+	pending   []natsPending // Events published before the first connection
+}
+
+// natsPending is an event waiting for the first connection to the NATS server.
+type natsPending struct {
+	msg       *nats.Msg
+	event     events.Event
+	logPrefix string
+}
+
+// NewNATSPublisher creates a publisher for NATS subjects. It doesn't wait for the
+// server: if it is unreachable, the connection is retried in the background.
+//
+// Parameters:
+//   - url: The NATS server URL(s), comma-separated (e.g., "nats://localhost:4222")
+//   - subject: The prefix of the subjects that receive the events
+//   - stream: The JetStream stream that persists the events, or empty for core NATS.
+//     The stream is created for "<subject>.>" if it doesn't exist; an existing
+//     stream is used as is.
+//   - encoding: EncodingJSON (the default, if empty) or EncodingAvro
+//
+// Returns:
+//   - A pointer to a new NATSPublisher instance
+//   - An error if the URL, subject, or encoding is invalid
+func NewNATSPublisher(url, subject, stream, encoding string) (*NATSPublisher, error) {
+	if subject == "" {
+		return nil, errors.New("a NATS subject is required")
+	}
+	enc, err := newEncoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &NATSPublisher{subject: subject, encoder: enc}
+	// Held until the publisher is set up, so the connect handler can't run before
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conn, err := nats.Connect(url,
+		nats.Name("golang-simple-notes"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1), // Never give up; events are buffered in the meantime
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Reconnected to NATS at %s", c.ConnectedUrlRedacted())
+		}),
+		nats.ConnectHandler(func(*nats.Conn) {
+			p.publishPending()
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	p.conn = conn
+	if stream != "" {
+		p.js, err = jetstream.New(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+		}
+		// Publishing still works if the server is down now, once the stream exists
+		if err := p.ensureStream(stream); err != nil {
+			log.Printf("Failed to set up JetStream stream %s: %v; publishing fails until it exists", stream, err)
+		}
+	}
+	return p, nil
+}
+
+// ensureStream creates the stream for the subjects of the events, unless it exists.
+func (p *NATSPublisher) ensureStream(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), natsSetupTimeout)
+	defer cancel()
+
+	_, err := p.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     name,
+		Subjects: []string{p.subject + ".>"},
+		Storage:  jetstream.FileStorage,
+	})
+	if errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		return nil
+	}
+	return err
+}
+
+// Notify publishes an event; it doesn't wait for the server. Before the first
+// connection is established, the event is held until it is.
+func (p *NATSPublisher) Notify(ctx context.Context, event events.Event) {
+	data, err := p.encoder.encode(event)
+	if err != nil {
+		log.Printf("%sFailed to encode %s event for NATS: %v", requestid.LogPrefix(ctx), event.Type, err)
+		record("nats", resultDropped, 1)
+		return
+	}
+
+	msg := nats.NewMsg(natsSubject(p.subject, event))
+	msg.Data = data
+	for key, value := range p.encoder.headers(ctx, event) {
+		msg.Header.Set(key, value)
+	}
+
+	p.mu.Lock()
+	if !p.connected {
+		defer p.mu.Unlock()
+		if len(p.pending) >= natsPendingSize {
+			log.Printf("%sNATS is unreachable and too many events are pending, dropping %s event of note %s", requestid.LogPrefix(ctx), event.Type, event.NoteID)
+			record("nats", resultDropped, 1)
+			return
+		}
+		p.pending = append(p.pending, natsPending{msg: msg, event: event, logPrefix: requestid.LogPrefix(ctx)})
+		return
+	}
+	p.mu.Unlock()
+	p.publish(requestid.LogPrefix(ctx), event, msg)
+}
+
+// publishPending publishes the events held until the first connection was established.
+// The lock is held while publishing, so later events don't overtake them.
+func (p *NATSPublisher) publishPending() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	log.Printf("Connected to NATS at %s", p.conn.ConnectedUrlRedacted())
+	if len(p.pending) > 0 {
+		log.Printf("Publishing %d events held until NATS was reachable", len(p.pending))
+	}
+	p.connected = true
+	for _, pending := range p.pending {
+		p.publish(pending.logPrefix, pending.event, pending.msg)
+	}
+	p.pending = nil
+}
+
+// publish hands a message to the connection, or to JetStream, and records the outcome.
+func (p *NATSPublisher) publish(logPrefix string, event events.Event, msg *nats.Msg) {
+	if p.js == nil {
+		// Core NATS has no acknowledgements: the event is sent once it is handed to the connection
+		if err := p.conn.PublishMsg(msg); err != nil {
+			log.Printf("%sFailed to publish %s event of note %s to NATS: %v", logPrefix, event.Type, event.NoteID, err)
+			record("nats", resultDropped, 1)
+			return
+		}
+		record("nats", resultSuccess, 1)
+		return
+	}
+
+	future, err := p.js.PublishMsgAsync(msg)
+	if err != nil {
+		log.Printf("%sFailed to publish %s event of note %s to JetStream: %v", logPrefix, event.Type, event.NoteID, err)
+		record("nats", resultDropped, 1)
+		return
+	}
+	go p.awaitAck(logPrefix, event, future)
+}
+
+// awaitAck records the outcome of a JetStream publish.
+func (p *NATSPublisher) awaitAck(logPrefix string, event events.Event, future jetstream.PubAckFuture) {
+	select {
+	case <-future.Ok():
+		record("nats", resultSuccess, 1)
+	case err := <-future.Err():
+		log.Printf("%sJetStream did not acknowledge %s event of note %s: %v", logPrefix, event.Type, event.NoteID, err)
+		record("nats", resultFailure, 1)
+	}
+}
+
+// Ping checks that the connection to the NATS server is established, with a round trip.
+func (p *NATSPublisher) Ping(ctx context.Context) error {
+	if !p.conn.IsConnected() {
+		return fmt.Errorf("not connected to NATS (%v)", p.conn.Status())
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close waits until the published events have been sent (and, with JetStream,
+// acknowledged), then closes the connection. If the context is done first, the
+// remaining events are abandoned, as are the events held if the publisher never
+// connected.
+func (p *NATSPublisher) Close(ctx context.Context) error {
+	defer p.conn.Close()
+
+	p.mu.Lock()
+	connected, pending := p.connected, len(p.pending)
+	p.pending = nil
+	p.mu.Unlock()
+	if !connected {
+		if pending > 0 {
+			record("nats", resultDropped, pending)
+			return fmt.Errorf("NATS was never reachable, %d events were not sent", pending)
+		}
+		return nil
+	}
+
+	if p.js != nil {
+		select {
+		case <-p.js.PublishAsyncComplete():
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for JetStream acknowledgements: %w", ctx.Err())
+		}
+	}
+
+	if !p.conn.IsConnected() {
+		if n, err := p.conn.Buffered(); err == nil && n > 0 {
+			return fmt.Errorf("NATS is disconnected, %d bytes of events were not sent", n)
+		}
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, natsFlushTimeout)
+		defer cancel()
+	}
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("flushing NATS events: %w", err)
+	}
+	return nil
+}
+
+// natsSubject returns the subject of an event.
+func natsSubject(prefix string, event events.Event) string {
+	return prefix + "." + event.Type
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/storage"
+)
+
+// openChangeStream opens a change feed if the backend supports one and it is enabled:
+// a change stream for MongoDB, or the changes feed for CouchDB. Without a change feed,
+// watchers are only notified about changes made through this instance.
+//
+// Parameters:
+//   - ctx: The context for opening the feed
+//   - backend: The storage backend the application connected to
+//
+// Returns:
+//   - The opened change feed, or nil if change feeds are disabled or unavailable
+func (a *App) openChangeStream(ctx context.Context, backend storage.NoteStorage) storage.ChangeFeed {
+	switch backend := backend.(type) {
+	case *storage.MongoDBStorage:
+		if !a.config.MongoDBChangeStreams {
+			return nil
+		}
+		changes, err := backend.WatchChanges(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrChangeStreamsUnsupported) {
+				log.Printf("MongoDB change streams are unavailable (standalone server); watchers are only notified about changes made through this instance")
+			} else {
+				log.Printf("Failed to open MongoDB change stream: %v; watchers are only notified about changes made through this instance", err)
+			}
+			return nil
+		}
+		log.Println("Watching MongoDB change stream for note changes")
+		return changes
+	case *storage.CouchDBStorage:
+		if !a.config.CouchDBChangesFeed {
+			return nil
+		}
+		changes, err := backend.WatchChanges(ctx)
+		if err != nil {
+			log.Printf("Failed to open CouchDB changes feed: %v; watchers are only notified about changes made through this instance", err)
+			return nil
+		}
+		log.Println("Watching CouchDB changes feed for note changes")
+		return changes
+	default:
+		return nil
+	}
+}
+
+// startChangeStream publishes every change reported by the change feed to the event bus,
+// including changes made by other instances, until the returned shutdown hook is called.
+// Created and updated notes are read through the storage, so they receive decrypted notes.
+//
+// Returns:
+//   - A shutdown hook that stops reading the change feed and closes it
+func (a *App) startChangeStream() func(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		a.changes.Run(ctx, func(change storage.Change) {
+			a.notifyChange(ctx, change)
+		})
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return a.changes.Close(shutdownCtx)
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// notifyChange invalidates the cached note, if any, and publishes the event of a changed note.
+func (a *App) notifyChange(ctx context.Context, change storage.Change) {
+	// The change may have been made by another instance, bypassing this instance's cache
+	if a.cache != nil {
+		a.cache.Invalidate(ctx, change.NoteID)
+	}
+
+	event := events.Event{NoteID: change.NoteID, Timestamp: time.Now().UTC()}
+	switch change.Type {
+	case storage.ChangeCreated, storage.ChangeUpdated:
+		note, err := a.storage.Get(ctx, change.NoteID)
+		if err != nil {
+			// A note deleted right after the change is reported by the deletion event
+			if !errors.Is(err, storage.ErrNoteNotFound) {
+				log.Printf("Failed to read changed note %s: %v", change.NoteID, err)
+			}
+			return
+		}
+		event.Type = events.NoteUpdated
+		if change.Type == storage.ChangeCreated {
+			event.Type = events.NoteCreated
+		}
+		event.Note = note
+	case storage.ChangeDeleted:
+		event.Type = events.NoteDeleted
+	default:
+		return
+	}
+
+	a.bus.Publish(ctx, event)
+}
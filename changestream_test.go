@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/webhook"
+)
+
+func TestApp_OpenChangeStream(t *testing.T) {
+	// Only MongoDB has change streams
+	app := NewApp(&Config{StorageType: "memory", MongoDBChangeStreams: true})
+	if changes := app.openChangeStream(context.Background(), storage.NewInMemoryStorage()); changes != nil {
+		t.Error("Expected no change stream for in-memory storage")
+	}
+}
+
+func TestApp_NotifyChange(t *testing.T) {
+	ctx := context.Background()
+	app := NewApp(&Config{})
+	app.storage = storage.NewInMemoryStorage()
+	app.watchers = webhook.NewWatchers(time.Second)
+	app.bus.Subscribe("watchers", app.watchers)
+
+	note := &model.Note{ID: "changed", Title: "Changed by another instance"}
+	if err := app.storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	received, cancel := app.watchers.Subscribe(note.ID)
+	defer cancel()
+
+	// Creations are not reported; a new note cannot have watchers yet
+	app.notifyChange(ctx, storage.Change{Type: storage.ChangeCreated, NoteID: note.ID})
+	app.notifyChange(ctx, storage.Change{Type: storage.ChangeUpdated, NoteID: note.ID})
+	app.notifyChange(ctx, storage.Change{Type: storage.ChangeDeleted, NoteID: note.ID})
+
+	updated := <-received
+	if updated.Type != events.NoteUpdated || updated.Note == nil || updated.Note.Title != note.Title {
+		t.Errorf("Expected an update event with the note read from the storage, got %+v", updated)
+	}
+	if updated.Timestamp.Location() != time.UTC {
+		t.Errorf("Expected the event timestamp in UTC, got %v", updated.Timestamp)
+	}
+	if deleted := <-received; deleted.Type != events.NoteDeleted || deleted.NoteID != note.ID {
+		t.Errorf("Expected a delete event, got %+v", deleted)
+	}
+	if _, ok := <-received; ok {
+		t.Error("Expected the subscription to end after the delete event")
+	}
+}
+
+func TestApp_OpenChangeStreamDisabled(t *testing.T) {
+	// Disabled change feeds are not opened, even for backends that support them
+	app := NewApp(&Config{StorageType: "couchdb"})
+	if changes := app.openChangeStream(context.Background(), &storage.CouchDBStorage{}); changes != nil {
+		t.Error("Expected no changes feed when it is disabled")
+	}
+	if changes := app.openChangeStream(context.Background(), &storage.MongoDBStorage{}); changes != nil {
+		t.Error("Expected no change stream when it is disabled")
+	}
+}
+
+func TestApp_NotifyChangeInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	app := NewApp(&Config{})
+	app.cache = storage.NewCachedStorage(backend, storage.NewLRUCache(10, time.Minute))
+	app.storage = app.cache
+	app.watchers = webhook.NewWatchers(time.Second)
+	app.bus.Subscribe("watchers", app.watchers)
+
+	note := &model.Note{ID: "cached", Title: "Original"}
+	if err := app.storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if _, err := app.storage.Get(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+
+	// Another instance updates the note directly in the backend
+	if err := backend.Update(ctx, &model.Note{ID: note.ID, Title: "Changed by another instance"}); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	app.notifyChange(ctx, storage.Change{Type: storage.ChangeUpdated, NoteID: note.ID})
+
+	if got, err := app.storage.Get(ctx, note.ID); err != nil || got.Title != "Changed by another instance" {
+		t.Errorf("Expected the change to invalidate the cached note, got %+v (%v)", got, err)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"golang-simple-notes/logging"
+
+	"github.com/spf13/cobra"
+)
+
+// version is the application version, set at build time with
+// -ldflags "-X main.version=<version>".
+var version = "dev"
+
+// cliOptions holds the values of the global command-line flags.
+type cliOptions struct {
+	configPath  string // Path of the configuration file (--config)
+	restPort    string // REST server listen address (--rest-port)
+	storageType string // Storage backend (--storage)
+	logLevel    string // Minimum log level (--log-level)
+}
+
+// newRootCommand creates the command-line interface of the application.
+// Running the binary without a subcommand starts the servers, like "serve".
+//
+// Returns:
+//   - The root command, with the serve, migrate, rotate-keys, and version subcommands
+func newRootCommand() *cobra.Command {
+	opts := &cliOptions{}
+
+	root := &cobra.Command{
+		Use:   "notes-api",
+		Short: "Notes API with REST and gRPC interfaces",
+		Long: "Notes API is a simple notes management microservice with REST and gRPC interfaces,\n" +
+			"storing notes in memory, CouchDB, or MongoDB.\n\n" +
+			"Settings are read from the configuration file, then environment variables, then flags.",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true, // Don't print the usage for errors that are not about the command line
+		SilenceErrors: true, // main logs the error
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd, opts)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.configPath, "config", "", "path to a YAML or TOML configuration file")
+	flags.StringVar(&opts.restPort, "rest-port", "", `REST server listen address (e.g., ":8080")`)
+	flags.StringVar(&opts.storageType, "storage", "", "storage backend: memory, couchdb, or mongodb (overrides STORAGE_TYPE)")
+	flags.StringVar(&opts.logLevel, "log-level", "", "minimum log level: debug, info, warn, or error (overrides LOG_LEVEL)")
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Start the REST and gRPC servers (default)",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runServe(cmd, opts)
+			},
+		},
+		newMigrateCommand(opts),
+		&cobra.Command{
+			Use:   "rotate-keys",
+			Short: "Re-encrypt all notes with the active encryption key",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runRotateKeys(cmd, opts)
+			},
+		},
+		&cobra.Command{
+			Use:   "version",
+			Short: "Print the version",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				fmt.Fprintln(cmd.OutOrStdout(), versionString())
+			},
+		},
+	)
+
+	return root
+}
+
+// newMigrateCommand creates the "migrate" subcommand, which copies all notes
+// from the configured storage backend to another one.
+func newMigrateCommand(opts *cliOptions) *cobra.Command {
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy all notes from the configured storage backend to another backend",
+		Long: "Copy all notes from the configured storage backend (--storage or STORAGE_TYPE) to the\n" +
+			"target backend (--to). Notes that already exist in the target are overwritten.\n" +
+			"Notes are copied as stored, so encrypted notes stay encrypted with the same keys.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := opts.loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			result, err := NewApp(config).Migrate(cmd.Context(), target)
+			if err != nil {
+				return err
+			}
+			log.Printf("Migration finished: copied %d, overwritten %d, failed %d notes",
+				result.Copied, result.Overwritten, result.Failed)
+			if result.Failed > 0 {
+				return fmt.Errorf("failed to copy %d notes", result.Failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&target, "to", "", "target storage backend: memory, couchdb, or mongodb")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// loadConfig loads the configuration (see readConfig), then configures logging
+// and logs the effective settings.
+func (o *cliOptions) loadConfig(cmd *cobra.Command) (*Config, error) {
+	config, err := o.readConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := logging.Setup(os.Stderr, config.LogLevel); err != nil {
+		return nil, err
+	}
+	config.LogSettings()
+	return config, nil
+}
+
+// readConfig loads the configuration, applies the command-line flags that were
+// set explicitly, and validates the result.
+func (o *cliOptions) readConfig(cmd *cobra.Command) (*Config, error) {
+	config, err := LoadConfig(o.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("rest-port") {
+		config.RESTPort = o.restPort
+	}
+	if flags.Changed("storage") {
+		config.StorageType = o.storageType
+	}
+	if flags.Changed("log-level") {
+		config.LogLevel = o.logLevel
+	}
+
+	// Fail fast on invalid settings, before anything is started
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return config, nil
+}
+
+// runServe starts the application and blocks until it is shut down.
+func runServe(cmd *cobra.Command, opts *cliOptions) error {
+	config, err := opts.loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Create and initialize the application with the configuration
+	app := NewApp(config)
+	if err := app.Initialize(cmd.Context()); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	// Re-read the configuration file and environment on SIGHUP; flags still take precedence
+	go app.ReloadOnSignal(cmd.Context(), func() (*Config, error) {
+		return opts.readConfig(cmd)
+	})
+
+	// Run the application, which starts the REST and gRPC servers
+	// It returns the context's error once a shutdown signal has been handled
+	if err := app.Run(cmd.Context()); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to run application: %w", err)
+	}
+	return nil
+}
+
+// runRotateKeys re-encrypts all stored notes with the active encryption key.
+func runRotateKeys(cmd *cobra.Command, opts *cliOptions) error {
+	config, err := opts.loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	app := NewApp(config)
+	if err := app.Initialize(cmd.Context()); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer func() {
+		if err := app.Shutdown(context.WithoutCancel(cmd.Context())); err != nil {
+			log.Printf("Shutdown failed: %v", err)
+		}
+	}()
+
+	result, err := app.RotateEncryptionKeys(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to rotate encryption keys: %w", err)
+	}
+	log.Printf("Key rotation finished: scanned %d, rotated %d, failed %d notes",
+		result.Scanned, result.Rotated, result.Failed)
+	return nil
+}
+
+// versionString describes the application version, the commit it was built from
+// (if known), and the Go version.
+func versionString() string {
+	revision := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	return fmt.Sprintf("notes-api %s (commit %s, %s)", version, revision, runtime.Version())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/logging"
+)
+
+// executeCommand runs the root command with the given arguments and returns its output.
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	// Commands configure the global logger; restore it afterwards
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		_ = logging.SetLevel(logging.DefaultLevel)
+	})
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(args)
+	err := root.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+func TestCLI_Version(t *testing.T) {
+	out, err := executeCommand(t, "version")
+	if err != nil {
+		t.Fatalf("version failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "notes-api dev (commit ") {
+		t.Errorf("Unexpected version output: %q", out)
+	}
+}
+
+func TestCLI_InvalidUsage(t *testing.T) {
+	tests := map[string][]string{
+		"UnknownCommand":   {"frobnicate"},
+		"UnknownFlag":      {"--frobnicate"},
+		"MigrateWithoutTo": {"migrate"},
+		"InvalidLogLevel":  {"serve", "--log-level", "loud"},
+		"MissingConfig":    {"serve", "--config", "/nonexistent/config.yaml"},
+		"MigrateToSelf":    {"migrate", "--storage", "memory", "--to", "memory"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := executeCommand(t, args...); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		})
+	}
+}
+
+func TestCLI_FlagsOverrideConfig(t *testing.T) {
+	t.Setenv("STORAGE_TYPE", "couchdb")
+	t.Setenv("LOG_LEVEL", "error")
+
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		_ = logging.SetLevel(logging.DefaultLevel)
+	})
+
+	root := newRootCommand()
+	cmd, args, err := root.Find([]string{"serve", "--storage", "mongodb", "--rest-port", ":9090"})
+	if err != nil {
+		t.Fatalf("Failed to find the serve command: %v", err)
+	}
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	opts := &cliOptions{storageType: "mongodb", restPort: ":9090"}
+	config, err := opts.loadConfig(cmd)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	// Flags override the environment; settings without a flag keep the environment value
+	if config.StorageType != "mongodb" || config.RESTPort != ":9090" {
+		t.Errorf("Expected flag values, got storage %q, port %q", config.StorageType, config.RESTPort)
+	}
+	if config.LogLevel != "error" || logging.Level() != "error" {
+		t.Errorf("Expected the log level from the environment, got %q (active %q)", config.LogLevel, logging.Level())
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Health probes of Client.Health.
+const (
+	ProbeReady   = "ready"   // Whether every dependency (e.g., the storage) is available
+	ProbeStartup = "startup" // Whether the service has finished initializing
+)
+
+// HealthReport is the result of a health probe.
+type HealthReport struct {
+	Status string                      `json:"status"` // "ok" if every check passed, "unavailable" otherwise
+	Checks map[string]DependencyHealth `json:"checks"` // Result of each check, by name
+}
+
+// DependencyHealth is the result of a single health check.
+type DependencyHealth struct {
+	Status    string `json:"status"`          // "ok" or "unavailable"
+	LatencyMS int64  `json:"latency_ms"`      // How long the check took, in milliseconds
+	Error     string `json:"error,omitempty"` // Why the dependency is unavailable
+}
+
+// Webhook is a registered webhook of the service.
+type Webhook struct {
+	ID        string    `json:"id"`               // Unique identifier of the webhook
+	URL       string    `json:"url"`              // URL that receives signed event payloads
+	Events    []string  `json:"events,omitempty"` // Subscribed event types; empty means all
+	Source    string    `json:"source"`           // "config" or "api"
+	CreatedAt time.Time `json:"created_at"`       // When the webhook was registered
+}
+
+// WebhookInput holds the settings of a new webhook.
+type WebhookInput struct {
+	URL    string   `json:"url"`              // URL that receives signed event payloads
+	Events []string `json:"events,omitempty"` // Event types to deliver (e.g., "note.created"); empty for all
+	Secret string   `json:"secret,omitempty"` // Key of the payload signatures; empty for the service's secret
+}
+
+// Delivery is an attempt to deliver an event to a webhook.
+type Delivery struct {
+	ID          string     `json:"id"`                     // Unique identifier, sent in the X-Webhook-Delivery header
+	HookID      string     `json:"hook_id"`                // ID of the receiving webhook
+	Event       string     `json:"event"`                  // Event type
+	NoteID      string     `json:"note_id"`                // ID of the note the event is about
+	Status      string     `json:"status"`                 // "pending", "succeeded", or "failed"
+	Attempts    int        `json:"attempts"`               // Number of attempts made so far
+	StatusCode  int        `json:"status_code,omitempty"`  // HTTP status of the last response, if any
+	LastError   string     `json:"last_error,omitempty"`   // Why the last attempt failed
+	CreatedAt   time.Time  `json:"created_at"`             // When the event was queued
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // When the delivery succeeded or was given up
+}
+
+// Divergence is a difference between the backends found by dual-write verification.
+type Divergence struct {
+	NoteID        string    `json:"note_id"`                  // ID of the divergent note
+	Operation     string    `json:"operation"`                // Write operation that was verified
+	PrimaryHash   string    `json:"primary_hash,omitempty"`   // Hash of the note on the primary (empty if missing)
+	SecondaryHash string    `json:"secondary_hash,omitempty"` // Hash of the note on the secondary (empty if missing)
+	Reason        string    `json:"reason"`                   // Human-readable description of the difference
+	RequestID     string    `json:"request_id,omitempty"`     // Request that performed the write
+	DetectedAt    time.Time `json:"detected_at"`              // When the divergence was detected
+}
+
+// DivergenceReport summarizes the results of dual-write verification.
+type DivergenceReport struct {
+	Verified    uint64       `json:"verified"`    // Writes found identical on both backends
+	Mismatched  uint64       `json:"mismatched"`  // Writes found different on the two backends
+	Errors      uint64       `json:"errors"`      // Verifications that failed to read a backend
+	Dropped     uint64       `json:"dropped"`     // Writes not verified because the queue was full
+	Divergences []Divergence `json:"divergences"` // Most recent divergences, oldest first
+}
+
+// ReconcileResult is the number of notes repaired by a reconciliation pass.
+type ReconcileResult struct {
+	Created int `json:"created"` // Notes missing on the secondary
+	Updated int `json:"updated"` // Notes whose content differed
+	Deleted int `json:"deleted"` // Notes that exist only on the secondary
+}
+
+// Live returns nil if the service is running and able to serve requests.
+func (c *Client) Live(ctx context.Context) error {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/health/live"})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Health runs a health probe (ProbeReady or ProbeStartup) and returns its report. If a
+// check failed, the report is returned along with an error that matches ErrUnavailable.
+func (c *Client) Health(ctx context.Context, probe string) (*HealthReport, error) {
+	var report HealthReport
+	req := request{method: http.MethodGet, path: "/health/" + url.PathEscape(probe), errorBody: &report}
+	if _, err := c.decode(ctx, req, &report); err != nil {
+		if errors.Is(err, ErrUnavailable) && report.Status != "" {
+			return &report, err
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Webhooks returns the registered webhooks. Like all admin methods, it returns ErrAuth
+// if the client doesn't have the service's admin token (see WithToken).
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	if _, err := c.doJSON(ctx, http.MethodGet, "/api/admin/webhooks", nil, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CreateWebhook registers a webhook and returns it with its ID.
+func (c *Client) CreateWebhook(ctx context.Context, input WebhookInput) (*Webhook, error) {
+	var hook Webhook
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/admin/webhooks", input, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// DeleteWebhook removes a webhook, or returns ErrNotFound if it doesn't exist.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, "/api/admin/webhooks/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// Deliveries returns the recent deliveries to a webhook, or to all webhooks if hookID is empty.
+func (c *Client) Deliveries(ctx context.Context, hookID string) ([]Delivery, error) {
+	path := "/api/admin/webhooks/deliveries"
+	if hookID != "" {
+		path = "/api/admin/webhooks/" + url.PathEscape(hookID) + "/deliveries"
+	}
+
+	var deliveries []Delivery
+	if _, err := c.doJSON(ctx, http.MethodGet, path, nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Purge deletes all notes and returns how many were deleted. It requests a confirmation
+// token and confirms the purge with it right away, so there is no way back: use it with care.
+func (c *Client) Purge(ctx context.Context) (int, error) {
+	var confirmation struct {
+		Token string `json:"confirmation_token"`
+	}
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/admin/purge", nil, &confirmation); err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	in := map[string]string{"confirm": confirmation.Token}
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/admin/purge", in, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// Reindex rebuilds the indexes of the storage backend. It fails with an *Error with
+// status 501 Not Implemented if the backend has no indexes to rebuild.
+func (c *Client) Reindex(ctx context.Context) error {
+	_, err := c.doJSON(ctx, http.MethodPost, "/api/admin/reindex", nil, nil)
+	return err
+}
+
+// Compact compacts the storage backend to reclaim disk space. It fails with an *Error
+// with status 501 Not Implemented if the backend doesn't support compaction.
+func (c *Client) Compact(ctx context.Context) error {
+	_, err := c.doJSON(ctx, http.MethodPost, "/api/admin/compact", nil, nil)
+	return err
+}
+
+// LogLevel returns the minimum level of the service's log messages (e.g., "info").
+func (c *Client) LogLevel(ctx context.Context) (string, error) {
+	var body struct {
+		Level string `json:"level"`
+	}
+	if _, err := c.doJSON(ctx, http.MethodGet, "/api/admin/loglevel", nil, &body); err != nil {
+		return "", err
+	}
+	return body.Level, nil
+}
+
+// SetLogLevel changes the minimum level of the service's log messages ("debug", "info",
+// "warn", or "error") until it restarts or reloads its configuration. It fails with
+// ErrBadRequest if the level is not valid.
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	in := map[string]string{"level": level}
+	_, err := c.doJSON(ctx, http.MethodPut, "/api/admin/loglevel", in, nil)
+	return err
+}
+
+// Divergences returns the dual-write verification report. It returns ErrNotFound
+// unless the service runs in dual-write mode with verification.
+func (c *Client) Divergences(ctx context.Context) (*DivergenceReport, error) {
+	var report DivergenceReport
+	if _, err := c.doJSON(ctx, http.MethodGet, "/api/migration/divergences", nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Reconcile runs a reconciliation pass of asynchronous dual-write and returns the number
+// of notes it repaired. It returns ErrNotFound unless the service runs in asynchronous
+// dual-write mode.
+func (c *Client) Reconcile(ctx context.Context) (*ReconcileResult, error) {
+	var result ReconcileResult
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/migration/reconcile", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
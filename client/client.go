@@ -0,0 +1,292 @@
+// Package client is a typed Go client for the REST API of the notes service, so that
+// consumers don't have to hand-roll HTTP calls:
+//
+//	c := client.New("http://localhost:8080", client.WithToken(os.Getenv("ADMIN_TOKEN")))
+//	note, err := c.CreateNote(ctx, client.NoteInput{Title: "Shopping", Content: "Milk"})
+//
+// Every call takes a context, idempotent requests are retried on transient failures, and
+// list queries can be iterated page by page (see Client.Notes). Failed requests return an
+// *Error, which matches sentinel errors such as ErrNotFound or ErrConflict with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-simple-notes/requestid"
+)
+
+// Errors matched by *Error with errors.Is.
+var (
+	ErrNotFound    = errors.New("not found")                 // 404 Not Found
+	ErrConflict    = errors.New("conflict")                  // 409 Conflict (e.g., a concurrent update)
+	ErrUnavailable = errors.New("service unavailable")       // 503 Service Unavailable (e.g., the storage circuit is open)
+	ErrBadRequest  = errors.New("bad request")               // 400 Bad Request (e.g., an invalid note)
+	ErrAuth        = errors.New("unauthorized or forbidden") // 401 Unauthorized or 403 Forbidden
+	errRetryable   = errors.New("retryable response status") // Statuses worth retrying
+)
+
+// Error is a response with an unexpected status code.
+type Error struct {
+	StatusCode int           // HTTP status code
+	Message    string        // Error message of the response body
+	RequestID  string        // X-Request-ID of the response, to find the request in the server logs
+	RetryAfter time.Duration // Delay from the Retry-After header, if any
+}
+
+// Error returns the status code and message.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("notes API returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request ID " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is matches the error against ErrNotFound, ErrConflict, ErrUnavailable, ErrBadRequest, and ErrAuth.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case errRetryable:
+		switch e.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy controls how idempotent requests (GET, PUT, DELETE) are retried after
+// network errors and 429, 502, 503, and 504 responses. Requests that create something
+// (POST) are never retried, since the first attempt may have succeeded.
+type RetryPolicy struct {
+	MaxAttempts  int           // Total number of attempts, including the first (1 disables retries)
+	InitialDelay time.Duration // Delay before the first retry; doubled for every further retry
+	MaxDelay     time.Duration // Upper bound of the delay between attempts
+}
+
+// DefaultRetryPolicy returns the retry policy used unless WithRetryPolicy is given:
+// up to 3 attempts, 100ms apart at first, backing off to at most 2s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+}
+
+// Client calls the REST API of a notes service. It is safe for concurrent use.
+type Client struct {
+	baseURL string       // URL of the service, without a trailing slash
+	http    *http.Client // HTTP client sending the requests
+	token   string       // Bearer token sent with every request (optional)
+	retry   RetryPolicy  // Retries of idempotent requests
+}
+
+// Option configures optional features of a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the given HTTP client (e.g., with custom TLS
+// settings or timeouts) instead of one with a 30-second timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithToken sends the given bearer token with every request, as required by the admin,
+// export, and import endpoints (the service's ADMIN_TOKEN), or by an authenticating proxy.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy of idempotent requests.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New creates a client for the notes service at baseURL (e.g., "http://localhost:8080").
+//
+// Parameters:
+//   - baseURL: The URL of the service, with scheme and host
+//   - opts: Optional features to enable (e.g., WithToken)
+//
+// Returns:
+//   - A pointer to a new Client instance
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+		retry:   DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// request describes a call to the API.
+type request struct {
+	method      string
+	path        string    // Path with the query string, if any
+	body        []byte    // Request body; nil for none
+	stream      io.Reader // Request body that can only be sent once, instead of body (POST only)
+	contentType string    // Content-Type of the body; JSON if empty
+	errorBody   any       // Decodes JSON bodies of error responses (e.g., the import summary), if not nil
+}
+
+// doJSON sends a request with an optional JSON body, and decodes the JSON response into
+// out, unless out is nil. It returns the response headers.
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out any) (http.Header, error) {
+	req := request{method: method, path: path}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		req.body = body
+	}
+	return c.decode(ctx, req, out)
+}
+
+// decode sends a request and decodes the JSON response into out, unless out is nil.
+// It returns the response headers.
+func (c *Client) decode(ctx context.Context, req request, out any) (http.Header, error) {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode response of %s %s: %w", req.method, req.path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// do sends a request, retrying idempotent requests according to the retry policy. It
+// returns the response if its status is 2xx, and an *Error otherwise; the caller must
+// close the body of the response.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	attempts := 1
+	if req.method != http.MethodPost && req.stream == nil {
+		attempts = max(1, c.retry.MaxAttempts)
+	}
+
+	delay := c.retry.InitialDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil || !retryable(err) {
+			return nil, err
+		}
+
+		// Wait for the server's Retry-After if it asks for longer than the backoff
+		wait := delay
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		// Jitter spreads out the retries of clients that failed at the same time
+		wait = wait/2 + rand.N(wait/2+1)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, c.retry.MaxDelay)
+	}
+}
+
+// send makes a single attempt of a request.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	body := req.stream
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	// Tie the request to the caller's request in the server logs
+	if id := requestid.FromContext(ctx); id != "" {
+		httpReq.Header.Set(requestid.Header, id)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, newError(resp, req.errorBody)
+}
+
+// newError creates the error of a response with an unexpected status code. If errorBody
+// is not nil and the response is JSON, the body is decoded into it instead of becoming
+// the message of the error.
+func newError(resp *http.Response, errorBody any) *Error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestid.Header),
+	}
+	if errorBody != nil && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(resp.Body).Decode(errorBody); err != nil {
+			apiErr.Message = fmt.Sprintf("malformed response body: %v", err)
+		}
+	} else {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr.Message = strings.TrimSpace(string(message))
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// retryable reports whether a failed attempt may succeed when repeated: network errors
+// and responses that indicate a temporary condition.
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return errors.Is(apiErr, errRetryable)
+	}
+	return true
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/rest"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestServer starts a notes service with in-memory storage and an admin token
+func newTestServer(t *testing.T, opts ...rest.HandlerOption) *httptest.Server {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Use(rest.RequestIDMiddleware)
+	opts = append(opts, rest.WithAdminToken("secret"))
+	rest.NewHandler(storage.NewInMemoryStorage(), opts...).RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestNotes tests creating, reading, updating, and deleting notes, with the errors of each
+func TestNotes(t *testing.T) {
+	ctx := context.Background()
+	c := New(newTestServer(t).URL + "/")
+
+	note, err := c.CreateNote(ctx, NoteInput{Title: "Shopping", Content: "Milk"})
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if note.ID == "" || note.Title != "Shopping" {
+		t.Errorf("Unexpected note: %+v", note)
+	}
+	if _, err := c.CreateNote(ctx, NoteInput{}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an empty note, got %v", err)
+	}
+
+	got, err := c.GetNote(ctx, note.ID)
+	if err != nil || got.Content != "Milk" {
+		t.Errorf("Expected the note back, got %+v: %v", got, err)
+	}
+
+	updated, err := c.UpdateNote(ctx, note.ID, NoteInput{Title: "Shopping", Content: "Milk, eggs"})
+	if err != nil || updated.Content != "Milk, eggs" {
+		t.Errorf("Expected the updated note, got %+v: %v", updated, err)
+	}
+
+	if err := c.DeleteNote(ctx, note.ID); err != nil {
+		t.Fatalf("DeleteNote failed: %v", err)
+	}
+	_, err = c.GetNote(ctx, note.ID)
+	var apiErr *Error
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) || apiErr.RequestID == "" {
+		t.Errorf("Expected ErrNotFound with a request ID, got %v", err)
+	}
+}
+
+// TestTemplates tests managing templates and creating notes from them
+func TestTemplates(t *testing.T) {
+	ctx := context.Background()
+	notes := service.New(storage.NewInMemoryStorage())
+	templates := service.NewTemplateService(storage.NewInMemoryStorage(), notes)
+	c := New(newTestServer(t, rest.WithNoteService(notes), rest.WithTemplates(templates)).URL)
+
+	template, err := c.CreateTemplate(ctx, NoteInput{Title: "Meeting: {{title}}", Content: "In {{room}}"})
+	if err != nil {
+		t.Fatalf("CreateTemplate failed: %v", err)
+	}
+	if list, err := c.Templates(ctx); err != nil || len(list) != 1 {
+		t.Errorf("Expected one template, got %+v: %v", list, err)
+	}
+	if _, err := c.UpdateTemplate(ctx, template.ID, NoteInput{Title: "Meeting: {{title}}", Content: "Room {{room}}"}); err != nil {
+		t.Fatalf("UpdateTemplate failed: %v", err)
+	}
+
+	note, err := c.CreateNoteFromTemplate(ctx, template.ID, TemplateVariables{Title: "Planning", Variables: map[string]string{"room": "1"}})
+	if err != nil {
+		t.Fatalf("CreateNoteFromTemplate failed: %v", err)
+	}
+	if note.Title != "Meeting: Planning" || note.Content != "Room 1" {
+		t.Errorf("Unexpected note: %+v", note)
+	}
+
+	if err := c.DeleteTemplate(ctx, template.ID); err != nil {
+		t.Fatalf("DeleteTemplate failed: %v", err)
+	}
+	if _, err := c.GetTemplate(ctx, template.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestNotesPagination tests listing a page of notes and iterating over all pages
+func TestNotesPagination(t *testing.T) {
+	ctx := context.Background()
+	c := New(newTestServer(t).URL)
+	for _, title := range []string{"e", "c", "a", "d", "b"} {
+		if _, err := c.CreateNote(ctx, NoteInput{Title: title, Content: "x"}); err != nil {
+			t.Fatalf("CreateNote failed: %v", err)
+		}
+	}
+
+	page, err := c.ListNotes(ctx, ListOptions{Sort: "title", Descending: true, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("ListNotes failed: %v", err)
+	}
+	if page.Total != 5 || len(page.Notes) != 2 || page.Notes[0].Title != "d" || page.Notes[1].Title != "c" {
+		t.Errorf("Unexpected page: total %d, notes %+v", page.Total, page.Notes)
+	}
+
+	var titles []string
+	for note, err := range c.Notes(ctx, ListOptions{Sort: "title", Limit: 2}) {
+		if err != nil {
+			t.Fatalf("Notes failed: %v", err)
+		}
+		titles = append(titles, note.Title)
+	}
+	if strings.Join(titles, "") != "abcde" {
+		t.Errorf("Expected all notes in order, got %v", titles)
+	}
+
+	if count, err := c.CountNotes(ctx, ""); err != nil || count != 5 {
+		t.Errorf("Expected 5 notes, got %d: %v", count, err)
+	}
+	if stats, err := c.Stats(ctx); err != nil || stats.Notes != 5 {
+		t.Errorf("Expected statistics of 5 notes, got %+v: %v", stats, err)
+	}
+
+	// An invalid query ends the iteration with the error
+	for _, err := range c.Notes(ctx, ListOptions{Sort: "color"}) {
+		if !errors.Is(err, ErrBadRequest) {
+			t.Errorf("Expected ErrBadRequest, got %v", err)
+		}
+	}
+}
+
+// TestRetries tests that idempotent requests are retried on transient failures, and others are not
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get(requestid.Header) != "req-1" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1)%3 != 0 {
+			http.Error(w, "Storage is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"count":7}`))
+	}))
+	defer server.Close()
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	c := New(server.URL, WithToken("token"), WithRetryPolicy(policy))
+
+	if count, err := c.CountNotes(ctx, ""); err != nil || count != 7 {
+		t.Errorf("Expected the count after retries, got %d: %v", count, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+
+	// POST requests are never retried
+	calls.Store(0)
+	if _, err := c.CreateNote(ctx, NoteInput{Title: "t"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected a single attempt, got %d", n)
+	}
+
+	// Client errors are not retried
+	calls.Store(0)
+	if _, err := New(server.URL, WithRetryPolicy(policy)).Stats(ctx); !errors.Is(err, ErrAuth) {
+		t.Errorf("Expected ErrAuth, got %v", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected no retries, got %d", n)
+	}
+}
+
+// TestExportImport tests exporting notes and importing them again, with a conflict
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	c := New(newTestServer(t).URL, WithToken("secret"))
+	if _, err := c.CreateNote(ctx, NoteInput{Title: "Exported", Content: "x"}); err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+
+	body, err := c.Export(ctx, FormatNDJSON)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	exported, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || !strings.Contains(string(exported), `"Exported"`) {
+		t.Fatalf("Unexpected export %q: %v", exported, err)
+	}
+
+	summary, err := c.Import(ctx, strings.NewReader(string(exported)), ImportOptions{})
+	if err != nil || summary.Skipped != 1 {
+		t.Errorf("Expected the note to be skipped, got %+v: %v", summary, err)
+	}
+	summary, err = c.Import(ctx, strings.NewReader(string(exported)), ImportOptions{OnConflict: ConflictFail})
+	if !errors.Is(err, ErrConflict) || summary == nil || summary.Results[0].Status != "conflict" {
+		t.Errorf("Expected a conflict with the summary, got %+v: %v", summary, err)
+	}
+}
+
+// TestAdmin tests the admin endpoints, which require the admin token
+func TestAdmin(t *testing.T) {
+	ctx := context.Background()
+	runner := jobs.NewRunner(1, 10)
+	defer func() { _ = runner.Close(ctx) }()
+	hooks := webhook.NewHooks(storage.RetryPolicy{}, "", runner)
+	server := newTestServer(t, rest.WithHooks(hooks))
+
+	if _, err := New(server.URL).Webhooks(ctx); !errors.Is(err, ErrAuth) {
+		t.Errorf("Expected ErrAuth without the token, got %v", err)
+	}
+
+	c := New(server.URL, WithToken("secret"))
+	hook, err := c.CreateWebhook(ctx, WebhookInput{URL: "https://example.com/hook", Secret: "s"})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if list, err := c.Webhooks(ctx); err != nil || len(list) != 1 || list[0].ID != hook.ID {
+		t.Errorf("Expected the webhook in the list, got %+v: %v", list, err)
+	}
+	if err := c.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Errorf("DeleteWebhook failed: %v", err)
+	}
+
+	for range 3 {
+		if _, err := c.CreateNote(ctx, NoteInput{Title: "t", Content: "c"}); err != nil {
+			t.Fatalf("CreateNote failed: %v", err)
+		}
+	}
+	if deleted, err := c.Purge(ctx); err != nil || deleted != 3 {
+		t.Errorf("Expected 3 notes to be purged, got %d: %v", deleted, err)
+	}
+
+	// The in-memory storage has nothing to maintain
+	var apiErr *Error
+	if err := c.Reindex(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotImplemented {
+		t.Errorf("Expected 501 Not Implemented, got %v", err)
+	}
+
+	if err := c.SetLogLevel(ctx, "verbose"); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest for an invalid log level, got %v", err)
+	}
+	if level, err := c.LogLevel(ctx); err != nil || level == "" {
+		t.Errorf("Expected the log level, got %q: %v", level, err)
+	}
+
+	if report, err := c.Health(ctx, ProbeReady); err != nil || report.Checks["storage"].Status != "ok" {
+		t.Errorf("Expected a healthy storage, got %+v: %v", report, err)
+	}
+	if err := c.Live(ctx); err != nil {
+		t.Errorf("Live failed: %v", err)
+	}
+	if _, err := c.Divergences(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound without dual-write, got %v", err)
+	}
+}
+
+// TestSubscribe tests receiving note events over a WebSocket connection
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	broadcaster := webhook.NewBroadcaster()
+	c := New(newTestServer(t, rest.WithBroadcaster(broadcaster)).URL)
+
+	sub, err := c.Subscribe(ctx, "note-1")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	broadcaster.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-2"})
+	broadcaster.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: "note-1"})
+	event, err := sub.Next(ctx)
+	if err != nil || event.NoteID != "note-1" || event.Type != events.NoteDeleted {
+		t.Errorf("Expected the event of note-1, got %+v: %v", event, err)
+	}
+
+	// Without real-time events, the endpoint doesn't exist
+	if _, err := New(newTestServer(t).URL).Subscribe(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// wsMessage is a message of the WebSocket protocol of GET /api/ws, in either direction.
+type wsMessage struct {
+	Type    string        `json:"type"`               // Message type (e.g., "subscribe" or "event")
+	ID      string        `json:"id,omitempty"`       // ID of the subscription the message is about
+	All     bool          `json:"all,omitempty"`      // Subscribe to the events of all notes
+	NoteIDs []string      `json:"note_ids,omitempty"` // Subscribe to the events of these notes
+	Event   *events.Event `json:"event,omitempty"`    // The note event of an "event" message
+	Error   string        `json:"error,omitempty"`    // Why a client message was rejected
+}
+
+// Subscription receives note events over a WebSocket connection. It is not safe for
+// concurrent use.
+type Subscription struct {
+	conn *websocket.Conn
+}
+
+// Subscribe opens a WebSocket connection and subscribes to the events of the given notes,
+// or of all notes if no IDs are given. It returns ErrNotFound if the service doesn't have
+// real-time events enabled. The caller must close the subscription.
+func (c *Client) Subscribe(ctx context.Context, noteIDs ...string) (*Subscription, error) {
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		header.Set(requestid.Header, id)
+	}
+
+	conn, resp, err := websocket.Dial(ctx, c.baseURL+"/api/ws", &websocket.DialOptions{HTTPClient: c.http, HTTPHeader: header})
+	if err != nil {
+		// The handshake response tells why the service refused the connection
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, newError(resp, nil)
+		}
+		return nil, err
+	}
+
+	s := &Subscription{conn: conn}
+	req := wsMessage{Type: "subscribe", All: len(noteIDs) == 0, NoteIDs: noteIDs}
+	if err := wsjson.Write(ctx, conn, req); err != nil {
+		_ = conn.CloseNow()
+		return nil, err
+	}
+	reply, err := s.read(ctx)
+	if err == nil && reply.Type != "subscribed" {
+		err = fmt.Errorf("unexpected %q message while subscribing", reply.Type)
+	}
+	if err != nil {
+		_ = conn.CloseNow()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next waits for the next note event. It returns an error if the context is canceled
+// or the connection is closed (e.g., because the service shuts down).
+func (s *Subscription) Next(ctx context.Context) (*events.Event, error) {
+	for {
+		msg, err := s.read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Type == "event" && msg.Event != nil {
+			return msg.Event, nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (s *Subscription) Close() error {
+	return s.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// read reads the next message, turning rejections by the service into errors.
+func (s *Subscription) read(ctx context.Context) (wsMessage, error) {
+	var msg wsMessage
+	if err := wsjson.Read(ctx, s.conn, &msg); err != nil {
+		return wsMessage{}, err
+	}
+	if msg.Type == "error" {
+		return wsMessage{}, fmt.Errorf("notes API rejected the subscription: %s", msg.Error)
+	}
+	return msg, nil
+}
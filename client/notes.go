@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// defaultPageSize is the number of notes fetched per request by Notes, unless ListOptions.Limit is set.
+const defaultPageSize = 100
+
+// NoteInput holds the fields of a note that clients can set.
+type NoteInput struct {
+	Title   string   `json:"title"`           // Title of the note
+	Content string   `json:"content"`         // Content of the note
+	Color   string   `json:"color,omitempty"` // Color of the note, as #rrggbb (optional)
+	Icon    string   `json:"icon,omitempty"`  // Icon of the note, e.g., "star" (optional)
+	Tags    []string `json:"tags,omitempty"`  // Tags of the note, e.g., "work" (optional)
+	Rev     string   `json:"_rev,omitempty"`  // Revision the update is based on; a stale revision fails with ErrConflict
+}
+
+// ListOptions filters, sorts, and paginates a list of notes.
+type ListOptions struct {
+	Query      string // Text the title or content must contain (case-insensitive)
+	Sort       string // Field to sort by: "created_at", "updated_at", "title", or "most_viewed"; the server's default if empty
+	Descending bool   // Sort in descending order
+	Limit      int    // Maximum number of notes to return (at most 1000); 0 for all
+	Offset     int    // Number of matching notes to skip
+}
+
+// values returns the options as query parameters of GET /api/notes.
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Query != "" {
+		query.Set("q", o.Query)
+	}
+	if o.Sort != "" {
+		sort := o.Sort
+		if o.Descending {
+			sort = "-" + sort
+		}
+		query.Set("sort", sort)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// NotePage is a page of a list of notes.
+type NotePage struct {
+	Notes []*model.Note // Notes of the page
+	Total int           // Number of notes matching the query, regardless of the limit and offset
+}
+
+// Stats holds statistics about the notes of a service.
+type Stats struct {
+	Storage      string         `json:"storage"`                     // Backend holding the notes (e.g., "couchdb")
+	Notes        int            `json:"notes"`                       // Number of notes
+	ContentBytes int64          `json:"content_bytes"`               // Total size of the note contents in bytes
+	Oldest       *time.Time     `json:"oldest_created_at,omitempty"` // Creation time of the oldest note; nil without notes
+	Newest       *time.Time     `json:"newest_created_at,omitempty"` // Creation time of the newest note; nil without notes
+	Tags         map[string]int `json:"tags"`                        // Number of notes per tag
+}
+
+// Watch is a registration of a callback URL for the events of a single note.
+type Watch struct {
+	ID          string    `json:"id"`           // Unique identifier of the watch
+	NoteID      string    `json:"note_id"`      // ID of the watched note
+	CallbackURL string    `json:"callback_url"` // URL that receives event payloads
+	CreatedAt   time.Time `json:"created_at"`   // When the watch was registered
+}
+
+// notePath returns the path of a note, or of a resource below it.
+func notePath(id string, elems ...string) string {
+	path := "/api/notes/" + url.PathEscape(id)
+	for _, elem := range elems {
+		path += "/" + url.PathEscape(elem)
+	}
+	return path
+}
+
+// CreateNote creates a note and returns it with its ID and timestamps.
+// It returns ErrBadRequest if the note is invalid (e.g., empty).
+func (c *Client) CreateNote(ctx context.Context, input NoteInput) (*model.Note, error) {
+	var note model.Note
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/notes", NoteInput{Title: input.Title, Content: input.Content, Color: input.Color, Icon: input.Icon, Tags: input.Tags}, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// GetNote returns the note with the given ID, or ErrNotFound if it doesn't exist.
+func (c *Client) GetNote(ctx context.Context, id string) (*model.Note, error) {
+	var note model.Note
+	if _, err := c.doJSON(ctx, http.MethodGet, notePath(id), nil, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// DuplicateNote creates a copy of a note with a new ID, fresh timestamps, and " (copy)"
+// appended to its title, and returns the copy. It returns ErrNotFound if the note doesn't exist.
+func (c *Client) DuplicateNote(ctx context.Context, id string) (*model.Note, error) {
+	var note model.Note
+	if _, err := c.doJSON(ctx, http.MethodPost, notePath(id, "duplicate"), nil, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// UpdateNote replaces the title, content, color, icon, and tags of a note and returns the updated note.
+// If input.Rev is set, the update fails with ErrConflict on backends that track revisions,
+// unless the revision is still current. It returns ErrNotFound if the note doesn't exist.
+func (c *Client) UpdateNote(ctx context.Context, id string, input NoteInput) (*model.Note, error) {
+	var note model.Note
+	if _, err := c.doJSON(ctx, http.MethodPut, notePath(id), input, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// DeleteNote deletes a note, or returns ErrNotFound if it doesn't exist.
+func (c *Client) DeleteNote(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, notePath(id), nil, nil)
+	return err
+}
+
+// Backlinks returns the notes that link to a note with [[id]] or [[title]], sorted by
+// title, or ErrNotFound if the note doesn't exist.
+func (c *Client) Backlinks(ctx context.Context, id string) ([]*model.Note, error) {
+	var notes []*model.Note
+	if _, err := c.doJSON(ctx, http.MethodGet, notePath(id, "backlinks"), nil, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// ListNotes returns a single page of the notes matching the options.
+func (c *Client) ListNotes(ctx context.Context, opts ListOptions) (*NotePage, error) {
+	path := "/api/notes"
+	if query := opts.values().Encode(); query != "" {
+		path += "?" + query
+	}
+
+	page := &NotePage{}
+	header, err := c.doJSON(ctx, http.MethodGet, path, nil, &page.Notes)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = len(page.Notes)
+	if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+		page.Total = total
+	}
+	return page, nil
+}
+
+// Notes iterates over all notes matching the options, fetching them one page at a time.
+// opts.Limit sets the page size (100 if 0), and iteration starts at opts.Offset.
+// If a request fails, the error is yielded and the iteration ends:
+//
+//	for note, err := range c.Notes(ctx, client.ListOptions{Sort: "title"}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(note.Title)
+//	}
+//
+// Notes created or deleted during the iteration may shift the pages, so a note may be
+// skipped or returned twice.
+func (c *Client) Notes(ctx context.Context, opts ListOptions) iter.Seq2[*model.Note, error] {
+	return func(yield func(*model.Note, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = defaultPageSize
+		}
+		for {
+			page, err := c.ListNotes(ctx, opts)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, note := range page.Notes {
+				if !yield(note, nil) {
+					return
+				}
+			}
+			opts.Offset += len(page.Notes)
+			if len(page.Notes) < opts.Limit || opts.Offset >= page.Total {
+				return
+			}
+		}
+	}
+}
+
+// CountNotes returns the number of notes whose title or content contains query,
+// or of all notes if query is empty.
+func (c *Client) CountNotes(ctx context.Context, query string) (int, error) {
+	path := "/api/notes/count"
+	if query != "" {
+		path += "?" + url.Values{"q": {query}}.Encode()
+	}
+
+	var out struct {
+		Count int `json:"count"`
+	}
+	if _, err := c.doJSON(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+// Stats returns statistics about the notes.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if _, err := c.doJSON(ctx, http.MethodGet, "/api/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// WatchNote registers a callback URL that receives the events of a note. It returns
+// ErrNotFound if the note doesn't exist, or if the service doesn't have watches enabled.
+func (c *Client) WatchNote(ctx context.Context, noteID, callbackURL string) (*Watch, error) {
+	var watch Watch
+	in := map[string]string{"callback_url": callbackURL}
+	if _, err := c.doJSON(ctx, http.MethodPost, notePath(noteID, "watch"), in, &watch); err != nil {
+		return nil, err
+	}
+	return &watch, nil
+}
+
+// Watches returns the watches of a note.
+func (c *Client) Watches(ctx context.Context, noteID string) ([]Watch, error) {
+	var watches []Watch
+	if _, err := c.doJSON(ctx, http.MethodGet, notePath(noteID, "watch"), nil, &watches); err != nil {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// UnwatchNote removes a watch of a note, or returns ErrNotFound if it doesn't exist.
+func (c *Client) UnwatchNote(ctx context.Context, noteID, watchID string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, notePath(noteID, "watch", watchID), nil, nil)
+	return err
+}
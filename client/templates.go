@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"golang-simple-notes/model"
+)
+
+// TemplateVariables holds the values substituted into a template by CreateNoteFromTemplate.
+// The variables {{date}}, {{time}}, and {{datetime}} are always set by the server.
+type TemplateVariables struct {
+	Title     string            `json:"title,omitempty"`     // Value of {{title}}
+	Variables map[string]string `json:"variables,omitempty"` // Values of custom variables, by name
+}
+
+// templatePath returns the path of a template.
+func templatePath(id string) string {
+	return "/api/templates/" + url.PathEscape(id)
+}
+
+// Templates returns all note templates, sorted by title.
+func (c *Client) Templates(ctx context.Context) ([]*model.Note, error) {
+	var templates []*model.Note
+	if _, err := c.doJSON(ctx, http.MethodGet, "/api/templates", nil, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// CreateTemplate creates a note template, whose title and content may contain variables
+// (e.g., {{date}} or {{title}}). It returns ErrBadRequest if the template is empty.
+func (c *Client) CreateTemplate(ctx context.Context, input NoteInput) (*model.Note, error) {
+	var template model.Note
+	if _, err := c.doJSON(ctx, http.MethodPost, "/api/templates", NoteInput{Title: input.Title, Content: input.Content}, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetTemplate returns the template with the given ID, or ErrNotFound if it doesn't exist.
+func (c *Client) GetTemplate(ctx context.Context, id string) (*model.Note, error) {
+	var template model.Note
+	if _, err := c.doJSON(ctx, http.MethodGet, templatePath(id), nil, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateTemplate replaces the title and content of a template and returns the updated template.
+func (c *Client) UpdateTemplate(ctx context.Context, id string, input NoteInput) (*model.Note, error) {
+	var template model.Note
+	if _, err := c.doJSON(ctx, http.MethodPut, templatePath(id), input, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// DeleteTemplate deletes a template, or returns ErrNotFound if it doesn't exist.
+func (c *Client) DeleteTemplate(ctx context.Context, id string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, templatePath(id), nil, nil)
+	return err
+}
+
+// CreateNoteFromTemplate creates a note from a template, substituting its variables, and
+// returns the note. It returns ErrNotFound if the template doesn't exist.
+func (c *Client) CreateNoteFromTemplate(ctx context.Context, templateID string, vars TemplateVariables) (*model.Note, error) {
+	var note model.Note
+	if _, err := c.doJSON(ctx, http.MethodPost, notePath("from-template", templateID), vars, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Formats of Client.Export and Client.Import.
+const (
+	FormatNDJSON   = "ndjson" // One JSON note per line (the default)
+	FormatJSON     = "json"   // A single JSON array of notes
+	FormatMarkdown = "zip-md" // A ZIP archive with a Markdown file per note, with optional YAML front matter
+	FormatENEX     = "enex"   // An Evernote export (import only)
+)
+
+// Conflict policies of Client.Import, for notes that already exist.
+const (
+	ConflictSkip      = "skip"      // Keep the existing note (the default)
+	ConflictOverwrite = "overwrite" // Replace the existing note
+	ConflictFail      = "fail"      // Stop the import at the first existing note
+)
+
+// ImportOptions controls an import.
+type ImportOptions struct {
+	Format     string // FormatNDJSON (the default), FormatJSON, FormatMarkdown, or FormatENEX
+	OnConflict string // ConflictSkip (the default), ConflictOverwrite, or ConflictFail
+}
+
+// ImportResult is the outcome of importing a single record.
+type ImportResult struct {
+	Index  int    `json:"index"`           // Position of the record in the input, starting at 0
+	ID     string `json:"id,omitempty"`    // ID of the note, if the record has one
+	Status string `json:"status"`          // "created", "overwritten", "skipped", "invalid", "conflict", or "failed"
+	Error  string `json:"error,omitempty"` // Why the record was not imported
+}
+
+// ImportSummary is the outcome of an import.
+type ImportSummary struct {
+	Created     int            `json:"created"`         // Number of notes created
+	Overwritten int            `json:"overwritten"`     // Number of existing notes replaced
+	Skipped     int            `json:"skipped"`         // Number of existing notes kept
+	Failed      int            `json:"failed"`          // Number of records that were not imported
+	Results     []ImportResult `json:"results"`         // Outcome of every record, in input order
+	Error       string         `json:"error,omitempty"` // Why the import stopped before the end of malformed input
+}
+
+// Export streams all notes in the given format (FormatNDJSON if empty); it requires the
+// admin token (see WithToken). The caller must close the returned reader. If the service
+// fails during the export, reading fails with an error rather than ending early, so a
+// truncated export can't be mistaken for a complete one.
+func (c *Client) Export(ctx context.Context, format string) (io.ReadCloser, error) {
+	path := "/api/export"
+	if format != "" {
+		path += "?" + url.Values{"format": {format}}.Encode()
+	}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Import imports notes from r, as written by Export in FormatNDJSON or FormatJSON. Notes keep
+// their IDs and timestamps. The input is streamed, so the import is never retried.
+//
+// Imports require the admin token (see WithToken). FormatENEX (an Evernote export) and
+// FormatMarkdown (a ZIP archive of Markdown files, e.g., a zipped Obsidian vault) are
+// converted by the server's admin API. Notes without IDs get IDs derived from their contents or file
+// names, so importing the same files again finds the notes imported before.
+//
+// The summary lists the outcome of every record, and is also returned with an error: with
+// ErrConflict if the import was stopped by an existing note (ConflictFail), or with
+// ErrBadRequest if the input is malformed. Records before the stop stay imported.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportSummary, error) {
+	path, query := "/api/import", url.Values{}
+	if opts.OnConflict != "" {
+		query.Set("on_conflict", opts.OnConflict)
+	}
+	contentType := "application/x-ndjson"
+	switch opts.Format {
+	case FormatJSON:
+		contentType = "application/json"
+	case FormatENEX, FormatMarkdown:
+		path = "/api/admin/import"
+		query.Set("format", opts.Format)
+		contentType = "application/xml"
+		if opts.Format == FormatMarkdown {
+			contentType = "application/zip"
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var summary ImportSummary
+	req := request{method: http.MethodPost, path: path, stream: r, contentType: contentType, errorBody: &summary}
+	if _, err := c.decode(ctx, req, &summary); err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && summary.Results != nil {
+			if apiErr.Message == "" {
+				apiErr.Message = summary.Error
+			}
+			return &summary, err
+		}
+		return nil, err
+	}
+	return &summary, nil
+}
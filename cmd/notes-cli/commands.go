@@ -0,0 +1,370 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang-simple-notes/client"
+	"golang-simple-notes/model"
+
+	"github.com/spf13/cobra"
+)
+
+// listFlags holds the flags of the list and search commands.
+type listFlags struct {
+	sort   string // Sort field; a leading "-" sorts in descending order
+	limit  int    // Maximum number of notes; 0 for all
+	offset int    // Number of notes to skip
+}
+
+// register adds the flags to a command.
+func (f *listFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.sort, "sort", "", `sort by created_at, updated_at, title, or most_viewed; prefix with "-" for descending order`)
+	cmd.Flags().IntVar(&f.limit, "limit", 0, "maximum number of notes (0 for all)")
+	cmd.Flags().IntVar(&f.offset, "offset", 0, "number of notes to skip")
+}
+
+// listNotes lists the notes matching query and writes them in the output format.
+// Without a limit, all notes are fetched page by page.
+func listNotes(cmd *cobra.Command, opts *options, flags *listFlags, query string) error {
+	c, ctx, cancel := opts.client(cmd)
+	defer cancel()
+
+	listOpts := client.ListOptions{Query: query, Limit: flags.limit, Offset: flags.offset}
+	listOpts.Sort, listOpts.Descending = strings.CutPrefix(flags.sort, "-")
+
+	var notes []*model.Note
+	if flags.limit > 0 {
+		page, err := c.ListNotes(ctx, listOpts)
+		if err != nil {
+			return err
+		}
+		notes = page.Notes
+	} else {
+		notes = []*model.Note{}
+		for note, err := range c.Notes(ctx, listOpts) {
+			if err != nil {
+				return err
+			}
+			notes = append(notes, note)
+		}
+	}
+	return writeNotes(cmd.OutOrStdout(), opts.output, notes)
+}
+
+// newListCommand creates the "list" command.
+func newListCommand(opts *options) *cobra.Command {
+	flags := &listFlags{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List notes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listNotes(cmd, opts, flags, "")
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+// newSearchCommand creates the "search" command.
+func newSearchCommand(opts *options) *cobra.Command {
+	flags := &listFlags{}
+	cmd := &cobra.Command{
+		Use:   "search <text>...",
+		Short: "List notes whose title or content contains the text (case-insensitive)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listNotes(cmd, opts, flags, strings.Join(args, " "))
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+// newGetCommand creates the "get" command.
+func newGetCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show a note",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+
+			note, err := c.GetNote(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return writeNote(cmd.OutOrStdout(), opts.output, note)
+		},
+	}
+}
+
+// newCreateCommand creates the "create" command.
+func newCreateCommand(opts *options) *cobra.Command {
+	var title, content string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a note",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input := client.NoteInput{Title: title}
+			var err error
+			if input.Content, err = readContent(cmd, content); err != nil {
+				return err
+			}
+
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+			note, err := c.CreateNote(ctx, input)
+			if err != nil {
+				return err
+			}
+			return writeNote(cmd.OutOrStdout(), opts.output, note)
+		},
+	}
+	cmd.Flags().StringVar(&title, "title", "", "title of the note")
+	cmd.Flags().StringVar(&content, "content", "", `content of the note; "-" reads it from standard input`)
+	return cmd
+}
+
+// newEditCommand creates the "edit" command.
+func newEditCommand(opts *options) *cobra.Command {
+	var title, content string
+	cmd := &cobra.Command{
+		Use:   "edit <id>",
+		Short: "Change the title or content of a note",
+		Long: "Change the title or content of a note; fields without a flag are kept.\n" +
+			"The edit fails if someone else changed the note since it was read.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			titleChanged, contentChanged := cmd.Flags().Changed("title"), cmd.Flags().Changed("content")
+			if !titleChanged && !contentChanged {
+				return errors.New("nothing to change: set --title or --content")
+			}
+
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+			note, err := c.GetNote(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			// Send the revision that was read, so a concurrent change is not overwritten
+			input := client.NoteInput{Title: note.Title, Content: note.Content, Color: note.Color, Icon: note.Icon, Tags: note.Tags,
+				Rev: note.Rev}
+			if titleChanged {
+				input.Title = title
+			}
+			if contentChanged {
+				if input.Content, err = readContent(cmd, content); err != nil {
+					return err
+				}
+			}
+			updated, err := c.UpdateNote(ctx, note.ID, input)
+			if errors.Is(err, client.ErrConflict) {
+				return fmt.Errorf("note %s was changed by someone else; run the edit again: %w", note.ID, err)
+			}
+			if err != nil {
+				return err
+			}
+			return writeNote(cmd.OutOrStdout(), opts.output, updated)
+		},
+	}
+	cmd.Flags().StringVar(&title, "title", "", "new title of the note")
+	cmd.Flags().StringVar(&content, "content", "", `new content of the note; "-" reads it from standard input`)
+	return cmd
+}
+
+// newDeleteCommand creates the "delete" command.
+func newDeleteCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <id>...",
+		Short: "Delete notes",
+		Long:  "Delete notes. In table output, every deleted note is reported; in JSON output, nothing is printed.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+
+			for _, id := range args {
+				if err := c.DeleteNote(ctx, id); err != nil {
+					return fmt.Errorf("failed to delete note %s: %w", id, err)
+				}
+				if opts.output == outputTable {
+					fmt.Fprintf(cmd.OutOrStdout(), "Deleted note %s\n", id)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// newExportCommand creates the "export" command.
+func newExportCommand(opts *options) *cobra.Command {
+	var format, file string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all notes to a file or standard output",
+		Long:  "Export all notes to a file or standard output. Exports need the admin token.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+
+			body, err := c.Export(ctx, format)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+
+			if file == "" {
+				if _, err := io.Copy(cmd.OutOrStdout(), body); err != nil {
+					return fmt.Errorf("export failed: %w", err)
+				}
+				return nil
+			}
+
+			f, err := os.Create(file)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, body); err != nil {
+				f.Close()
+				return fmt.Errorf("export failed: %w", err)
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", client.FormatNDJSON, "export format: ndjson, json, or zip-md")
+	cmd.Flags().StringVar(&file, "file", "", "file to write the export to (default standard output)")
+	return cmd
+}
+
+// newImportCommand creates the "import" command.
+func newImportCommand(opts *options) *cobra.Command {
+	var format, onConflict string
+	cmd := &cobra.Command{
+		Use:   "import <file|directory|->",
+		Short: "Import notes from a file, a directory of Markdown files, or standard input",
+		Long: "Import notes from an export (ndjson or json), an Evernote export (enex), or Markdown files\n" +
+			"with optional YAML front matter (zip-md), such as an Obsidian vault or a Notable directory.\n" +
+			"For zip-md, the argument is a ZIP archive or a directory, which is archived on the fly.\n" +
+			"Imports need the admin token.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch format {
+			case client.FormatNDJSON, client.FormatJSON, client.FormatENEX, client.FormatMarkdown:
+			default:
+				return fmt.Errorf("format must be ndjson, json, enex, or zip-md")
+			}
+			input, err := openImport(cmd, args[0], format)
+			if err != nil {
+				return err
+			}
+			defer input.Close()
+
+			c, ctx, cancel := opts.client(cmd)
+			defer cancel()
+
+			summary, err := c.Import(ctx, input, client.ImportOptions{Format: format, OnConflict: onConflict})
+			if summary != nil {
+				if writeErr := writeImportSummary(cmd.OutOrStdout(), opts.output, summary); writeErr != nil {
+					return writeErr
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("import failed: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", client.FormatNDJSON, "input format: ndjson, json, enex, or zip-md")
+	cmd.Flags().StringVar(&onConflict, "on-conflict", client.ConflictSkip, "what to do with existing notes: skip, overwrite, or fail")
+	return cmd
+}
+
+// openImport opens the input of an import: standard input for "-", a file, or, for the
+// zip-md format, a directory that is archived while it is being uploaded.
+func openImport(cmd *cobra.Command, name, format string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(cmd.InOrStdin()), nil
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.Open(name)
+	}
+	if format != client.FormatMarkdown {
+		return nil, fmt.Errorf("%s is a directory; only the zip-md format imports directories", name)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(zipMarkdownFiles(name, writer))
+	}()
+	return reader, nil
+}
+
+// zipMarkdownFiles writes the Markdown files (.md) of a directory and its subdirectories
+// to w as a ZIP archive, with their paths relative to the directory.
+func zipMarkdownFiles(dir string, w io.Writer) error {
+	archive := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     filepath.ToSlash(rel),
+			Method:   zip.Deflate,
+			Modified: info.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(file, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// readContent returns the content flag, or standard input if it is "-".
+func readContent(cmd *cobra.Command, content string) (string, error) {
+	if content != "-" {
+		return content, nil
+	}
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return "", fmt.Errorf("failed to read content: %w", err)
+	}
+	return string(data), nil
+}
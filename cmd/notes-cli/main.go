@@ -0,0 +1,104 @@
+// Package main is notes-cli, a command-line client for the REST API of the notes service.
+// It lists, reads, creates, edits, deletes, searches, and exports notes, with table output
+// for people and JSON output for scripts and smoke tests:
+//
+//	notes-cli --url http://localhost:8080 create --title "Shopping" --content "Milk"
+//	notes-cli search milk -o json | jq -r '.[]._id'
+//
+// Only the REST API is supported: the gRPC server does not serve requests yet.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang-simple-notes/client"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the --output flag.
+const (
+	outputTable = "table" // Aligned columns for people
+	outputJSON  = "json"  // The API's JSON, for scripts
+)
+
+// options holds the values of the global command-line flags.
+type options struct {
+	url     string        // URL of the notes service (--url)
+	token   string        // Bearer token sent with every request (--token)
+	output  string        // Output format (--output)
+	timeout time.Duration // Timeout of each command (--timeout)
+}
+
+// main runs the command given on the command line and exits with status 1 if it fails.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCommand creates the command-line interface.
+//
+// Returns:
+//   - The root command, with the list, get, create, edit, delete, search, export, and import subcommands
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:   "notes-cli",
+		Short: "Command-line client for the notes REST API",
+		Long: "notes-cli manages the notes of a notes service through its REST API.\n\n" +
+			"The service URL and token default to NOTES_URL and NOTES_TOKEN.",
+		SilenceUsage:  true, // Don't print the usage for errors that are not about the command line
+		SilenceErrors: true, // main prints the error
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.output != outputTable && opts.output != outputJSON {
+				return fmt.Errorf("output must be %s or %s", outputTable, outputJSON)
+			}
+			return nil
+		},
+	}
+
+	url := os.Getenv("NOTES_URL")
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.url, "url", url, "URL of the notes service (default from NOTES_URL)")
+	flags.StringVar(&opts.token, "token", os.Getenv("NOTES_TOKEN"), "bearer token for the service (default from NOTES_TOKEN)")
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "output format: table or json")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of the command")
+
+	root.AddCommand(
+		newListCommand(opts),
+		newGetCommand(opts),
+		newCreateCommand(opts),
+		newEditCommand(opts),
+		newDeleteCommand(opts),
+		newSearchCommand(opts),
+		newExportCommand(opts),
+		newImportCommand(opts),
+	)
+	return root
+}
+
+// client creates a client for the service and a context with the command timeout.
+// The caller must call the returned cancel function.
+func (o *options) client(cmd *cobra.Command) (*client.Client, context.Context, context.CancelFunc) {
+	var clientOpts []client.Option
+	if o.token != "" {
+		clientOpts = append(clientOpts, client.WithToken(o.token))
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+	return client.New(o.url, clientOpts...), ctx, cancel
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/rest"
+	"golang-simple-notes/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestServer starts a notes service with in-memory storage
+func newTestServer(t *testing.T, opts ...rest.HandlerOption) string {
+	t.Helper()
+	r := chi.NewRouter()
+	rest.NewHandler(storage.NewInMemoryStorage(), opts...).RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// run executes the CLI against a service with the given standard input, and returns its output.
+func run(t *testing.T, url, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetIn(strings.NewReader(stdin))
+	root.SetArgs(append([]string{"--url", url}, args...))
+	err := root.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+// createNote creates a note with the CLI and returns it
+func createNote(t *testing.T, url string, args ...string) model.Note {
+	t.Helper()
+	out, err := run(t, url, "", append([]string{"create", "-o", "json"}, args...)...)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	var note model.Note
+	if err := json.Unmarshal([]byte(out), &note); err != nil {
+		t.Fatalf("create printed invalid JSON %q: %v", out, err)
+	}
+	return note
+}
+
+func TestCLI_NoteLifecycle(t *testing.T) {
+	url := newTestServer(t)
+	note := createNote(t, url, "--title", "Shopping", "--content", "Milk")
+
+	out, err := run(t, url, "", "get", note.ID)
+	if err != nil || !strings.Contains(out, "Shopping\n") || !strings.HasSuffix(out, "\nMilk\n") {
+		t.Errorf("Unexpected get output %q: %v", out, err)
+	}
+
+	// Content is read from standard input with "-", and the title is kept
+	out, err = run(t, url, "Milk\nEggs\n", "edit", note.ID, "--content", "-", "-o", "json")
+	if err != nil || !strings.Contains(out, `"content": "Milk\nEggs\n"`) || !strings.Contains(out, `"title": "Shopping"`) {
+		t.Errorf("Unexpected edit output %q: %v", out, err)
+	}
+	if _, err := run(t, url, "", "edit", note.ID); err == nil {
+		t.Error("Expected an error for an edit without changes")
+	}
+
+	out, err = run(t, url, "", "delete", note.ID)
+	if err != nil || out != "Deleted note "+note.ID+"\n" {
+		t.Errorf("Unexpected delete output %q: %v", out, err)
+	}
+	if _, err := run(t, url, "", "get", note.ID); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error, got %v", err)
+	}
+}
+
+func TestCLI_ListAndSearch(t *testing.T) {
+	url := newTestServer(t)
+	for _, title := range []string{"Groceries", "Meeting notes", "Gift ideas"} {
+		createNote(t, url, "--title", title, "--content", "x")
+	}
+
+	out, err := run(t, url, "", "list", "--sort", "-title")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "ID ") || !strings.Contains(lines[1], "Meeting notes") || !strings.Contains(lines[3], "Gift ideas") {
+		t.Errorf("Unexpected list output:\n%s", out)
+	}
+
+	out, err = run(t, url, "", "search", "g", "-o", "json", "--sort", "title", "--limit", "1")
+	var notes []model.Note
+	if err != nil || json.Unmarshal([]byte(out), &notes) != nil || len(notes) != 1 || notes[0].Title != "Gift ideas" {
+		t.Errorf("Unexpected search output %q: %v", out, err)
+	}
+}
+
+func TestCLI_Export(t *testing.T) {
+	url := newTestServer(t, rest.WithAdminToken("secret"))
+	createNote(t, url, "--title", "Exported", "--content", "x")
+
+	file := filepath.Join(t.TempDir(), "notes.json")
+	if _, err := run(t, url, "", "--token", "secret", "export", "--format", "json", "--file", file); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	var notes []model.Note
+	if err != nil || json.Unmarshal(data, &notes) != nil || len(notes) != 1 || notes[0].Title != "Exported" {
+		t.Errorf("Unexpected export %q: %v", data, err)
+	}
+}
+
+func TestCLI_Import(t *testing.T) {
+	url := newTestServer(t, rest.WithAdminToken("secret"))
+	dir := t.TempDir()
+
+	enex := filepath.Join(dir, "export.enex")
+	err := os.WriteFile(enex, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<en-export><note><title>From Evernote</title><content><![CDATA[<en-note><div>Hello</div></en-note>]]></content></note></en-export>`), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	vault := filepath.Join(dir, "vault")
+	if err := os.MkdirAll(filepath.Join(vault, "daily"), 0o700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(vault, "daily", "Today.md"), []byte("---\ntitle: From Obsidian\n---\nHi"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	out, err := run(t, url, "", "--token", "secret", "import", "--format", "enex", enex)
+	if err != nil || !strings.Contains(out, "Created 1, overwritten 0, skipped 0, failed 0") {
+		t.Errorf("Unexpected ENEX import output %q: %v", out, err)
+	}
+	if out, err := run(t, url, "", "--token", "secret", "import", "--format", "zip-md", vault); err != nil || !strings.Contains(out, "Created 1") {
+		t.Errorf("Unexpected Markdown import output %q: %v", out, err)
+	}
+	// Importing the same files again skips the notes
+	if out, err := run(t, url, "", "--token", "secret", "import", "--format", "enex", enex); err != nil || !strings.Contains(out, "skipped 1") {
+		t.Errorf("Unexpected repeated import output %q: %v", out, err)
+	}
+
+	out, err = run(t, url, "", "list", "-o", "json")
+	var notes []model.Note
+	if err != nil || json.Unmarshal([]byte(out), &notes) != nil || len(notes) != 2 {
+		t.Errorf("Expected both imported notes, got %q: %v", out, err)
+	}
+
+	// Without the admin token, imports are rejected; directories need the zip-md format
+	if _, err := run(t, url, "", "import", "--format", "enex", enex); err == nil {
+		t.Error("Expected an error without the admin token")
+	}
+	if _, err := run(t, url, "", "import", vault); err == nil {
+		t.Error("Expected an error for a directory in the ndjson format")
+	}
+}
+
+func TestCLI_InvalidUsage(t *testing.T) {
+	url := newTestServer(t)
+	tests := map[string][]string{
+		"UnknownOutput": {"list", "-o", "yaml"},
+		"CreateEmpty":   {"create"},
+		"GetWithoutID":  {"get"},
+		"SearchNoText":  {"search"},
+		"InvalidSort":   {"list", "--sort", "color"},
+		"UnknownExport": {"export", "--format", "csv"},
+		"UnknownImport": {"import", "--format", "csv", "-"},
+		"ExtraArgument": {"list", "all"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := run(t, url, "", args...); err == nil {
+				t.Errorf("Expected an error for %v", args)
+			}
+		})
+	}
+}
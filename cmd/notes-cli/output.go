@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang-simple-notes/client"
+	"golang-simple-notes/model"
+)
+
+// maxTitleWidth is the number of title characters shown in table output.
+const maxTitleWidth = 50
+
+// writeNotes writes a list of notes as a table, or as a JSON array.
+func writeNotes(w io.Writer, format string, notes []*model.Note) error {
+	if format == outputJSON {
+		return writeJSON(w, notes)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tUPDATED")
+	for _, note := range notes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", note.ID, truncate(note.Title, maxTitleWidth), formatTime(note.UpdatedAt))
+	}
+	return tw.Flush()
+}
+
+// writeNote writes a single note with its fields and content, or as a JSON object.
+func writeNote(w io.Writer, format string, note *model.Note) error {
+	if format == outputJSON {
+		return writeJSON(w, note)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", note.ID)
+	if note.Rev != "" {
+		fmt.Fprintf(tw, "Revision:\t%s\n", note.Rev)
+	}
+	fmt.Fprintf(tw, "Title:\t%s\n", note.Title)
+	fmt.Fprintf(tw, "Created:\t%s\n", formatTime(note.CreatedAt))
+	fmt.Fprintf(tw, "Updated:\t%s\n", formatTime(note.UpdatedAt))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if note.Content != "" {
+		_, err := fmt.Fprintf(w, "\n%s\n", strings.TrimRight(note.Content, "\n"))
+		return err
+	}
+	return nil
+}
+
+// writeImportSummary writes the counters of an import and the records that were not
+// imported, or the whole summary as JSON.
+func writeImportSummary(w io.Writer, format string, summary *client.ImportSummary) error {
+	if format == outputJSON {
+		return writeJSON(w, summary)
+	}
+
+	fmt.Fprintf(w, "Created %d, overwritten %d, skipped %d, failed %d\n",
+		summary.Created, summary.Overwritten, summary.Skipped, summary.Failed)
+	for _, result := range summary.Results {
+		if result.Error == "" {
+			continue
+		}
+		id := result.ID
+		if id == "" {
+			id = "-"
+		}
+		if _, err := fmt.Fprintf(w, "Record %d (%s): %s: %s\n", result.Index, id, result.Status, result.Error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON writes a value as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// truncate shortens a single-line version of s to at most width characters.
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s
+}
+
+// formatTime formats a timestamp in the local time zone, to the second.
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -1,33 +1,1439 @@
 package main
 
-import "os"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-// Config holds all configuration for the application
+	"golang-simple-notes/blob"
+	"golang-simple-notes/broker"
+	"golang-simple-notes/embedding"
+	"golang-simple-notes/kms"
+	"golang-simple-notes/logging"
+	"golang-simple-notes/scheduler"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/summarizer"
+	"golang-simple-notes/vector"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all configuration for the application.
+//
+// Settings are taken from, in increasing order of precedence:
+// 1. Built-in defaults (see defaultConfig)
+// 2. An optional YAML or TOML configuration file (see LoadConfig)
+// 3. Environment variables
+// 4. Command-line flags, for the few settings that have one (see cli.go)
+//
+// The file keys are the lower-case names of the environment variables
+// (e.g., "storage_type" for STORAGE_TYPE), as listed in the struct tags.
 type Config struct {
-	StorageType       string
-	CouchDBURL        string
-	CouchDBName       string
-	MongoDBURI        string
-	MongoDBName       string
-	MongoDBCollection string
-	RESTPort          string
-	GRPCPort          string
+	StorageType       string `yaml:"storage_type" toml:"storage_type"`
+	CouchDBURL        string `yaml:"couchdb_url" toml:"couchdb_url"`
+	CouchDBName       string `yaml:"couchdb_db" toml:"couchdb_db"`
+	CouchDBUser       string `yaml:"couchdb_user" toml:"couchdb_user"`         // Overrides the credentials in CouchDBURL
+	CouchDBPassword   string `yaml:"couchdb_password" toml:"couchdb_password"` // Never logged
+	MongoDBURI        string `yaml:"mongodb_uri" toml:"mongodb_uri"`
+	MongoDBName       string `yaml:"mongodb_db" toml:"mongodb_db"`
+	MongoDBCollection string `yaml:"mongodb_collection" toml:"mongodb_collection"`
+	RESTPort          string `yaml:"rest_port" toml:"rest_port"` // Only configurable in the configuration file
+	GRPCPort          string `yaml:"grpc_port" toml:"grpc_port"` // Only configurable in the configuration file
+
+	// MongoDB client settings; they override the same options in the connection string
+	MongoDBMaxPoolSize    int    `yaml:"mongodb_max_pool_size" toml:"mongodb_max_pool_size"`     // Maximum number of connections in the pool
+	MongoDBMinPoolSize    int    `yaml:"mongodb_min_pool_size" toml:"mongodb_min_pool_size"`     // Minimum number of idle connections kept in the pool
+	MongoDBReadPreference string `yaml:"mongodb_read_preference" toml:"mongodb_read_preference"` // "primary", "primaryPreferred", "secondary", "secondaryPreferred", or "nearest"
+	MongoDBWriteConcern   string `yaml:"mongodb_write_concern" toml:"mongodb_write_concern"`     // "majority", or the number of nodes that must acknowledge a write
+	MongoDBChangeStreams  bool   `yaml:"mongodb_change_streams" toml:"mongodb_change_streams"`   // Notify watchers about changes made by other instances (requires a replica set)
+
+	// CouchDBChangesFeed notifies watchers about changes made by other instances,
+	// read from the CouchDB changes feed
+	CouchDBChangesFeed bool `yaml:"couchdb_changes_feed" toml:"couchdb_changes_feed"`
+
+	// CouchDBConflictPolicy resolves concurrent updates of a note: "last-write-wins"
+	// retries the update on the current revision, "reject" returns 409 Conflict to the client
+	CouchDBConflictPolicy string `yaml:"couchdb_conflict_policy" toml:"couchdb_conflict_policy"`
+
+	// LogLevel is the minimum level of log messages: "debug", "info", "warn", or "error"
+	LogLevel string `yaml:"log_level" toml:"log_level"`
+
+	// Logging of REST request and response bodies at debug level; disabled when BodyLogMaxBytes is zero
+	BodyLogMaxBytes      int     `yaml:"body_log_max_bytes" toml:"body_log_max_bytes"`           // Maximum number of bytes logged of each body
+	BodyLogRate          float64 `yaml:"body_log_rate" toml:"body_log_rate"`                     // Maximum number of requests logged per second
+	BodyLogExcludedPaths string  `yaml:"body_log_excluded_paths" toml:"body_log_excluded_paths"` // Comma-separated path prefixes whose bodies are never logged
+
+	// StorageStrict makes startup fail if the storage backend is unreachable, instead of
+	// falling back to in-memory storage (which loses all notes on restart)
+	StorageStrict bool `yaml:"storage_strict" toml:"storage_strict"`
+
+	// Retries while connecting to the storage backend at startup
+	StorageRetryMaxAttempts    int           `yaml:"storage_retry_max_attempts" toml:"storage_retry_max_attempts"`       // Maximum number of connection attempts
+	StorageRetryInitialDelay   time.Duration `yaml:"storage_retry_initial_delay" toml:"storage_retry_initial_delay"`     // Delay after the first failed attempt, doubled after every further one (with jitter)
+	StorageRetryMaxDelay       time.Duration `yaml:"storage_retry_max_delay" toml:"storage_retry_max_delay"`             // Upper bound of the delay between attempts
+	StorageRetryAttemptTimeout time.Duration `yaml:"storage_retry_attempt_timeout" toml:"storage_retry_attempt_timeout"` // Maximum duration of a single attempt (zero means no limit)
+	StorageRetryDeadline       time.Duration `yaml:"storage_retry_deadline" toml:"storage_retry_deadline"`               // Maximum total duration of all attempts (zero means no limit)
+
+	// Reconnection after a fallback to in-memory storage
+	StorageReconnectInterval time.Duration `yaml:"storage_reconnect_interval" toml:"storage_reconnect_interval"` // How often to try to reconnect to the configured backend (zero disables reconnection)
+	StorageReconnectReplay   bool          `yaml:"storage_reconnect_replay" toml:"storage_reconnect_replay"`     // Copy the notes written in memory to the backend when reconnecting
+
+	// Circuit breaker around the storage backend (see storage.CircuitBreakerStorage)
+	StorageCircuitFailureThreshold int           `yaml:"storage_circuit_failure_threshold" toml:"storage_circuit_failure_threshold"` // Consecutive failures that open the circuit (zero disables the circuit breaker)
+	StorageCircuitOpenTimeout      time.Duration `yaml:"storage_circuit_open_timeout" toml:"storage_circuit_open_timeout"`           // How long an open circuit rejects operations before probing the backend again
+
+	// Read-through cache of notes and lists (see storage.CachedStorage)
+	StorageCacheSize int           `yaml:"storage_cache_size" toml:"storage_cache_size"` // Maximum number of cached notes and lists (zero disables the cache)
+	StorageCacheTTL  time.Duration `yaml:"storage_cache_ttl" toml:"storage_cache_ttl"`   // How long cached entries are served
+
+	// StorageCacheRedisURL enables a Redis cache shared by all instances (e.g., "redis://localhost:6379/0");
+	// with StorageCacheSize, each instance keeps a local cache in front of Redis
+	StorageCacheRedisURL string `yaml:"storage_cache_redis_url" toml:"storage_cache_redis_url"`
+
+	// StorageLogOperations logs every storage operation (not only failures) with its request ID
+	StorageLogOperations bool `yaml:"storage_log_operations" toml:"storage_log_operations"`
+
+	// StorageSlowThreshold logs storage operations taking at least this long (zero disables the log)
+	StorageSlowThreshold time.Duration `yaml:"storage_slow_threshold" toml:"storage_slow_threshold"`
+
+	// StorageOpTimeout bounds every single storage operation, so a slow query fails on its own
+	// instead of holding the request until the server timeout (zero means no limit)
+	StorageOpTimeout time.Duration `yaml:"storage_op_timeout" toml:"storage_op_timeout"`
+
+	// Bounds of in-memory note storage, so that it cannot exhaust the memory of the process (zero means no limit)
+	StorageMemoryMaxNotes int    `yaml:"storage_memory_max_notes" toml:"storage_memory_max_notes"` // Maximum number of notes
+	StorageMemoryMaxBytes int    `yaml:"storage_memory_max_bytes" toml:"storage_memory_max_bytes"` // Maximum total size of the notes, in bytes (approximate)
+	StorageMemoryEviction string `yaml:"storage_memory_eviction" toml:"storage_memory_eviction"`   // Writes beyond the limits: "reject" (507 Insufficient Storage) or "lru" (evict the least recently used notes)
+
+	// Snapshots of in-memory storage, which keep the notes across restarts (disabled when StorageSnapshotPath is empty)
+	StorageSnapshotPath     string        `yaml:"storage_snapshot_path" toml:"storage_snapshot_path"`         // JSON file the notes are loaded from at startup and saved to
+	StorageSnapshotInterval time.Duration `yaml:"storage_snapshot_interval" toml:"storage_snapshot_interval"` // Time between snapshots of changed notes (zero saves only on shutdown)
+
+	// Encryption at rest (disabled when EncryptionKeys, EncryptionKeysFile, and EncryptionKMS are empty)
+	EncryptionKeys         string `yaml:"encryption_keys" toml:"encryption_keys"`                   // Comma-separated "<key ID>:<base64 key>" pairs
+	EncryptionKeysFile     string `yaml:"encryption_keys_file" toml:"encryption_keys_file"`         // File with the key pairs, one per line or comma-separated (e.g., a mounted secret)
+	EncryptionActiveKey    string `yaml:"encryption_active_key" toml:"encryption_active_key"`       // Key ID used to encrypt new data
+	EncryptionLazyRotation bool   `yaml:"encryption_lazy_rotation" toml:"encryption_lazy_rotation"` // Re-encrypt notes on read when they use an old key
+	EncryptionKMS          string `yaml:"encryption_kms" toml:"encryption_kms"`                     // Envelope encryption with data keys wrapped by "aws", "gcp", or "vault"; local keys then only decrypt old data
+	EncryptionKMSKey       string `yaml:"encryption_kms_key" toml:"encryption_kms_key"`             // KMS key that wraps the data keys (AWS key ARN or alias, GCP key resource name, Vault transit key)
+	EncryptionKMSEndpoint  string `yaml:"encryption_kms_endpoint" toml:"encryption_kms_endpoint"`   // URL of the KMS (e.g., the Vault address); empty for the default
+
+	// Dual-write migration (disabled when DualWriteTarget is empty)
+	DualWriteTarget string `yaml:"dual_write_target" toml:"dual_write_target"` // Storage type that receives a copy of every write ("couchdb", "mongodb", or "memory")
+	DualWriteVerify bool   `yaml:"dual_write_verify" toml:"dual_write_verify"` // Re-read every write from both backends and report divergences
+
+	// Asynchronous dual-write: writes don't wait for the target, and reconciliation repairs what it missed
+	DualWriteMode              string        `yaml:"dual_write_mode" toml:"dual_write_mode"`                             // "sync" or "async"
+	DualWriteReconcileInterval time.Duration `yaml:"dual_write_reconcile_interval" toml:"dual_write_reconcile_interval"` // Time between reconciliation passes in async mode (zero disables them)
+
+	// Debug endpoints (pprof and expvar), served on a separate address; disabled when DebugAddr is empty
+	DebugAddr  string `yaml:"debug_addr" toml:"debug_addr"`   // Listen address of the debug server (e.g., "localhost:6060")
+	DebugToken string `yaml:"debug_token" toml:"debug_token"` // Bearer token required by the debug server (optional)
+
+	// REST server timeouts and limits, guarding against slow or oversized requests (zero means no limit)
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout" toml:"http_read_header_timeout"` // Maximum time to read request headers
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout" toml:"http_read_timeout"`               // Maximum time to read the entire request, including the body
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout" toml:"http_write_timeout"`             // Maximum time from the end of the request headers to the end of the response
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout" toml:"http_idle_timeout"`               // Maximum time to keep an idle keep-alive connection open
+	HTTPMaxHeaderBytes    int           `yaml:"http_max_header_bytes" toml:"http_max_header_bytes"`       // Maximum size of request headers, in bytes
+
+	// Cache of the responses of the REST list, count, and search endpoints (see rest.ResponseCache)
+	HTTPCacheTTL  time.Duration `yaml:"http_cache_ttl" toml:"http_cache_ttl"`   // How long cached responses are served (zero disables the cache)
+	HTTPCacheSize int           `yaml:"http_cache_size" toml:"http_cache_size"` // Maximum number of cached responses
+
+	// Startup dependency gate: wait for the storage and message brokers before binding ports
+	StartupWaitTimeout  time.Duration `yaml:"startup_wait_timeout" toml:"startup_wait_timeout"`   // Maximum time to wait for the dependencies (zero disables the wait)
+	StartupWaitInterval time.Duration `yaml:"startup_wait_interval" toml:"startup_wait_interval"` // Delay between checks of a dependency that isn't reachable yet
+
+	// ShutdownDrainTimeout is how long shutdown waits for in-flight REST and gRPC requests
+	// before closing the publishers, webhooks, and storage they use
+	ShutdownDrainTimeout time.Duration `yaml:"shutdown_drain_timeout" toml:"shutdown_drain_timeout"`
+
+	// Load shedding: /api requests beyond these limits are rejected with 503 Service Unavailable (zero disables a limit)
+	MaxInFlightRequests int           `yaml:"max_inflight_requests" toml:"max_inflight_requests"` // Maximum number of requests handled at once
+	MaxInFlightReads    int           `yaml:"max_inflight_reads" toml:"max_inflight_reads"`       // Maximum number of GET, HEAD, and OPTIONS requests handled at once
+	MaxInFlightWrites   int           `yaml:"max_inflight_writes" toml:"max_inflight_writes"`     // Maximum number of other requests handled at once
+	LoadShedRetryAfter  time.Duration `yaml:"load_shed_retry_after" toml:"load_shed_retry_after"` // Delay suggested to rejected clients in the Retry-After header
+
+	// Quotas per owner of notes, identified by a request header (zero disables a limit)
+	QuotaMaxNotes    int    `yaml:"quota_max_notes" toml:"quota_max_notes"`       // Maximum number of notes of an owner
+	QuotaMaxBytes    int    `yaml:"quota_max_bytes" toml:"quota_max_bytes"`       // Maximum total size of the titles and contents of the notes of an owner
+	QuotaOwnerHeader string `yaml:"quota_owner_header" toml:"quota_owner_header"` // Request header identifying the owner (e.g., an API key)
+	QuotaAPIKeys     string `yaml:"quota_api_keys" toml:"quota_api_keys"`         // Comma-separated API keys accepted in the owner header; other values are rejected
+
+	// Views of the notes, counted in memory and written in batches
+	ViewFlushInterval time.Duration `yaml:"view_flush_interval" toml:"view_flush_interval"` // Time between writes of the counted views (zero disables view counting)
+
+	// Background jobs: webhook deliveries, asynchronous imports, and cleanups
+	JobWorkers   int `yaml:"job_workers" toml:"job_workers"`       // Number of jobs run at once
+	JobQueueSize int `yaml:"job_queue_size" toml:"job_queue_size"` // Number of jobs that can wait for a worker; more are rejected
+
+	// Scheduled tasks, each enabled on its own and run as a background job on a cron schedule
+	ScheduleJitter time.Duration `yaml:"schedule_jitter" toml:"schedule_jitter"` // Maximum random delay added to each scheduled run
+	BackupEnabled  bool          `yaml:"backup_enabled" toml:"backup_enabled"`   // Write an NDJSON export of every note to BackupDir
+	BackupSchedule string        `yaml:"backup_schedule" toml:"backup_schedule"` // When backups are written
+	BackupDir      string        `yaml:"backup_dir" toml:"backup_dir"`           // Directory of the backup files
+	BackupKeep     int           `yaml:"backup_keep" toml:"backup_keep"`         // Number of most recent backups kept (zero keeps every backup)
+	PurgeEnabled   bool          `yaml:"purge_enabled" toml:"purge_enabled"`     // Delete the notes not updated for PurgeMaxAge
+	PurgeSchedule  string        `yaml:"purge_schedule" toml:"purge_schedule"`   // When old notes are purged
+	PurgeMaxAge    time.Duration `yaml:"purge_max_age" toml:"purge_max_age"`     // Age of the last update after which a note is purged
+	StatsEnabled   bool          `yaml:"stats_enabled" toml:"stats_enabled"`     // Recompute the note statistics exported as metrics
+	StatsSchedule  string        `yaml:"stats_schedule" toml:"stats_schedule"`   // When the note statistics are recomputed
+
+	// Blob store of large files, such as exports, downloaded directly from it (disabled when BlobStore is empty)
+	BlobStore     string        `yaml:"blob_store" toml:"blob_store"`           // Blob store type: "s3" (including MinIO) or "gridfs"
+	BlobURLExpiry time.Duration `yaml:"blob_url_expiry" toml:"blob_url_expiry"` // Validity of the download URLs (at most 7 days)
+	BlobURLSecret string        `yaml:"blob_url_secret" toml:"blob_url_secret"` // Key of the signatures of the download URLs served by the API (gridfs); never logged
+	BlobBaseURL   string        `yaml:"blob_base_url" toml:"blob_base_url"`     // Public URL of the API in the download URLs served by it (gridfs); empty makes them relative
+	GridFSBucket  string        `yaml:"gridfs_bucket" toml:"gridfs_bucket"`     // GridFS bucket in the MongoDB database of the notes (gridfs)
+	S3Endpoint    string        `yaml:"s3_endpoint" toml:"s3_endpoint"`         // URL of the S3 API (e.g., http://minio:9000)
+	S3Region      string        `yaml:"s3_region" toml:"s3_region"`             // Region of the bucket
+	S3Bucket      string        `yaml:"s3_bucket" toml:"s3_bucket"`             // Bucket of the files
+	S3AccessKey   string        `yaml:"s3_access_key" toml:"s3_access_key"`     // Access key ID
+	S3SecretKey   string        `yaml:"s3_secret_key" toml:"s3_secret_key"`     // Secret access key; never logged
+	S3PathStyle   bool          `yaml:"s3_path_style" toml:"s3_path_style"`     // Address the bucket in the path rather than the host name (needed by MinIO)
+
+	// Files attached to notes, stored by the storage backend itself (CouchDB or in-memory)
+	AttachmentMaxBytes int    `yaml:"attachment_max_bytes" toml:"attachment_max_bytes"` // Maximum size of an attachment (zero disables attachments)
+	ThumbnailSizes     string `yaml:"thumbnail_sizes" toml:"thumbnail_sizes"`           // Comma-separated sizes in pixels of the thumbnails of image attachments (empty disables thumbnails)
+
+	// Summaries of notes, for POST /api/notes/{id}/summarize (disabled when Summarizer is empty)
+	Summarizer          string        `yaml:"summarizer" toml:"summarizer"`                         // "extractive" (no external service) or "llm" (OpenAI-compatible chat completions API)
+	SummarySentences    int           `yaml:"summary_sentences" toml:"summary_sentences"`           // Maximum number of sentences of a summary
+	SummarizerLLMURL    string        `yaml:"summarizer_llm_url" toml:"summarizer_llm_url"`         // Base URL of the LLM API (e.g., https://api.openai.com/v1)
+	SummarizerLLMAPIKey string        `yaml:"summarizer_llm_api_key" toml:"summarizer_llm_api_key"` // API key of the LLM API, sent as a bearer token; never logged
+	SummarizerLLMModel  string        `yaml:"summarizer_llm_model" toml:"summarizer_llm_model"`     // Model that writes the summaries
+	SummarizerTimeout   time.Duration `yaml:"summarizer_timeout" toml:"summarizer_timeout"`         // Timeout of a request to the LLM API
+
+	// Semantic search, with ?mode=semantic on GET /api/notes/search (disabled when Embedder is empty)
+	Embedder           string        `yaml:"embedder" toml:"embedder"`                       // "hashing" (no external service) or "openai" (OpenAI-compatible embeddings API)
+	EmbedderDimensions int           `yaml:"embedder_dimensions" toml:"embedder_dimensions"` // Number of dimensions of the vectors of the hashing embedder
+	EmbedderURL        string        `yaml:"embedder_url" toml:"embedder_url"`               // Base URL of the embeddings API (e.g., https://api.openai.com/v1)
+	EmbedderAPIKey     string        `yaml:"embedder_api_key" toml:"embedder_api_key"`       // API key of the embeddings API, sent as a bearer token; never logged
+	EmbedderModel      string        `yaml:"embedder_model" toml:"embedder_model"`           // Embedding model (e.g., text-embedding-3-small)
+	EmbedderTimeout    time.Duration `yaml:"embedder_timeout" toml:"embedder_timeout"`       // Timeout of a request to the embeddings API
+	VectorIndex        string        `yaml:"vector_index" toml:"vector_index"`               // "memory" (rebuilt at startup) or "qdrant"
+	QdrantURL          string        `yaml:"qdrant_url" toml:"qdrant_url"`                   // URL of the Qdrant REST API (e.g., http://qdrant:6333)
+	QdrantCollection   string        `yaml:"qdrant_collection" toml:"qdrant_collection"`     // Qdrant collection of the vectors
+	QdrantAPIKey       string        `yaml:"qdrant_api_key" toml:"qdrant_api_key"`           // API key of Qdrant; never logged
+
+	// HTTP/2 for the REST server (always available over TLS)
+	RESTH2C                   bool          `yaml:"rest_h2c" toml:"rest_h2c"`                                         // Accept HTTP/2 without TLS (h2c with prior knowledge)
+	HTTP2MaxConcurrentStreams int           `yaml:"http2_max_concurrent_streams" toml:"http2_max_concurrent_streams"` // Maximum number of concurrent requests per HTTP/2 connection (zero means the Go default)
+	HTTP2PingInterval         time.Duration `yaml:"http2_ping_interval" toml:"http2_ping_interval"`                   // Ping idle HTTP/2 connections after this long to detect dead peers (zero disables pings)
+
+	// HTTPS for the REST server (disabled when RESTTLSCert and RESTTLSKey are empty)
+	RESTTLSCert      string `yaml:"rest_tls_cert" toml:"rest_tls_cert"`                     // Path to the PEM-encoded server certificate (chain)
+	RESTTLSKey       string `yaml:"rest_tls_key" toml:"rest_tls_key"`                       // Path to the PEM-encoded private key of the certificate
+	RESTTLSClientCA  string `yaml:"rest_tls_client_ca" toml:"rest_tls_client_ca"`           // Path to PEM-encoded CA certificates; if set, clients must present a certificate signed by them
+	RESTRedirectAddr string `yaml:"rest_http_redirect_addr" toml:"rest_http_redirect_addr"` // Listen address of a plain HTTP server redirecting to HTTPS (e.g., ":8079"); disabled when empty
+
+	// Webhooks receiving signed payloads for every note event; more can be registered through the admin API
+	WebhookURLs              string        `yaml:"webhook_urls" toml:"webhook_urls"`                               // Comma-separated URLs of the webhooks
+	WebhookSecret            string        `yaml:"webhook_secret" toml:"webhook_secret"`                           // Key of the HMAC-SHA256 payload signatures (required by webhook_urls)
+	WebhookMaxAttempts       int           `yaml:"webhook_max_attempts" toml:"webhook_max_attempts"`               // Maximum number of attempts per delivery
+	WebhookRetryInitialDelay time.Duration `yaml:"webhook_retry_initial_delay" toml:"webhook_retry_initial_delay"` // Delay after the first failed attempt, doubled after every further one (with jitter)
+	WebhookRetryMaxDelay     time.Duration `yaml:"webhook_retry_max_delay" toml:"webhook_retry_max_delay"`         // Upper bound of the delay between attempts
+	WebhookTimeout           time.Duration `yaml:"webhook_timeout" toml:"webhook_timeout"`                         // Maximum duration of a single delivery attempt
+	WebhookAllowPrivate      bool          `yaml:"webhook_allow_private" toml:"webhook_allow_private"`             // Let webhooks and note watches call private, loopback, and link-local addresses
+
+	// AdminToken is the bearer token required by the /api/admin, /api/export, and /api/import
+	// endpoints, which are disabled without it (optional)
+	AdminToken string `yaml:"admin_token" toml:"admin_token"`
+
+	// UIEnabled serves the web UI for managing notes at /ui, on the REST port
+	UIEnabled bool `yaml:"ui_enabled" toml:"ui_enabled"`
+
+	// Security headers added to every REST response (X-Content-Type-Options is always sent when enabled)
+	SecurityHeaders               bool          `yaml:"security_headers" toml:"security_headers"`                                 // Add the security headers below
+	SecurityFrameOptions          string        `yaml:"security_frame_options" toml:"security_frame_options"`                     // X-Frame-Options: "DENY" or "SAMEORIGIN" (empty leaves it out)
+	SecurityReferrerPolicy        string        `yaml:"security_referrer_policy" toml:"security_referrer_policy"`                 // Referrer-Policy (empty leaves it out)
+	SecurityContentSecurityPolicy string        `yaml:"security_content_security_policy" toml:"security_content_security_policy"` // Content-Security-Policy of every response (empty for the web UI's built-in policy only)
+	SecurityHSTSMaxAge            time.Duration `yaml:"security_hsts_max_age" toml:"security_hsts_max_age"`                       // max-age of Strict-Transport-Security, sent over HTTPS only (zero leaves it out)
+
+	// RESTPutCreates makes PUT /api/notes/{id} create the note with that ID if it doesn't exist
+	RESTPutCreates bool `yaml:"rest_put_creates" toml:"rest_put_creates"`
+
+	// Kafka topic receiving every note event (disabled when KafkaBrokers is empty)
+	KafkaBrokers  string `yaml:"kafka_brokers" toml:"kafka_brokers"`   // Comma-separated bootstrap brokers (host:port)
+	KafkaTopic    string `yaml:"kafka_topic" toml:"kafka_topic"`       // Topic of the events, keyed by note ID
+	KafkaEncoding string `yaml:"kafka_encoding" toml:"kafka_encoding"` // Encoding of the events: "json" or "avro"
+
+	// NATS subjects receiving every note event (disabled when NATSURL is empty)
+	NATSURL       string `yaml:"nats_url" toml:"nats_url"`             // Comma-separated NATS server URLs
+	NATSSubject   string `yaml:"nats_subject" toml:"nats_subject"`     // Subject prefix; events go to "<subject>.<event type>"
+	NATSJetStream bool   `yaml:"nats_jetstream" toml:"nats_jetstream"` // Persist the events in a JetStream stream
+	NATSStream    string `yaml:"nats_stream" toml:"nats_stream"`       // Name of the JetStream stream
+	NATSEncoding  string `yaml:"nats_encoding" toml:"nats_encoding"`   // Encoding of the events: "json" or "avro"
+
+	// RabbitMQ exchange receiving every note event (disabled when AMQPURL is empty)
+	AMQPURL          string `yaml:"amqp_url" toml:"amqp_url"`                     // AMQP URL of the broker
+	AMQPExchange     string `yaml:"amqp_exchange" toml:"amqp_exchange"`           // Exchange of the events, declared as durable
+	AMQPExchangeType string `yaml:"amqp_exchange_type" toml:"amqp_exchange_type"` // "direct", "fanout", "topic", or "headers"
+	AMQPRoutingKey   string `yaml:"amqp_routing_key" toml:"amqp_routing_key"`     // Routing key of every event (empty for the event type)
+	AMQPEncoding     string `yaml:"amqp_encoding" toml:"amqp_encoding"`           // Encoding of the events: "json" or "avro"
+
+	// Rate limiting and CORS for the REST API; these settings, like LogLevel, are reloaded on SIGHUP
+	RateLimitRPS       float64 `yaml:"rate_limit_rps" toml:"rate_limit_rps"`             // Requests per second allowed per client IP on /api routes (zero disables rate limiting)
+	RateLimitBurst     int     `yaml:"rate_limit_burst" toml:"rate_limit_burst"`         // Number of requests a client may send at once before being limited
+	CORSAllowedOrigins string  `yaml:"cors_allowed_origins" toml:"cors_allowed_origins"` // Comma-separated origins allowed to call the API from browsers ("*" for any); CORS is disabled when empty
 }
 
 // NewConfig creates a new Config instance with values from environment variables
 func NewConfig() *Config {
+	config := defaultConfig()
+	config.applyEnv()
+	return config
+}
+
+// LoadConfig creates a new Config instance from a configuration file, overridden by
+// environment variables. The file format is chosen by its extension: ".yaml" or ".yml"
+// for YAML, ".toml" for TOML. Settings missing from the file keep their defaults.
+//
+// Parameters:
+//   - path: The path of the configuration file; if empty, only environment variables are used
+//
+// Returns:
+//   - A pointer to the loaded Config
+//   - An error if the file cannot be read, has an unsupported format, or contains invalid or unknown settings
+func LoadConfig(path string) (*Config, error) {
+	config := defaultConfig()
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	config.applyEnv()
+	return config, nil
+}
+
+// defaultConfig returns the configuration used when nothing else is specified.
+func defaultConfig() *Config {
+	retry := storage.DefaultRetryPolicy()
 	return &Config{
-		StorageType:       getEnv("STORAGE_TYPE", "memory"),
-		CouchDBURL:        getEnv("COUCHDB_URL", "http://localhost:5984"),
-		CouchDBName:       getEnv("COUCHDB_DB", "notes"),
-		MongoDBURI:        getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-		MongoDBName:       getEnv("MONGODB_DB", "notes"),
-		MongoDBCollection: getEnv("MONGODB_COLLECTION", "notes"),
+		StorageType:       "memory",
+		CouchDBURL:        "http://localhost:5984",
+		CouchDBName:       "notes",
+		MongoDBURI:        "mongodb://localhost:27017",
+		MongoDBName:       "notes",
+		MongoDBCollection: "notes",
 		RESTPort:          ":8080",
 		GRPCPort:          ":8081",
+		LogLevel:          logging.DefaultLevel,
+		BodyLogRate:       1,
+
+		MongoDBMaxPoolSize:    100,
+		MongoDBReadPreference: "primary",
+		MongoDBWriteConcern:   "majority",
+		MongoDBChangeStreams:  true,
+		CouchDBChangesFeed:    true,
+		CouchDBConflictPolicy: string(storage.ConflictLastWriteWins),
+
+		StorageRetryMaxAttempts:    retry.MaxAttempts,
+		StorageRetryInitialDelay:   retry.InitialDelay,
+		StorageRetryMaxDelay:       retry.MaxDelay,
+		StorageRetryAttemptTimeout: retry.AttemptTimeout,
+		StorageRetryDeadline:       retry.Deadline,
+
+		StorageReconnectInterval: 30 * time.Second,
+		StorageReconnectReplay:   true,
+
+		StorageCircuitFailureThreshold: 5,
+		StorageCircuitOpenTimeout:      30 * time.Second,
+
+		StorageCacheTTL: time.Minute,
+
+		StorageSlowThreshold: time.Second,
+		StorageOpTimeout:     10 * time.Second,
+
+		StorageMemoryEviction:   string(storage.EvictionReject),
+		StorageSnapshotInterval: time.Minute,
+
+		EncryptionLazyRotation: true,
+		DualWriteVerify:        true,
+
+		DualWriteMode:              "sync",
+		DualWriteReconcileInterval: 10 * time.Minute,
+
+		HTTPReadHeaderTimeout: 5 * time.Second,
+		HTTPReadTimeout:       15 * time.Second,
+		HTTPWriteTimeout:      30 * time.Second,
+		HTTPIdleTimeout:       60 * time.Second,
+		HTTPMaxHeaderBytes:    64 << 10,
+		HTTPCacheSize:         1000,
+		LoadShedRetryAfter:    time.Second,
+		QuotaOwnerHeader:      "X-API-Key",
+		ViewFlushInterval:     30 * time.Second,
+		JobWorkers:            4,
+		JobQueueSize:          1000,
+		ScheduleJitter:        30 * time.Second,
+		BackupSchedule:        "0 3 * * *",
+		BackupDir:             "backups",
+		BackupKeep:            7,
+		PurgeSchedule:         "30 3 * * *",
+		StatsSchedule:         "*/5 * * * *",
+		BlobURLExpiry:         15 * time.Minute,
+		GridFSBucket:          "blobs",
+		S3Region:              "us-east-1",
+		AttachmentMaxBytes:    10 << 20,
+		ThumbnailSizes:        "128,512",
+		SummarySentences:      3,
+		SummarizerTimeout:     30 * time.Second,
+		EmbedderDimensions:    256,
+		EmbedderTimeout:       30 * time.Second,
+		VectorIndex:           vector.KindMemory,
+		QdrantCollection:      "notes",
+		ShutdownDrainTimeout:  15 * time.Second,
+		StartupWaitInterval:   time.Second,
+
+		HTTP2MaxConcurrentStreams: 250,
+
+		WebhookMaxAttempts:       5,
+		WebhookRetryInitialDelay: time.Second,
+		WebhookRetryMaxDelay:     time.Minute,
+		WebhookTimeout:           10 * time.Second,
+
+		KafkaTopic:    "notes.events",
+		KafkaEncoding: broker.EncodingJSON,
+
+		NATSSubject:  "notes.events",
+		NATSStream:   "NOTES",
+		NATSEncoding: broker.EncodingJSON,
+
+		AMQPExchange:     "notes.events",
+		AMQPExchangeType: "topic",
+		AMQPEncoding:     broker.EncodingJSON,
+
+		RateLimitBurst: 20,
+
+		SecurityHeaders:        true,
+		SecurityFrameOptions:   "DENY",
+		SecurityReferrerPolicy: "no-referrer",
+		SecurityHSTSMaxAge:     180 * 24 * time.Hour,
 	}
 }
 
+// applyEnv overrides settings with the environment variables that are set.
+// Variables that are empty or cannot be parsed leave the current value unchanged.
+func (c *Config) applyEnv() {
+	c.StorageType = getEnv("STORAGE_TYPE", c.StorageType)
+	c.CouchDBURL = getEnv("COUCHDB_URL", c.CouchDBURL)
+	c.CouchDBName = getEnv("COUCHDB_DB", c.CouchDBName)
+	c.CouchDBUser = getEnv("COUCHDB_USER", c.CouchDBUser)
+	c.CouchDBPassword = getEnv("COUCHDB_PASSWORD", c.CouchDBPassword)
+	c.MongoDBURI = getEnv("MONGODB_URI", c.MongoDBURI)
+	c.MongoDBName = getEnv("MONGODB_DB", c.MongoDBName)
+	c.MongoDBCollection = getEnv("MONGODB_COLLECTION", c.MongoDBCollection)
+	c.MongoDBMaxPoolSize = getEnvInt("MONGODB_MAX_POOL_SIZE", c.MongoDBMaxPoolSize)
+	c.MongoDBMinPoolSize = getEnvInt("MONGODB_MIN_POOL_SIZE", c.MongoDBMinPoolSize)
+	c.MongoDBReadPreference = getEnv("MONGODB_READ_PREFERENCE", c.MongoDBReadPreference)
+	c.MongoDBWriteConcern = getEnv("MONGODB_WRITE_CONCERN", c.MongoDBWriteConcern)
+	c.MongoDBChangeStreams = getEnvBool("MONGODB_CHANGE_STREAMS", c.MongoDBChangeStreams)
+	c.CouchDBChangesFeed = getEnvBool("COUCHDB_CHANGES_FEED", c.CouchDBChangesFeed)
+	c.CouchDBConflictPolicy = getEnv("COUCHDB_CONFLICT_POLICY", c.CouchDBConflictPolicy)
+	c.LogLevel = getEnv("LOG_LEVEL", c.LogLevel)
+	c.BodyLogMaxBytes = getEnvInt("BODY_LOG_MAX_BYTES", c.BodyLogMaxBytes)
+	c.BodyLogRate = getEnvFloat("BODY_LOG_RATE", c.BodyLogRate)
+	c.BodyLogExcludedPaths = getEnv("BODY_LOG_EXCLUDED_PATHS", c.BodyLogExcludedPaths)
+
+	c.StorageStrict = getEnvBool("STORAGE_STRICT", c.StorageStrict)
+	c.StorageRetryMaxAttempts = getEnvInt("STORAGE_RETRY_MAX_ATTEMPTS", c.StorageRetryMaxAttempts)
+	c.StorageRetryInitialDelay = getEnvDuration("STORAGE_RETRY_INITIAL_DELAY", c.StorageRetryInitialDelay)
+	c.StorageRetryMaxDelay = getEnvDuration("STORAGE_RETRY_MAX_DELAY", c.StorageRetryMaxDelay)
+	c.StorageRetryAttemptTimeout = getEnvDuration("STORAGE_RETRY_ATTEMPT_TIMEOUT", c.StorageRetryAttemptTimeout)
+	c.StorageRetryDeadline = getEnvDuration("STORAGE_RETRY_DEADLINE", c.StorageRetryDeadline)
+	c.StorageReconnectInterval = getEnvDuration("STORAGE_RECONNECT_INTERVAL", c.StorageReconnectInterval)
+	c.StorageReconnectReplay = getEnvBool("STORAGE_RECONNECT_REPLAY", c.StorageReconnectReplay)
+	c.StorageCircuitFailureThreshold = getEnvInt("STORAGE_CIRCUIT_FAILURE_THRESHOLD", c.StorageCircuitFailureThreshold)
+	c.StorageCircuitOpenTimeout = getEnvDuration("STORAGE_CIRCUIT_OPEN_TIMEOUT", c.StorageCircuitOpenTimeout)
+	c.StorageCacheSize = getEnvInt("STORAGE_CACHE_SIZE", c.StorageCacheSize)
+	c.StorageCacheTTL = getEnvDuration("STORAGE_CACHE_TTL", c.StorageCacheTTL)
+	c.StorageCacheRedisURL = getEnv("STORAGE_CACHE_REDIS_URL", c.StorageCacheRedisURL)
+	c.StorageLogOperations = getEnvBool("STORAGE_LOG_OPERATIONS", c.StorageLogOperations)
+	c.StorageSlowThreshold = getEnvDuration("STORAGE_SLOW_THRESHOLD", c.StorageSlowThreshold)
+	c.StorageOpTimeout = getEnvDuration("STORAGE_OP_TIMEOUT", c.StorageOpTimeout)
+	c.StorageMemoryMaxNotes = getEnvInt("STORAGE_MEMORY_MAX_NOTES", c.StorageMemoryMaxNotes)
+	c.StorageMemoryMaxBytes = getEnvInt("STORAGE_MEMORY_MAX_BYTES", c.StorageMemoryMaxBytes)
+	c.StorageMemoryEviction = getEnv("STORAGE_MEMORY_EVICTION", c.StorageMemoryEviction)
+	c.StorageSnapshotPath = getEnv("STORAGE_SNAPSHOT_PATH", c.StorageSnapshotPath)
+	c.StorageSnapshotInterval = getEnvDuration("STORAGE_SNAPSHOT_INTERVAL", c.StorageSnapshotInterval)
+
+	c.EncryptionKeys = getEnv("ENCRYPTION_KEYS", c.EncryptionKeys)
+	c.EncryptionKeysFile = getEnv("ENCRYPTION_KEYS_FILE", c.EncryptionKeysFile)
+	c.EncryptionActiveKey = getEnv("ENCRYPTION_ACTIVE_KEY", c.EncryptionActiveKey)
+	c.EncryptionLazyRotation = getEnvBool("ENCRYPTION_LAZY_ROTATION", c.EncryptionLazyRotation)
+	c.EncryptionKMS = getEnv("ENCRYPTION_KMS", c.EncryptionKMS)
+	c.EncryptionKMSKey = getEnv("ENCRYPTION_KMS_KEY", c.EncryptionKMSKey)
+	c.EncryptionKMSEndpoint = getEnv("ENCRYPTION_KMS_ENDPOINT", c.EncryptionKMSEndpoint)
+
+	c.DualWriteTarget = getEnv("DUAL_WRITE_TARGET", c.DualWriteTarget)
+	c.DualWriteVerify = getEnvBool("DUAL_WRITE_VERIFY", c.DualWriteVerify)
+	c.DualWriteMode = getEnv("DUAL_WRITE_MODE", c.DualWriteMode)
+	c.DualWriteReconcileInterval = getEnvDuration("DUAL_WRITE_RECONCILE_INTERVAL", c.DualWriteReconcileInterval)
+
+	c.DebugAddr = getEnv("DEBUG_ADDR", c.DebugAddr)
+	c.DebugToken = getEnv("DEBUG_TOKEN", c.DebugToken)
+
+	c.HTTPReadHeaderTimeout = getEnvDuration("HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout)
+	c.HTTPReadTimeout = getEnvDuration("HTTP_READ_TIMEOUT", c.HTTPReadTimeout)
+	c.HTTPWriteTimeout = getEnvDuration("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout)
+	c.HTTPIdleTimeout = getEnvDuration("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+	c.HTTPMaxHeaderBytes = getEnvInt("HTTP_MAX_HEADER_BYTES", c.HTTPMaxHeaderBytes)
+	c.HTTPCacheTTL = getEnvDuration("HTTP_CACHE_TTL", c.HTTPCacheTTL)
+	c.HTTPCacheSize = getEnvInt("HTTP_CACHE_SIZE", c.HTTPCacheSize)
+	c.StartupWaitTimeout = getEnvDuration("STARTUP_WAIT_TIMEOUT", c.StartupWaitTimeout)
+	c.StartupWaitInterval = getEnvDuration("STARTUP_WAIT_INTERVAL", c.StartupWaitInterval)
+	c.ShutdownDrainTimeout = getEnvDuration("SHUTDOWN_DRAIN_TIMEOUT", c.ShutdownDrainTimeout)
+	c.MaxInFlightRequests = getEnvInt("MAX_INFLIGHT_REQUESTS", c.MaxInFlightRequests)
+	c.MaxInFlightReads = getEnvInt("MAX_INFLIGHT_READS", c.MaxInFlightReads)
+	c.MaxInFlightWrites = getEnvInt("MAX_INFLIGHT_WRITES", c.MaxInFlightWrites)
+	c.LoadShedRetryAfter = getEnvDuration("LOAD_SHED_RETRY_AFTER", c.LoadShedRetryAfter)
+	c.QuotaMaxNotes = getEnvInt("QUOTA_MAX_NOTES", c.QuotaMaxNotes)
+	c.QuotaMaxBytes = getEnvInt("QUOTA_MAX_BYTES", c.QuotaMaxBytes)
+	c.QuotaOwnerHeader = getEnv("QUOTA_OWNER_HEADER", c.QuotaOwnerHeader)
+	c.QuotaAPIKeys = getEnv("QUOTA_API_KEYS", c.QuotaAPIKeys)
+	c.ViewFlushInterval = getEnvDuration("VIEW_FLUSH_INTERVAL", c.ViewFlushInterval)
+	c.JobWorkers = getEnvInt("JOB_WORKERS", c.JobWorkers)
+	c.JobQueueSize = getEnvInt("JOB_QUEUE_SIZE", c.JobQueueSize)
+	c.ScheduleJitter = getEnvDuration("SCHEDULE_JITTER", c.ScheduleJitter)
+	c.BackupEnabled = getEnvBool("BACKUP_ENABLED", c.BackupEnabled)
+	c.BackupSchedule = getEnv("BACKUP_SCHEDULE", c.BackupSchedule)
+	c.BackupDir = getEnv("BACKUP_DIR", c.BackupDir)
+	c.BackupKeep = getEnvInt("BACKUP_KEEP", c.BackupKeep)
+	c.PurgeEnabled = getEnvBool("PURGE_ENABLED", c.PurgeEnabled)
+	c.PurgeSchedule = getEnv("PURGE_SCHEDULE", c.PurgeSchedule)
+	c.PurgeMaxAge = getEnvDuration("PURGE_MAX_AGE", c.PurgeMaxAge)
+	c.StatsEnabled = getEnvBool("STATS_ENABLED", c.StatsEnabled)
+	c.StatsSchedule = getEnv("STATS_SCHEDULE", c.StatsSchedule)
+	c.BlobStore = getEnv("BLOB_STORE", c.BlobStore)
+	c.BlobURLExpiry = getEnvDuration("BLOB_URL_EXPIRY", c.BlobURLExpiry)
+	c.BlobURLSecret = getEnv("BLOB_URL_SECRET", c.BlobURLSecret)
+	c.BlobBaseURL = getEnv("BLOB_BASE_URL", c.BlobBaseURL)
+	c.GridFSBucket = getEnv("GRIDFS_BUCKET", c.GridFSBucket)
+	c.S3Endpoint = getEnv("S3_ENDPOINT", c.S3Endpoint)
+	c.S3Region = getEnv("S3_REGION", c.S3Region)
+	c.S3Bucket = getEnv("S3_BUCKET", c.S3Bucket)
+	c.S3AccessKey = getEnv("S3_ACCESS_KEY", c.S3AccessKey)
+	c.S3SecretKey = getEnv("S3_SECRET_KEY", c.S3SecretKey)
+	c.S3PathStyle = getEnvBool("S3_PATH_STYLE", c.S3PathStyle)
+	c.AttachmentMaxBytes = getEnvInt("ATTACHMENT_MAX_BYTES", c.AttachmentMaxBytes)
+	c.ThumbnailSizes = getEnv("THUMBNAIL_SIZES", c.ThumbnailSizes)
+
+	c.Summarizer = getEnv("SUMMARIZER", c.Summarizer)
+	c.SummarySentences = getEnvInt("SUMMARY_SENTENCES", c.SummarySentences)
+	c.SummarizerLLMURL = getEnv("SUMMARIZER_LLM_URL", c.SummarizerLLMURL)
+	c.SummarizerLLMAPIKey = getEnv("SUMMARIZER_LLM_API_KEY", c.SummarizerLLMAPIKey)
+	c.SummarizerLLMModel = getEnv("SUMMARIZER_LLM_MODEL", c.SummarizerLLMModel)
+	c.SummarizerTimeout = getEnvDuration("SUMMARIZER_TIMEOUT", c.SummarizerTimeout)
+
+	c.Embedder = getEnv("EMBEDDER", c.Embedder)
+	c.EmbedderDimensions = getEnvInt("EMBEDDER_DIMENSIONS", c.EmbedderDimensions)
+	c.EmbedderURL = getEnv("EMBEDDER_URL", c.EmbedderURL)
+	c.EmbedderAPIKey = getEnv("EMBEDDER_API_KEY", c.EmbedderAPIKey)
+	c.EmbedderModel = getEnv("EMBEDDER_MODEL", c.EmbedderModel)
+	c.EmbedderTimeout = getEnvDuration("EMBEDDER_TIMEOUT", c.EmbedderTimeout)
+	c.VectorIndex = getEnv("VECTOR_INDEX", c.VectorIndex)
+	c.QdrantURL = getEnv("QDRANT_URL", c.QdrantURL)
+	c.QdrantCollection = getEnv("QDRANT_COLLECTION", c.QdrantCollection)
+	c.QdrantAPIKey = getEnv("QDRANT_API_KEY", c.QdrantAPIKey)
+
+	c.RESTH2C = getEnvBool("REST_H2C", c.RESTH2C)
+	c.HTTP2MaxConcurrentStreams = getEnvInt("HTTP2_MAX_CONCURRENT_STREAMS", c.HTTP2MaxConcurrentStreams)
+	c.HTTP2PingInterval = getEnvDuration("HTTP2_PING_INTERVAL", c.HTTP2PingInterval)
+
+	c.RESTTLSCert = getEnv("REST_TLS_CERT", c.RESTTLSCert)
+	c.RESTTLSKey = getEnv("REST_TLS_KEY", c.RESTTLSKey)
+	c.RESTTLSClientCA = getEnv("REST_TLS_CLIENT_CA", c.RESTTLSClientCA)
+	c.RESTRedirectAddr = getEnv("REST_HTTP_REDIRECT_ADDR", c.RESTRedirectAddr)
+
+	c.WebhookURLs = getEnv("WEBHOOK_URLS", c.WebhookURLs)
+	c.WebhookSecret = getEnv("WEBHOOK_SECRET", c.WebhookSecret)
+	c.WebhookMaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts)
+	c.WebhookRetryInitialDelay = getEnvDuration("WEBHOOK_RETRY_INITIAL_DELAY", c.WebhookRetryInitialDelay)
+	c.WebhookRetryMaxDelay = getEnvDuration("WEBHOOK_RETRY_MAX_DELAY", c.WebhookRetryMaxDelay)
+	c.WebhookTimeout = getEnvDuration("WEBHOOK_TIMEOUT", c.WebhookTimeout)
+	c.WebhookAllowPrivate = getEnvBool("WEBHOOK_ALLOW_PRIVATE", c.WebhookAllowPrivate)
+	c.AdminToken = getEnv("ADMIN_TOKEN", c.AdminToken)
+	c.UIEnabled = getEnvBool("UI_ENABLED", c.UIEnabled)
+	c.RESTPutCreates = getEnvBool("REST_PUT_CREATES", c.RESTPutCreates)
+	c.SecurityHeaders = getEnvBool("SECURITY_HEADERS", c.SecurityHeaders)
+	c.SecurityFrameOptions = getEnv("SECURITY_FRAME_OPTIONS", c.SecurityFrameOptions)
+	c.SecurityReferrerPolicy = getEnv("SECURITY_REFERRER_POLICY", c.SecurityReferrerPolicy)
+	c.SecurityContentSecurityPolicy = getEnv("SECURITY_CONTENT_SECURITY_POLICY", c.SecurityContentSecurityPolicy)
+	c.SecurityHSTSMaxAge = getEnvDuration("SECURITY_HSTS_MAX_AGE", c.SecurityHSTSMaxAge)
+
+	c.KafkaBrokers = getEnv("KAFKA_BROKERS", c.KafkaBrokers)
+	c.KafkaTopic = getEnv("KAFKA_TOPIC", c.KafkaTopic)
+	c.KafkaEncoding = getEnv("KAFKA_ENCODING", c.KafkaEncoding)
+
+	c.NATSURL = getEnv("NATS_URL", c.NATSURL)
+	c.NATSSubject = getEnv("NATS_SUBJECT", c.NATSSubject)
+	c.NATSJetStream = getEnvBool("NATS_JETSTREAM", c.NATSJetStream)
+	c.NATSStream = getEnv("NATS_STREAM", c.NATSStream)
+	c.NATSEncoding = getEnv("NATS_ENCODING", c.NATSEncoding)
+
+	c.AMQPURL = getEnv("AMQP_URL", c.AMQPURL)
+	c.AMQPExchange = getEnv("AMQP_EXCHANGE", c.AMQPExchange)
+	c.AMQPExchangeType = getEnv("AMQP_EXCHANGE_TYPE", c.AMQPExchangeType)
+	c.AMQPRoutingKey = getEnv("AMQP_ROUTING_KEY", c.AMQPRoutingKey)
+	c.AMQPEncoding = getEnv("AMQP_ENCODING", c.AMQPEncoding)
+
+	c.RateLimitRPS = getEnvFloat("RATE_LIMIT_RPS", c.RateLimitRPS)
+	c.RateLimitBurst = getEnvInt("RATE_LIMIT_BURST", c.RateLimitBurst)
+	c.CORSAllowedOrigins = getEnv("CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins)
+}
+
+// loadFile overrides settings with the values from a YAML or TOML configuration file.
+// Unknown keys are rejected, so typos don't silently leave a setting at its default.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown setting %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q (use .yaml, .yml, or .toml)", ext)
+	}
+	return nil
+}
+
+// storageTypes lists the supported values of StorageType and DualWriteTarget.
+var storageTypes = []string{"memory", "couchdb", "mongodb"}
+
+// amqpExchangeTypes lists the supported values of AMQPExchangeType.
+var amqpExchangeTypes = []string{"direct", "fanout", "topic", "headers"}
+
+// referrerPolicies lists the supported values of SecurityReferrerPolicy.
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+	"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// secretSettings lists the settings whose values are never logged.
+var secretSettings = map[string]bool{
+	"couchdb_password":       true,
+	"encryption_keys":        true,
+	"debug_token":            true,
+	"webhook_secret":         true,
+	"admin_token":            true,
+	"quota_api_keys":         true,
+	"blob_url_secret":        true,
+	"s3_secret_key":          true,
+	"summarizer_llm_api_key": true,
+	"embedder_api_key":       true,
+	"qdrant_api_key":         true,
+}
+
+// Validate checks the configuration for malformed or contradictory settings,
+// so that mistakes are reported at startup instead of surfacing later (or never).
+//
+// Returns:
+//   - nil if the configuration is valid
+//   - An error describing every invalid setting otherwise
+func (c *Config) Validate() error {
+	var errs []error
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if !isStorageType(c.StorageType) {
+		addErr("storage_type: unknown storage type %q (use %s)", c.StorageType, strings.Join(storageTypes, ", "))
+	}
+	if c.DualWriteTarget != "" {
+		if !isStorageType(c.DualWriteTarget) {
+			addErr("dual_write_target: unknown storage type %q (use %s)", c.DualWriteTarget, strings.Join(storageTypes, ", "))
+		} else if c.DualWriteTarget == c.StorageType && c.DualWriteTarget != "memory" {
+			addErr("dual_write_target: must differ from storage_type %q", c.StorageType)
+		}
+	}
+	if c.DualWriteMode != "sync" && c.DualWriteMode != "async" {
+		addErr("dual_write_mode: must be \"sync\" or \"async\", got %q", c.DualWriteMode)
+	}
+	if c.DualWriteReconcileInterval < 0 {
+		addErr("dual_write_reconcile_interval: must not be negative")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		addErr("log_level: %v", err)
+	}
+	if c.BodyLogMaxBytes < 0 {
+		addErr("body_log_max_bytes: must not be negative")
+	}
+	if c.BodyLogMaxBytes > 0 && !(c.BodyLogRate > 0) {
+		addErr("body_log_rate: must be positive when body logging is enabled")
+	}
+	for _, path := range c.bodyLogExcludedPaths() {
+		if !strings.HasPrefix(path, "/") {
+			addErr("body_log_excluded_paths: path %q must start with /", path)
+		}
+	}
+
+	// Backend connection settings are checked only for the backends in use
+	if c.usesStorage("couchdb") {
+		if err := validateURL(c.CouchDBURL, "http", "https"); err != nil {
+			addErr("couchdb_url: %v", err)
+		}
+		if c.CouchDBName == "" {
+			addErr("couchdb_db: must not be empty")
+		}
+		if c.CouchDBPassword != "" && c.CouchDBUser == "" {
+			addErr("couchdb_password: requires couchdb_user")
+		}
+		if c.CouchDBConflictPolicy != "" {
+			if err := storage.ConflictPolicy(c.CouchDBConflictPolicy).Validate(); err != nil {
+				addErr("couchdb_conflict_policy: %v", err)
+			}
+		}
+	}
+	if c.usesStorage("mongodb") {
+		if err := validateURL(c.MongoDBURI, "mongodb", "mongodb+srv"); err != nil {
+			addErr("mongodb_uri: %v", err)
+		}
+		if c.MongoDBName == "" || c.MongoDBCollection == "" {
+			addErr("mongodb_db and mongodb_collection: must not be empty")
+		}
+		if c.MongoDBMaxPoolSize < 0 || c.MongoDBMinPoolSize < 0 {
+			addErr("mongodb_max_pool_size and mongodb_min_pool_size: must not be negative")
+		} else if c.MongoDBMaxPoolSize > 0 && c.MongoDBMinPoolSize > c.MongoDBMaxPoolSize {
+			addErr("mongodb_min_pool_size: must not exceed mongodb_max_pool_size")
+		}
+		if err := (storage.MongoDBOptions{ReadPreference: c.MongoDBReadPreference}).Validate(); err != nil {
+			addErr("mongodb_read_preference: %v", err)
+		}
+		if err := (storage.MongoDBOptions{WriteConcern: c.MongoDBWriteConcern}).Validate(); err != nil {
+			addErr("mongodb_write_concern: %v", err)
+		}
+	}
+
+	// Retries; file values are not range-checked when they are decoded
+	if c.StorageRetryMaxAttempts < 1 {
+		addErr("storage_retry_max_attempts: must be at least 1")
+	}
+	for name, d := range map[string]time.Duration{
+		"storage_retry_initial_delay":   c.StorageRetryInitialDelay,
+		"storage_retry_max_delay":       c.StorageRetryMaxDelay,
+		"storage_retry_attempt_timeout": c.StorageRetryAttemptTimeout,
+		"storage_retry_deadline":        c.StorageRetryDeadline,
+		"storage_reconnect_interval":    c.StorageReconnectInterval,
+	} {
+		if d < 0 {
+			addErr("%s: must not be negative", name)
+		}
+	}
+
+	// Circuit breaker
+	if c.StorageCircuitFailureThreshold < 0 {
+		addErr("storage_circuit_failure_threshold: must not be negative")
+	}
+	if c.StorageCircuitFailureThreshold > 0 && c.StorageCircuitOpenTimeout <= 0 {
+		addErr("storage_circuit_open_timeout: must be positive when the circuit breaker is enabled")
+	}
+
+	// Cache
+	if c.StorageCacheSize < 0 {
+		addErr("storage_cache_size: must not be negative")
+	}
+	if (c.StorageCacheSize > 0 || c.StorageCacheRedisURL != "") && c.StorageCacheTTL <= 0 {
+		addErr("storage_cache_ttl: must be positive when the cache is enabled")
+	}
+	if c.HTTPCacheTTL < 0 {
+		addErr("http_cache_ttl: must not be negative")
+	}
+	if c.HTTPCacheTTL > 0 && c.HTTPCacheSize <= 0 {
+		addErr("http_cache_size: must be positive when the response cache is enabled")
+	}
+	if c.StorageCacheRedisURL != "" {
+		if err := validateURL(c.StorageCacheRedisURL, "redis", "rediss"); err != nil {
+			addErr("storage_cache_redis_url: %v", err)
+		}
+	}
+
+	// Instrumentation
+	if c.StorageSlowThreshold < 0 {
+		addErr("storage_slow_threshold: must not be negative")
+	}
+	if c.StorageOpTimeout < 0 {
+		addErr("storage_op_timeout: must not be negative")
+	}
+
+	// Bounds of in-memory storage
+	if c.StorageMemoryMaxNotes < 0 || c.StorageMemoryMaxBytes < 0 {
+		addErr("storage_memory_max_notes and storage_memory_max_bytes: must not be negative")
+	}
+	if err := storage.EvictionPolicy(c.StorageMemoryEviction).Validate(); err != nil {
+		addErr("storage_memory_eviction: %v", err)
+	}
+
+	// Snapshots; both in-memory storages of a memory-to-memory dual write would use the same file
+	if c.StorageSnapshotPath != "" {
+		if c.StorageType != "memory" {
+			addErr("storage_snapshot_path: requires storage_type \"memory\"")
+		} else if c.DualWriteTarget == "memory" {
+			addErr("storage_snapshot_path: cannot be used with dual_write_target \"memory\"")
+		}
+	}
+	if c.StorageSnapshotInterval < 0 {
+		addErr("storage_snapshot_interval: must not be negative")
+	}
+
+	// Listen addresses must be valid and distinct
+	addrs := map[string]string{"rest_port": c.RESTPort, "grpc_port": c.GRPCPort}
+	if c.DebugAddr != "" {
+		addrs["debug_addr"] = c.DebugAddr
+	}
+	if c.RESTRedirectAddr != "" {
+		addrs["rest_http_redirect_addr"] = c.RESTRedirectAddr
+	}
+	ports := make(map[string]string, len(addrs))
+	for _, name := range sortedKeys(addrs) {
+		port, err := validateListenAddr(addrs[name])
+		if err != nil {
+			addErr("%s: %v", name, err)
+			continue
+		}
+		if other, ok := ports[port]; ok && port != "0" {
+			addErr("%s: port %s is already used by %s", name, port, other)
+			continue
+		}
+		ports[port] = name
+	}
+
+	// HTTPS requires both the certificate and its key
+	if (c.RESTTLSCert == "") != (c.RESTTLSKey == "") {
+		addErr("rest_tls_cert and rest_tls_key: both must be set to enable TLS")
+	}
+	if c.RESTTLSCert == "" && c.RESTTLSClientCA != "" {
+		addErr("rest_tls_client_ca: client certificate verification requires rest_tls_cert and rest_tls_key")
+	}
+	if c.RESTTLSCert == "" && c.RESTRedirectAddr != "" {
+		addErr("rest_http_redirect_addr: redirecting to HTTPS requires rest_tls_cert and rest_tls_key")
+	}
+
+	// Encryption keys are read and parsed now rather than when the storage is initialized
+	switch {
+	case c.EncryptionKeys != "" && c.EncryptionKeysFile != "":
+		addErr("encryption_keys_file: set together with encryption_keys; use only one")
+	case c.EncryptionKMS != "" && c.EncryptionActiveKey != "":
+		addErr("encryption_active_key: not used with encryption_kms, which encrypts all new data")
+	case c.encryptionEnabled():
+		if _, err := c.keyring(); err != nil {
+			addErr("%v", err)
+		}
+	case c.EncryptionActiveKey != "":
+		addErr("encryption_active_key: set without encryption_keys")
+	}
+
+	// Webhooks; configured URLs are signed with the shared secret
+	for _, u := range c.webhookURLs() {
+		if err := validateURL(u, "http", "https"); err != nil {
+			addErr("webhook_urls: %v", err)
+		}
+	}
+	if len(c.webhookURLs()) > 0 && c.WebhookSecret == "" {
+		addErr("webhook_secret: required to sign the payloads of webhook_urls")
+	}
+	if c.WebhookMaxAttempts < 1 {
+		addErr("webhook_max_attempts: must be at least 1")
+	}
+	for name, d := range map[string]time.Duration{
+		"webhook_retry_initial_delay": c.WebhookRetryInitialDelay,
+		"webhook_retry_max_delay":     c.WebhookRetryMaxDelay,
+		"webhook_timeout":             c.WebhookTimeout,
+	} {
+		if d < 0 {
+			addErr("%s: must not be negative", name)
+		}
+	}
+
+	// Kafka event publishing
+	for _, addr := range c.kafkaBrokers() {
+		if host, _, err := net.SplitHostPort(addr); err != nil || host == "" {
+			addErr("kafka_brokers: invalid broker address %q (use \"host:port\")", addr)
+		}
+	}
+	if len(c.kafkaBrokers()) > 0 && c.KafkaTopic == "" {
+		addErr("kafka_topic: required when kafka_brokers is set")
+	}
+	if c.KafkaEncoding != broker.EncodingJSON && c.KafkaEncoding != broker.EncodingAvro {
+		addErr("kafka_encoding: must be %q or %q", broker.EncodingJSON, broker.EncodingAvro)
+	}
+
+	// NATS event publishing
+	if c.NATSURL != "" {
+		for _, u := range strings.Split(c.NATSURL, ",") {
+			if err := validateURL(strings.TrimSpace(u), "nats", "tls", "ws", "wss"); err != nil {
+				addErr("nats_url: %v", err)
+			}
+		}
+		if c.NATSSubject == "" || strings.ContainsAny(c.NATSSubject, "*> \t") {
+			addErr("nats_subject: must be a subject without wildcards or whitespace, got %q", c.NATSSubject)
+		}
+		if c.NATSJetStream && (c.NATSStream == "" || strings.ContainsAny(c.NATSStream, ".*> \t/\\")) {
+			addErr("nats_stream: must be a stream name without whitespace, '.', '*', '>', or slashes, got %q", c.NATSStream)
+		}
+	}
+	if c.NATSEncoding != broker.EncodingJSON && c.NATSEncoding != broker.EncodingAvro {
+		addErr("nats_encoding: must be %q or %q", broker.EncodingJSON, broker.EncodingAvro)
+	}
+
+	// RabbitMQ event publishing
+	if c.AMQPURL != "" {
+		if err := validateURL(c.AMQPURL, "amqp", "amqps"); err != nil {
+			addErr("amqp_url: %v", err)
+		}
+		if c.AMQPExchange == "" {
+			addErr("amqp_exchange: required when amqp_url is set")
+		}
+		if !slices.Contains(amqpExchangeTypes, c.AMQPExchangeType) {
+			addErr("amqp_exchange_type: unknown exchange type %q (use %s)", c.AMQPExchangeType, strings.Join(amqpExchangeTypes, ", "))
+		}
+	}
+	if c.AMQPEncoding != broker.EncodingJSON && c.AMQPEncoding != broker.EncodingAvro {
+		addErr("amqp_encoding: must be %q or %q", broker.EncodingJSON, broker.EncodingAvro)
+	}
+
+	// Rate limiting and CORS; file values are not range-checked when they are decoded
+	if c.RateLimitRPS < 0 {
+		addErr("rate_limit_rps: must not be negative")
+	}
+	if c.RateLimitRPS > 0 && c.RateLimitBurst < 1 {
+		addErr("rate_limit_burst: must be at least 1 when rate limiting is enabled")
+	}
+	for _, origin := range c.corsOrigins() {
+		if err := validateOrigin(origin); err != nil {
+			addErr("cors_allowed_origins: %v", err)
+		}
+	}
+
+	if c.StartupWaitTimeout < 0 {
+		addErr("startup_wait_timeout: must not be negative")
+	}
+	if c.StartupWaitTimeout > 0 && c.StartupWaitInterval <= 0 {
+		addErr("startup_wait_interval: must be positive when the startup wait is enabled")
+	}
+	if c.ShutdownDrainTimeout <= 0 {
+		addErr("shutdown_drain_timeout: must be positive")
+	}
+
+	// Load shedding
+	if c.MaxInFlightRequests < 0 || c.MaxInFlightReads < 0 || c.MaxInFlightWrites < 0 {
+		addErr("max_inflight_requests, max_inflight_reads, max_inflight_writes: must not be negative")
+	}
+	if c.loadShedding() && c.LoadShedRetryAfter <= 0 {
+		addErr("load_shed_retry_after: must be positive when load shedding is enabled")
+	}
+
+	// Quotas
+	if c.QuotaMaxNotes < 0 || c.QuotaMaxBytes < 0 {
+		addErr("quota_max_notes, quota_max_bytes: must not be negative")
+	}
+	if c.quotasEnabled() && strings.TrimSpace(c.QuotaOwnerHeader) == "" {
+		addErr("quota_owner_header: is required when quotas are enabled")
+	}
+
+	// Views
+	if c.ViewFlushInterval < 0 {
+		addErr("view_flush_interval: must not be negative")
+	}
+
+	// Background jobs
+	if c.JobWorkers <= 0 {
+		addErr("job_workers: must be positive")
+	}
+	if c.JobQueueSize <= 0 {
+		addErr("job_queue_size: must be positive")
+	}
+
+	// Scheduled tasks
+	if c.ScheduleJitter < 0 {
+		addErr("schedule_jitter: must not be negative")
+	}
+	for _, task := range []struct {
+		name     string
+		enabled  bool
+		schedule string
+	}{
+		{"backup_schedule", c.BackupEnabled, c.BackupSchedule},
+		{"purge_schedule", c.PurgeEnabled, c.PurgeSchedule},
+		{"stats_schedule", c.StatsEnabled, c.StatsSchedule},
+	} {
+		if _, err := scheduler.Parse(task.schedule); task.enabled && err != nil {
+			addErr("%s: %v", task.name, err)
+		}
+	}
+	if c.BackupEnabled && strings.TrimSpace(c.BackupDir) == "" {
+		addErr("backup_dir: is required when backups are enabled")
+	}
+	if c.BackupKeep < 0 {
+		addErr("backup_keep: must not be negative")
+	}
+	if c.PurgeEnabled && c.PurgeMaxAge <= 0 {
+		addErr("purge_max_age: must be positive when the purge is enabled")
+	}
+
+	// Blob store
+	switch c.BlobStore {
+	case "":
+	case "s3":
+		if _, err := blob.NewS3Store(c.s3Config()); err != nil {
+			addErr("s3: %v", err)
+		}
+	case "gridfs":
+		if strings.TrimSpace(c.GridFSBucket) == "" {
+			addErr("gridfs_bucket: is required by the gridfs blob store")
+		}
+		if c.BlobBaseURL != "" {
+			if u, err := url.Parse(c.BlobBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				addErr("blob_base_url: must be an http(s) URL")
+			}
+		}
+	default:
+		addErr("blob_store: unknown type %q (use s3 or gridfs, or leave empty to disable)", c.BlobStore)
+	}
+	if c.BlobURLExpiry < time.Second || c.BlobURLExpiry > 7*24*time.Hour {
+		addErr("blob_url_expiry: must be between 1s and 168h")
+	}
+	if c.AttachmentMaxBytes < 0 {
+		addErr("attachment_max_bytes: must not be negative")
+	}
+	if _, err := c.thumbnailSizes(); err != nil {
+		addErr("thumbnail_sizes: %v", err)
+	}
+
+	// Summaries
+	if c.Summarizer != "" && !summarizer.IsKind(c.Summarizer) {
+		addErr("summarizer: unknown summarizer %q (use %s or %s, or leave empty to disable)", c.Summarizer, summarizer.KindExtractive, summarizer.KindLLM)
+	}
+	if c.SummarySentences < 1 || c.SummarySentences > 20 {
+		addErr("summary_sentences: must be between 1 and 20")
+	}
+	if c.Summarizer == summarizer.KindLLM {
+		if err := validateURL(c.SummarizerLLMURL, "http", "https"); err != nil {
+			addErr("summarizer_llm_url: %v", err)
+		}
+		if c.SummarizerLLMModel == "" {
+			addErr("summarizer_llm_model: required by the llm summarizer")
+		}
+	}
+	if c.SummarizerTimeout <= 0 {
+		addErr("summarizer_timeout: must be positive")
+	}
+
+	// Semantic search
+	if c.Embedder != "" && !embedding.IsKind(c.Embedder) {
+		addErr("embedder: unknown embedder %q (use %s or %s, or leave empty to disable)", c.Embedder, embedding.KindHashing, embedding.KindOpenAI)
+	}
+	if c.EmbedderDimensions < 16 || c.EmbedderDimensions > 4096 {
+		addErr("embedder_dimensions: must be between 16 and 4096")
+	}
+	if c.Embedder == embedding.KindOpenAI {
+		if err := validateURL(c.EmbedderURL, "http", "https"); err != nil {
+			addErr("embedder_url: %v", err)
+		}
+		if c.EmbedderModel == "" {
+			addErr("embedder_model: required by the openai embedder")
+		}
+	}
+	if c.EmbedderTimeout <= 0 {
+		addErr("embedder_timeout: must be positive")
+	}
+	if !vector.IsKind(c.VectorIndex) {
+		addErr("vector_index: unknown vector index %q (use %s or %s)", c.VectorIndex, vector.KindMemory, vector.KindQdrant)
+	}
+	if c.Embedder != "" && c.VectorIndex == vector.KindQdrant {
+		if err := validateURL(c.QdrantURL, "http", "https"); err != nil {
+			addErr("qdrant_url: %v", err)
+		}
+		if c.QdrantCollection == "" {
+			addErr("qdrant_collection: required by the qdrant vector index")
+		}
+	}
+
+	// Security headers
+	if c.SecurityFrameOptions != "" && c.SecurityFrameOptions != "DENY" && c.SecurityFrameOptions != "SAMEORIGIN" {
+		addErr("security_frame_options: must be \"DENY\" or \"SAMEORIGIN\"")
+	}
+	if c.SecurityReferrerPolicy != "" && !slices.Contains(referrerPolicies, c.SecurityReferrerPolicy) {
+		addErr("security_referrer_policy: unknown policy %q (use %s)", c.SecurityReferrerPolicy, strings.Join(referrerPolicies, ", "))
+	}
+	if strings.ContainsAny(c.SecurityContentSecurityPolicy, "\r\n") {
+		addErr("security_content_security_policy: must be a single line")
+	}
+	if c.SecurityHSTSMaxAge < 0 {
+		addErr("security_hsts_max_age: must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadShedding reports whether any concurrency limit is set.
+func (c *Config) loadShedding() bool {
+	return c.MaxInFlightRequests > 0 || c.MaxInFlightReads > 0 || c.MaxInFlightWrites > 0
+}
+
+// quotasEnabled reports whether any quota limit is set.
+func (c *Config) quotasEnabled() bool {
+	return c.QuotaMaxNotes > 0 || c.QuotaMaxBytes > 0
+}
+
+// quotaAPIKeys returns the API keys accepted in the quota owner header.
+func (c *Config) quotaAPIKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(c.QuotaAPIKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// s3Config returns the configuration of the S3 blob store.
+func (c *Config) s3Config() blob.S3Config {
+	return blob.S3Config{
+		Endpoint:  c.S3Endpoint,
+		Region:    c.S3Region,
+		Bucket:    c.S3Bucket,
+		AccessKey: c.S3AccessKey,
+		SecretKey: c.S3SecretKey,
+		PathStyle: c.S3PathStyle,
+	}
+}
+
+// quotaLimits returns the limits of every owner of notes.
+func (c *Config) quotaLimits() service.QuotaLimits {
+	return service.QuotaLimits{MaxNotes: c.QuotaMaxNotes, MaxBytes: int64(c.QuotaMaxBytes)}
+}
+
+// bodyLogExcludedPaths returns the path prefixes whose bodies are never logged.
+func (c *Config) bodyLogExcludedPaths() []string {
+	var paths []string
+	for _, path := range strings.Split(c.BodyLogExcludedPaths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// corsOrigins returns the list of origins allowed to make cross-origin requests.
+func (c *Config) corsOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.CORSAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// validateOrigin checks that origin is "*" or a browser origin ("scheme://host[:port]").
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("invalid origin %q (use \"*\" or \"scheme://host[:port]\")", origin)
+	}
+	return nil
+}
+
+// encryptionEnabled reports whether encryption at rest is configured.
+func (c *Config) encryptionEnabled() bool {
+	return c.EncryptionKeys != "" || c.EncryptionKeysFile != "" || c.EncryptionKMS != ""
+}
+
+// keyring creates the keyring of encryption at rest: an envelope keyring if a KMS is
+// configured, or else a keyring of the local keys. No KMS request is made.
+// Errors name the setting at fault.
+func (c *Config) keyring() (*storage.Keyring, error) {
+	spec, err := c.encryptionKeys()
+	if err != nil {
+		return nil, fmt.Errorf("encryption_keys_file: %w", err)
+	}
+	keys, err := storage.ParseKeys(spec)
+	if err != nil {
+		return nil, fmt.Errorf("encryption_keys: %w", err)
+	}
+
+	if c.EncryptionKMS == "" {
+		keyring, err := storage.NewKeyring(c.EncryptionActiveKey, keys)
+		if err != nil {
+			return nil, fmt.Errorf("encryption_keys: %w", err)
+		}
+		return keyring, nil
+	}
+
+	provider, err := kms.New(c.EncryptionKMS, c.EncryptionKMSKey, c.EncryptionKMSEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("encryption_kms: %w", err)
+	}
+	keyring, err := storage.NewEnvelopeKeyring(provider, keys)
+	if err != nil {
+		return nil, fmt.Errorf("encryption_keys: %w", err)
+	}
+	return keyring, nil
+}
+
+// encryptionKeys returns the encryption key specification, read from the keys file
+// if one is configured.
+func (c *Config) encryptionKeys() (string, error) {
+	if c.EncryptionKeysFile == "" {
+		return c.EncryptionKeys, nil
+	}
+	data, err := os.ReadFile(c.EncryptionKeysFile)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// webhookURLs returns the URLs of the configured webhooks.
+func (c *Config) webhookURLs() []string {
+	var urls []string
+	for _, u := range strings.Split(c.WebhookURLs, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// kafkaBrokers returns the addresses of the Kafka bootstrap brokers.
+func (c *Config) kafkaBrokers() []string {
+	var brokers []string
+	for _, addr := range strings.Split(c.KafkaBrokers, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			brokers = append(brokers, addr)
+		}
+	}
+	return brokers
+}
+
+// thumbnailSizes returns the sizes of the thumbnails of image attachments, in pixels.
+func (c *Config) thumbnailSizes() ([]int, error) {
+	var sizes []int
+	for _, value := range strings.Split(c.ThumbnailSizes, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 16 || size > 4096 {
+			return nil, fmt.Errorf("invalid size %q (must be between 16 and 4096 pixels)", value)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// newSummarizer creates the summarizer of the notes, or returns nil if summaries are
+// disabled. No request is made to an LLM API.
+func (c *Config) newSummarizer() service.Summarizer {
+	switch c.Summarizer {
+	case summarizer.KindExtractive:
+		return summarizer.NewExtractive(c.SummarySentences)
+	case summarizer.KindLLM:
+		return summarizer.NewLLM(c.SummarizerLLMURL, c.SummarizerLLMAPIKey, c.SummarizerLLMModel, c.SummarySentences, c.SummarizerTimeout)
+	default:
+		return nil
+	}
+}
+
+// newEmbedder creates the embedder of semantic search, or returns nil if semantic search
+// is disabled. No request is made to an embeddings API.
+func (c *Config) newEmbedder() service.Embedder {
+	switch c.Embedder {
+	case embedding.KindHashing:
+		return embedding.NewHashing(c.EmbedderDimensions)
+	case embedding.KindOpenAI:
+		return embedding.NewOpenAI(c.EmbedderURL, c.EmbedderAPIKey, c.EmbedderModel, c.EmbedderTimeout)
+	default:
+		return nil
+	}
+}
+
+// newVectorIndex creates the vector index of semantic search. No request is made to Qdrant.
+func (c *Config) newVectorIndex() service.VectorIndex {
+	if c.VectorIndex == vector.KindQdrant {
+		return vector.NewQdrant(c.QdrantURL, c.QdrantCollection, c.QdrantAPIKey)
+	}
+	return vector.NewMemoryIndex()
+}
+
+// webhookRetryPolicy returns the policy for retrying webhook deliveries.
+func (c *Config) webhookRetryPolicy() storage.RetryPolicy {
+	return storage.RetryPolicy{
+		MaxAttempts:    c.WebhookMaxAttempts,
+		InitialDelay:   c.WebhookRetryInitialDelay,
+		MaxDelay:       c.WebhookRetryMaxDelay,
+		AttemptTimeout: c.WebhookTimeout,
+	}
+}
+
+// retryPolicy returns the policy for retrying connections to the storage backends.
+func (c *Config) retryPolicy() storage.RetryPolicy {
+	return storage.RetryPolicy{
+		MaxAttempts:    c.StorageRetryMaxAttempts,
+		InitialDelay:   c.StorageRetryInitialDelay,
+		MaxDelay:       c.StorageRetryMaxDelay,
+		AttemptTimeout: c.StorageRetryAttemptTimeout,
+		Deadline:       c.StorageRetryDeadline,
+	}
+}
+
+// mongoDBOptions returns the MongoDB client settings.
+func (c *Config) mongoDBOptions() storage.MongoDBOptions {
+	return storage.MongoDBOptions{
+		MaxPoolSize:    uint64(max(c.MongoDBMaxPoolSize, 0)),
+		MinPoolSize:    uint64(max(c.MongoDBMinPoolSize, 0)),
+		ReadPreference: c.MongoDBReadPreference,
+		WriteConcern:   c.MongoDBWriteConcern,
+	}
+}
+
+// memoryLimits returns the bounds of in-memory note storage.
+func (c *Config) memoryLimits() storage.InMemoryLimits {
+	return storage.InMemoryLimits{
+		MaxNotes: c.StorageMemoryMaxNotes,
+		MaxBytes: c.StorageMemoryMaxBytes,
+		Eviction: storage.EvictionPolicy(c.StorageMemoryEviction),
+	}
+}
+
+// usesStorage reports whether the given storage type is the primary backend or the dual-write target.
+func (c *Config) usesStorage(storageType string) bool {
+	return c.StorageType == storageType || c.DualWriteTarget == storageType
+}
+
+// LogSettings logs every effective setting, one per line, with secrets redacted.
+// Settings are named by their configuration file keys.
+func (c *Config) LogSettings() {
+	log.Println("Effective configuration:")
+	for _, setting := range c.settings() {
+		log.Printf("  %s = %s", setting[0], setting[1])
+	}
+}
+
+// settings returns the name and printable value of every setting, with secrets
+// and URL passwords redacted, in declaration order.
+func (c *Config) settings() [][2]string {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	settings := make([][2]string, 0, t.NumField())
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("yaml")
+		value := fmt.Sprint(v.Field(i).Interface())
+		switch {
+		case secretSettings[name] && value != "":
+			value = "[REDACTED]"
+		case name == "couchdb_url" || name == "mongodb_uri" || name == "storage_cache_redis_url" || name == "amqp_url":
+			value = redactURL(value)
+		case name == "nats_url" && value != "":
+			urls := strings.Split(value, ",")
+			for i, u := range urls {
+				urls[i] = redactURL(strings.TrimSpace(u))
+			}
+			value = strings.Join(urls, ",")
+		}
+		settings = append(settings, [2]string{name, value})
+	}
+	return settings
+}
+
+// redactURL hides the password of a URL, if it has one.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// Don't risk printing a password from a URL that cannot be parsed
+		return "[REDACTED]"
+	}
+	return u.Redacted()
+}
+
+// validateURL checks that raw is an absolute URL with a host and one of the given schemes.
+func validateURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("invalid URL")
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			if u.Host == "" {
+				return fmt.Errorf("URL %q has no host", u.Redacted())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("URL %q must use the %s scheme", u.Redacted(), strings.Join(schemes, " or "))
+}
+
+// validateListenAddr checks that addr is a "host:port" listen address with a valid port.
+//
+// Returns:
+//   - The port of the address
+//   - An error if the address is malformed or the port is out of range
+func validateListenAddr(addr string) (string, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q (use \"host:port\" or \":port\")", addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q in listen address %q", port, addr)
+	}
+	return port, nil
+}
+
+// isStorageType reports whether storageType is a supported storage type.
+func isStorageType(storageType string) bool {
+	for _, t := range storageTypes {
+		if t == storageType {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -36,3 +1442,43 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvBool gets a boolean environment variable or returns a default value
+// if the variable is not set or cannot be parsed
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration gets a duration environment variable (e.g., "15s") or returns a default value
+// if the variable is not set or cannot be parsed
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvFloat gets a non-negative floating-point environment variable or returns a default value
+// if the variable is not set or cannot be parsed
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvInt gets a non-negative integer environment variable or returns a default value
+// if the variable is not set or cannot be parsed
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,257 @@
+// Package crdt implements a Replicated Growable Array (RGA), a sequence CRDT for text that
+// several participants edit concurrently. Every character is an element with a unique ID;
+// insertions name the element they follow, and deletions leave a tombstone in place. Replicas
+// that apply the same operations, in any order that respects causality (an insertion after
+// the element it follows, a deletion after the insertion it deletes), end up with the same text.
+//
+// IDs are Lamport timestamps: a participant gives each new element a clock greater than the
+// clock of every element it has seen, and its own site name, so IDs never collide.
+package crdt
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Operation types.
+const (
+	OpInsert = "insert" // Insert a character after an element, or at the start
+	OpDelete = "delete" // Delete an element, leaving a tombstone
+)
+
+// ErrUnknownElement is returned for an operation that refers to an element the document
+// doesn't have, e.g., because an operation it depends on was not applied first.
+var ErrUnknownElement = errors.New("unknown element")
+
+// ID identifies an element of a document.
+type ID struct {
+	Clock uint64 `json:"clock"` // Lamport timestamp of the insertion
+	Site  string `json:"site"`  // Participant that inserted the element
+}
+
+// compare orders IDs by clock, then by site.
+func (id ID) compare(other ID) int {
+	if c := cmp.Compare(id.Clock, other.Clock); c != 0 {
+		return c
+	}
+	return strings.Compare(id.Site, other.Site)
+}
+
+// String returns the ID as clock@site.
+func (id ID) String() string {
+	return fmt.Sprintf("%d@%s", id.Clock, id.Site)
+}
+
+// Op is an operation on a document, as exchanged between participants.
+type Op struct {
+	Type  string `json:"type"`            // OpInsert or OpDelete
+	ID    ID     `json:"id"`              // The inserted or deleted element
+	After *ID    `json:"after,omitempty"` // Insert: the element the character follows; nil for the start
+	Text  string `json:"text,omitempty"`  // Insert: the character, a single Unicode code point
+}
+
+// element is a character of a document.
+type element struct {
+	id      ID
+	after   *ID
+	text    string
+	deleted bool
+}
+
+// Doc is a text document. It is not safe for concurrent use.
+type Doc struct {
+	elements []element   // All elements in document order, including tombstones
+	known    map[ID]bool // IDs of all elements
+	clock    uint64      // Greatest clock of all elements
+}
+
+// New creates an empty document.
+func New() *Doc {
+	return &Doc{known: make(map[ID]bool)}
+}
+
+// Text returns the text of the document, without the deleted characters.
+func (d *Doc) Text() string {
+	var b strings.Builder
+	for _, e := range d.elements {
+		if !e.deleted {
+			b.WriteString(e.text)
+		}
+	}
+	return b.String()
+}
+
+// Clock returns the greatest clock of the elements of the document. New elements
+// must have a greater clock.
+func (d *Doc) Clock() uint64 {
+	return d.clock
+}
+
+// Apply applies operations in order. Operations that were already applied are skipped,
+// so operations may be delivered more than once. It stops at the first invalid operation
+// and returns the valid operations before it, with the error.
+func (d *Doc) Apply(ops []Op) ([]Op, error) {
+	applied := make([]Op, 0, len(ops))
+	for i, op := range ops {
+		if err := d.apply(op); err != nil {
+			return applied, fmt.Errorf("operation %d: %w", i, err)
+		}
+		applied = append(applied, op)
+	}
+	return applied, nil
+}
+
+// apply applies a single operation.
+func (d *Doc) apply(op Op) error {
+	switch op.Type {
+	case OpInsert:
+		return d.insert(op)
+	case OpDelete:
+		i := d.find(op.ID)
+		if i < 0 {
+			return fmt.Errorf("%w %s", ErrUnknownElement, op.ID)
+		}
+		d.elements[i].deleted = true
+		return nil
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+// insert integrates an inserted character: right after the element it follows, but after
+// the elements that were inserted concurrently with a greater ID, so every replica picks
+// the same position.
+func (d *Doc) insert(op Op) error {
+	if d.known[op.ID] {
+		return nil
+	}
+	if op.ID.Site == "" || op.ID.Clock == 0 {
+		return fmt.Errorf("invalid element ID %s", op.ID)
+	}
+	if utf8.RuneCountInString(op.Text) != 1 || !utf8.ValidString(op.Text) {
+		return fmt.Errorf("insert of %s must have a single character", op.ID)
+	}
+
+	position := 0
+	if op.After != nil {
+		// Elements are inserted after elements their participant has seen
+		if op.After.Clock >= op.ID.Clock {
+			return fmt.Errorf("element %s must have a greater clock than %s", op.ID, op.After)
+		}
+		i := d.find(*op.After)
+		if i < 0 {
+			return fmt.Errorf("%w %s", ErrUnknownElement, op.After)
+		}
+		position = i + 1
+	}
+	for position < len(d.elements) && d.elements[position].id.compare(op.ID) > 0 {
+		position++
+	}
+
+	d.elements = slices.Insert(d.elements, position, element{id: op.ID, after: op.After, text: op.Text})
+	d.known[op.ID] = true
+	d.clock = max(d.clock, op.ID.Clock)
+	return nil
+}
+
+// find returns the position of an element, or -1 if the document doesn't have it.
+func (d *Doc) find(id ID) int {
+	if !d.known[id] {
+		return -1
+	}
+	return slices.IndexFunc(d.elements, func(e element) bool { return e.id == id })
+}
+
+// Ops returns the operations that rebuild the document: the insertions of all elements
+// in causal order, followed by the deletions.
+func (d *Doc) Ops() []Op {
+	inserted := slices.Clone(d.elements)
+	// An element has a greater clock than the element it follows
+	slices.SortFunc(inserted, func(a, b element) int { return a.id.compare(b.id) })
+
+	ops := make([]Op, 0, len(inserted))
+	for _, e := range inserted {
+		ops = append(ops, Op{Type: OpInsert, ID: e.id, After: e.after, Text: e.text})
+	}
+	for _, e := range d.elements {
+		if e.deleted {
+			ops = append(ops, Op{Type: OpDelete, ID: e.id})
+		}
+	}
+	return ops
+}
+
+// SetText changes the text of the document to text on behalf of a site, with the smallest
+// edit of a single range: the characters between the common prefix and suffix of the old
+// and new text are deleted, and the new ones inserted in their place. Invalid UTF-8 in text
+// is replaced with U+FFFD.
+//
+// Returns:
+//   - The operations of the edit, already applied, for the other participants
+func (d *Doc) SetText(site string, text string) []Op {
+	// Visible elements and the characters of the new text
+	var visible []int
+	for i, e := range d.elements {
+		if !e.deleted {
+			visible = append(visible, i)
+		}
+	}
+	chars := strings.Split(strings.ToValidUTF8(text, "\uFFFD"), "")
+
+	prefix := 0
+	for prefix < len(visible) && prefix < len(chars) && d.elements[visible[prefix]].text == chars[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(visible)-prefix && suffix < len(chars)-prefix &&
+		d.elements[visible[len(visible)-1-suffix]].text == chars[len(chars)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for _, i := range visible[prefix : len(visible)-suffix] {
+		ops = append(ops, Op{Type: OpDelete, ID: d.elements[i].id})
+	}
+	var after *ID
+	if prefix > 0 {
+		id := d.elements[visible[prefix-1]].id
+		after = &id
+	}
+	clock := d.clock
+	for _, char := range chars[prefix : len(chars)-suffix] {
+		clock++
+		id := ID{Clock: clock, Site: site}
+		ops = append(ops, Op{Type: OpInsert, ID: id, After: after, Text: char})
+		after = &id
+	}
+
+	// The operations are valid by construction
+	if _, err := d.Apply(ops); err != nil {
+		panic(err)
+	}
+	return ops
+}
+
+// MarshalJSON encodes the document as the JSON array of the operations that rebuild it.
+func (d *Doc) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Ops())
+}
+
+// UnmarshalJSON replaces the document with the one rebuilt from a JSON array of operations.
+func (d *Doc) UnmarshalJSON(data []byte) error {
+	var ops []Op
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return err
+	}
+	doc := New()
+	if _, err := doc.Apply(ops); err != nil {
+		return err
+	}
+	*d = *doc
+	return nil
+}
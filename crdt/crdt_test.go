@@ -0,0 +1,144 @@
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestDoc_SetText tests that edits of the whole text are turned into operations on a single range
+func TestDoc_SetText(t *testing.T) {
+	doc := New()
+	steps := []struct {
+		text    string
+		deletes int
+		inserts int
+	}{
+		{"Hello", 0, 5},
+		{"Hello, world", 0, 7},
+		{"Help, world", 2, 1},
+		{"Help, world", 0, 0},
+		{"", 11, 0},
+		{"héllo ✓", 0, 7},
+	}
+
+	for _, step := range steps {
+		ops := doc.SetText("a", step.text)
+		deletes, inserts := 0, 0
+		for _, op := range ops {
+			if op.Type == OpDelete {
+				deletes++
+			} else {
+				inserts++
+			}
+		}
+		if deletes != step.deletes || inserts != step.inserts {
+			t.Errorf("%q: expected %d deletions and %d insertions, got %+v", step.text, step.deletes, step.inserts, ops)
+		}
+		if got := doc.Text(); got != step.text {
+			t.Errorf("Expected text %q, got %q", step.text, got)
+		}
+	}
+}
+
+// TestDoc_Converge tests that replicas applying concurrent edits in different orders end up with the same text
+func TestDoc_Converge(t *testing.T) {
+	base := New()
+	initial := base.SetText("server", "ac")
+
+	alice, bob := New(), New()
+	for _, doc := range []*Doc{alice, bob} {
+		if _, err := doc.Apply(initial); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	// Both insert between a and c, and Bob also deletes c
+	fromAlice := alice.SetText("alice", "abc")
+	fromBob := bob.SetText("bob", "aB")
+
+	if _, err := alice.Apply(fromBob); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := bob.Apply(fromAlice); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := base.Apply(slices.Concat(fromBob, fromAlice)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if alice.Text() != bob.Text() || alice.Text() != base.Text() {
+		t.Fatalf("Expected the replicas to converge, got %q, %q, and %q", alice.Text(), bob.Text(), base.Text())
+	}
+	if got := alice.Text(); got != "aBb" && got != "abB" {
+		t.Errorf("Expected both insertions without c, got %q", got)
+	}
+
+	// Operations delivered twice are skipped
+	if _, err := alice.Apply(fromAlice); err != nil || alice.Text() != bob.Text() {
+		t.Errorf("Expected duplicate operations to be skipped, got %q: %v", alice.Text(), err)
+	}
+}
+
+// TestDoc_Apply tests that invalid operations are rejected
+func TestDoc_Apply(t *testing.T) {
+	doc := New()
+	doc.SetText("a", "x")
+	first := ID{Clock: 1, Site: "a"}
+	missing := ID{Clock: 7, Site: "b"}
+
+	tests := []struct {
+		name string
+		op   Op
+		err  error
+	}{
+		{"unknown after", Op{Type: OpInsert, ID: ID{Clock: 8, Site: "b"}, After: &missing, Text: "y"}, ErrUnknownElement},
+		{"unknown delete", Op{Type: OpDelete, ID: missing}, ErrUnknownElement},
+		{"older than after", Op{Type: OpInsert, ID: ID{Clock: 1, Site: "b"}, After: &first, Text: "y"}, nil},
+		{"several characters", Op{Type: OpInsert, ID: ID{Clock: 2, Site: "b"}, Text: "yz"}, nil},
+		{"no site", Op{Type: OpInsert, ID: ID{Clock: 2}, Text: "y"}, nil},
+		{"unknown type", Op{Type: "move", ID: first}, nil},
+	}
+	for _, tt := range tests {
+		applied, err := doc.Apply([]Op{{Type: OpInsert, ID: ID{Clock: 3, Site: "c"}, After: &first, Text: "!"}, tt.op})
+		if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+			t.Errorf("%s: expected an error, got %v", tt.name, err)
+		}
+		if len(applied) != 1 {
+			t.Errorf("%s: expected the valid operation before the invalid one to be applied, got %+v", tt.name, applied)
+		}
+	}
+	if got := doc.Text(); got != "x!" {
+		t.Errorf("Expected text %q, got %q", "x!", got)
+	}
+}
+
+// TestDoc_JSON tests that a document survives a round trip through JSON, tombstones included
+func TestDoc_JSON(t *testing.T) {
+	doc := New()
+	doc.SetText("a", "Hello")
+	doc.SetText("b", "Help!")
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	restored := New()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if restored.Text() != "Help!" || restored.Clock() != doc.Clock() {
+		t.Errorf("Expected %q with clock %d, got %q with clock %d", "Help!", doc.Clock(), restored.Text(), restored.Clock())
+	}
+
+	// The restored document accepts further edits of the original
+	ops := doc.SetText("a", "Hello!")
+	if _, err := restored.Apply(ops); err != nil || restored.Text() != "Hello!" {
+		t.Errorf("Expected %q, got %q: %v", "Hello!", restored.Text(), err)
+	}
+
+	if err := json.Unmarshal([]byte(`[{"type":"delete","id":{"clock":1,"site":"a"}}]`), restored); !errors.Is(err, ErrUnknownElement) {
+		t.Errorf("Expected ErrUnknownElement for an invalid document, got %v", err)
+	}
+}
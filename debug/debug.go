@@ -0,0 +1,55 @@
+// Package debug provides the HTTP handler for runtime diagnostics: the net/http/pprof
+// profiling endpoints and the expvar variables. It is meant to be served on a separate,
+// non-public admin port, so profiling data is never exposed through the public API.
+package debug
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// Handler returns an HTTP handler serving the diagnostics endpoints:
+//   - /debug/pprof/ - Index of the available profiles (heap, goroutine, block, mutex, etc.)
+//   - /debug/pprof/profile - CPU profile (duration set by the "seconds" query parameter)
+//   - /debug/pprof/trace - Execution trace
+//   - /debug/pprof/cmdline, /debug/pprof/symbol - Command line and symbol lookup
+//   - /debug/vars - expvar variables (memstats, cmdline, and anything published by the application)
+//
+// Parameters:
+//   - token: If not empty, requests must carry an "Authorization: Bearer <token>" header
+//
+// Returns:
+//   - An http.Handler serving the endpoints above
+func Handler(token string) http.Handler {
+	// Use a dedicated mux; importing net/http/pprof also registers its handlers
+	// on http.DefaultServeMux, which the application never serves
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	if token == "" {
+		return mux
+	}
+	return requireToken(token, mux)
+}
+
+// requireToken rejects requests that don't carry the expected bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// Compare in constant time so the token can't be guessed byte by byte
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandler tests that the pprof and expvar endpoints are served
+func TestHandler(t *testing.T) {
+	handler := Handler("")
+
+	tests := []struct {
+		path     string
+		contains string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/cmdline", ""},
+		{"/debug/vars", "memstats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("Expected response to contain %q", tt.contains)
+			}
+		})
+	}
+
+	// Anything outside /debug is not served
+	req := httptest.NewRequest("GET", "/api/notes", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestHandler_Token tests that a configured token is required
+func TestHandler_Token(t *testing.T) {
+	handler := Handler("secret")
+
+	tests := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"Missing", "", http.StatusUnauthorized},
+		{"Wrong", "Bearer wrong", http.StatusUnauthorized},
+		{"Wrong Scheme", "Basic secret", http.StatusUnauthorized},
+		{"Valid", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/vars", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
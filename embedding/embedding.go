@@ -0,0 +1,93 @@
+// Package embedding implements service.Embedder: providers that turn texts into vectors
+// (embeddings) whose distances reflect how related the texts are, for semantic search.
+// A hashing embedder works without external services; a client of an embeddings API
+// compatible with OpenAI's uses a real embedding model.
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Supported embedding providers.
+const (
+	KindHashing = "hashing" // Feature hashing of the words, without external services
+	KindOpenAI  = "openai"  // OpenAI-compatible embeddings API
+)
+
+// IsKind reports whether name is a supported embedding provider.
+func IsKind(name string) bool {
+	return name == KindHashing || name == KindOpenAI
+}
+
+// Hashing embeds texts by feature hashing: every word and pair of adjacent words adds to
+// one of a fixed number of dimensions, chosen by its hash. Texts sharing words get close
+// vectors, so it finds notes by their vocabulary rather than their meaning (synonyms
+// don't match), but it needs no model, so it suits development, tests, and small
+// deployments.
+type Hashing struct {
+	dimensions int // Number of dimensions of the vectors
+}
+
+// NewHashing creates a hashing embedder.
+//
+// Parameters:
+//   - dimensions: The number of dimensions of the vectors (at least 1); more dimensions
+//     mean fewer collisions between words, and larger vectors
+//
+// Returns:
+//   - A pointer to a new Hashing embedder
+func NewHashing(dimensions int) *Hashing {
+	return &Hashing{dimensions: max(1, dimensions)}
+}
+
+// Embed returns the normalized vector of every text. Texts without words get zero vectors.
+func (h *Hashing) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, h.dimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for j, word := range words {
+			h.add(vector, word, 1)
+			if j > 0 {
+				h.add(vector, words[j-1]+" "+word, 0.5)
+			}
+		}
+		normalize(vector)
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// add adds the weight of a feature to its dimension, with a sign taken from its hash, so
+// that collisions cancel out rather than add up on average.
+func (h *Hashing) add(vector []float32, feature string, weight float32) {
+	hash := fnv.New64a()
+	hash.Write([]byte(feature))
+	sum := hash.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint64(h.dimensions)] += weight
+}
+
+// normalize scales a vector to unit length, so the dot product of two vectors is their
+// cosine similarity. Zero vectors are left as they are.
+func normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
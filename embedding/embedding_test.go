@@ -0,0 +1,83 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// similarity returns the dot product of two normalized vectors.
+func similarity(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// TestHashing tests that texts sharing words get closer vectors than unrelated texts
+func TestHashing(t *testing.T) {
+	vectors, err := NewHashing(256).Embed(context.Background(), []string{
+		"Water the tomatoes in the garden",
+		"The garden tomatoes need water",
+		"Quarterly budget review meeting",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 4 || len(vectors[0]) != 256 {
+		t.Fatalf("Expected 4 vectors of 256 dimensions, got %d", len(vectors))
+	}
+	if self := similarity(vectors[0], vectors[0]); self < 0.999 || self > 1.001 {
+		t.Errorf("Expected a normalized vector, got a similarity of %f with itself", self)
+	}
+	related, unrelated := similarity(vectors[0], vectors[1]), similarity(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("Expected related texts to be closer: %f <= %f", related, unrelated)
+	}
+	if similarity(vectors[3], vectors[3]) != 0 {
+		t.Error("Expected a zero vector for a text without words")
+	}
+}
+
+// TestOpenAI tests the requests to an OpenAI-compatible embeddings API, and its errors
+func TestOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.URL.Path != "/v1/embeddings" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+			return
+		}
+		if request.Model != "small" || len(request.Input) != 2 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		// Out of order, as the API doesn't guarantee it
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	vectors, err := NewOpenAI(server.URL+"/v1/", "key", "small", time.Second).Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Expected the embeddings in the order of the texts, got %v", vectors)
+	}
+	_, err = NewOpenAI(server.URL+"/v1", "wrong", "small", time.Second).Embed(context.Background(), []string{"first", "second"})
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("Expected the error of the API, got %v", err)
+	}
+}
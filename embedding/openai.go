@@ -0,0 +1,98 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxErrorBody is the number of bytes of an error response included in errors.
+const maxErrorBody = 512
+
+// OpenAI embeds texts with an embeddings API compatible with OpenAI's (OpenAI, Azure
+// OpenAI, Ollama, vLLM, LiteLLM, ...).
+type OpenAI struct {
+	endpoint string       // Base URL of the API, to which /embeddings is appended
+	apiKey   string       // Bearer token of the API (optional, e.g., for a local server)
+	model    string       // Embedding model
+	client   *http.Client // Client of the API, with the request timeout
+}
+
+// NewOpenAI creates an embedder backed by an OpenAI-compatible embeddings API. No request
+// is made, so the API doesn't have to be reachable yet.
+//
+// Parameters:
+//   - endpoint: The base URL of the API (e.g., https://api.openai.com/v1)
+//   - apiKey: The API key, sent as a bearer token; empty to send none
+//   - model: The embedding model (e.g., text-embedding-3-small)
+//   - timeout: The timeout of every request
+//
+// Returns:
+//   - A pointer to a new OpenAI embedder
+func NewOpenAI(endpoint, apiKey, model string, timeout time.Duration) *OpenAI {
+	return &OpenAI{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+		model:    model,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Embed returns the vector of every text, computed by the model in a single request.
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{Model: o.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var embeddings struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embeddings); err != nil {
+		return nil, fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+
+	// The embeddings may come in any order, with the index of their text
+	vectors := make([][]float32, len(texts))
+	for _, data := range embeddings.Data {
+		if data.Index < 0 || data.Index >= len(texts) || vectors[data.Index] != nil {
+			return nil, fmt.Errorf("invalid response from %s: unexpected embedding %d", req.URL.Host, data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("invalid response from %s: no embedding for text %d", req.URL.Host, i)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,124 @@
+// Package events provides the internal event bus for note lifecycle events.
+// Changes to notes are published once, by the note service or the change feed,
+// and every integration (watches, webhooks, WebSocket streams, message queues)
+// subscribes to the same stream instead of being wired into the write path.
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// Note lifecycle event types.
+const (
+	NoteCreated = "note.created" // A note was created
+	NoteUpdated = "note.updated" // A note was updated
+	NoteDeleted = "note.deleted" // A note was deleted
+)
+
+// Types lists all event types, in lifecycle order.
+var Types = []string{NoteCreated, NoteUpdated, NoteDeleted}
+
+// Event describes a change to a note.
+type Event struct {
+	Type      string      `json:"event"`          // Event type (e.g., "note.updated")
+	NoteID    string      `json:"note_id"`        // ID of the note that changed
+	Note      *model.Note `json:"note,omitempty"` // The note after the change (omitted on delete)
+	Timestamp time.Time   `json:"timestamp"`      // When the change happened
+}
+
+// Publisher publishes note events. It is implemented by Bus.
+type Publisher interface {
+	// Publish delivers an event to the subscribers.
+	Publish(ctx context.Context, event Event)
+}
+
+// Subscriber receives note events.
+type Subscriber interface {
+	// Notify delivers an event; it must not block on slow receivers,
+	// since events are delivered synchronously on the publisher's goroutine.
+	Notify(ctx context.Context, event Event)
+}
+
+// SubscriberFunc is a function that receives note events.
+type SubscriberFunc func(ctx context.Context, event Event)
+
+// Notify calls f(ctx, event).
+func (f SubscriberFunc) Notify(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// subscription is a subscriber registered with a Bus.
+type subscription struct {
+	name       string     // Name used in log messages
+	subscriber Subscriber // Receiver of the events
+}
+
+// Bus delivers every published event to all subscribers, in the order they subscribed.
+// Events are delivered synchronously, so subscribers see them in publishing order and
+// must hand off slow work (e.g., HTTP requests) to their own goroutines.
+// A subscriber that panics is logged and skipped, so it cannot break the write that
+// published the event. It is safe for concurrent use.
+type Bus struct {
+	mutex         sync.RWMutex
+	subscriptions []*subscription
+}
+
+// NewBus creates an event bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a subscriber for all events published from now on.
+//
+// Parameters:
+//   - name: A short name of the subscriber, used in log messages (e.g., "webhooks")
+//   - subscriber: The receiver of the events
+//
+// Returns:
+//   - A function that removes the subscription; calling it more than once is safe
+func (b *Bus) Subscribe(name string, subscriber Subscriber) func() {
+	sub := &subscription{name: name, subscriber: subscriber}
+
+	b.mutex.Lock()
+	b.subscriptions = append(b.subscriptions, sub)
+	b.mutex.Unlock()
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		for i, s := range b.subscriptions {
+			if s == sub {
+				b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers an event to every subscriber.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mutex.RLock()
+	subscriptions := b.subscriptions
+	b.mutex.RUnlock()
+
+	for _, sub := range subscriptions {
+		b.deliver(ctx, sub, event)
+	}
+}
+
+// deliver delivers an event to a single subscriber, recovering from its panics.
+func (b *Bus) deliver(ctx context.Context, sub *subscription, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%sEvent subscriber %s panicked on %s event of note %s: %v",
+				requestid.LogPrefix(ctx), sub.name, event.Type, event.NoteID, r)
+		}
+	}()
+	sub.subscriber.Notify(ctx, event)
+}
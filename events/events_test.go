@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+// recorder is a subscriber that records the events it receives
+type recorder struct {
+	events []Event
+}
+
+// Notify records the event
+func (r *recorder) Notify(ctx context.Context, event Event) {
+	r.events = append(r.events, event)
+}
+
+// types returns the types of the recorded events
+func (r *recorder) types() []string {
+	types := make([]string, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+// TestBus tests that events are delivered to subscribers in order until they unsubscribe
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+
+	first, second := &recorder{}, &recorder{}
+	unsubscribe := bus.Subscribe("first", first)
+	bus.Subscribe("second", second)
+
+	bus.Publish(ctx, Event{Type: NoteCreated, NoteID: "note-1"})
+	bus.Publish(ctx, Event{Type: NoteDeleted, NoteID: "note-1"})
+
+	// Unsubscribing twice is safe
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(ctx, Event{Type: NoteCreated, NoteID: "note-2"})
+
+	if types := first.types(); len(types) != 2 || types[0] != NoteCreated || types[1] != NoteDeleted {
+		t.Errorf("Expected the first subscriber to receive two events, got %v", types)
+	}
+	if len(second.events) != 3 {
+		t.Errorf("Expected the second subscriber to receive three events, got %v", second.types())
+	}
+}
+
+// TestBus_Panic tests that a panicking subscriber doesn't affect the others
+func TestBus_Panic(t *testing.T) {
+	bus := NewBus()
+	bus.Subscribe("broken", SubscriberFunc(func(ctx context.Context, event Event) {
+		panic("broken subscriber")
+	}))
+	rec := &recorder{}
+	bus.Subscribe("recorder", rec)
+
+	bus.Publish(context.Background(), Event{Type: NoteUpdated, NoteID: "note-1"})
+
+	if len(rec.events) != 1 {
+		t.Errorf("Expected the event to reach the second subscriber, got %v", rec.types())
+	}
+}
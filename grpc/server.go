@@ -5,36 +5,61 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"time"
 
+	"golang-simple-notes/inflight"
+	"golang-simple-notes/metrics"
 	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
 	"golang-simple-notes/storage"
+	"golang-simple-notes/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the fully qualified gRPC service name, used to name RPC spans.
+const serviceName = "notes.Notes"
+
+var (
+	// errNoteNotFound is returned by RPCs for notes that don't exist
+	errNoteNotFound = errors.New("note not found")
+
+	// errConflict is returned by updates of notes that were modified concurrently
+	errConflict = errors.New("note was modified concurrently")
+
+	// errShuttingDown is returned by RPCs received after Shutdown was called
+	errShuttingDown = errors.New("server is shutting down")
 )
 
 // Server implements the Notes gRPC service.
-// It uses a storage implementation to persist and retrieve notes.
-// This follows the dependency injection pattern, allowing the server
-// to work with any storage implementation that satisfies the NoteStorage interface.
+// It is a thin adapter over the note service, which holds the business logic
+// shared with the REST API.
 type Server struct {
-	storage storage.NoteStorage // Storage backend for notes
-	port    int                 // Port to listen on
+	notes service.Notes    // Business logic of notes
+	port  int              // Port to listen on
+	calls inflight.Tracker // RPCs being handled, waited for by Shutdown
 }
 
-// NewServer creates a new instance of the gRPC server with the provided storage and port.
+// NewServer creates a new instance of the gRPC server with the provided note service and port.
 // This follows the factory pattern for creating servers.
 //
 // Parameters:
-//   - storage: An implementation of the NoteStorage interface
+//   - notes: The note service, shared with the REST API
 //   - port: The port number to listen on
 //
 // Returns:
 //   - A pointer to a new Server instance
-func NewServer(storage storage.NoteStorage, port int) *Server {
+func NewServer(notes service.Notes, port int) *Server {
 	return &Server{
-		storage: storage,
-		port:    port,
+		notes: notes,
+		port:  port,
 	}
 }
 
@@ -54,7 +79,7 @@ func (s *Server) Start() error {
 	// This is a mock implementation that would normally listen for gRPC requests
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
 	if err != nil {
-		return fmt.Errorf("failed to listen: %v", err)
+		return fmt.Errorf("failed to listen: %w", err)
 	}
 
 	fmt.Printf("gRPC server listening on %s\n", listener.Addr())
@@ -70,6 +95,120 @@ func (s *Server) Start() error {
 	return listener.Close()
 }
 
+// Shutdown stops accepting RPCs and waits for the ones in flight to finish, logging how many
+// are left while it waits. RPCs received after Shutdown was called fail with "Unavailable".
+// In a full gRPC implementation this would call GracefulStop on the grpc.Server.
+//
+// Parameters:
+//   - ctx: The context bounding the wait
+//
+// Returns:
+//   - An error if ctx ends before all RPCs have finished
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.calls.Drain(ctx, "gRPC")
+}
+
+// ContextWithMetadata returns a context carrying the request ID found in the incoming
+// gRPC metadata under the "x-request-id" key, or a newly generated ID if the metadata
+// has none (or an invalid one). Trace context sent by the client (the W3C "traceparent"
+// key) is extracted as well, so RPC spans join the client's trace. In a full gRPC
+// implementation this would run in a unary server interceptor, which would also echo
+// the ID back in the response header metadata.
+//
+// Parameters:
+//   - ctx: The context of the incoming call
+//   - md: The incoming metadata (gRPC metadata is a map of lowercase keys to values)
+//
+// Returns:
+//   - A context carrying the request ID
+func ContextWithMetadata(ctx context.Context, md map[string][]string) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	if values := md[requestid.MetadataKey]; len(values) > 0 && requestid.IsValid(values[0]) {
+		return requestid.NewContext(ctx, values[0])
+	}
+	return requestid.Ensure(ctx)
+}
+
+// metadataCarrier adapts gRPC metadata to the OpenTelemetry TextMapCarrier interface.
+// Unlike propagation.HeaderCarrier, it doesn't canonicalize keys, since gRPC metadata keys are lowercase.
+type metadataCarrier map[string][]string
+
+// Get returns the first value for the key, or an empty string.
+func (c metadataCarrier) Get(key string) string {
+	if values := c[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set stores a single value for the key.
+func (c metadataCarrier) Set(key, value string) {
+	c[key] = []string{value}
+}
+
+// Keys returns all keys of the metadata.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startSpan begins a server span for an RPC method (e.g., "notes.Notes/GetNote").
+// It also makes sure the context carries a request ID, which is recorded on the span.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx = requestid.Ensure(ctx)
+	return tracing.Tracer().Start(ctx, serviceName+"/"+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", serviceName),
+			attribute.String("rpc.method", method),
+			attribute.String("request.id", requestid.FromContext(ctx)),
+		),
+	)
+}
+
+// observeRPC records an RPC in the request metrics, with the status code that a full gRPC
+// implementation would return for its error. It is deferred right after startSpan, with a
+// pointer to the named error result of the method.
+func observeRPC(ctx context.Context, method string, start time.Time, err *error) {
+	metrics.ObserveGRPCRequest(ctx, method, rpcCode(*err), time.Since(start))
+}
+
+// rpcCode returns the name of the gRPC status code of an RPC error.
+func rpcCode(err error) string {
+	switch {
+	case err == nil:
+		return "OK"
+	case errors.Is(err, service.ErrInvalidNote):
+		return "InvalidArgument"
+	case errors.Is(err, errNoteNotFound):
+		return "NotFound"
+	case errors.Is(err, errConflict):
+		return "Aborted"
+	case errors.Is(err, errShuttingDown):
+		return "Unavailable"
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return "ResourceExhausted"
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "DeadlineExceeded"
+	default:
+		return "Internal"
+	}
+}
+
+// spanError records an error on the RPC span and returns it unchanged.
+func spanError(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
 // The following methods would normally implement the gRPC service interface
 // In a real implementation, these would have the correct signatures based on the generated protobuf code
 // from the proto/notes.proto file. For demonstration purposes, we're using simplified signatures.
@@ -85,14 +224,24 @@ func (s *Server) Start() error {
 // Returns:
 //   - The created note, including its generated ID and timestamps
 //   - An error if the creation fails
-func (s *Server) CreateNote(ctx context.Context, title, content string) (*model.Note, error) {
+func (s *Server) CreateNote(ctx context.Context, title, content string) (_ *model.Note, err error) {
+	// Trace and measure the RPC; this also makes sure storage calls carry a request ID
+	ctx, span := startSpan(ctx, "CreateNote")
+	defer span.End()
+	defer observeRPC(ctx, "CreateNote", time.Now(), &err)
+	if !s.calls.Add() {
+		return nil, errShuttingDown
+	}
+	defer s.calls.Done()
+
 	// Create a new note with the provided title and content
 	// This will generate a unique ID and set the creation/update timestamps
-	note := model.NewNote(title, content)
-
-	// Save the note to the storage
-	if err := s.storage.Create(ctx, note); err != nil {
-		return nil, fmt.Errorf("failed to create note: %v", err)
+	note, err := s.notes.Create(ctx, service.NoteInput{Title: title, Content: content})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidNote) {
+			return nil, err
+		}
+		return nil, spanError(span, fmt.Errorf("failed to create note: %w", err))
 	}
 
 	return note, nil
@@ -108,15 +257,24 @@ func (s *Server) CreateNote(ctx context.Context, title, content string) (*model.
 // Returns:
 //   - The requested note if found
 //   - An error if the note doesn't exist or if retrieval fails
-func (s *Server) GetNote(ctx context.Context, id string) (*model.Note, error) {
-	// Get the note from the storage
-	note, err := s.storage.Get(ctx, id)
+func (s *Server) GetNote(ctx context.Context, id string) (_ *model.Note, err error) {
+	// Trace and measure the RPC; this also makes sure storage calls carry a request ID
+	ctx, span := startSpan(ctx, "GetNote")
+	defer span.End()
+	defer observeRPC(ctx, "GetNote", time.Now(), &err)
+	if !s.calls.Add() {
+		return nil, errShuttingDown
+	}
+	defer s.calls.Done()
+
+	// Get the note from the storage, counting the view
+	note, err := s.notes.View(ctx, id)
 	if err != nil {
 		// Handle specific error cases
 		if err == storage.ErrNoteNotFound {
-			return nil, fmt.Errorf("note not found")
+			return nil, errNoteNotFound
 		}
-		return nil, fmt.Errorf("failed to retrieve note: %v", err)
+		return nil, spanError(span, fmt.Errorf("failed to retrieve note: %w", err))
 	}
 
 	return note, nil
@@ -131,11 +289,20 @@ func (s *Server) GetNote(ctx context.Context, id string) (*model.Note, error) {
 // Returns:
 //   - A slice of all notes, which may be empty if there are no notes
 //   - An error if retrieval fails
-func (s *Server) GetAllNotes(ctx context.Context) ([]*model.Note, error) {
+func (s *Server) GetAllNotes(ctx context.Context) (_ []*model.Note, err error) {
+	// Trace and measure the RPC; this also makes sure storage calls carry a request ID
+	ctx, span := startSpan(ctx, "GetAllNotes")
+	defer span.End()
+	defer observeRPC(ctx, "GetAllNotes", time.Now(), &err)
+	if !s.calls.Add() {
+		return nil, errShuttingDown
+	}
+	defer s.calls.Done()
+
 	// Get all notes from the storage
-	notes, err := s.storage.GetAll(ctx)
+	notes, err := s.notes.GetAll(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve notes: %v", err)
+		return nil, spanError(span, fmt.Errorf("failed to retrieve notes: %w", err))
 	}
 
 	return notes, nil
@@ -152,29 +319,46 @@ func (s *Server) GetAllNotes(ctx context.Context) ([]*model.Note, error) {
 //
 // Returns:
 //   - The updated note
-//   - An error if the note doesn't exist or if the update fails
-func (s *Server) UpdateNote(ctx context.Context, id, title, content string) (*model.Note, error) {
-	// First, get the existing note to make sure it exists
-	existingNote, err := s.storage.Get(ctx, id)
-	if err != nil {
-		// Handle specific error cases
-		if err == storage.ErrNoteNotFound {
-			return nil, fmt.Errorf("note not found")
-		}
-		return nil, fmt.Errorf("failed to retrieve note: %v", err)
+//   - An error if the note doesn't exist, if it was modified concurrently, or if the update fails
+func (s *Server) UpdateNote(ctx context.Context, id, title, content string) (_ *model.Note, err error) {
+	// Trace and measure the RPC; this also makes sure storage calls carry a request ID
+	ctx, span := startSpan(ctx, "UpdateNote")
+	defer span.End()
+	defer observeRPC(ctx, "UpdateNote", time.Now(), &err)
+	if !s.calls.Add() {
+		return nil, errShuttingDown
 	}
+	defer s.calls.Done()
 
-	// Update the note's fields
-	existingNote.Title = title
-	existingNote.Content = content
-	existingNote.UpdatedAt = time.Now() // Update the "last updated" timestamp
+	// The gRPC API doesn't have the color, icon, and tags of notes, so updates keep them;
+	// the update is based on the note's update time, so they aren't reverted if another
+	// client changed them in the meantime
+	input := service.NoteInput{Title: title, Content: content}
+	current, err := s.notes.Get(ctx, id)
+	if err == nil {
+		input.Color, input.Icon, input.Tags = current.Color, current.Icon, current.Tags
+		input.UpdatedAt = current.UpdatedAt
+	}
 
-	// Save the updated note to the storage
-	if err := s.storage.Update(ctx, existingNote); err != nil {
-		return nil, fmt.Errorf("failed to update note: %v", err)
+	// Update the note's fields and its "last updated" timestamp
+	var note *model.Note
+	if err == nil {
+		note, err = s.notes.Update(ctx, id, input)
+	}
+	if err != nil {
+		// Handle specific error cases
+		switch {
+		case errors.Is(err, service.ErrInvalidNote):
+			return nil, err
+		case err == storage.ErrNoteNotFound:
+			return nil, errNoteNotFound
+		case errors.Is(err, storage.ErrConflict):
+			return nil, errConflict
+		}
+		return nil, spanError(span, fmt.Errorf("failed to update note: %w", err))
 	}
 
-	return existingNote, nil
+	return note, nil
 }
 
 // DeleteNote deletes a note by its ID.
@@ -187,14 +371,23 @@ func (s *Server) UpdateNote(ctx context.Context, id, title, content string) (*mo
 // Returns:
 //   - An error if the note doesn't exist or if deletion fails
 //   - nil if deletion is successful
-func (s *Server) DeleteNote(ctx context.Context, id string) error {
+func (s *Server) DeleteNote(ctx context.Context, id string) (err error) {
+	// Trace and measure the RPC; this also makes sure storage calls carry a request ID
+	ctx, span := startSpan(ctx, "DeleteNote")
+	defer span.End()
+	defer observeRPC(ctx, "DeleteNote", time.Now(), &err)
+	if !s.calls.Add() {
+		return errShuttingDown
+	}
+	defer s.calls.Done()
+
 	// Delete the note from the storage
-	if err := s.storage.Delete(ctx, id); err != nil {
+	if err := s.notes.Delete(ctx, id); err != nil {
 		// Handle specific error cases
 		if err == storage.ErrNoteNotFound {
-			return fmt.Errorf("note not found")
+			return errNoteNotFound
 		}
-		return fmt.Errorf("failed to delete note: %v", err)
+		return spanError(span, fmt.Errorf("failed to delete note: %w", err))
 	}
 
 	return nil
@@ -4,120 +4,44 @@ import (
 	"context"
 	"errors"
 	"net"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
+	"golang-simple-notes/metrics"
 	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
 	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-// MockStorage is a mock implementation of the NoteStorage interface for testing
-type MockStorage struct {
-	notes map[string]*model.Note
-}
-
-// NewMockStorage creates a new instance of MockStorage
-func NewMockStorage() *MockStorage {
-	return &MockStorage{
-		notes: make(map[string]*model.Note),
-	}
-}
-
-// Create adds a new note to the storage
-func (s *MockStorage) Create(ctx context.Context, note *model.Note) error {
-	s.notes[note.ID] = note
-	return nil
-}
-
-// Get retrieves a note by its ID
-func (s *MockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	note, exists := s.notes[id]
-	if !exists {
-		return nil, storage.ErrNoteNotFound
-	}
-	return note, nil
-}
-
-// GetAll retrieves all notes from the storage
-func (s *MockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	notes := make([]*model.Note, 0, len(s.notes))
-	for _, note := range s.notes {
-		notes = append(notes, note)
-	}
-	return notes, nil
-}
-
-// Update updates an existing note
-func (s *MockStorage) Update(ctx context.Context, note *model.Note) error {
-	if _, exists := s.notes[note.ID]; !exists {
-		return storage.ErrNoteNotFound
-	}
-	s.notes[note.ID] = note
-	return nil
-}
-
-// Delete removes a note from the storage
-func (s *MockStorage) Delete(ctx context.Context, id string) error {
-	if _, exists := s.notes[id]; !exists {
-		return storage.ErrNoteNotFound
-	}
-	delete(s.notes, id)
-	return nil
-}
-
-// Close closes any resources used by the storage
-func (s *MockStorage) Close(ctx context.Context) error {
-	return nil
-}
-
-// FailingMockStorage is a mock implementation that always returns errors
-type FailingMockStorage struct{}
-
-// NewFailingMockStorage creates a new instance of FailingMockStorage
-func NewFailingMockStorage() *FailingMockStorage {
-	return &FailingMockStorage{}
-}
-
-// Create always returns an error
-func (s *FailingMockStorage) Create(ctx context.Context, note *model.Note) error {
-	return errors.New("mock storage create error")
-}
-
-// Get always returns an error
-func (s *FailingMockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	return nil, errors.New("mock storage get error")
-}
-
-// GetAll always returns an error
-func (s *FailingMockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	return nil, errors.New("mock storage getall error")
-}
-
-// Update always returns an error
-func (s *FailingMockStorage) Update(ctx context.Context, note *model.Note) error {
-	return errors.New("mock storage update error")
-}
-
-// Delete always returns an error
-func (s *FailingMockStorage) Delete(ctx context.Context, id string) error {
-	return errors.New("mock storage delete error")
-}
-
-// Close always returns an error
-func (s *FailingMockStorage) Close(ctx context.Context) error {
-	return errors.New("mock storage close error")
+// newFailingStorage returns a fake storage whose every operation fails
+func newFailingStorage() *fake.Storage {
+	backend := fake.New()
+	backend.Fail(errors.New("mock storage error"))
+	return backend
 }
 
 // TestNewServer tests the creation of a new server
 func TestNewServer(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 
 	if server == nil {
 		t.Fatal("Expected server to be created, got nil")
 	}
 
-	if server.storage != mockStorage {
-		t.Error("Expected server to use the provided storage")
+	if server.notes == nil {
+		t.Error("Expected server to use the provided note service")
 	}
 
 	if server.port != 8081 {
@@ -127,8 +51,8 @@ func TestNewServer(t *testing.T) {
 
 // TestStart tests the Start method
 func TestStart(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 
 	// Since the Start method is a mock implementation that just returns nil,
 	// we just verify that it doesn't return an error
@@ -140,7 +64,7 @@ func TestStart(t *testing.T) {
 
 // TestStartError tests error handling in the Start method
 func TestStartError(t *testing.T) {
-	mockStorage := NewMockStorage()
+	mockStorage := fake.New()
 
 	// First, create a listener on the port we want to use
 	listener, err := net.Listen("tcp", ":8082")
@@ -155,7 +79,7 @@ func TestStartError(t *testing.T) {
 	}(listener)
 
 	// Now try to start a server on the same port, which should fail
-	server := NewServer(mockStorage, 8082)
+	server := NewServer(service.New(mockStorage), 8082)
 	err = server.Start()
 
 	// We expect an error because the port is already in use
@@ -166,8 +90,8 @@ func TestStartError(t *testing.T) {
 
 // TestCreateNote tests the CreateNote method
 func TestCreateNote(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 	ctx := context.Background()
 
 	note, err := server.CreateNote(ctx, "Test Title", "Test Content")
@@ -200,8 +124,8 @@ func TestCreateNote(t *testing.T) {
 
 // TestGetNote tests the GetNote method
 func TestGetNote(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 	ctx := context.Background()
 
 	// Create a note
@@ -238,8 +162,8 @@ func TestGetNote(t *testing.T) {
 
 // TestGetAllNotes tests the GetAllNotes method
 func TestGetAllNotes(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 	ctx := context.Background()
 
 	// Create some notes
@@ -282,12 +206,13 @@ func TestGetAllNotes(t *testing.T) {
 
 // TestUpdateNote tests the UpdateNote method
 func TestUpdateNote(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 	ctx := context.Background()
 
 	// Create a note
 	originalNote := model.NewNote("Original Title", "Original Content")
+	originalNote.Color, originalNote.Icon, originalNote.Tags = "#1e90ff", "star", []string{"work"}
 	err := mockStorage.Create(ctx, originalNote)
 	if err != nil {
 		return
@@ -311,6 +236,11 @@ func TestUpdateNote(t *testing.T) {
 		t.Errorf("Expected content to be 'Updated Content', got '%s'", updatedNote.Content)
 	}
 
+	// The gRPC API can't set the color, icon, and tags, so they are kept
+	if updatedNote.Color != "#1e90ff" || updatedNote.Icon != "star" || !slices.Equal(updatedNote.Tags, []string{"work"}) {
+		t.Errorf("Expected the color, icon, and tags to be kept, got %q, %q, and %v", updatedNote.Color, updatedNote.Icon, updatedNote.Tags)
+	}
+
 	// Verify the note was updated in storage
 	retrieved, err := mockStorage.Get(ctx, originalNote.ID)
 	if err != nil {
@@ -322,10 +252,45 @@ func TestUpdateNote(t *testing.T) {
 	}
 }
 
+// concurrentUpdate is a fake storage where another client updates a note right after it is
+// first read
+type concurrentUpdate struct {
+	*fake.Storage
+	once sync.Once
+}
+
+// Get gets a note, updating it behind the caller's back on the first call
+func (s *concurrentUpdate) Get(ctx context.Context, id string) (*model.Note, error) {
+	note, err := s.Storage.Get(ctx, id)
+	if err == nil {
+		s.once.Do(func() {
+			changed := *note
+			changed.Color, changed.UpdatedAt = "#ff0000", note.UpdatedAt.Add(time.Second)
+			s.Add(&changed)
+		})
+	}
+	return note, err
+}
+
+// TestUpdateNoteConflict tests that an update doesn't overwrite a concurrent update
+func TestUpdateNoteConflict(t *testing.T) {
+	original := model.NewNote("Original Title", "Original Content")
+	backend := &concurrentUpdate{Storage: fake.New(original)}
+	server := NewServer(service.New(backend), 8081)
+
+	_, err := server.UpdateNote(context.Background(), original.ID, "Updated Title", "Updated Content")
+	if !errors.Is(err, errConflict) || rpcCode(err) != "Aborted" {
+		t.Fatalf("Expected an Aborted conflict, got %v", err)
+	}
+	if stored := backend.Note(original.ID); stored.Title != "Original Title" || stored.Color != "#ff0000" {
+		t.Errorf("Expected the concurrent update to be kept, got %+v", stored)
+	}
+}
+
 // TestDeleteNote tests the DeleteNote method
 func TestDeleteNote(t *testing.T) {
-	mockStorage := NewMockStorage()
-	server := NewServer(mockStorage, 8081)
+	mockStorage := fake.New()
+	server := NewServer(service.New(mockStorage), 8081)
 	ctx := context.Background()
 
 	// Create a note
@@ -350,8 +315,8 @@ func TestDeleteNote(t *testing.T) {
 
 // TestCreateNoteError tests error handling in CreateNote
 func TestCreateNoteError(t *testing.T) {
-	failingStorage := NewFailingMockStorage()
-	server := NewServer(failingStorage, 8081)
+	failingStorage := newFailingStorage()
+	server := NewServer(service.New(failingStorage), 8081)
 	ctx := context.Background()
 
 	_, err := server.CreateNote(ctx, "Test Title", "Test Content")
@@ -362,8 +327,8 @@ func TestCreateNoteError(t *testing.T) {
 
 // TestGetNoteError tests error handling in GetNote
 func TestGetNoteError(t *testing.T) {
-	failingStorage := NewFailingMockStorage()
-	server := NewServer(failingStorage, 8081)
+	failingStorage := newFailingStorage()
+	server := NewServer(service.New(failingStorage), 8081)
 	ctx := context.Background()
 
 	_, err := server.GetNote(ctx, "test-id")
@@ -374,8 +339,8 @@ func TestGetNoteError(t *testing.T) {
 
 // TestGetAllNotesError tests error handling in GetAllNotes
 func TestGetAllNotesError(t *testing.T) {
-	failingStorage := NewFailingMockStorage()
-	server := NewServer(failingStorage, 8081)
+	failingStorage := newFailingStorage()
+	server := NewServer(service.New(failingStorage), 8081)
 	ctx := context.Background()
 
 	_, err := server.GetAllNotes(ctx)
@@ -386,8 +351,8 @@ func TestGetAllNotesError(t *testing.T) {
 
 // TestUpdateNoteError tests error handling in UpdateNote
 func TestUpdateNoteError(t *testing.T) {
-	failingStorage := NewFailingMockStorage()
-	server := NewServer(failingStorage, 8081)
+	failingStorage := newFailingStorage()
+	server := NewServer(service.New(failingStorage), 8081)
 	ctx := context.Background()
 
 	_, err := server.UpdateNote(ctx, "test-id", "New Title", "New Content")
@@ -398,8 +363,8 @@ func TestUpdateNoteError(t *testing.T) {
 
 // TestDeleteNoteError tests error handling in DeleteNote
 func TestDeleteNoteError(t *testing.T) {
-	failingStorage := NewFailingMockStorage()
-	server := NewServer(failingStorage, 8081)
+	failingStorage := newFailingStorage()
+	server := NewServer(service.New(failingStorage), 8081)
 	ctx := context.Background()
 
 	err := server.DeleteNote(ctx, "test-id")
@@ -407,3 +372,167 @@ func TestDeleteNoteError(t *testing.T) {
 		t.Error("Expected error when deleting note with failing storage")
 	}
 }
+
+// TestContextWithMetadata tests request ID extraction from gRPC metadata
+func TestContextWithMetadata(t *testing.T) {
+	ctx := ContextWithMetadata(context.Background(), map[string][]string{
+		requestid.MetadataKey: {"grpc-req-1"},
+	})
+	if id := requestid.FromContext(ctx); id != "grpc-req-1" {
+		t.Errorf("Expected request ID 'grpc-req-1', got %q", id)
+	}
+
+	ctx = ContextWithMetadata(context.Background(), map[string][]string{
+		requestid.MetadataKey: {"not valid!"},
+	})
+	if id := requestid.FromContext(ctx); id == "" || id == "not valid!" {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+
+	ctx = ContextWithMetadata(context.Background(), nil)
+	if id := requestid.FromContext(ctx); id == "" {
+		t.Error("Expected a generated request ID without metadata")
+	}
+}
+
+// TestRPCSpans tests that RPC methods record server spans that join the client's trace
+func TestRPCSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	ctx := ContextWithMetadata(context.Background(), map[string][]string{
+		"traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+
+	server := NewServer(service.New(fake.New()), 0)
+	if _, err := server.GetAllNotes(ctx); err != nil {
+		t.Fatalf("GetAllNotes failed: %v", err)
+	}
+	if _, err := NewServer(service.New(newFailingStorage()), 0).GetAllNotes(ctx); err == nil {
+		t.Fatal("Expected GetAllNotes to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name() != "notes.Notes/GetAllNotes" {
+			t.Errorf("Expected span name 'notes.Notes/GetAllNotes', got %q", span.Name())
+		}
+		if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected span to join the client's trace, got trace ID %s", got)
+		}
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("Expected successful RPC span not to be marked as failed")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Error("Expected failed RPC span to be marked as failed")
+	}
+}
+
+// TestRPCMetrics tests that RPCs are counted by method and status code
+func TestRPCMetrics(t *testing.T) {
+	server := NewServer(service.New(fake.New()), 0)
+	ctx := context.Background()
+	count := func(method, code string) float64 {
+		return testutil.ToFloat64(metrics.GRPCRequests.WithLabelValues(method, code))
+	}
+	before := map[string]float64{
+		"OK":              count("UpdateNote", "OK"),
+		"NotFound":        count("UpdateNote", "NotFound"),
+		"InvalidArgument": count("UpdateNote", "InvalidArgument"),
+		"Internal":        count("DeleteNote", "Internal"),
+	}
+
+	note, err := server.CreateNote(ctx, "Title", "Content")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if _, err := server.UpdateNote(ctx, note.ID, "New title", "Content"); err != nil {
+		t.Fatalf("UpdateNote failed: %v", err)
+	}
+	if _, err := server.UpdateNote(ctx, "missing", "Title", "Content"); err == nil {
+		t.Fatal("Expected updating a missing note to fail")
+	}
+	if _, err := server.UpdateNote(ctx, note.ID, "", ""); err == nil {
+		t.Fatal("Expected emptying a note to fail")
+	}
+	if err := NewServer(service.New(newFailingStorage()), 0).DeleteNote(ctx, note.ID); err == nil {
+		t.Fatal("Expected DeleteNote to fail")
+	}
+
+	for code, value := range before {
+		method := "UpdateNote"
+		if code == "Internal" {
+			method = "DeleteNote"
+		}
+		if got := count(method, code); got != value+1 {
+			t.Errorf("Expected one more %s call with code %s, got %v (was %v)", method, code, got, value)
+		}
+	}
+}
+
+// blockingStorage blocks Get until release is closed.
+type blockingStorage struct {
+	*fake.Storage
+	entered chan struct{}
+	release chan struct{}
+}
+
+// Get signals that it was called and waits for the release
+func (s *blockingStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.Storage.Get(ctx, id)
+}
+
+// TestShutdown tests that Shutdown waits for in-flight RPCs and rejects new ones
+func TestShutdown(t *testing.T) {
+	blocking := &blockingStorage{Storage: fake.New(), entered: make(chan struct{}), release: make(chan struct{})}
+	server := NewServer(service.New(blocking), 0)
+	ctx := context.Background()
+
+	getErr := make(chan error, 1)
+	go func() {
+		_, err := server.GetNote(ctx, "note-1")
+		getErr <- err
+	}()
+	<-blocking.entered
+
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(ctx) }()
+
+	// New RPCs are rejected as soon as the shutdown has started
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := server.GetAllNotes(ctx)
+		if errors.Is(err, errShuttingDown) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected new RPCs to be rejected, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Shutdown to wait for the in-flight RPC, got %v", err)
+	default:
+	}
+
+	close(blocking.release)
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if err := <-getErr; !errors.Is(err, errNoteNotFound) {
+		t.Errorf("Expected the in-flight RPC to complete, got %v", err)
+	}
+}
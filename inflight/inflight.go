@@ -0,0 +1,96 @@
+// Package inflight counts the requests a server is handling, so that a graceful shutdown
+// can stop accepting new requests, wait for the in-flight ones to finish, and report its
+// progress while it waits.
+package inflight
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// pollInterval is how often Wait checks whether all requests have finished
+	pollInterval = 10 * time.Millisecond
+
+	// progressInterval is how often Wait logs the number of requests it is still waiting for
+	progressInterval = time.Second
+)
+
+// Tracker counts in-flight requests. The zero value is ready to use.
+type Tracker struct {
+	count    atomic.Int64
+	draining atomic.Bool
+}
+
+// Add registers a new request, unless the tracker is draining.
+// Every successful Add must be followed by a call to Done once the request is handled.
+//
+// Returns:
+//   - false if the request must be rejected because the server is shutting down
+func (t *Tracker) Add() bool {
+	// Count first, so Drain either sees the request or the request sees Drain
+	t.count.Add(1)
+	if t.draining.Load() {
+		t.count.Add(-1)
+		return false
+	}
+	return true
+}
+
+// Done marks a request registered with Add as handled.
+func (t *Tracker) Done() {
+	t.count.Add(-1)
+}
+
+// Count returns the number of requests being handled.
+func (t *Tracker) Count() int {
+	return int(t.count.Load())
+}
+
+// Drain makes Add reject new requests, then waits for the in-flight ones (see Wait).
+//
+// Parameters:
+//   - ctx: The context bounding the wait
+//   - name: The name of the server, used in log messages (e.g., "gRPC")
+//
+// Returns:
+//   - An error if ctx ends before all requests have finished
+func (t *Tracker) Drain(ctx context.Context, name string) error {
+	t.draining.Store(true)
+	return t.Wait(ctx, name)
+}
+
+// Wait waits until no requests are in flight, logging how many are left every second.
+// Unlike Drain, it doesn't reject new requests, for servers that stop accepting them
+// on their own (e.g., http.Server.Shutdown).
+//
+// Parameters:
+//   - ctx: The context bounding the wait
+//   - name: The name of the server, used in log messages (e.g., "REST")
+//
+// Returns:
+//   - An error if ctx ends before all requests have finished
+func (t *Tracker) Wait(ctx context.Context, name string) error {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	progress := time.NewTicker(progressInterval)
+	defer progress.Stop()
+
+	start := time.Now()
+	for {
+		count := t.Count()
+		if count == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d %s requests still in flight: %w", count, name, ctx.Err())
+		case <-progress.C:
+			log.Printf("Waiting for %d in-flight %s requests (%v elapsed)", count, name, time.Since(start).Round(time.Second))
+		case <-poll.C:
+		}
+	}
+}
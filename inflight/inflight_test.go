@@ -0,0 +1,65 @@
+package inflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTrackerDrain tests that draining rejects new requests and waits for the in-flight ones
+func TestTrackerDrain(t *testing.T) {
+	previous := progressInterval
+	progressInterval = 20 * time.Millisecond
+	t.Cleanup(func() { progressInterval = previous })
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	var tracker Tracker
+	if !tracker.Add() || !tracker.Add() {
+		t.Fatal("Expected requests to be accepted")
+	}
+	if tracker.Count() != 2 {
+		t.Fatalf("Expected 2 requests in flight, got %d", tracker.Count())
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tracker.Done()
+		tracker.Done()
+	}()
+	if err := tracker.Drain(context.Background(), "test"); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if tracker.Add() {
+		t.Error("Expected requests to be rejected while draining")
+	}
+	if tracker.Count() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", tracker.Count())
+	}
+	if !strings.Contains(logs.String(), "Waiting for 2 in-flight test requests") {
+		t.Errorf("Expected progress to be logged, got %q", logs.String())
+	}
+}
+
+// TestTrackerWaitTimeout tests that waiting ends with the context, reporting the remaining requests
+func TestTrackerWaitTimeout(t *testing.T) {
+	var tracker Tracker
+	tracker.Add()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := tracker.Wait(ctx, "REST")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "1 REST requests still in flight") {
+		t.Errorf("Expected a timeout reporting the remaining request, got %v", err)
+	}
+	// Waiting doesn't reject new requests
+	if !tracker.Add() {
+		t.Error("Expected requests to be accepted after Wait")
+	}
+}
@@ -0,0 +1,372 @@
+// Package jobs runs background work, such as webhook deliveries and asynchronous imports,
+// on a fixed pool of workers fed by an in-process queue. Failed attempts are retried with
+// exponential backoff, and the status of recent jobs is kept for the admin API. Jobs are
+// kept in memory, so jobs still queued when the application stops are lost.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"    // Waiting for a worker, or for its next attempt
+	StatusRunning   = "running"   // An attempt is in progress
+	StatusSucceeded = "succeeded" // An attempt succeeded
+	StatusFailed    = "failed"    // Every attempt failed, or the job was abandoned at shutdown
+)
+
+// maxFinished is the number of most recent finished jobs kept for the status endpoint.
+const maxFinished = 200
+
+var (
+	// ErrQueueFull is returned when a job is submitted while every queue slot is taken.
+	ErrQueueFull = errors.New("job queue is full")
+
+	// ErrClosed is returned when a job is submitted after the runner has been closed.
+	ErrClosed = errors.New("job runner is closed")
+
+	// ErrJobNotFound is returned when a job with the specified ID doesn't exist, or is
+	// no longer kept.
+	ErrJobNotFound = errors.New("job not found")
+
+	// errAbandoned is the error of the jobs still queued when the runner stops.
+	errAbandoned = errors.New("abandoned at shutdown")
+)
+
+// Task describes the work of a job.
+type Task struct {
+	Kind  string                                 // Kind of work, for status and metrics (e.g., "webhook")
+	Retry storage.RetryPolicy                    // Attempts, backoff, and timeouts; the zero value makes a single attempt
+	Run   func(ctx context.Context) (any, error) // Makes a single attempt; its result is shown in the job status
+	Done  func(result any, err error)            // Called once the job has succeeded or failed (optional)
+}
+
+// Job is the status of a submitted task.
+type Job struct {
+	ID          string     `json:"id"`                     // Unique identifier
+	Kind        string     `json:"kind"`                   // Kind of work (see Task.Kind)
+	Status      string     `json:"status"`                 // StatusQueued, StatusRunning, StatusSucceeded, or StatusFailed
+	Attempts    int        `json:"attempts"`               // Number of attempts started so far
+	LastError   string     `json:"last_error,omitempty"`   // Why the last attempt failed
+	Result      any        `json:"result,omitempty"`       // Result of the last attempt, if any
+	CreatedAt   time.Time  `json:"created_at"`             // When the job was submitted
+	RetryAt     *time.Time `json:"retry_at,omitempty"`     // When the next attempt is due, while waiting for it
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // When the job succeeded or was given up
+}
+
+// entry is a job in the queue, with what its attempts need.
+type entry struct {
+	id       string
+	task     Task
+	ctx      context.Context // Context of the submitter, without its cancellation
+	deadline time.Time       // End of the retry policy's deadline; zero means none
+}
+
+// Runner runs jobs on a fixed number of workers. It is safe for concurrent use.
+type Runner struct {
+	queue   chan *entry
+	ctx     context.Context    // Canceled to stop the workers and abandon the retries
+	cancel  context.CancelFunc // Cancels ctx
+	workers sync.WaitGroup     // Running workers
+	waiting sync.WaitGroup     // Jobs waiting for their next attempt
+	pending sync.WaitGroup     // Jobs that haven't finished
+
+	mutex    sync.RWMutex
+	closed   bool
+	jobs     map[string]*Job // Unfinished and recent finished jobs by ID
+	finished []string        // IDs of the recent finished jobs, oldest first
+}
+
+// NewRunner creates a runner and starts its workers.
+//
+// Parameters:
+//   - workers: The number of jobs run at once (at least one)
+//   - queueSize: The number of jobs that can wait for a worker; more are rejected with ErrQueueFull
+//
+// Returns:
+//   - A pointer to a new Runner instance
+func NewRunner(workers, queueSize int) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{
+		queue:  make(chan *entry, max(queueSize, 1)),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*Job),
+	}
+	for range max(workers, 1) {
+		r.workers.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+// Submit queues a task. Its attempts run with a context that keeps the values of ctx
+// (e.g., the request ID), but isn't canceled with it.
+//
+// Returns:
+//   - The status of the new job
+//   - ErrQueueFull if the queue is full, or ErrClosed if the runner has been closed
+func (r *Runner) Submit(ctx context.Context, task Task) (Job, error) {
+	job := &Job{ID: newJobID(), Kind: task.Kind, Status: StatusQueued, CreatedAt: time.Now().UTC()}
+	e := &entry{id: job.ID, task: task, ctx: context.WithoutCancel(ctx)}
+	if task.Retry.Deadline > 0 {
+		e.deadline = job.CreatedAt.Add(task.Retry.Deadline)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return Job{}, ErrClosed
+	}
+	select {
+	case r.queue <- e:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	r.jobs[job.ID] = job
+	r.pending.Add(1)
+	metrics.JobsQueueLength.Set(float64(len(r.queue)))
+	return *job, nil
+}
+
+// Get returns the status of a job.
+// It returns ErrJobNotFound if the job doesn't exist or is no longer kept.
+func (r *Runner) Get(id string) (Job, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// List returns the unfinished and recent finished jobs, newest first.
+//
+// Parameters:
+//   - kind: If not empty, only the jobs of this kind are returned
+//   - status: If not empty, only the jobs with this status are returned
+func (r *Runner) List(kind, status string) []Job {
+	r.mutex.RLock()
+	jobs := []Job{}
+	for _, job := range r.jobs {
+		if (kind == "" || job.Kind == kind) && (status == "" || job.Status == status) {
+			jobs = append(jobs, *job)
+		}
+	}
+	r.mutex.RUnlock()
+
+	slices.SortFunc(jobs, func(a, b Job) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return jobs
+}
+
+// Close stops accepting jobs and waits until the submitted ones have finished, including
+// their retries. If the context is done first, attempts in progress are canceled, and the
+// jobs still queued or waiting for a retry are abandoned and marked as failed, and the
+// context's error is returned.
+func (r *Runner) Close(ctx context.Context) error {
+	r.mutex.Lock()
+	r.closed = true
+	r.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	r.cancel()
+	r.workers.Wait()
+	r.waiting.Wait()
+
+	// Nothing adds to the queue anymore
+	for {
+		select {
+		case e := <-r.queue:
+			r.finish(e, nil, errAbandoned)
+		default:
+			metrics.JobsQueueLength.Set(0)
+			return err
+		}
+	}
+}
+
+// work runs queued jobs until the runner is stopped.
+func (r *Runner) work() {
+	defer r.workers.Done()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case e := <-r.queue:
+			metrics.JobsQueueLength.Set(float64(len(r.queue)))
+			r.attempt(e)
+		}
+	}
+}
+
+// attempt makes an attempt of a job, and finishes the job or schedules its next attempt.
+func (r *Runner) attempt(e *entry) {
+	var attempt int
+	r.update(e.id, func(job *Job) {
+		job.Status = StatusRunning
+		job.Attempts++
+		job.RetryAt = nil
+		attempt = job.Attempts
+	})
+
+	result, err := r.run(e)
+	if err == nil {
+		r.finish(e, result, nil)
+		return
+	}
+	r.update(e.id, func(job *Job) {
+		job.LastError = err.Error()
+		job.Result = result
+	})
+
+	maxAttempts := max(e.task.Retry.MaxAttempts, 1)
+	delay := e.task.Retry.Delay(attempt)
+	retryAt := time.Now().UTC().Add(delay)
+	switch {
+	case attempt >= maxAttempts:
+		r.finish(e, result, fmt.Errorf("failed after %d attempts: %w", attempt, err))
+		return
+	case r.ctx.Err() != nil:
+		r.finish(e, result, fmt.Errorf("failed after %d attempts (%w): %w", attempt, errAbandoned, err))
+		return
+	case !e.deadline.IsZero() && retryAt.After(e.deadline):
+		r.finish(e, result, fmt.Errorf("failed after %d attempts (deadline exceeded): %w", attempt, err))
+		return
+	}
+
+	log.Printf("%sAttempt %d/%d of %s job %s failed: %v; retrying in %v", requestid.LogPrefix(e.ctx),
+		attempt, maxAttempts, e.task.Kind, e.id, err, delay.Round(time.Millisecond))
+	r.update(e.id, func(job *Job) {
+		job.Status = StatusQueued
+		job.RetryAt = &retryAt
+	})
+	r.retryAfter(e, delay)
+}
+
+// run calls the task of a job, with a context canceled when the runner is stopped, the
+// attempt timeout passes, or the deadline of the retry policy passes.
+func (r *Runner) run(e *entry) (any, error) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	stop := context.AfterFunc(r.ctx, cancel)
+	defer stop()
+
+	if !e.deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, e.deadline)
+		defer cancelDeadline()
+	}
+	if e.task.Retry.AttemptTimeout > 0 {
+		var cancelAttempt context.CancelFunc
+		ctx, cancelAttempt = context.WithTimeout(ctx, e.task.Retry.AttemptTimeout)
+		defer cancelAttempt()
+	}
+	return e.task.Run(ctx)
+}
+
+// retryAfter queues a job again once the delay has passed, without holding up a worker.
+// If the runner is stopped first, the job is abandoned.
+func (r *Runner) retryAfter(e *entry, delay time.Duration) {
+	r.waiting.Add(1)
+	go func() {
+		defer r.waiting.Done()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			r.finish(e, nil, errAbandoned)
+			return
+		}
+		select {
+		case r.queue <- e:
+			metrics.JobsQueueLength.Set(float64(len(r.queue)))
+		case <-r.ctx.Done():
+			r.finish(e, nil, errAbandoned)
+		}
+	}()
+}
+
+// finish records the outcome of a job, discarding the oldest finished job if too many
+// are kept, and calls the Done function of its task.
+func (r *Runner) finish(e *entry, result any, err error) {
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+		log.Printf("%sGiving up %s job %s: %v", requestid.LogPrefix(e.ctx), e.task.Kind, e.id, err)
+	}
+
+	now := time.Now().UTC()
+	r.mutex.Lock()
+	if job, ok := r.jobs[e.id]; ok {
+		job.Status = status
+		job.Result = result
+		job.RetryAt = nil
+		job.CompletedAt = &now
+		if err != nil {
+			job.LastError = err.Error()
+		}
+	}
+	r.finished = append(r.finished, e.id)
+	if len(r.finished) > maxFinished {
+		delete(r.jobs, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+	r.mutex.Unlock()
+	metrics.JobsCompleted.WithLabelValues(e.task.Kind, status).Inc()
+
+	if e.task.Done != nil {
+		e.task.Done(result, err)
+	}
+	r.pending.Done()
+}
+
+// update changes the status of a job.
+func (r *Runner) update(id string, fn func(job *Job)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if job, ok := r.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// newJobID generates a random identifier for a job.
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
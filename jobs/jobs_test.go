@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-simple-notes/storage"
+)
+
+// testRetryPolicy retries quickly, so failing jobs don't slow down the tests
+var testRetryPolicy = storage.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// TestRunner tests that jobs are retried until they succeed or the retry policy gives up
+func TestRunner(t *testing.T) {
+	r := NewRunner(2, 10)
+
+	var calls atomic.Int32
+	done := make(chan error, 2)
+	flaky, err := r.Submit(context.Background(), Task{
+		Kind:  "flaky",
+		Retry: testRetryPolicy,
+		Run: func(ctx context.Context) (any, error) {
+			if calls.Add(1) == 1 {
+				return nil, errors.New("temporary failure")
+			}
+			return "done", nil
+		},
+		Done: func(result any, err error) { done <- err },
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if flaky.Status != StatusQueued || flaky.Kind != "flaky" || flaky.CreatedAt.Location() != time.UTC {
+		t.Errorf("Expected a queued job created in UTC, got %+v", flaky)
+	}
+	broken, err := r.Submit(context.Background(), Task{
+		Kind:  "broken",
+		Retry: testRetryPolicy,
+		Run:   func(ctx context.Context) (any, error) { return nil, errors.New("permanent failure") },
+		Done:  func(result any, err error) { done <- err },
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(done) != 2 {
+		t.Fatalf("Expected both jobs to be done, got %d", len(done))
+	}
+
+	job, err := r.Get(flaky.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if job.Status != StatusSucceeded || job.Attempts != 2 || job.Result != "done" || job.CompletedAt == nil || job.CompletedAt.Location() != time.UTC {
+		t.Errorf("Expected a successful second attempt, got %+v", job)
+	}
+	job, _ = r.Get(broken.ID)
+	if job.Status != StatusFailed || job.Attempts != testRetryPolicy.MaxAttempts || job.LastError == "" {
+		t.Errorf("Expected a failed job, got %+v", job)
+	}
+
+	if got := r.List("", ""); len(got) != 2 {
+		t.Errorf("Expected two jobs, got %+v", got)
+	}
+	if got := r.List("", StatusFailed); len(got) != 1 || got[0].ID != broken.ID {
+		t.Errorf("Expected the failed job, got %+v", got)
+	}
+	if got := r.List("flaky", ""); len(got) != 1 || got[0].ID != flaky.ID {
+		t.Errorf("Expected the flaky job, got %+v", got)
+	}
+	if _, err := r.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+	if _, err := r.Submit(context.Background(), Task{Kind: "late"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+// TestRunner_QueueFull tests that jobs beyond the queue size are rejected
+func TestRunner_QueueFull(t *testing.T) {
+	r := NewRunner(1, 1)
+	started, unblock := make(chan struct{}), make(chan struct{})
+	block := Task{Kind: "block", Run: func(ctx context.Context) (any, error) {
+		started <- struct{}{}
+		<-unblock
+		return nil, nil
+	}}
+
+	if _, err := r.Submit(context.Background(), block); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+	// The worker is busy, so the next job waits in the only queue slot
+	if _, err := r.Submit(context.Background(), Task{Kind: "queued", Run: block.Run}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if _, err := r.Submit(context.Background(), Task{Kind: "rejected"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	close(unblock)
+	<-started
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+// TestRunner_Close_Timeout tests that jobs still running or waiting for a retry are
+// abandoned when Close times out
+func TestRunner_Close_Timeout(t *testing.T) {
+	r := NewRunner(1, 10)
+	var abandoned atomic.Int32
+	task := Task{
+		Kind:  "slow",
+		Retry: storage.RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour},
+		Run: func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		Done: func(result any, err error) {
+			if err != nil {
+				abandoned.Add(1)
+			}
+		},
+	}
+	for range 3 {
+		if _, err := r.Submit(context.Background(), task); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the close to time out, got %v", err)
+	}
+	if got := abandoned.Load(); got != 3 {
+		t.Errorf("Expected every job to be abandoned, got %d", got)
+	}
+	if got := r.List("", StatusFailed); len(got) != 3 {
+		t.Errorf("Expected every job to be failed, got %+v", got)
+	}
+}
+
+// TestRunner_AttemptTimeout tests that attempts are limited by the retry policy
+func TestRunner_AttemptTimeout(t *testing.T) {
+	r := NewRunner(1, 1)
+	job, err := r.Submit(context.Background(), Task{
+		Kind:  "stuck",
+		Retry: storage.RetryPolicy{AttemptTimeout: 10 * time.Millisecond},
+		Run: func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if job, _ = r.Get(job.ID); job.Status != StatusFailed || job.Attempts != 1 {
+		t.Errorf("Expected a single timed out attempt, got %+v", job)
+	}
+}
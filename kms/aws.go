@@ -0,0 +1,201 @@
+package kms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsEncryptionContext is bound to every wrapped data key; AWS KMS requires the same
+// context to unwrap it and records it in CloudTrail.
+var awsEncryptionContext = map[string]string{"purpose": "notes-data-key"}
+
+// AWSProvider wraps data keys with an AWS KMS key. Requests are signed with Signature
+// Version 4, using the credentials of AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// (for temporary credentials) AWS_SESSION_TOKEN.
+type AWSProvider struct {
+	keyID    string // Key ID, ARN, alias name, or alias ARN
+	region   string // Region of the key
+	endpoint string // URL of the KMS API
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	client *http.Client
+	now    func() time.Time // Clock for request signatures (replaced in tests)
+}
+
+// NewAWS creates a provider for an AWS KMS key. The region is taken from the key ARN,
+// or else from AWS_REGION or AWS_DEFAULT_REGION.
+//
+// Parameters:
+//   - keyID: The key ID, ARN, alias name (e.g., "alias/notes"), or alias ARN
+//   - endpoint: The URL of the KMS API; empty for the regional endpoint
+//
+// Returns:
+//   - A pointer to a new AWSProvider instance
+//   - An error if the region or the credentials are missing
+func NewAWS(keyID, endpoint string) (*AWSProvider, error) {
+	region := awsRegion(keyID)
+	if region == "" {
+		return nil, errors.New("AWS region is unknown: use a key ARN or set AWS_REGION")
+	}
+	p := &AWSProvider{
+		keyID:        keyID,
+		region:       region,
+		endpoint:     endpoint,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       newClient(),
+		now:          time.Now,
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, errors.New("AWS credentials are missing: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if p.endpoint == "" {
+		p.endpoint = "https://kms." + region + ".amazonaws.com/"
+	}
+	return p, nil
+}
+
+// KeyID returns the configured key.
+func (p *AWSProvider) KeyID() string {
+	return p.keyID
+}
+
+// WrapKey encrypts a data key with the KMS Encrypt operation.
+func (p *AWSProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	err := p.call(ctx, "Encrypt", map[string]any{
+		"KeyId":             p.keyID,
+		"Plaintext":         dataKey,
+		"EncryptionContext": awsEncryptionContext,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a data key with the KMS Decrypt operation.
+func (p *AWSProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	err := p.call(ctx, "Decrypt", map[string]any{
+		"KeyId":             keyID,
+		"CiphertextBlob":    wrapped,
+		"EncryptionContext": awsEncryptionContext,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// call invokes a KMS operation. Binary fields are base64 encoded by encoding/json,
+// as the KMS JSON protocol expects.
+func (p *AWSProvider) call(ctx context.Context, operation string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := newJSONRequest(ctx, p.endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+operation)
+	p.sign(req, body, p.now().UTC())
+
+	if err := doJSON(p.client, req, out); err != nil {
+		return fmt.Errorf("AWS KMS %s failed: %w", operation, err)
+	}
+	return nil
+}
+
+// sign adds a Signature Version 4 authorization to a request.
+func (p *AWSProvider) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+	}
+
+	// Canonical headers: the host and every header set above, lowercase and sorted
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + p.region + "/kms/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+p.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// awsRegion returns the region of a key ARN ("arn:aws:kms:<region>:<account>:key/<id>"),
+// or else the configured default region.
+func awsRegion(keyID string) string {
+	if parts := strings.Split(keyID, ":"); len(parts) >= 6 && parts[0] == "arn" && parts[3] != "" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// sha256Hex returns the hex-encoded SHA-256 hash of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
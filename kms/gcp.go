@@ -0,0 +1,146 @@
+package kms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcpMetadataHost is the host of the GCE metadata server, unless GCE_METADATA_HOST is set.
+const gcpMetadataHost = "metadata.google.internal"
+
+// GCPProvider wraps data keys with a Google Cloud KMS crypto key. Requests are
+// authorized with the access token in GOOGLE_OAUTH_ACCESS_TOKEN if it is set, or else
+// with a token of the service account attached to the instance (e.g., on GKE, Cloud
+// Run, or Compute Engine), fetched from the metadata server and refreshed before it expires.
+type GCPProvider struct {
+	keyName  string // Crypto key resource name
+	endpoint string // URL of the Cloud KMS API
+	client   *http.Client
+
+	staticToken  string // Access token from the environment; empty to use the metadata server
+	metadataHost string // Host of the metadata server
+
+	mutex   sync.Mutex
+	token   string    // Cached access token from the metadata server
+	expires time.Time // Expiry of the cached token
+}
+
+// NewGCP creates a provider for a Google Cloud KMS crypto key.
+//
+// Parameters:
+//   - keyName: The resource name of the crypto key
+//     ("projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>")
+//   - endpoint: The URL of the Cloud KMS API; empty for https://cloudkms.googleapis.com
+//
+// Returns:
+//   - A pointer to a new GCPProvider instance
+//   - An error if the key name is malformed
+func NewGCP(keyName, endpoint string) (*GCPProvider, error) {
+	if !strings.HasPrefix(keyName, "projects/") || !strings.Contains(keyName, "/cryptoKeys/") {
+		return nil, fmt.Errorf("invalid GCP crypto key name %q", keyName)
+	}
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	metadataHost := os.Getenv("GCE_METADATA_HOST")
+	if metadataHost == "" {
+		metadataHost = gcpMetadataHost
+	}
+	return &GCPProvider{
+		keyName:      keyName,
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		client:       newClient(),
+		staticToken:  os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+		metadataHost: metadataHost,
+	}, nil
+}
+
+// KeyID returns the crypto key resource name.
+func (p *GCPProvider) KeyID() string {
+	return p.keyName
+}
+
+// WrapKey encrypts a data key with the primary version of the crypto key.
+func (p *GCPProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := p.call(ctx, p.keyName+":encrypt", map[string]any{"plaintext": dataKey}, &out); err != nil {
+		return nil, err
+	}
+	return out.Ciphertext, nil
+}
+
+// UnwrapKey decrypts a data key; Cloud KMS finds the key version in the ciphertext.
+func (p *GCPProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := p.call(ctx, keyID+":decrypt", map[string]any{"ciphertext": wrapped}, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// call invokes a Cloud KMS method on a resource.
+func (p *GCPProvider) call(ctx context.Context, method string, in, out any) error {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a GCP access token: %w", err)
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := newJSONRequest(ctx, p.endpoint+"/v1/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if err := doJSON(p.client, req, out); err != nil {
+		return fmt.Errorf("GCP KMS %s failed: %w", method[strings.LastIndex(method, ":")+1:], err)
+	}
+	return nil
+}
+
+// accessToken returns the static access token, or a token from the metadata server.
+func (p *GCPProvider) accessToken(ctx context.Context) (string, error) {
+	if p.staticToken != "" {
+		return p.staticToken, nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// Refresh a minute early, so a token doesn't expire during a request
+	if p.token != "" && time.Now().Before(p.expires.Add(-time.Minute)) {
+		return p.token, nil
+	}
+
+	url := "http://" + p.metadataHost + "/computeMetadata/v1/instance/service-accounts/default/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doJSON(p.client, req, &out); err != nil {
+		return "", err
+	}
+	if out.AccessToken == "" {
+		return "", errors.New("the metadata server returned no access token")
+	}
+	p.token = out.AccessToken
+	p.expires = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	return p.token, nil
+}
@@ -0,0 +1,100 @@
+// Package kms implements storage.KeyProvider for external key management services:
+// AWS KMS, Google Cloud KMS, and HashiCorp Vault's transit secrets engine. They wrap the
+// data keys of envelope encryption at rest, so the keys that protect the notes never
+// leave the key management service. The providers call the services' HTTP APIs directly
+// and read credentials from each service's standard environment variables.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang-simple-notes/storage"
+)
+
+// Supported key management services.
+const (
+	ProviderAWS   = "aws"   // AWS KMS
+	ProviderGCP   = "gcp"   // Google Cloud KMS
+	ProviderVault = "vault" // HashiCorp Vault transit secrets engine
+)
+
+// requestTimeout bounds every call to a key management service.
+const requestTimeout = 10 * time.Second
+
+// maxErrorBody is the number of bytes of an error response included in errors.
+const maxErrorBody = 512
+
+// IsProvider reports whether name is a supported key management service.
+func IsProvider(name string) bool {
+	return name == ProviderAWS || name == ProviderGCP || name == ProviderVault
+}
+
+// New creates the key provider of a key management service. No request is made, so the
+// service doesn't have to be reachable yet.
+//
+// Parameters:
+//   - provider: The key management service (ProviderAWS, ProviderGCP, or ProviderVault)
+//   - key: The key that wraps the data keys: an AWS key ID, ARN, or alias; a GCP crypto
+//     key resource name; or a Vault transit key as "<mount>/<name>" or "<name>"
+//   - endpoint: The URL of the service; empty for the default (for Vault, VAULT_ADDR)
+//
+// Returns:
+//   - The key provider
+//   - An error if the provider is unknown or its credentials are missing
+func New(provider, key, endpoint string) (storage.KeyProvider, error) {
+	if key == "" {
+		return nil, fmt.Errorf("a key is required for %s", provider)
+	}
+	switch provider {
+	case ProviderAWS:
+		return NewAWS(key, endpoint)
+	case ProviderGCP:
+		return NewGCP(key, endpoint)
+	case ProviderVault:
+		return NewVault(key, endpoint)
+	default:
+		return nil, fmt.Errorf("unknown key management service %q (expected %s, %s, or %s)",
+			provider, ProviderAWS, ProviderGCP, ProviderVault)
+	}
+}
+
+// newClient returns the HTTP client for calls to a key management service.
+func newClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
+
+// doJSON sends a request and decodes its JSON response into out.
+// Responses other than 200 OK are returned as errors, with the start of their body.
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+// newJSONRequest creates a POST request with a JSON body.
+func newJSONRequest(ctx context.Context, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
@@ -0,0 +1,218 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reverse returns the bytes in reverse order; the fake services "encrypt" by reversing
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// roundTrip wraps and unwraps a data key with a provider
+func roundTrip(t *testing.T, p interface {
+	KeyID() string
+	WrapKey(context.Context, []byte) ([]byte, error)
+	UnwrapKey(context.Context, string, []byte) ([]byte, error)
+}) {
+	t.Helper()
+	ctx := context.Background()
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+
+	wrapped, err := p.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("WrapKey failed: %v", err)
+	}
+	if bytes.Contains(wrapped, dataKey) {
+		t.Errorf("Expected the data key to be wrapped, got %q", wrapped)
+	}
+	unwrapped, err := p.UnwrapKey(ctx, p.KeyID(), wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapKey failed: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("Expected the data key back, got %q", unwrapped)
+	}
+}
+
+// TestAWSProvider tests wrapping and unwrapping with the KMS JSON protocol and signed requests
+func TestAWSProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		wantCredential := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/eu-west-1/kms/aws4_request, " +
+			"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="
+		if !strings.HasPrefix(auth, wantCredential) || r.Header.Get("X-Amz-Security-Token") != "session" {
+			http.Error(w, `{"__type":"UnrecognizedClientException"}`, http.StatusBadRequest)
+			return
+		}
+
+		var in struct {
+			KeyId             string
+			Plaintext         []byte
+			CiphertextBlob    []byte
+			EncryptionContext map[string]string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.EncryptionContext["purpose"] != "notes-data-key" {
+			http.Error(w, `{"__type":"ValidationException"}`, http.StatusBadRequest)
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]any{"KeyId": in.KeyId, "CiphertextBlob": reverse(in.Plaintext)})
+		case "TrentService.Decrypt":
+			json.NewEncoder(w).Encode(map[string]any{"KeyId": in.KeyId, "Plaintext": reverse(in.CiphertextBlob)})
+		default:
+			http.Error(w, `{"__type":"UnknownOperationException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewAWS("arn:aws:kms:eu-west-1:111122223333:key/1234abcd", server.URL)
+	if err != nil {
+		t.Fatalf("NewAWS failed: %v", err)
+	}
+	p.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	roundTrip(t, p)
+
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := NewAWS("alias/notes", ""); err == nil {
+		t.Error("Expected an error without a region")
+	}
+}
+
+// TestGCPProvider tests wrapping and unwrapping with a token from the metadata server
+func TestGCPProvider(t *testing.T) {
+	const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/notes"
+	var tokenRequests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/computeMetadata/v1/instance/service-accounts/default/token" {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				http.Error(w, "missing header", http.StatusForbidden)
+				return
+			}
+			tokenRequests.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "expires_in": 3600})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error":{"code":401}}`, http.StatusUnauthorized)
+			return
+		}
+
+		var in struct {
+			Plaintext  []byte `json:"plaintext"`
+			Ciphertext []byte `json:"ciphertext"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/" + keyName + ":encrypt":
+			json.NewEncoder(w).Encode(map[string]any{"name": keyName + "/cryptoKeyVersions/1", "ciphertext": reverse(in.Plaintext)})
+		case "/v1/" + keyName + ":decrypt":
+			json.NewEncoder(w).Encode(map[string]any{"plaintext": reverse(in.Ciphertext)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	p, err := NewGCP(keyName, server.URL)
+	if err != nil {
+		t.Fatalf("NewGCP failed: %v", err)
+	}
+	roundTrip(t, p)
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("Expected the access token to be fetched once, got %d", n)
+	}
+
+	if _, err := NewGCP("notes", ""); err == nil {
+		t.Error("Expected an error for a key that is not a resource name")
+	}
+}
+
+// TestVaultProvider tests wrapping and unwrapping with the transit secrets engine on a nested mount
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "team" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/ops/transit/encrypt/notes":
+			plaintext, _ := base64.StdEncoding.DecodeString(in["plaintext"])
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(reverse(plaintext)),
+			}})
+		case "/v1/ops/transit/decrypt/notes":
+			ciphertext, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(in["ciphertext"], "vault:v1:"))
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+				"plaintext": base64.StdEncoding.EncodeToString(reverse(ciphertext)),
+			}})
+		default:
+			http.Error(w, `{"errors":["no handler for route"]}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("VAULT_NAMESPACE", "team")
+	p, err := New(ProviderVault, "ops/transit/notes", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	roundTrip(t, p)
+
+	// Errors carry the start of the response
+	if _, err := p.UnwrapKey(context.Background(), "ops/transit/missing", []byte("vault:v1:AAAA")); err == nil || !strings.Contains(err.Error(), "no handler for route") {
+		t.Errorf("Expected the Vault error message, got %v", err)
+	}
+}
+
+// TestNew tests the validation of providers and their credentials
+func TestNew(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	invalid := map[string][2]string{
+		"UnknownProvider": {"azure", "key"},
+		"MissingKey":      {ProviderVault, ""},
+		"AWSCredentials":  {ProviderAWS, "arn:aws:kms:eu-west-1:111122223333:key/1234abcd"},
+		"VaultToken":      {ProviderVault, "notes"},
+	}
+	for name, c := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(c[0], c[1], "http://localhost:8200"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
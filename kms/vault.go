@@ -0,0 +1,115 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultDefaultMount is the mount path of the transit secrets engine if the key doesn't name one.
+const vaultDefaultMount = "transit"
+
+// VaultProvider wraps data keys with a key of Vault's transit secrets engine. Requests
+// are authorized with the token in VAULT_TOKEN; VAULT_NAMESPACE selects a namespace
+// (Vault Enterprise). Rotating the transit key in Vault keeps older data keys readable.
+type VaultProvider struct {
+	key       string // Transit key as configured ("<mount>/<name>" or "<name>")
+	address   string // Address of the Vault server
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVault creates a provider for a Vault transit key.
+//
+// Parameters:
+//   - key: The transit key as "<mount path>/<name>", or "<name>" on the "transit" mount
+//   - address: The address of the Vault server; empty for VAULT_ADDR
+//
+// Returns:
+//   - A pointer to a new VaultProvider instance
+//   - An error if the address or the token is missing
+func NewVault(key, address string) (*VaultProvider, error) {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, errors.New("the Vault address is missing: set the endpoint or VAULT_ADDR")
+	}
+	p := &VaultProvider{
+		key:       key,
+		address:   strings.TrimSuffix(address, "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    newClient(),
+	}
+	if p.token == "" {
+		return nil, errors.New("the Vault token is missing: set VAULT_TOKEN")
+	}
+	return p, nil
+}
+
+// KeyID returns the transit key as configured.
+func (p *VaultProvider) KeyID() string {
+	return p.key
+}
+
+// WrapKey encrypts a data key with the latest version of the transit key.
+// The result is Vault's ciphertext ("vault:v<version>:...").
+func (p *VaultProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	in := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := p.call(ctx, "encrypt", p.key, in, &out); err != nil {
+		return nil, err
+	}
+	return []byte(out.Data.Ciphertext), nil
+}
+
+// UnwrapKey decrypts a data key with the transit key it was wrapped with.
+func (p *VaultProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	in := map[string]string{"ciphertext": string(wrapped)}
+	if err := p.call(ctx, "decrypt", keyID, in, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Plaintext)
+}
+
+// call invokes a transit operation ("encrypt" or "decrypt") with a key.
+func (p *VaultProvider) call(ctx context.Context, operation, key string, in, out any) error {
+	// Mount paths may be nested, so the key name follows the last slash
+	mount, name := vaultDefaultMount, key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		mount, name = key[:i], key[i+1:]
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := newJSONRequest(ctx, p.address+"/v1/"+mount+"/"+operation+"/"+name, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	if err := doJSON(p.client, req, out); err != nil {
+		return fmt.Errorf("vault transit %s failed: %w", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package logging configures the application's log output and log level.
+// Messages are written with log/slog; lines written with the standard log package
+// (e.g., log.Printf) are routed through the same handler at the info level,
+// so raising the level to "warn" or "error" silences routine messages.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// DefaultLevel is the log level used unless another one is configured.
+const DefaultLevel = "info"
+
+// level is the minimum level of messages that are written. It can be changed at any time.
+var level = new(slog.LevelVar)
+
+// Setup installs a text handler writing to w as the default logger,
+// filtering messages below the given level.
+//
+// Parameters:
+//   - w: The destination of log output (e.g., os.Stderr)
+//   - levelName: The minimum level: "debug", "info", "warn", or "error"
+//
+// Returns:
+//   - An error if the level name is not valid
+func Setup(w io.Writer, levelName string) error {
+	if err := SetLevel(levelName); err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+	return nil
+}
+
+// SetLevel changes the minimum level of messages that are written.
+func SetLevel(levelName string) error {
+	l, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level returns the name of the current minimum level (e.g., "info").
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// ParseLevel converts a level name ("debug", "info", "warn", or "error", case-insensitive) to a slog level.
+func ParseLevel(levelName string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(levelName)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q (use debug, info, warn, or error)", levelName)
+	}
+}
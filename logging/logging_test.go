@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"":        slog.LevelInfo,
+		"warn":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		" error ": slog.LevelError,
+	}
+	for name, want := range tests {
+		got, err := ParseLevel(name)
+		if err != nil {
+			t.Errorf("ParseLevel(%q) failed: %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", name, got, want)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		log.SetOutput(os.Stderr)
+		level.Set(slog.LevelInfo)
+	})
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "warn"); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if Level() != "warn" {
+		t.Errorf("Expected level 'warn', got %q", Level())
+	}
+
+	// Standard log lines are written at the info level, so they are filtered out
+	log.Printf("routine message")
+	slog.Warn("something odd")
+	if strings.Contains(buf.String(), "routine message") {
+		t.Errorf("Expected info messages to be filtered, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "something odd") {
+		t.Errorf("Expected warnings to be written, got: %s", buf.String())
+	}
+
+	// The level can be changed while running
+	buf.Reset()
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	log.Printf("routine message")
+	if !strings.Contains(buf.String(), "routine message") {
+		t.Errorf("Expected info messages after lowering the level, got: %s", buf.String())
+	}
+
+	if err := Setup(&buf, "loud"); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
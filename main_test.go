@@ -10,8 +10,7 @@ import (
 	"testing"
 	"time"
 
-	"golang-simple-notes/model"
-	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
 
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mongodb"
@@ -267,84 +266,11 @@ func startSharedCouchDBContainer(ctx context.Context) error {
 	return nil
 }
 
-// MockStorage is a simple implementation of NoteStorage for testing
-type MockStorage struct {
-	notes []*model.Note
-}
-
-func NewMockStorage() *MockStorage {
-	return &MockStorage{
-		notes: make([]*model.Note, 0),
-	}
-}
-
-func (s *MockStorage) Create(ctx context.Context, note *model.Note) error {
-	s.notes = append(s.notes, note)
-	return nil
-}
-
-func (s *MockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	for _, note := range s.notes {
-		if note.ID == id {
-			return note, nil
-		}
-	}
-	return nil, storage.ErrNoteNotFound
-}
-
-func (s *MockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	return s.notes, nil
-}
-
-func (s *MockStorage) Update(ctx context.Context, note *model.Note) error {
-	for i, n := range s.notes {
-		if n.ID == note.ID {
-			s.notes[i] = note
-			return nil
-		}
-	}
-	return storage.ErrNoteNotFound
-}
-
-func (s *MockStorage) Delete(ctx context.Context, id string) error {
-	for i, note := range s.notes {
-		if note.ID == id {
-			s.notes = append(s.notes[:i], s.notes[i+1:]...)
-			return nil
-		}
-	}
-	return storage.ErrNoteNotFound
-}
-
-func (s *MockStorage) Close(ctx context.Context) error {
-	return nil
-}
-
-// ErrorMockStorage is a mock storage that returns an error on Create
-type ErrorMockStorage struct{}
-
-func (s *ErrorMockStorage) Create(ctx context.Context, note *model.Note) error {
-	return fmt.Errorf("mock error")
-}
-
-func (s *ErrorMockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	return nil, storage.ErrNoteNotFound
-}
-
-func (s *ErrorMockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	return nil, nil
-}
-
-func (s *ErrorMockStorage) Update(ctx context.Context, note *model.Note) error {
-	return storage.ErrNoteNotFound
-}
-
-func (s *ErrorMockStorage) Delete(ctx context.Context, id string) error {
-	return storage.ErrNoteNotFound
-}
-
-func (s *ErrorMockStorage) Close(ctx context.Context) error {
-	return nil
+// newCreateFailingStorage returns a fake storage whose creations fail
+func newCreateFailingStorage() *fake.Storage {
+	backend := fake.New()
+	backend.Fail(fmt.Errorf("mock error"), fake.Create)
+	return backend
 }
 
 type MyLogConsumer struct{}
@@ -0,0 +1,421 @@
+// Package metrics defines the Prometheus collectors exported by the Notes API.
+// All collectors are registered with a package-level registry so that the
+// application exposes a single, well-defined set of metrics on /metrics,
+// independent of whatever else may be registered with the default registry.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// namespace is the common prefix of every metric exported by the application.
+const namespace = "notes"
+
+// Registry is the Prometheus registry holding all application metrics.
+var Registry = prometheus.NewRegistry()
+
+// requestDurationBuckets are the buckets of the request duration histograms, in seconds.
+var requestDurationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+var (
+	// HTTPRequests counts REST requests by method, route pattern (e.g., "/api/notes/{id}"),
+	// and status code, for request rates and error ratios.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of REST requests by method, route, and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPRequestDuration records how long REST requests take by method and route pattern.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of REST requests in seconds by method and route.",
+		Buckets:   requestDurationBuckets,
+	}, []string{"method", "route"})
+
+	// HTTPRequestsShed counts REST requests rejected with 503 because too many requests were
+	// in flight, by class ("read" or "write").
+	HTTPRequestsShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "shed_requests_total",
+		Help:      "Number of REST requests rejected because too many requests were in flight by class.",
+	}, []string{"class"})
+
+	// HTTPCacheRequests counts the list, count, and search requests looked up in the REST
+	// response cache by result ("hit" or "miss").
+	HTTPCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "cache_requests_total",
+		Help:      "Number of REST response cache lookups by result.",
+	}, []string{"result"})
+
+	// GRPCRequests counts gRPC calls by method (e.g., "GetNote") and status code (e.g., "NotFound").
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of gRPC calls by method and status code.",
+	}, []string{"method", "code"})
+
+	// GRPCRequestDuration records how long gRPC calls take by method.
+	GRPCRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of gRPC calls in seconds by method.",
+		Buckets:   requestDurationBuckets,
+	}, []string{"method"})
+
+	// EncryptionNotes reports how many notes are currently encrypted with each key ID.
+	// Notes that have not been encrypted yet are reported with key_id="plaintext".
+	// Any series other than the active key shows data that still needs rotation.
+	EncryptionNotes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "encryption",
+		Name:      "notes",
+		Help:      "Number of notes encrypted with each key ID.",
+	}, []string{"key_id"})
+
+	// EncryptionBytes reports the size of the encrypted payloads stored under each key ID.
+	EncryptionBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "encryption",
+		Name:      "bytes",
+		Help:      "Size in bytes of note payloads stored under each key ID.",
+	}, []string{"key_id"})
+
+	// EncryptionRotations counts notes re-encrypted with the active key, by rotation mode.
+	EncryptionRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "encryption",
+		Name:      "rotations_total",
+		Help:      "Number of notes re-encrypted with the active key.",
+	}, []string{"mode"})
+
+	// DualWriteVerifications counts dual-write verifications by result:
+	// "match", "mismatch", "error" (a backend couldn't be read), or "dropped" (queue full).
+	DualWriteVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "dualwrite",
+		Name:      "verifications_total",
+		Help:      "Number of dual-write verifications by result.",
+	}, []string{"result"})
+
+	// StorageFallbacks counts fallbacks to in-memory storage because the configured
+	// backend ("couchdb" or "mongodb") was unreachable.
+	StorageFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "fallbacks_total",
+		Help:      "Number of fallbacks to in-memory storage by configured backend.",
+	}, []string{"backend"})
+
+	// StorageFallbackActive is 1 while notes are stored in memory instead of the configured
+	// backend, and would be lost on restart; it is meant for alerting.
+	StorageFallbackActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "fallback_active",
+		Help:      "Whether notes are stored in memory because the configured backend is unreachable (1) or not (0).",
+	})
+
+	// StorageBackend has a single series, with value 1, identifying the backend that notes are
+	// currently stored in ("memory", "couchdb", or "mongodb") and the configured backend.
+	// The two differ while notes are stored in memory after a fallback.
+	StorageBackend = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "backend",
+		Help:      "Backend that notes are stored in (type) and configured backend (configured); always 1.",
+	}, []string{"type", "configured"})
+
+	// StorageReconnections counts attempts to switch from the in-memory fallback back
+	// to the configured backend, by result ("success" or "failure").
+	StorageReconnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "reconnections_total",
+		Help:      "Number of attempts to switch back from the in-memory fallback to the configured backend by result.",
+	}, []string{"result"})
+
+	// StorageCircuitState reports the state of the storage circuit breaker by backend:
+	// 0 (closed), 1 (open, operations are rejected), or 2 (half-open, probing the backend).
+	StorageCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "circuit_state",
+		Help:      "State of the storage circuit breaker by backend: 0 (closed), 1 (open), or 2 (half-open).",
+	}, []string{"backend"})
+
+	// StorageCircuitTransitions counts state changes of the storage circuit breaker
+	// by backend and new state ("closed", "open", or "half-open").
+	StorageCircuitTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "circuit_transitions_total",
+		Help:      "Number of storage circuit breaker state changes by backend and new state.",
+	}, []string{"backend", "state"})
+
+	// StorageCircuitRejections counts storage operations rejected by the circuit breaker
+	// without calling the backend.
+	StorageCircuitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "circuit_rejections_total",
+		Help:      "Number of storage operations rejected by the circuit breaker by backend.",
+	}, []string{"backend"})
+
+	// StorageCacheRequests counts lookups in the storage read cache by result ("hit" or "miss").
+	StorageCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "cache_requests_total",
+		Help:      "Number of storage read cache lookups by result.",
+	}, []string{"result"})
+
+	// StorageMemoryNotes and StorageMemoryBytes report the number and total size of the notes
+	// in in-memory storage, while it is bounded (see storage.InMemoryLimits).
+	StorageMemoryNotes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "memory_notes",
+		Help:      "Number of notes in bounded in-memory storage.",
+	})
+	StorageMemoryBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "memory_bytes",
+		Help:      "Approximate total size of the notes in bounded in-memory storage in bytes.",
+	})
+
+	// StorageMemoryEvictions counts notes deleted from in-memory storage to stay within its limits.
+	StorageMemoryEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "memory_evictions_total",
+		Help:      "Number of notes evicted from in-memory storage to stay within its limits.",
+	})
+
+	// StorageMemoryRejections counts writes rejected because in-memory storage reached its limits.
+	StorageMemoryRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "memory_rejections_total",
+		Help:      "Number of writes rejected because in-memory storage reached its limits.",
+	})
+
+	// StorageOperationDuration records how long storage operations take by backend,
+	// method (e.g., "Get"), and result ("success", "not_found", or "error").
+	StorageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of storage operations in seconds by backend, method, and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"backend", "method", "result"})
+
+	// ReplicationLag reports how far the replication secondary is behind the primary:
+	// the age of the last mirrored write while more writes are queued, and zero once caught up.
+	ReplicationLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "lag_seconds",
+		Help:      "Age in seconds of the last write mirrored to the secondary while more writes are queued.",
+	})
+
+	// ReplicationQueueLength reports the number of writes waiting to be mirrored to the secondary.
+	ReplicationQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "queue_length",
+		Help:      "Number of writes waiting to be mirrored to the secondary.",
+	})
+
+	// ReplicationWrites counts writes mirrored to the secondary by result:
+	// "applied", "failed", or "dropped" (queue full); failed and dropped writes are left for reconciliation.
+	ReplicationWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "writes_total",
+		Help:      "Number of writes mirrored to the secondary by result.",
+	}, []string{"result"})
+
+	// ReplicationRepairs counts notes repaired on the secondary by reconciliation,
+	// by action ("created", "updated", or "deleted").
+	ReplicationRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "repairs_total",
+		Help:      "Number of notes repaired on the secondary by reconciliation by action.",
+	}, []string{"action"})
+
+	// EventsPublished counts note events published to message brokers by broker (e.g., "kafka")
+	// and result: "success", "failure", or "dropped" (discarded before reaching the broker).
+	EventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "published_total",
+		Help:      "Number of note events published to message brokers by broker and result.",
+	}, []string{"broker", "result"})
+
+	// JobsQueueLength reports the number of background jobs waiting for a worker.
+	JobsQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "queue_length",
+		Help:      "Number of background jobs waiting for a worker.",
+	})
+
+	// JobsCompleted counts finished background jobs by kind (e.g., "webhook") and
+	// status ("succeeded" or "failed").
+	JobsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "completed_total",
+		Help:      "Number of finished background jobs by kind and status.",
+	}, []string{"kind", "status"})
+
+	// ScheduledRuns counts the runs of scheduled tasks by task (e.g., "backup") and result:
+	// "submitted", "skipped" (the previous run was still in progress), or "rejected" (the
+	// job queue was full or closed). The outcome of submitted runs is in JobsCompleted.
+	ScheduledRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "runs_total",
+		Help:      "Number of runs of scheduled tasks by task and result.",
+	}, []string{"task", "result"})
+
+	// StatsNotes reports the number of stored notes, as of the last run of the stats task.
+	StatsNotes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "stats",
+		Name:      "notes",
+		Help:      "Number of stored notes, as of the last stats recompute.",
+	})
+
+	// StatsContentBytes reports the total size of the stored note contents, as of the last
+	// run of the stats task.
+	StatsContentBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "stats",
+		Name:      "content_bytes",
+		Help:      "Total size of the stored note contents in bytes, as of the last stats recompute.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		HTTPRequestsShed,
+		HTTPCacheRequests,
+		GRPCRequests,
+		GRPCRequestDuration,
+		EncryptionNotes,
+		EncryptionBytes,
+		EncryptionRotations,
+		DualWriteVerifications,
+		StorageFallbacks,
+		StorageFallbackActive,
+		StorageBackend,
+		StorageReconnections,
+		StorageCircuitState,
+		StorageCircuitTransitions,
+		StorageCircuitRejections,
+		StorageCacheRequests,
+		StorageMemoryNotes,
+		StorageMemoryBytes,
+		StorageMemoryEvictions,
+		StorageMemoryRejections,
+		StorageOperationDuration,
+		ReplicationLag,
+		ReplicationQueueLength,
+		ReplicationWrites,
+		ReplicationRepairs,
+		EventsPublished,
+		JobsQueueLength,
+		JobsCompleted,
+		ScheduledRuns,
+		StatsNotes,
+		StatsContentBytes,
+	)
+}
+
+// Handler returns an HTTP handler serving all registered metrics in the Prometheus
+// text exposition format, or in the OpenMetrics format (which includes exemplars)
+// to scrapers that ask for it.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// ObserveHTTPRequest records a REST request in HTTPRequests and HTTPRequestDuration.
+// Requests that are part of a sampled trace are recorded with the trace ID as exemplar,
+// so dashboards can link from a latency spike to a matching trace.
+//
+// Parameters:
+//   - ctx: The context of the request, carrying its span
+//   - method: The HTTP method
+//   - route: The route pattern that matched the request
+//   - code: The status code of the response
+//   - duration: How long the request took
+func ObserveHTTPRequest(ctx context.Context, method, route string, code int, duration time.Duration) {
+	exemplar := traceExemplar(ctx)
+	addWithExemplar(HTTPRequests.WithLabelValues(method, route, strconv.Itoa(code)), exemplar)
+	observeWithExemplar(HTTPRequestDuration.WithLabelValues(method, route), duration, exemplar)
+}
+
+// ObserveGRPCRequest records a gRPC call in GRPCRequests and GRPCRequestDuration,
+// with the trace ID as exemplar like ObserveHTTPRequest.
+//
+// Parameters:
+//   - ctx: The context of the call, carrying its span
+//   - method: The RPC method (e.g., "GetNote")
+//   - code: The gRPC status code name (e.g., "OK" or "NotFound")
+//   - duration: How long the call took
+func ObserveGRPCRequest(ctx context.Context, method, code string, duration time.Duration) {
+	exemplar := traceExemplar(ctx)
+	addWithExemplar(GRPCRequests.WithLabelValues(method, code), exemplar)
+	observeWithExemplar(GRPCRequestDuration.WithLabelValues(method), duration, exemplar)
+}
+
+// traceExemplar returns the exemplar labels of the sampled span in ctx, or nil if there is none.
+func traceExemplar(ctx context.Context) prometheus.Labels {
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsValid() || !span.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": span.TraceID().String()}
+}
+
+// addWithExemplar increments a counter, attaching the exemplar if there is one.
+func addWithExemplar(counter prometheus.Counter, exemplar prometheus.Labels) {
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		adder.AddWithExemplar(1, exemplar)
+		return
+	}
+	counter.Inc()
+}
+
+// observeWithExemplar records a duration in a histogram, attaching the exemplar if there is one.
+func observeWithExemplar(histogram prometheus.Observer, duration time.Duration, exemplar prometheus.Labels) {
+	if observer, ok := histogram.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		observer.ObserveWithExemplar(duration.Seconds(), exemplar)
+		return
+	}
+	histogram.Observe(duration.Seconds())
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestHandler verifies that registered metrics are exposed in the text format
+func TestHandler(t *testing.T) {
+	EncryptionNotes.WithLabelValues("test-key").Set(3)
+	defer EncryptionNotes.DeleteLabelValues("test-key")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `notes_encryption_notes{key_id="test-key"} 3`) {
+		t.Errorf("Expected encryption gauge in output, got:\n%s", body)
+	}
+	if !strings.Contains(body, "go_goroutines") {
+		t.Error("Expected Go runtime metrics in output")
+	}
+}
+
+// TestObserveHTTPRequest verifies that requests of sampled traces carry the trace ID as exemplar
+func TestObserveHTTPRequest(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ObserveHTTPRequest(ctx, "GET", "/test/{id}", http.StatusOK, 30*time.Millisecond)
+	ObserveGRPCRequest(context.Background(), "TestMethod", "NotFound", time.Millisecond)
+	defer HTTPRequests.DeleteLabelValues("GET", "/test/{id}", "200")
+	defer HTTPRequestDuration.DeleteLabelValues("GET", "/test/{id}")
+	defer GRPCRequests.DeleteLabelValues("TestMethod", "NotFound")
+	defer GRPCRequestDuration.DeleteLabelValues("TestMethod")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, expected := range []string{
+		`notes_http_requests_total{code="200",method="GET",route="/test/{id}"} 1.0 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 1.0`,
+		`notes_http_request_duration_seconds_bucket{method="GET",route="/test/{id}",le="0.05"} 1 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.03`,
+		`notes_grpc_requests_total{code="NotFound",method="TestMethod"} 1.0` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %s in output, got:\n%s", expected, body)
+		}
+	}
+}
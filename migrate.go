@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// MigrationResult summarizes a copy of notes from one storage backend to another.
+type MigrationResult struct {
+	Copied      int // Notes created in the target backend
+	Overwritten int // Notes that already existed in the target backend and were overwritten
+	Failed      int // Notes that could not be copied
+}
+
+// Migrate copies all notes from the configured storage backend to the target backend.
+// Unlike Initialize, it never falls back to in-memory storage: copying from or to a
+// fallback would silently lose data, so connection failures are returned as errors.
+//
+// Parameters:
+//   - ctx: The context for the storage operations
+//   - target: The storage type to copy notes to ("couchdb", "mongodb", or "memory")
+//
+// Returns:
+//   - The number of copied, overwritten, and failed notes
+//   - An error if a backend cannot be connected to or the notes cannot be listed
+func (a *App) Migrate(ctx context.Context, target string) (MigrationResult, error) {
+	if target == a.config.StorageType {
+		return MigrationResult{}, fmt.Errorf("migration target must differ from the storage type %q", a.config.StorageType)
+	}
+
+	source, err := a.connectStorage(ctx, a.config.StorageType, a.config.retryPolicy())
+	if err != nil {
+		return MigrationResult{}, fmt.Errorf("failed to connect to source storage: %w", err)
+	}
+	defer closeStorage(ctx, source)
+
+	destination, err := a.connectStorage(ctx, target, a.config.retryPolicy())
+	if err != nil {
+		return MigrationResult{}, fmt.Errorf("failed to connect to target storage: %w", err)
+	}
+	defer closeStorage(ctx, destination)
+
+	log.Printf("Migrating notes: %s -> %s", a.config.StorageType, target)
+	return migrateNotes(ctx, source, destination)
+}
+
+// migrateNotes copies every note from source to destination, overwriting notes
+// that already exist in the destination. Notes are copied as stored, so encrypted
+// notes stay encrypted.
+func migrateNotes(ctx context.Context, source, destination storage.NoteStorage) (MigrationResult, error) {
+	var result MigrationResult
+
+	notes, err := source.GetAll(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	for _, stored := range notes {
+		// Revisions are specific to each backend; copy the note so the source is not modified
+		note := *stored
+		note.Rev = ""
+
+		err := destination.Create(ctx, &note)
+		if err == nil {
+			result.Copied++
+			continue
+		}
+		if !isDuplicateKeyError(err) {
+			log.Printf("Failed to copy note %s: %v", note.ID, err)
+			result.Failed++
+			continue
+		}
+
+		// The note already exists: overwrite it, using the destination's current revision
+		if err := overwriteNote(ctx, destination, &note); err != nil {
+			log.Printf("Failed to overwrite note %s: %v", note.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Overwritten++
+	}
+
+	return result, nil
+}
+
+// overwriteNote replaces an existing note in the storage.
+func overwriteNote(ctx context.Context, s storage.NoteStorage, note *model.Note) error {
+	existing, err := s.Get(ctx, note.ID)
+	if err != nil {
+		return err
+	}
+	note.Rev = existing.Rev
+	return s.Update(ctx, note)
+}
+
+// closeStorage closes a storage backend, logging any error.
+func closeStorage(ctx context.Context, s storage.NoteStorage) {
+	if err := s.Close(context.WithoutCancel(ctx)); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+func TestMigrateNotes(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewInMemoryStorage()
+	destination := storage.NewInMemoryStorage()
+
+	for _, id := range []string{"note-1", "note-2"} {
+		if err := source.Create(ctx, &model.Note{ID: id, Title: "Title " + id, Rev: "1-source"}); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	result, err := migrateNotes(ctx, source, destination)
+	if err != nil {
+		t.Fatalf("migrateNotes failed: %v", err)
+	}
+	if result.Copied != 2 || result.Failed != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	copied, err := destination.Get(ctx, "note-2")
+	if err != nil {
+		t.Fatalf("Failed to get copied note: %v", err)
+	}
+	if copied.Title != "Title note-2" || copied.Rev != "" {
+		t.Errorf("Expected the note without its source revision, got %+v", copied)
+	}
+
+	// Notes that cannot be created are counted as failed
+	result, err = migrateNotes(ctx, source, newCreateFailingStorage())
+	if err != nil {
+		t.Fatalf("migrateNotes failed: %v", err)
+	}
+	if result.Copied != 0 || result.Failed != 2 {
+		t.Errorf("Expected all notes to fail, got %+v", result)
+	}
+}
+
+func TestApp_MigrateToSameStorage(t *testing.T) {
+	app := NewApp(&Config{StorageType: "memory"})
+	if _, err := app.Migrate(context.Background(), "memory"); err == nil {
+		t.Error("Expected an error when migrating to the configured storage type")
+	}
+}
+
+func TestMigrateNotes_Overwrite(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewInMemoryStorage()
+	if err := source.Create(ctx, &model.Note{ID: "note-1", Title: "New"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// A destination that reports existing notes as duplicates, like CouchDB and MongoDB
+	destination := &duplicateStorage{InMemoryStorage: storage.NewInMemoryStorage()}
+	if err := destination.InMemoryStorage.Create(ctx, &model.Note{ID: "note-1", Title: "Old", Rev: "3-abc"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	result, err := migrateNotes(ctx, source, destination)
+	if err != nil {
+		t.Fatalf("migrateNotes failed: %v", err)
+	}
+	if result.Overwritten != 1 || result.Copied != 0 || result.Failed != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	note, _ := destination.Get(ctx, "note-1")
+	if note.Title != "New" || note.Rev != "3-abc" {
+		t.Errorf("Expected the note to be overwritten at the current revision, got %+v", note)
+	}
+}
+
+// duplicateStorage is an in-memory storage that rejects creating notes that already exist.
+type duplicateStorage struct {
+	*storage.InMemoryStorage
+}
+
+func (s *duplicateStorage) Create(ctx context.Context, note *model.Note) error {
+	if _, err := s.Get(ctx, note.ID); err == nil {
+		return errors.New("Document update conflict")
+	}
+	return s.InMemoryStorage.Create(ctx, note)
+}
@@ -4,7 +4,9 @@ package model
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -13,12 +15,22 @@ import (
 // The struct tags (`json:"..."` and `bson:"..."`) are used for JSON serialization
 // and MongoDB document mapping, respectively.
 type Note struct {
-	ID        string    `json:"_id" bson:"_id"`                       // Unique identifier for the note
-	Rev       string    `json:"_rev,omitempty" bson:"_rev,omitempty"` // Revision ID (used by CouchDB)
-	Title     string    `json:"title" bson:"title"`                   // Title of the note
-	Content   string    `json:"content" bson:"content"`               // Content/body of the note
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`         // When the note was created
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`         // When the note was last updated
+	ID        string    `json:"_id" bson:"_id"`                             // Unique identifier for the note
+	Rev       string    `json:"_rev,omitempty" bson:"_rev,omitempty"`       // Revision ID (used by CouchDB)
+	Title     string    `json:"title" bson:"title"`                         // Title of the note
+	Content   string    `json:"content" bson:"content"`                     // Content/body of the note
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`               // When the note was created
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`               // When the note was last updated
+	Owner     string    `json:"owner,omitempty" bson:"owner,omitempty"`     // Owner the note counts against, if quotas are enabled
+	Summary   string    `json:"summary,omitempty" bson:"summary,omitempty"` // Summary of the content, if it was summarized since its last change
+	Color     string    `json:"color,omitempty" bson:"color,omitempty"`     // Color of the note in clients, as #rrggbb in lower case (optional)
+	Icon      string    `json:"icon,omitempty" bson:"icon,omitempty"`       // Icon of the note in clients, one of service.Icons (optional)
+	Tags      []string  `json:"tags,omitempty" bson:"tags,omitempty"`       // Tags of the note, in lower case, sorted, and without duplicates (optional)
+
+	// Views of the note by clients, if they are counted. They are stored apart from the
+	// note, and only set on the notes returned to clients.
+	Views          int64     `json:"views,omitempty" bson:"-"`           // Number of times the note was viewed
+	LastAccessedAt time.Time `json:"last_accessed_at,omitzero" bson:"-"` // When the note was last viewed
 }
 
 // NewNote creates a new note with the given title and content.
@@ -27,7 +39,7 @@ type Note struct {
 //
 //	note := model.NewNote("Shopping List", "Milk, Eggs, Bread")
 func NewNote(title, content string) *Note {
-	now := time.Now() // Get the current time for timestamps
+	now := time.Now().UTC() // Get the current time for timestamps, stored in UTC
 	return &Note{
 		ID:        generateID(), // Generate a unique ID
 		Title:     title,
@@ -37,6 +49,40 @@ func NewNote(title, content string) *Note {
 	}
 }
 
+// MaxIDLength is the maximum length of a note ID.
+const MaxIDLength = 255
+
+// ValidateID checks a note ID chosen by a client (e.g., in a URL or of an imported note),
+// rather than generated: it must be 1 to MaxIDLength characters long and consist of letters,
+// digits, hyphens, underscores, and dots, like the generated IDs. Every transport and the
+// note service use it, so a note that can be stored can also be addressed.
+//
+// Returns:
+//   - nil if the ID is valid
+//   - An error describing what is wrong, which can be shown to clients
+func ValidateID(id string) error {
+	if id == "" {
+		return errors.New("_id is required")
+	}
+	if len(id) > MaxIDLength {
+		return fmt.Errorf("_id must be at most %d characters long", MaxIDLength)
+	}
+	if strings.IndexFunc(id, func(c rune) bool { return !isIDChar(c) }) >= 0 {
+		return errors.New("_id must only contain letters, digits, hyphens, underscores, and dots")
+	}
+	return nil
+}
+
+// isIDChar reports whether a character may appear in a note ID.
+func isIDChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' ||
+		c == '_' ||
+		c == '.'
+}
+
 // generateID creates a unique ID for a note based on the current timestamp and a random suffix.
 // The format used (year, month, day, hour, minute, second, microsecond) followed by
 // a random component ensures uniqueness even if multiple notes are created in the
@@ -37,6 +37,10 @@ func TestNewNote(t *testing.T) {
 	if note.UpdatedAt.After(now) || note.UpdatedAt.Before(now.Add(-time.Second)) {
 		t.Errorf("Expected UpdatedAt to be close to now, got %v", note.UpdatedAt)
 	}
+
+	if note.CreatedAt.Location() != time.UTC || note.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Expected timestamps in UTC, got %v and %v", note.CreatedAt, note.UpdatedAt)
+	}
 }
 
 func TestGenerateID(t *testing.T) {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/storage"
+)
+
+// startStorageReconnection starts trying to reconnect to the configured storage backend
+// in the background, every StorageReconnectInterval, until it succeeds.
+//
+// Returns:
+//   - A shutdown hook that stops the reconnection attempts
+func (a *App) startStorageReconnection() func(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(a.config.StorageReconnectInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if a.reconnectStorage(ctx) {
+					return
+				}
+			}
+		}
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// reconnectStorage tries to connect to the configured storage backend and, if it is
+// reachable, switches traffic from the in-memory fallback to it. If replay is enabled,
+// the notes written to memory in the meantime are copied to the backend first,
+// overwriting older versions; writes wait until the switch is complete.
+//
+// Returns:
+//   - true if traffic has been switched back to the configured backend
+func (a *App) reconnectStorage(ctx context.Context) bool {
+	storageType := a.config.StorageType
+
+	// A single attempt: the next one follows after the reconnection interval anyway
+	backend, err := a.connectStorage(ctx, storageType, storage.RetryPolicy{AttemptTimeout: a.config.StorageRetryAttemptTimeout})
+	if err != nil {
+		log.Printf("Storage %s is still unreachable: %v", storageType, err)
+		metrics.StorageReconnections.WithLabelValues("failure").Inc()
+		return false
+	}
+
+	var replay func(ctx context.Context, current, next storage.NoteStorage) error
+	if a.config.StorageReconnectReplay {
+		replay = func(ctx context.Context, current, next storage.NoteStorage) error {
+			result, err := migrateNotes(ctx, current, next)
+			if err != nil {
+				return err
+			}
+			if result.Failed > 0 {
+				return fmt.Errorf("failed to replay %d notes", result.Failed)
+			}
+			log.Printf("Replayed %d notes from memory to %s (%d overwritten)",
+				result.Copied+result.Overwritten, storageType, result.Overwritten)
+			return nil
+		}
+	}
+
+	previous, err := a.fallback.Switch(ctx, backend, replay)
+	if err != nil {
+		// Keep the notes in memory; the replay is retried with the next attempt
+		log.Printf("Failed to switch back to %s storage: %v", storageType, err)
+		metrics.StorageReconnections.WithLabelValues("failure").Inc()
+		closeStorage(ctx, backend)
+		return false
+	}
+	closeStorage(ctx, previous)
+
+	// The backend may hold notes that differ from the cached in-memory ones
+	if a.cache != nil {
+		a.cache.Clear(ctx)
+	}
+
+	log.Printf("Reconnected to %s storage; notes are no longer stored in memory", storageType)
+	metrics.StorageReconnections.WithLabelValues("success").Inc()
+	metrics.StorageFallbackActive.Set(0)
+	reportStorageBackend(storageType, storageType)
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApp_ReconnectStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Unreachable", func(t *testing.T) {
+		memory := storage.NewInMemoryStorage()
+		app := NewApp(&Config{StorageType: "couchdb", CouchDBURL: "http://invalid-url:5984", CouchDBName: "notes"})
+		app.fallback = storage.NewSwitchableStorage(memory)
+		failures := testutil.ToFloat64(metrics.StorageReconnections.WithLabelValues("failure"))
+
+		if app.reconnectStorage(ctx) {
+			t.Fatal("Expected reconnection to fail")
+		}
+		if got := testutil.ToFloat64(metrics.StorageReconnections.WithLabelValues("failure")); got != failures+1 {
+			t.Errorf("Expected the failure to be counted, got %v", got)
+		}
+	})
+
+	// "memory" stands in for a recovered backend: connecting to it always succeeds
+	t.Run("Replay", func(t *testing.T) {
+		memory := storage.NewInMemoryStorage()
+		if err := memory.Create(ctx, &model.Note{ID: "offline", Title: "Written during the outage"}); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		app := NewApp(&Config{StorageType: "memory", StorageReconnectReplay: true})
+		app.fallback = storage.NewSwitchableStorage(memory)
+		metrics.StorageFallbackActive.Set(1)
+		metrics.StorageBackend.WithLabelValues("memory", "couchdb").Set(1)
+
+		if !app.reconnectStorage(ctx) {
+			t.Fatal("Expected reconnection to succeed")
+		}
+		if testutil.ToFloat64(metrics.StorageFallbackActive) != 0 {
+			t.Error("Expected the fallback gauge to be cleared")
+		}
+		if got := testutil.CollectAndCount(metrics.StorageBackend); got != 1 {
+			t.Errorf("Expected a single backend series, got %d", got)
+		}
+		if got := testutil.ToFloat64(metrics.StorageBackend.WithLabelValues("memory", "memory")); got != 1 {
+			t.Errorf("Expected the backend gauge to identify the configured backend, got %v", got)
+		}
+
+		// The note was copied to the new backend, and new writes go there too
+		if _, err := app.fallback.Get(ctx, "offline"); err != nil {
+			t.Errorf("Expected the note to be replayed: %v", err)
+		}
+		if err := app.fallback.Create(ctx, &model.Note{ID: "online", Title: "Written after reconnecting"}); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		if _, err := memory.Get(ctx, "online"); err == nil {
+			t.Error("Expected new notes not to be written to memory")
+		}
+	})
+
+	t.Run("NoReplay", func(t *testing.T) {
+		memory := storage.NewInMemoryStorage()
+		if err := memory.Create(ctx, &model.Note{ID: "offline", Title: "Written during the outage"}); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		app := NewApp(&Config{StorageType: "memory"})
+		app.fallback = storage.NewSwitchableStorage(memory)
+
+		if !app.reconnectStorage(ctx) {
+			t.Fatal("Expected reconnection to succeed")
+		}
+		if _, err := app.fallback.Get(ctx, "offline"); err == nil {
+			t.Error("Expected the note not to be replayed")
+		}
+	})
+}
+
+func TestApp_StartStorageReconnection(t *testing.T) {
+	app := NewApp(&Config{StorageType: "memory", StorageReconnectInterval: 10 * time.Millisecond})
+	memory := storage.NewInMemoryStorage()
+	app.fallback = storage.NewSwitchableStorage(memory)
+
+	stop := app.startStorageReconnection()
+	deadline := time.Now().Add(time.Second)
+	for i := 0; ; i++ {
+		// Once switched, writes no longer reach the in-memory fallback
+		id := fmt.Sprintf("probe-%d", i)
+		_ = app.fallback.Create(context.Background(), &model.Note{ID: id, Title: "Probe"})
+		if _, err := memory.Get(context.Background(), id); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected traffic to be switched back in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := stop(context.Background()); err != nil {
+		t.Errorf("Failed to stop reconnection: %v", err)
+	}
+}
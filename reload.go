@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang-simple-notes/logging"
+	"golang-simple-notes/rest"
+)
+
+// reloadableSettings lists the settings (by configuration file key) that Reload
+// applies without a restart.
+var reloadableSettings = map[string]bool{
+	"log_level":            true,
+	"rate_limit_rps":       true,
+	"rate_limit_burst":     true,
+	"cors_allowed_origins": true,
+}
+
+// restSettings returns the REST settings that can be changed at runtime.
+func (c *Config) restSettings() rest.RuntimeSettings {
+	return rest.RuntimeSettings{
+		RateLimit:          c.RateLimitRPS,
+		RateLimitBurst:     c.RateLimitBurst,
+		CORSAllowedOrigins: c.corsOrigins(),
+	}
+}
+
+// Reload applies the reloadable settings of a new configuration (log level, rate limits,
+// and CORS) without restarting. Changes to other settings are logged, but only take
+// effect after a restart. The configuration must have been validated.
+//
+// Parameters:
+//   - config: The new configuration
+//
+// Returns:
+//   - An error if the new settings cannot be applied; the current settings are kept
+func (a *App) Reload(config *Config) error {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	if err := logging.SetLevel(config.LogLevel); err != nil {
+		return err
+	}
+	if a.restSettings != nil {
+		a.restSettings.Store(config.restSettings())
+	}
+
+	applied := a.applied
+	if applied == nil {
+		applied = a.config
+	}
+	previous, current := applied.settings(), config.settings()
+	for i := range current {
+		name, value := current[i][0], current[i][1]
+		switch {
+		case value == previous[i][1]:
+		case reloadableSettings[name]:
+			log.Printf("Reloaded %s: %s", name, value)
+		default:
+			log.Printf("Setting %s changed, but only takes effect after a restart", name)
+		}
+	}
+	a.applied = config
+
+	return nil
+}
+
+// ReloadOnSignal reloads the configuration whenever the process receives SIGHUP,
+// until the context is canceled. Invalid configurations are logged and ignored,
+// keeping the current settings.
+//
+// Parameters:
+//   - ctx: The context that stops watching for the signal when canceled
+//   - load: Loads and validates the new configuration
+func (a *App) ReloadOnSignal(ctx context.Context, load func() (*Config, error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	a.reloadOnSignals(ctx, signals, load)
+}
+
+// reloadOnSignals reloads the configuration for every signal received on the channel,
+// until the context is canceled.
+func (a *App) reloadOnSignals(ctx context.Context, signals <-chan os.Signal, load func() (*Config, error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Println("Reloading configuration...")
+			config, err := load()
+			if err == nil {
+				err = a.Reload(config)
+			}
+			if err != nil {
+				log.Printf("Failed to reload configuration, keeping the current settings: %v", err)
+			}
+		}
+	}
+}
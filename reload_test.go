@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang-simple-notes/logging"
+	"golang-simple-notes/rest"
+)
+
+func TestApp_Reload(t *testing.T) {
+	logOutput := captureAppLog(t)
+	t.Cleanup(func() { _ = logging.SetLevel(logging.DefaultLevel) })
+
+	config := defaultConfig()
+	app := NewApp(config)
+	app.restSettings = rest.NewSettings(config.restSettings())
+
+	reloaded := defaultConfig()
+	reloaded.LogLevel = "debug"
+	reloaded.RateLimitRPS = 5
+	reloaded.CORSAllowedOrigins = "https://a.example.com, https://b.example.com"
+	reloaded.StorageType = "mongodb"
+	if err := app.Reload(reloaded); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if logging.Level() != "debug" {
+		t.Errorf("Expected log level debug, got %s", logging.Level())
+	}
+	settings := app.restSettings.Load()
+	if settings.RateLimit != 5 || settings.RateLimitBurst != 20 ||
+		!slices.Equal(settings.CORSAllowedOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("Unexpected REST settings after reload: %+v", settings)
+	}
+
+	// Changes are reported; settings that need a restart are not applied
+	output := logOutput.String()
+	for _, want := range []string{"Reloaded log_level: debug", "Reloaded rate_limit_rps: 5", "Setting storage_type changed"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected log output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "rate_limit_burst") {
+		t.Errorf("Expected unchanged settings not to be reported, got:\n%s", output)
+	}
+	if app.config.StorageType != "memory" {
+		t.Errorf("Expected the startup configuration to be kept, got %s", app.config.StorageType)
+	}
+
+	// Later reloads are compared with the last applied configuration
+	logOutput.Reset()
+	if err := app.Reload(reloaded); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if logOutput.Len() != 0 {
+		t.Errorf("Expected no changes to be reported, got:\n%s", logOutput.String())
+	}
+}
+
+func TestApp_ReloadOnSignals(t *testing.T) {
+	logOutput := captureAppLog(t)
+	t.Cleanup(func() { _ = logging.SetLevel(logging.DefaultLevel) })
+
+	app := NewApp(defaultConfig())
+	app.restSettings = rest.NewSettings(app.config.restSettings())
+
+	// The first reload fails, the second one succeeds
+	configs := []*Config{nil, defaultConfig()}
+	configs[1].RateLimitRPS = 10
+	load := func() (*Config, error) {
+		config := configs[0]
+		configs = configs[1:]
+		if config == nil {
+			return nil, errors.New("invalid configuration")
+		}
+		return config, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		app.reloadOnSignals(ctx, signals, load)
+		close(done)
+	}()
+
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGHUP
+	cancel()
+	<-done // The loop only returns after the second reload has finished
+
+	if app.restSettings.Load().RateLimit != 10 {
+		t.Errorf("Expected the rate limit to be reloaded, got %+v", app.restSettings.Load())
+	}
+	if !strings.Contains(logOutput.String(), "keeping the current settings: invalid configuration") {
+		t.Errorf("Expected the failed reload to be logged, got:\n%s", logOutput.String())
+	}
+}
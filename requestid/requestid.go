@@ -0,0 +1,87 @@
+// Package requestid provides helpers for generating request IDs and carrying them
+// through a context.Context, so a single ID can correlate REST, gRPC, and storage
+// log lines that belong to the same client request.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const (
+	// Header is the HTTP header used to accept and return request IDs.
+	Header = "X-Request-ID"
+
+	// MetadataKey is the gRPC metadata key used to propagate request IDs.
+	// gRPC metadata keys are always lowercase.
+	MetadataKey = "x-request-id"
+
+	// maxLength limits the size of client-supplied request IDs,
+	// so clients cannot inflate log lines with arbitrary data.
+	maxLength = 128
+)
+
+// contextKey is an unexported type for the context key,
+// preventing collisions with keys defined in other packages.
+type contextKey struct{}
+
+// New generates a new random request ID (32 hexadecimal characters).
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand practically never fails; an empty ID is still safe to use
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewContext returns a copy of ctx carrying the given request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or an empty string if there is none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure returns ctx unchanged if it already carries a request ID,
+// or a copy of ctx with a newly generated ID otherwise.
+func Ensure(ctx context.Context) context.Context {
+	if FromContext(ctx) != "" {
+		return ctx
+	}
+	return NewContext(ctx, New())
+}
+
+// IsValid reports whether a client-supplied request ID is acceptable.
+// Valid IDs are 1 to 128 characters long and consist of letters, digits,
+// and the characters '-', '_', '.', and ':'.
+func IsValid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		if !((c >= 'a' && c <= 'z') ||
+			(c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// LogPrefix returns a "[request-id] " prefix for log lines, or an empty string
+// if ctx carries no request ID.
+func LogPrefix(ctx context.Context) string {
+	if id := FromContext(ctx); id != "" {
+		return "[" + id + "] "
+	}
+	return ""
+}
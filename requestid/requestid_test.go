@@ -0,0 +1,72 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	id1 := New()
+	id2 := New()
+
+	if len(id1) != 32 {
+		t.Errorf("Expected 32 characters, got %d (%s)", len(id1), id1)
+	}
+	if id1 == id2 {
+		t.Error("Expected unique request IDs")
+	}
+	if !IsValid(id1) {
+		t.Errorf("Expected generated ID %q to be valid", id1)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if id := FromContext(ctx); id != "" {
+		t.Errorf("Expected empty request ID, got %q", id)
+	}
+	if prefix := LogPrefix(ctx); prefix != "" {
+		t.Errorf("Expected empty log prefix, got %q", prefix)
+	}
+
+	ctx = NewContext(ctx, "abc-123")
+	if id := FromContext(ctx); id != "abc-123" {
+		t.Errorf("Expected 'abc-123', got %q", id)
+	}
+	if prefix := LogPrefix(ctx); prefix != "[abc-123] " {
+		t.Errorf("Expected '[abc-123] ', got %q", prefix)
+	}
+
+	// Ensure keeps an existing ID
+	if id := FromContext(Ensure(ctx)); id != "abc-123" {
+		t.Errorf("Expected Ensure to keep 'abc-123', got %q", id)
+	}
+
+	// Ensure generates an ID when there is none
+	if id := FromContext(Ensure(context.Background())); id == "" {
+		t.Error("Expected Ensure to generate a request ID")
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	cases := []struct {
+		id    string
+		valid bool
+		name  string
+	}{
+		{"", false, "Empty"},
+		{"abc-123_DEF.4:5", true, "AllowedChars"},
+		{"has space", false, "Space"},
+		{"line\nbreak", false, "Newline"},
+		{strings.Repeat("a", 128), true, "MaxLength"},
+		{strings.Repeat("a", 129), false, "TooLong"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := IsValid(c.id); got != c.valid {
+				t.Errorf("IsValid(%q) = %v, want %v", c.id, got, c.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// defaultRecentWindow is the window of GET /api/notes/recent without ?window=.
+const defaultRecentWindow = 24 * time.Hour
+
+// defaultActivityWindows are the windows of GET /api/activity without ?window=.
+var defaultActivityWindows = []string{"1h", "24h", "7d"}
+
+// maxActivityWindows is the maximum number of windows of GET /api/activity.
+const maxActivityWindows = 10
+
+// WithActivity enables the GET /api/activity endpoint, reporting the changes counted by
+// the activity log.
+func WithActivity(activity *service.ActivityLog) HandlerOption {
+	return func(h *Handler) {
+		h.activity = activity
+	}
+}
+
+// parseWindow parses a time window: a positive Go duration (e.g., "90m" or "24h"), or a
+// number of days (e.g., "7d").
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n > 365 {
+			return 0, fmt.Errorf("window must be a duration such as 24h or 7d")
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if window, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("window must be a duration such as 24h or 7d")
+		}
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	return window, nil
+}
+
+// getRecentNotes handles GET /api/notes/recent.
+// It returns the notes updated within ?window= (24h by default; see parseWindow), most
+// recently updated first, as GET /api/notes does: ?q=, ?filter=, ?sort=, ?limit=, ?offset=,
+// and ?expand= apply too. The window is a filter on updated_at that the backends run
+// natively, so only recent notes are read. It starts at the beginning of a minute, so
+// that the same request made within a minute is the same query, which can be cached.
+func (h *Handler) getRecentNotes(w http.ResponseWriter, r *http.Request) {
+	window := defaultRecentWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		var err error
+		if window, err = parseWindow(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	since := time.Now().Add(-window).Truncate(time.Minute)
+	opts.Filter = storage.UpdatedSince(since).And(opts.Filter)
+	if opts.Sort == "" {
+		opts.Sort, opts.Descending = storage.SortUpdatedAt, true
+	}
+	h.writeNotes(w, r, opts)
+}
+
+// activityWindow is the activity of a window of GET /api/activity.
+type activityWindow struct {
+	Window string    `json:"window"` // The window, as requested
+	Since  time.Time `json:"since"`  // Start of the period counted
+	service.ActivityCounts
+}
+
+// activityResponse is the body of GET /api/activity.
+type activityResponse struct {
+	Windows []activityWindow `json:"windows"`
+}
+
+// getActivity handles GET /api/activity.
+// It returns the numbers of notes created, updated, and deleted within each ?window=
+// (which can be repeated; 1h, 24h, and 7d by default), at most a week (see
+// service.ActivityLog). Each window has the start of the period counted, which is later
+// than the start of the window if counting started after it (e.g., on a restart).
+func (h *Handler) getActivity(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()["window"]
+	if len(values) == 0 {
+		values = defaultActivityWindows
+	}
+	if len(values) > maxActivityWindows {
+		http.Error(w, fmt.Sprintf("at most %d windows can be requested", maxActivityWindows), http.StatusBadRequest)
+		return
+	}
+
+	body := activityResponse{Windows: make([]activityWindow, 0, len(values))}
+	for _, value := range values {
+		window, err := parseWindow(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if window > service.ActivityRetention {
+			http.Error(w, "window must not be longer than 7d", http.StatusBadRequest)
+			return
+		}
+		counts, since := h.activity.Counts(window)
+		body.Windows = append(body.Windows, activityWindow{Window: value, Since: since, ActivityCounts: counts})
+	}
+
+	if err := writeJSON(w, http.StatusOK, body); err != nil {
+		http.Error(w, "Failed to encode activity", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// TestRecentNotes tests that GET /api/notes/recent returns the notes updated within the
+// window, most recently updated first
+func TestRecentNotes(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	r := chi.NewRouter()
+	NewHandler(backend).RegisterRoutes(r)
+
+	now := time.Now()
+	for _, note := range []struct {
+		title string
+		ago   time.Duration
+	}{{"Last week", 7 * 24 * time.Hour}, {"This morning", 3 * time.Hour}, {"Just now", time.Minute}} {
+		n := model.NewNote(note.title, "Content")
+		n.UpdatedAt = now.Add(-note.ago)
+		if err := backend.Create(context.Background(), n); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{"", http.StatusOK, []string{"Just now", "This morning"}},
+		{"?window=1h", http.StatusOK, []string{"Just now"}},
+		{"?window=30d&sort=title", http.StatusOK, []string{"Just now", "Last week", "This morning"}},
+		{"?window=24h&q=morning", http.StatusOK, []string{"This morning"}},
+		{"?window=-1h", http.StatusBadRequest, nil},
+		{"?window=soon", http.StatusBadRequest, nil},
+		{"?limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/notes/recent"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var notes []*model.Note
+			if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			var titles []string
+			for _, note := range notes {
+				titles = append(titles, note.Title)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, titles)
+			}
+		})
+	}
+}
+
+// TestActivity tests that GET /api/activity reports the changes within every window
+func TestActivity(t *testing.T) {
+	activity := service.NewActivityLog()
+	r := chi.NewRouter()
+	NewHandler(storage.NewInMemoryStorage(), WithActivity(activity)).RegisterRoutes(r)
+
+	ctx := context.Background()
+	now := time.Now()
+	activity.Notify(ctx, events.Event{Type: events.NoteCreated, NoteID: "a", Timestamp: now})
+	activity.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "a", Timestamp: now})
+	activity.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: "a", Timestamp: now})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/activity", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var body activityResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(body.Windows) != 3 || body.Windows[0].Window != "1h" || body.Windows[2].Window != "7d" {
+		t.Fatalf("Expected the default windows, got %+v", body.Windows)
+	}
+	for _, window := range body.Windows {
+		if window.ActivityCounts != (service.ActivityCounts{Created: 1, Updated: 1, Deleted: 1}) || window.Since.IsZero() {
+			t.Errorf("Unexpected activity of window %s: %+v", window.Window, window)
+		}
+	}
+
+	for _, query := range []string{"?window=8d", "?window=0s", "?window=1h" + strings.Repeat("&window=1h", maxActivityWindows)} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/activity"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
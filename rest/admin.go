@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang-simple-notes/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WithAdminToken requires the given bearer token on the /api/admin endpoints, and on the
+// export and import of all notes. Without a token, these endpoints aren't registered.
+func WithAdminToken(token string) HandlerOption {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
+}
+
+// registerAdminRoutes registers the routes of the admin API under /api/admin. Every
+// admin route requires the admin token: webhooks receive the contents of every note,
+// and the other routes are destructive, expensive, or reveal the activity of other
+// clients, so without a token none of them is registered.
+func (h *Handler) registerAdminRoutes(r chi.Router) {
+	if h.adminToken == "" {
+		log.Printf("Warning: the admin endpoints under /api/admin, /api/export, and /api/import are disabled; set ADMIN_TOKEN to enable them")
+		return
+	}
+
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(requireBearerToken(h.adminToken, "admin"))
+
+		r.Post("/purge", h.purgeNotes)                      // Delete all notes (with a confirmation token)
+		r.Post("/reindex", h.maintain(storage.TaskReindex)) // Rebuild the storage indexes
+		r.Post("/compact", h.maintain(storage.TaskCompact)) // Compact the storage
+		r.Post("/import", h.importConverted)                // Import notes from Evernote or Markdown files
+		r.Get("/loglevel", h.getLogLevel)                   // Current log level
+		r.Put("/loglevel", h.setLogLevel)                   // Change the log level at runtime
+
+		if h.search != nil && h.search.Semantic() && h.jobs != nil {
+			r.Post("/search/reindex", h.reindexSearch) // Embed and index every note for semantic searches
+		}
+
+		if h.jobs != nil {
+			r.Get("/jobs", h.listJobs)       // Queued, running, and recent background jobs
+			r.Get("/jobs/{jobID}", h.getJob) // Status of a background job
+		}
+
+		if h.hooks == nil {
+			return
+		}
+		r.Get("/webhooks", h.listHooks)                          // List the webhooks
+		r.Post("/webhooks", h.createHook)                        // Register a webhook
+		r.Get("/webhooks/deliveries", h.listDeliveries)          // Recent deliveries to all webhooks
+		r.Delete("/webhooks/{hookID}", h.deleteHook)             // Remove a webhook
+		r.Get("/webhooks/{hookID}/deliveries", h.listDeliveries) // Recent deliveries to a webhook
+	})
+}
+
+// requireBearerToken returns a middleware that rejects requests without the expected bearer token.
+func requireBearerToken(token, realm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			// Compare in constant time so the token can't be guessed byte by byte
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
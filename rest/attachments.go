@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// WithAttachments enables the attachment endpoints under /api/notes/{id}/attachments,
+// backed by the given service, with uploads of at most maxSize bytes.
+func WithAttachments(attachments service.Attachments, maxSize int64) HandlerOption {
+	return func(h *Handler) {
+		h.attachments = attachments
+		h.attachmentMaxSize = maxSize
+	}
+}
+
+// registerAttachmentRoutes registers the attachment endpoints of a note, if attachments
+// are enabled. It is called within the /api/notes/{id} routes.
+func (h *Handler) registerAttachmentRoutes(r chi.Router) {
+	if h.attachments == nil {
+		return
+	}
+	r.Get("/attachments", h.listAttachments)
+	r.Get("/attachments/{name}", h.getAttachment)
+	r.Get("/attachments/{name}/thumbnail", h.getThumbnail)
+	r.Put("/attachments/{name}", h.putAttachment)
+	r.Delete("/attachments/{name}", h.deleteAttachment)
+}
+
+// listAttachments handles GET /api/notes/{id}/attachments.
+// It returns the attachments of a note as a JSON array sorted by name, which is empty
+// if there are none, or a 404 Not Found if the note doesn't exist.
+func (h *Handler) listAttachments(w http.ResponseWriter, r *http.Request) {
+	attachments, err := h.attachments.List(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.attachmentError(w, r, err, "Failed to get attachments")
+		return
+	}
+	if err := writeJSON(w, http.StatusOK, attachments); err != nil {
+		http.Error(w, "Failed to encode attachments", http.StatusInternalServerError)
+		return
+	}
+}
+
+// putAttachment handles PUT /api/notes/{id}/attachments/{name}.
+// It stores the request body as an attachment of a note, with the content type of the
+// request, replacing an attachment with the same name, and returns the attachment.
+// Bodies larger than the configured maximum are rejected with a 413 Content Too Large.
+func (h *Handler) putAttachment(w http.ResponseWriter, r *http.Request) {
+	// The body is read first, so that the backend can send it again on a conflict
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.attachmentMaxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Attachments are limited to %d bytes", h.attachmentMaxSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read the attachment", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.attachments.Put(r.Context(), chi.URLParam(r, "id"), storage.Attachment{
+		Name:        chi.URLParam(r, "name"),
+		ContentType: r.Header.Get("Content-Type"),
+		Size:        int64(len(content)),
+	}, bytes.NewReader(content))
+	if err != nil {
+		h.attachmentError(w, r, err, "Failed to store the attachment")
+		return
+	}
+	if err := writeJSON(w, http.StatusOK, attachment); err != nil {
+		http.Error(w, "Failed to encode attachment", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getAttachment handles GET /api/notes/{id}/attachments/{name}.
+// It streams the content of an attachment as a download with its content type, or returns
+// a 404 Not Found if the note or the attachment doesn't exist.
+func (h *Handler) getAttachment(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	attachment, body, err := h.attachments.Get(r.Context(), chi.URLParam(r, "id"), name)
+	if err != nil {
+		h.attachmentError(w, r, err, "Failed to get the attachment")
+		return
+	}
+	defer body.Close()
+
+	// The content type comes from the client that uploaded the file, so browsers must
+	// neither render the file in the page of the API nor guess another type
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if attachment.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	}
+	if attachment.Digest != "" {
+		w.Header().Set("ETag", strconv.Quote(attachment.Digest))
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("%sDownload of attachment %s failed after the response was started: %v", requestid.LogPrefix(r.Context()), name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// getThumbnail handles GET /api/notes/{id}/attachments/{name}/thumbnail?size=.
+// It returns a thumbnail of an image attachment, scaled down to the given size (the
+// smallest configured size by default), to be shown inline. It returns a 400 Bad Request
+// for a size that is not configured, a 404 Not Found if the attachment has no thumbnail,
+// or a 503 Service Unavailable with a Retry-After header while the thumbnail is created.
+func (h *Handler) getThumbnail(w http.ResponseWriter, r *http.Request) {
+	size := 0
+	if value := r.URL.Query().Get("size"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil || size <= 0 {
+			http.Error(w, "Invalid size parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	attachment, body, err := h.attachments.Thumbnail(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "name"), size)
+	if err != nil {
+		h.attachmentError(w, r, err, "Failed to get the thumbnail")
+		return
+	}
+	defer body.Close()
+
+	// Thumbnails are encoded by the service, so they can be shown in the page
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if attachment.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	}
+	if attachment.Digest != "" {
+		w.Header().Set("ETag", strconv.Quote(attachment.Digest))
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("%sDownload of a thumbnail failed after the response was started: %v", requestid.LogPrefix(r.Context()), err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// deleteAttachment handles DELETE /api/notes/{id}/attachments/{name}.
+// It returns a 204 No Content, or a 404 Not Found if the note or the attachment doesn't exist.
+func (h *Handler) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if err := h.attachments.Delete(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "name")); err != nil {
+		h.attachmentError(w, r, err, "Failed to delete the attachment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachmentError writes the response for an error of the attachment service.
+func (h *Handler) attachmentError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAttachment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrNoteNotFound):
+		http.Error(w, "Note not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrAttachmentNotFound):
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNoThumbnail):
+		http.Error(w, "Attachment has no thumbnail", http.StatusNotFound)
+	case errors.Is(err, service.ErrThumbnailPending):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Thumbnail is being generated, try again shortly", http.StatusServiceUnavailable)
+	case errors.Is(err, storage.ErrConflict):
+		http.Error(w, "The note was modified concurrently, try again", http.StatusConflict)
+	case storageUnavailable(w, err):
+	default:
+		log.Printf("%s%s: %v", requestid.LogPrefix(r.Context()), message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
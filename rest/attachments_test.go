@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// TestAttachments tests uploading, listing, downloading, and deleting the attachments of a note
+func TestAttachments(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	note := model.NewNote("Title", "Content")
+	if err := backend.Create(context.Background(), note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	r := chi.NewRouter()
+	NewHandler(backend, WithAttachments(service.NewAttachmentService(backend, nil), 16)).RegisterRoutes(r)
+	serve := func(method, url, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	base := "/api/notes/" + note.ID + "/attachments"
+
+	w := serve("PUT", base+"/hello.txt", "text/plain", "hello")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var attachment storage.Attachment
+	if err := json.Unmarshal(w.Body.Bytes(), &attachment); err != nil || attachment.Name != "hello.txt" || attachment.Size != 5 {
+		t.Errorf("Unexpected attachment %s: %v", w.Body.String(), err)
+	}
+
+	w = serve("GET", base, "", "")
+	var list []storage.Attachment
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].ContentType != "text/plain" {
+		t.Errorf("Unexpected attachments %s: %v", w.Body.String(), err)
+	}
+
+	w = serve("GET", base+"/hello.txt", "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("Expected the attachment, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/plain" || w.Header().Get("Content-Length") != "5" ||
+		w.Header().Get("Content-Disposition") != `attachment; filename="hello.txt"` || w.Header().Get("ETag") == "" {
+		t.Errorf("Unexpected headers %v", w.Header())
+	}
+
+	// In order, since the file is deleted twice
+	for _, tc := range []struct {
+		name, method, url, body string
+		status                  int
+	}{
+		{"TooLarge", "PUT", base + "/large.bin", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+		{"InvalidName", "PUT", base + "/.hidden", "x", http.StatusBadRequest},
+		{"MissingNote", "PUT", "/api/notes/missing/attachments/a.txt", "x", http.StatusNotFound},
+		{"MissingFile", "GET", base + "/other.txt", "", http.StatusNotFound},
+		{"Delete", "DELETE", base + "/hello.txt", "", http.StatusNoContent},
+		{"DeleteAgain", "DELETE", base + "/hello.txt", "", http.StatusNotFound},
+		{"ListMissingNote", "GET", "/api/notes/missing/attachments", "", http.StatusNotFound},
+	} {
+		if w := serve(tc.method, tc.url, "", tc.body); w.Code != tc.status {
+			t.Errorf("%s: expected status code %d, got %d: %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+
+	// Without attachments, the endpoints don't exist
+	r = chi.NewRouter()
+	NewHandler(backend).RegisterRoutes(r)
+	if w := serve("GET", base, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestThumbnail tests getting the thumbnail of an image attachment once it has been created
+func TestThumbnail(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	note := model.NewNote("Title", "Content")
+	if err := backend.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	runner := jobs.NewRunner(1, 10)
+	defer runner.Close(ctx)
+	attachments := service.NewAttachmentService(backend, nil, service.WithThumbnails(runner, []int{16}))
+	r := chi.NewRouter()
+	NewHandler(backend, WithAttachments(attachments, 1<<20)).RegisterRoutes(r)
+	serve := func(method, url, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	base := "/api/notes/" + note.ID + "/attachments"
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	if w := serve("PUT", base+"/image.png", "image/png", img.Bytes()); w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Pending until the job has run
+	w := serve("GET", base+"/image.png/thumbnail?size=16", "", nil)
+	for deadline := time.Now().Add(5 * time.Second); w.Code == http.StatusServiceUnavailable && time.Now().Before(deadline); {
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected a Retry-After header, got %v", w.Header())
+		}
+		time.Sleep(10 * time.Millisecond)
+		w = serve("GET", base+"/image.png/thumbnail?size=16", "", nil)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || w.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("Expected an inline PNG thumbnail, got %d: %v", w.Code, w.Header())
+	}
+	if config, err := png.DecodeConfig(w.Body); err != nil || config.Width != 16 {
+		t.Errorf("Expected a 16 pixels wide thumbnail, got %+v: %v", config, err)
+	}
+
+	if w := serve("PUT", base+"/notes.txt", "text/plain", []byte("text")); w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	for _, tc := range []struct {
+		name, url string
+		status    int
+	}{
+		{"InvalidSize", base + "/image.png/thumbnail?size=small", http.StatusBadRequest},
+		{"UnknownSize", base + "/image.png/thumbnail?size=64", http.StatusBadRequest},
+		{"NotAnImage", base + "/notes.txt/thumbnail", http.StatusNotFound},
+		{"MissingFile", base + "/other.png/thumbnail", http.StatusNotFound},
+	} {
+		if w := serve("GET", tc.url, "", nil); w.Code != tc.status {
+			t.Errorf("%s: expected status code %d, got %d: %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+}
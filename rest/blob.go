@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/blob"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// exportKeyPrefix is the prefix of the keys of the exports stored in the blob store.
+const exportKeyPrefix = "exports/"
+
+// exportUpload is the result of an export job.
+type exportUpload struct {
+	Key       string    `json:"key"`        // Key of the file in the blob store
+	Notes     int       `json:"notes"`      // Number of exported notes
+	Size      int64     `json:"size"`       // Size of the file in bytes
+	URL       string    `json:"url"`        // Presigned download URL
+	ExpiresAt time.Time `json:"expires_at"` // When the download URL expires
+}
+
+// WithBlobStore enables exports to the blob store (POST /api/export), downloaded from
+// URLs that expire after urlExpiry. It requires the job runner (see WithJobs). If the
+// store implements blob.URLVerifier, its files are served by GET /api/blobs/{key}.
+func WithBlobStore(store blob.BlobStore, urlExpiry time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.blobs = store
+		h.blobURLExpiry = urlExpiry
+	}
+}
+
+// downloadBlob handles GET /api/blobs/{key}.
+// It serves a file of a blob store that has no download URLs of its own (e.g., GridFS),
+// from a URL issued by the store. The signature of the URL stands in for authentication,
+// so this endpoint doesn't require the admin token. It responds with 403 Forbidden if the
+// URL is invalid or has expired, and 404 Not Found if the file no longer exists.
+func (h *Handler) downloadBlob(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	if err := h.blobs.(blob.URLVerifier).VerifyDownload(key, r.URL.Query()); err != nil {
+		http.Error(w, "Invalid or expired download URL", http.StatusForbidden)
+		return
+	}
+	body, err := h.blobs.Get(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%sFailed to download %s: %v", requestid.LogPrefix(r.Context()), key, err)
+		http.Error(w, "Failed to download the file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(key)))
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("%sDownload of %s failed after the response was started: %v", requestid.LogPrefix(r.Context()), key, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// submitExport handles POST /api/export.
+// It runs an export in the format given by ?format= (as for GET /api/export) as an
+// "export" background job, which writes the notes to a temporary file, uploads it to the
+// blob store, and returns a download URL, so that the file doesn't stream through the
+// server again when it is downloaded. It responds with 202 Accepted and the job, whose
+// result holds the URL once it has finished (see GET /api/admin/jobs/{jobID}).
+func (h *Handler) submitExport(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = defaultExportFormat
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("format must be one of: %s", strings.Join(exportFormatNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	// A random suffix keeps apart the exports started in the same second
+	key := fmt.Sprintf("%snotes-%s-%s.%s", exportKeyPrefix, time.Now().UTC().Format("20060102-150405"),
+		strings.ToLower(rand.Text()), format.extension)
+	job, err := h.jobs.Submit(r.Context(), jobs.Task{
+		Kind: "export",
+		Run: func(ctx context.Context) (any, error) {
+			return h.uploadExport(ctx, key, format)
+		},
+	})
+	if err != nil {
+		jobRejected(w, err)
+		return
+	}
+	writeJobAccepted(w, job)
+}
+
+// uploadExport writes all notes to a temporary file, which is removed afterwards, and
+// uploads it to the blob store under the given key.
+func (h *Handler) uploadExport(ctx context.Context, key string, format exportFormat) (*exportUpload, error) {
+	file, err := os.CreateTemp("", "notes-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	count := 0
+	writer := format.newWriter(file)
+	err = h.notes.Stream(ctx, func(note *model.Note) error {
+		count++
+		return writer.WriteNote(note)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export notes: %w", err)
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the export: %w", err)
+	}
+	if err := h.blobs.Put(ctx, key, file, size, format.contentType); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(h.blobURLExpiry)
+	url, err := h.blobs.DownloadURL(ctx, key, h.blobURLExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the download URL: %w", err)
+	}
+	return &exportUpload{Key: key, Notes: count, Size: size, URL: url, ExpiresAt: expiresAt}, nil
+}
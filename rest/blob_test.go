@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/blob"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// memoryBlobStore is a blob store holding files in memory, with fake download URLs
+type memoryBlobStore struct {
+	mutex sync.Mutex
+	files map[string][]byte
+	types map[string]string
+}
+
+func (m *memoryBlobStore) Put(_ context.Context, key string, body io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return io.ErrShortWrite
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.files[key], m.types[key] = data, contentType
+	return nil
+}
+
+func (m *memoryBlobStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.files[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryBlobStore) Delete(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.files, key)
+	return nil
+}
+
+func (m *memoryBlobStore) DownloadURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://blobs.example.com/" + key + "?expires=" + expiry.String(), nil
+}
+
+// TestSubmitExport tests that exports to the blob store run as background jobs whose
+// result is a download URL
+func TestSubmitExport(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	for _, note := range []*model.Note{model.NewNote("First", "One"), model.NewNote("Second", "Two")} {
+		if err := backend.Create(context.Background(), note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	blobs := &memoryBlobStore{files: map[string][]byte{}, types: map[string]string{}}
+	runner := jobs.NewRunner(1, 10)
+	r := chi.NewRouter()
+	NewHandler(backend, WithJobs(runner), WithBlobStore(blobs, 10*time.Minute), WithAdminToken("admin-token")).RegisterRoutes(r)
+
+	w := serveAdmin(r, "POST", "/api/export?format=json", "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil || job.Kind != "export" {
+		t.Fatalf("Unexpected job %s: %v", w.Body.String(), err)
+	}
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	job, err := runner.Get(job.ID)
+	if err != nil || job.Status != jobs.StatusSucceeded {
+		t.Fatalf("Expected a successful export, got %+v: %v", job, err)
+	}
+	upload := job.Result.(*exportUpload)
+	if upload.Notes != 2 || !strings.HasPrefix(upload.Key, "exports/notes-") || !strings.HasSuffix(upload.Key, ".json") ||
+		upload.URL != "https://blobs.example.com/"+upload.Key+"?expires=10m0s" {
+		t.Errorf("Unexpected export result %+v", upload)
+	}
+	var notes []model.Note
+	if err := json.Unmarshal(blobs.files[upload.Key], &notes); err != nil || len(notes) != 2 || int64(len(blobs.files[upload.Key])) != upload.Size {
+		t.Errorf("Expected both notes in the uploaded file, got %s: %v", blobs.files[upload.Key], err)
+	}
+	if blobs.types[upload.Key] != "application/json" {
+		t.Errorf("Unexpected content type %q", blobs.types[upload.Key])
+	}
+
+	w = serveAdmin(r, "POST", "/api/export?format=pdf", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an unknown format, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Without a blob store, exports are only downloaded
+	r = chi.NewRouter()
+	NewHandler(backend, WithJobs(newTestRunner(t)), WithAdminToken("admin-token")).RegisterRoutes(r)
+	w = serveAdmin(r, "POST", "/api/export", "")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// signedBlobStore is a blob store whose download URLs are served by the API server
+type signedBlobStore struct {
+	*memoryBlobStore
+	*blob.URLSigner
+}
+
+func (s signedBlobStore) DownloadURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	return s.Sign(key, expiry), nil
+}
+
+// TestDownloadBlob tests that the files of blob stores without URLs of their own are
+// served from signed URLs only
+func TestDownloadBlob(t *testing.T) {
+	store := signedBlobStore{
+		memoryBlobStore: &memoryBlobStore{files: map[string][]byte{}, types: map[string]string{}},
+		URLSigner:       blob.NewURLSigner("/api/blobs", []byte("secret")),
+	}
+	content := `{"id":"1"}` + "\n"
+	if err := store.Put(context.Background(), "exports/notes.json", strings.NewReader(content), int64(len(content)), "application/json"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	r := chi.NewRouter()
+	NewHandler(storage.NewInMemoryStorage(), WithJobs(newTestRunner(t)), WithBlobStore(store, time.Minute)).RegisterRoutes(r)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	url, _ := store.DownloadURL(context.Background(), "exports/notes.json", time.Minute)
+	w := get(url)
+	if w.Code != http.StatusOK || w.Body.String() != content {
+		t.Fatalf("Expected the file, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="notes.json"` {
+		t.Errorf("Unexpected Content-Disposition %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Unexpected Content-Type %q", got)
+	}
+
+	if w = get(strings.Replace(url, "notes.json", "other.json", 1)); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d for another key, got %d", http.StatusForbidden, w.Code)
+	}
+	if w = get("/api/blobs/exports/notes.json"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d without a signature, got %d", http.StatusForbidden, w.Code)
+	}
+	if err := store.Delete(context.Background(), "exports/notes.json"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if w = get(url); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a deleted file, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Stores with URLs of their own don't need the endpoint
+	r = chi.NewRouter()
+	NewHandler(storage.NewInMemoryStorage(), WithBlobStore(store.memoryBlobStore, time.Minute)).RegisterRoutes(r)
+	if w = get(url); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang-simple-notes/requestid"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/time/rate"
+)
+
+// redacted replaces secrets in logged headers and bodies
+const redacted = "[REDACTED]"
+
+var (
+	// secretNamePattern matches the names of headers that hold credentials
+	secretNamePattern = regexp.MustCompile(`(?i)authorization|cookie|password|secret|token|signature|api[_-]?key`)
+
+	// secretJSONPattern matches JSON string fields with secret names, even in truncated bodies
+	secretJSONPattern = regexp.MustCompile(`(?i)("[^"]*(?:password|secret|token|api[_-]?key)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*("|$)`)
+
+	// secretFormPattern matches URL-encoded form fields with secret names
+	secretFormPattern = regexp.MustCompile(`(?i)((?:^|&)[^=&]*(?:password|secret|token|api[_-]?key)[^=&]*=)[^&]*`)
+)
+
+// BodyLogOptions configures BodyLogMiddleware.
+type BodyLogOptions struct {
+	MaxBytes      int      // Maximum number of bytes logged of each request and response body
+	Rate          float64  // Maximum number of requests logged per second; further ones are skipped
+	ExcludedPaths []string // Path prefixes whose requests are never logged (e.g., "/api/admin/backup")
+}
+
+// BodyLogMiddleware logs the headers and the first bytes of the bodies of requests and their
+// responses at debug level, to troubleshoot malformed client payloads. Secrets are redacted:
+// credential headers (e.g., Authorization, X-API-Key) and JSON or form fields whose names
+// contain "password", "secret", "token", or "api_key". Only a sample of the requests is logged,
+// limited to options.Rate per second, and nothing is captured while debug logging is off,
+// so the log level can be raised at runtime when a problem shows up.
+// WebSocket upgrades and the excluded paths are passed on untouched.
+//
+// Parameters:
+//   - options: The body size limit, sampling rate, and excluded paths
+//
+// Returns:
+//   - The middleware
+func BodyLogMiddleware(options BodyLogOptions) func(http.Handler) http.Handler {
+	limiter := rate.NewLimiter(rate.Limit(options.Rate), max(1, int(math.Ceil(options.Rate))))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slog.Default().Enabled(r.Context(), slog.LevelDebug) || r.Header.Get("Upgrade") != "" ||
+				isExcludedPath(r.URL.Path, options.ExcludedPaths) || !limiter.Allow() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Read the start of the request body, and put it back in front of the rest
+			requestBody, err := io.ReadAll(io.LimitReader(r.Body, int64(options.MaxBytes)+1))
+			if err != nil {
+				// The handler runs into the same error when it reads the rest of the body
+				slog.Debug(requestid.LogPrefix(r.Context())+"http body: failed to read request body", "error", err)
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+
+			response := &limitedBuffer{limit: options.MaxBytes}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(response)
+			next.ServeHTTP(ww, r)
+
+			slog.Debug(requestid.LogPrefix(r.Context())+"http body",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"request_headers", redactHeaders(r.Header),
+				"request_body", formatBody(r.Header.Get("Content-Type"), requestBody, options.MaxBytes),
+				"response_body", formatBody(ww.Header().Get("Content-Type"), response.buf.Bytes(), options.MaxBytes),
+				"response_size", ww.BytesWritten())
+		})
+	}
+}
+
+// isExcludedPath reports whether path starts with one of the excluded prefixes.
+func isExcludedPath(path string, excluded []string) bool {
+	for _, prefix := range excluded {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+// Write never fails, so the response is written in full even when the buffer is full.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	// Keep one byte more than the limit, to tell truncated bodies apart
+	if room := b.limit + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// redactHeaders returns the headers as a string, with the values of credential headers redacted.
+func redactHeaders(header http.Header) string {
+	var builder strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			if secretNamePattern.MatchString(name) {
+				value = redacted
+			}
+			if builder.Len() > 0 {
+				builder.WriteString("; ")
+			}
+			builder.WriteString(name + ": " + value)
+		}
+	}
+	return builder.String()
+}
+
+// formatBody returns a body for logging: text bodies with secret fields redacted, truncated to
+// maxBytes, and a placeholder for binary ones.
+func formatBody(contentType string, body []byte, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+	truncated := len(body) > maxBytes
+	if truncated {
+		body = body[:maxBytes]
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var text string
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		text = secretFormPattern.ReplaceAllString(string(body), "${1}"+redacted)
+	case isTextMediaType(mediaType):
+		text = secretJSONPattern.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+	default:
+		return "[" + mediaType + " body omitted]"
+	}
+
+	if truncated {
+		text = strings.ToValidUTF8(text, "") + "...[truncated]"
+	}
+	return text
+}
+
+// isTextMediaType reports whether bodies of the media type are text worth logging.
+// Bodies without a content type are assumed to be JSON, which most clients send.
+func isTextMediaType(mediaType string) bool {
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/yaml"
+}
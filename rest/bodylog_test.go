@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog sends log messages of the given level and above to the returned buffer
+// until the test ends.
+func captureLog(t *testing.T, level slog.Level) *bytes.Buffer {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level})))
+	return &buf
+}
+
+// TestBodyLogMiddleware tests logging of bodies with redaction, truncation, sampling, and opt-outs
+func TestBodyLogMiddleware(t *testing.T) {
+	var received string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1","content":"` + strings.Repeat("x", 100) + `"}`))
+	})
+	send := func(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer admin-token")
+		req.Header.Set("X-API-Key", "key-123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	requestBody := `{"title":"Hello","webhook_secret":"s3cret","content":"` + strings.Repeat("y", 40) + `"}`
+
+	t.Run("Logged", func(t *testing.T) {
+		log := captureLog(t, slog.LevelDebug)
+		handler := BodyLogMiddleware(BodyLogOptions{MaxBytes: 64, Rate: 10})(next)
+
+		w := send(handler, "/api/notes", requestBody)
+		if w.Code != http.StatusCreated || !strings.HasSuffix(w.Body.String(), `xxx"}`) {
+			t.Errorf("Expected the full response, got %d %s", w.Code, w.Body.String())
+		}
+		if received != requestBody {
+			t.Errorf("Expected the handler to receive the full request body, got %s", received)
+		}
+
+		output := log.String()
+		for _, expected := range []string{"http body", "status=201", `\"title\":\"Hello\"`, "Authorization: [REDACTED]",
+			"X-Api-Key: [REDACTED]", `\"webhook_secret\":\"[REDACTED]\"`, "...[truncated]", "response_size=123"} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("Expected the log to contain %s, got %s", expected, output)
+			}
+		}
+		for _, secret := range []string{"admin-token", "key-123", "s3cret"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("Expected %s to be redacted, got %s", secret, output)
+			}
+		}
+	})
+
+	t.Run("Sampled", func(t *testing.T) {
+		log := captureLog(t, slog.LevelDebug)
+		handler := BodyLogMiddleware(BodyLogOptions{MaxBytes: 64, Rate: 0.001})(next)
+
+		send(handler, "/api/notes", requestBody)
+		send(handler, "/api/notes", requestBody)
+		if count := strings.Count(log.String(), "http body"); count != 1 {
+			t.Errorf("Expected only the first request to be logged, got %d", count)
+		}
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		tests := map[string]struct {
+			level slog.Level
+			path  string
+		}{
+			"ExcludedPath": {slog.LevelDebug, "/api/admin/restore"},
+			"DebugOff":     {slog.LevelInfo, "/api/notes"},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				log := captureLog(t, tt.level)
+				handler := BodyLogMiddleware(BodyLogOptions{MaxBytes: 64, Rate: 10, ExcludedPaths: []string{"/api/admin/"}})(next)
+
+				send(handler, tt.path, requestBody)
+				if log.Len() != 0 {
+					t.Errorf("Expected nothing to be logged, got %s", log.String())
+				}
+				if received != requestBody {
+					t.Errorf("Expected the handler to receive the request body, got %s", received)
+				}
+			})
+		}
+	})
+}
+
+// TestFormatBody tests redaction of form fields and omission of binary bodies
+func TestFormatBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"application/x-www-form-urlencoded", "user=jo&password=hunter2&api_key=k", "user=jo&password=[REDACTED]&api_key=[REDACTED]"},
+		{"application/json; charset=utf-8", `{"token": "abc\"def", "n": 1}`, `{"token": "[REDACTED]", "n": 1}`},
+		{"application/zip", "PK\x03\x04", "[application/zip body omitted]"},
+		{"text/plain", "", ""},
+	}
+	for _, tt := range tests {
+		if got := formatBody(tt.contentType, []byte(tt.body), 100); got != tt.expected {
+			t.Errorf("formatBody(%q, %q) = %q, expected %q", tt.contentType, tt.body, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// wsCollab holds the notes a WebSocket connection edits together with others. The content of
+// a joined note is a CRDT document (see package crdt), which the client keeps a copy of:
+//
+//	→ {"type":"join","note_id":"abc"}
+//	← {"type":"joined","note_id":"abc","site":"3f2a…","ops":[{"type":"insert","id":{"clock":1,"site":"server-…"},"text":"H"},…]}
+//	→ {"type":"edit","note_id":"abc","ops":[{"type":"insert","id":{"clock":6,"site":"3f2a…"},"after":{"clock":5,"site":"server-…"},"text":"!"}]}
+//	← {"type":"edited","note_id":"abc"}
+//	← {"type":"update","note_id":"abc","ops":[{"type":"delete","id":{"clock":1,"site":"server-…"}}]}
+//	→ {"type":"leave","note_id":"abc"}
+//	← {"type":"left","note_id":"abc"}
+//
+// Clients apply their edits locally before sending them, inserting characters with the site
+// name they were given and a clock greater than every clock they have seen, and apply the
+// operations of update messages as they arrive. The server stores the note with the merged
+// text after every edit, so note events and REST clients see it too.
+type wsCollab struct {
+	service  service.Collaboration // Editing sessions of the notes; nil if disabled
+	sites    map[string]string     // Site name of the connection, by ID of the joined note
+	updates  chan wsMessage        // Update messages waiting to be written to the connection
+	overflow func()                // Closes the connection when updates is full
+}
+
+// newWebSocketCollab creates the joined notes of a connection; overflow is called, without
+// blocking, if the connection falls behind the updates.
+func newWebSocketCollab(collab service.Collaboration, overflow func()) *wsCollab {
+	return &wsCollab{
+		service:  collab,
+		sites:    make(map[string]string),
+		updates:  make(chan wsMessage, webSocketUpdateBuffer),
+		overflow: overflow,
+	}
+}
+
+// handle applies a join, edit, or leave message and returns the reply.
+func (c *wsCollab) handle(ctx context.Context, req wsRequest) wsMessage {
+	if c == nil || c.service == nil {
+		return wsMessage{Type: wsError, NoteID: req.NoteID, Error: "collaborative editing is not enabled"}
+	}
+	if req.NoteID == "" {
+		return wsMessage{Type: wsError, Error: req.Type + " requires a note_id"}
+	}
+	noteID := req.NoteID
+	site, joined := c.sites[noteID]
+
+	switch req.Type {
+	case wsJoin:
+		if joined {
+			return wsMessage{Type: wsError, NoteID: noteID, Error: "note already joined"}
+		}
+		if len(c.sites) >= maxWebSocketJoins {
+			return wsMessage{Type: wsError, NoteID: noteID,
+				Error: fmt.Sprintf("at most %d notes can be joined per connection", maxWebSocketJoins)}
+		}
+		state, err := c.service.Join(ctx, noteID, func(ops []crdt.Op) {
+			select {
+			case c.updates <- wsMessage{Type: wsUpdate, NoteID: noteID, Ops: ops}:
+			default:
+				c.overflow()
+			}
+		})
+		if err != nil {
+			return collabError(ctx, noteID, "join", err)
+		}
+		c.sites[noteID] = state.Site
+		return wsMessage{Type: wsJoined, NoteID: noteID, Site: state.Site, Ops: state.Ops}
+	case wsEdit:
+		if !joined {
+			return wsMessage{Type: wsError, NoteID: noteID, Error: service.ErrNotJoined.Error()}
+		}
+		if err := c.service.Edit(ctx, noteID, site, req.Ops); err != nil {
+			return collabError(ctx, noteID, "edit", err)
+		}
+		return wsMessage{Type: wsEdited, NoteID: noteID}
+	default:
+		if !joined {
+			return wsMessage{Type: wsError, NoteID: noteID, Error: service.ErrNotJoined.Error()}
+		}
+		c.service.Leave(noteID, site)
+		delete(c.sites, noteID)
+		return wsMessage{Type: wsLeft, NoteID: noteID}
+	}
+}
+
+// leaveAll leaves the joined notes, when the connection closes.
+func (c *wsCollab) leaveAll() {
+	for noteID, site := range c.sites {
+		c.service.Leave(noteID, site)
+	}
+	clear(c.sites)
+}
+
+// collabError returns the error message for a failed join or edit. Errors caused by the
+// client are described; other errors are logged.
+func collabError(ctx context.Context, noteID, action string, err error) wsMessage {
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		return wsMessage{Type: wsError, NoteID: noteID, Error: "note not found"}
+	}
+	if errors.Is(err, service.ErrInvalidEdit) || errors.Is(err, service.ErrInvalidNote) || errors.Is(err, service.ErrNotJoined) ||
+		errors.Is(err, storage.ErrStorageFull) || errors.Is(err, storage.ErrConflict) {
+		return wsMessage{Type: wsError, NoteID: noteID, Error: err.Error()}
+	}
+	log.Printf("%sFailed to %s note %s: %v", requestid.LogPrefix(ctx), action, noteID, err)
+	return wsMessage{Type: wsError, NoteID: noteID, Error: "failed to " + action + " the note"}
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+	"golang-simple-notes/webhook"
+)
+
+// TestWebSocketCollab tests editing a note together over WebSocket connections
+func TestWebSocketCollab(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mockStorage := fake.New()
+	notes := service.New(mockStorage)
+	collab := service.NewCollabService(storage.NewInMemoryStorage(), notes, newTestRunner(t))
+	r := chi.NewRouter()
+	NewHandler(mockStorage, WithNoteService(notes), WithBroadcaster(webhook.NewBroadcaster()), WithCollaboration(collab)).RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	note, err := notes.Create(ctx, service.NoteInput{Title: "Shared", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// call sends a message and reads the reply
+	call := func(conn *websocket.Conn, req wsRequest) wsMessage {
+		t.Helper()
+		var reply wsMessage
+		if err := wsjson.Write(ctx, conn, req); err != nil {
+			t.Fatalf("Failed to write %s: %v", req.Type, err)
+		}
+		if err := wsjson.Read(ctx, conn, &reply); err != nil {
+			t.Fatalf("Failed to read the reply to %s: %v", req.Type, err)
+		}
+		return reply
+	}
+
+	var conns []*websocket.Conn
+	var docs []*crdt.Doc
+	var sites []string
+	for range 2 {
+		conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer func() { _ = conn.CloseNow() }()
+
+		reply := call(conn, wsRequest{Type: wsJoin, NoteID: note.ID})
+		if reply.Type != wsJoined || reply.Site == "" {
+			t.Fatalf("Expected a joined reply, got %+v", reply)
+		}
+		doc := crdt.New()
+		if _, err := doc.Apply(reply.Ops); err != nil || doc.Text() != "Hi" {
+			t.Fatalf("Expected the document of the note, got %q: %v", doc.Text(), err)
+		}
+		conns, docs, sites = append(conns, conn), append(docs, doc), append(sites, reply.Site)
+	}
+
+	if reply := call(conns[0], wsRequest{Type: wsJoin, NoteID: note.ID}); reply.Type != wsError {
+		t.Errorf("Expected an error for joining twice, got %+v", reply)
+	}
+	if reply := call(conns[0], wsRequest{Type: wsJoin, NoteID: "missing"}); reply.Error != "note not found" {
+		t.Errorf("Expected an error for a missing note, got %+v", reply)
+	}
+
+	// The edit of the first connection is stored and sent to the second
+	ops := docs[0].SetText(sites[0], "Hi there")
+	if reply := call(conns[0], wsRequest{Type: wsEdit, NoteID: note.ID, Ops: ops}); reply.Type != wsEdited {
+		t.Fatalf("Expected an edited reply, got %+v", reply)
+	}
+	var update wsMessage
+	if err := wsjson.Read(ctx, conns[1], &update); err != nil || update.Type != wsUpdate || update.NoteID != note.ID {
+		t.Fatalf("Expected an update, got %+v: %v", update, err)
+	}
+	if _, err := docs[1].Apply(update.Ops); err != nil || docs[1].Text() != "Hi there" {
+		t.Errorf("Expected the edit to be applied, got %q: %v", docs[1].Text(), err)
+	}
+	if stored, _ := notes.Get(ctx, note.ID); stored.Content != "Hi there" {
+		t.Errorf("Expected the edit to be stored, got %q", stored.Content)
+	}
+
+	forged := []crdt.Op{{Type: crdt.OpInsert, ID: crdt.ID{Clock: 100, Site: sites[0]}, Text: "x"}}
+	if reply := call(conns[1], wsRequest{Type: wsEdit, NoteID: note.ID, Ops: forged}); reply.Type != wsError || !strings.Contains(reply.Error, "invalid edit") {
+		t.Errorf("Expected an invalid edit error, got %+v", reply)
+	}
+	if reply := call(conns[1], wsRequest{Type: wsLeave, NoteID: note.ID}); reply.Type != wsLeft {
+		t.Errorf("Expected a left reply, got %+v", reply)
+	}
+	if reply := call(conns[1], wsRequest{Type: wsEdit, NoteID: note.ID, Ops: ops}); reply.Error != service.ErrNotJoined.Error() {
+		t.Errorf("Expected an error after leaving, got %+v", reply)
+	}
+}
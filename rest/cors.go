@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"golang-simple-notes/requestid"
+)
+
+const (
+	// corsAllowedMethods lists the methods used by the API
+	corsAllowedMethods = "GET, POST, PUT, DELETE"
+
+	// corsMaxAge is how long browsers may cache a preflight response, in seconds
+	corsMaxAge = 600
+)
+
+// CORSMiddleware allows browsers to call the API from the configured origins.
+// Requests from other origins are passed on without CORS headers, so browsers block
+// the responses. Preflight requests from allowed origins are answered directly.
+// The allowed origins are read from the settings for every request, so they can be
+// changed at runtime; an empty list disables CORS.
+//
+// Parameters:
+//   - settings: The settings holding the current allowed origins
+//
+// Returns:
+//   - The middleware
+func CORSMiddleware(settings *Settings) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Responses depend on the origin, so caches must keep them apart
+			w.Header().Add("Vary", "Origin")
+
+			allowed := settings.Load().CORSAllowedOrigins
+			if !slices.Contains(allowed, origin) && !slices.Contains(allowed, "*") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+
+			// Answer preflight requests, which ask whether the actual request is allowed
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+				}
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			// Let scripts read the request ID of responses, the total count of lists, and the
+			// location of created notes
+			w.Header().Set("Access-Control-Expose-Headers", requestid.Header+", "+TotalCountHeader+", Location")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCORSMiddleware tests CORS headers, preflight requests, and runtime changes of the allowed origins
+func TestCORSMiddleware(t *testing.T) {
+	settings := NewSettings(RuntimeSettings{CORSAllowedOrigins: []string{"https://app.example.com"}})
+	handler := CORSMiddleware(settings)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/notes", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("AllowedOrigin", func(t *testing.T) {
+		w := send("GET", "https://app.example.com", nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Expected the origin to be allowed, got %q", got)
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Errorf("Expected Vary: Origin, got %q", w.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("OtherOrigin", func(t *testing.T) {
+		w := send("GET", "https://evil.example.com", nil)
+		if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers, got %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("SameOrigin", func(t *testing.T) {
+		w := send("GET", "", nil)
+		if w.Header().Get("Vary") != "" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers without an Origin, got %v", w.Header())
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		w := send("OPTIONS", "https://app.example.com", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "Content-Type",
+		})
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", w.Code)
+		}
+		if w.Header().Get("Access-Control-Allow-Methods") != corsAllowedMethods ||
+			w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" {
+			t.Errorf("Unexpected preflight headers: %v", w.Header())
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		settings.Store(RuntimeSettings{CORSAllowedOrigins: []string{"*"}})
+		w := send("GET", "https://other.example.com", nil)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://other.example.com" {
+			t.Errorf("Expected any origin to be allowed, got %q", got)
+		}
+
+		settings.Store(RuntimeSettings{})
+		w = send("GET", "https://app.example.com", nil)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected CORS to be disabled, got %q", got)
+		}
+	})
+}
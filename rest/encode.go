@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// maxPooledJSONBuffer is the capacity above which buffers are not returned to the pool,
+// so that one large response doesn't keep its memory for the lifetime of the process.
+const maxPooledJSONBuffer = 64 << 10
+
+// jsonBuffer is a buffer with a JSON encoder writing into it. Both are reused across
+// responses, which saves their allocations on every request.
+type jsonBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+// jsonBuffers pools the buffers of JSON responses.
+var jsonBuffers = sync.Pool{
+	New: func() any {
+		b := &jsonBuffer{}
+		b.encoder = json.NewEncoder(&b.Buffer)
+		return b
+	},
+}
+
+// getJSONBuffer returns an empty buffer from the pool.
+func getJSONBuffer() *jsonBuffer {
+	return jsonBuffers.Get().(*jsonBuffer)
+}
+
+// putJSONBuffer returns a buffer to the pool, unless it has grown too large.
+func putJSONBuffer(b *jsonBuffer) {
+	if b.Cap() > maxPooledJSONBuffer {
+		return
+	}
+	b.Reset()
+	jsonBuffers.Put(b)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code.
+// The body is encoded into a pooled buffer and written at once, so nothing is written if
+// encoding fails: the caller can still respond with an error.
+//
+// Returns:
+//   - An error if v can't be encoded; errors writing the response are ignored, since the
+//     client has gone away
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	b := getJSONBuffer()
+	defer putJSONBuffer(b)
+	if err := b.encoder.Encode(v); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b.Bytes())
+	return nil
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+)
+
+// discardResponseWriter is a ResponseWriter that discards the body, so benchmarks measure
+// the handler rather than the recorder
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// SetWriteDeadline pretends to support deadlines, like the server's response writers
+func (w *discardResponseWriter) SetWriteDeadline(time.Time) error { return nil }
+
+// TestWriteJSON tests that responses are written at once, and not at all if encoding fails
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeJSON(w, http.StatusCreated, map[string]int{"count": 1}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != "{\"count\":1}\n" {
+		t.Errorf("Unexpected response: %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if err := writeJSON(w, http.StatusOK, func() {}); err == nil {
+		t.Fatal("Expected an error for a value that can't be encoded")
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("Expected nothing to be written, got %v %q", w.Header(), w.Body.String())
+	}
+
+	// A buffer that grew too large is not pooled, and pooled buffers start empty
+	large := strings.Repeat("x", maxPooledJSONBuffer)
+	if err := writeJSON(httptest.NewRecorder(), http.StatusOK, large); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	if b := getJSONBuffer(); b.Len() != 0 || b.Cap() > maxPooledJSONBuffer {
+		t.Errorf("Expected an empty, small buffer from the pool, got length %d and capacity %d", b.Len(), b.Cap())
+	}
+}
+
+// benchmarkNote is a note of typical size
+var benchmarkNote = &model.Note{
+	ID:      "20240102150405.000000.1a2b3c4d",
+	Title:   "Meeting notes",
+	Content: strings.Repeat("Discussed the roadmap and the next release. ", 10),
+}
+
+// BenchmarkWriteJSON compares writing a note through a pooled buffer with encoding it to the
+// response directly, as the handlers did before:
+//
+//	go test ./rest -run '^$' -bench WriteJSON -benchmem
+func BenchmarkWriteJSON(b *testing.B) {
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		w := &discardResponseWriter{header: make(http.Header)}
+		for b.Loop() {
+			if err := writeJSON(w, http.StatusOK, benchmarkNote); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		w := &discardResponseWriter{header: make(http.Header)}
+		for b.Loop() {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(benchmarkNote); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetAllNotes measures GET /api/notes with 100 notes, streamed through a pooled buffer:
+//
+//	go test ./rest -run '^$' -bench GetAllNotes -benchmem
+func BenchmarkGetAllNotes(b *testing.B) {
+	mockStorage := fake.New()
+	for i := range 100 {
+		note := *benchmarkNote
+		note.ID = fmt.Sprintf("note-%03d", i)
+		if err := mockStorage.Create(context.Background(), &note); err != nil {
+			b.Fatal(err)
+		}
+	}
+	handler := NewHandler(mockStorage)
+	req := httptest.NewRequest("GET", "/api/notes", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		handler.getAllNotes(&discardResponseWriter{header: make(http.Header)}, req)
+	}
+}
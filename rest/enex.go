@@ -0,0 +1,228 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// enexTimeLayout is the format of the timestamps in an ENEX file (e.g., 20240102T150405Z).
+const enexTimeLayout = "20060102T150405Z"
+
+// enexNote is a note of an Evernote export (ENEX). Tags, attributes, and attachments
+// (resources) are not imported.
+type enexNote struct {
+	Title   string `xml:"title"`
+	Content string `xml:"content"` // ENML, an XHTML dialect
+	Created string `xml:"created"`
+	Updated string `xml:"updated"`
+}
+
+// readENEXNotes calls fn for every note of an Evernote export, converted to a note with
+// plain-text content. ENEX notes have no IDs, so each gets an ID derived from its title,
+// creation time, and content: importing the same export again finds the notes it created.
+// It stops early if fn returns false, and returns an error if the body is not an ENEX file.
+func readENEXNotes(body io.Reader, fn func(index int, note *model.Note, err error) bool) error {
+	decoder := xml.NewDecoder(body)
+	root := false
+	for index := 0; ; {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			if !root {
+				return errors.New("expected an Evernote export (en-export)")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !root {
+			if start.Name.Local != "en-export" {
+				return errors.New("expected an Evernote export (en-export)")
+			}
+			root = true
+			continue
+		}
+		if start.Name.Local != "note" {
+			continue
+		}
+
+		var record enexNote
+		if err := decoder.DecodeElement(&record, &start); err != nil {
+			return fmt.Errorf("note %d: %w", index, err)
+		}
+		note, err := record.note()
+		if !fn(index, note, err) {
+			return nil
+		}
+		index++
+	}
+}
+
+// note converts an ENEX note to a note.
+func (n *enexNote) note() (*model.Note, error) {
+	content, err := enmlText(n.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content: %w", err)
+	}
+	note := &model.Note{Title: strings.TrimSpace(n.Title), Content: content}
+
+	if n.Created != "" {
+		if note.CreatedAt, err = time.Parse(enexTimeLayout, n.Created); err != nil {
+			return nil, fmt.Errorf("invalid created time %q", n.Created)
+		}
+	}
+	if n.Updated != "" {
+		if note.UpdatedAt, err = time.Parse(enexTimeLayout, n.Updated); err != nil {
+			return nil, fmt.Errorf("invalid updated time %q", n.Updated)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(note.Title + "\x00" + n.Created + "\x00" + n.Content))
+	note.ID = "enex-" + hex.EncodeToString(sum[:10])
+	return note, nil
+}
+
+// enmlBlocks are the ENML elements that start and end a line of text.
+var enmlBlocks = map[string]bool{
+	"div": true, "p": true, "li": true, "tr": true, "blockquote": true, "pre": true,
+	"ul": true, "ol": true, "table": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// blankLines matches runs of more than one empty line.
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// enmlText converts ENML, the XHTML dialect of Evernote notes, to plain text with Markdown
+// for headings, list items, checkboxes, and links. Formatting and attachments are dropped.
+func enmlText(enml string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(enml))
+	// ENML is XHTML; be lenient with HTML entities and unclosed elements
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var b strings.Builder
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	var links []string // href of the open links, innermost last
+	pre := 0           // Depth of <pre> elements, whose whitespace is kept
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			if enmlBlocks[name] {
+				newline()
+			}
+			switch name {
+			case "br":
+				b.WriteByte('\n')
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				b.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+			case "li":
+				b.WriteString("- ")
+			case "pre":
+				pre++
+			case "en-todo":
+				if enmlAttr(t, "checked") == "true" {
+					b.WriteString("[x] ")
+				} else {
+					b.WriteString("[ ] ")
+				}
+			case "a":
+				href := enmlAttr(t, "href")
+				if href != "" {
+					b.WriteByte('[')
+				}
+				links = append(links, href)
+			}
+		case xml.EndElement:
+			name := t.Name.Local
+			switch name {
+			case "pre":
+				pre--
+			case "a":
+				if len(links) > 0 {
+					if href := links[len(links)-1]; href != "" {
+						b.WriteString("](" + href + ")")
+					}
+					links = links[:len(links)-1]
+				}
+			}
+			if enmlBlocks[name] {
+				newline()
+			}
+		case xml.CharData:
+			text := string(t)
+			if pre == 0 {
+				text = collapseSpace(text)
+				// Drop the indentation at the start of a line
+				if b.Len() == 0 || strings.HasSuffix(b.String(), "\n") {
+					text = strings.TrimLeft(text, " ")
+				}
+			}
+			b.WriteString(text)
+		}
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	text := blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+// enmlAttr returns the value of an attribute of an element, or "" if it has none.
+func enmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// collapseSpace replaces every run of whitespace in text with a single space, like HTML.
+func collapseSpace(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text == "" {
+			return ""
+		}
+		return " "
+	}
+	collapsed := strings.Join(fields, " ")
+	if strings.TrimLeft(text, " \t\r\n") != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRight(text, " \t\r\n") != text {
+		collapsed += " "
+	}
+	return collapsed
+}
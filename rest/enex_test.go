@@ -0,0 +1,225 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// enexExport is an Evernote export with two notes, the second one without content
+const enexExport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export4.dtd">
+<en-export export-date="20240105T120000Z" application="Evernote" version="10.0">
+  <note>
+    <title>Shopping List</title>
+    <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><h2>Groceries</h2><div><en-todo checked="true"/>Milk</div><div><en-todo/>Eggs &amp; bread</div><div><br/></div>
+<ul><li>See <a href="https://example.com">the   recipe</a></li></ul></en-note>]]></content>
+    <created>20240102T150405Z</created>
+    <updated>20240103T090000Z</updated>
+    <tag>food</tag>
+  </note>
+  <note>
+    <title>Untitled</title>
+    <created>not a time</created>
+  </note>
+</en-export>`
+
+// TestReadENEXNotes tests converting the notes of an Evernote export
+func TestReadENEXNotes(t *testing.T) {
+	var notes []*model.Note
+	var errs []error
+	err := readENEXNotes(strings.NewReader(enexExport), func(index int, note *model.Note, err error) bool {
+		notes = append(notes, note)
+		errs = append(errs, err)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readENEXNotes failed: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("Expected 2 notes, got %d", len(notes))
+	}
+
+	note := notes[0]
+	if errs[0] != nil || note.Title != "Shopping List" {
+		t.Fatalf("Unexpected first note: %+v, %v", note, errs[0])
+	}
+	want := "## Groceries\n[x] Milk\n[ ] Eggs & bread\n\n- See [the recipe](https://example.com)"
+	if note.Content != want {
+		t.Errorf("Expected content %q, got %q", want, note.Content)
+	}
+	if !note.CreatedAt.Equal(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)) || !note.UpdatedAt.Equal(time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamps: %v, %v", note.CreatedAt, note.UpdatedAt)
+	}
+	if !strings.HasPrefix(note.ID, "enex-") {
+		t.Errorf("Expected a derived ID, got %q", note.ID)
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "invalid created time") {
+		t.Errorf("Expected an invalid creation time, got %v", errs[1])
+	}
+
+	// The IDs are stable, so importing the export again finds the same notes
+	readENEXNotes(strings.NewReader(enexExport), func(index int, again *model.Note, err error) bool {
+		if again.ID != note.ID {
+			t.Errorf("Expected the same ID on every import, got %q and %q", note.ID, again.ID)
+		}
+		return false
+	})
+
+	if err := readENEXNotes(strings.NewReader(`<html><body/></html>`), nil); err == nil {
+		t.Error("Expected an error for a document that is not an Evernote export")
+	}
+}
+
+// zipArchive creates a ZIP archive with the given files
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Modified: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestParseMarkdownNote tests converting Markdown files with and without front matter
+func TestParseMarkdownNote(t *testing.T) {
+	modified := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	// A file exported by GET /api/export?format=zip-md
+	exported := &model.Note{ID: "note-1", Title: "Title", Content: "Line 1\nLine 2\n", Tags: []string{"home", "work"},
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	data, err := markdownNote(exported)
+	if err != nil {
+		t.Fatalf("markdownNote failed: %v", err)
+	}
+	note, err := parseMarkdownNote("title.md", data, modified)
+	if err != nil {
+		t.Fatalf("parseMarkdownNote failed: %v", err)
+	}
+	if !reflect.DeepEqual(note, exported) {
+		t.Errorf("Expected the exported note back, got %+v", note)
+	}
+
+	// A Notable note and an Obsidian note without front matter
+	notable := "---\r\ntitle: Plans\r\ncreated: 2023-05-01T10:00:00Z\r\nmodified: '2023-05-02 11:30:00'\r\ntags: [work]\r\n---\r\n\r\n# Plans\r\n"
+	note, err = parseMarkdownNote("notes/plans.md", []byte(notable), modified)
+	if err != nil {
+		t.Fatalf("parseMarkdownNote failed: %v", err)
+	}
+	if note.Title != "Plans" || note.Content != "# Plans\n" || note.CreatedAt.Year() != 2023 || note.UpdatedAt.Hour() != 11 ||
+		!reflect.DeepEqual(note.Tags, []string{"work"}) {
+		t.Errorf("Unexpected Notable note: %+v", note)
+	}
+
+	note, err = parseMarkdownNote("Vault/Projects.md", []byte("---\ntags: '#project, ideas/later'\n---\n"), modified)
+	if err != nil || !reflect.DeepEqual(note.Tags, []string{"project", "ideas/later"}) {
+		t.Errorf("Expected the tags of the string, got %+v: %v", note, err)
+	}
+
+	note, err = parseMarkdownNote("Vault/Daily/2024-02-01.md", []byte("Today [[Plans]]"), modified)
+	if err != nil {
+		t.Fatalf("parseMarkdownNote failed: %v", err)
+	}
+	if note.Title != "2024-02-01" || note.Content != "Today [[Plans]]" || !strings.HasPrefix(note.ID, "md-") ||
+		!note.CreatedAt.Equal(modified) || !note.UpdatedAt.Equal(modified) {
+		t.Errorf("Unexpected Obsidian note: %+v", note)
+	}
+
+	for _, invalid := range []string{"---\ntitle: Unclosed\n", "---\ntitle: [\n---\n", "---\ncreated: yesterday\n---\n"} {
+		if _, err := parseMarkdownNote("invalid.md", []byte(invalid), modified); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestImportConverted tests importing Evernote and Markdown files through the admin endpoint
+func TestImportConverted(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	r := chi.NewRouter()
+	NewHandler(backend, WithAdminToken("secret")).RegisterRoutes(r)
+
+	post := func(query, contentType string, body []byte) (*httptest.ResponseRecorder, importSummary) {
+		req := httptest.NewRequest("POST", "/api/admin/import"+query, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var summary importSummary
+		if w.Header().Get("Content-Type") == "application/json" {
+			if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+				t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+			}
+		}
+		return w, summary
+	}
+
+	w, summary := post("?format=enex", "application/xml", []byte(enexExport))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := importStatuses(summary); !reflect.DeepEqual(got, []string{importCreated, importInvalid}) {
+		t.Errorf("Unexpected statuses: %v", got)
+	}
+	// Importing again skips the notes created before
+	if _, summary := post("?format=enex", "application/xml", []byte(enexExport)); summary.Skipped != 1 {
+		t.Errorf("Expected the note to be skipped, got %+v", summary)
+	}
+
+	archive := zipArchive(t, map[string]string{
+		"vault/Ideas.md":               "Some ideas",
+		"vault/.obsidian/workspace.md": "Not a note",
+		"vault/image.png":              "Not Markdown",
+	})
+	w, summary = post("?format=zip-md&on_conflict=overwrite", "application/zip", archive)
+	if w.Code != http.StatusOK || summary.Created != 1 || len(summary.Results) != 1 {
+		t.Fatalf("Expected one created note, got %d: %s", w.Code, w.Body.String())
+	}
+	note, err := backend.Get(context.Background(), summary.Results[0].ID)
+	if err != nil || note.Title != "Ideas" || note.Content != "Some ideas" {
+		t.Errorf("Unexpected imported note: %+v, %v", note, err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		body  []byte
+	}{
+		{"Unknown format", "?format=html", []byte("<html/>")},
+		{"Missing format", "", []byte(enexExport)},
+		{"Invalid policy", "?format=enex&on_conflict=merge", []byte(enexExport)},
+		{"Not a ZIP archive", "?format=zip-md", []byte("not a zip")},
+		{"Not an ENEX file", "?format=enex", []byte("not xml")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, _ := post(tt.query, "application/octet-stream", tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
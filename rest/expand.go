@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+)
+
+// maxExpansions limits how many related resources a single request can embed.
+const maxExpansions = 8
+
+// Expander loads a related resource (e.g., attachments) for a batch of notes.
+// It receives all notes of a response at once, so expanding a list of notes
+// costs one storage round trip per expansion instead of one per note (avoiding N+1 queries).
+type Expander interface {
+	// Expand returns the related resource for each note, keyed by note ID.
+	// Notes missing from the result are embedded with a null value.
+	Expand(ctx context.Context, notes []*model.Note) (map[string]any, error)
+}
+
+// ExpanderFunc is an adapter that allows an ordinary function to be used as an Expander.
+type ExpanderFunc func(ctx context.Context, notes []*model.Note) (map[string]any, error)
+
+// Expand calls f(ctx, notes).
+func (f ExpanderFunc) Expand(ctx context.Context, notes []*model.Note) (map[string]any, error) {
+	return f(ctx, notes)
+}
+
+// AttachmentsExpander returns an Expander that embeds the attachments of notes, as listed
+// by GET /api/notes/{id}/attachments, or nil if attachments are disabled (nil). The
+// attachments of all notes are listed at once.
+func AttachmentsExpander(attachments service.Attachments) Expander {
+	if attachments == nil {
+		return nil
+	}
+	return ExpanderFunc(func(ctx context.Context, notes []*model.Note) (map[string]any, error) {
+		ids := make([]string, len(notes))
+		for i, note := range notes {
+			ids[i] = note.ID
+		}
+		lists, err := attachments.ListMany(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		related := make(map[string]any, len(lists))
+		for id, list := range lists {
+			related[id] = list
+		}
+		return related, nil
+	})
+}
+
+// BacklinksExpander returns an Expander that embeds the notes linking to each note, as
+// returned by GET /api/notes/{id}/backlinks, in the time zone of the expanded notes. The
+// linking notes of all notes are read at once.
+func BacklinksExpander(notes service.Notes) Expander {
+	return ExpanderFunc(func(ctx context.Context, expanded []*model.Note) (map[string]any, error) {
+		backlinks, err := notes.BacklinksMany(ctx, expanded)
+		if err != nil {
+			return nil, err
+		}
+		loc := time.UTC
+		if len(expanded) > 0 {
+			loc = expanded[0].CreatedAt.Location()
+		}
+		related := make(map[string]any, len(backlinks))
+		for id, list := range backlinks {
+			related[id] = linkNotes(notesInTimezone(list, loc))
+		}
+		return related, nil
+	})
+}
+
+// WithExpander registers an expander for the given name of the ?expand= query parameter.
+// The expanded resource is embedded in the note object under the same name. A nil
+// expander isn't registered.
+func WithExpander(name string, expander Expander) HandlerOption {
+	return func(h *Handler) {
+		if expander == nil {
+			return
+		}
+		if h.expanders == nil {
+			h.expanders = make(map[string]Expander)
+		}
+		h.expanders[name] = expander
+	}
+}
+
+// parseExpand parses the comma-separated ?expand= query parameter.
+// It returns the requested expansions in order without duplicates,
+// or an error if an expansion is unknown or too many are requested.
+func (h *Handler) parseExpand(r *http.Request) ([]string, error) {
+	raw := r.URL.Query().Get("expand")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := h.expanders[name]; !ok {
+			return nil, fmt.Errorf("unknown expansion %q (available: %s)", name, h.availableExpansions())
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	if len(names) > maxExpansions {
+		return nil, fmt.Errorf("too many expansions (maximum %d)", maxExpansions)
+	}
+	return names, nil
+}
+
+// availableExpansions returns the registered expansion names as a sorted, comma-separated list.
+func (h *Handler) availableExpansions() string {
+	if len(h.expanders) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(h.expanders))
+	for name := range h.expanders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// expandNotes embeds the requested related resources into the JSON representation of each note,
+// with its links. Each expander is called once for the whole batch of notes.
+func (h *Handler) expandNotes(ctx context.Context, notes []*model.Note, names []string) ([]map[string]any, error) {
+	shaped := make([]map[string]any, len(notes))
+	for i, note := range notes {
+		obj, err := noteToMap(note)
+		if err != nil {
+			return nil, err
+		}
+		obj["links"] = newNoteLinks(note.ID)
+		shaped[i] = obj
+	}
+
+	for _, name := range names {
+		related, err := h.expanders[name].Expand(ctx, notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %w", name, err)
+		}
+		for i, note := range notes {
+			shaped[i][name] = related[note.ID]
+		}
+	}
+	return shaped, nil
+}
+
+// noteToMap converts a note to a generic JSON object, so extra fields can be embedded.
+func noteToMap(note *model.Note) (map[string]any, error) {
+	data, err := json.Marshal(note)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,246 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// countingExpander records how many times it was called and returns the note title in upper case
+type countingExpander struct {
+	calls int
+	err   error
+}
+
+// Expand returns one value per note, or the configured error
+func (e *countingExpander) Expand(ctx context.Context, notes []*model.Note) (map[string]any, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	result := make(map[string]any, len(notes))
+	for _, note := range notes {
+		result[note.ID] = strings.ToUpper(note.Title)
+	}
+	return result, nil
+}
+
+// setupExpandRouter creates a router with a "shout" expansion and two stored notes
+func setupExpandRouter(expander Expander) *chi.Mux {
+	mockStorage := fake.New()
+	mockStorage.Add(&model.Note{ID: "note-1", Title: "first"})
+	mockStorage.Add(&model.Note{ID: "note-2", Title: "second"})
+
+	r := chi.NewRouter()
+	NewHandler(mockStorage, WithExpander("shout", expander)).RegisterRoutes(r)
+	return r
+}
+
+// TestGetNote_Expand tests embedding a related resource into a single note
+func TestGetNote_Expand(t *testing.T) {
+	expander := &countingExpander{}
+	r := setupExpandRouter(expander)
+
+	req := httptest.NewRequest("GET", "/api/notes/note-1?expand=shout,shout", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["_id"] != "note-1" || response["title"] != "first" {
+		t.Errorf("Expected note fields to be preserved, got %v", response)
+	}
+	if response["shout"] != "FIRST" {
+		t.Errorf("Expected expanded field 'FIRST', got %v", response["shout"])
+	}
+	if expander.calls != 1 {
+		t.Errorf("Expected duplicate expansions to be called once, got %d calls", expander.calls)
+	}
+}
+
+// TestGetAllNotes_Expand tests that a list is expanded with a single batch call
+func TestGetAllNotes_Expand(t *testing.T) {
+	expander := &countingExpander{}
+	r := setupExpandRouter(expander)
+
+	req := httptest.NewRequest("GET", "/api/notes?expand=shout", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response) != 2 {
+		t.Fatalf("Expected 2 notes, got %d", len(response))
+	}
+	for _, note := range response {
+		if note["shout"] != strings.ToUpper(note["title"].(string)) {
+			t.Errorf("Unexpected expansion for note %v", note)
+		}
+	}
+	if expander.calls != 1 {
+		t.Errorf("Expected one batch call for the whole list, got %d", expander.calls)
+	}
+}
+
+// TestExpand_Errors tests error responses for invalid or failing expansions
+func TestExpand_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expander *countingExpander
+		code     int
+	}{
+		{"Unknown Expansion", "/api/notes/note-1?expand=attachments", &countingExpander{}, http.StatusBadRequest},
+		{"Unknown Expansion In List", "/api/notes?expand=shout,comments", &countingExpander{}, http.StatusBadRequest},
+		{"Expander Failure", "/api/notes/note-1?expand=shout", &countingExpander{err: errors.New("boom")}, http.StatusInternalServerError},
+		{"Empty Expansion", "/api/notes/note-1?expand=", &countingExpander{}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupExpandRouter(tt.expander)
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestExpand_NoExpanders tests that expansions are rejected when none are registered
+func TestExpand_NoExpanders(t *testing.T) {
+	r := chi.NewRouter()
+	NewHandler(fake.New()).RegisterRoutes(r)
+
+	req := httptest.NewRequest("GET", "/api/notes?expand=versions", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "available: none") {
+		t.Errorf("Expected error to list available expansions, got: %s", w.Body.String())
+	}
+}
+
+// countingStore is in-memory storage that counts the reads of single notes and attachment
+// lists, and of batches of them
+type countingStore struct {
+	*storage.InMemoryStorage
+	gets, batchGets, lists, batchLists int
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*model.Note, error) {
+	s.gets++
+	return s.InMemoryStorage.Get(ctx, id)
+}
+
+func (s *countingStore) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	s.batchGets++
+	return s.InMemoryStorage.GetMany(ctx, ids)
+}
+
+func (s *countingStore) ListAttachments(ctx context.Context, noteID string) ([]storage.Attachment, error) {
+	s.lists++
+	return s.InMemoryStorage.ListAttachments(ctx, noteID)
+}
+
+func (s *countingStore) ListAttachmentsMany(ctx context.Context, noteIDs []string) (map[string][]storage.Attachment, error) {
+	s.batchLists++
+	return s.InMemoryStorage.ListAttachmentsMany(ctx, noteIDs)
+}
+
+// TestExpand_Expanders tests that the attachments and backlinks of a list of notes are
+// expanded with one batch read each, rather than one read per note
+func TestExpand_Expanders(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{InMemoryStorage: storage.NewInMemoryStorage()}
+	for _, note := range []*model.Note{
+		{ID: "a", Title: "A", Content: "See [[c]]"},
+		{ID: "b", Title: "B", Content: "See [[c]] and [[a]]"},
+		{ID: "c", Title: "C", Content: "Nothing"},
+	} {
+		if err := backend.Create(ctx, note); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, err := backend.PutAttachment(ctx, id, storage.Attachment{Name: id + ".txt", ContentType: "text/plain"}, strings.NewReader(id)); err != nil {
+			t.Fatalf("PutAttachment failed: %v", err)
+		}
+	}
+	notes := service.New(backend)
+	attachments := service.NewAttachmentService(backend, nil)
+	r := chi.NewRouter()
+	NewHandler(backend, WithNoteService(notes), WithAttachments(attachments, 1024),
+		WithExpander("attachments", AttachmentsExpander(attachments)),
+		WithExpander("backlinks", BacklinksExpander(notes))).RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/notes?sort=title&expand=attachments,backlinks", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response []struct {
+		ID          string               `json:"_id"`
+		Attachments []storage.Attachment `json:"attachments"`
+		Backlinks   []struct {
+			ID    string    `json:"_id"`
+			Links noteLinks `json:"links"`
+		} `json:"backlinks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || len(response) != 3 {
+		t.Fatalf("Expected 3 notes, got %s: %v", w.Body.String(), err)
+	}
+	want := map[string]struct {
+		attachments string
+		backlinks   []string
+	}{
+		"a": {"a.txt", []string{"b"}},
+		"b": {"", nil},
+		"c": {"c.txt", []string{"a", "b"}},
+	}
+	for _, note := range response {
+		var names, backlinks []string
+		for _, attachment := range note.Attachments {
+			names = append(names, attachment.Name)
+		}
+		for _, linking := range note.Backlinks {
+			backlinks = append(backlinks, linking.ID)
+			if linking.Links.Self != "/api/notes/"+linking.ID {
+				t.Errorf("Expected the links of the linking note %s, got %+v", linking.ID, linking.Links)
+			}
+		}
+		if strings.Join(names, ",") != want[note.ID].attachments || strings.Join(backlinks, ",") != strings.Join(want[note.ID].backlinks, ",") {
+			t.Errorf("Note %s: unexpected attachments %v and backlinks %v", note.ID, names, backlinks)
+		}
+	}
+
+	if backend.gets != 0 || backend.lists != 0 || backend.batchGets != 1 || backend.batchLists != 1 {
+		t.Errorf("Expected one batch read per expansion, got %d gets, %d batch gets, %d lists, and %d batch lists",
+			backend.gets, backend.batchGets, backend.lists, backend.batchLists)
+	}
+}
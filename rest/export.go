@@ -0,0 +1,186 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// noteWriter writes exported notes to a response body in one export format.
+type noteWriter interface {
+	// WriteNote writes a single note.
+	WriteNote(note *model.Note) error
+
+	// Close finishes the export (e.g., closes a JSON array). It doesn't close the underlying writer.
+	Close() error
+}
+
+// exportFormat describes an export format of GET /api/export.
+type exportFormat struct {
+	contentType string                       // Content-Type of the response
+	extension   string                       // File name extension of the download
+	newWriter   func(w io.Writer) noteWriter // Creates a writer for the response body
+}
+
+// exportFormats lists the supported values of ?format= of GET /api/export.
+var exportFormats = map[string]exportFormat{
+	"ndjson": {contentType: "application/x-ndjson", extension: "ndjson", newWriter: newNDJSONWriter},
+	"json":   {contentType: "application/json", extension: "json", newWriter: newJSONArrayWriter},
+	"zip-md": {contentType: "application/zip", extension: "zip", newWriter: newZipMarkdownWriter},
+}
+
+// defaultExportFormat is the format used when ?format= is not given.
+const defaultExportFormat = "ndjson"
+
+// exportWriteTimeout is the time allowed for writing each note of an export. Exports may take
+// longer than the server's write timeout as a whole, so the deadline is extended for every note.
+const exportWriteTimeout = 30 * time.Second
+
+// exportNotes handles GET /api/export.
+// It streams all notes as a file download, in the format given by ?format=
+// (ndjson, the default, with one note per line, json, with a single array, or zip-md,
+// with a Markdown file per note in a ZIP archive).
+// Notes are read from the storage one at a time, so the export doesn't hold all notes in memory.
+//
+// The status code is sent with the first note. If reading the notes fails after that,
+// the connection is aborted, so that clients cannot mistake a truncated export for a complete one.
+func (h *Handler) exportNotes(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = defaultExportFormat
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("format must be one of: %s", strings.Join(exportFormatNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	var writer noteWriter
+	start := func() {
+		filename := fmt.Sprintf("notes-%s.%s", time.Now().UTC().Format("20060102-150405"), format.extension)
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		writer = format.newWriter(w)
+	}
+
+	err := h.notes.Stream(r.Context(), func(note *model.Note) error {
+		if writer == nil {
+			start()
+		}
+		// Not all response writers support deadlines (e.g., in tests); the server's timeout applies then
+		_ = controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		return writer.WriteNote(note)
+	})
+	if err == nil && writer == nil {
+		// No notes: the export is still a valid, empty file
+		start()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if writer != nil {
+		log.Printf("%sExport failed after the response was started: %v", requestid.LogPrefix(r.Context()), err)
+		panic(http.ErrAbortHandler)
+	}
+	if storageUnavailable(w, err) {
+		return
+	}
+	http.Error(w, "Failed to export notes", http.StatusInternalServerError)
+}
+
+// exportFormatNames returns the names of the supported export formats, sorted.
+func exportFormatNames() []string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ndjsonWriter writes notes as newline-delimited JSON, one note per line.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+// newNDJSONWriter creates a note writer for the ndjson export format.
+func newNDJSONWriter(w io.Writer) noteWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+// WriteNote writes a note on its own line.
+func (n *ndjsonWriter) WriteNote(note *model.Note) error {
+	return n.encoder.Encode(note)
+}
+
+// Close does nothing: NDJSON has no trailer.
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonArrayWriter writes notes as the elements of a single JSON array. Each note is encoded
+// into a pooled buffer (see jsonBuffer) and written with its separator in a single call.
+type jsonArrayWriter struct {
+	w     io.Writer
+	buf   *jsonBuffer
+	count int  // Number of notes written
+	links bool // Whether the notes are written with their links (see withLinks)
+}
+
+// newJSONArrayWriter creates a note writer for the json export format.
+func newJSONArrayWriter(w io.Writer) noteWriter {
+	return &jsonArrayWriter{w: w, buf: getJSONBuffer()}
+}
+
+// newNoteListWriter creates a note writer for the lists of the notes API, which write the
+// notes as the json export format does, with their links.
+func newNoteListWriter(w io.Writer) noteWriter {
+	return &jsonArrayWriter{w: w, buf: getJSONBuffer(), links: true}
+}
+
+// WriteNote writes a note as the next array element, opening the array before the first one.
+func (j *jsonArrayWriter) WriteNote(note *model.Note) error {
+	separator := byte(',')
+	if j.count == 0 {
+		separator = '['
+	}
+	j.buf.Reset()
+	j.buf.WriteByte(separator)
+	var v any = note
+	if j.links {
+		v = withLinks(note)
+	}
+	if err := j.buf.encoder.Encode(v); err != nil {
+		return err
+	}
+	if _, err := j.w.Write(j.buf.Bytes()); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+// Close closes the array, writing an empty one if there were no notes, and returns the
+// buffer to the pool.
+func (j *jsonArrayWriter) Close() error {
+	putJSONBuffer(j.buf)
+	closing := "]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+)
+
+// streamFailingStorage is a storage whose stream fails after the given number of notes
+type streamFailingStorage struct {
+	*fake.Storage
+	failAfter int
+}
+
+var errStreamFailed = errors.New("stream failed")
+
+func (s *streamFailingStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	for i := range s.failAfter {
+		if err := fn(&model.Note{ID: string(rune('a' + i)), Title: "Title"}); err != nil {
+			return err
+		}
+	}
+	return errStreamFailed
+}
+
+// exportRequest serves GET /api/export with the given query through the handler's routes
+func exportRequest(t *testing.T, backend storage.NoteStorage, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := chi.NewRouter()
+	NewHandler(backend, WithAdminToken("admin-token")).RegisterRoutes(r)
+
+	req := httptest.NewRequest("GET", "/api/export"+query, nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestExportNotes tests the export endpoint in every format
+func TestExportNotes(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	for _, title := range []string{"First", "Second"} {
+		if err := backend.Create(ctx, model.NewNote(title, "Content")); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		w := exportRequest(t, backend, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected Content-Type application/x-ndjson, got %q", ct)
+		}
+		disposition := w.Header().Get("Content-Disposition")
+		if !strings.HasPrefix(disposition, `attachment; filename="notes-`) || !strings.HasSuffix(disposition, `.ndjson"`) {
+			t.Errorf("Unexpected Content-Disposition: %q", disposition)
+		}
+
+		var titles []string
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var note model.Note
+			if err := json.Unmarshal(scanner.Bytes(), &note); err != nil {
+				t.Fatalf("Failed to unmarshal line %q: %v", scanner.Text(), err)
+			}
+			titles = append(titles, note.Title)
+		}
+		if len(titles) != 2 {
+			t.Errorf("Expected 2 lines, got %v", titles)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := exportRequest(t, backend, "?format=json")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %q", ct)
+		}
+		var notes []model.Note
+		if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+			t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+		}
+		if len(notes) != 2 {
+			t.Errorf("Expected 2 notes, got %d", len(notes))
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		w := exportRequest(t, storage.NewInMemoryStorage(), "?format=json")
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+			t.Errorf("Expected an empty array, got %d %q", w.Code, w.Body.String())
+		}
+		w = exportRequest(t, storage.NewInMemoryStorage(), "?format=ndjson")
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Expected an empty file, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		w := exportRequest(t, backend, "?format=xml")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+// TestExportNotesErrors tests failures before and after the response was started
+func TestExportNotesErrors(t *testing.T) {
+	t.Run("BeforeFirstNote", func(t *testing.T) {
+		w := exportRequest(t, &streamFailingStorage{Storage: fake.New()}, "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+
+	t.Run("AfterFirstNote", func(t *testing.T) {
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("Expected the handler to abort the response, got %v", recovered)
+			}
+		}()
+		exportRequest(t, &streamFailingStorage{Storage: fake.New(), failAfter: 1}, "")
+		t.Error("Expected the handler to panic")
+	})
+}
@@ -5,19 +5,85 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
+	"golang-simple-notes/blob"
+	"golang-simple-notes/jobs"
 	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
 	"golang-simple-notes/storage"
+	"golang-simple-notes/webhook"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 // Handler handles HTTP requests for notes.
-// It uses a storage implementation to persist and retrieve notes.
+// Note operations are delegated to the note service, which holds the business logic
+// shared with the gRPC API; the storage is used directly for health checks and
+// maintenance tasks only.
 // This follows the dependency injection pattern, allowing the handler
 // to work with any storage implementation that satisfies the NoteStorage interface.
 type Handler struct {
-	storage storage.NoteStorage // Storage backend for notes
+	storage  storage.NoteStorage // Storage backend for notes
+	notes    service.Notes       // Business logic of notes
+	watchers *webhook.Watchers   // Per-note watch registry (optional)
+	hooks    *webhook.Hooks      // Webhook registry for the admin endpoints (optional)
+	jobs     *jobs.Runner        // Background jobs, for the admin endpoints and asynchronous imports (optional)
+
+	blobs         blob.BlobStore // Blob store of the exports of POST /api/export (optional)
+	blobURLExpiry time.Duration  // Validity of the download URLs of the blob store
+
+	broadcaster *webhook.Broadcaster  // Event stream of the WebSocket endpoint (optional)
+	collab      service.Collaboration // Collaborative editing over the WebSocket endpoint (optional)
+	settings    *Settings             // Runtime settings of the REST server, for the WebSocket origins (optional)
+	templates   service.Templates     // Note templates, stored apart from the notes (optional)
+	quotas      *service.Quotas       // Quotas of the owners of notes, for GET /api/quota (optional)
+	activity    *service.ActivityLog  // Counts of recent changes, for GET /api/activity (optional)
+	summaries   service.Summaries     // Summaries of notes, for POST /api/notes/{id}/summarize (optional)
+	search      service.Search        // Searches of notes, by text or meaning, for GET /api/notes/search (optional)
+
+	attachments       service.Attachments // Files attached to notes (optional)
+	attachmentMaxSize int64               // Maximum size of an uploaded attachment, in bytes
+
+	expanders     map[string]Expander        // Related resources available via ?expand= (optional)
+	verifier      *storage.Verifier          // Dual-write verifier for the divergence report (optional)
+	replicated    *storage.ReplicatedStorage // Asynchronous dual-write storage for reconciliation (optional)
+	checks        map[string]HealthCheck     // Additional dependency checks for /health/ready (optional)
+	startupChecks map[string]HealthCheck     // Initialization checks for /health/startup (optional)
+	adminToken    string                     // Bearer token required by the admin endpoints (optional)
+	putCreates    bool                       // Whether PUT /api/notes/{id} creates missing notes
+	purge         purgeGuard                 // Pending confirmation of POST /api/admin/purge
+}
+
+// HandlerOption configures optional features of a Handler.
+type HandlerOption func(*Handler)
+
+// WithWatchers enables the per-note watch endpoints, backed by the given registry.
+func WithWatchers(watchers *webhook.Watchers) HandlerOption {
+	return func(h *Handler) {
+		h.watchers = watchers
+	}
+}
+
+// WithNoteService makes the handler use a note service shared with other transports,
+// instead of a service of its own that doesn't publish note events.
+func WithNoteService(notes service.Notes) HandlerOption {
+	return func(h *Handler) {
+		h.notes = notes
+	}
+}
+
+// WithPutCreates makes PUT /api/notes/{id} create the note with the ID of the URL if it
+// doesn't exist (responding with 201 Created), instead of responding with 404 Not Found.
+func WithPutCreates(enabled bool) HandlerOption {
+	return func(h *Handler) {
+		h.putCreates = enabled
+	}
 }
 
 // NewHandler creates a new Handler instance with the provided storage.
@@ -25,90 +91,353 @@ type Handler struct {
 //
 // Parameters:
 //   - storage: An implementation of the NoteStorage interface
+//   - opts: Optional features to enable (e.g., WithWatchers)
 //
 // Returns:
 //   - A pointer to a new Handler instance
-func NewHandler(storage storage.NoteStorage) *Handler {
-	return &Handler{
+func NewHandler(storage storage.NoteStorage, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		storage: storage,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.notes == nil {
+		h.notes = service.New(storage)
+	}
+	return h
 }
 
 // RegisterRoutes registers the handler's routes with the provided router.
 // This sets up all the API endpoints for the Notes API.
 //
 // The routes are:
-//   - GET /health - Health check endpoint
-//   - GET /api/notes - Get all notes
+//   - GET /health - Health check endpoint (same as /health/live)
+//   - GET /health/live - Liveness check, succeeds while the process is running
+//   - GET /health/ready - Readiness check, pinging the storage backend and other dependencies
+//   - GET /health/startup - Startup check, succeeds once initialization has finished
+//   - GET /api/notes - Get all notes (with optional filtering, sorting, and pagination)
+//   - GET /api/notes/count - Count the notes (with optional filtering)
+//   - GET /api/notes/recent - Get the notes updated within a time window (?window=, with the options of GET /api/notes)
+//   - GET /api/notes/search - Search the notes by text or, if enabled, by meaning (?q=, ?mode=text or semantic, ?limit=, ?highlight=offsets, html, or none)
 //   - POST /api/notes - Create a new note
 //   - GET /api/notes/{id} - Get a note by ID
-//   - PUT /api/notes/{id} - Update a note
+//   - PUT /api/notes/{id} - Update a note (or create it, if enabled with WithPutCreates)
 //   - DELETE /api/notes/{id} - Delete a note
+//   - POST /api/notes/from-template/{id} - Create a note from a template (only if templates are enabled)
+//   - POST /api/notes/{id}/duplicate - Create a copy of a note
+//   - POST /api/notes/{id}/summarize - Summarize a note and store the summary on it (only if a summarizer is configured)
+//   - GET /api/notes/{id}/backlinks - Get the notes linking to a note with [[id]] or [[title]]
+//   - GET /api/notes/{id}/attachments - List a note's attachments (only if attachments are enabled)
+//   - GET, PUT, DELETE /api/notes/{id}/attachments/{name} - Download, upload, or delete an attachment (only if attachments are enabled)
+//   - GET /api/notes/{id}/attachments/{name}/thumbnail - Thumbnail of an image attachment (only if thumbnails are enabled)
+//   - POST /api/notes/{id}/watch - Watch a note (only if watchers are enabled)
+//   - GET /api/notes/{id}/watch - List a note's watches (only if watchers are enabled)
+//   - DELETE /api/notes/{id}/watch/{watchID} - Remove a watch (only if watchers are enabled)
+//   - GET, POST /api/templates - List or create note templates (only if templates are enabled)
+//   - GET, PUT, DELETE /api/templates/{id} - Get, update, or delete a template (only if templates are enabled)
+//   - GET /api/migration/divergences - Dual-write divergence report (only if verification is enabled)
+//   - POST /api/migration/reconcile - Reconcile the dual-write target (only in asynchronous mode)
+//   - GET /api/stats - Statistics about the notes (count, content size, creation times, storage backend)
+//   - GET /api/quota - Usage and limits of the client's quota (only if quotas are enabled)
+//   - GET /api/activity - Numbers of notes created, updated, and deleted over time windows (only if activity is counted)
+//   - GET /api/export - Download all notes (NDJSON, a JSON array, or Markdown files in a ZIP archive) (only with an admin token)
+//   - POST /api/export - Export all notes to the blob store as a background job (only with an admin token, if the blob store and job runner are enabled)
+//   - GET /api/blobs/{key} - Download a file of the blob store from a signed URL (only for blob stores without URLs of their own)
+//   - POST /api/import - Import notes (NDJSON or a JSON array) with a conflict policy, as a background job with ?async=true (only with an admin token)
+//   - GET /api/ws - WebSocket stream of note events (only if the broadcaster is enabled)
+//   - POST /api/admin/purge - Delete all notes, confirmed with a token from a previous request (only with an admin token)
+//   - POST /api/admin/reindex - Rebuild the indexes of the storage backend (only with an admin token)
+//   - POST /api/admin/search/reindex - Embed and index every note for semantic searches (only with an admin token and semantic search)
+//   - POST /api/admin/compact - Compact the storage backend, e.g., CouchDB compaction (only with an admin token)
+//   - POST /api/admin/import - Import notes from an Evernote export or Markdown files (only with an admin token)
+//   - GET, PUT /api/admin/loglevel - Get or change the log level at runtime (only with an admin token)
+//   - GET, POST /api/admin/webhooks - List or register webhooks (only with an admin token, if webhooks are enabled)
+//   - DELETE /api/admin/webhooks/{hookID} - Remove a webhook (only with an admin token, if webhooks are enabled)
+//   - GET /api/admin/webhooks/deliveries, /api/admin/webhooks/{hookID}/deliveries - Recent webhook deliveries (only with an admin token)
+//   - GET /api/admin/jobs, /api/admin/jobs/{jobID} - Status of background jobs (only with an admin token, if the job runner is enabled)
+//
+// The /api/admin, /api/export, and /api/import routes require the admin token, and aren't
+// registered without one.
 //
-// The {id} routes use the ValidateNoteIDMiddleware to ensure the ID is valid.
+// The {id} routes use the ValidateNoteIDMiddleware to ensure the ID is valid. The routes
+// reading and writing notes also serve JSON:API documents (see jsonAPIMiddleware).
 func (h *Handler) RegisterRoutes(r chi.Router) {
-	// Health check endpoint
-	r.Get("/health", h.handleHealth)
+	// Health check endpoints, suitable for Kubernetes liveness, readiness, and startup probes
+	r.Get("/health", h.handleLive)
+	r.Get("/health/live", h.handleLive)
+	r.Get("/health/ready", h.handleReady)
+	r.Get("/health/startup", h.handleStartup)
+
+	// Dual-write divergence report
+	if h.verifier != nil {
+		r.Get("/api/migration/divergences", h.getDivergences)
+	}
+	if h.replicated != nil {
+		r.Post("/api/migration/reconcile", h.reconcile)
+	}
+
+	// Statistics about the notes
+	r.Get("/api/stats", h.getStats)
+
+	// Usage of the client's quota
+	if h.quotas != nil {
+		r.Get("/api/quota", h.getQuota)
+	}
+
+	// Recent changes of the notes
+	if h.activity != nil {
+		r.Get("/api/activity", h.getActivity)
+	}
+
+	// Export of all notes as a file download, and import of such files: bulk operations
+	// on every note, which require the admin token like the admin API
+	if h.adminToken != "" {
+		r.Group(func(r chi.Router) {
+			r.Use(requireBearerToken(h.adminToken, "admin"))
+			r.Get("/api/export", h.exportNotes)
+			r.Post("/api/import", h.importNotes)
+			if h.blobs != nil && h.jobs != nil {
+				r.Post("/api/export", h.submitExport)
+			}
+		})
+	}
+	if _, ok := h.blobs.(blob.URLVerifier); ok {
+		r.Get("/api/blobs/*", h.downloadBlob)
+	}
+
+	// Real-time note events for WebSocket clients
+	if h.broadcaster != nil {
+		r.Get("/api/ws", h.handleWebSocket)
+	}
+
+	// Admin API for operators
+	h.registerAdminRoutes(r)
+
+	// Note templates
+	h.registerTemplateRoutes(r)
 
 	// Group all note-related routes under /api/notes
 	r.Route("/api/notes", func(r chi.Router) {
-		// Routes for operations on all notes
-		r.Get("/", h.getAllNotes) // Get all notes
-		r.Post("/", h.createNote) // Create a new note
+		// Routes for operations on all notes, which also serve JSON:API documents
+		notes := r.With(jsonAPIMiddleware)
+		notes.Get("/", h.getAllNotes)          // Get all notes
+		notes.Post("/", h.createNote)          // Create a new note
+		notes.Get("/count", h.countNotes)      // Count the notes
+		notes.Get("/recent", h.getRecentNotes) // Notes updated recently
+		if h.search != nil {
+			notes.Get("/search", h.searchNotes) // Search the notes by text or meaning
+		}
+		if h.templates != nil {
+			// Create a note from a template
+			r.With(ValidateNoteIDMiddleware).Post("/from-template/{id}", h.createNoteFromTemplate)
+		}
 
 		// Routes for operations on a specific note
 		r.Route("/{id}", func(r chi.Router) {
 			// Add middleware to validate the note ID
 			r.Use(ValidateNoteIDMiddleware)
-			r.Get("/", h.getNote)       // Get a note by ID
-			r.Put("/", h.updateNote)    // Update a note
-			r.Delete("/", h.deleteNote) // Delete a note
+			note := r.With(jsonAPIMiddleware)
+			note.Get("/", h.getNote)                 // Get a note by ID
+			note.Put("/", h.updateNote)              // Update a note
+			note.Delete("/", h.deleteNote)           // Delete a note
+			note.Get("/backlinks", h.getBacklinks)   // Notes linking to a note
+			note.Post("/duplicate", h.duplicateNote) // Copy a note
+
+			if h.summaries != nil {
+				r.Post("/summarize", h.summarizeNote) // Summarize a note
+			}
+
+			// Files attached to the note
+			h.registerAttachmentRoutes(r)
+
+			if h.watchers != nil {
+				r.Post("/watch", h.createWatch)             // Watch a note
+				r.Get("/watch", h.listWatches)              // List a note's watches
+				r.Delete("/watch/{watchID}", h.deleteWatch) // Remove a watch
+			}
 		})
 	})
 }
 
-// handleHealth handles the health check endpoint (GET /health).
-// It returns a simple "OK" response with a 200 status code to indicate that the service is running.
-// This endpoint can be used by load balancers or monitoring tools to check if the service is healthy.
-func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK) // Set the status code to 200 OK
-	if _, err := w.Write([]byte("OK")); err != nil {
-		_ = err // cannot change status code; ignore write error
-	}
-}
-
 // getAllNotes handles GET /api/notes.
-// It retrieves all notes from the storage and returns them as a JSON array.
+// It retrieves the notes from the storage and returns them as a JSON array.
 // If there are no notes, it returns an empty array.
+// The list can be filtered, sorted, and paginated (see parseListOptions), and
+// related resources can be embedded with ?expand= (see parseExpand).
+//
+// Without expansions, the array is written one note at a time as the notes are read (see
+// storage.StreamList), so large lists aren't held in memory. As with exports, the status code
+// is sent with the first note, and the connection is aborted if reading fails after that.
 func (h *Handler) getAllNotes(w http.ResponseWriter, r *http.Request) {
-	// Get all notes from the storage
-	notes, err := h.storage.GetAll(r.Context())
+	// Parse the list query before touching the storage
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeNotes(w, r, opts)
+}
+
+// writeNotes writes the notes matching a list query, as GET /api/notes does, with the
+// related resources requested with ?expand=.
+func (h *Handler) writeNotes(w http.ResponseWriter, r *http.Request, opts storage.ListOptions) {
+	// Parse the requested expansions before touching the storage
+	expand, err := h.parseExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	loc, err := parseTimezone(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(expand) > 0 {
+		h.getExpandedNotes(w, r, opts, expand, loc)
+		return
+	}
+
+	// Report the number of matching notes regardless of pagination, for pagination controls.
+	// The header is sent before the first note, so the notes are counted first.
+	total, err := h.notes.Count(r.Context(), opts)
+	if err != nil {
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
 		// If there's an error, return a 500 Internal Server Error
 		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
 
-	// Set the Content-Type header to application/json
-	w.Header().Set("Content-Type", "application/json")
+	controller := http.NewResponseController(w)
+	var writer noteWriter
+	start := func() {
+		w.Header().Set("Content-Type", "application/json")
+		writer = newNoteListWriter(w)
+	}
+	err = h.notes.StreamList(r.Context(), opts, func(note *model.Note) error {
+		if writer == nil {
+			start()
+		}
+		// Not all response writers support deadlines (e.g., in tests); the server's timeout applies then
+		_ = controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		return writer.WriteNote(inTimezone(note, loc))
+	})
+	if err == nil && writer == nil {
+		// No matching notes: an empty array
+		start()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
 
-	// Encode the notes as JSON and write to the response
-	if err := json.NewEncoder(w).Encode(notes); err != nil {
-		// If encoding fails, return a 500 Internal Server Error
+	if writer != nil {
+		log.Printf("%sListing notes failed after the response was started: %v", requestid.LogPrefix(r.Context()), err)
+		panic(http.ErrAbortHandler)
+	}
+	if storageUnavailable(w, err) {
+		return
+	}
+	http.Error(w, "Failed to get notes", http.StatusInternalServerError)
+}
+
+// getExpandedNotes writes the notes of GET /api/notes with related resources embedded,
+// which are looked up for all notes of the list at once, with their timestamps in loc.
+func (h *Handler) getExpandedNotes(w http.ResponseWriter, r *http.Request, opts storage.ListOptions, expand []string, loc *time.Location) {
+	// Get the matching notes from the storage
+	notes, err := h.notes.List(r.Context(), opts)
+	if err != nil {
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
+		// If there's an error, return a 500 Internal Server Error
+		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
+		return
+	}
+
+	// Without pagination, the number of matching notes is the number of notes returned
+	total := len(notes)
+	if opts.Limit > 0 || opts.Offset > 0 {
+		total, err = h.notes.Count(r.Context(), opts)
+		if err != nil {
+			if storageUnavailable(w, err) {
+				return
+			}
+			http.Error(w, "Failed to count notes", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+
+	shaped, err := h.expandNotes(r.Context(), notesInTimezone(notes, loc), expand)
+	if err != nil {
+		http.Error(w, "Failed to expand notes", http.StatusInternalServerError)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, shaped); err != nil {
 		http.Error(w, "Failed to encode notes", http.StatusInternalServerError)
 		return
 	}
 }
 
+// duplicateNote handles POST /api/notes/{id}/duplicate.
+// It creates a copy of a note with a new ID, fresh timestamps, and " (copy)" appended
+// to its title, and returns the copy as JSON with a 201 Created.
+// If the note doesn't exist, it returns a 404 Not Found.
+func (h *Handler) duplicateNote(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	note, err := h.notes.Duplicate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoteNotFound) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to duplicate note", http.StatusInternalServerError)
+		return
+	}
+
+	setLocation(w, note)
+	if err := writeJSON(w, http.StatusCreated, withLinks(note)); err != nil {
+		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
+		return
+	}
+}
+
 // getNote handles GET /api/notes/{id}.
 // It retrieves a note by its ID from the storage and returns it as JSON.
 // If the note doesn't exist, it returns a 404 Not Found.
+// Related resources can be embedded with ?expand= (see parseExpand), and the timestamps
+// are returned in the time zone requested with ?tz= (see parseTimezone).
 func (h *Handler) getNote(w http.ResponseWriter, r *http.Request) {
 	// Get the note ID from the URL path parameter
 	id := chi.URLParam(r, "id")
 
-	// Get the note from the storage
-	note, err := h.storage.Get(r.Context(), id)
+	// Parse the requested expansions before touching the storage
+	expand, err := h.parseExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	loc, err := parseTimezone(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get the note from the storage, counting the view
+	note, err := h.notes.View(r.Context(), id)
 	if err != nil {
 		// Handle specific error cases
 		if err == storage.ErrNoteNotFound {
@@ -116,16 +445,29 @@ func (h *Handler) getNote(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Note not found", http.StatusNotFound)
 			return
 		}
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
 		// For any other error, return a 500 Internal Server Error
 		http.Error(w, "Failed to get note", http.StatusInternalServerError)
 		return
 	}
 
-	// Set the Content-Type header to application/json
-	w.Header().Set("Content-Type", "application/json")
+	// Embed the requested related resources
+	note = inTimezone(note, loc)
+	var body any = withLinks(note)
+	if len(expand) > 0 {
+		shaped, err := h.expandNotes(r.Context(), []*model.Note{note}, expand)
+		if err != nil {
+			http.Error(w, "Failed to expand note", http.StatusInternalServerError)
+			return
+		}
+		body = shaped[0]
+	}
 
-	// Encode the note as JSON and write to the response
-	if err := json.NewEncoder(w).Encode(note); err != nil {
+	// Encode the note as JSON and write it to the response
+	if err := writeJSON(w, http.StatusOK, body); err != nil {
 		// If encoding fails, return a 500 Internal Server Error
 		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
 		return
@@ -134,76 +476,140 @@ func (h *Handler) getNote(w http.ResponseWriter, r *http.Request) {
 
 // createNote handles POST /api/notes.
 // It creates a new note from the request body and returns the created note as JSON.
-// The note ID is generated automatically.
+// The note ID and timestamps are set automatically; other fields of the body are ignored.
 func (h *Handler) createNote(w http.ResponseWriter, r *http.Request) {
-	var note model.Note
+	var body model.Note
 
 	// Decode the request body into a Note struct
-	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		// If decoding fails, return a 400 Bad Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	// Create the note in the storage
-	if err := h.storage.Create(r.Context(), &note); err != nil {
+	note, err := h.notes.Create(r.Context(), service.NoteInput{Title: body.Title, Content: body.Content, Color: body.Color, Icon: body.Icon,
+		Tags: body.Tags})
+	if err != nil {
+		// If the note is invalid (e.g., empty), return a 400 Bad Request
+		if errors.Is(err, service.ErrInvalidNote) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
 		// If creation fails, return a 500 Internal Server Error
 		http.Error(w, "Failed to create note", http.StatusInternalServerError)
 		return
 	}
 
-	// Set the Content-Type header to application/json
-	w.Header().Set("Content-Type", "application/json")
-
-	// Set the status code to 201 Created
-	w.WriteHeader(http.StatusCreated)
-
-	// Encode the created note as JSON and write to the response
-	if err := json.NewEncoder(w).Encode(note); err != nil {
+	// Encode the created note as JSON and write it to the response with a 201 Created and its location
+	setLocation(w, note)
+	if err := writeJSON(w, http.StatusCreated, withLinks(note)); err != nil {
 		// If encoding fails, return a 500 Internal Server Error
 		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
 		return
 	}
 }
 
+// updateBody is the body of PUT /api/notes/{id}: the fields clients set, the preconditions
+// of conditional updates, and the immutable fields, which are only compared with the note.
+type updateBody struct {
+	ID        *string    `json:"_id"`        // Must be the ID of the URL, if given
+	Rev       string     `json:"_rev"`       // Revision the update is based on (optional)
+	Title     string     `json:"title"`      // New title
+	Content   string     `json:"content"`    // New content
+	Color     string     `json:"color"`      // New color (optional)
+	Icon      string     `json:"icon"`       // New icon (optional)
+	Tags      []string   `json:"tags"`       // New tags (optional)
+	CreatedAt *time.Time `json:"created_at"` // Must be the creation time of the note, if given
+	UpdatedAt time.Time  `json:"updated_at"` // Update time the update is based on (optional)
+}
+
 // updateNote handles PUT /api/notes/{id}.
-// It updates an existing note with the data from the request body and returns the updated note as JSON.
-// If the note doesn't exist, it returns a 404 Not Found.
+// It updates the title, content, color, icon, and tags of an existing note with the data from the request body
+// and returns the updated note as JSON. If the body has a _rev, the update is rejected with
+// 409 Conflict on backends that track revisions, unless the revision is still current.
+// If the body has an updated_at, the update is rejected with 409 Conflict on any backend,
+// unless the note was last updated at that time. The ID and creation time of a note never
+// change: a body with another _id or created_at is rejected with 422 Unprocessable Entity,
+// and the other fields set by the server (e.g., owner or views) are ignored.
+// If the note doesn't exist, it returns a 404 Not Found, or, if enabled with WithPutCreates,
+// creates the note with the ID of the URL and returns it with a 201 Created (unless the
+// body has a _rev or updated_at, which expect an existing note).
 func (h *Handler) updateNote(w http.ResponseWriter, r *http.Request) {
 	// Get the note ID from the URL path parameter
+	// This ensures the correct note is updated, regardless of any ID in the request body
 	id := chi.URLParam(r, "id")
 
-	var note model.Note
+	var body updateBody
 
-	// Decode the request body into a Note struct
-	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+	// Decode the request body
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		// If decoding fails, return a 400 Bad Request
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	// Set the note ID to the one from the URL path
-	// This ensures the correct note is updated, regardless of any ID in the request body
-	note.ID = id
+	// The ID of the body may only repeat the ID of the URL
+	if body.ID != nil && *body.ID != id {
+		http.Error(w, "_id cannot be changed", http.StatusUnprocessableEntity)
+		return
+	}
 
 	// Update the note in the storage
-	if err := h.storage.Update(r.Context(), &note); err != nil {
+	input := service.NoteInput{Title: body.Title, Content: body.Content, Color: body.Color, Icon: body.Icon,
+		Tags: body.Tags, Rev: body.Rev, UpdatedAt: body.UpdatedAt}
+	if body.CreatedAt != nil {
+		input.CreatedAt = *body.CreatedAt
+	}
+	var note *model.Note
+	var created bool
+	var err error
+	if h.putCreates {
+		note, created, err = h.notes.Upsert(r.Context(), id, input)
+	} else {
+		note, err = h.notes.Update(r.Context(), id, input)
+	}
+	if err != nil {
 		// Handle specific error cases
+		if errors.Is(err, service.ErrInvalidNote) {
+			// If the note is invalid (e.g., empty), return a 400 Bad Request
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrImmutableField) {
+			// If the body changes the creation time, return a 422 Unprocessable Entity
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		if err == storage.ErrNoteNotFound {
 			// If the note doesn't exist, return a 404 Not Found
 			http.Error(w, "Note not found", http.StatusNotFound)
 			return
 		}
+		// If the note was modified concurrently and the storage rejects the update, return a 409 Conflict
+		if errors.Is(err, storage.ErrConflict) {
+			http.Error(w, "Note was modified concurrently; fetch it and retry", http.StatusConflict)
+			return
+		}
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
 		// For any other error, return a 500 Internal Server Error
 		http.Error(w, "Failed to update note", http.StatusInternalServerError)
 		return
 	}
 
-	// Set the Content-Type header to application/json
-	w.Header().Set("Content-Type", "application/json")
-
-	// Encode the updated note as JSON and write to the response
-	if err := json.NewEncoder(w).Encode(note); err != nil {
+	// Encode the updated note as JSON and write it to the response, with a 201 Created if it was created
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		setLocation(w, note)
+	}
+	if err := writeJSON(w, status, withLinks(note)); err != nil {
 		// If encoding fails, return a 500 Internal Server Error
 		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
 		return
@@ -219,13 +625,17 @@ func (h *Handler) deleteNote(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	// Delete the note from the storage
-	if err := h.storage.Delete(r.Context(), id); err != nil {
+	if err := h.notes.Delete(r.Context(), id); err != nil {
 		// Handle specific error cases
 		if err == storage.ErrNoteNotFound {
 			// If the note doesn't exist, return a 404 Not Found
 			http.Error(w, "Note not found", http.StatusNotFound)
 			return
 		}
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
 		// For any other error, return a 500 Internal Server Error
 		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
 		return
@@ -235,3 +645,37 @@ func (h *Handler) deleteNote(w http.ResponseWriter, r *http.Request) {
 	// This indicates that the request was successful but there's no content to return
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// storageUnavailable responds with 503 Service Unavailable and a Retry-After header
+// if the storage circuit breaker rejected an operation because the backend keeps failing,
+// with 504 Gateway Timeout if the operation took longer than the storage timeout,
+// with 507 Insufficient Storage if in-memory storage reached its limits,
+// or with 403 Forbidden or 413 Content Too Large if a quota is exceeded (see quotaExceeded).
+//
+// Parameters:
+//   - w: The response writer
+//   - err: The error returned by the storage
+//
+// Returns:
+//   - true if a response has been written, false if err is a different error
+func storageUnavailable(w http.ResponseWriter, err error) bool {
+	if quotaExceeded(w, err) {
+		return true
+	}
+	if errors.Is(err, storage.ErrStorageFull) {
+		http.Error(w, "Storage is full", http.StatusInsufficientStorage)
+		return true
+	}
+	if errors.Is(err, storage.ErrTimeout) {
+		http.Error(w, "Storage timed out", http.StatusGatewayTimeout)
+		return true
+	}
+	var openErr *storage.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		return false
+	}
+	retryAfter := max(1, int(math.Ceil(openErr.RetryAfter.Seconds())))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Storage temporarily unavailable", http.StatusServiceUnavailable)
+	return true
+}
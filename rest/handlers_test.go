@@ -5,135 +5,27 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"golang-simple-notes/model"
 	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// MockStorage is a mock implementation of the NoteStorage interface for testing
-type MockStorage struct {
-	notes map[string]*model.Note
-}
-
-// NewMockStorage creates a new instance of MockStorage
-func NewMockStorage() *MockStorage {
-	return &MockStorage{
-		notes: make(map[string]*model.Note),
-	}
-}
-
-// Create adds a new note to the storage
-func (s *MockStorage) Create(ctx context.Context, note *model.Note) error {
-	s.notes[note.ID] = note
-	return nil
-}
-
-// Get retrieves a note by its ID
-func (s *MockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	note, exists := s.notes[id]
-	if !exists {
-		return nil, storage.ErrNoteNotFound
-	}
-	return note, nil
-}
-
-// GetAll retrieves all notes from the storage
-func (s *MockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	notes := make([]*model.Note, 0, len(s.notes))
-	for _, note := range s.notes {
-		notes = append(notes, note)
-	}
-	return notes, nil
-}
-
-// Update updates an existing note
-func (s *MockStorage) Update(ctx context.Context, note *model.Note) error {
-	if _, exists := s.notes[note.ID]; !exists {
-		return storage.ErrNoteNotFound
-	}
-	s.notes[note.ID] = note
-	return nil
-}
-
-// Delete removes a note from the storage
-func (s *MockStorage) Delete(ctx context.Context, id string) error {
-	if _, exists := s.notes[id]; !exists {
-		return storage.ErrNoteNotFound
-	}
-	delete(s.notes, id)
-	return nil
-}
-
-// Close closes any resources used by the storage
-func (s *MockStorage) Close(ctx context.Context) error {
-	return nil
-}
-
-// ErrorMockStorage is a mock implementation that returns errors for testing error handling
-type ErrorMockStorage struct {
-	shouldError bool
-}
-
-// NewErrorMockStorage creates a new instance of ErrorMockStorage
-func NewErrorMockStorage(shouldError bool) *ErrorMockStorage {
-	return &ErrorMockStorage{
-		shouldError: shouldError,
-	}
-}
-
-// Create returns an error if shouldError is true
-func (s *ErrorMockStorage) Create(ctx context.Context, note *model.Note) error {
-	if s.shouldError {
-		return errors.New("storage error")
-	}
-	return nil
-}
-
-// Get returns an error if shouldError is true
-func (s *ErrorMockStorage) Get(ctx context.Context, id string) (*model.Note, error) {
-	if s.shouldError {
-		return nil, errors.New("storage error")
-	}
-	return &model.Note{ID: id, Title: "Test Title", Content: "Test Content"}, nil
-}
-
-// GetAll returns an error if shouldError is true
-func (s *ErrorMockStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
-	if s.shouldError {
-		return nil, errors.New("storage error")
-	}
-	return []*model.Note{}, nil
-}
-
-// Update returns an error if shouldError is true
-func (s *ErrorMockStorage) Update(ctx context.Context, note *model.Note) error {
-	if s.shouldError {
-		return errors.New("storage error")
-	}
-	return nil
-}
-
-// Delete returns an error if shouldError is true
-func (s *ErrorMockStorage) Delete(ctx context.Context, id string) error {
-	if s.shouldError {
-		return errors.New("storage error")
-	}
-	return nil
-}
-
-// Close returns an error if shouldError is true
-func (s *ErrorMockStorage) Close(ctx context.Context) error {
-	if s.shouldError {
-		return errors.New("storage error")
-	}
-	return nil
+// newFailingStorage returns a fake storage whose every operation fails, to test error handling
+func newFailingStorage() *fake.Storage {
+	backend := fake.New()
+	backend.Fail(errors.New("storage error"))
+	return backend
 }
 
 // setupTestRequest creates a test request with the given method, path, and body
@@ -148,7 +40,7 @@ func setupTestRequest(method, path, body string) *http.Request {
 func TestCreateNote(t *testing.T) {
 	// Test valid request
 	t.Run("Valid Request", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		reqBody := `{"title":"Test Title","content":"Test Content"}`
@@ -178,7 +70,7 @@ func TestCreateNote(t *testing.T) {
 
 	// Test invalid JSON
 	t.Run("Invalid JSON", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		reqBody := `{"title":"Test Title","content":"Test Content"`
@@ -198,7 +90,7 @@ func TestCreateNote(t *testing.T) {
 
 	// Test storage error
 	t.Run("Storage Error", func(t *testing.T) {
-		errorStorage := NewErrorMockStorage(true)
+		errorStorage := newFailingStorage()
 		handler := NewHandler(errorStorage)
 
 		reqBody := `{"title":"Test Title","content":"Test Content"}`
@@ -221,7 +113,7 @@ func TestCreateNote(t *testing.T) {
 func TestGetAllNotes(t *testing.T) {
 	// Test getting all notes successfully
 	t.Run("Success", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		// Add some notes to the storage
@@ -258,7 +150,7 @@ func TestGetAllNotes(t *testing.T) {
 
 	// Test storage error
 	t.Run("Storage Error", func(t *testing.T) {
-		errorStorage := NewErrorMockStorage(true)
+		errorStorage := newFailingStorage()
 		handler := NewHandler(errorStorage)
 
 		req := setupTestRequest("GET", "/api/notes", "")
@@ -280,7 +172,7 @@ func TestGetAllNotes(t *testing.T) {
 func TestGetNote(t *testing.T) {
 	// Test getting a note successfully
 	t.Run("Success", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		// Add a note to the storage with a valid ID
@@ -315,7 +207,7 @@ func TestGetNote(t *testing.T) {
 
 	// Test note not found
 	t.Run("Note Not Found", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		req := setupTestRequest("GET", "/api/notes/nonexistent", "")
@@ -337,7 +229,7 @@ func TestGetNote(t *testing.T) {
 
 	// Test storage error
 	t.Run("Storage Error", func(t *testing.T) {
-		errorStorage := NewErrorMockStorage(true)
+		errorStorage := newFailingStorage()
 		handler := NewHandler(errorStorage)
 
 		req := setupTestRequest("GET", "/api/notes/test", "")
@@ -362,17 +254,19 @@ func TestGetNote(t *testing.T) {
 func TestUpdateNote(t *testing.T) {
 	// Test updating a note successfully
 	t.Run("Success", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		// Add a note to the storage with a valid ID
-		note := &model.Note{ID: "testid123", Title: "Original Title", Content: "Original Content"}
+		created := time.Now().Add(-time.Hour).UTC()
+		note := &model.Note{ID: "testid123", Title: "Original Title", Content: "Original Content", CreatedAt: created, UpdatedAt: created}
 		errC := mockStorage.Create(context.Background(), note)
 		if errC != nil {
 			return
 		}
 
-		reqBody := `{"title":"Updated Title","content":"Updated Content"}`
+		// The immutable fields may be sent back unchanged
+		reqBody := `{"_id":"testid123","title":"Updated Title","content":"Updated Content","created_at":"` + created.Format(time.RFC3339Nano) + `"}`
 		req := setupTestRequest("PUT", "/api/notes/"+note.ID, reqBody)
 		chiCtx := chi.NewRouteContext()
 		chiCtx.URLParams.Add("id", note.ID)
@@ -398,11 +292,15 @@ func TestUpdateNote(t *testing.T) {
 		if response.Content != "Updated Content" {
 			t.Errorf("Expected content 'Updated Content', got '%s'", response.Content)
 		}
+
+		if !response.CreatedAt.Equal(created) || !response.UpdatedAt.After(created) {
+			t.Errorf("Expected the creation time to be kept and the update time to advance, got %v and %v", response.CreatedAt, response.UpdatedAt)
+		}
 	})
 
 	// Test note not found
 	t.Run("Note Not Found", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		reqBody := `{"title":"Updated Title","content":"Updated Content"}`
@@ -423,9 +321,37 @@ func TestUpdateNote(t *testing.T) {
 		}
 	})
 
+	// Test changing the ID or creation time
+	t.Run("Immutable Fields", func(t *testing.T) {
+		created := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+		mockStorage := fake.New(&model.Note{ID: "test", Title: "Original Title", CreatedAt: created, UpdatedAt: created})
+		handler := NewHandler(mockStorage)
+
+		for _, reqBody := range []string{
+			`{"_id":"other","title":"Updated Title"}`,
+			`{"title":"Updated Title","created_at":"2000-01-01T00:00:00Z"}`,
+			`{"title":"Updated Title","created_at":"2024-01-15T12:00:00.001Z"}`,
+		} {
+			req := setupTestRequest("PUT", "/api/notes/test", reqBody)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", "test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+			w := httptest.NewRecorder()
+
+			handler.updateNote(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "cannot be changed") {
+				t.Errorf("%s: expected status code %d, got %d: %s", reqBody, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			}
+		}
+		if note := mockStorage.Note("test"); note.Title != "Original Title" || !note.CreatedAt.Equal(created) {
+			t.Errorf("Expected the note to be left unchanged, got %+v", note)
+		}
+	})
+
 	// Test invalid JSON
 	t.Run("Invalid JSON", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		reqBody := `{"title":"Updated Title","content":"Updated Content"`
@@ -448,7 +374,7 @@ func TestUpdateNote(t *testing.T) {
 
 	// Test storage error
 	t.Run("Storage Error", func(t *testing.T) {
-		errorStorage := NewErrorMockStorage(true)
+		errorStorage := newFailingStorage()
 		handler := NewHandler(errorStorage)
 
 		reqBody := `{"title":"Updated Title","content":"Updated Content"}`
@@ -468,13 +394,104 @@ func TestUpdateNote(t *testing.T) {
 			t.Errorf("Expected error message to contain 'Failed to update note', got: %s", w.Body.String())
 		}
 	})
+
+	// Test an update rejected because the note was modified concurrently
+	t.Run("Conflict", func(t *testing.T) {
+		mockStorage := fake.New()
+		mockStorage.Add(&model.Note{ID: "test", Title: "Original Title", Rev: "2-current"})
+		handler := NewHandler(&conflictStorage{NoteStorage: mockStorage})
+
+		reqBody := `{"_rev":"1-stale","title":"Updated Title","content":"Updated Content"}`
+		req := setupTestRequest("PUT", "/api/notes/test", reqBody)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "test")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		w := httptest.NewRecorder()
+
+		handler.updateNote(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	// Test an update based on an update time that is no longer the note's (on any backend)
+	t.Run("Stale UpdatedAt", func(t *testing.T) {
+		updatedAt := time.Date(2025, 1, 10, 9, 30, 0, 123000000, time.UTC)
+		mockStorage := fake.New()
+		mockStorage.Add(&model.Note{ID: "test", Title: "Original Title", UpdatedAt: updatedAt})
+		handler := NewHandler(mockStorage)
+
+		put := func(body string) *httptest.ResponseRecorder {
+			req := setupTestRequest("PUT", "/api/notes/test", body)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", "test")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+			w := httptest.NewRecorder()
+			handler.updateNote(w, req)
+			return w
+		}
+
+		if w := put(`{"title":"Stale","updated_at":"2025-01-10T09:00:00Z"}`); w.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+		}
+		if mockStorage.Note("test").Title != "Original Title" {
+			t.Errorf("Expected the note to be unchanged, got %q", mockStorage.Note("test").Title)
+		}
+		if w := put(`{"title":"Current","updated_at":"2025-01-10T09:30:00.123Z"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	})
+
+	// Test creating a missing note, if enabled
+	t.Run("Create", func(t *testing.T) {
+		mockStorage := fake.New()
+		put := func(handler *Handler, id string) *httptest.ResponseRecorder {
+			req := setupTestRequest("PUT", "/api/notes/"+url.PathEscape(id), `{"title":"Created"}`)
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("id", id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+			w := httptest.NewRecorder()
+			handler.updateNote(w, req)
+			return w
+		}
+
+		if w := put(NewHandler(mockStorage), "new"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d without PUT creation, got %d", http.StatusNotFound, w.Code)
+		}
+
+		handler := NewHandler(mockStorage, WithPutCreates(true))
+		w := put(handler, "new")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var note model.Note
+		if err := json.Unmarshal(w.Body.Bytes(), &note); err != nil || note.ID != "new" || note.Title != "Created" {
+			t.Errorf("Expected the created note, got %s: %v", w.Body.String(), err)
+		}
+		if w := put(handler, "new"); w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for an existing note, got %d", http.StatusOK, w.Code)
+		}
+		if w := put(handler, "with space"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for an invalid ID, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+// conflictStorage is a NoteStorage whose updates always conflict
+type conflictStorage struct {
+	storage.NoteStorage
+}
+
+func (s *conflictStorage) Update(context.Context, *model.Note) error {
+	return fmt.Errorf("%w: 1-stale is no longer current", storage.ErrConflict)
 }
 
 // TestDeleteNote tests the deleteNote handler
 func TestDeleteNote(t *testing.T) {
 	// Test deleting a note successfully
 	t.Run("Success", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		// Add a note to the storage with a valid ID
@@ -499,7 +516,7 @@ func TestDeleteNote(t *testing.T) {
 
 	// Test note not found
 	t.Run("Note Not Found", func(t *testing.T) {
-		mockStorage := NewMockStorage()
+		mockStorage := fake.New()
 		handler := NewHandler(mockStorage)
 
 		req := setupTestRequest("DELETE", "/api/notes/nonexistent", "")
@@ -521,7 +538,7 @@ func TestDeleteNote(t *testing.T) {
 
 	// Test storage error
 	t.Run("Storage Error", func(t *testing.T) {
-		errorStorage := NewErrorMockStorage(true)
+		errorStorage := newFailingStorage()
 		handler := NewHandler(errorStorage)
 
 		req := setupTestRequest("DELETE", "/api/notes/test", "")
@@ -542,9 +559,193 @@ func TestDeleteNote(t *testing.T) {
 	})
 }
 
+// TestDuplicateNote tests creating a copy of a note through the router
+func TestDuplicateNote(t *testing.T) {
+	mockStorage := fake.New()
+	original := &model.Note{ID: "original", Title: "Title", Content: "Content", CreatedAt: time.Now().Add(-time.Hour)}
+	mockStorage.Add(original)
+
+	r := chi.NewRouter()
+	NewHandler(mockStorage).RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, setupTestRequest("POST", "/api/notes/original/duplicate", ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var copied model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &copied); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if copied.ID == original.ID || copied.Title != "Title (copy)" || copied.Content != "Content" {
+		t.Errorf("Unexpected copy: %+v", copied)
+	}
+	if !copied.CreatedAt.After(original.CreatedAt) {
+		t.Errorf("Expected fresh timestamps, got %v", copied.CreatedAt)
+	}
+	if mockStorage.Note(copied.ID) == nil || len(mockStorage.Notes()) != 2 {
+		t.Errorf("Expected the copy to be stored next to the original, got %d notes", len(mockStorage.Notes()))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, setupTestRequest("POST", "/api/notes/missing/duplicate", ""))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestNoteAppearance tests setting, filtering by, and rejecting invalid colors and icons of notes
+func TestNoteAppearance(t *testing.T) {
+	mockStorage := fake.New()
+	r := chi.NewRouter()
+	NewHandler(mockStorage).RegisterRoutes(r)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, setupTestRequest(method, target, body))
+		return w
+	}
+
+	w := send("POST", "/api/notes", `{"title":"Colored","color":"#1E90FF","icon":"star"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if created.Color != "#1e90ff" || created.Icon != "star" {
+		t.Errorf("Expected the normalized color and the icon, got %+v", created)
+	}
+	if w := send("POST", "/api/notes", `{"title":"Plain"}`); strings.Contains(w.Body.String(), `"color"`) {
+		t.Errorf("Expected a note without a color to leave it out, got %s", w.Body.String())
+	}
+
+	var listed []model.Note
+	if err := json.Unmarshal(send("GET", "/api/notes?filter=color:%231e90ff", "").Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("Expected the colored note only, got %+v: %v", listed, err)
+	}
+
+	w = send("PUT", "/api/notes/"+created.ID, `{"title":"Colored","color":"#00ff00","icon":"heart"}`)
+	if note := mockStorage.Note(created.ID); w.Code != http.StatusOK || note.Color != "#00ff00" || note.Icon != "heart" {
+		t.Errorf("Expected the color and icon to be updated, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, tt := range []struct{ method, target, body string }{
+		{"POST", "/api/notes", `{"title":"Invalid","color":"red"}`},
+		{"POST", "/api/notes", `{"title":"Invalid","icon":"unknown"}`},
+		{"PUT", "/api/notes/" + created.ID, `{"title":"Invalid","color":"#12345"}`},
+	} {
+		if w := send(tt.method, tt.target, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status code %d, got %d: %s", tt.method, tt.body, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestNoteTags tests setting, filtering by, updating, and rejecting invalid tags of notes
+func TestNoteTags(t *testing.T) {
+	mockStorage := fake.New()
+	r := chi.NewRouter()
+	NewHandler(mockStorage).RegisterRoutes(r)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, setupTestRequest(method, target, body))
+		return w
+	}
+
+	w := send("POST", "/api/notes", `{"title":"Tagged","tags":["Work","home","work"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !slices.Equal(created.Tags, []string{"home", "work"}) {
+		t.Errorf("Expected the normalized tags, got %+v", created)
+	}
+	if w := send("POST", "/api/notes", `{"title":"Plain"}`); strings.Contains(w.Body.String(), `"tags"`) {
+		t.Errorf("Expected a note without tags to leave them out, got %s", w.Body.String())
+	}
+
+	var listed []model.Note
+	if err := json.Unmarshal(send("GET", "/api/notes?filter=tag:WORK", "").Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("Expected the tagged note only, got %+v: %v", listed, err)
+	}
+
+	w = send("PUT", "/api/notes/"+created.ID, `{"title":"Tagged","tags":["travel"]}`)
+	if note := mockStorage.Note(created.ID); w.Code != http.StatusOK || !slices.Equal(note.Tags, []string{"travel"}) {
+		t.Errorf("Expected the tags to be replaced, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, tt := range []struct{ method, target, body string }{
+		{"POST", "/api/notes", `{"title":"Invalid","tags":["two words"]}`},
+		{"POST", "/api/notes", `{"title":"Invalid","tags":"work"}`},
+		{"PUT", "/api/notes/" + created.ID, `{"title":"Invalid","tags":[""]}`},
+	} {
+		if w := send(tt.method, tt.target, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status code %d, got %d: %s", tt.method, tt.body, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestStorageUnavailable tests that handlers return 503 Service Unavailable while the storage circuit breaker is open
+func TestStorageUnavailable(t *testing.T) {
+	// A single failure opens the circuit
+	breaker := storage.NewCircuitBreakerStorage(newFailingStorage(), "test-rest", 1, time.Minute)
+	if err := breaker.Ping(context.Background()); err == nil {
+		t.Fatal("Expected the first ping to fail")
+	}
+	handler := NewHandler(breaker)
+
+	tests := map[string]struct {
+		method, body string
+		handle       http.HandlerFunc
+	}{
+		"GetAll": {http.MethodGet, "", handler.getAllNotes},
+		"Get":    {http.MethodGet, "", handler.getNote},
+		"Create": {http.MethodPost, `{"title":"Title","content":"Content"}`, handler.createNote},
+		"Update": {http.MethodPut, `{"title":"Title","content":"Content"}`, handler.updateNote},
+		"Delete": {http.MethodDelete, "", handler.deleteNote},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := setupTestRequest(tt.method, "/api/notes/test-id", tt.body)
+			chi.RouteContext(req.Context()).URLParams.Add("id", "test-id")
+			w := httptest.NewRecorder()
+
+			tt.handle(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+			}
+			if retryAfter := w.Header().Get("Retry-After"); retryAfter != "60" {
+				t.Errorf("Expected Retry-After 60, got %q", retryAfter)
+			}
+		})
+	}
+}
+
+// TestStorageFull tests that writes return 507 Insufficient Storage when in-memory storage reached its limits
+func TestStorageFull(t *testing.T) {
+	memory := storage.NewInMemoryStorage()
+	memory.SetLimits(storage.InMemoryLimits{MaxNotes: 1, Eviction: storage.EvictionReject})
+	r := chi.NewRouter()
+	NewHandler(memory).RegisterRoutes(r)
+
+	for i, want := range []int{http.StatusCreated, http.StatusInsufficientStorage} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, setupTestRequest("POST", "/api/notes", `{"title":"Title","content":"Content"}`))
+		if w.Code != want {
+			t.Errorf("Note %d: expected status code %d, got %d: %s", i+1, want, w.Code, w.Body.String())
+		}
+	}
+}
+
 // TestHealthEndpoint tests the /health endpoint
 func TestHealthEndpoint(t *testing.T) {
-	mockStorage := NewMockStorage()
+	mockStorage := fake.New()
 	handler := NewHandler(mockStorage)
 	r := chi.NewRouter()
 	handler.RegisterRoutes(r)
@@ -575,7 +776,7 @@ func TestHealthEndpoint(t *testing.T) {
 
 // TestEmptyNoteID tests that the middleware returns 400 Bad Request when the ID is empty
 func TestEmptyNoteID(t *testing.T) {
-	mockStorage := NewMockStorage()
+	mockStorage := fake.New()
 	handler := NewHandler(mockStorage)
 
 	req := setupTestRequest("GET", "/api/notes/", "")
@@ -597,7 +798,7 @@ func TestEmptyNoteID(t *testing.T) {
 
 // TestUnsupportedMethods tests that unsupported methods return 405 Method Not Allowed
 func TestUnsupportedMethods(t *testing.T) {
-	mockStorage := NewMockStorage()
+	mockStorage := fake.New()
 	handler := NewHandler(mockStorage)
 	r := chi.NewRouter()
 	handler.RegisterRoutes(r)
@@ -644,6 +845,7 @@ func TestIsValidNoteID(t *testing.T) {
 		{strings.Repeat("a", 256), false, "TooLong"},
 		{"invalid@id", false, "InvalidChar"},
 		{"UPPER_and-lower123", true, "MixedCase"},
+		{"20230415123045.123456.1a2b3c4d", true, "Generated"},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -656,7 +858,7 @@ func TestIsValidNoteID(t *testing.T) {
 
 // TestEmptyAndInvalidID tests empty and invalid IDs for PUT and DELETE methods
 func TestEmptyAndInvalidID(t *testing.T) {
-	mockStorage := NewMockStorage()
+	mockStorage := fake.New()
 	handler := NewHandler(mockStorage)
 
 	invalidIDs := []struct {
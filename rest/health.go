@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout is the maximum time a single dependency check may take.
+const healthCheckTimeout = 2 * time.Second
+
+// Health check statuses reported by the readiness endpoint.
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+// HealthCheck checks that a dependency of the service is available.
+// It returns an error describing the problem if it isn't.
+type HealthCheck func(ctx context.Context) error
+
+// HealthReport is the response body of the readiness endpoint.
+type HealthReport struct {
+	Status string                      `json:"status"` // "ok" if every dependency is available, "unavailable" otherwise
+	Checks map[string]DependencyHealth `json:"checks"` // Result of each dependency check, by name
+}
+
+// DependencyHealth is the result of checking a single dependency.
+type DependencyHealth struct {
+	Status    string `json:"status"`          // "ok" or "unavailable"
+	LatencyMS int64  `json:"latency_ms"`      // How long the check took, in milliseconds
+	Error     string `json:"error,omitempty"` // Why the dependency is unavailable
+}
+
+// WithHealthCheck adds a dependency check to the readiness endpoint, in addition
+// to the storage check that is always performed.
+func WithHealthCheck(name string, check HealthCheck) HandlerOption {
+	return func(h *Handler) {
+		if h.checks == nil {
+			h.checks = make(map[string]HealthCheck)
+		}
+		h.checks[name] = check
+	}
+}
+
+// WithStartupCheck adds a check to the startup endpoint, which reports whether
+// the application has finished initializing. Without startup checks, the endpoint
+// reports success as soon as the handler serves requests.
+func WithStartupCheck(name string, check HealthCheck) HandlerOption {
+	return func(h *Handler) {
+		if h.startupChecks == nil {
+			h.startupChecks = make(map[string]HealthCheck)
+		}
+		h.startupChecks[name] = check
+	}
+}
+
+// handleLive handles the liveness endpoint (GET /health/live, also served on GET /health).
+// It returns a simple "OK" response with a 200 status code as long as the process is running
+// and able to serve requests. It doesn't check any dependencies, so a failing database
+// doesn't cause the orchestrator to restart the service.
+func (h *Handler) handleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK) // Set the status code to 200 OK
+	if _, err := w.Write([]byte("OK")); err != nil {
+		_ = err // cannot change status code; ignore write error
+	}
+}
+
+// handleStartup handles the startup endpoint (GET /health/startup).
+// It responds with 503 Service Unavailable until every startup check passes,
+// i.e. until the application has finished initializing.
+func (h *Handler) handleStartup(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, runHealthChecks(r.Context(), h.startupChecks))
+}
+
+// handleReady handles the readiness endpoint (GET /health/ready).
+// It checks every dependency (at least the storage backend) in parallel, each with its own timeout,
+// and returns a JSON breakdown of the results. If any dependency is unavailable,
+// it responds with 503 Service Unavailable, so load balancers stop routing traffic here.
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]HealthCheck{"storage": h.storage.Ping}
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+
+	writeHealthReport(w, runHealthChecks(r.Context(), checks))
+}
+
+// writeHealthReport writes a health report as JSON, with 503 Service Unavailable
+// if any check failed.
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	status := http.StatusOK
+	if report.Status != healthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		_ = err // cannot change status code; ignore write error
+	}
+}
+
+// runHealthChecks runs the checks concurrently and collects their results.
+func runHealthChecks(ctx context.Context, checks map[string]HealthCheck) HealthReport {
+	report := HealthReport{
+		Status: healthStatusOK,
+		Checks: make(map[string]DependencyHealth, len(checks)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runHealthCheck(ctx, check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[name] = result
+			if result.Status != healthStatusOK {
+				report.Status = healthStatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// runHealthCheck runs a single check with its own timeout.
+func runHealthCheck(ctx context.Context, check HealthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := DependencyHealth{
+		Status:    healthStatusOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = healthStatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
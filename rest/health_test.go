@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestReadyEndpoint tests the readiness endpoint with available and unavailable dependencies
+func TestReadyEndpoint(t *testing.T) {
+	serve := func(t *testing.T, handler *Handler) (*httptest.ResponseRecorder, HealthReport) {
+		t.Helper()
+		r := chi.NewRouter()
+		handler.RegisterRoutes(r)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/health/ready", nil))
+
+		var report HealthReport
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w, report
+	}
+
+	t.Run("All dependencies available", func(t *testing.T) {
+		w, report := serve(t, NewHandler(fake.New()))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code 200, got %d", w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %q", ct)
+		}
+		if report.Status != "ok" || report.Checks["storage"].Status != "ok" {
+			t.Errorf("Expected storage to be ok, got %+v", report)
+		}
+	})
+
+	t.Run("Storage unavailable", func(t *testing.T) {
+		w, report := serve(t, NewHandler(newFailingStorage()))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status code 503, got %d", w.Code)
+		}
+		storageHealth := report.Checks["storage"]
+		if report.Status != "unavailable" || storageHealth.Status != "unavailable" || storageHealth.Error != "storage error" {
+			t.Errorf("Expected storage to be unavailable, got %+v", report)
+		}
+	})
+
+	t.Run("Additional dependency unavailable", func(t *testing.T) {
+		handler := NewHandler(fake.New(),
+			WithHealthCheck("cache", func(ctx context.Context) error { return nil }),
+			WithHealthCheck("broker", func(ctx context.Context) error { return errors.New("connection refused") }),
+		)
+		w, report := serve(t, handler)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status code 503, got %d", w.Code)
+		}
+		if len(report.Checks) != 3 {
+			t.Errorf("Expected 3 checks, got %+v", report.Checks)
+		}
+		if report.Checks["storage"].Status != "ok" || report.Checks["cache"].Status != "ok" {
+			t.Errorf("Expected storage and cache to be ok, got %+v", report.Checks)
+		}
+		if report.Checks["broker"].Error != "connection refused" {
+			t.Errorf("Expected broker error, got %+v", report.Checks["broker"])
+		}
+	})
+
+	t.Run("Check timeout", func(t *testing.T) {
+		handler := NewHandler(fake.New(), WithHealthCheck("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		// Cancel the request, so the check doesn't wait for the full timeout
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := chi.NewRouter()
+		handler.RegisterRoutes(r)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/health/ready", nil).WithContext(ctx))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status code 503, got %d", w.Code)
+		}
+	})
+}
+
+// TestLiveAndStartupEndpoints tests the liveness and startup endpoints
+func TestLiveAndStartupEndpoints(t *testing.T) {
+	started := false
+	handler := NewHandler(newFailingStorage(), WithStartupCheck("initialization", func(ctx context.Context) error {
+		if !started {
+			return errors.New("initialization in progress")
+		}
+		return nil
+	}))
+	r := chi.NewRouter()
+	handler.RegisterRoutes(r)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	// Liveness doesn't depend on the (failing) storage
+	for _, path := range []string{"/health", "/health/live"} {
+		if w := get(path); w.Code != http.StatusOK || w.Body.String() != "OK" {
+			t.Errorf("GET %s: expected 200 OK, got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+
+	w := get("/health/startup")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503 before startup, got %d", w.Code)
+	}
+	var report HealthReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Checks["initialization"].Error != "initialization in progress" {
+		t.Errorf("Expected initialization check to fail, got %+v", report)
+	}
+
+	started = true
+	if w := get("/health/startup"); w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200 after startup, got %d", w.Code)
+	}
+}
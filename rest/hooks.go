@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"golang-simple-notes/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// hookRequest is the request body for POST /api/admin/webhooks.
+type hookRequest struct {
+	URL    string   `json:"url"`              // URL that receives signed event payloads
+	Events []string `json:"events,omitempty"` // Event types to deliver; empty means all
+	Secret string   `json:"secret,omitempty"` // Key of the payload signatures; empty means the configured secret
+}
+
+// WithHooks enables the webhook admin endpoints, backed by the given registry.
+func WithHooks(hooks *webhook.Hooks) HandlerOption {
+	return func(h *Handler) {
+		h.hooks = hooks
+	}
+}
+
+// listHooks handles GET /api/admin/webhooks.
+// It returns the registered webhooks as a JSON array; their secrets are never returned.
+func (h *Handler) listHooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.hooks.List()); err != nil {
+		http.Error(w, "Failed to encode webhooks", http.StatusInternalServerError)
+		return
+	}
+}
+
+// createHook handles POST /api/admin/webhooks.
+// It registers a webhook that receives the subscribed note events (all if none are given).
+func (h *Handler) createHook(w http.ResponseWriter, r *http.Request) {
+	var req hookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.hooks.Add(req.URL, req.Events, req.Secret, "api")
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidCallbackURL), errors.Is(err, webhook.ErrPrivateCallbackURL),
+			errors.Is(err, webhook.ErrUnknownEvent), errors.Is(err, webhook.ErrSecretRequired):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(hook); err != nil {
+		http.Error(w, "Failed to encode webhook", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deleteHook handles DELETE /api/admin/webhooks/{hookID}.
+// It removes a webhook and returns a 204 No Content.
+// If the webhook doesn't exist, it returns a 404 Not Found.
+func (h *Handler) deleteHook(w http.ResponseWriter, r *http.Request) {
+	if err := h.hooks.Remove(chi.URLParam(r, "hookID")); err != nil {
+		if errors.Is(err, webhook.ErrHookNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDeliveries handles GET /api/admin/webhooks/deliveries and GET /api/admin/webhooks/{hookID}/deliveries.
+// It returns the status of the most recent deliveries (to all webhooks, or to the given one),
+// newest first, as a JSON array.
+func (h *Handler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.hooks.Deliveries(chi.URLParam(r, "hookID"))); err != nil {
+		http.Error(w, "Failed to encode deliveries", http.StatusInternalServerError)
+		return
+	}
+}
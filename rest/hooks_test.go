@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+	"golang-simple-notes/webhook"
+)
+
+// adminRequest serves a request to the admin API with the given bearer token (if any)
+func adminRequest(hooks *webhook.Hooks, adminToken, method, path, token, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	NewHandler(fake.New(), WithHooks(hooks), WithAdminToken(adminToken)).RegisterRoutes(r)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestWebhookEndpoints tests registering, listing, and removing webhooks through the admin API
+func TestWebhookEndpoints(t *testing.T) {
+	hooks := webhook.NewHooks(storage.RetryPolicy{MaxAttempts: 1, AttemptTimeout: time.Second}, "", newTestRunner(t))
+
+	w := adminRequest(hooks, "admin-token", "POST", "/api/admin/webhooks", "admin-token", `{"url":"https://example.com/hook","events":["note.deleted"],"secret":"s3cret"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("Expected the secret not to be returned, got %s", w.Body.String())
+	}
+	var hook webhook.Hook
+	if err := json.Unmarshal(w.Body.Bytes(), &hook); err != nil || hook.ID == "" || hook.Source != "api" {
+		t.Fatalf("Unexpected webhook %s: %v", w.Body.String(), err)
+	}
+
+	w = adminRequest(hooks, "admin-token", "GET", "/api/admin/webhooks", "admin-token", "")
+	var list []webhook.Hook
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Errorf("Expected one webhook, got %s", w.Body.String())
+	}
+
+	w = adminRequest(hooks, "admin-token", "GET", "/api/admin/webhooks/"+hook.ID+"/deliveries", "admin-token", "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected no deliveries, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w = adminRequest(hooks, "admin-token", "DELETE", "/api/admin/webhooks/"+hook.ID, "admin-token", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w = adminRequest(hooks, "admin-token", "DELETE", "/api/admin/webhooks/"+hook.ID, "admin-token", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestCreateWebhookInvalid tests that invalid webhooks are rejected
+func TestCreateWebhookInvalid(t *testing.T) {
+	hooks := webhook.NewHooks(storage.RetryPolicy{MaxAttempts: 1}, "", newTestRunner(t))
+
+	for _, body := range []string{
+		`not json`,
+		`{"url":"example.com/hook","secret":"s"}`,
+		`{"url":"https://example.com/hook","events":["note.archived"],"secret":"s"}`,
+		`{"url":"https://example.com/hook"}`,
+	} {
+		if w := adminRequest(hooks, "admin-token", "POST", "/api/admin/webhooks", "admin-token", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+// TestAdminToken tests that the admin API requires the admin token, and isn't available without one
+func TestAdminToken(t *testing.T) {
+	hooks := webhook.NewHooks(storage.RetryPolicy{MaxAttempts: 1}, "", newTestRunner(t))
+
+	for _, token := range []string{"", "wrong"} {
+		w := adminRequest(hooks, "admin-token", "GET", "/api/admin/webhooks", token, "")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected status code %d, got %d", token, http.StatusUnauthorized, w.Code)
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Token %q: expected a WWW-Authenticate header", token)
+		}
+	}
+	if w := adminRequest(hooks, "admin-token", "GET", "/api/admin/webhooks", "admin-token", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Without an admin token, the admin API isn't available
+	for _, method := range []string{"GET", "POST"} {
+		if w := adminRequest(hooks, "", method, "/api/admin/webhooks", "", `{"url":"https://example.com/hook","secret":"s"}`); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected the webhooks not to be available, got %d", method, w.Code)
+		}
+	}
+
+	// The export and import of all notes require the admin token as well
+	for _, tt := range []struct{ method, path string }{{"GET", "/api/export"}, {"POST", "/api/import"}} {
+		if w := adminRequest(nil, "admin-token", tt.method, tt.path, "", "[]"); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected status code %d without the token, got %d", tt.method, tt.path, http.StatusUnauthorized, w.Code)
+		}
+		if w := adminRequest(nil, "admin-token", tt.method, tt.path, "admin-token", "[]"); w.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status code %d with the token, got %d: %s", tt.method, tt.path, http.StatusOK, w.Code, w.Body.String())
+		}
+		if w := adminRequest(nil, "", tt.method, tt.path, "", "[]"); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected the endpoint not to be available without an admin token, got %d", tt.method, tt.path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+)
+
+// notesPath is the path of the collection of notes.
+const notesPath = "/api/notes"
+
+// notePath returns the path of a note, relative to the root of the server.
+func notePath(id string) string {
+	return notesPath + "/" + url.PathEscape(id)
+}
+
+// noteLinks are the links of a note in responses, so clients can navigate the API without
+// building URLs themselves.
+type noteLinks struct {
+	Self       string `json:"self"`       // The note
+	Collection string `json:"collection"` // The list of notes
+}
+
+// newNoteLinks returns the links of the note with the given ID.
+func newNoteLinks(id string) noteLinks {
+	return noteLinks{Self: notePath(id), Collection: notesPath}
+}
+
+// linkedNote is the representation of a note in the responses of the notes API: the
+// note, with its links. Exports and the other APIs leave the links out.
+type linkedNote struct {
+	*model.Note
+	Links noteLinks `json:"links"`
+}
+
+// withLinks returns the representation of a note with its links.
+func withLinks(note *model.Note) linkedNote {
+	return linkedNote{Note: note, Links: newNoteLinks(note.ID)}
+}
+
+// linkNotes returns the representations of notes with their links.
+func linkNotes(notes []*model.Note) []linkedNote {
+	linked := make([]linkedNote, len(notes))
+	for i, note := range notes {
+		linked[i] = withLinks(note)
+	}
+	return linked
+}
+
+// linkedSearchResult is a search result whose note has its links.
+type linkedSearchResult struct {
+	service.SearchResult
+	Note linkedNote `json:"note"` // Replaces the note of the result
+}
+
+// setLocation sets the Location header of the response creating a note to its path.
+func setLocation(w http.ResponseWriter, note *model.Note) {
+	w.Header().Set("Location", notePath(note.ID))
+}
@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+)
+
+// TestLinks tests the Location header of created notes, and the links of the notes in responses
+func TestLinks(t *testing.T) {
+	backend := fake.New(&model.Note{ID: "existing", Title: "Existing", Content: "See [[existing]]"})
+	r := chi.NewRouter()
+	NewHandler(backend, WithPutCreates(true), WithExpander("shout", &countingExpander{}), WithAdminToken("admin-token")).RegisterRoutes(r)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, setupTestRequest(method, target, body))
+		return w
+	}
+	// linksOf decodes the links of a note in a response
+	linksOf := func(t *testing.T, data []byte) noteLinks {
+		t.Helper()
+		var body struct {
+			ID    string    `json:"_id"`
+			Links noteLinks `json:"links"`
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Fatalf("Failed to unmarshal note: %v", err)
+		}
+		if want := newNoteLinks(body.ID); body.Links != want {
+			t.Errorf("Expected the links %+v, got %+v", want, body.Links)
+		}
+		return body.Links
+	}
+
+	t.Run("Created", func(t *testing.T) {
+		for _, tt := range []struct{ method, target, body string }{
+			{"POST", "/api/notes", `{"title":"New"}`},
+			{"PUT", "/api/notes/chosen-id", `{"title":"New"}`},
+			{"POST", "/api/notes/existing/duplicate", ""},
+		} {
+			w := send(tt.method, tt.target, tt.body)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("%s %s: expected status code %d, got %d: %s", tt.method, tt.target, http.StatusCreated, w.Code, w.Body.String())
+			}
+			links := linksOf(t, w.Body.Bytes())
+			if location := w.Header().Get("Location"); location != links.Self || !strings.HasPrefix(location, "/api/notes/") {
+				t.Errorf("%s %s: expected the Location of the note, got %q", tt.method, tt.target, location)
+			}
+		}
+		if links := linksOf(t, send("GET", "/api/notes/chosen-id", "").Body.Bytes()); links.Self != "/api/notes/chosen-id" {
+			t.Errorf("Expected the path of the note, got %+v", links)
+		}
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		w := send("PUT", "/api/notes/existing", `{"title":"Updated"}`)
+		if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+			t.Errorf("Expected status code %d without a Location, got %d with %q", http.StatusOK, w.Code, w.Header().Get("Location"))
+		}
+		if links := linksOf(t, w.Body.Bytes()); links.Collection != "/api/notes" {
+			t.Errorf("Expected the collection link, got %+v", links)
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		linksOf(t, send("GET", "/api/notes/existing", "").Body.Bytes())
+		linksOf(t, send("GET", "/api/notes/existing?expand=shout", "").Body.Bytes())
+		for _, target := range []string{"/api/notes", "/api/notes?expand=shout", "/api/notes/existing/backlinks"} {
+			var notes []json.RawMessage
+			if err := json.Unmarshal(send("GET", target, "").Body.Bytes(), &notes); err != nil || len(notes) == 0 {
+				t.Fatalf("%s: expected notes, got %d: %v", target, len(notes), err)
+			}
+			for _, note := range notes {
+				linksOf(t, note)
+			}
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		if body := serveAdmin(r, "GET", "/api/export?format=json", "").Body.String(); !strings.Contains(body, `"existing"`) || strings.Contains(body, `"links"`) {
+			t.Errorf("Expected exports without links, got %s", body)
+		}
+	})
+}
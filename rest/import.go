@@ -0,0 +1,373 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+)
+
+// Conflict policies of POST /api/import, given by ?on_conflict=.
+const (
+	conflictSkip      = "skip"      // Keep the existing note and skip the record
+	conflictOverwrite = "overwrite" // Replace the existing note with the record
+	conflictFail      = "fail"      // Stop the import at the first existing note
+)
+
+// Per-record statuses of an import.
+const (
+	importCreated     = "created"     // The note didn't exist and was created
+	importOverwritten = "overwritten" // The note existed and was replaced
+	importSkipped     = "skipped"     // The note existed and was kept
+	importInvalid     = "invalid"     // The record is not a valid note
+	importConflict    = "conflict"    // The note existed and the import was stopped
+	importFailed      = "failed"      // The storage failed to save the note
+)
+
+// maxAsyncImportSize is the maximum size of the body of an asynchronous import, which is
+// kept in memory until the import job runs.
+const maxAsyncImportSize = 64 << 20
+
+// importRecordResult is the outcome of importing a single record.
+type importRecordResult struct {
+	Index  int    `json:"index"`           // Position of the record in the request body, starting at 0
+	ID     string `json:"id,omitempty"`    // ID of the note, if the record has one
+	Status string `json:"status"`          // One of the import statuses
+	Error  string `json:"error,omitempty"` // Why the record was not imported
+}
+
+// importSummary is the response body of POST /api/import.
+type importSummary struct {
+	Created     int                  `json:"created"`         // Number of notes created
+	Overwritten int                  `json:"overwritten"`     // Number of existing notes replaced
+	Skipped     int                  `json:"skipped"`         // Number of existing notes kept
+	Failed      int                  `json:"failed"`          // Number of records that were invalid, conflicting, or failed to save
+	Results     []importRecordResult `json:"results"`         // Outcome of every record, in request order
+	Error       string               `json:"error,omitempty"` // Why the import stopped before the end of a malformed body
+}
+
+// add records the outcome of a record and updates the counters.
+func (s *importSummary) add(result importRecordResult) {
+	switch result.Status {
+	case importCreated:
+		s.Created++
+	case importOverwritten:
+		s.Overwritten++
+	case importSkipped:
+		s.Skipped++
+	default:
+		s.Failed++
+	}
+	s.Results = append(s.Results, result)
+}
+
+// importFunc reads the records of an import, imports them, and passes the outcome of each
+// to add, stopping when add returns false (after a conflict); it returns an error if the
+// input is malformed.
+type importFunc func(add func(result importRecordResult) bool) error
+
+// importNotes handles POST /api/import.
+// It imports notes from the request body, which is either NDJSON (Content-Type application/x-ndjson),
+// as written by GET /api/export, or a JSON array of notes (any other Content-Type).
+// Records keep their IDs and timestamps. Notes that already exist are handled according to
+// ?on_conflict=: skip (the default) keeps them, overwrite replaces them, and fail stops the import.
+//
+// Every record is validated and imported on its own, and the response lists the outcome of each one.
+// It returns 200 OK, even if some records were invalid, or 409 Conflict if the import was stopped
+// by an existing note. If the body is malformed, it returns 400 Bad Request, with the summary of
+// the records before the malformed part, if any. Records imported before a stop stay imported.
+// With ?async=true, the import runs as a background job instead (see submitImport).
+func (h *Handler) importNotes(w http.ResponseWriter, r *http.Request) {
+	policy, ok := importPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	run := func(r *http.Request) importFunc {
+		return func(add func(result importRecordResult) bool) error {
+			return readImportRecords(r, func(index int, record json.RawMessage) bool {
+				return add(h.importRecord(r, index, record, policy))
+			})
+		}
+	}
+	if r.URL.Query().Get("async") == "true" {
+		h.submitImport(w, r, run)
+		return
+	}
+	h.runImport(w, run(r))
+}
+
+// importPolicy returns the conflict policy given by ?on_conflict=, or writes a 400 Bad Request.
+func importPolicy(w http.ResponseWriter, r *http.Request) (string, bool) {
+	policy := r.URL.Query().Get("on_conflict")
+	if policy == "" {
+		policy = conflictSkip
+	}
+	if policy != conflictSkip && policy != conflictOverwrite && policy != conflictFail {
+		http.Error(w, fmt.Sprintf("on_conflict must be one of: %s, %s, %s", conflictSkip, conflictOverwrite, conflictFail), http.StatusBadRequest)
+		return "", false
+	}
+	return policy, true
+}
+
+// runImport runs an import and writes its summary.
+func (h *Handler) runImport(w http.ResponseWriter, run importFunc) {
+	summary, stopped, err := collectImport(run)
+	if err != nil && len(summary.Results) == 0 {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case err != nil:
+		// Records before the malformed part have been imported, so report them as well
+		w.WriteHeader(http.StatusBadRequest)
+	case stopped:
+		w.WriteHeader(http.StatusConflict)
+	}
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, "Failed to encode import summary", http.StatusInternalServerError)
+		return
+	}
+}
+
+// collectImport runs an import and summarizes the outcome of its records.
+//
+// Returns:
+//   - The summary, with the error of a malformed input, if any
+//   - true if the import was stopped by a conflict
+//   - An error if the input is malformed
+func collectImport(run importFunc) (importSummary, bool, error) {
+	summary := importSummary{Results: []importRecordResult{}}
+	stopped := false
+	err := run(func(result importRecordResult) bool {
+		summary.add(result)
+		if result.Status == importConflict {
+			stopped = true
+		}
+		return !stopped
+	})
+	if err != nil {
+		summary.Error = fmt.Sprintf("invalid request body: %v", err)
+	}
+	return summary, stopped, err
+}
+
+// submitImport runs an import as an "import" background job, so a large import doesn't
+// hold the request open. The body is read into memory first (up to maxAsyncImportSize),
+// and the import runs with the values of the request context, such as its owner. It
+// responds with 202 Accepted and the job, whose result is the import summary once it has
+// finished (see GET /api/admin/jobs/{jobID}); a malformed body fails the job.
+func (h *Handler) submitImport(w http.ResponseWriter, r *http.Request, run func(r *http.Request) importFunc) {
+	if h.jobs == nil {
+		http.Error(w, "Asynchronous imports are not enabled", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAsyncImportSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxAsyncImportSize {
+		http.Error(w, fmt.Sprintf("Asynchronous imports are limited to %d MiB", maxAsyncImportSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), jobs.Task{
+		Kind: "import",
+		Run: func(ctx context.Context) (any, error) {
+			req := r.Clone(ctx)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			summary, _, err := collectImport(run(req))
+			return summary, err
+		},
+	})
+	if err != nil {
+		jobRejected(w, err)
+		return
+	}
+	writeJobAccepted(w, job)
+}
+
+// importConverters lists the formats of POST /api/admin/import, given by ?format=, with the
+// function that reads the notes of a request body in that format.
+var importConverters = map[string]func(body io.Reader, fn func(index int, note *model.Note, err error) bool) error{
+	"enex":   readENEXNotes,
+	"zip-md": readMarkdownArchive,
+}
+
+// importConverted handles POST /api/admin/import.
+// It imports notes from other applications, converted from the format given by ?format=:
+// enex for an Evernote export, or zip-md for a ZIP archive of Markdown files with optional
+// YAML front matter (as written by GET /api/export?format=zip-md, or a zipped Obsidian vault
+// or Notable directory). The notes go through the same pipeline as POST /api/import, with
+// the same conflict policies and response, and can run as a background job too.
+func (h *Handler) importConverted(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	convert, ok := importConverters[format]
+	if !ok {
+		http.Error(w, "format must be one of: enex, zip-md", http.StatusBadRequest)
+		return
+	}
+	policy, ok := importPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	run := func(r *http.Request) importFunc {
+		return func(add func(result importRecordResult) bool) error {
+			return convert(r.Body, func(index int, note *model.Note, err error) bool {
+				if err != nil {
+					return add(importRecordResult{Index: index, Status: importInvalid, Error: err.Error()})
+				}
+				return add(h.importNote(r, index, note, policy))
+			})
+		}
+	}
+	if r.URL.Query().Get("async") == "true" {
+		h.submitImport(w, r, run)
+		return
+	}
+	h.runImport(w, run(r))
+}
+
+// importRecord decodes a single record and imports it (see importNote).
+func (h *Handler) importRecord(r *http.Request, index int, record json.RawMessage, policy string) importRecordResult {
+	result := importRecordResult{Index: index}
+
+	var note model.Note
+	if err := json.Unmarshal(record, &note); err != nil {
+		result.Status = importInvalid
+		result.Error = "record is not a note object"
+		return result
+	}
+	return h.importNote(r, index, &note, policy)
+}
+
+// importNote validates a single note and saves it according to the conflict policy.
+func (h *Handler) importNote(r *http.Request, index int, note *model.Note, policy string) importRecordResult {
+	result := importRecordResult{Index: index, ID: note.ID}
+	if err := validateImportedNote(note); err != nil {
+		result.Status = importInvalid
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx := r.Context()
+	exists, err := h.notes.Exists(ctx, note.ID)
+	if err != nil {
+		result.Status = importFailed
+		result.Error = "failed to check for an existing note"
+		return result
+	}
+
+	switch {
+	case !exists:
+		_, err = h.notes.Import(ctx, note, false)
+		result.Status = importCreated
+	case policy == conflictSkip:
+		result.Status = importSkipped
+	case policy == conflictFail:
+		result.Status = importConflict
+		result.Error = "note already exists"
+	default:
+		// A note deleted since the check is created instead
+		var created bool
+		created, err = h.notes.Import(ctx, note, true)
+		result.Status = importOverwritten
+		if created {
+			result.Status = importCreated
+		}
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidNote):
+		result.Status = importInvalid
+		result.Error = err.Error()
+	case errors.Is(err, service.ErrQuotaExceeded):
+		result.Status = importFailed
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = importFailed
+		result.Error = "failed to save note"
+	}
+	return result
+}
+
+// validateImportedNote checks that an imported note can be stored.
+func validateImportedNote(note *model.Note) error {
+	// The same IDs as in URLs, so every imported note can be addressed
+	if err := model.ValidateID(note.ID); err != nil {
+		return err
+	}
+	if note.Title == "" && note.Content == "" {
+		return errors.New("title or content is required")
+	}
+	if !note.UpdatedAt.IsZero() && note.UpdatedAt.Before(note.CreatedAt) {
+		return errors.New("updated_at must not be before created_at")
+	}
+	return nil
+}
+
+// readImportRecords calls fn for every record of the request body, without decoding the records,
+// so that a record that is not a note doesn't stop the import. It stops early if fn returns false.
+// It returns an error if the body is not well-formed NDJSON or JSON.
+func readImportRecords(r *http.Request, fn func(index int, record json.RawMessage) bool) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" || mediaType == "application/ndjson" {
+		return readNDJSONRecords(r.Body, fn)
+	}
+	return readJSONArrayRecords(r.Body, fn)
+}
+
+// readNDJSONRecords calls fn for every non-empty line of an NDJSON body.
+func readNDJSONRecords(body io.Reader, fn func(index int, record json.RawMessage) bool) error {
+	decoder := json.NewDecoder(body)
+	for index := 0; ; index++ {
+		var record json.RawMessage
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		if !fn(index, record) {
+			return nil
+		}
+	}
+}
+
+// readJSONArrayRecords calls fn for every element of a JSON array body.
+func readJSONArrayRecords(body io.Reader, fn func(index int, record json.RawMessage) bool) error {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("expected a JSON array of notes")
+	}
+
+	for index := 0; decoder.More(); index++ {
+		var record json.RawMessage
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		if !fn(index, record) {
+			return nil
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// importRequest serves POST /api/import with the given query and body through the handler's routes
+func importRequest(t *testing.T, backend storage.NoteStorage, query, contentType, body string) (*httptest.ResponseRecorder, importSummary) {
+	t.Helper()
+	r := chi.NewRouter()
+	NewHandler(backend, WithAdminToken("admin-token")).RegisterRoutes(r)
+
+	req := httptest.NewRequest("POST", "/api/import"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var summary importSummary
+	if w.Header().Get("Content-Type") == "application/json" {
+		if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w, summary
+}
+
+// importStatuses returns the status of every record of an import summary
+func importStatuses(summary importSummary) []string {
+	statuses := make([]string, len(summary.Results))
+	for i, result := range summary.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+// newImportBackend creates a storage with a single existing note
+func newImportBackend(t *testing.T) storage.NoteStorage {
+	t.Helper()
+	backend := storage.NewInMemoryStorage()
+	if err := backend.Create(context.Background(), &model.Note{ID: "existing", Title: "Original"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	return backend
+}
+
+// importBody is an NDJSON body with a new note, the existing note, and an invalid record
+const importBody = `{"_id":"new","title":"New","content":"Content","created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-02T00:00:00Z"}
+{"_id":"existing","title":"Imported"}
+{"title":"No ID"}
+`
+
+// TestImportNotesConflictPolicies tests every conflict policy of the import endpoint
+func TestImportNotesConflictPolicies(t *testing.T) {
+	tests := []struct {
+		query         string
+		wantCode      int
+		wantStatuses  []string
+		wantExisting  string
+		wantNewExists bool
+	}{
+		{"", http.StatusOK, []string{importCreated, importSkipped, importInvalid}, "Original", true},
+		{"?on_conflict=skip", http.StatusOK, []string{importCreated, importSkipped, importInvalid}, "Original", true},
+		{"?on_conflict=overwrite", http.StatusOK, []string{importCreated, importOverwritten, importInvalid}, "Imported", true},
+		{"?on_conflict=fail", http.StatusConflict, []string{importCreated, importConflict}, "Original", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			backend := newImportBackend(t)
+			w, summary := importRequest(t, backend, tt.query, "application/x-ndjson", importBody)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if statuses := importStatuses(summary); !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("Expected statuses %v, got %v", tt.wantStatuses, statuses)
+			}
+
+			existing, err := backend.Get(context.Background(), "existing")
+			if err != nil {
+				t.Fatalf("Failed to get the existing note: %v", err)
+			}
+			if existing.Title != tt.wantExisting {
+				t.Errorf("Expected the existing note's title %q, got %q", tt.wantExisting, existing.Title)
+			}
+			imported, err := backend.Get(context.Background(), "new")
+			if err != nil {
+				t.Fatalf("Failed to get the imported note: %v", err)
+			}
+			if imported.UpdatedAt.Year() != 2024 || imported.UpdatedAt.Day() != 2 {
+				t.Errorf("Expected the imported timestamps to be kept, got %v", imported.UpdatedAt)
+			}
+		})
+	}
+}
+
+// deletedAfterCheck is a storage that deletes a note right after checking that it exists,
+// as if another client deleted it in between
+type deletedAfterCheck struct {
+	*storage.InMemoryStorage
+}
+
+// Exists checks whether the note exists, and deletes it
+func (s deletedAfterCheck) Exists(ctx context.Context, id string) (bool, error) {
+	exists, err := s.InMemoryStorage.Exists(ctx, id)
+	if exists {
+		_ = s.InMemoryStorage.Delete(ctx, id)
+	}
+	return exists, err
+}
+
+// TestImportNotesOverwriteDeleted tests that overwriting a note deleted since it was found creates it
+func TestImportNotesOverwriteDeleted(t *testing.T) {
+	backend := deletedAfterCheck{InMemoryStorage: storage.NewInMemoryStorage()}
+	if err := backend.Create(context.Background(), &model.Note{ID: "existing", Title: "Original"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	w, summary := importRequest(t, backend, "?on_conflict=overwrite", "application/x-ndjson", `{"_id":"existing","title":"Imported"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if statuses := importStatuses(summary); !reflect.DeepEqual(statuses, []string{importCreated}) {
+		t.Errorf("Expected the note to be created, got %v", statuses)
+	}
+	if note, err := backend.Get(context.Background(), "existing"); err != nil || note.Title != "Imported" {
+		t.Errorf("Expected the imported note, got %+v: %v", note, err)
+	}
+}
+
+// TestImportNotesJSONArray tests importing a JSON array, including the summary counters
+func TestImportNotesJSONArray(t *testing.T) {
+	body := `[{"_id":"a","title":"A"}, {"_id":"has space","title":"B"}, 42, {"_id":"c","content":"C"}]`
+	w, summary := importRequest(t, storage.NewInMemoryStorage(), "", "application/json", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	want := []string{importCreated, importInvalid, importInvalid, importCreated}
+	if statuses := importStatuses(summary); !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Expected statuses %v, got %v", want, statuses)
+	}
+	if summary.Created != 2 || summary.Failed != 2 {
+		t.Errorf("Expected 2 created and 2 failed records, got %+v", summary)
+	}
+	if summary.Results[1].ID != "has space" || summary.Results[1].Error == "" {
+		t.Errorf("Expected the invalid record to report its ID and an error, got %+v", summary.Results[1])
+	}
+}
+
+// TestImportNotesRoundTrip tests that an export can be imported into another storage
+func TestImportNotesRoundTrip(t *testing.T) {
+	source := storage.NewInMemoryStorage()
+	for _, title := range []string{"First", "Second"} {
+		if err := source.Create(context.Background(), model.NewNote(title, "Content")); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	export := exportRequest(t, source, "")
+
+	target := storage.NewInMemoryStorage()
+	w, summary := importRequest(t, target, "", "application/x-ndjson", export.Body.String())
+	if w.Code != http.StatusOK || summary.Created != 2 {
+		t.Fatalf("Expected 2 created notes, got %d %+v", w.Code, summary)
+	}
+	notes, _ := target.GetAll(context.Background())
+	if len(notes) != 2 {
+		t.Errorf("Expected 2 notes in the target, got %d", len(notes))
+	}
+}
+
+// TestImportNotesErrors tests requests that cannot be imported
+func TestImportNotesErrors(t *testing.T) {
+	t.Run("UnknownPolicy", func(t *testing.T) {
+		w, _ := importRequest(t, storage.NewInMemoryStorage(), "?on_conflict=merge", "application/json", "[]")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("NotAnArray", func(t *testing.T) {
+		w, _ := importRequest(t, storage.NewInMemoryStorage(), "", "application/json", `{"_id":"a","title":"A"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("UnaddressableID", func(t *testing.T) {
+		// IDs that URLs reject can't be imported either, so every imported note can be read
+		backend := storage.NewInMemoryStorage()
+		body := "{\"_id\":\"a/b\",\"title\":\"Slash\"}\n{\"_id\":\"a@b\",\"title\":\"At\"}\n"
+		w, summary := importRequest(t, backend, "", "application/x-ndjson", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if got := importStatuses(summary); !reflect.DeepEqual(got, []string{importInvalid, importInvalid}) {
+			t.Errorf("Expected both records to be invalid, got %+v", summary)
+		}
+		for _, id := range []string{"a/b", "a@b"} {
+			if isValidNoteID(id) {
+				t.Errorf("Expected %q to be rejected in URLs too", id)
+			}
+			if _, err := backend.Get(context.Background(), id); err == nil {
+				t.Errorf("Expected note %q not to be imported", id)
+			}
+		}
+	})
+
+	t.Run("MalformedAfterRecords", func(t *testing.T) {
+		w, summary := importRequest(t, storage.NewInMemoryStorage(), "", "application/x-ndjson", "{\"_id\":\"a\",\"title\":\"A\"}\n{\"_id\":")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if summary.Created != 1 || summary.Error == "" {
+			t.Errorf("Expected the imported record and an error in the summary, got %+v", summary)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"golang-simple-notes/jobs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WithJobs enables the job admin endpoints and asynchronous imports, backed by the given runner.
+func WithJobs(runner *jobs.Runner) HandlerOption {
+	return func(h *Handler) {
+		h.jobs = runner
+	}
+}
+
+// listJobs handles GET /api/admin/jobs.
+// It returns the queued, running, and recent finished background jobs as a JSON array,
+// newest first, optionally filtered by ?kind= (e.g., webhook or import) and ?status=.
+func (h *Handler) listJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	writeAdminJSON(w, h.jobs.List(query.Get("kind"), query.Get("status")))
+}
+
+// getJob handles GET /api/admin/jobs/{jobID}.
+// It returns the status of a background job, or 404 Not Found if it doesn't exist or is no longer kept.
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(chi.URLParam(r, "jobID"))
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, job)
+}
+
+// writeJobAccepted responds to a request whose work has been submitted as a background
+// job with 202 Accepted, the status of the job, and its location in the admin API.
+func writeJobAccepted(w http.ResponseWriter, job jobs.Job) {
+	w.Header().Set("Location", "/api/admin/jobs/"+job.ID)
+	if err := writeJSON(w, http.StatusAccepted, job); err != nil {
+		http.Error(w, "Failed to encode job", http.StatusInternalServerError)
+	}
+}
+
+// jobRejected writes a 503 Service Unavailable if a job cannot be submitted because the
+// queue is full or the application is shutting down.
+func jobRejected(w http.ResponseWriter, err error) {
+	if errors.Is(err, jobs.ErrQueueFull) {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, "Cannot run the job: "+err.Error(), http.StatusServiceUnavailable)
+}
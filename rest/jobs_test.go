@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/jobs"
+)
+
+// newTestRunner creates a job runner, closed at the end of the test
+func newTestRunner(t *testing.T) *jobs.Runner {
+	t.Helper()
+	runner := jobs.NewRunner(2, 10)
+	t.Cleanup(func() { _ = runner.Close(context.Background()) })
+	return runner
+}
+
+// TestAsyncImport tests that an asynchronous import runs as a background job whose
+// status, with the import summary, is available from the admin API
+func TestAsyncImport(t *testing.T) {
+	backend := newImportBackend(t)
+	runner := jobs.NewRunner(1, 10)
+	r := chi.NewRouter()
+	NewHandler(backend, WithJobs(runner), WithAdminToken("admin-token")).RegisterRoutes(r)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		req.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/import?async=true", importBody)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var job jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil || job.Kind != "import" {
+		t.Fatalf("Unexpected job %s: %v", w.Body.String(), err)
+	}
+	if location := w.Header().Get("Location"); location != "/api/admin/jobs/"+job.ID {
+		t.Errorf("Unexpected location %q", location)
+	}
+	w = send("POST", "/api/import?async=true", "not json")
+	var malformed jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &malformed); err != nil {
+		t.Fatalf("Unexpected job %s: %v", w.Body.String(), err)
+	}
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	w = send("GET", "/api/admin/jobs/"+job.ID, "")
+	var status struct {
+		Status string        `json:"status"`
+		Result importSummary `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+	}
+	if status.Status != jobs.StatusSucceeded || status.Result.Created != 1 || status.Result.Skipped != 1 || status.Result.Failed != 1 {
+		t.Errorf("Expected a finished import with its summary, got %s", w.Body.String())
+	}
+	if _, err := backend.Get(context.Background(), "new"); err != nil {
+		t.Errorf("Expected the note to be imported, got %v", err)
+	}
+
+	w = send("GET", "/api/admin/jobs/"+malformed.ID, "")
+	if !strings.Contains(w.Body.String(), `"status":"failed"`) || !strings.Contains(w.Body.String(), "invalid request body") {
+		t.Errorf("Expected the import of a malformed body to fail, got %s", w.Body.String())
+	}
+
+	w = send("GET", "/api/admin/jobs?kind=import&status=failed", "")
+	var list []jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].ID != malformed.ID {
+		t.Errorf("Expected the failed import, got %s", w.Body.String())
+	}
+	if w = send("GET", "/api/admin/jobs/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// The runner is closed, so no more jobs are accepted
+	if w = send("POST", "/api/import?async=true", importBody); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
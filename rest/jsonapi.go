@@ -0,0 +1,336 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// JSONAPIMediaType is the media type of JSON:API documents (https://jsonapi.org), which
+// clients of the notes API may ask for instead of plain JSON.
+const JSONAPIMediaType = "application/vnd.api+json"
+
+// jsonAPINoteType is the type of notes in JSON:API documents.
+const jsonAPINoteType = "notes"
+
+// acceptsJSONAPI reports whether a request asks for JSON:API documents: its Accept header
+// lists the JSON:API media type without parameters. As JSON:API requires, a request that
+// only accepts the media type with parameters (e.g., extensions) is rejected.
+//
+// Returns:
+//   - Whether the response should be a JSON:API document
+//   - Whether the request accepts no representation the server can produce (406 Not Acceptable)
+func acceptsJSONAPI(r *http.Request) (bool, bool) {
+	accept := r.Header.Get("Accept")
+	if !strings.Contains(accept, JSONAPIMediaType) {
+		return false, false
+	}
+	others := false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		delete(params, "q") // A quality value isn't a media type parameter
+		switch {
+		case mediaType == JSONAPIMediaType && len(params) == 0:
+			return true, false
+		case mediaType != JSONAPIMediaType:
+			others = true
+		}
+	}
+	return false, !others
+}
+
+// jsonAPIMiddleware serves the routes of the notes API as JSON:API documents to clients
+// asking for them with the Accept header (see acceptsJSONAPI); other requests are passed
+// on unchanged. It converts the JSON responses of the handlers: a note becomes a resource
+// object of type "notes" (its _id the id, its _rev in meta, its links the resource links,
+// and its other fields the attributes), lists of notes and search results become arrays of
+// resources, with the total count in meta and pagination links, other objects become meta,
+// and errors become error objects. Bodies sent as JSON:API documents are converted to the
+// plain JSON bodies the handlers expect. JSON:API responses are built in memory, so lists
+// aren't streamed.
+func jsonAPIMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The representation depends on the Accept header, so caches must keep them apart
+		addVary(w.Header(), "Accept")
+
+		if isJSONAPI(r.Header.Get("Content-Type")) {
+			if !convertJSONAPIRequest(w, r) {
+				return
+			}
+		}
+		jsonAPI, notAcceptable := acceptsJSONAPI(r)
+		if notAcceptable {
+			writeJSONAPIError(w, http.StatusNotAcceptable, "JSON:API media type parameters are not supported")
+			return
+		}
+		if !jsonAPI {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &jsonAPIRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		recorder.finish(r)
+	})
+}
+
+// isJSONAPI reports whether a Content-Type is the JSON:API media type, with or without parameters.
+func isJSONAPI(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == JSONAPIMediaType
+}
+
+// jsonAPIRequest is the body of a request creating or updating a note as a JSON:API document.
+type jsonAPIRequest struct {
+	Data *struct {
+		Type       string                     `json:"type"`
+		ID         string                     `json:"id,omitempty"`
+		Attributes map[string]json.RawMessage `json:"attributes"`
+		Meta       struct {
+			Rev string `json:"rev,omitempty"`
+		} `json:"meta"`
+	} `json:"data"`
+}
+
+// convertJSONAPIRequest replaces the JSON:API document of a request body with the note it
+// describes, as plain JSON. It writes an error and returns false if the body can't be
+// converted: 415 Unsupported Media Type for media type parameters, 400 Bad Request for an
+// invalid document, and 409 Conflict for a resource of another type.
+func convertJSONAPIRequest(w http.ResponseWriter, r *http.Request) bool {
+	if _, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); len(params) > 0 {
+		writeJSONAPIError(w, http.StatusUnsupportedMediaType, "JSON:API media type parameters are not supported")
+		return false
+	}
+
+	var doc jsonAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil && err != io.EOF {
+		writeJSONAPIError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	note := make(map[string]json.RawMessage)
+	if doc.Data != nil {
+		if doc.Data.Type != jsonAPINoteType {
+			writeJSONAPIError(w, http.StatusConflict, "The type of the resource must be "+jsonAPINoteType)
+			return false
+		}
+		for name, value := range doc.Data.Attributes {
+			note[name] = value
+		}
+		if doc.Data.ID != "" {
+			note["_id"], _ = json.Marshal(doc.Data.ID)
+		}
+		if doc.Data.Meta.Rev != "" {
+			note["_rev"], _ = json.Marshal(doc.Data.Meta.Rev)
+		}
+	}
+	body, err := json.Marshal(note)
+	if err != nil {
+		writeJSONAPIError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/json")
+	return true
+}
+
+// jsonAPIRecorder holds the response of a handler, to convert it to a JSON:API document.
+type jsonAPIRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *jsonAPIRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *jsonAPIRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(p)
+}
+
+// finish writes the response of the handler as a JSON:API document. Responses without a
+// body (e.g., 204 No Content) and bodies that aren't JSON are written unchanged.
+func (r *jsonAPIRecorder) finish(req *http.Request) {
+	w := r.ResponseWriter
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.status >= http.StatusBadRequest {
+		writeJSONAPIErrorBody(w, r.status, r.body.Bytes())
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	var body any
+	decoder := json.NewDecoder(bytes.NewReader(r.body.Bytes()))
+	decoder.UseNumber() // Numbers are written back as they are
+	if r.body.Len() == 0 || mediaType != "application/json" || decoder.Decode(&body) != nil {
+		w.WriteHeader(r.status)
+		_, _ = w.Write(r.body.Bytes())
+		return
+	}
+
+	doc := make(map[string]any)
+	switch body := body.(type) {
+	case map[string]any:
+		if _, ok := body["_id"]; ok {
+			doc["data"] = noteResource(body)
+		} else {
+			doc["meta"] = body
+		}
+	case []any:
+		data := make([]any, len(body))
+		for i, item := range body {
+			data[i] = itemResource(item)
+		}
+		doc["data"] = data
+		doc["links"] = paginationLinks(req, w.Header().Get(TotalCountHeader))
+		if total, err := strconv.Atoi(w.Header().Get(TotalCountHeader)); err == nil {
+			doc["meta"] = map[string]any{"total": total}
+		}
+	default:
+		doc["meta"] = map[string]any{"value": body}
+	}
+
+	w.Header().Set("Content-Type", JSONAPIMediaType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(r.status)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+// noteResource converts the JSON representation of a note to a JSON:API resource object.
+func noteResource(note map[string]any) map[string]any {
+	resource := map[string]any{"type": jsonAPINoteType, "id": note["_id"]}
+	attributes := make(map[string]any, len(note))
+	for name, value := range note {
+		switch name {
+		case "_id":
+		case "_rev":
+			resource["meta"] = map[string]any{"rev": value}
+		case "links":
+			resource["links"] = value
+		default:
+			attributes[name] = value
+		}
+	}
+	resource["attributes"] = attributes
+	return resource
+}
+
+// itemResource converts an element of a list to a JSON:API resource object: a note, or a
+// search result, whose other fields (e.g., its score) go to the meta of its note.
+func itemResource(item any) any {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return item
+	}
+	if _, ok := obj["_id"]; ok {
+		return noteResource(obj)
+	}
+	note, ok := obj["note"].(map[string]any)
+	if !ok {
+		return item
+	}
+	resource := noteResource(note)
+	meta, _ := resource["meta"].(map[string]any)
+	if meta == nil {
+		meta = make(map[string]any)
+	}
+	for name, value := range obj {
+		if name != "note" {
+			meta[name] = value
+		}
+	}
+	resource["meta"] = meta
+	return resource
+}
+
+// paginationLinks returns the links of a list: itself, and, for a page of a list with a
+// known total count (see parseListOptions), the first, previous, next, and last pages.
+func paginationLinks(r *http.Request, totalCount string) map[string]any {
+	links := map[string]any{"self": r.URL.RequestURI()}
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		return links
+	}
+	total, err := strconv.Atoi(totalCount)
+	if err != nil {
+		return links
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	page := func(offset int) string {
+		query.Set("offset", strconv.Itoa(offset))
+		return r.URL.Path + "?" + query.Encode()
+	}
+	links["first"] = page(0)
+	links["last"] = page(max(0, (total-1)/limit*limit))
+	if offset > 0 {
+		links["prev"] = page(max(0, offset-limit))
+	}
+	if offset+limit < total {
+		links["next"] = page(offset + limit)
+	}
+	return links
+}
+
+// jsonAPIError is an error object of a JSON:API document.
+type jsonAPIError struct {
+	Status string         `json:"status"`           // HTTP status code, as a string
+	Title  string         `json:"title"`            // Summary of the error (by default, the status text)
+	Detail string         `json:"detail,omitempty"` // Message of the error
+	Meta   map[string]any `json:"meta,omitempty"`   // Other members of a problem details object (e.g., quota limits)
+}
+
+// writeJSONAPIError writes a JSON:API document with a single error.
+func writeJSONAPIError(w http.ResponseWriter, status int, detail string) {
+	writeJSONAPIErrorObject(w, jsonAPIError{Status: strconv.Itoa(status), Title: http.StatusText(status), Detail: detail})
+}
+
+// writeJSONAPIErrorBody writes the error response of a handler as a JSON:API document: the
+// message of a plain text error is the detail of the error object, and the title and detail
+// of a problem details object (see writeProblem) are kept, with its other members in meta.
+func writeJSONAPIErrorBody(w http.ResponseWriter, status int, body []byte) {
+	var problem map[string]any
+	if err := json.Unmarshal(body, &problem); err != nil {
+		writeJSONAPIError(w, status, strings.TrimSpace(string(body)))
+		return
+	}
+	object := jsonAPIError{Status: strconv.Itoa(status), Title: http.StatusText(status), Meta: make(map[string]any)}
+	for name, value := range problem {
+		text, _ := value.(string)
+		switch name {
+		case "title":
+			object.Title = text
+		case "detail":
+			object.Detail = text
+		case "status":
+		default:
+			object.Meta[name] = value
+		}
+	}
+	writeJSONAPIErrorObject(w, object)
+}
+
+// writeJSONAPIErrorObject writes a JSON:API document with a single error object.
+func writeJSONAPIErrorObject(w http.ResponseWriter, object jsonAPIError) {
+	status, _ := strconv.Atoi(object.Status)
+	w.Header().Set("Content-Type", JSONAPIMediaType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": []jsonAPIError{object}})
+}
@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+)
+
+// jsonAPIDocument is a JSON:API document, as tests decode it.
+type jsonAPIDocument struct {
+	Data   json.RawMessage   `json:"data"`
+	Meta   map[string]any    `json:"meta"`
+	Links  map[string]string `json:"links"`
+	Errors []jsonAPIError    `json:"errors"`
+}
+
+// jsonAPIResource is a JSON:API resource object, as tests decode it.
+type jsonAPIResource struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Attributes map[string]any `json:"attributes"`
+	Meta       map[string]any `json:"meta"`
+	Links      noteLinks      `json:"links"`
+}
+
+// TestJSONAPI tests serving the notes API as JSON:API documents to clients asking for them
+func TestJSONAPI(t *testing.T) {
+	backend := fake.New()
+	for _, id := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		backend.Add(&model.Note{ID: id, Title: "Note " + id, Rev: "1-" + id})
+	}
+	r := chi.NewRouter()
+	NewHandler(backend).RegisterRoutes(r)
+
+	// send sends a request, and decodes the JSON:API document of the response
+	send := func(t *testing.T, method, target, body string, header map[string]string) (*httptest.ResponseRecorder, jsonAPIDocument) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Accept", JSONAPIMediaType)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var doc jsonAPIDocument
+		if w.Body.Len() > 0 {
+			if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("Failed to unmarshal document: %v: %s", err, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != JSONAPIMediaType {
+				t.Errorf("Expected Content-Type %s, got %q", JSONAPIMediaType, contentType)
+			}
+		}
+		return w, doc
+	}
+
+	t.Run("Note", func(t *testing.T) {
+		w, doc := send(t, "GET", "/api/notes/n1", "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resource jsonAPIResource
+		if err := json.Unmarshal(doc.Data, &resource); err != nil {
+			t.Fatalf("Failed to unmarshal resource: %v", err)
+		}
+		if resource.Type != "notes" || resource.ID != "n1" || resource.Attributes["title"] != "Note n1" {
+			t.Errorf("Unexpected resource %+v", resource)
+		}
+		if _, ok := resource.Attributes["_id"]; ok || resource.Meta["rev"] != "1-n1" || resource.Links.Self != "/api/notes/n1" {
+			t.Errorf("Expected the ID, revision, and links outside the attributes, got %+v", resource)
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept") {
+			t.Errorf("Expected the response to vary by Accept, got %q", w.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w, doc := send(t, "GET", "/api/notes?sort=title&limit=2&offset=2", "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var resources []jsonAPIResource
+		if err := json.Unmarshal(doc.Data, &resources); err != nil {
+			t.Fatalf("Failed to unmarshal resources: %v", err)
+		}
+		if len(resources) != 2 || resources[0].ID != "n3" || resources[1].ID != "n4" {
+			t.Errorf("Expected the third and fourth notes, got %+v", resources)
+		}
+		if doc.Meta["total"] != float64(5) {
+			t.Errorf("Expected the total count in meta, got %v", doc.Meta)
+		}
+		want := map[string]string{
+			"self":  "/api/notes?sort=title&limit=2&offset=2",
+			"first": "/api/notes?limit=2&offset=0&sort=title",
+			"prev":  "/api/notes?limit=2&offset=0&sort=title",
+			"next":  "/api/notes?limit=2&offset=4&sort=title",
+			"last":  "/api/notes?limit=2&offset=4&sort=title",
+		}
+		for name, link := range want {
+			if doc.Links[name] != link {
+				t.Errorf("Expected the %s link %s, got %q", name, link, doc.Links[name])
+			}
+		}
+
+		// The last page has no next page
+		if _, doc := send(t, "GET", "/api/notes?limit=2&offset=4", "", nil); doc.Links["next"] != "" || doc.Links["prev"] == "" {
+			t.Errorf("Expected a previous page only, got %v", doc.Links)
+		}
+		// Other objects become meta
+		if _, doc := send(t, "GET", "/api/notes/count", "", nil); doc.Meta["count"] != float64(5) || doc.Data != nil {
+			t.Errorf("Expected the count in meta, got %+v", doc)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		header := map[string]string{"Content-Type": JSONAPIMediaType}
+		w, doc := send(t, "POST", "/api/notes", `{"data":{"type":"notes","attributes":{"title":"Created","content":"Body"}}}`, header)
+		if w.Code != http.StatusCreated || w.Header().Get("Location") == "" {
+			t.Fatalf("Expected status code %d with a Location, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var resource jsonAPIResource
+		if err := json.Unmarshal(doc.Data, &resource); err != nil || resource.Attributes["content"] != "Body" {
+			t.Fatalf("Expected the created note, got %s: %v", doc.Data, err)
+		}
+
+		w, _ = send(t, "PUT", "/api/notes/"+resource.ID, `{"data":{"type":"notes","id":"`+resource.ID+`","attributes":{"title":"Updated"}}}`, header)
+		if w.Code != http.StatusOK || backend.Note(resource.ID).Title != "Updated" {
+			t.Errorf("Expected the note to be updated, got %d: %s", w.Code, w.Body.String())
+		}
+
+		// Plain JSON bodies are accepted as well
+		if w, _ := send(t, "PUT", "/api/notes/"+resource.ID, `{"title":"Plain"}`, nil); w.Code != http.StatusOK {
+			t.Errorf("Expected a plain JSON body to be accepted, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if w, _ := send(t, "DELETE", "/api/notes/"+resource.ID, "", nil); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Errorf("Expected status code %d without a body, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			method string
+			target string
+			body   string
+			header map[string]string
+			status int
+		}{
+			{"NotFound", "GET", "/api/notes/missing", "", nil, http.StatusNotFound},
+			{"InvalidQuery", "GET", "/api/notes?limit=0", "", nil, http.StatusBadRequest},
+			{"InvalidNote", "POST", "/api/notes", `{"data":{"type":"notes","attributes":{}}}`, map[string]string{"Content-Type": JSONAPIMediaType}, http.StatusBadRequest},
+			{"OtherType", "POST", "/api/notes", `{"data":{"type":"people","attributes":{"title":"T"}}}`, map[string]string{"Content-Type": JSONAPIMediaType}, http.StatusConflict},
+			{"ContentTypeParameters", "POST", "/api/notes", `{}`, map[string]string{"Content-Type": JSONAPIMediaType + "; ext=bulk"}, http.StatusUnsupportedMediaType},
+			{"AcceptParameters", "GET", "/api/notes", "", map[string]string{"Accept": JSONAPIMediaType + "; ext=bulk"}, http.StatusNotAcceptable},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w, doc := send(t, tt.method, tt.target, tt.body, tt.header)
+				if w.Code != tt.status {
+					t.Fatalf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+				}
+				if len(doc.Errors) != 1 || doc.Errors[0].Status != strconv.Itoa(tt.status) || doc.Errors[0].Title != http.StatusText(tt.status) {
+					t.Errorf("Expected an error object with the status, got %+v", doc.Errors)
+				}
+			})
+		}
+	})
+
+	t.Run("PlainJSON", func(t *testing.T) {
+		// Without the JSON:API media type, or with it among others with parameters only, responses are plain JSON
+		for _, accept := range []string{"", "application/json", JSONAPIMediaType + "; ext=bulk, application/json"} {
+			req := httptest.NewRequest("GET", "/api/notes/n1", nil)
+			req.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"_id":"n1"`) {
+				t.Errorf("Accept %q: expected a plain JSON note, got %d %q: %s", accept, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"golang-simple-notes/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getBacklinks handles GET /api/notes/{id}/backlinks.
+// It returns the notes whose contents link to the note, with [[id]] or [[title]], as a
+// JSON array sorted by title, which is empty if no note links to it.
+// If the note doesn't exist, it returns a 404 Not Found.
+func (h *Handler) getBacklinks(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	loc, err := parseTimezone(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notes, err := h.notes.Backlinks(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoteNotFound) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to get backlinks", http.StatusInternalServerError)
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, linkNotes(notesInTimezone(notes, loc))); err != nil {
+		http.Error(w, "Failed to encode notes", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestGetBacklinks tests that the notes linking to a note by ID or title are returned
+func TestGetBacklinks(t *testing.T) {
+	mockStorage := fake.New()
+	mockStorage.Add(&model.Note{ID: "target", Title: "Target"})
+	mockStorage.Add(&model.Note{ID: "by-id", Title: "B", Content: "See [[target]]."})
+	mockStorage.Add(&model.Note{ID: "by-title", Title: "A", Content: "See [[target|the target]]."})
+	mockStorage.Add(&model.Note{ID: "other", Title: "C", Content: "See [[by-id]]."})
+
+	r := chi.NewRouter()
+	NewHandler(mockStorage).RegisterRoutes(r)
+
+	tests := []struct {
+		id     string
+		status int
+		want   []string
+	}{
+		{"target", http.StatusOK, []string{"by-title", "by-id"}},
+		{"other", http.StatusOK, []string{}},
+		{"missing", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/notes/"+tt.id+"/backlinks", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+			var notes []*model.Note
+			if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if notes == nil {
+				t.Fatal("Expected a JSON array, got null")
+			}
+			if len(notes) != len(tt.want) {
+				t.Fatalf("Expected %d backlinks, got %+v", len(tt.want), notes)
+			}
+			for i, note := range notes {
+				if note.ID != tt.want[i] {
+					t.Errorf("Expected backlink %d to be %s, got %s", i, tt.want[i], note.ID)
+				}
+			}
+		})
+	}
+}
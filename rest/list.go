@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-simple-notes/storage"
+)
+
+// maxListLimit is the largest page size a client can request with ?limit=.
+const maxListLimit = 1000
+
+// TotalCountHeader is the response header of GET /api/notes holding the number of notes
+// matching the query, regardless of ?limit= and ?offset=.
+const TotalCountHeader = "X-Total-Count"
+
+// parseListOptions parses the list query parameters of GET /api/notes:
+//   - q: text the title or content must contain, ignoring case and accents
+//   - accents: "match" for the accents of q to match; "ignore" (the default) ignores them
+//   - filter: filter expression the notes must match (see storage.ParseFilter)
+//   - sort: created_at, updated_at, title, or most_viewed; a leading "-" sorts in descending order
+//   - limit: maximum number of notes to return (1 to maxListLimit)
+//   - offset: number of matching notes to skip
+//
+// It returns an error describing the first invalid parameter.
+func parseListOptions(r *http.Request) (storage.ListOptions, error) {
+	query := r.URL.Query()
+	opts := storage.ListOptions{Query: strings.TrimSpace(query.Get("q"))}
+
+	switch query.Get("accents") {
+	case "", "ignore":
+	case "match":
+		opts.MatchAccents = true
+	default:
+		return storage.ListOptions{}, fmt.Errorf("accents must be ignore or match")
+	}
+
+	filter, err := storage.ParseFilter(query.Get("filter"))
+	if err != nil {
+		return storage.ListOptions{}, err
+	}
+	opts.Filter = filter
+
+	if sort := query.Get("sort"); sort != "" {
+		opts.Sort, opts.Descending = strings.CutPrefix(sort, "-")
+		if opts.Sort == "" {
+			return storage.ListOptions{}, fmt.Errorf("sort must name a field")
+		}
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return storage.ListOptions{}, fmt.Errorf("limit must be a number between 1 and %d", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return storage.ListOptions{}, fmt.Errorf("offset must be a non-negative number")
+		}
+		opts.Offset = offset
+	}
+
+	if err := opts.Validate(); err != nil {
+		return storage.ListOptions{}, err
+	}
+	return opts, nil
+}
+
+// countNotes handles GET /api/notes/count.
+// It returns the number of notes matching the ?q= and ?filter= parameters (all notes
+// without them) as a JSON object, e.g., {"count": 42}. The other list parameters are accepted but
+// don't change the count.
+func (h *Handler) countNotes(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.notes.Count(r.Context(), opts)
+	if err != nil {
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to count notes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"count": count}); err != nil {
+		http.Error(w, "Failed to encode count", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+)
+
+// TestParseListOptions tests parsing of the list query parameters
+func TestParseListOptions(t *testing.T) {
+	valid := []struct {
+		query string
+		want  storage.ListOptions
+	}{
+		{"", storage.ListOptions{}},
+		{"q=+groceries+", storage.ListOptions{Query: "groceries"}},
+		{"q=caf%C3%A9&accents=match", storage.ListOptions{Query: "café", MatchAccents: true}},
+		{"accents=ignore", storage.ListOptions{}},
+		{"sort=title", storage.ListOptions{Sort: storage.SortTitle}},
+		{"sort=-created_at", storage.ListOptions{Sort: storage.SortCreatedAt, Descending: true}},
+		{"sort=most_viewed", storage.ListOptions{Sort: storage.SortMostViewed}},
+		{"limit=10&offset=20", storage.ListOptions{Limit: 10, Offset: 20}},
+	}
+	for _, tt := range valid {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseListOptions(httptest.NewRequest("GET", "/api/notes?"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	for _, query := range []string{"sort=content", "sort=-", "limit=0", "limit=1001", "limit=ten", "offset=-1", "filter=priority:high", "filter=title:", "accents=strict"} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := parseListOptions(httptest.NewRequest("GET", "/api/notes?"+query, nil)); err == nil {
+				t.Errorf("Expected an error for %q", query)
+			}
+		})
+	}
+}
+
+// TestGetAllNotesListQuery tests filtering, sorting, and pagination of GET /api/notes
+func TestGetAllNotesListQuery(t *testing.T) {
+	mockStorage := fake.New()
+	handler := NewHandler(mockStorage)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"Shopping list", "Meeting notes", "Shopping ideas"} {
+		note := model.NewNote(title, "Content")
+		note.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		if err := mockStorage.Create(context.Background(), note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		req := setupTestRequest("GET", "/api/notes?q=shopping&sort=-created_at&limit=1", "")
+		w := httptest.NewRecorder()
+		handler.getAllNotes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var response []*model.Note
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(response) != 1 || response[0].Title != "Shopping ideas" {
+			t.Errorf("Expected only the newest shopping note, got %+v", response)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		filter := url.QueryEscape(`title:shopping AND created_at>=2024-01-01T01:00:00Z`)
+		req := setupTestRequest("GET", "/api/notes?filter="+filter, "")
+		w := httptest.NewRecorder()
+		handler.getAllNotes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var response []*model.Note
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(response) != 1 || response[0].Title != "Shopping ideas" || w.Header().Get(TotalCountHeader) != "1" {
+			t.Errorf("Expected only the newer shopping note, got %+v", response)
+		}
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		req := setupTestRequest("GET", "/api/notes?sort=content", "")
+		w := httptest.NewRecorder()
+		handler.getAllNotes(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+// TestTotalCount tests the X-Total-Count header of GET /api/notes and GET /api/notes/count
+func TestTotalCount(t *testing.T) {
+	mockStorage := fake.New()
+	handler := NewHandler(mockStorage)
+	for _, title := range []string{"Shopping list", "Meeting notes", "Shopping ideas"} {
+		if err := mockStorage.Create(context.Background(), model.NewNote(title, "Content")); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	t.Run("Header", func(t *testing.T) {
+		req := setupTestRequest("GET", "/api/notes?q=shopping&limit=1", "")
+		w := httptest.NewRecorder()
+		handler.getAllNotes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get(TotalCountHeader); got != "2" {
+			t.Errorf("Expected %s 2, got %q", TotalCountHeader, got)
+		}
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		req := setupTestRequest("GET", "/api/notes/count?q=shopping", "")
+		w := httptest.NewRecorder()
+		handler.countNotes(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var response map[string]int
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if response["count"] != 2 {
+			t.Errorf("Expected a count of 2, got %v", response)
+		}
+	})
+
+	t.Run("StorageError", func(t *testing.T) {
+		req := setupTestRequest("GET", "/api/notes/count", "")
+		w := httptest.NewRecorder()
+		NewHandler(newFailingStorage()).countNotes(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
+
+// TestGetAllNotesStreaming tests that unsorted lists are streamed, and aborted if reading fails midway
+func TestGetAllNotesStreaming(t *testing.T) {
+	list := func(backend storage.NoteStorage, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		NewHandler(backend).getAllNotes(w, setupTestRequest("GET", "/api/notes"+query, ""))
+		return w
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		backend := &streamFailingStorage{Storage: fake.New(), failAfter: 3}
+		for _, title := range []string{"Shopping list", "Meeting notes"} {
+			if err := backend.Create(context.Background(), model.NewNote(title, "Content")); err != nil {
+				t.Fatalf("Failed to create note: %v", err)
+			}
+		}
+		// A sorted list is read at once, without the failing stream
+		w := list(backend, "?sort=title&q=shopping")
+		var notes []*model.Note
+		if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil || len(notes) != 1 || notes[0].Title != "Shopping list" {
+			t.Errorf("Expected the matching note, got %s: %v", w.Body.String(), err)
+		}
+	})
+
+	t.Run("BeforeFirstNote", func(t *testing.T) {
+		w := list(&streamFailingStorage{Storage: fake.New()}, "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+
+	t.Run("AfterFirstNote", func(t *testing.T) {
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("Expected the handler to abort the response, got %v", recovered)
+			}
+		}()
+		list(&streamFailingStorage{Storage: fake.New(), failAfter: 1}, "")
+		t.Error("Expected the handler to panic")
+	})
+}
@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-simple-notes/metrics"
+)
+
+// ConcurrencyLimits configures LoadSheddingMiddleware. A limit of zero disables it.
+type ConcurrencyLimits struct {
+	Total      int           // Maximum number of /api requests in flight
+	Reads      int           // Maximum number of GET, HEAD, and OPTIONS requests in flight
+	Writes     int           // Maximum number of other requests in flight
+	RetryAfter time.Duration // Delay suggested to rejected clients in the Retry-After header
+}
+
+// semaphore limits the number of requests in flight; a nil semaphore has no limit.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore with the given number of slots, or nil if limit is not positive.
+func newSemaphore(limit int) semaphore {
+	if limit <= 0 {
+		return nil
+	}
+	return make(semaphore, limit)
+}
+
+// tryAcquire takes a slot without waiting, and reports whether one was free.
+func (s semaphore) tryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot taken by tryAcquire.
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// LoadSheddingMiddleware bounds the number of /api requests handled at once, so latency
+// stays bounded during spikes instead of every request slowing down. Requests arriving
+// while the limits are reached are rejected right away with 503 Service Unavailable and
+// a Retry-After header. Reads and writes have separate limits, so a burst of slow writes
+// (e.g., imports) cannot starve reads, and vice versa; both also count against the total.
+// Health probes, metrics, and WebSocket connections (which stay open) are never limited.
+//
+// Parameters:
+//   - limits: The concurrency limits and the Retry-After delay
+//
+// Returns:
+//   - The middleware
+func LoadSheddingMiddleware(limits ConcurrencyLimits) func(http.Handler) http.Handler {
+	total, reads, writes := newSemaphore(limits.Total), newSemaphore(limits.Reads), newSemaphore(limits.Writes)
+	retryAfter := strconv.Itoa(max(1, int(math.Ceil(limits.RetryAfter.Seconds()))))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			class, limit := "write", writes
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				class, limit = "read", reads
+			}
+
+			if !limit.tryAcquire() {
+				shed(w, class, retryAfter)
+				return
+			}
+			defer limit.release()
+			if !total.tryAcquire() {
+				shed(w, class, retryAfter)
+				return
+			}
+			defer total.release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// shed rejects a request because the server is at its concurrency limit.
+func shed(w http.ResponseWriter, class, retryAfter string) {
+	metrics.HTTPRequestsShed.WithLabelValues(class).Inc()
+	w.Header().Set("Retry-After", retryAfter)
+	http.Error(w, "Server is overloaded, retry later", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLoadSheddingMiddleware tests the total, read, and write concurrency limits
+func TestLoadSheddingMiddleware(t *testing.T) {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/slow" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func(handler http.Handler, method, path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	// block starts n slow requests and waits until all of them are being handled
+	block := func(handler http.Handler, method string, n int, wg *sync.WaitGroup) {
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				send(handler, method, "/api/slow")
+			}()
+			<-entered
+		}
+	}
+
+	t.Run("ReadsAndWrites", func(t *testing.T) {
+		handler := LoadSheddingMiddleware(ConcurrencyLimits{Reads: 2, Writes: 1, RetryAfter: 1500 * time.Millisecond})(next)
+		var wg sync.WaitGroup
+		block(handler, "GET", 2, &wg)
+
+		w := send(handler, "GET", "/api/notes")
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Expected 503 with Retry-After 2 once reads are saturated, got %d %q", w.Code, w.Header().Get("Retry-After"))
+		}
+		if w := send(handler, "POST", "/api/notes"); w.Code != http.StatusOK {
+			t.Errorf("Expected writes to have their own limit, got %d", w.Code)
+		}
+		for _, path := range []string{"/health/ready", "/metrics"} {
+			if w := send(handler, "GET", path); w.Code != http.StatusOK {
+				t.Errorf("Expected %s not to be limited, got %d", path, w.Code)
+			}
+		}
+		if w := send(handler, "GET", "/api/ws", "Upgrade", "websocket"); w.Code != http.StatusOK {
+			t.Errorf("Expected WebSocket connections not to be limited, got %d", w.Code)
+		}
+
+		close(unblock)
+		wg.Wait()
+		unblock = make(chan struct{})
+		if w := send(handler, "GET", "/api/notes"); w.Code != http.StatusOK {
+			t.Errorf("Expected reads to be accepted again, got %d", w.Code)
+		}
+	})
+
+	t.Run("Total", func(t *testing.T) {
+		handler := LoadSheddingMiddleware(ConcurrencyLimits{Total: 1})(next)
+		var wg sync.WaitGroup
+		block(handler, "PUT", 1, &wg)
+
+		w := send(handler, "GET", "/api/notes")
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+			t.Errorf("Expected 503 with Retry-After 1 once the total is reached, got %d %q", w.Code, w.Header().Get("Retry-After"))
+		}
+
+		close(unblock)
+		wg.Wait()
+	})
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"golang-simple-notes/logging"
+	"golang-simple-notes/requestid"
+)
+
+// logLevelBody is the request and response body of /api/admin/loglevel.
+type logLevelBody struct {
+	Level string `json:"level"` // Minimum level of log messages: "debug", "info", "warn", or "error"
+}
+
+// getLogLevel handles GET /api/admin/loglevel.
+// It returns the current minimum level of log messages.
+func (h *Handler) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, logLevelBody{Level: logging.Level()})
+}
+
+// setLogLevel handles PUT /api/admin/loglevel.
+// It changes the minimum level of log messages until the next restart or configuration
+// reload, which applies LOG_LEVEL again.
+func (h *Handler) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.Level == "" {
+		http.Error(w, "Missing log level", http.StatusBadRequest)
+		return
+	}
+
+	previous := logging.Level()
+	if err := logging.SetLevel(body.Level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Logged as a warning, so that the change shows up whatever the new level is
+	slog.Warn(requestid.LogPrefix(r.Context())+"admin: log level changed", "from", previous, "to", logging.Level())
+	writeAdminJSON(w, logLevelBody{Level: logging.Level()})
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/logging"
+	"golang-simple-notes/storage/fake"
+)
+
+// TestLogLevel tests reading and changing the log level at runtime
+func TestLogLevel(t *testing.T) {
+	previous := logging.Level()
+	t.Cleanup(func() { _ = logging.SetLevel(previous) })
+	r := maintenanceRouter(fake.New())
+
+	w := serveAdmin(r, "PUT", "/api/admin/loglevel", `{"level":"DEBUG"}`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"level":"debug"}` {
+		t.Fatalf("Expected the level to be changed to debug, got %d %s", w.Code, w.Body.String())
+	}
+	if level := logging.Level(); level != "debug" {
+		t.Errorf("Expected the log level to be debug, got %s", level)
+	}
+	w = serveAdmin(r, "GET", "/api/admin/loglevel", "")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"level":"debug"}` {
+		t.Errorf("Expected the current level, got %d %s", w.Code, w.Body.String())
+	}
+
+	for name, body := range map[string]string{
+		"Invalid":   `{"level":"verbose"}`,
+		"Missing":   `{}`,
+		"Malformed": `level=debug`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if w := serveAdmin(r, "PUT", "/api/admin/loglevel", body); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if level := logging.Level(); level != "debug" {
+				t.Errorf("Expected the log level to be kept, got %s", level)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// purgeConfirmationTTL is how long a purge confirmation token stays valid.
+const purgeConfirmationTTL = 5 * time.Minute
+
+// purgeRequest is the request body for POST /api/admin/purge.
+type purgeRequest struct {
+	Confirm string `json:"confirm,omitempty"` // Confirmation token from a previous request; empty to get one
+}
+
+// purgeConfirmation is the response to a purge request without a confirmation token.
+type purgeConfirmation struct {
+	Token     string    `json:"confirmation_token"` // Token that confirms the purge
+	Notes     int       `json:"notes"`              // Number of notes that the purge would delete
+	ExpiresAt time.Time `json:"expires_at"`         // Time after which the token is no longer accepted
+}
+
+// purgeResult is the response to a confirmed purge.
+type purgeResult struct {
+	Deleted int `json:"deleted"` // Number of notes deleted
+}
+
+// maintenanceResult is the response to a maintenance task.
+type maintenanceResult struct {
+	Task   storage.MaintenanceTask `json:"task"`   // Task that was run
+	Status string                  `json:"status"` // Always "ok"; failures are reported with an error status
+}
+
+// purgeGuard holds the pending purge confirmation token. Only the most recent token is
+// pending, and it can be used once.
+type purgeGuard struct {
+	mutex   sync.Mutex
+	token   string    // Pending confirmation token; empty if none
+	expires time.Time // Expiry of the pending token
+}
+
+// issue generates a new confirmation token, replacing the pending one.
+func (g *purgeGuard) issue() (string, time.Time, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.token = hex.EncodeToString(b)
+	g.expires = time.Now().Add(purgeConfirmationTTL)
+	return g.token, g.expires, nil
+}
+
+// redeem reports whether the token is the pending, unexpired confirmation token,
+// and if so, consumes it.
+func (g *purgeGuard) redeem(token string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.token == "" || time.Now().After(g.expires) {
+		return false
+	}
+	// Compare in constant time so the token can't be guessed byte by byte
+	if subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) != 1 {
+		return false
+	}
+	g.token = ""
+	return true
+}
+
+// purgeNotes handles POST /api/admin/purge.
+// Purging deletes all notes and takes two requests:
+//  1. Without a body (or without "confirm"), it returns a confirmation token that is
+//     valid for five minutes, along with the number of notes that would be deleted.
+//  2. With {"confirm": "<token>"}, it deletes all notes and returns how many were deleted.
+//
+// A wrong, expired, or already used token returns a 400 Bad Request.
+// The notes are deleted one at a time, so note events are published for every note.
+func (h *Handler) purgeNotes(w http.ResponseWriter, r *http.Request) {
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Confirm == "" {
+		count, err := h.notes.Count(r.Context(), storage.ListOptions{})
+		if err != nil {
+			if storageUnavailable(w, err) {
+				return
+			}
+			http.Error(w, "Failed to count notes", http.StatusInternalServerError)
+			return
+		}
+		token, expires, err := h.purge.issue()
+		if err != nil {
+			http.Error(w, "Failed to generate confirmation token", http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, purgeConfirmation{Token: token, Notes: count, ExpiresAt: expires})
+		return
+	}
+
+	if !h.purge.redeem(req.Confirm) {
+		http.Error(w, "Invalid or expired confirmation token", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.notes.Purge(r.Context())
+	log.Printf("%sadmin: purged %d notes", requestid.LogPrefix(r.Context()), deleted)
+	if err != nil {
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to purge notes", http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, purgeResult{Deleted: deleted})
+}
+
+// maintain returns a handler for POST /api/admin/reindex and POST /api/admin/compact,
+// which runs the maintenance task on the storage backend.
+// If the backend doesn't support the task, it returns a 501 Not Implemented.
+func (h *Handler) maintain(task storage.MaintenanceTask) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := storage.Maintain(r.Context(), h.storage, task)
+		if err != nil {
+			// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+			if storageUnavailable(w, err) {
+				return
+			}
+			if errors.Is(err, storage.ErrUnsupportedTask) {
+				http.Error(w, err.Error(), http.StatusNotImplemented)
+				return
+			}
+			log.Printf("%sadmin: %s failed: %v", requestid.LogPrefix(r.Context()), task, err)
+			http.Error(w, "Failed to run "+string(task), http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, maintenanceResult{Task: task, Status: "ok"})
+	}
+}
+
+// writeAdminJSON writes the response of an admin endpoint as JSON.
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
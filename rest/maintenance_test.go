@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maintenanceRouter returns a router for a handler with an admin token on the given storage
+func maintenanceRouter(mockStorage *fake.Storage) chi.Router {
+	r := chi.NewRouter()
+	NewHandler(mockStorage, WithAdminToken("admin-token")).RegisterRoutes(r)
+	return r
+}
+
+// serveAdmin serves an authenticated admin request
+func serveAdmin(r chi.Router, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestPurgeNotes tests that purging requires a confirmation token, which can be used once
+func TestPurgeNotes(t *testing.T) {
+	mockStorage := fake.New()
+	for _, id := range []string{"a", "b"} {
+		mockStorage.Add(&model.Note{ID: id, Title: id})
+	}
+	r := maintenanceRouter(mockStorage)
+
+	w := serveAdmin(r, "POST", "/api/admin/purge", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var confirmation purgeConfirmation
+	if err := json.Unmarshal(w.Body.Bytes(), &confirmation); err != nil || confirmation.Token == "" || confirmation.Notes != 2 {
+		t.Fatalf("Unexpected confirmation %s: %v", w.Body.String(), err)
+	}
+	if len(mockStorage.Notes()) != 2 {
+		t.Fatalf("Expected no notes to be deleted before confirmation, got %d notes", len(mockStorage.Notes()))
+	}
+
+	if w := serveAdmin(r, "POST", "/api/admin/purge", `{"confirm":"wrong"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for a wrong token, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serveAdmin(r, "POST", "/api/admin/purge", `{"confirm":"`+confirmation.Token+`"}`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"deleted":2}` {
+		t.Fatalf("Expected 2 notes to be deleted, got %d %s", w.Code, w.Body.String())
+	}
+	if len(mockStorage.Notes()) != 0 {
+		t.Errorf("Expected no notes after the purge, got %d", len(mockStorage.Notes()))
+	}
+
+	if w := serveAdmin(r, "POST", "/api/admin/purge", `{"confirm":"`+confirmation.Token+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for a used token, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// TestMaintenanceEndpoints tests the maintenance endpoints on a storage without maintenance tasks,
+// and that they are neither registered without an admin token nor served without authentication
+func TestMaintenanceEndpoints(t *testing.T) {
+	r := maintenanceRouter(fake.New())
+	for _, path := range []string{"/api/admin/reindex", "/api/admin/compact"} {
+		if w := serveAdmin(r, "POST", path, ""); w.Code != http.StatusNotImplemented {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusNotImplemented, path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/purge", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d without a token, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	if w := adminRequest(nil, "", "POST", "/api/admin/purge", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d without an admin token, got %d", http.StatusNotFound, w.Code)
+	}
+}
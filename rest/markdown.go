@@ -0,0 +1,326 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang-simple-notes/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// markdownFrontMatter is the metadata written at the top of every exported Markdown file.
+type markdownFrontMatter struct {
+	ID        string    `yaml:"id"`
+	Title     string    `yaml:"title"`
+	Color     string    `yaml:"color,omitempty"`
+	Icon      string    `yaml:"icon,omitempty"`
+	Tags      []string  `yaml:"tags,omitempty"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+// maxMarkdownNameLength is the maximum length of a Markdown file name, without the extension.
+const maxMarkdownNameLength = 64
+
+// maxImportArchiveSize is the maximum size of a ZIP archive of Markdown files to import,
+// compressed, and of each file in it, uncompressed.
+const maxImportArchiveSize = 64 << 20
+
+// Front matter keys of imported Markdown files. Besides the keys of exported files, the keys
+// used by Obsidian and Notable are recognized.
+var (
+	markdownCreatedKeys = []string{"created_at", "created", "date"}
+	markdownUpdatedKeys = []string{"updated_at", "updated", "modified"}
+)
+
+// markdownTimeLayouts are the formats accepted for timestamps in front matter given as strings.
+var markdownTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", time.DateOnly}
+
+// zipMarkdownWriter writes notes as Markdown files with YAML front matter into a ZIP archive.
+// The archive is written as the notes arrive, so it can be streamed.
+type zipMarkdownWriter struct {
+	archive *zip.Writer
+	names   map[string]bool // File names already in the archive, to keep names unique
+}
+
+// newZipMarkdownWriter creates a note writer for the zip-md export format.
+func newZipMarkdownWriter(w io.Writer) noteWriter {
+	return &zipMarkdownWriter{archive: zip.NewWriter(w), names: make(map[string]bool)}
+}
+
+// WriteNote adds the note to the archive as a Markdown file named after its title.
+func (z *zipMarkdownWriter) WriteNote(note *model.Note) error {
+	content, err := markdownNote(note)
+	if err != nil {
+		return err
+	}
+
+	file, err := z.archive.CreateHeader(&zip.FileHeader{
+		Name:     z.fileName(note),
+		Method:   zip.Deflate,
+		Modified: note.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+// Close writes the archive's central directory.
+func (z *zipMarkdownWriter) Close() error {
+	return z.archive.Close()
+}
+
+// fileName returns a unique file name for the note, derived from its title (or its ID,
+// if the title has no usable characters). Later notes with the same name get a numeric suffix.
+func (z *zipMarkdownWriter) fileName(note *model.Note) string {
+	base := slugify(note.Title)
+	if base == "" {
+		base = slugify(note.ID)
+	}
+	if base == "" {
+		base = "note"
+	}
+
+	name := base + ".md"
+	for i := 2; z.names[name]; i++ {
+		name = fmt.Sprintf("%s-%d.md", base, i)
+	}
+	z.names[name] = true
+	return name
+}
+
+// markdownNote renders a note as Markdown: its metadata as YAML front matter, followed by its content.
+func markdownNote(note *model.Note) ([]byte, error) {
+	frontMatter, err := yaml.Marshal(markdownFrontMatter{
+		ID:        note.ID,
+		Title:     note.Title,
+		Color:     note.Color,
+		Icon:      note.Icon,
+		Tags:      note.Tags,
+		CreatedAt: note.CreatedAt.UTC(),
+		UpdatedAt: note.UpdatedAt.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode front matter: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(frontMatter)
+	buf.WriteString("---\n\n")
+	buf.WriteString(note.Content)
+	if note.Content != "" && !strings.HasSuffix(note.Content, "\n") {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// slugify turns text into a file name that is safe on every platform: lowercase letters
+// and digits, with any other characters collapsed into single hyphens.
+func slugify(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			if b.Len() >= maxMarkdownNameLength {
+				break
+			}
+			continue
+		}
+		hyphen = true
+	}
+	return b.String()
+}
+
+// readMarkdownArchive calls fn for every Markdown file (.md) of a ZIP archive, such as an
+// export in the zip-md format or a zipped Obsidian vault or Notable directory, converted to a
+// note. Files in hidden directories (e.g., .obsidian) are skipped. It stops early if fn returns
+// false, and returns an error if the body is not a ZIP archive.
+func readMarkdownArchive(body io.Reader, fn func(index int, note *model.Note, err error) bool) error {
+	data, err := io.ReadAll(io.LimitReader(body, maxImportArchiveSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxImportArchiveSize {
+		return fmt.Errorf("archive is larger than %d MiB", maxImportArchiveSize>>20)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("expected a ZIP archive of Markdown files: %w", err)
+	}
+
+	index := 0
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".md") || hiddenPath(file.Name) {
+			continue
+		}
+		note, err := readMarkdownFile(file)
+		if !fn(index, note, err) {
+			return nil
+		}
+		index++
+	}
+	return nil
+}
+
+// hiddenPath reports whether a path in an archive is, or is in, a hidden file or directory.
+func hiddenPath(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") || elem == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// readMarkdownFile reads a Markdown file of an archive and converts it to a note.
+func readMarkdownFile(file *zip.File) (*model.Note, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxImportArchiveSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name, err)
+	}
+	if len(data) > maxImportArchiveSize {
+		return nil, fmt.Errorf("%s: file is larger than %d MiB", file.Name, maxImportArchiveSize>>20)
+	}
+	note, err := parseMarkdownNote(file.Name, data, file.Modified)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name, err)
+	}
+	return note, nil
+}
+
+// parseMarkdownNote converts a Markdown file with optional YAML front matter to a note.
+// The front matter provides the ID, title, and timestamps; without them, the ID is derived
+// from the file name, the title is the file name without the extension, and the timestamps
+// are the modification time of the file. The content is the rest of the file.
+func parseMarkdownNote(name string, data []byte, modified time.Time) (*model.Note, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	frontMatter := map[string]any{}
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		end := strings.Index(rest, "\n---\n")
+		closing := len("\n---\n")
+		if end < 0 && strings.HasSuffix(rest, "\n---") {
+			end, closing = len(rest)-len("\n---"), len("\n---")
+		}
+		if end < 0 {
+			return nil, errors.New("front matter is not closed with ---")
+		}
+		if err := yaml.Unmarshal([]byte(rest[:end]), &frontMatter); err != nil {
+			return nil, fmt.Errorf("invalid front matter: %w", err)
+		}
+		// Exported files have an empty line between the front matter and the content
+		text = strings.TrimPrefix(rest[end+closing:], "\n")
+	}
+
+	note := &model.Note{
+		ID:      frontMatterString(frontMatter, "id"),
+		Title:   frontMatterString(frontMatter, "title"),
+		Color:   frontMatterString(frontMatter, "color"),
+		Icon:    frontMatterString(frontMatter, "icon"),
+		Tags:    frontMatterTags(frontMatter),
+		Content: text,
+	}
+	if note.ID == "" {
+		sum := sha256.Sum256([]byte(name))
+		note.ID = "md-" + hex.EncodeToString(sum[:10])
+	}
+	if note.Title == "" {
+		note.Title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+
+	var err error
+	if note.CreatedAt, err = frontMatterTime(frontMatter, markdownCreatedKeys); err != nil {
+		return nil, err
+	}
+	if note.UpdatedAt, err = frontMatterTime(frontMatter, markdownUpdatedKeys); err != nil {
+		return nil, err
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = modified
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = modified
+	}
+	if note.UpdatedAt.Before(note.CreatedAt) {
+		note.UpdatedAt = note.CreatedAt
+	}
+	return note, nil
+}
+
+// frontMatterString returns the value of a front matter key as a string, or "" if it's missing.
+func frontMatterString(frontMatter map[string]any, key string) string {
+	value, ok := frontMatter[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// frontMatterTags returns the tags of the front matter, a list or a string of tags separated
+// by commas or spaces, as written by Obsidian and Notable; a leading # of a tag is dropped.
+func frontMatterTags(frontMatter map[string]any) []string {
+	var tags []string
+	switch value := frontMatter["tags"].(type) {
+	case nil:
+		return nil
+	case []any:
+		for _, tag := range value {
+			tags = append(tags, fmt.Sprint(tag))
+		}
+	default:
+		tags = strings.FieldsFunc(fmt.Sprint(value), func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	}
+
+	for i, tag := range tags {
+		tags[i] = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	}
+	return tags
+}
+
+// frontMatterTime returns the value of the first of the keys present in the front matter
+// as a time, or the zero time if none of them is.
+func frontMatterTime(frontMatter map[string]any, keys []string) (time.Time, error) {
+	for _, key := range keys {
+		switch value := frontMatter[key].(type) {
+		case nil:
+			continue
+		case time.Time:
+			return value, nil
+		case string:
+			for _, layout := range markdownTimeLayouts {
+				if t, err := time.Parse(layout, value); err == nil {
+					return t, nil
+				}
+			}
+			return time.Time{}, fmt.Errorf("invalid %s %q", key, value)
+		default:
+			return time.Time{}, fmt.Errorf("invalid %s %v", key, value)
+		}
+	}
+	return time.Time{}, nil
+}
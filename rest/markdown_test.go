@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// TestSlugify tests the conversion of titles to file names
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Shopping List", "shopping-list"},
+		{"  Ideas: 2024 / Q1!  ", "ideas-2024-q1"},
+		{"Café Übersicht", "café-übersicht"},
+		{"../../etc/passwd", "etc-passwd"},
+		{"!!!", ""},
+		{strings.Repeat("a", 100), strings.Repeat("a", maxMarkdownNameLength)},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.text); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestMarkdownNote tests the front matter and body of an exported note
+func TestMarkdownNote(t *testing.T) {
+	created := time.Date(2023, 4, 15, 12, 30, 45, 0, time.UTC)
+	note := &model.Note{ID: "abc", Title: "Title: with colon", Content: "Line 1\nLine 2", CreatedAt: created, UpdatedAt: created}
+
+	content, err := markdownNote(note)
+	if err != nil {
+		t.Fatalf("Failed to render note: %v", err)
+	}
+	want := "---\nid: abc\ntitle: 'Title: with colon'\ncreated_at: 2023-04-15T12:30:45Z\nupdated_at: 2023-04-15T12:30:45Z\n---\n\nLine 1\nLine 2\n"
+	if string(content) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, content)
+	}
+
+	// The color and icon are only written if set
+	note.Color, note.Icon = "#1e90ff", "star"
+	if content, err := markdownNote(note); err != nil || !strings.Contains(string(content), "color: '#1e90ff'\nicon: star\n") {
+		t.Errorf("Expected the color and icon in the front matter, got %s: %v", content, err)
+	}
+	note.Tags = []string{"home", "work"}
+	if content, err := markdownNote(note); err != nil || !strings.Contains(string(content), "tags:\n    - home\n    - work\n") {
+		t.Errorf("Expected the tags in the front matter, got %s: %v", content, err)
+	}
+}
+
+// TestExportNotesZipMarkdown tests the zip-md export format
+func TestExportNotesZipMarkdown(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	for _, note := range []*model.Note{
+		{ID: "1", Title: "Same Title", Content: "First"},
+		{ID: "2", Title: "Same Title", Content: "Second"},
+		{ID: "3", Title: "", Content: "No title"},
+	} {
+		if err := backend.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	w := exportRequest(t, backend, "?format=zip-md")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %q", ct)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasSuffix(disposition, `.zip"`) {
+		t.Errorf("Unexpected Content-Disposition: %q", disposition)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	contents := make(map[string]string)
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		contents[file.Name] = string(data)
+	}
+
+	// The in-memory storage returns notes in random order, so either note may get the suffix
+	if len(contents) != 3 {
+		t.Fatalf("Expected 3 files, got %v", contents)
+	}
+	for _, name := range []string{"same-title.md", "same-title-2.md", "3.md"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("Expected file %s in the archive, got %v", name, contents)
+		}
+	}
+	if !strings.HasSuffix(contents["3.md"], "---\n\nNo title\n") {
+		t.Errorf("Unexpected content of 3.md: %q", contents["3.md"])
+	}
+}
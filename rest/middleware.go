@@ -1,10 +1,20 @@
 package rest
 
 import (
+	"context"
 	"net/http"
-	"strings"
+	"time"
+
+	"golang-simple-notes/inflight"
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ValidateNoteIDMiddleware is a middleware that validates the note ID in the request URL
@@ -25,38 +35,95 @@ func ValidateNoteIDMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// isValidNoteID checks if a note ID is valid
+// isValidNoteID checks if a note ID is valid (see model.ValidateID), the same way the
+// note service checks the IDs of imported notes
 func isValidNoteID(id string) bool {
-	// ID should not be empty
-	if id == "" {
-		return false
-	}
+	return model.ValidateID(id) == nil
+}
 
-	// ID should not contain spaces
-	if strings.Contains(id, " ") {
-		return false
-	}
+// RequestIDMiddleware assigns a request ID to every request.
+// A valid X-Request-ID header supplied by the client is reused; otherwise a new ID
+// is generated. The ID is stored in the request context (so storage and logging code
+// can include it), registered with chi's request ID key (so middleware.Logger prints it),
+// and returned to the client in the X-Request-ID response header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.IsValid(id) {
+			id = requestid.New()
+		}
 
-	// ID should not be too long (e.g., max 255 characters)
-	if len(id) > 255 {
-		return false
-	}
+		ctx := requestid.NewContext(r.Context(), id)
+		ctx = context.WithValue(ctx, middleware.RequestIDKey, id)
+
+		// Record the ID on the request span (if tracing is enabled) to link logs and traces
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-	// ID should only contain alphanumeric characters, hyphens, and underscores
-	for _, c := range id {
-		if !isValidIDChar(c) {
-			return false
+// TracingMiddleware starts an OpenTelemetry server span for every request,
+// continuing the trace context sent by the client in the W3C traceparent header.
+// Once the request has been routed, the span is renamed after the matched route pattern
+// (e.g., "GET /api/notes/{id}"), so span names don't contain note IDs.
+func TracingMiddleware(next http.Handler) http.Handler {
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(attribute.String("http.route", pattern))
+			}
 		}
-	}
+	})
 
-	return true
+	return otelhttp.NewHandler(routed, "rest",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
 }
 
-// isValidIDChar checks if a character is valid in a note ID
-func isValidIDChar(c rune) bool {
-	return (c >= 'a' && c <= 'z') ||
-		(c >= 'A' && c <= 'Z') ||
-		(c >= '0' && c <= '9') ||
-		c == '-' ||
-		c == '_'
+// MetricsMiddleware records the rate, errors, and duration of every request by method,
+// route pattern, and status code (see metrics.ObserveHTTPRequest). Route patterns keep the
+// number of series bounded; requests that match no route are recorded as "unmatched".
+// It must come after TracingMiddleware, so the trace ID of a request becomes its exemplar.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		code := ww.Status()
+		if code == 0 {
+			// Nothing was written, so the server answers 200 OK
+			code = http.StatusOK
+		}
+		metrics.ObserveHTTPRequest(r.Context(), r.Method, route, code, time.Since(start))
+	})
+}
+
+// InFlightMiddleware counts the requests being handled in the tracker, so a graceful
+// shutdown can report how many it waits for. WebSocket connections are not counted,
+// since they stay open until the server ends them.
+func InFlightMiddleware(tracker *inflight.Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" || !tracker.Add() {
+				// The server stops accepting requests on its own while shutting down
+				next.ServeHTTP(w, r)
+				return
+			}
+			defer tracker.Done()
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang-simple-notes/inflight"
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/requestid"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestRequestIDMiddleware tests generation, reuse, and propagation of request IDs
+func TestRequestIDMiddleware(t *testing.T) {
+	var seenID, seenChiID string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = requestid.FromContext(r.Context())
+		seenChiID = middleware.GetReqID(r.Context())
+	}))
+
+	t.Run("Generated", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if seenID == "" {
+			t.Fatal("Expected a request ID in the context")
+		}
+		if seenChiID != seenID {
+			t.Errorf("Expected chi request ID %q, got %q", seenID, seenChiID)
+		}
+		if got := w.Header().Get(requestid.Header); got != seenID {
+			t.Errorf("Expected response header %q, got %q", seenID, got)
+		}
+	})
+
+	t.Run("Accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		req.Header.Set(requestid.Header, "client-id-42")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if seenID != "client-id-42" {
+			t.Errorf("Expected client request ID to be reused, got %q", seenID)
+		}
+		if got := w.Header().Get(requestid.Header); got != "client-id-42" {
+			t.Errorf("Expected response header 'client-id-42', got %q", got)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		req.Header.Set(requestid.Header, "bad id with spaces")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if seenID == "bad id with spaces" || seenID == "" {
+			t.Errorf("Expected invalid client ID to be replaced, got %q", seenID)
+		}
+	})
+}
+
+// TestTracingMiddleware tests that request spans are named after the route and join the client's trace
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	r := chi.NewRouter()
+	r.Use(TracingMiddleware)
+	r.Use(RequestIDMiddleware)
+	r.Get("/api/notes/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/api/notes/note-1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(requestid.Header, "req-123")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/notes/{id}" {
+		t.Errorf("Expected span name 'GET /api/notes/{id}', got %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected span to join the client's trace, got trace ID %s", got)
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["http.route"] != "/api/notes/{id}" {
+		t.Errorf("Expected http.route attribute, got %v", attrs)
+	}
+	if attrs["request.id"] != "req-123" {
+		t.Errorf("Expected request.id attribute 'req-123', got %q", attrs["request.id"])
+	}
+}
+
+// TestMetricsMiddleware tests that requests are counted by method, route pattern, and status code
+func TestMetricsMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.Get("/test-metrics/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "missing" {
+			http.NotFound(w, r)
+		}
+	})
+
+	for _, path := range []string{"/test-metrics/a", "/test-metrics/b", "/test-metrics/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/test-metrics/{id}", "200")); got != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/test-metrics/{id}", "404")); got != 1 {
+		t.Errorf("Expected 1 failed request, got %v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.HTTPRequestDuration, "notes_http_request_duration_seconds"); got == 0 {
+		t.Error("Expected request durations to be recorded")
+	}
+}
+
+// TestInFlightMiddleware tests that requests are counted while they are handled, except WebSocket connections
+func TestInFlightMiddleware(t *testing.T) {
+	var tracker inflight.Tracker
+	var during int
+	handler := InFlightMiddleware(&tracker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = tracker.Count()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/notes", nil))
+	if during != 1 || tracker.Count() != 0 {
+		t.Errorf("Expected 1 request in flight while handled and none after, got %d and %d", during, tracker.Count())
+	}
+
+	req := httptest.NewRequest("GET", "/api/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if during != 0 {
+		t.Errorf("Expected WebSocket connections not to be counted, got %d", during)
+	}
+}
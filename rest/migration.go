@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"golang-simple-notes/storage"
+)
+
+// WithVerifier enables the dual-write divergence report endpoint, backed by the given verifier.
+func WithVerifier(verifier *storage.Verifier) HandlerOption {
+	return func(h *Handler) {
+		h.verifier = verifier
+	}
+}
+
+// WithReplication enables the reconciliation endpoint of asynchronous dual-write, backed by the given storage.
+func WithReplication(replicated *storage.ReplicatedStorage) HandlerOption {
+	return func(h *Handler) {
+		h.replicated = replicated
+	}
+}
+
+// getDivergences handles GET /api/migration/divergences.
+// It returns the dual-write verification counters and the most recent divergences
+// between the primary and secondary backends as JSON.
+func (h *Handler) getDivergences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.verifier.Report()); err != nil {
+		http.Error(w, "Failed to encode divergence report", http.StatusInternalServerError)
+		return
+	}
+}
+
+// reconcile handles POST /api/migration/reconcile.
+// It runs a reconciliation pass of asynchronous dual-write right away, without waiting
+// for the next scheduled one, and returns the number of notes it repaired as JSON.
+func (h *Handler) reconcile(w http.ResponseWriter, r *http.Request) {
+	result, err := h.replicated.Reconcile(r.Context())
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		http.Error(w, "Reconciliation failed", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode reconciliation result", http.StatusInternalServerError)
+		return
+	}
+}
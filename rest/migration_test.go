@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestGetDivergences tests the dual-write divergence report endpoint
+func TestGetDivergences(t *testing.T) {
+	primary := storage.NewInMemoryStorage()
+	secondary := storage.NewInMemoryStorage()
+	verifier := storage.NewVerifier(primary, secondary, 10, 0)
+	defer verifier.Stop()
+
+	// A note that only exists on the primary is reported as a divergence
+	ctx := context.Background()
+	if err := primary.Create(ctx, &model.Note{ID: "note-1", Title: "Title"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	verifier.Enqueue(ctx, "Create", "note-1")
+
+	r := chi.NewRouter()
+	NewHandler(primary, WithVerifier(verifier)).RegisterRoutes(r)
+
+	var report storage.DivergenceReport
+	deadline := time.Now().Add(2 * time.Second)
+	for report.Mismatched == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the divergence, last report: %+v", report)
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		req := httptest.NewRequest("GET", "/api/migration/divergences", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+
+	if len(report.Divergences) != 1 || report.Divergences[0].NoteID != "note-1" {
+		t.Errorf("Unexpected divergences: %+v", report.Divergences)
+	}
+}
+
+// TestGetDivergences_Disabled tests that the endpoint is not registered without a verifier
+func TestGetDivergences_Disabled(t *testing.T) {
+	r := chi.NewRouter()
+	NewHandler(fake.New()).RegisterRoutes(r)
+
+	req := httptest.NewRequest("GET", "/api/migration/divergences", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestReconcile tests the asynchronous dual-write reconciliation endpoint
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := storage.NewInMemoryStorage(), storage.NewInMemoryStorage()
+	if err := primary.Create(ctx, &model.Note{ID: "note-1", Title: "Title"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	replicated := storage.NewReplicatedStorage(primary, secondary, 10, 0)
+	defer func() { _ = replicated.Close(ctx) }()
+
+	r := chi.NewRouter()
+	NewHandler(replicated, WithReplication(replicated)).RegisterRoutes(r)
+
+	req := httptest.NewRequest("POST", "/api/migration/reconcile", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var result storage.ReconcileResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result != (storage.ReconcileResult{Created: 1}) {
+		t.Errorf("Unexpected reconciliation result: %+v", result)
+	}
+	if _, err := secondary.Get(ctx, "note-1"); err != nil {
+		t.Errorf("Expected the note on the secondary: %v", err)
+	}
+}
+
+// TestReconcile_Disabled tests that the endpoint is not registered without asynchronous dual-write
+func TestReconcile_Disabled(t *testing.T) {
+	r := chi.NewRouter()
+	NewHandler(fake.New(), WithReplication(nil)).RegisterRoutes(r)
+
+	req := httptest.NewRequest("POST", "/api/migration/reconcile", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected the endpoint not to be registered, got status code %d", w.Code)
+	}
+}
@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"golang-simple-notes/service"
+)
+
+// quotaProblemType identifies quota errors in problem details (RFC 9457).
+const quotaProblemType = "https://github.com/starichkov/golang-simple-notes/blob/main/API.md#quotas"
+
+// problem is a problem details object (RFC 9457), with the extension members of quota errors.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Limit     string `json:"limit,omitempty"`     // Exceeded limit: "notes" or "bytes"
+	Max       int64  `json:"max,omitempty"`       // Value of the exceeded limit
+	Used      int64  `json:"used"`                // Usage before the rejected write
+	Requested int64  `json:"requested,omitempty"` // Amount the rejected write would have added
+}
+
+// writeProblem writes a problem details object with the application/problem+json media type.
+func writeProblem(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// quotaExceeded responds with a problem details object if a write was rejected because
+// the owner of the note would exceed its quota: 403 Forbidden for the number of notes,
+// and 413 Content Too Large for their total size.
+//
+// Returns:
+//   - true if a response has been written, false if err is a different error
+func quotaExceeded(w http.ResponseWriter, err error) bool {
+	var quotaErr *service.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	p := problem{
+		Type:      quotaProblemType,
+		Title:     "Note quota exceeded",
+		Status:    http.StatusForbidden,
+		Detail:    quotaErr.Error(),
+		Limit:     quotaErr.Limit,
+		Max:       quotaErr.Max,
+		Used:      quotaErr.Used,
+		Requested: quotaErr.Requested,
+	}
+	if quotaErr.Limit == service.QuotaBytes {
+		p.Title = "Storage quota exceeded"
+		p.Status = http.StatusRequestEntityTooLarge
+	}
+	writeProblem(w, p)
+	return true
+}
+
+// WithQuotas enables the GET /api/quota endpoint, reporting the usage of the client's quota.
+func WithQuotas(quotas *service.Quotas) HandlerOption {
+	return func(h *Handler) {
+		h.quotas = quotas
+	}
+}
+
+// quotaResponse is the body of GET /api/quota.
+type quotaResponse struct {
+	service.QuotaUsage
+	MaxNotes int   `json:"max_notes,omitempty"` // Maximum number of notes, if limited
+	MaxBytes int64 `json:"max_bytes,omitempty"` // Maximum total size of the notes in bytes, if limited
+}
+
+// getQuota handles GET /api/quota.
+// It returns the number and total size of the notes of the client's owner (see
+// OwnerMiddleware), with the limits that apply to them.
+func (h *Handler) getQuota(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.quotas.Usage(r.Context(), service.OwnerFromContext(r.Context()))
+	if err != nil {
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to get quota usage", http.StatusInternalServerError)
+		return
+	}
+
+	limits := h.quotas.Limits()
+	body := quotaResponse{QuotaUsage: usage, MaxNotes: limits.MaxNotes, MaxBytes: limits.MaxBytes}
+	if err := writeJSON(w, http.StatusOK, body); err != nil {
+		http.Error(w, "Failed to encode quota usage", http.StatusInternalServerError)
+		return
+	}
+}
+
+// OwnerMiddleware identifies the owner of every request by an API key in a header (e.g.,
+// X-API-Key), so the notes it creates count against the quota of that owner. Only the
+// given keys are accepted, and requests with any other value are rejected with 401
+// Unauthorized, so clients can't get a fresh quota by sending a new value. The owner is a
+// hash of the key, so keys never end up in the storage or in responses. Requests without
+// the header share the quota of anonymous clients.
+//
+// Parameters:
+//   - header: The name of the header identifying the owner
+//   - keys: The accepted API keys; without any, every request is anonymous
+//
+// Returns:
+//   - The middleware
+func OwnerMiddleware(header string, keys []string) func(http.Handler) http.Handler {
+	// Keys are looked up by their hash, so the lookup takes no longer for a near match
+	owners := make(map[[sha256.Size]byte]string, len(keys))
+	for _, key := range keys {
+		sum := sha256.Sum256([]byte(key))
+		owners[sum] = hex.EncodeToString(sum[:8])
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if value := r.Header.Get(header); value != "" {
+				owner, ok := owners[sha256.Sum256([]byte(value))]
+				if !ok {
+					http.Error(w, "Unknown API key", http.StatusUnauthorized)
+					return
+				}
+				r = r.WithContext(service.ContextWithOwner(r.Context(), owner))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// TestQuotas tests that notes beyond the quota of their owner are rejected with problem
+// details, and that GET /api/quota reports the usage of the client's owner
+func TestQuotas(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	quotas := service.NewQuotas(storage.NewInMemoryStorage(), service.QuotaLimits{MaxNotes: 1, MaxBytes: 10})
+	notes := service.New(backend, service.WithQuotas(quotas))
+	r := chi.NewRouter()
+	r.Use(OwnerMiddleware("X-API-Key", []string{"alice", "bob"}))
+	NewHandler(backend, WithNoteService(notes), WithQuotas(quotas)).RegisterRoutes(r)
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) problem {
+		t.Helper()
+		if w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Expected problem details, got %q", w.Header().Get("Content-Type"))
+		}
+		var p problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+		}
+		return p
+	}
+
+	if w := send("POST", "/api/notes", "alice", `{"title":"One","content":"1"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := send("POST", "/api/notes", "alice", `{"title":"Two"}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 once the note quota is used, got %d", w.Code)
+	}
+	if p := decode(w); p.Type != quotaProblemType || p.Limit != service.QuotaNotes || p.Max != 1 || p.Used != 1 || p.Requested != 1 {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	w = send("POST", "/api/notes", "bob", `{"title":"Far too long"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 beyond the size quota, got %d", w.Code)
+	}
+	if p := decode(w); p.Limit != service.QuotaBytes || p.Max != 10 || p.Used != 0 || p.Requested != 12 {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	// Anonymous clients share a quota of their own
+	if w := send("POST", "/api/notes", "", `{"title":"Anonymous"}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for an anonymous client, got %d", w.Code)
+	}
+
+	w = send("GET", "/api/quota", "alice", "")
+	var body quotaResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+	}
+	want := quotaResponse{QuotaUsage: service.QuotaUsage{Notes: 1, Bytes: 4}, MaxNotes: 1, MaxBytes: 10}
+	if w.Code != http.StatusOK || body != want {
+		t.Errorf("Expected %+v, got %d %+v", want, w.Code, body)
+	}
+}
+
+// TestOwnerMiddleware tests that the owner is a hash of an accepted key, never the key
+// itself, and that other keys are rejected
+func TestOwnerMiddleware(t *testing.T) {
+	var owners []string
+	handler := OwnerMiddleware("X-API-Key", []string{"secret", "other"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owners = append(owners, service.OwnerFromContext(r.Context()))
+	}))
+	for _, key := range []string{"secret", "secret", "other", ""} {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(owners[0]) != 16 || strings.Contains(owners[0], "secret") {
+		t.Errorf("Expected a 16-digit hash, got %q", owners[0])
+	}
+	if owners[0] != owners[1] || owners[0] == owners[2] {
+		t.Errorf("Expected the same key to map to the same owner only, got %q", owners)
+	}
+	if owners[3] != "" {
+		t.Errorf("Expected no owner without the header, got %q", owners[3])
+	}
+
+	// Unknown keys would give clients a fresh quota for every value
+	owners = nil
+	req := httptest.NewRequest("GET", "/api/notes", nil)
+	req.Header.Set("X-API-Key", "made-up")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || len(owners) != 0 {
+		t.Errorf("Expected an unknown key to be rejected, got %d (owners: %q)", w.Code, owners)
+	}
+}
@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateLimiterIdleTimeout is how long the limiter of a client that sent no requests is kept
+	rateLimiterIdleTimeout = 10 * time.Minute
+
+	// rateLimiterSweepInterval is how often idle limiters are removed
+	rateLimiterSweepInterval = time.Minute
+)
+
+// clientLimiter is the token bucket of a single client.
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter limits the request rate of each client IP with a token bucket.
+type rateLimiter struct {
+	settings  *Settings
+	mutex     sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+// RateLimitMiddleware limits the number of /api requests each client IP may send,
+// answering 429 Too Many Requests with a Retry-After header once the limit is exceeded.
+// The limit is read from the settings for every request, so it can be changed at runtime;
+// a rate of zero disables limiting. Health probes and metrics are never limited.
+//
+// Parameters:
+//   - settings: The settings holding the current rate limit and burst size
+//
+// Returns:
+//   - The middleware
+func RateLimitMiddleware(settings *Settings) func(http.Handler) http.Handler {
+	limiter := &rateLimiter{
+		settings: settings,
+		clients:  make(map[string]*clientLimiter),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current := settings.Load()
+			if current.RateLimit <= 0 || !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if delay, ok := limiter.allow(clientIP(r), current, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allow takes a token from the bucket of the client, creating the bucket if needed.
+//
+// Returns:
+//   - How long the client should wait before retrying, if the request is not allowed
+//   - Whether the request is allowed
+func (l *rateLimiter) allow(client string, settings *RuntimeSettings, now time.Time) (time.Duration, bool) {
+	limit, burst := rate.Limit(settings.RateLimit), max(settings.RateLimitBurst, 1)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(limit, burst)}
+		l.clients[client] = c
+	} else if c.limiter.Limit() != limit || c.limiter.Burst() != burst {
+		// The settings were reloaded: keep the client's tokens, but apply the new limit
+		c.limiter.SetLimitAt(now, limit)
+		c.limiter.SetBurstAt(now, burst)
+	}
+	c.lastSeen = now
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Don't consume a token for a rejected request
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// sweep removes the limiters of clients that have been idle for a while.
+// The caller must hold the mutex.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, c := range l.clients {
+		if now.Sub(c.lastSeen) >= rateLimiterIdleTimeout {
+			delete(l.clients, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientIP returns the IP address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRateLimitMiddleware tests per-client limiting and runtime changes of the limit
+func TestRateLimitMiddleware(t *testing.T) {
+	settings := NewSettings(RuntimeSettings{RateLimit: 1, RateLimitBurst: 2})
+	handler := RateLimitMiddleware(settings)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The burst is allowed, then the client is limited
+	for i := range 2 {
+		if w := send("/api/notes", "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i, w.Code)
+		}
+	}
+	w := send("/api/notes", "10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// Other clients and non-API routes are not affected
+	if w := send("/api/notes", "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", w.Code)
+	}
+	if w := send("/health/ready", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected health probes not to be limited, got %d", w.Code)
+	}
+
+	// Disabling the limit takes effect immediately
+	settings.Store(RuntimeSettings{})
+	if w := send("/api/notes", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected no limit after disabling it, got %d", w.Code)
+	}
+}
+
+// TestRateLimiter_Reload tests that changed limits apply to existing clients
+func TestRateLimiter_Reload(t *testing.T) {
+	limiter := &rateLimiter{clients: make(map[string]*clientLimiter)}
+	now := time.Now()
+
+	strict := &RuntimeSettings{RateLimit: 0.1, RateLimitBurst: 1}
+	if _, ok := limiter.allow("client", strict, now); !ok {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	if _, ok := limiter.allow("client", strict, now); ok {
+		t.Fatal("Expected the second request to be limited")
+	}
+
+	// After a reload, the bucket refills at the new rate
+	relaxed := &RuntimeSettings{RateLimit: 100, RateLimitBurst: 1}
+	delay, ok := limiter.allow("client", relaxed, now.Add(time.Millisecond))
+	if ok || delay > 10*time.Millisecond {
+		t.Fatalf("Expected the request to be limited for at most 10ms, got %v", delay)
+	}
+	if _, ok := limiter.allow("client", relaxed, now.Add(20*time.Millisecond)); !ok {
+		t.Error("Expected the request to be allowed with the reloaded limit")
+	}
+}
+
+// TestRateLimiter_Sweep tests that idle clients are forgotten
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter := &rateLimiter{clients: make(map[string]*clientLimiter)}
+	settings := &RuntimeSettings{RateLimit: 1, RateLimitBurst: 1}
+	now := time.Now()
+
+	limiter.allow("idle", settings, now)
+	limiter.allow("active", settings, now.Add(rateLimiterIdleTimeout))
+	if _, ok := limiter.clients["idle"]; ok {
+		t.Error("Expected the idle client to be removed")
+	}
+	if _, ok := limiter.clients["active"]; !ok {
+		t.Error("Expected the active client to be kept")
+	}
+}
@@ -0,0 +1,257 @@
+package rest
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/metrics"
+)
+
+// maxCachedResponseBytes is the size of the largest response body ResponseCache stores;
+// larger responses (e.g., unpaginated lists of many notes) are served uncached.
+const maxCachedResponseBytes = 1 << 20
+
+// cachedPaths are the paths whose GET responses ResponseCache stores: the endpoints that
+// list, count, and search notes.
+var cachedPaths = map[string]bool{
+	"/api/notes":        true,
+	"/api/notes/":       true,
+	"/api/notes/count":  true,
+	"/api/notes/recent": true,
+	"/api/notes/search": true,
+}
+
+// ResponseCache caches the rendered responses of the list, count, and search endpoints,
+// keyed by their path and query parameters, so clients polling the same lists (e.g.,
+// dashboards) are served from memory. It holds at most size responses, evicting the least
+// recently used one when full, and serves them for ttl at most.
+//
+// Every cached response is dropped on any write: when a note event is published (it
+// implements events.Subscriber, so writes of other instances reported by a change feed
+// count too), and when a request other than GET, HEAD, or OPTIONS goes through
+// Middleware. A response rendered while a write happened isn't stored, since it may
+// predate the write. The views of the notes aren't writes, so the views in cached lists
+// may be up to ttl old. It is safe for concurrent use.
+type ResponseCache struct {
+	size int           // Maximum number of responses
+	ttl  time.Duration // How long responses are served after they were stored
+
+	mutex      sync.Mutex
+	entries    map[string]*list.Element // Responses by key
+	order      *list.List               // Responses from most to least recently used
+	generation uint64                   // Number of invalidations, to detect writes during a request
+
+	now func() time.Time // Clock, replaced in tests
+}
+
+// cachedResponse is a response stored by ResponseCache.
+type cachedResponse struct {
+	key     string
+	header  http.Header // Headers set by the handler
+	body    []byte
+	expires time.Time
+}
+
+// NewResponseCache creates an empty response cache.
+//
+// Parameters:
+//   - size: The maximum number of responses (at least 1)
+//   - ttl: How long responses are served after they were stored
+//
+// Returns:
+//   - A pointer to a new ResponseCache instance
+func NewResponseCache(size int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		size:    max(size, 1),
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Notify drops every cached response when a note is created, updated, or deleted.
+func (c *ResponseCache) Notify(ctx context.Context, event events.Event) {
+	c.Invalidate()
+}
+
+// Invalidate drops every cached response.
+func (c *ResponseCache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.entries)
+	c.order.Init()
+	c.generation++
+}
+
+// Middleware serves the GET requests of the cached paths from the cache, and stores their
+// successful responses, with an X-Cache header telling whether the response was a HIT or
+// a MISS, and a Vary header listing the request headers that are part of the cache key.
+// Other requests are passed on, and invalidate the cache unless they are reads.
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		default:
+			next.ServeHTTP(w, r)
+			c.Invalidate()
+			return
+		}
+		if !cachedPaths[r.URL.Path] || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The query parameters are encoded sorted by name, so their order doesn't matter.
+		// The time zone of the timestamps and the representation may be requested with
+		// headers as well.
+		key := r.URL.Path + "?" + r.URL.Query().Encode() + "#" + r.Header.Get(TimezoneHeader)
+		if jsonAPI, _ := acceptsJSONAPI(r); jsonAPI {
+			key += "#" + JSONAPIMediaType
+		}
+		// Shared caches in front of the service must keep the same variants apart
+		addVary(w.Header(), "Accept", TimezoneHeader)
+		entry, generation := c.get(key)
+		if entry != nil {
+			metrics.HTTPCacheRequests.WithLabelValues("hit").Inc()
+			for name, values := range entry.header {
+				w.Header()[name] = slices.Clone(values)
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(entry.body)
+			return
+		}
+		metrics.HTTPCacheRequests.WithLabelValues("miss").Inc()
+
+		w.Header().Set("X-Cache", "MISS")
+		recorder := &responseRecorder{ResponseWriter: w, before: w.Header().Clone()}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == http.StatusOK && !recorder.overflow {
+			c.set(key, generation, recorder.header, recorder.body.Bytes())
+		}
+	})
+}
+
+// addVary adds request header names to the Vary header of a response, unless it already
+// lists them.
+func addVary(header http.Header, names ...string) {
+	for _, name := range names {
+		listed := slices.ContainsFunc(header.Values("Vary"), func(value string) bool {
+			return slices.ContainsFunc(strings.Split(value, ","), func(field string) bool {
+				return strings.EqualFold(strings.TrimSpace(field), name)
+			})
+		})
+		if !listed {
+			header.Add("Vary", name)
+		}
+	}
+}
+
+// get returns the unexpired response stored under a key, if any, and the generation of
+// the cache, which set checks to store the response of a miss.
+func (c *ResponseCache) get(key string) (*cachedResponse, uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation
+	}
+	entry := element.Value.(*cachedResponse)
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		c.order.Remove(element)
+		return nil, c.generation
+	}
+	c.order.MoveToFront(element)
+	return entry, c.generation
+}
+
+// set stores a response under a key, unless the cache was invalidated since the
+// generation, evicting the least recently used response if the cache is full.
+func (c *ResponseCache) set(key string, generation uint64, header http.Header, body []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation != generation {
+		return
+	}
+
+	entry := &cachedResponse{key: key, header: header, body: slices.Clone(body), expires: c.now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+		c.order.Remove(oldest)
+	}
+}
+
+// responseRecorder passes a response on while keeping a copy of its status, the headers
+// set by the handler, and its body, up to maxCachedResponseBytes.
+type responseRecorder struct {
+	http.ResponseWriter
+	before   http.Header // Headers set before the handler ran (e.g., X-Request-ID), which aren't stored
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool // Whether the body is too large to be stored
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.header = make(http.Header)
+		for name, values := range r.Header() {
+			if name != "X-Cache" && !slices.Equal(values, r.before[name]) {
+				r.header[name] = slices.Clone(values)
+			}
+		}
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(p) > maxCachedResponseBytes {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController (e.g., the
+// write deadlines of streamed lists).
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush sends the buffered response, for streamed lists.
+func (r *responseRecorder) Flush() {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+)
+
+// TestResponseCache tests serving repeated list requests from the cache, and dropping
+// cached responses on writes, note events, expiry, and eviction
+func TestResponseCache(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	var during func()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if during != nil {
+			during()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", "1")
+		addVary(w.Header(), "Accept")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "call %d", calls)
+	})
+	send := func(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-ID", "req")
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	// expect sends a GET request, and checks whether it was a cache hit
+	expect := func(t *testing.T, handler http.Handler, target, result string) {
+		t.Helper()
+		before := calls
+		w := send(handler, "GET", target)
+		if got := w.Header().Get("X-Cache"); got != result {
+			t.Errorf("%s: expected X-Cache %s, got %q", target, result, got)
+		}
+		if hit := calls == before; hit != (result == "HIT") {
+			t.Errorf("%s: expected the handler to be called on a miss only", target)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("X-Total-Count") != "1" {
+			t.Errorf("%s: expected the headers of the handler, got %v", target, w.Header())
+		}
+		// Shared caches must tell the representations and time zones apart on hits too
+		if vary := w.Header().Values("Vary"); w.Code == http.StatusOK && !slices.Equal(vary, []string{"Accept", TimezoneHeader}) {
+			t.Errorf("%s: expected the response to vary by Accept and %s, got %q", target, TimezoneHeader, vary)
+		}
+	}
+	reset := func() {
+		calls, status, during = 0, http.StatusOK, nil
+	}
+
+	t.Run("Hits", func(t *testing.T) {
+		reset()
+		cache := NewResponseCache(10, time.Minute)
+		handler := cache.Middleware(next)
+		expect(t, handler, "/api/notes?limit=5&sort=title", "MISS")
+		expect(t, handler, "/api/notes?limit=5&sort=title", "HIT")
+		expect(t, handler, "/api/notes?sort=title&limit=5", "HIT")
+		expect(t, handler, "/api/notes?limit=6&sort=title", "MISS")
+		expect(t, handler, "/api/notes/count", "MISS")
+
+		// The time zone may be requested with a header, which is part of the key
+		req := httptest.NewRequest("GET", "/api/notes?limit=5&sort=title", nil)
+		req.Header.Set(TimezoneHeader, "Europe/Berlin")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if calls != 4 {
+			t.Errorf("Expected a response in another time zone not to be served from the cache")
+		}
+		req = httptest.NewRequest("GET", "/api/notes?limit=5&sort=title", nil)
+		req.Header.Set("Accept", JSONAPIMediaType)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if calls != 5 {
+			t.Errorf("Expected a JSON:API response not to be served from the cache")
+		}
+
+		w := send(handler, "GET", "/api/notes/count")
+		if w.Body.String() != "call 3" || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected the cached response, got %q with headers %v", w.Body.String(), w.Header())
+		}
+		if len(w.Header()["X-Request-Id"]) != 1 {
+			t.Errorf("Expected the request ID of the request only, got %v", w.Header()["X-Request-Id"])
+		}
+	})
+
+	t.Run("Uncached", func(t *testing.T) {
+		reset()
+		handler := NewResponseCache(10, time.Minute).Middleware(next)
+		for _, target := range []string{"/api/notes/42", "/api/stats", "/health/ready"} {
+			before := calls
+			send(handler, "GET", target)
+			if w := send(handler, "GET", target); w.Header().Get("X-Cache") != "" || calls != before+2 {
+				t.Errorf("Expected %s not to be cached", target)
+			}
+		}
+
+		status = http.StatusBadRequest
+		expect(t, handler, "/api/notes?limit=0", "MISS")
+		expect(t, handler, "/api/notes?limit=0", "MISS")
+	})
+
+	t.Run("Writes", func(t *testing.T) {
+		reset()
+		cache := NewResponseCache(10, time.Minute)
+		handler := cache.Middleware(next)
+		expect(t, handler, "/api/notes", "MISS")
+		send(handler, "HEAD", "/api/notes")
+		send(handler, "OPTIONS", "/api/notes")
+		expect(t, handler, "/api/notes", "HIT")
+
+		send(handler, "POST", "/api/notes")
+		expect(t, handler, "/api/notes", "MISS")
+		expect(t, handler, "/api/notes", "HIT")
+
+		// A note event, e.g., a change of another instance
+		cache.Notify(context.Background(), events.Event{Type: events.NoteDeleted, NoteID: "42"})
+		expect(t, handler, "/api/notes", "MISS")
+
+		// A response rendered during a write may predate it, so it isn't stored
+		cache.Invalidate()
+		during = cache.Invalidate
+		expect(t, handler, "/api/notes/search?q=go", "MISS")
+		during = nil
+		expect(t, handler, "/api/notes/search?q=go", "MISS")
+		expect(t, handler, "/api/notes/search?q=go", "HIT")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		reset()
+		now := time.Now()
+		cache := NewResponseCache(10, time.Minute)
+		cache.now = func() time.Time { return now }
+		handler := cache.Middleware(next)
+		expect(t, handler, "/api/notes/recent", "MISS")
+		now = now.Add(59 * time.Second)
+		expect(t, handler, "/api/notes/recent", "HIT")
+		now = now.Add(time.Second)
+		expect(t, handler, "/api/notes/recent", "MISS")
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		reset()
+		handler := NewResponseCache(2, time.Minute).Middleware(next)
+		expect(t, handler, "/api/notes?offset=1", "MISS")
+		expect(t, handler, "/api/notes?offset=2", "MISS")
+		expect(t, handler, "/api/notes?offset=1", "HIT")
+		// The least recently used response is evicted
+		expect(t, handler, "/api/notes?offset=3", "MISS")
+		expect(t, handler, "/api/notes?offset=1", "HIT")
+		expect(t, handler, "/api/notes?offset=2", "MISS")
+	})
+
+	t.Run("LargeResponses", func(t *testing.T) {
+		reset()
+		large := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte(strings.Repeat("x", maxCachedResponseBytes/2+1)))
+			_, _ = w.Write([]byte(strings.Repeat("x", maxCachedResponseBytes/2+1)))
+		})
+		handler := NewResponseCache(10, time.Minute).Middleware(large)
+		for range 2 {
+			if w := send(handler, "GET", "/api/notes"); w.Body.Len() != maxCachedResponseBytes+2 {
+				t.Fatalf("Expected the whole response, got %d bytes", w.Body.Len())
+			}
+		}
+		if calls != 2 {
+			t.Errorf("Expected responses over %d bytes not to be cached, got %d calls", maxCachedResponseBytes, calls)
+		}
+	})
+}
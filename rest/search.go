@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
+)
+
+// defaultSearchLimit is the number of results of a search without ?limit=.
+const defaultSearchLimit = 20
+
+// WithSearch enables GET /api/notes/search, backed by the given service, and, for semantic
+// searches with an admin token and a job runner, POST /api/admin/search/reindex.
+func WithSearch(search service.Search) HandlerOption {
+	return func(h *Handler) {
+		h.search = search
+	}
+}
+
+// Highlight formats of search results (?highlight=).
+const (
+	highlightOffsets = "offsets" // Plain fragments, with the offsets of the matches (the default)
+	highlightHTML    = "html"    // Fragments escaped for HTML, with the matches in <em> tags
+	highlightNone    = "none"    // No highlights
+)
+
+// searchNotes handles GET /api/notes/search?q=&mode=&limit=&highlight=.
+// It returns the notes matching the query ?q= as a JSON array of results, each with the
+// note, for semantic searches its similarity score, and the fragments of the note where
+// the query matches: with ?mode=text (the default), the notes containing the query, most
+// recently updated first; with ?mode=semantic, the notes nearest to the query by meaning,
+// most similar first. At most ?limit= results are returned (20 by default). The fragments
+// come with the offsets of the matches (?highlight=offsets, the default), escaped for HTML
+// with the matches in <em> tags (?highlight=html), or are left out (?highlight=none).
+// It returns a 400 Bad Request for an invalid search, or a 502 Bad Gateway if the query
+// cannot be embedded.
+func (h *Handler) searchNotes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultSearchLimit
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be a number between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	format := query.Get("highlight")
+	switch format {
+	case "":
+		format = highlightOffsets
+	case highlightOffsets, highlightHTML, highlightNone:
+	default:
+		http.Error(w, fmt.Sprintf("highlight must be %s, %s, or %s", highlightOffsets, highlightHTML, highlightNone), http.StatusBadRequest)
+		return
+	}
+	loc, err := parseTimezone(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.search.Search(r.Context(), query.Get("mode"), query.Get("q"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSearch):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrEmbedder):
+			log.Printf("%sFailed to embed a search query: %v", requestid.LogPrefix(r.Context()), err)
+			http.Error(w, "The embedder failed; try again later", http.StatusBadGateway)
+		case storageUnavailable(w, err):
+		default:
+			log.Printf("%sFailed to search notes: %v", requestid.LogPrefix(r.Context()), err)
+			http.Error(w, "Failed to search notes", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	linked := make([]linkedSearchResult, len(results))
+	for i := range results {
+		switch format {
+		case highlightHTML:
+			for j, highlight := range results[i].Highlights {
+				results[i].Highlights[j] = service.Highlight{Field: highlight.Field, Fragment: highlight.HTML()}
+			}
+		case highlightNone:
+			results[i].Highlights = nil
+		}
+		linked[i] = linkedSearchResult{SearchResult: results[i], Note: withLinks(inTimezone(results[i].Note, loc))}
+	}
+	if err := writeJSON(w, http.StatusOK, linked); err != nil {
+		http.Error(w, "Failed to encode search results", http.StatusInternalServerError)
+		return
+	}
+}
+
+// reindexSearch handles POST /api/admin/search/reindex.
+// It embeds and indexes every note for semantic searches in a "semantic-reindex" job,
+// and responds with 202 Accepted and the job, whose result is the number of notes indexed.
+func (h *Handler) reindexSearch(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Submit(r.Context(), jobs.Task{
+		Kind: "semantic-reindex",
+		Run: func(ctx context.Context) (any, error) {
+			indexed, err := h.search.Reindex(ctx)
+			return map[string]int{"indexed": indexed}, err
+		},
+	})
+	if err != nil {
+		jobRejected(w, err)
+		return
+	}
+	writeJobAccepted(w, job)
+}
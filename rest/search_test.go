@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/embedding"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/vector"
+)
+
+// TestSearchNotes tests text and semantic searches, and the errors of the endpoint
+func TestSearchNotes(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	for _, note := range []*model.Note{
+		model.NewNote("Garden", "Water the tomatoes every morning"),
+		model.NewNote("Budget", "Review the quarterly budget"),
+	} {
+		if err := backend.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	runner := jobs.NewRunner(1, 10)
+	defer runner.Close(ctx)
+	search := service.NewSearchService(service.New(backend),
+		service.WithSemanticSearch(embedding.NewHashing(256), vector.NewMemoryIndex(), runner))
+	if _, err := search.Reindex(ctx); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	r := chi.NewRouter()
+	NewHandler(backend, WithSearch(search), WithJobs(runner), WithAdminToken("admin-token")).RegisterRoutes(r)
+	serve := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	for _, url := range []string{"/api/notes/search?q=tomatoes", "/api/notes/search?q=watering+tomatoes&mode=semantic&limit=1"} {
+		w := serve(url)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code %d, got %d: %s", url, http.StatusOK, w.Code, w.Body.String())
+		}
+		var results []service.SearchResult
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 || results[0].Note.Title != "Garden" {
+			t.Errorf("%s: expected the garden note, got %s: %v", url, w.Body.String(), err)
+		}
+	}
+
+	// Matches are highlighted with offsets, or <em> tags
+	for highlight, expected := range map[string]string{
+		"":        `"highlights":[{"field":"content","fragment":"Water the tomatoes every morning","matches":[[10,18]]}]`,
+		"html":    `"highlights":[{"field":"content","fragment":"Water the \u003cem\u003etomatoes\u003c/em\u003e every morning"}]`,
+		"offsets": `"matches":[[10,18]]`,
+	} {
+		if w := serve("/api/notes/search?q=Tomatoes&highlight=" + highlight); !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("highlight=%s: expected %s, got %s", highlight, expected, w.Body.String())
+		}
+	}
+	if w := serve("/api/notes/search?q=tomatoes&highlight=none"); strings.Contains(w.Body.String(), "highlights") {
+		t.Errorf("Expected no highlights, got %s", w.Body.String())
+	}
+
+	for _, url := range []string{
+		"/api/notes/search",
+		"/api/notes/search?q=tomatoes&mode=fuzzy",
+		"/api/notes/search?q=tomatoes&limit=0",
+		"/api/notes/search?q=tomatoes&highlight=bold",
+	} {
+		if w := serve(url); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", url, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	w := serveAdmin(r, "POST", "/api/admin/search/reindex", "")
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") == "" {
+		t.Errorf("Expected status code %d with a Location, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+}
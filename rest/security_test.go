@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSecurityHeadersMiddleware tests the configured security headers and HSTS over TLS only
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := SecurityHeadersMiddleware(SecurityHeaders{
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'none'",
+		HSTSMaxAge:            24*time.Hour + 500*time.Millisecond,
+	})(next)
+
+	t.Run("PlainHTTP", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/notes", nil))
+
+		expected := map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Referrer-Policy":           "no-referrer",
+			"Content-Security-Policy":   "default-src 'none'",
+			"Strict-Transport-Security": "",
+		}
+		for key, value := range expected {
+			if got := w.Header().Get(key); got != value {
+				t.Errorf("Expected %s %q, got %q", key, value, got)
+			}
+		}
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		req.TLS = &tls.ConnectionState{}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=86400" {
+			t.Errorf("Expected HSTS with a max age of a day, got %q", got)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/notes", nil)
+		req.TLS = &tls.ConnectionState{}
+		w := httptest.NewRecorder()
+		SecurityHeadersMiddleware(SecurityHeaders{})(next).ServeHTTP(w, req)
+
+		for _, key := range []string{"X-Frame-Options", "Referrer-Policy", "Content-Security-Policy", "Strict-Transport-Security"} {
+			if got := w.Header().Get(key); got != "" {
+				t.Errorf("Expected no %s, got %q", key, got)
+			}
+		}
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Error("Expected X-Content-Type-Options to always be set")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package rest
+
+import "sync/atomic"
+
+// RuntimeSettings holds the REST server settings that can be changed while
+// the server is running, without a restart.
+type RuntimeSettings struct {
+	RateLimit          float64  // Requests per second allowed per client IP on /api routes (zero disables rate limiting)
+	RateLimitBurst     int      // Number of requests a client may send at once before being limited
+	CORSAllowedOrigins []string // Origins allowed to make cross-origin requests ("*" allows any); empty disables CORS
+}
+
+// Settings holds the current RuntimeSettings. Middleware reads a snapshot for every
+// request, and a new snapshot is swapped in atomically when the settings are reloaded,
+// so a request never sees a mix of old and new settings.
+type Settings struct {
+	current atomic.Pointer[RuntimeSettings]
+}
+
+// NewSettings creates a Settings holder with the given initial settings.
+//
+// Parameters:
+//   - initial: The settings in effect until the next Store
+//
+// Returns:
+//   - A pointer to a new Settings instance
+func NewSettings(initial RuntimeSettings) *Settings {
+	s := &Settings{}
+	s.Store(initial)
+	return s
+}
+
+// Load returns the current settings snapshot. It must not be modified.
+func (s *Settings) Load() *RuntimeSettings {
+	return s.current.Load()
+}
+
+// Store replaces the current settings.
+func (s *Settings) Store(settings RuntimeSettings) {
+	s.current.Store(&settings)
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// getStats handles GET /api/stats.
+// It returns statistics about the notes as JSON: their number, the total size of their
+// contents, the creation times of the oldest and newest notes, the number of notes per tag,
+// and the storage backend.
+// The backend computes them natively where it can (a MongoDB aggregation or a CouchDB
+// view), so the notes are not loaded.
+func (h *Handler) getStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.notes.Stats(r.Context())
+	if err != nil {
+		// If the storage circuit breaker rejected the operation, return a 503 Service Unavailable
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+
+	// Without tagged notes, the breakdown is empty rather than null
+	if stats.Tags == nil {
+		stats.Tags = map[string]int{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, "Failed to encode statistics", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+)
+
+// TestGetStats tests the statistics of GET /api/stats
+func TestGetStats(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		backend := storage.NewInMemoryStorage()
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, content := range []string{"Milk", "Café"} {
+			note := &model.Note{ID: string(rune('a' + i)), Title: "Title", Content: content, CreatedAt: base.Add(time.Duration(i) * time.Hour),
+				Tags: []string{"shopping", "work"}[i:]}
+			if err := backend.Create(context.Background(), note); err != nil {
+				t.Fatalf("Failed to create note: %v", err)
+			}
+		}
+
+		w := httptest.NewRecorder()
+		NewHandler(backend).getStats(w, setupTestRequest("GET", "/api/stats", ""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var response struct {
+			Storage      string         `json:"storage"`
+			Notes        int            `json:"notes"`
+			ContentBytes int64          `json:"content_bytes"`
+			Oldest       time.Time      `json:"oldest_created_at"`
+			Newest       time.Time      `json:"newest_created_at"`
+			Tags         map[string]int `json:"tags"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		// "Café" takes 5 bytes in UTF-8
+		if response.Storage != storage.BackendMemory || response.Notes != 2 || response.ContentBytes != 9 {
+			t.Errorf("Unexpected statistics: %+v", response)
+		}
+		if !response.Oldest.Equal(base) || !response.Newest.Equal(base.Add(time.Hour)) {
+			t.Errorf("Unexpected creation times: %v, %v", response.Oldest, response.Newest)
+		}
+		if want := map[string]int{"shopping": 1, "work": 2}; !maps.Equal(response.Tags, want) {
+			t.Errorf("Expected notes per tag %v, got %v", want, response.Tags)
+		}
+	})
+
+	t.Run("NoNotes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHandler(fake.New()).getStats(w, setupTestRequest("GET", "/api/stats", ""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, `"notes":0`) || strings.Contains(body, "oldest_created_at") ||
+			!strings.Contains(body, `"tags":{}`) {
+			t.Errorf("Expected zero notes without creation times or tags, got %s", body)
+		}
+	})
+
+	t.Run("StorageError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHandler(newFailingStorage()).getStats(w, setupTestRequest("GET", "/api/stats", ""))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// WithSummaries enables POST /api/notes/{id}/summarize, backed by the given service.
+func WithSummaries(summaries service.Summaries) HandlerOption {
+	return func(h *Handler) {
+		h.summaries = summaries
+	}
+}
+
+// summarizeNote handles POST /api/notes/{id}/summarize.
+// It summarizes the content of a note, stores the summary on the note, and returns the
+// updated note. It returns a 404 Not Found if the note doesn't exist, a 409 Conflict if
+// the note was modified while it was summarized, or a 502 Bad Gateway if the summarizer
+// failed.
+func (h *Handler) summarizeNote(w http.ResponseWriter, r *http.Request) {
+	note, err := h.summaries.Summarize(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNoteNotFound):
+			http.Error(w, "Note not found", http.StatusNotFound)
+		case errors.Is(err, storage.ErrConflict):
+			http.Error(w, "Note was modified concurrently; try again", http.StatusConflict)
+		case errors.Is(err, service.ErrSummarizer):
+			log.Printf("%sFailed to summarize note %s: %v", requestid.LogPrefix(r.Context()), chi.URLParam(r, "id"), err)
+			http.Error(w, "The summarizer failed; try again later", http.StatusBadGateway)
+		case storageUnavailable(w, err):
+		default:
+			log.Printf("%sFailed to store the summary of note %s: %v", requestid.LogPrefix(r.Context()), chi.URLParam(r, "id"), err)
+			http.Error(w, "Failed to summarize note", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, withLinks(note)); err != nil {
+		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
+		return
+	}
+}
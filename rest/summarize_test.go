@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/summarizer"
+)
+
+// failingSummarizer is a summarizer whose API is unavailable
+type failingSummarizer struct{}
+
+// Summarize fails
+func (failingSummarizer) Summarize(context.Context, string, string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+// TestSummarizeNote tests summarizing a note, and the errors of the endpoint
+func TestSummarizeNote(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	note := model.NewNote("Title", "First sentence. Second sentence.")
+	if err := backend.Create(context.Background(), note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	serve := func(summaries service.Summaries, url string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		NewHandler(backend, WithSummaries(summaries)).RegisterRoutes(r)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", url, nil))
+		return w
+	}
+	notes := service.New(backend, service.WithSummarizer(summarizer.NewExtractive(1)))
+
+	w := serve(notes, "/api/notes/"+note.ID+"/summarize")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var summarized model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &summarized); err != nil || summarized.Summary != "First sentence." {
+		t.Errorf("Expected the note with its summary, got %s: %v", w.Body.String(), err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		summaries service.Summaries
+		url       string
+		status    int
+	}{
+		{"MissingNote", notes, "/api/notes/missing/summarize", http.StatusNotFound},
+		{"SummarizerFailed", service.New(backend, service.WithSummarizer(failingSummarizer{})), "/api/notes/" + note.ID + "/summarize", http.StatusBadGateway},
+		{"Disabled", nil, "/api/notes/" + note.ID + "/summarize", http.StatusNotFound},
+	} {
+		if w := serve(tc.summaries, tc.url); w.Code != tc.status {
+			t.Errorf("%s: expected status code %d, got %d: %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WithTemplates enables the template endpoints under /api/templates and
+// POST /api/notes/from-template/{id}, backed by the given template service.
+func WithTemplates(templates service.Templates) HandlerOption {
+	return func(h *Handler) {
+		h.templates = templates
+	}
+}
+
+// instantiateRequest is the optional request body for POST /api/notes/from-template/{id}.
+type instantiateRequest struct {
+	Title     string            `json:"title"`     // Value of {{title}}
+	Variables map[string]string `json:"variables"` // Values of custom variables
+}
+
+// registerTemplateRoutes registers the template endpoints, if templates are enabled.
+// POST /api/notes/from-template/{id} is registered with the note routes.
+func (h *Handler) registerTemplateRoutes(r chi.Router) {
+	if h.templates == nil {
+		return
+	}
+	r.Route("/api/templates", func(r chi.Router) {
+		r.Get("/", h.listTemplates)
+		r.Post("/", h.createTemplate)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(ValidateNoteIDMiddleware)
+			r.Get("/", h.getTemplate)
+			r.Put("/", h.updateTemplate)
+			r.Delete("/", h.deleteTemplate)
+		})
+	})
+}
+
+// listTemplates handles GET /api/templates.
+// It returns all templates as a JSON array sorted by title, which is empty if there are none.
+func (h *Handler) listTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.templates.List(r.Context())
+	if err != nil {
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to get templates", http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []*model.Note{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(templates); err != nil {
+		http.Error(w, "Failed to encode templates", http.StatusInternalServerError)
+		return
+	}
+}
+
+// createTemplate handles POST /api/templates.
+// It creates a template from a JSON body with a title and content, like a note,
+// and returns it with a 201 Created.
+func (h *Handler) createTemplate(w http.ResponseWriter, r *http.Request) {
+	var body model.Note
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.templates.Create(r.Context(), service.NoteInput{Title: body.Title, Content: body.Content})
+	if err != nil {
+		h.templateError(w, err, "Failed to create template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, "Failed to encode template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getTemplate handles GET /api/templates/{id}.
+// If the template doesn't exist, it returns a 404 Not Found.
+func (h *Handler) getTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.templates.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.templateError(w, err, "Failed to get template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, "Failed to encode template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// updateTemplate handles PUT /api/templates/{id}.
+// It replaces the title and content of a template, like PUT /api/notes/{id}.
+// If the template doesn't exist, it returns a 404 Not Found.
+func (h *Handler) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	var body model.Note
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.templates.Update(r.Context(), chi.URLParam(r, "id"),
+		service.NoteInput{Title: body.Title, Content: body.Content, Rev: body.Rev})
+	if err != nil {
+		h.templateError(w, err, "Failed to update template")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		http.Error(w, "Failed to encode template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deleteTemplate handles DELETE /api/templates/{id}.
+// It returns a 204 No Content, or a 404 Not Found if the template doesn't exist.
+func (h *Handler) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.templates.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.templateError(w, err, "Failed to delete template")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createNoteFromTemplate handles POST /api/notes/from-template/{id}.
+// It creates a note from a template, substituting its variables with the values of an
+// optional JSON body ({"title": "...", "variables": {"name": "value"}}), and returns the
+// note with a 201 Created. If the template doesn't exist, it returns a 404 Not Found.
+func (h *Handler) createNoteFromTemplate(w http.ResponseWriter, r *http.Request) {
+	var body instantiateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	note, err := h.templates.Instantiate(r.Context(), chi.URLParam(r, "id"),
+		service.TemplateVariables{Title: body.Title, Variables: body.Variables})
+	if err != nil {
+		h.templateError(w, err, "Failed to create note")
+		return
+	}
+
+	setLocation(w, note)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(withLinks(note)); err != nil {
+		http.Error(w, "Failed to encode note", http.StatusInternalServerError)
+		return
+	}
+}
+
+// templateError writes the response for an error of the template service.
+func (h *Handler) templateError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidNote):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrNoteNotFound):
+		http.Error(w, "Template not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrConflict):
+		http.Error(w, "Template was modified concurrently; fetch it and retry", http.StatusConflict)
+	case storageUnavailable(w, err):
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
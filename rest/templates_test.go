@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/fake"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// setupTemplateRouter creates a router with the template endpoints enabled
+func setupTemplateRouter() (*chi.Mux, *fake.Storage) {
+	mockStorage := fake.New()
+	notes := service.New(mockStorage)
+	templates := service.NewTemplateService(storage.NewInMemoryStorage(), notes)
+
+	r := chi.NewRouter()
+	NewHandler(mockStorage, WithNoteService(notes), WithTemplates(templates)).RegisterRoutes(r)
+	return r, mockStorage
+}
+
+// serve sends a request to the router and returns the recorded response
+func serve(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, setupTestRequest(method, path, body))
+	return w
+}
+
+// TestTemplateEndpoints tests managing templates and creating notes from them
+func TestTemplateEndpoints(t *testing.T) {
+	r, mockStorage := setupTemplateRouter()
+
+	w := serve(r, "POST", "/api/templates", `{"title":"Meeting: {{title}}","content":"Notes of {{title}} in {{room}}"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var template model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &template); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(mockStorage.Notes()) != 0 {
+		t.Errorf("Expected the template to be stored apart from the notes, got %d notes", len(mockStorage.Notes()))
+	}
+
+	if w := serve(r, "GET", "/api/templates", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), template.ID) {
+		t.Errorf("Expected the template to be listed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(r, "PUT", "/api/templates/"+template.ID, `{"title":"Meeting: {{title}}","content":"Minutes of {{title}} in {{room}}"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = serve(r, "POST", "/api/notes/from-template/"+template.ID, `{"title":"Planning","variables":{"room":"Room 1"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var note model.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &note); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if note.Title != "Meeting: Planning" || note.Content != "Minutes of Planning in Room 1" {
+		t.Errorf("Unexpected note: %+v", note)
+	}
+	if mockStorage.Note(note.ID) == nil {
+		t.Error("Expected the note to be stored with the notes")
+	}
+
+	// The body is optional
+	if w := serve(r, "POST", "/api/notes/from-template/"+template.ID, ""); w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d without a body, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if w := serve(r, "DELETE", "/api/templates/"+template.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := serve(r, "GET", "/api/templates/"+template.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := serve(r, "POST", "/api/notes/from-template/"+template.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestTemplateEndpoints_Invalid tests invalid template requests
+func TestTemplateEndpoints_Invalid(t *testing.T) {
+	r, _ := setupTemplateRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"Empty template", "POST", "/api/templates", `{}`, http.StatusBadRequest},
+		{"Invalid body", "POST", "/api/templates", `{`, http.StatusBadRequest},
+		{"Invalid variables", "POST", "/api/notes/from-template/some-id", `{"variables":[]}`, http.StatusBadRequest},
+		{"Missing template", "PUT", "/api/templates/missing", `{"title":"Title"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, tt.method, tt.path, tt.body); w.Code != tt.status {
+				t.Errorf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestTemplateEndpoints_Disabled tests that the template endpoints only exist if enabled
+func TestTemplateEndpoints_Disabled(t *testing.T) {
+	r := chi.NewRouter()
+	NewHandler(fake.New()).RegisterRoutes(r)
+
+	if w := serve(r, "GET", "/api/templates", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
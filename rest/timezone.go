@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TimezoneHeader is the header of read requests asking for the timestamps of notes in a
+// time zone, as ?tz= does.
+const TimezoneHeader = "Accept-Timezone"
+
+// offsetPattern matches a fixed UTC offset, e.g., +02:00 or -05:30.
+var offsetPattern = regexp.MustCompile(`^([+-])(\d{2}):(\d{2})$`)
+
+// parseTimezone returns the time zone requested with ?tz=, or else the Accept-Timezone
+// header: an IANA name (e.g., Europe/Berlin), UTC (or Z), or a fixed offset (e.g.,
+// +02:00). Without either, timestamps are returned in UTC, as they are stored. The local
+// time zone of the server is rejected, since clients cannot know it.
+func parseTimezone(r *http.Request) (*time.Location, error) {
+	raw := r.URL.Query().Get("tz")
+	if raw == "" {
+		raw = r.Header.Get(TimezoneHeader)
+	}
+	switch raw {
+	case "", "UTC", "Z":
+		return time.UTC, nil
+	case "Local":
+		return nil, fmt.Errorf("unknown time zone %q", raw)
+	}
+
+	if match := offsetPattern.FindStringSubmatch(raw); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("invalid UTC offset %q", raw)
+		}
+		offset := (hours*60 + minutes) * 60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(raw, offset), nil
+	}
+
+	loc, err := time.LoadLocation(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", raw)
+	}
+	return loc, nil
+}
+
+// inTimezone returns a copy of a note with its timestamps in a time zone, leaving the
+// stored note (which may be cached) untouched.
+func inTimezone(note *model.Note, loc *time.Location) *model.Note {
+	localized := *note
+	localized.CreatedAt = note.CreatedAt.In(loc)
+	localized.UpdatedAt = note.UpdatedAt.In(loc)
+	if !note.LastAccessedAt.IsZero() {
+		localized.LastAccessedAt = note.LastAccessedAt.In(loc)
+	}
+	return &localized
+}
+
+// notesInTimezone returns copies of notes with their timestamps in a time zone.
+func notesInTimezone(notes []*model.Note, loc *time.Location) []*model.Note {
+	localized := make([]*model.Note, len(notes))
+	for i, note := range notes {
+		localized[i] = inTimezone(note, loc)
+	}
+	return localized
+}
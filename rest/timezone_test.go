@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage/fake"
+)
+
+// TestTimezones tests returning the timestamps of notes in UTC, or in the time zone
+// requested with ?tz= or the Accept-Timezone header
+func TestTimezones(t *testing.T) {
+	// A note stored with a local offset, as older versions did
+	created := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC).In(time.FixedZone("CET", 3600))
+	backend := fake.New(
+		&model.Note{ID: "1", Title: "Winter", Content: "See [[2]]", CreatedAt: created, UpdatedAt: created},
+		&model.Note{ID: "2", Title: "Target", CreatedAt: created, UpdatedAt: created},
+	)
+	r := chi.NewRouter()
+	NewHandler(backend, WithSearch(service.NewSearchService(service.New(backend))),
+		WithExpander("shout", &countingExpander{})).RegisterRoutes(r)
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		status int
+		want   string
+	}{
+		{"Default", "/api/notes/1", "", http.StatusOK, `"created_at":"2024-01-15T12:00:00Z"`},
+		{"UTC", "/api/notes/1?tz=UTC", "", http.StatusOK, `"created_at":"2024-01-15T12:00:00Z"`},
+		{"IANA", "/api/notes/1?tz=America/New_York", "", http.StatusOK, `"created_at":"2024-01-15T07:00:00-05:00"`},
+		{"Offset", "/api/notes/1?tz=%2B05:30", "", http.StatusOK, `"created_at":"2024-01-15T17:30:00+05:30"`},
+		{"Header", "/api/notes/1", "Asia/Tokyo", http.StatusOK, `"updated_at":"2024-01-15T21:00:00+09:00"`},
+		{"QueryOverHeader", "/api/notes/1?tz=UTC", "Asia/Tokyo", http.StatusOK, `"updated_at":"2024-01-15T12:00:00Z"`},
+		{"List", "/api/notes?tz=Europe/Berlin", "", http.StatusOK, `"created_at":"2024-01-15T13:00:00+01:00"`},
+		{"Expanded", "/api/notes?expand=shout&tz=Europe/Berlin", "", http.StatusOK, `"created_at":"2024-01-15T13:00:00+01:00"`},
+		{"Search", "/api/notes/search?q=winter&tz=-03:00", "", http.StatusOK, `"created_at":"2024-01-15T09:00:00-03:00"`},
+		{"Backlinks", "/api/notes/2/backlinks?tz=Europe/Berlin", "", http.StatusOK, `"created_at":"2024-01-15T13:00:00+01:00"`},
+		{"Unknown", "/api/notes/1?tz=Mars/Olympus", "", http.StatusBadRequest, "unknown time zone"},
+		{"Local", "/api/notes/1?tz=Local", "", http.StatusBadRequest, "unknown time zone"},
+		{"InvalidOffset", "/api/notes?tz=%2B25:00", "", http.StatusBadRequest, "invalid UTC offset"},
+		{"InvalidHeader", "/api/notes", "Nowhere", http.StatusBadRequest, "unknown time zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(TimezoneHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Expected the response to contain %s, got %s", tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// The stored notes are left untouched
+	if note := backend.Note("1"); !note.CreatedAt.Equal(created) || note.CreatedAt.Location() != created.Location() {
+		t.Errorf("Expected the stored note to be unchanged, got %v", note.CreatedAt)
+	}
+}
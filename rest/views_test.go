@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// TestViews tests that GET /api/notes/{id} counts the views of notes, and that lists have
+// them and can be sorted by them
+func TestViews(t *testing.T) {
+	backend := storage.NewInMemoryStorage()
+	views := service.NewViewCounter(storage.NewInMemoryStorage(), jobs.NewRunner(1, 10))
+	notes := service.New(backend, service.WithViewCounter(views))
+	r := chi.NewRouter()
+	NewHandler(backend, WithNoteService(notes)).RegisterRoutes(r)
+
+	get := func(path string, into any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), into); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+
+	popular := model.NewNote("Popular", "Content")
+	quiet := model.NewNote("Quiet", "Content")
+	for _, note := range []*model.Note{quiet, popular} {
+		if err := backend.Create(context.Background(), note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	var viewed model.Note
+	for i := 0; i < 3; i++ {
+		get("/api/notes/"+popular.ID, &viewed)
+	}
+	if viewed.Views != 3 || viewed.LastAccessedAt.IsZero() {
+		t.Errorf("Expected 3 views with the time of the last one, got %d at %v", viewed.Views, viewed.LastAccessedAt)
+	}
+	if _, err := views.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	var list []*model.Note
+	get("/api/notes?sort=most_viewed", &list)
+	if len(list) != 2 || list[0].ID != popular.ID || list[0].Views != 3 || list[1].Views != 0 {
+		t.Errorf("Expected the viewed note first, with its views, got %+v", list)
+	}
+	get("/api/notes?sort=-most_viewed&limit=1", &list)
+	if len(list) != 1 || list[0].ID != quiet.ID {
+		t.Errorf("Expected the least viewed note, got %+v", list)
+	}
+}
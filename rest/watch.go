@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// watchRequest is the request body for POST /api/notes/{id}/watch.
+type watchRequest struct {
+	CallbackURL string `json:"callback_url"` // URL that receives event payloads
+}
+
+// createWatch handles POST /api/notes/{id}/watch.
+// It registers a callback URL that receives an event whenever the note is updated or deleted.
+// If the note doesn't exist, it returns a 404 Not Found.
+func (h *Handler) createWatch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req watchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Only existing notes can be watched
+	if _, err := h.notes.Get(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrNoteNotFound) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		if storageUnavailable(w, err) {
+			return
+		}
+		http.Error(w, "Failed to get note", http.StatusInternalServerError)
+		return
+	}
+
+	watch, err := h.watchers.Add(id, req.CallbackURL, service.OwnerFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidCallbackURL):
+			http.Error(w, "Invalid callback URL", http.StatusBadRequest)
+		case errors.Is(err, webhook.ErrPrivateCallbackURL):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, webhook.ErrTooManyWatches):
+			http.Error(w, "Too many watches for this note", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to create watch", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(watch); err != nil {
+		http.Error(w, "Failed to encode watch", http.StatusInternalServerError)
+		return
+	}
+}
+
+// listWatches handles GET /api/notes/{id}/watch.
+// It returns the watches the caller registered for the note as a JSON array, which is empty
+// if the caller doesn't watch the note. Anonymous callers can't be told apart, so they
+// share their watches, but only see the scheme and host of the callback URLs.
+func (h *Handler) listWatches(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	owner := service.OwnerFromContext(r.Context())
+
+	watches := h.watchers.List(id, owner)
+	if owner == "" {
+		for i := range watches {
+			watches[i].CallbackURL = redactCallbackURL(watches[i].CallbackURL)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(watches); err != nil {
+		http.Error(w, "Failed to encode watches", http.StatusInternalServerError)
+		return
+	}
+}
+
+// redactCallbackURL returns the scheme and host of a callback URL, without the path and
+// query that may carry credentials of the receiver.
+func redactCallbackURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// deleteWatch handles DELETE /api/notes/{id}/watch/{watchID}.
+// It removes a single watch and returns a 204 No Content.
+// If the caller has no such watch, it returns a 404 Not Found.
+func (h *Handler) deleteWatch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	watchID := chi.URLParam(r, "watchID")
+
+	if err := h.watchers.Remove(id, watchID, service.OwnerFromContext(r.Context())); err != nil {
+		if errors.Is(err, webhook.ErrWatchNotFound) {
+			http.Error(w, "Watch not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete watch", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
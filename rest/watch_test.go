@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage/fake"
+	"golang-simple-notes/webhook"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// setupWatchRouter creates a router with watch endpoints enabled and a single stored note
+func setupWatchRouter() (*chi.Mux, *webhook.Watchers) {
+	mockStorage := fake.New()
+	mockStorage.Add(&model.Note{ID: "note-1", Title: "Title"})
+
+	watchers := webhook.NewWatchers(time.Second)
+	r := chi.NewRouter()
+	NewHandler(mockStorage, WithWatchers(watchers)).RegisterRoutes(r)
+	return r, watchers
+}
+
+// TestWatchEndpoints tests creating, listing, and deleting watches
+func TestWatchEndpoints(t *testing.T) {
+	r, watchers := setupWatchRouter()
+
+	// Create a watch
+	req := httptest.NewRequest("POST", "/api/notes/note-1/watch", strings.NewReader(`{"callback_url":"https://example.com/hook"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var watch webhook.Watch
+	if err := json.Unmarshal(w.Body.Bytes(), &watch); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if watch.ID == "" || watch.NoteID != "note-1" {
+		t.Errorf("Unexpected watch: %+v", watch)
+	}
+
+	// List watches
+	req = httptest.NewRequest("GET", "/api/notes/note-1/watch", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var watches []webhook.Watch
+	if err := json.Unmarshal(w.Body.Bytes(), &watches); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(watches) != 1 || watches[0].ID != watch.ID || watches[0].CallbackURL != "https://example.com" {
+		t.Errorf("Expected the created watch with a redacted URL, got %+v", watches)
+	}
+
+	// Delete the watch
+	req = httptest.NewRequest("DELETE", "/api/notes/note-1/watch/"+watch.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := watchers.List("note-1", ""); len(got) != 0 {
+		t.Errorf("Expected no watches after deletion, got %+v", got)
+	}
+
+	// Deleting it again returns 404
+	req = httptest.NewRequest("DELETE", "/api/notes/note-1/watch/"+watch.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// TestWatchEndpoints_Owners tests that callers only see and remove their own watches
+func TestWatchEndpoints_Owners(t *testing.T) {
+	r, _ := setupWatchRouter()
+
+	serve := func(method, path, body, owner string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if owner != "" {
+			req = req.WithContext(service.ContextWithOwner(req.Context(), owner))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("POST", "/api/notes/note-1/watch", `{"callback_url":"https://example.com/hook?token=alice"}`, "alice")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var watch webhook.Watch
+	if err := json.Unmarshal(w.Body.Bytes(), &watch); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	for _, owner := range []string{"bob", ""} {
+		w = serve("GET", "/api/notes/note-1/watch", "", owner)
+		if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+			t.Errorf("Expected no watches for %q, got %s", owner, body)
+		}
+		if w = serve("DELETE", "/api/notes/note-1/watch/"+watch.ID, "", owner); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for %q, got %d", http.StatusNotFound, owner, w.Code)
+		}
+	}
+
+	w = serve("GET", "/api/notes/note-1/watch", "", "alice")
+	var watches []webhook.Watch
+	if err := json.Unmarshal(w.Body.Bytes(), &watches); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(watches) != 1 || watches[0].CallbackURL != "https://example.com/hook?token=alice" {
+		t.Errorf("Expected the owner's watch with its URL, got %+v", watches)
+	}
+	if w = serve("DELETE", "/api/notes/note-1/watch/"+watch.ID, "", "alice"); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+// TestCreateWatch_Errors tests error responses of the watch creation endpoint
+func TestCreateWatch_Errors(t *testing.T) {
+	r, _ := setupWatchRouter()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"Invalid JSON", "/api/notes/note-1/watch", `{"callback_url":`, http.StatusBadRequest},
+		{"Invalid URL", "/api/notes/note-1/watch", `{"callback_url":"not-a-url"}`, http.StatusBadRequest},
+		{"Private URL", "/api/notes/note-1/watch", `{"callback_url":"http://169.254.169.254/latest"}`, http.StatusBadRequest},
+		{"Loopback URL", "/api/notes/note-1/watch", `{"callback_url":"http://localhost:8080/admin"}`, http.StatusBadRequest},
+		{"Note Not Found", "/api/notes/missing/watch", `{"callback_url":"https://example.com/hook"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Errorf("Expected status code %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+// TestWatchEndpoints_Disabled tests that watch routes are not registered without a registry
+func TestWatchEndpoints_Disabled(t *testing.T) {
+	r := chi.NewRouter()
+	NewHandler(fake.New()).RegisterRoutes(r)
+
+	req := httptest.NewRequest("GET", "/api/notes/note-1/watch", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
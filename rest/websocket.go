@@ -0,0 +1,354 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/service"
+	"golang-simple-notes/webhook"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// Message types of the WebSocket protocol.
+const (
+	wsSubscribe    = "subscribe"    // Client: start receiving events (all notes, some note IDs, or a tag)
+	wsUnsubscribe  = "unsubscribe"  // Client: stop a subscription
+	wsPing         = "ping"         // Client: check that the server is responsive
+	wsJoin         = "join"         // Client: start editing a note together with others
+	wsEdit         = "edit"         // Client: operations on the document of a joined note
+	wsLeave        = "leave"        // Client: stop editing a note
+	wsSubscribed   = "subscribed"   // Server: a subscription was started
+	wsUnsubscribed = "unsubscribed" // Server: a subscription was stopped
+	wsEvent        = "event"        // Server: a note event matching one or more subscriptions
+	wsPong         = "pong"         // Server: the reply to a ping
+	wsJoined       = "joined"       // Server: a note was joined; has the site name and the document
+	wsEdited       = "edited"       // Server: an edit was merged and stored
+	wsUpdate       = "update"       // Server: operations of other participants on a joined note
+	wsLeft         = "left"         // Server: a note was left
+	wsError        = "error"        // Server: a client message was rejected
+)
+
+const (
+	// maxWebSocketSubscriptions limits the number of subscriptions of a single connection.
+	maxWebSocketSubscriptions = 32
+
+	// maxWebSocketNoteIDs limits the number of notes of a single subscription.
+	maxWebSocketNoteIDs = 100
+
+	// maxWebSocketJoins limits the number of notes a single connection edits at the same time.
+	maxWebSocketJoins = 8
+
+	// webSocketUpdateBuffer is the number of update messages waiting to be written to a
+	// connection; a connection that falls further behind is closed, and must join again.
+	webSocketUpdateBuffer = 256
+
+	// webSocketReadLimit is the maximum size of a client message, in bytes.
+	webSocketReadLimit = 64 << 10
+
+	// webSocketWriteTimeout is the maximum time to write a single message to a client.
+	webSocketWriteTimeout = 10 * time.Second
+
+	// webSocketPingInterval is how often idle connections are pinged, so dead ones are closed
+	// and proxies don't drop live ones.
+	webSocketPingInterval = 30 * time.Second
+)
+
+// wsRequest is a message from a WebSocket client.
+type wsRequest struct {
+	Type    string    `json:"type"`               // wsSubscribe, wsUnsubscribe, wsPing, wsJoin, wsEdit, or wsLeave
+	ID      string    `json:"id,omitempty"`       // ID of the subscription; generated on subscribe if empty
+	All     bool      `json:"all,omitempty"`      // Subscribe to the events of all notes
+	NoteIDs []string  `json:"note_ids,omitempty"` // Subscribe to the events of these notes
+	Tag     string    `json:"tag,omitempty"`      // Subscribe to the events of notes with this tag
+	NoteID  string    `json:"note_id,omitempty"`  // Note to join, edit, or leave
+	Ops     []crdt.Op `json:"ops,omitempty"`      // Operations of an edit
+}
+
+// wsMessage is a message to a WebSocket client.
+type wsMessage struct {
+	Type          string        `json:"type"`                    // One of the server message types, e.g., wsEvent
+	ID            string        `json:"id,omitempty"`            // ID of the subscription the message is about
+	Subscriptions []string      `json:"subscriptions,omitempty"` // IDs of the subscriptions an event matches
+	Event         *events.Event `json:"event,omitempty"`         // The note event
+	NoteID        string        `json:"note_id,omitempty"`       // Joined note the message is about
+	Site          string        `json:"site,omitempty"`          // Site name of the connection for a joined note
+	Ops           []crdt.Op     `json:"ops,omitempty"`           // Operations rebuilding the document, or of an update
+	Error         string        `json:"error,omitempty"`         // Why the client message was rejected
+}
+
+// wsSubscription is a subscription of a WebSocket connection.
+type wsSubscription struct {
+	all     bool            // Whether the events of all notes match
+	noteIDs map[string]bool // Notes whose events match, unless all is set
+	tag     string          // Tag of the notes whose events match, unless all or noteIDs is set
+	tagged  map[string]bool // Notes last seen with the tag, whose next event matches even without it
+}
+
+// matches reports whether an event is about a note of the subscription. For a tag, these
+// are the notes with the tag after the change, and the notes that had it before: an update
+// removing the tag, or a deletion, which has no note, matches a note that was seen with it.
+func (s wsSubscription) matches(event events.Event) bool {
+	if s.all || s.noteIDs[event.NoteID] {
+		return true
+	}
+	if s.tag == "" {
+		return false
+	}
+	if event.Note != nil && slices.Contains(event.Note.Tags, s.tag) {
+		s.tagged[event.NoteID] = true
+		return true
+	}
+	if s.tagged[event.NoteID] {
+		delete(s.tagged, event.NoteID)
+		return true
+	}
+	return false
+}
+
+// WithBroadcaster enables the WebSocket endpoint, which streams the events of the broadcaster.
+func WithBroadcaster(broadcaster *webhook.Broadcaster) HandlerOption {
+	return func(h *Handler) {
+		h.broadcaster = broadcaster
+	}
+}
+
+// WithCollaboration enables editing notes together over the WebSocket endpoint.
+func WithCollaboration(collab service.Collaboration) HandlerOption {
+	return func(h *Handler) {
+		h.collab = collab
+	}
+}
+
+// WithSettings gives the handler the runtime settings of the REST server.
+// The WebSocket endpoint accepts connections from the origins allowed by CORS.
+func WithSettings(settings *Settings) HandlerOption {
+	return func(h *Handler) {
+		h.settings = settings
+	}
+}
+
+// handleWebSocket handles GET /api/ws.
+// It upgrades the connection to a WebSocket and streams note events as JSON messages, for clients
+// that need bidirectional communication. Clients send subscribe messages for the events of all notes
+// or of some note IDs, and receive an event message for every change that matches a subscription:
+//
+//	→ {"type":"subscribe","id":"mine","note_ids":["abc"]}
+//	← {"type":"subscribed","id":"mine"}
+//	← {"type":"event","subscriptions":["mine"],"event":{"event":"note.updated","note_id":"abc",...}}
+//
+// If collaborative editing is enabled, clients also join notes to edit their content together
+// (see wsCollab).
+//
+// Browsers may only connect from the page's own origin or the origins allowed by CORS.
+func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// The server's read and write timeouts would also end the hijacked connection, so clear them;
+	// idle connections are detected with pings instead
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.webSocketOrigins()})
+	if err != nil {
+		// Accept has already written the error response
+		log.Printf("%sWebSocket handshake failed: %v", requestid.LogPrefix(r.Context()), err)
+		return
+	}
+	defer func() { _ = conn.CloseNow() }()
+	conn.SetReadLimit(webSocketReadLimit)
+
+	// Subscribe before reading any message, so no event is missed after a subscribe reply
+	received, cancel := h.broadcaster.Subscribe()
+	defer cancel()
+
+	// The connection has been hijacked, so the request context is no longer canceled by the server
+	ctx, stop := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer stop()
+
+	collab := newWebSocketCollab(h.collab, func() {
+		log.Printf("%sClosing WebSocket that fell behind the edits of a note", requestid.LogPrefix(ctx))
+		stop()
+	})
+	defer collab.leaveAll()
+
+	// Read client messages in the background; all writes happen in this goroutine
+	requests := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			msgType, data, err := conn.Read(ctx)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if msgType != websocket.MessageText {
+				data = nil
+			}
+			select {
+			case requests <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	subscriptions := make(map[string]wsSubscription)
+	ticker := time.NewTicker(webSocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		var msg wsMessage
+		select {
+		case data := <-requests:
+			msg = handleWebSocketRequest(ctx, subscriptions, collab, data)
+		case msg = <-collab.updates:
+		case event, ok := <-received:
+			if !ok {
+				_ = conn.Close(websocket.StatusGoingAway, "server is shutting down")
+				return
+			}
+			var matched []string
+			for id, subscription := range subscriptions {
+				if subscription.matches(event) {
+					matched = append(matched, id)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			slices.Sort(matched)
+			msg = wsMessage{Type: wsEvent, Subscriptions: matched, Event: &event}
+		case <-ticker.C:
+			pingCtx, cancelPing := context.WithTimeout(ctx, webSocketWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				log.Printf("%sClosing unresponsive WebSocket: %v", requestid.LogPrefix(ctx), err)
+				return
+			}
+			continue
+		case err := <-readErr:
+			status := websocket.CloseStatus(err)
+			if status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway && !errors.Is(err, context.Canceled) {
+				log.Printf("%sWebSocket closed: %v", requestid.LogPrefix(ctx), err)
+			}
+			return
+		}
+
+		writeCtx, cancelWrite := context.WithTimeout(ctx, webSocketWriteTimeout)
+		err := wsjson.Write(writeCtx, conn, msg)
+		cancelWrite()
+		if err != nil {
+			log.Printf("%sFailed to write to WebSocket: %v", requestid.LogPrefix(ctx), err)
+			return
+		}
+	}
+}
+
+// handleWebSocketRequest applies a client message to the connection's subscriptions or joined
+// notes and returns the reply.
+func handleWebSocketRequest(ctx context.Context, subscriptions map[string]wsSubscription, collab *wsCollab, data []byte) wsMessage {
+	var req wsRequest
+	if data == nil {
+		return wsMessage{Type: wsError, Error: "messages must be JSON text messages"}
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return wsMessage{Type: wsError, Error: "invalid message: " + err.Error()}
+	}
+
+	switch req.Type {
+	case wsPing:
+		return wsMessage{Type: wsPong}
+	case wsUnsubscribe:
+		if _, ok := subscriptions[req.ID]; !ok {
+			return wsMessage{Type: wsError, ID: req.ID, Error: "subscription not found"}
+		}
+		delete(subscriptions, req.ID)
+		return wsMessage{Type: wsUnsubscribed, ID: req.ID}
+	case wsSubscribe:
+		if _, exists := subscriptions[req.ID]; exists {
+			return wsMessage{Type: wsError, ID: req.ID, Error: "subscription already exists"}
+		}
+		if len(subscriptions) >= maxWebSocketSubscriptions {
+			return wsMessage{Type: wsError, ID: req.ID,
+				Error: fmt.Sprintf("at most %d subscriptions are allowed per connection", maxWebSocketSubscriptions)}
+		}
+		subscription, err := newWebSocketSubscription(req)
+		if err != nil {
+			return wsMessage{Type: wsError, ID: req.ID, Error: err.Error()}
+		}
+
+		id := req.ID
+		for n := 1; id == ""; n++ {
+			if _, ok := subscriptions[strconv.Itoa(n)]; !ok {
+				id = strconv.Itoa(n)
+			}
+		}
+		subscriptions[id] = subscription
+		return wsMessage{Type: wsSubscribed, ID: id}
+	case wsJoin, wsEdit, wsLeave:
+		return collab.handle(ctx, req)
+	default:
+		return wsMessage{Type: wsError, ID: req.ID, Error: fmt.Sprintf("unknown message type %q", req.Type)}
+	}
+}
+
+// newWebSocketSubscription validates a subscribe message, which must select exactly one of
+// all notes, some note IDs, or a tag.
+func newWebSocketSubscription(req wsRequest) (wsSubscription, error) {
+	selectors := 0
+	if req.All {
+		selectors++
+	}
+	if len(req.NoteIDs) > 0 {
+		selectors++
+	}
+	if req.Tag != "" {
+		selectors++
+	}
+	if selectors != 1 {
+		return wsSubscription{}, errors.New("subscribe requires exactly one of all, note_ids, or tag")
+	}
+
+	switch {
+	case req.All:
+		return wsSubscription{all: true}, nil
+	case req.Tag != "":
+		tag := service.NormalizeTag(req.Tag)
+		if tag == "" {
+			return wsSubscription{}, errors.New("tag must not be empty")
+		}
+		return wsSubscription{tag: tag, tagged: make(map[string]bool)}, nil
+	case len(req.NoteIDs) > maxWebSocketNoteIDs:
+		return wsSubscription{}, fmt.Errorf("at most %d note IDs are allowed per subscription", maxWebSocketNoteIDs)
+	}
+
+	noteIDs := make(map[string]bool, len(req.NoteIDs))
+	for _, id := range req.NoteIDs {
+		if id == "" {
+			return wsSubscription{}, errors.New("note IDs must not be empty")
+		}
+		noteIDs[id] = true
+	}
+	return wsSubscription{noteIDs: noteIDs}, nil
+}
+
+// webSocketOrigins returns the origins allowed to open WebSockets from browsers, besides
+// the server's own origin: the origins allowed by CORS.
+func (h *Handler) webSocketOrigins() []string {
+	if h.settings == nil {
+		return nil
+	}
+	return h.settings.Load().CORSAllowedOrigins
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage/fake"
+	"golang-simple-notes/webhook"
+)
+
+// TestHandleWebSocketRequest tests the replies to client messages and the resulting subscriptions
+func TestHandleWebSocketRequest(t *testing.T) {
+	subscriptions := make(map[string]wsSubscription)
+
+	tests := []struct {
+		request string
+		want    wsMessage
+	}{
+		{`{"type":"ping"}`, wsMessage{Type: wsPong}},
+		{`{"type":"subscribe","id":"all","all":true}`, wsMessage{Type: wsSubscribed, ID: "all"}},
+		{`{"type":"subscribe","note_ids":["a","b"]}`, wsMessage{Type: wsSubscribed, ID: "1"}},
+		{`{"type":"subscribe","id":"all","all":true}`, wsMessage{Type: wsError, ID: "all", Error: "subscription already exists"}},
+		{`{"type":"subscribe","id":"x"}`, wsMessage{Type: wsError, ID: "x", Error: "subscribe requires exactly one of all, note_ids, or tag"}},
+		{`{"type":"subscribe","id":"x","all":true,"note_ids":["a"]}`, wsMessage{Type: wsError, ID: "x", Error: "subscribe requires exactly one of all, note_ids, or tag"}},
+		{`{"type":"subscribe","id":"x","tag":" "}`, wsMessage{Type: wsError, ID: "x", Error: "tag must not be empty"}},
+		{`{"type":"subscribe","id":"work","tag":"Work"}`, wsMessage{Type: wsSubscribed, ID: "work"}},
+		{`{"type":"unsubscribe","id":"work"}`, wsMessage{Type: wsUnsubscribed, ID: "work"}},
+		{`{"type":"unsubscribe","id":"missing"}`, wsMessage{Type: wsError, ID: "missing", Error: "subscription not found"}},
+		{`{"type":"unsubscribe","id":"all"}`, wsMessage{Type: wsUnsubscribed, ID: "all"}},
+		{`{"type":"publish"}`, wsMessage{Type: wsError, Error: `unknown message type "publish"`}},
+		{`{"type":"join","note_id":"a"}`, wsMessage{Type: wsError, NoteID: "a", Error: "collaborative editing is not enabled"}},
+	}
+
+	for _, tt := range tests {
+		if got := handleWebSocketRequest(context.Background(), subscriptions, nil, []byte(tt.request)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.request, tt.want, got)
+		}
+	}
+
+	if len(subscriptions) != 1 || !subscriptions["1"].matches(events.Event{NoteID: "b"}) || subscriptions["1"].matches(events.Event{NoteID: "c"}) {
+		t.Errorf("Expected a single subscription to notes a and b, got %+v", subscriptions)
+	}
+	if got := handleWebSocketRequest(context.Background(), subscriptions, nil, []byte("{")); got.Type != wsError {
+		t.Errorf("Expected an error for malformed JSON, got %+v", got)
+	}
+	if got := handleWebSocketRequest(context.Background(), subscriptions, nil, nil); got.Type != wsError {
+		t.Errorf("Expected an error for a binary message, got %+v", got)
+	}
+}
+
+// TestWebSocketTagSubscription tests which events match a subscription to a tag
+func TestWebSocketTagSubscription(t *testing.T) {
+	subscription, err := newWebSocketSubscription(wsRequest{Type: wsSubscribe, Tag: " Work "})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	tagged := &model.Note{ID: "a", Tags: []string{"home", "work"}}
+	untagged := &model.Note{ID: "a", Tags: []string{"home"}}
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"other note", events.Event{Type: events.NoteCreated, NoteID: "b", Note: &model.Note{ID: "b"}}, false},
+		{"deletion of a note not seen", events.Event{Type: events.NoteDeleted, NoteID: "b"}, false},
+		{"note with the tag", events.Event{Type: events.NoteCreated, NoteID: "a", Note: tagged}, true},
+		{"removal of the tag", events.Event{Type: events.NoteUpdated, NoteID: "a", Note: untagged}, true},
+		{"note without the tag", events.Event{Type: events.NoteUpdated, NoteID: "a", Note: untagged}, false},
+		{"tag added again", events.Event{Type: events.NoteUpdated, NoteID: "a", Note: tagged}, true},
+		{"deletion", events.Event{Type: events.NoteDeleted, NoteID: "a"}, true},
+		{"deletion again", events.Event{Type: events.NoteDeleted, NoteID: "a"}, false},
+	}
+	for _, tt := range tests {
+		if got := subscription.matches(tt.event); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestWebSocket tests subscribing to note events over a WebSocket connection
+func TestWebSocket(t *testing.T) {
+	broadcaster := webhook.NewBroadcaster()
+	r := chi.NewRouter()
+	NewHandler(fake.New(), WithBroadcaster(broadcaster)).RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	var reply wsMessage
+	if err := wsjson.Write(ctx, conn, wsRequest{Type: wsSubscribe, ID: "mine", NoteIDs: []string{"note-1"}}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if err := wsjson.Read(ctx, conn, &reply); err != nil || reply.Type != wsSubscribed {
+		t.Fatalf("Expected a subscribed reply, got %+v: %v", reply, err)
+	}
+
+	// Only the event of the subscribed note is sent
+	broadcaster.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-2"})
+	broadcaster.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: "note-1"})
+	if err := wsjson.Read(ctx, conn, &reply); err != nil {
+		t.Fatalf("Failed to read event: %v", err)
+	}
+	if reply.Type != wsEvent || reply.Event == nil || reply.Event.NoteID != "note-1" || !reflect.DeepEqual(reply.Subscriptions, []string{"mine"}) {
+		t.Errorf("Unexpected event message: %+v", reply)
+	}
+
+	// Closing the broadcaster at shutdown closes the connection
+	broadcaster.Close()
+	if _, _, err := conn.Read(ctx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("Expected the connection to be closed with StatusGoingAway, got %v", err)
+	}
+}
+
+// TestWebSocketOrigin tests that browsers may only connect from the allowed origins
+func TestWebSocketOrigin(t *testing.T) {
+	settings := NewSettings(RuntimeSettings{CORSAllowedOrigins: []string{"https://app.example.com"}})
+	r := chi.NewRouter()
+	NewHandler(fake.New(), WithBroadcaster(webhook.NewBroadcaster()), WithSettings(settings)).RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+
+	for origin, allowed := range map[string]bool{"https://app.example.com": true, "https://evil.example.com": false} {
+		conn, resp, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: http.Header{"Origin": {origin}}})
+		if allowed && err != nil {
+			t.Errorf("%s: expected the connection to be accepted, got %v", origin, err)
+		}
+		if !allowed && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("%s: expected 403 Forbidden, got %v", origin, err)
+		}
+		if conn != nil {
+			_ = conn.CloseNow()
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/scheduler"
+	"golang-simple-notes/storage"
+)
+
+// scheduledTaskRetry retries a failed scheduled run a few times before waiting for the next one.
+var scheduledTaskRetry = storage.RetryPolicy{MaxAttempts: 3, InitialDelay: 10 * time.Second, MaxDelay: time.Minute}
+
+// Names of the backup files, which sort by the time they were written.
+const (
+	backupPrefix     = "notes-"
+	backupSuffix     = ".ndjson"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// newScheduler creates the scheduler of the enabled tasks.
+//
+// Returns:
+//   - The scheduler, or nil if no task is enabled
+//   - An error if a schedule is invalid
+func (a *App) newScheduler() (*scheduler.Scheduler, error) {
+	tasks := []struct {
+		name     string
+		enabled  bool
+		schedule string
+		run      func(ctx context.Context) (any, error)
+	}{
+		{"backup", a.config.BackupEnabled, a.config.BackupSchedule, a.backupNotes},
+		{"purge", a.config.PurgeEnabled, a.config.PurgeSchedule, a.purgeNotes},
+		{"stats", a.config.StatsEnabled, a.config.StatsSchedule, a.recomputeStats},
+	}
+
+	var s *scheduler.Scheduler
+	for _, task := range tasks {
+		if !task.enabled {
+			continue
+		}
+		schedule, err := scheduler.Parse(task.schedule)
+		if err != nil {
+			return nil, fmt.Errorf("%s task: %w", task.name, err)
+		}
+		if s == nil {
+			s = scheduler.New(a.jobs, a.config.ScheduleJitter)
+		}
+		s.Add(scheduler.Task{Name: task.name, Schedule: schedule, Retry: scheduledTaskRetry, Run: task.run})
+	}
+	return s, nil
+}
+
+// backupNotes writes every note to a new NDJSON file in the backup directory, in the
+// format of the export endpoint, so that a backup can be restored with POST /api/import.
+// The file is written under a temporary name and renamed once complete. The oldest
+// backups beyond BackupKeep are then removed.
+func (a *App) backupNotes(ctx context.Context) (any, error) {
+	dir := a.config.BackupDir
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	file, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	// Removing the temporary file fails harmlessly once it has been renamed
+	defer os.Remove(file.Name())
+
+	count := 0
+	encoder := json.NewEncoder(file)
+	err = a.notes.Stream(ctx, func(note *model.Note) error {
+		count++
+		return encoder.Encode(note)
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to save backup: %w", err)
+	}
+	log.Printf("Backed up %d notes to %s", count, path)
+
+	removed, err := removeOldBackups(dir, a.config.BackupKeep)
+	if err != nil {
+		// The backup itself succeeded, so it isn't written again
+		log.Printf("Failed to remove old backups: %v", err)
+	}
+	return map[string]any{"file": path, "notes": count, "removed": removed}, nil
+}
+
+// removeOldBackups removes the oldest backup files beyond the given number (zero keeps every backup).
+//
+// Returns:
+//   - The number of removed files
+//   - An error if the directory cannot be read, or a file cannot be removed
+func removeOldBackups(dir string, keep int) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return 0, nil
+	}
+
+	slices.Sort(backups)
+	removed := 0
+	var errs []error
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// purgeNotes deletes the notes that haven't been updated for PurgeMaxAge. They are
+// deleted through the note service, so watchers, webhooks, and brokers are notified,
+// and quotas are released. Notes deleted meanwhile by someone else are skipped.
+func (a *App) purgeNotes(ctx context.Context) (any, error) {
+	cutoff := time.Now().Add(-a.config.PurgeMaxAge)
+	var expired []string
+	err := a.notes.Stream(ctx, func(note *model.Note) error {
+		if note.UpdatedAt.Before(cutoff) {
+			expired = append(expired, note.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find old notes: %w", err)
+	}
+
+	deleted := 0
+	for _, id := range expired {
+		err := a.notes.Delete(ctx, id)
+		if errors.Is(err, storage.ErrNoteNotFound) {
+			continue
+		}
+		if err != nil {
+			return map[string]int{"deleted": deleted}, fmt.Errorf("failed to delete note %s: %w", id, err)
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d notes not updated since %s", deleted, cutoff.Format(time.RFC3339))
+	}
+	return map[string]int{"deleted": deleted}, nil
+}
+
+// recomputeStats computes the note statistics, which scan every note, and exports them as
+// metrics, so that dashboards don't need to call GET /api/stats.
+func (a *App) recomputeStats(ctx context.Context) (any, error) {
+	stats, err := a.notes.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute stats: %w", err)
+	}
+	metrics.StatsNotes.Set(float64(stats.Notes))
+	metrics.StatsContentBytes.Set(float64(stats.ContentBytes))
+	return stats, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newScheduledApp creates an app with in-memory notes, one of them not updated for a day
+func newScheduledApp(t *testing.T, config *Config) *App {
+	t.Helper()
+	ctx := context.Background()
+	memory := storage.NewInMemoryStorage()
+	old := time.Now().Add(-24 * time.Hour)
+	notes := []*model.Note{
+		{ID: "old", Title: "Old", Content: "Forgotten", CreatedAt: old, UpdatedAt: old},
+		{ID: "new", Title: "New", Content: "Recent", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	for _, note := range notes {
+		if err := memory.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	app := NewApp(config)
+	app.storage = memory
+	app.notes = service.New(memory)
+	return app
+}
+
+// TestApp_BackupNotes tests that backups hold every note as NDJSON, and that only the
+// most recent ones are kept
+func TestApp_BackupNotes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"notes-20240101T000000Z.ndjson", "notes-20240102T000000Z.ndjson", "unrelated.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	app := newScheduledApp(t, &Config{BackupDir: dir, BackupKeep: 2})
+
+	result, err := app.backupNotes(context.Background())
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	summary := result.(map[string]any)
+	if summary["notes"] != 2 || summary["removed"] != 1 {
+		t.Errorf("Unexpected backup summary %v", summary)
+	}
+
+	file, err := os.Open(summary["file"].(string))
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer file.Close()
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var note model.Note
+		if err := json.Unmarshal(scanner.Bytes(), &note); err != nil {
+			t.Fatalf("Invalid backup line %q: %v", scanner.Text(), err)
+		}
+		ids[note.ID] = true
+	}
+	if !ids["old"] || !ids["new"] {
+		t.Errorf("Expected every note in the backup, got %v", ids)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != "notes-20240102T000000Z.ndjson" || names[2] != "unrelated.txt" {
+		t.Errorf("Expected the oldest backup and the temporary file to be removed, got %v", names)
+	}
+}
+
+// TestApp_PurgeNotes tests that only the notes not updated for the maximum age are deleted
+func TestApp_PurgeNotes(t *testing.T) {
+	ctx := context.Background()
+	app := newScheduledApp(t, &Config{PurgeMaxAge: time.Hour})
+
+	result, err := app.purgeNotes(ctx)
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if result.(map[string]int)["deleted"] != 1 {
+		t.Errorf("Expected one deleted note, got %v", result)
+	}
+	if _, err := app.storage.Get(ctx, "old"); err == nil {
+		t.Error("Expected the old note to be purged")
+	}
+	if _, err := app.storage.Get(ctx, "new"); err != nil {
+		t.Errorf("Expected the recent note to be kept, got %v", err)
+	}
+}
+
+// TestApp_RecomputeStats tests that the note statistics are exported as metrics
+func TestApp_RecomputeStats(t *testing.T) {
+	app := newScheduledApp(t, &Config{})
+	if _, err := app.recomputeStats(context.Background()); err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.StatsNotes); got != 2 {
+		t.Errorf("Expected 2 notes, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.StatsContentBytes); got != float64(len("Forgotten")+len("Recent")) {
+		t.Errorf("Unexpected content size %v", got)
+	}
+}
+
+// TestApp_NewScheduler tests that only the enabled tasks are scheduled
+func TestApp_NewScheduler(t *testing.T) {
+	app := newScheduledApp(t, &Config{StatsSchedule: "@every 1h"})
+	app.jobs = jobs.NewRunner(1, 1)
+	defer app.jobs.Close(context.Background())
+	if s, err := app.newScheduler(); err != nil || s != nil {
+		t.Errorf("Expected no scheduler without enabled tasks, got %v, %v", s, err)
+	}
+
+	app.config.StatsEnabled = true
+	if s, err := app.newScheduler(); err != nil || s == nil {
+		t.Errorf("Expected a scheduler, got %v, %v", s, err)
+	}
+	app.config.BackupEnabled, app.config.BackupSchedule = true, "every day"
+	if _, err := app.newScheduler(); err == nil {
+		t.Error("Expected an invalid schedule to be rejected")
+	}
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the times at which a recurring task runs.
+type Schedule interface {
+	// Next returns the first time after t at which the task runs, or the zero time if
+	// it never runs again.
+	Next(t time.Time) time.Time
+}
+
+// cronSchedule is a Schedule given by a cron expression, as a set of matching values
+// per field (bit i is set if value i matches).
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDay                        bool // Whether the day of month or the day of week is "*"
+}
+
+// everySchedule runs a task at a fixed interval.
+type everySchedule time.Duration
+
+// cronField is the range of values of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min on (e.g., "jan"), if any
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// Sunday is both 0 and 7
+	dowField = cronField{name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// descriptors are the predefined schedules that can be used instead of five fields.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule in one of these forms:
+//   - Five cron fields (minute, hour, day of month, month, day of week), each being "*",
+//     a value, a range ("1-5"), a list ("1,15"), or a step ("*/15", "8-18/2"); months
+//     and days of week can be given by name ("jan", "mon"). As with cron, a task runs
+//     on the days matching either the day of month or the day of week if both are given.
+//   - A descriptor: @yearly (or @annually), @monthly, @weekly, @daily (or @midnight), or @hourly
+//   - "@every <duration>" (e.g., "@every 90m"), measured from the previous run
+//
+// Times are in the local time zone.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if interval, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1s", expr)
+		}
+		return everySchedule(d), nil
+	}
+	if fields, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = fields
+	} else if strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("invalid schedule %q: unknown descriptor", expr)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %d", expr, len(fields))
+	}
+	var s cronSchedule
+	var err error
+	for i, target := range []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		field := []cronField{minuteField, hourField, domField, monthField, dowField}[i]
+		if *target, err = field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // Sunday
+	}
+	s.anyDay = fields[2] == "*" || fields[4] == "*"
+	return s, nil
+}
+
+// parse returns the set of values matching a field.
+func (f cronField) parse(field string) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(strings.ToLower(field), ",") {
+		values, step, hasStep := strings.Cut(part, "/")
+		first, last := f.min, f.max
+		if values != "*" {
+			start, end, isRange := strings.Cut(values, "-")
+			var err error
+			if first, err = f.value(start); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = f.value(end); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" means from 5 to the end, every 15
+				last = f.max
+			}
+			if last < first {
+				return 0, fmt.Errorf("%s range %q is reversed", f.name, values)
+			}
+		}
+
+		increment := 1
+		if hasStep {
+			n, err := strconv.Atoi(step)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, step)
+			}
+			increment = n
+		}
+		for v := first; v <= last; v += increment {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a single value of a field, by number or name.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q (expected %d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching the expression, or the zero time if there
+// is none within five years (e.g., for February 30).
+func (s cronSchedule) Next(t time.Time) time.Time {
+	// Start at the next whole minute
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			// Skip straight to the next matching minute of the hour, if any
+			if next := s.minute >> t.Minute(); next != 0 {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)) * time.Minute)
+			} else {
+				t = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day of month and day of week
+// fields: both if either is "*", and either one otherwise.
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns t plus the interval.
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
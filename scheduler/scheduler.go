@@ -0,0 +1,142 @@
+// Package scheduler runs recurring tasks, such as backups, on cron schedules. Each run
+// is submitted as a background job, so that it is retried and its status is shown like
+// that of any other job. Runs are delayed by a random jitter, so that instances sharing
+// a schedule don't all hit the storage at once, and a run is skipped if the previous
+// run of the same task is still in progress.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/storage"
+)
+
+// Task is a recurring task.
+type Task struct {
+	Name     string                                 // Name of the task, also the kind of its jobs (e.g., "backup")
+	Schedule Schedule                               // When the task runs
+	Retry    storage.RetryPolicy                    // Attempts of each run; the zero value makes a single attempt
+	Run      func(ctx context.Context) (any, error) // Makes a single attempt; its result is shown in the job status
+}
+
+// entry is a task with whether a run of it is in progress.
+type entry struct {
+	task    Task
+	running atomic.Bool
+}
+
+// Scheduler submits the runs of tasks to a job runner when they are due.
+type Scheduler struct {
+	runner *jobs.Runner
+	jitter time.Duration
+	tasks  []*entry
+
+	ctx     context.Context    // Canceled to stop scheduling
+	cancel  context.CancelFunc // Cancels ctx
+	loops   sync.WaitGroup     // Running schedule loops
+	started bool
+}
+
+// New creates a scheduler.
+//
+// Parameters:
+//   - runner: The job runner running the tasks
+//   - jitter: The maximum random delay added to each run; zero runs tasks exactly on schedule
+//
+// Returns:
+//   - A pointer to a new Scheduler instance
+func New(runner *jobs.Runner, jitter time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{runner: runner, jitter: max(jitter, 0), ctx: ctx, cancel: cancel}
+}
+
+// Add adds a task. Tasks must be added before Start.
+func (s *Scheduler) Add(task Task) {
+	s.tasks = append(s.tasks, &entry{task: task})
+}
+
+// Start starts scheduling the tasks.
+func (s *Scheduler) Start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, e := range s.tasks {
+		log.Printf("Scheduled task %s, next run at %s", e.task.Name, e.task.Schedule.Next(time.Now()).Format(time.RFC3339))
+		s.loops.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Close stops scheduling runs. Runs already submitted are left to the job runner, which
+// should be closed afterwards to wait for them.
+func (s *Scheduler) Close(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.loops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop submits the runs of a task when they are due, until the scheduler is closed.
+func (s *Scheduler) loop(e *entry) {
+	defer s.loops.Done()
+	for {
+		next := e.task.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Scheduled task %s will not run again", e.task.Name)
+			return
+		}
+		if s.jitter > 0 {
+			next = next.Add(rand.N(s.jitter))
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			s.trigger(e)
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// trigger submits a run of a task, unless the previous run is still in progress.
+func (s *Scheduler) trigger(e *entry) {
+	name := e.task.Name
+	if !e.running.CompareAndSwap(false, true) {
+		log.Printf("Skipping scheduled task %s: the previous run is still in progress", name)
+		metrics.ScheduledRuns.WithLabelValues(name, "skipped").Inc()
+		return
+	}
+
+	job, err := s.runner.Submit(s.ctx, jobs.Task{
+		Kind:  name,
+		Retry: e.task.Retry,
+		Run:   e.task.Run,
+		Done:  func(any, error) { e.running.Store(false) },
+	})
+	if err != nil {
+		e.running.Store(false)
+		log.Printf("Failed to submit scheduled task %s: %v", name, err)
+		metrics.ScheduledRuns.WithLabelValues(name, "rejected").Inc()
+		return
+	}
+	log.Printf("Started scheduled task %s as job %s", name, job.ID)
+	metrics.ScheduledRuns.WithLabelValues(name, "submitted").Inc()
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-simple-notes/jobs"
+)
+
+// TestScheduler tests that tasks run as jobs on their schedule, and that a run is skipped
+// while the previous one is still in progress
+func TestScheduler(t *testing.T) {
+	runner := jobs.NewRunner(2, 10)
+	s := New(runner, 0)
+
+	var quick, slow, concurrent, maxConcurrent atomic.Int32
+	s.Add(Task{Name: "quick", Schedule: everySchedule(10 * time.Millisecond), Run: func(ctx context.Context) (any, error) {
+		quick.Add(1)
+		return nil, nil
+	}})
+	s.Add(Task{Name: "slow", Schedule: everySchedule(5 * time.Millisecond), Run: func(ctx context.Context) (any, error) {
+		n := concurrent.Add(1)
+		defer concurrent.Add(-1)
+		if n > maxConcurrent.Load() {
+			maxConcurrent.Store(n)
+		}
+		slow.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return nil, nil
+	}})
+	s.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := quick.Load(); got < 5 {
+		t.Errorf("Expected the quick task to run repeatedly, got %d runs", got)
+	}
+	if got := slow.Load(); got < 2 || got > 5 {
+		t.Errorf("Expected the slow task to skip runs while in progress, got %d runs", got)
+	}
+	if got := maxConcurrent.Load(); got != 1 {
+		t.Errorf("Expected runs of the slow task not to overlap, got %d at once", got)
+	}
+	if got := runner.List("quick", jobs.StatusSucceeded); len(got) != int(quick.Load()) {
+		t.Errorf("Expected a job per run, got %d jobs for %d runs", len(got), quick.Load())
+	}
+
+	// No more runs once closed
+	runs := quick.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := quick.Load(); got != runs {
+		t.Errorf("Expected no runs after Close, got %d more", got-runs)
+	}
+}
+
+// TestScheduler_Jitter tests that runs are delayed by up to the jitter
+func TestScheduler_Jitter(t *testing.T) {
+	runner := jobs.NewRunner(1, 10)
+	s := New(runner, 100*time.Millisecond)
+	start := time.Now()
+	ran := make(chan time.Time, 10)
+	s.Add(Task{Name: "jittered", Schedule: everySchedule(time.Millisecond), Run: func(ctx context.Context) (any, error) {
+		ran <- time.Now()
+		return nil, nil
+	}})
+	s.Start()
+	defer func() {
+		_ = s.Close(context.Background())
+		_ = runner.Close(context.Background())
+	}()
+
+	select {
+	case at := <-ran:
+		if elapsed := at.Sub(start); elapsed > time.Second {
+			t.Errorf("Expected the run within the jitter, got it after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the task to run")
+	}
+}
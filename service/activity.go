@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+)
+
+// Activity is counted per minute, for at most a week.
+const (
+	activityInterval = time.Minute
+
+	// ActivityRetention is how long ActivityLog keeps the counts of changes; longer windows
+	// can't be summarized.
+	ActivityRetention = 7 * 24 * time.Hour
+)
+
+// ActivityCounts are the numbers of notes created, updated, and deleted over a period.
+type ActivityCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// activitySlot holds the counts of one minute of the ring of ActivityLog.
+type activitySlot struct {
+	minute int64 // Minutes since the Unix epoch; the slot is stale if it isn't the one expected
+	counts ActivityCounts
+}
+
+// ActivityLog counts the notes created, updated, and deleted every minute, from the note
+// events it is notified of, over the last ActivityRetention. Counts are kept in memory,
+// in a ring of one slot per minute, so they take the same memory however busy the notes
+// are, and start over when the process restarts. With a change feed, they include the
+// changes of other instances. It implements events.Subscriber, and is safe for
+// concurrent use.
+type ActivityLog struct {
+	now func() time.Time // Clock, replaced in tests
+
+	mutex   sync.Mutex
+	started time.Time      // When counting started
+	slots   []activitySlot // Counts by minute, at the minute modulo their number
+}
+
+// NewActivityLog creates an activity log that starts counting now.
+func NewActivityLog() *ActivityLog {
+	return &ActivityLog{
+		now:     time.Now,
+		started: time.Now(),
+		slots:   make([]activitySlot, ActivityRetention/activityInterval),
+	}
+}
+
+// Notify counts the change of a created, updated, or deleted event, at the time of the event.
+func (l *ActivityLog) Notify(ctx context.Context, event events.Event) {
+	at := event.Timestamp
+	if at.IsZero() {
+		at = l.now()
+	}
+	minute := at.Unix() / int64(activityInterval/time.Second)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	slot := &l.slots[minute%int64(len(l.slots))]
+	if slot.minute != minute {
+		if slot.minute > minute {
+			// Older than the retention
+			return
+		}
+		*slot = activitySlot{minute: minute}
+	}
+	switch event.Type {
+	case events.NoteCreated:
+		slot.counts.Created++
+	case events.NoteUpdated:
+		slot.counts.Updated++
+	case events.NoteDeleted:
+		slot.counts.Deleted++
+	}
+}
+
+// Counts returns the changes counted over the last window, from the start of its first
+// minute. Windows longer than ActivityRetention are cut to it.
+//
+// Returns:
+//   - The numbers of notes created, updated, and deleted
+//   - The start of the period counted: the start of the window, or when counting
+//     started if that is later
+func (l *ActivityLog) Counts(window time.Duration) (ActivityCounts, time.Time) {
+	now := l.now()
+	window = min(window, ActivityRetention-activityInterval)
+	interval := int64(activityInterval / time.Second)
+	first, last := now.Add(-window).Unix()/interval, now.Unix()/interval
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var counts ActivityCounts
+	for minute := first; minute <= last; minute++ {
+		slot := l.slots[minute%int64(len(l.slots))]
+		if slot.minute == minute {
+			counts.Created += slot.counts.Created
+			counts.Updated += slot.counts.Updated
+			counts.Deleted += slot.counts.Deleted
+		}
+	}
+
+	since := time.Unix(first*interval, 0).UTC()
+	if l.started.After(since) {
+		since = l.started.UTC()
+	}
+	return counts, since
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+)
+
+// TestActivityLog tests counting changes over windows, and that counts older than the
+// retention are dropped as their slots are reused
+func TestActivityLog(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 30, 0, time.UTC)
+	log := NewActivityLog()
+	log.now = func() time.Time { return now }
+	log.started = now.Add(-30 * 24 * time.Hour)
+
+	notify := func(eventType string, ago time.Duration) {
+		log.Notify(ctx, events.Event{Type: eventType, NoteID: "n", Timestamp: now.Add(-ago)})
+	}
+	notify(events.NoteCreated, 0)
+	notify(events.NoteUpdated, 10*time.Minute)
+	notify(events.NoteUpdated, 2*time.Hour)
+	notify(events.NoteDeleted, 3*24*time.Hour)
+	// Older than the retention: its slot holds the minute of the update two hours ago
+	notify(events.NoteCreated, ActivityRetention+2*time.Hour)
+
+	tests := []struct {
+		window time.Duration
+		want   ActivityCounts
+	}{
+		{time.Minute, ActivityCounts{Created: 1}},
+		{time.Hour, ActivityCounts{Created: 1, Updated: 1}},
+		{24 * time.Hour, ActivityCounts{Created: 1, Updated: 2}},
+		{ActivityRetention, ActivityCounts{Created: 1, Updated: 2, Deleted: 1}},
+	}
+	for _, tt := range tests {
+		counts, since := log.Counts(tt.window)
+		if counts != tt.want {
+			t.Errorf("Counts(%v): expected %+v, got %+v", tt.window, tt.want, counts)
+		}
+		if start := now.Add(-tt.window); since.After(start) && since.Sub(start) > time.Minute {
+			t.Errorf("Counts(%v): expected the period to start with the window, got %v", tt.window, since)
+		}
+	}
+
+	// A slot is reset when a later minute reuses it
+	now = now.Add(ActivityRetention)
+	notify(events.NoteUpdated, 0)
+	if counts, _ := log.Counts(time.Minute); counts != (ActivityCounts{Updated: 1}) {
+		t.Errorf("Expected the counts of the reused slot to start over, got %+v", counts)
+	}
+
+	// The period starts when counting started, if that is later
+	log.started = now.Add(-time.Hour)
+	if _, since := log.Counts(24 * time.Hour); !since.Equal(log.started) {
+		t.Errorf("Expected the period to start with the log, got %v", since)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Icons are the icons a note can have, which clients map to their own images.
+var Icons = []string{
+	"book", "bookmark", "calendar", "check", "code", "flag", "heart", "home",
+	"idea", "lock", "music", "note", "pin", "shopping", "star", "travel", "work",
+}
+
+// colorPattern matches a hex color, e.g., #1e90ff.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// validateAppearance checks the color and icon of a note, which are optional: a color is a
+// hex color (#rrggbb), and an icon one of Icons.
+//
+// Returns:
+//   - The color in lower case, as it is stored, so filters on colors ignore case
+//   - An error wrapping ErrInvalidNote if the color or icon is invalid
+func validateAppearance(color, icon string) (string, error) {
+	if color != "" && !colorPattern.MatchString(color) {
+		return "", fmt.Errorf("%w: color must be a hex color like #1e90ff", ErrInvalidNote)
+	}
+	if icon != "" && !slices.Contains(Icons, icon) {
+		return "", fmt.Errorf("%w: icon must be one of %s", ErrInvalidNote, strings.Join(Icons, ", "))
+	}
+	return strings.ToLower(color), nil
+}
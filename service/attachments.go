@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/thumbnail"
+)
+
+// ErrInvalidAttachment is returned (wrapped) when the name or content type of an attachment
+// is invalid. The error message describes what is wrong, so it can be shown to clients.
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// attachmentName matches valid attachment names: letters, digits, dots, dashes, and
+// underscores, not starting with a dot or an underscore (CouchDB reserves names starting
+// with one), so names can be used in URLs without escaping.
+var attachmentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+// defaultAttachmentType is the content type of attachments uploaded without one.
+const defaultAttachmentType = "application/octet-stream"
+
+// AttachmentService manages the files attached to notes, stored by a storage backend that
+// supports them natively (e.g., as CouchDB document attachments). It implements the
+// Attachments port.
+type AttachmentService struct {
+	store storage.AttachmentStore // Backend storing the attachments with the notes
+	cache *storage.CachedStorage  // Cache of the notes, whose revision changes with their attachments (optional)
+
+	// Thumbnails of image attachments (see thumbnails.go)
+	runner  *jobs.Runner // Runner of the jobs creating the thumbnails; nil disables thumbnails
+	sizes   []int        // Sizes of the thumbnails, in ascending order
+	pending sync.Map     // Attachments whose thumbnails are being created, by thumbnailKey
+	failed  sync.Map     // Digests of the attachments that are not supported images, by note ID and name
+}
+
+// AttachmentOption configures optional features of an AttachmentService.
+type AttachmentOption func(*AttachmentService)
+
+// NewAttachmentService creates a new AttachmentService.
+//
+// Parameters:
+//   - store: The storage backend of the notes, which stores their attachments
+//   - cache: The cache in front of the backend, if any; notes are removed from it when
+//     their attachments change, since the backend may give them a new revision
+//   - opts: Optional features to enable (e.g., WithThumbnails)
+//
+// Returns:
+//   - A pointer to a new AttachmentService instance
+func NewAttachmentService(store storage.AttachmentStore, cache *storage.CachedStorage, opts ...AttachmentOption) *AttachmentService {
+	s := &AttachmentService{store: store, cache: cache}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Put stores a file as an attachment of a note, replacing an attachment with the same name.
+// Without a content type, the attachment is stored as application/octet-stream. With
+// thumbnails, those of a replaced attachment are removed, and those of an image are created
+// in the background.
+//
+// Returns:
+//   - The stored attachment
+//   - An error wrapping ErrInvalidAttachment if the name or content type is invalid, or the
+//     storage error (storage.ErrNoteNotFound if the note doesn't exist)
+func (s *AttachmentService) Put(ctx context.Context, noteID string, attachment storage.Attachment, body io.Reader) (storage.Attachment, error) {
+	if err := validateAttachmentName(attachment.Name); err != nil {
+		return storage.Attachment{}, err
+	}
+	if attachment.ContentType == "" {
+		attachment.ContentType = defaultAttachmentType
+	}
+	if _, _, err := mime.ParseMediaType(attachment.ContentType); err != nil {
+		return storage.Attachment{}, fmt.Errorf("%w: invalid content type %q", ErrInvalidAttachment, attachment.ContentType)
+	}
+
+	stored, err := s.store.PutAttachment(ctx, noteID, attachment, body)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	s.invalidate(ctx, noteID)
+	s.removeThumbnails(ctx, noteID, stored.Name)
+	if s.runner != nil && thumbnail.Supported(stored.ContentType) {
+		s.submitThumbnails(ctx, noteID, stored)
+	}
+	return stored, nil
+}
+
+// Get returns an attachment of a note with its content, which the caller must close.
+// It returns storage.ErrNoteNotFound or storage.ErrAttachmentNotFound if either is missing.
+func (s *AttachmentService) Get(ctx context.Context, noteID, name string) (storage.Attachment, io.ReadCloser, error) {
+	// No attachment can have an invalid name
+	if validateAttachmentName(name) != nil {
+		return storage.Attachment{}, nil, storage.ErrAttachmentNotFound
+	}
+	return s.store.GetAttachment(ctx, noteID, name)
+}
+
+// Delete removes an attachment of a note, with its thumbnails. It returns
+// storage.ErrNoteNotFound or storage.ErrAttachmentNotFound if either is missing.
+func (s *AttachmentService) Delete(ctx context.Context, noteID, name string) error {
+	if validateAttachmentName(name) != nil {
+		return storage.ErrAttachmentNotFound
+	}
+	if err := s.store.DeleteAttachment(ctx, noteID, name); err != nil {
+		return err
+	}
+	s.invalidate(ctx, noteID)
+	s.removeThumbnails(ctx, noteID, name)
+	return nil
+}
+
+// List returns the attachments of a note, sorted by name, or storage.ErrNoteNotFound.
+// Thumbnails are not listed.
+func (s *AttachmentService) List(ctx context.Context, noteID string) ([]storage.Attachment, error) {
+	attachments, err := s.store.ListAttachments(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(attachments, func(a storage.Attachment) bool {
+		return strings.HasPrefix(a.Name, thumbnailPrefix)
+	}), nil
+}
+
+// ListMany returns the attachments of several notes, sorted by name, by note ID, listed at
+// once if the backend supports it. Notes that don't exist are left out, and thumbnails are
+// not listed.
+func (s *AttachmentService) ListMany(ctx context.Context, noteIDs []string) (map[string][]storage.Attachment, error) {
+	attachments, err := storage.ListAttachmentsMany(ctx, s.store, noteIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, list := range attachments {
+		attachments[id] = slices.DeleteFunc(list, func(a storage.Attachment) bool {
+			return strings.HasPrefix(a.Name, thumbnailPrefix)
+		})
+	}
+	return attachments, nil
+}
+
+// invalidate removes a note whose attachments changed from the cache.
+func (s *AttachmentService) invalidate(ctx context.Context, noteID string) {
+	if s.cache != nil {
+		s.cache.Invalidate(ctx, noteID)
+	}
+}
+
+// validateAttachmentName checks the name of an attachment.
+func validateAttachmentName(name string) error {
+	if !attachmentName.MatchString(name) {
+		return fmt.Errorf("%w: name must be 1 to 255 letters, digits, dots, dashes, or underscores, starting with a letter or digit", ErrInvalidAttachment)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// revisingStorage is an in-memory storage whose attachment uploads change the revision
+// of their note, like CouchDB
+type revisingStorage struct {
+	*storage.InMemoryStorage
+}
+
+func (s revisingStorage) PutAttachment(ctx context.Context, noteID string, attachment storage.Attachment, body io.Reader) (storage.Attachment, error) {
+	note, err := s.Get(ctx, noteID)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	note.Rev = "2-" + attachment.Name
+	if err := s.Update(ctx, note); err != nil {
+		return storage.Attachment{}, err
+	}
+	return s.InMemoryStorage.PutAttachment(ctx, noteID, attachment, body)
+}
+
+// TestAttachmentService tests the validation of attachments, and that cached notes are
+// invalidated when their attachments change
+func TestAttachmentService(t *testing.T) {
+	ctx := context.Background()
+	backend := revisingStorage{storage.NewInMemoryStorage()}
+	cache := storage.NewCachedStorage(backend, storage.NewLRUCache(10, time.Minute))
+	attachments := NewAttachmentService(backend, cache)
+	note := model.NewNote("Title", "Content")
+	if err := cache.Create(ctx, note); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := cache.Get(ctx, note.ID); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	for _, invalid := range []storage.Attachment{{Name: ""}, {Name: "_design"}, {Name: "a/b"}, {Name: "a.txt", ContentType: "text/"}} {
+		if _, err := attachments.Put(ctx, note.ID, invalid, strings.NewReader("x")); !errors.Is(err, ErrInvalidAttachment) {
+			t.Errorf("Expected ErrInvalidAttachment for %+v, got %v", invalid, err)
+		}
+	}
+
+	stored, err := attachments.Put(ctx, note.ID, storage.Attachment{Name: "data.bin"}, strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if stored.ContentType != "application/octet-stream" {
+		t.Errorf("Expected the default content type, got %q", stored.ContentType)
+	}
+	if cached, err := cache.Get(ctx, note.ID); err != nil || cached.Rev != "2-data.bin" {
+		t.Errorf("Expected the note with its new revision, got %+v: %v", cached, err)
+	}
+
+	if _, _, err := attachments.Get(ctx, note.ID, "../data.bin"); !errors.Is(err, storage.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound for an invalid name, got %v", err)
+	}
+	if err := attachments.Delete(ctx, note.ID, "data.bin"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+var (
+	// ErrNotJoined is returned for an edit by a participant that hasn't joined the session of the note.
+	ErrNotJoined = errors.New("not joined to the note")
+
+	// ErrInvalidEdit is returned (wrapped) for operations that can't be applied to a document.
+	ErrInvalidEdit = errors.New("invalid edit")
+)
+
+// collabCleanupRetry is the retry policy of removing the document of a deleted note.
+var collabCleanupRetry = storage.RetryPolicy{
+	MaxAttempts:    3,
+	InitialDelay:   time.Second,
+	MaxDelay:       10 * time.Second,
+	AttemptTimeout: 30 * time.Second,
+}
+
+// CollabState is what a participant receives when it joins the editing session of a note.
+type CollabState struct {
+	Site string    // Site name of the participant, for the IDs of the characters it inserts
+	Ops  []crdt.Op // Operations that rebuild the document of the note
+}
+
+// CollabService lets several participants edit the content of a note at the same time.
+// The content of a note being edited is a CRDT document (see package crdt): participants
+// apply their edits locally, send the operations, and receive the operations of the others,
+// so every copy converges to the same text without locking.
+//
+// The service merges the operations into its own copy of the document, stores the note with
+// the resulting text through the note service (so the update is validated and published like
+// any other), and stores the document in a repository of its own, by note ID. Changes made
+// to the content in another way (e.g., by a PUT request) are merged as an edit of the
+// service when the session is joined or edited next. It also implements events.Subscriber,
+// to remove the documents of deleted notes. It is safe for concurrent use.
+//
+// Sessions are held in memory by the instance the participants are connected to.
+type CollabService struct {
+	repository NoteRepository // Storage of the documents, by note ID
+	notes      *NoteService   // Service storing the text of the documents in the notes
+	site       string         // Site name of the service, for the edits it makes itself
+	runner     *jobs.Runner   // Runs the removal of the documents of deleted notes
+
+	mutex    sync.Mutex                // Protects sessions
+	sessions map[string]*collabSession // Active editing sessions, by note ID
+}
+
+// collabSession is the editing session of a note.
+type collabSession struct {
+	refs int // Participants joined or joining; protected by the service mutex
+
+	mutex        sync.Mutex                     // Serializes joins and edits
+	doc          *crdt.Doc                      // Document of the note; nil until loaded
+	participants map[string]func(ops []crdt.Op) // Receivers of the operations of others, by site
+}
+
+// NewCollabService creates a new CollabService.
+//
+// Parameters:
+//   - repository: The storage of the documents, separate from the storage of the notes
+//   - notes: The note service that stores the text of the documents
+//   - runner: The job runner that removes the documents of deleted notes
+//
+// Returns:
+//   - A pointer to a new CollabService instance
+func NewCollabService(repository NoteRepository, notes *NoteService, runner *jobs.Runner) *CollabService {
+	return &CollabService{
+		repository: repository,
+		notes:      notes,
+		runner:     runner,
+		site:       "server-" + newSiteName(),
+		sessions:   make(map[string]*collabSession),
+	}
+}
+
+// Join adds a participant to the editing session of a note. receive is called with the
+// operations of the other participants and of the service, until the participant leaves;
+// it must not block, since it is called while the session is locked.
+//
+// Returns:
+//   - The site name of the participant and the current document
+//   - storage.ErrNoteNotFound if the note doesn't exist, or the storage error
+func (s *CollabService) Join(ctx context.Context, noteID string, receive func(ops []crdt.Op)) (*CollabState, error) {
+	s.mutex.Lock()
+	session := s.sessions[noteID]
+	if session == nil {
+		session = &collabSession{participants: make(map[string]func(ops []crdt.Op))}
+		s.sessions[noteID] = session
+	}
+	session.refs++
+	s.mutex.Unlock()
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if _, err := s.sync(ctx, noteID, session); err != nil {
+		s.release(noteID, session)
+		return nil, err
+	}
+	site := newSiteName()
+	session.participants[site] = receive
+	return &CollabState{Site: site, Ops: session.doc.Ops()}, nil
+}
+
+// Edit applies the operations of a participant to the document of a note, stores the
+// note with the resulting text, and sends the operations to the other participants.
+// Characters must be inserted with the site name of the participant. If the note can't be
+// stored (e.g., the edit leaves it empty), the edit is undone by an edit of the service.
+//
+// Returns:
+//   - ErrNotJoined if the participant hasn't joined the session, an error wrapping
+//     ErrInvalidEdit if an operation is invalid, or the storage error. The operations
+//     before an invalid one are applied; the participant should join again to get the
+//     document of the service.
+func (s *CollabService) Edit(ctx context.Context, noteID, site string, ops []crdt.Op) error {
+	s.mutex.Lock()
+	session := s.sessions[noteID]
+	s.mutex.Unlock()
+	if session == nil {
+		return ErrNotJoined
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if _, ok := session.participants[site]; !ok {
+		return ErrNotJoined
+	}
+	for _, op := range ops {
+		if op.Type == crdt.OpInsert && op.ID.Site != site {
+			return fmt.Errorf("%w: characters must be inserted with the site name %s", ErrInvalidEdit, site)
+		}
+	}
+
+	note, err := s.sync(ctx, noteID, session)
+	if err != nil {
+		return err
+	}
+
+	applied, err := session.doc.Apply(ops)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidEdit, err)
+	}
+	if len(applied) == 0 {
+		return err
+	}
+	session.broadcast(site, applied)
+
+	// Based on the note read, so an update made in another way in the meantime isn't overwritten
+	input := NoteInput{Title: note.Title, Content: session.doc.Text(), Color: note.Color, Icon: note.Icon,
+		Tags: note.Tags, UpdatedAt: note.UpdatedAt}
+	if _, updateErr := s.notes.Update(ctx, noteID, input); updateErr != nil {
+		// Undo the edit, so the document matches the note again
+		session.broadcast("", session.doc.SetText(s.site, note.Content))
+		err = errors.Join(err, updateErr)
+	}
+	if saveErr := s.save(ctx, noteID, session.doc); saveErr != nil {
+		err = errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// Leave removes a participant from the editing session of a note. The session ends when
+// the last participant leaves; the document stays stored.
+func (s *CollabService) Leave(noteID, site string) {
+	s.mutex.Lock()
+	session := s.sessions[noteID]
+	s.mutex.Unlock()
+	if session == nil {
+		return
+	}
+
+	session.mutex.Lock()
+	_, joined := session.participants[site]
+	delete(session.participants, site)
+	session.mutex.Unlock()
+	if joined {
+		s.release(noteID, session)
+	}
+}
+
+// Notify removes the document of the note of a deleted event. The storage is written by a
+// "collab-cleanup" job, retried a few times if it fails, so the publisher isn't blocked.
+func (s *CollabService) Notify(ctx context.Context, event events.Event) {
+	if event.Type != events.NoteDeleted {
+		return
+	}
+	_, err := s.runner.Submit(ctx, jobs.Task{
+		Kind:  "collab-cleanup",
+		Retry: collabCleanupRetry,
+		Run: func(ctx context.Context) (any, error) {
+			if err := s.repository.Delete(ctx, event.NoteID); err != nil && !errors.Is(err, storage.ErrNoteNotFound) {
+				return nil, err
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		log.Printf("%sFailed to delete the collaborative document of note %s: %v",
+			requestid.LogPrefix(ctx), event.NoteID, err)
+	}
+}
+
+// release drops a reference to a session, ending it when there are none left.
+func (s *CollabService) release(noteID string, session *collabSession) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session.refs--
+	if session.refs == 0 && s.sessions[noteID] == session {
+		delete(s.sessions, noteID)
+	}
+}
+
+// sync loads the document of a session if needed, and merges changes made to the content of
+// the note in another way as an edit of the service; the session mutex must be held.
+//
+// Returns:
+//   - The current note
+//   - storage.ErrNoteNotFound if the note doesn't exist, or the storage error
+func (s *CollabService) sync(ctx context.Context, noteID string, session *collabSession) (*model.Note, error) {
+	note, err := s.notes.Get(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if session.doc == nil {
+		doc, err := s.load(ctx, noteID)
+		if err != nil {
+			return nil, err
+		}
+		session.doc = doc
+	}
+
+	if session.doc.Text() != note.Content {
+		if ops := session.doc.SetText(s.site, note.Content); len(ops) > 0 {
+			session.broadcast("", ops)
+			if err := s.save(ctx, noteID, session.doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return note, nil
+}
+
+// load reads the stored document of a note, or returns an empty document if there is none.
+func (s *CollabService) load(ctx context.Context, noteID string) (*crdt.Doc, error) {
+	stored, err := s.repository.Get(ctx, noteID)
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		return crdt.New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := crdt.New()
+	if err := json.Unmarshal([]byte(stored.Content), doc); err != nil {
+		return nil, fmt.Errorf("invalid collaborative document of note %s: %w", noteID, err)
+	}
+	return doc, nil
+}
+
+// save stores the document of a note.
+func (s *CollabService) save(ctx context.Context, noteID string, doc *crdt.Doc) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	stored := &model.Note{
+		ID:        noteID,
+		Content:   string(data),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.repository.Update(ctx, stored)
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		err = s.repository.Create(ctx, stored)
+	}
+	return err
+}
+
+// broadcast sends operations to the participants of a session, except the one with the
+// given site; the session mutex must be held.
+func (session *collabSession) broadcast(except string, ops []crdt.Op) {
+	for site, receive := range session.participants {
+		if site != except {
+			receive(ops)
+		}
+	}
+}
+
+// newSiteName generates a random site name for a participant.
+func newSiteName() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
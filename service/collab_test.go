@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/storage"
+)
+
+// participant is a client of a collaborative editing session, with its copy of the document
+type participant struct {
+	t    *testing.T
+	site string
+	doc  *crdt.Doc
+}
+
+// join joins the session of a note and builds the document from the state
+func join(t *testing.T, collab *CollabService, noteID string) *participant {
+	t.Helper()
+	p := &participant{t: t, doc: crdt.New()}
+	state, err := collab.Join(context.Background(), noteID, p.receive)
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	p.site = state.Site
+	p.receive(state.Ops)
+	return p
+}
+
+// receive applies the operations of others to the copy of the document
+func (p *participant) receive(ops []crdt.Op) {
+	if _, err := p.doc.Apply(ops); err != nil {
+		p.t.Errorf("Failed to apply the operations of others: %v", err)
+	}
+}
+
+// TestCollabService tests editing a note together, with edits made in another way merged in
+func TestCollabService(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	notes := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+	documents := storage.NewInMemoryStorage()
+	runner := jobs.NewRunner(1, 10)
+	collab := NewCollabService(documents, notes, runner)
+
+	note, err := notes.Create(ctx, NoteInput{Title: "Shared", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	alice := join(t, collab, note.ID)
+	bob := join(t, collab, note.ID)
+	if alice.doc.Text() != "Hi" || alice.site == bob.site {
+		t.Fatalf("Expected both to get the content with their own site, got %q, %q, and %q", alice.doc.Text(), alice.site, bob.site)
+	}
+
+	// An edit is stored in the note and sent to the others
+	if err := collab.Edit(ctx, note.ID, alice.site, alice.doc.SetText(alice.site, "Hi!")); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if stored, _ := notes.Get(ctx, note.ID); stored.Content != "Hi!" || stored.Title != "Shared" {
+		t.Errorf("Expected the note to be stored with the edit, got %+v", stored)
+	}
+	if bob.doc.Text() != "Hi!" {
+		t.Errorf("Expected Bob to receive the edit, got %q", bob.doc.Text())
+	}
+	if types := rec.types(); types[len(types)-1] != events.NoteUpdated {
+		t.Errorf("Expected the edit to be published, got %v", types)
+	}
+
+	// An update through the note service is merged with a concurrent edit
+	if _, err := notes.Update(ctx, note.ID, NoteInput{Title: "Shared", Content: "Hello"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := collab.Edit(ctx, note.ID, bob.site, bob.doc.SetText(bob.site, "Hi!?")); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	stored, _ := notes.Get(ctx, note.ID)
+	if stored.Content != "Hello?" || alice.doc.Text() != stored.Content || bob.doc.Text() != stored.Content {
+		t.Errorf("Expected everyone to converge to %q, got %q, %q, and %q", "Hello?", stored.Content, alice.doc.Text(), bob.doc.Text())
+	}
+
+	// Invalid edits are rejected
+	forged := []crdt.Op{{Type: crdt.OpInsert, ID: crdt.ID{Clock: 100, Site: bob.site}, Text: "x"}}
+	if err := collab.Edit(ctx, note.ID, alice.site, forged); !errors.Is(err, ErrInvalidEdit) {
+		t.Errorf("Expected ErrInvalidEdit for an insertion with another site, got %v", err)
+	}
+	unknown := []crdt.Op{{Type: crdt.OpDelete, ID: crdt.ID{Clock: 100, Site: bob.site}}}
+	if err := collab.Edit(ctx, note.ID, alice.site, unknown); !errors.Is(err, ErrInvalidEdit) {
+		t.Errorf("Expected ErrInvalidEdit for an unknown element, got %v", err)
+	}
+	if err := collab.Edit(ctx, note.ID, "stranger", nil); !errors.Is(err, ErrNotJoined) {
+		t.Errorf("Expected ErrNotJoined, got %v", err)
+	}
+	if _, err := collab.Join(ctx, "missing", func([]crdt.Op) {}); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+
+	// The session ends when everyone has left; the document is loaded again on the next join
+	collab.Leave(note.ID, alice.site)
+	collab.Leave(note.ID, bob.site)
+	if len(collab.sessions) != 0 {
+		t.Errorf("Expected no sessions after everyone left, got %d", len(collab.sessions))
+	}
+	carol := join(t, collab, note.ID)
+	if carol.doc.Text() != "Hello?" || carol.doc.Clock() != alice.doc.Clock() {
+		t.Errorf("Expected the stored document, got %q with clock %d", carol.doc.Text(), carol.doc.Clock())
+	}
+	collab.Leave(note.ID, carol.site)
+
+	// The document is removed with the note
+	if err := notes.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	collab.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: note.ID})
+	if err := runner.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := documents.Get(ctx, note.ID); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected the document to be deleted with the note, got %v", err)
+	}
+}
+
+// TestCollabService_Undo tests that an edit that can't be stored is undone for everyone
+func TestCollabService_Undo(t *testing.T) {
+	ctx := context.Background()
+	notes := New(storage.NewInMemoryStorage())
+	collab := NewCollabService(storage.NewInMemoryStorage(), notes, jobs.NewRunner(1, 10))
+
+	note, err := notes.Create(ctx, NoteInput{Content: "x"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	alice := join(t, collab, note.ID)
+	bob := join(t, collab, note.ID)
+
+	// Without a title, deleting all content leaves an invalid note
+	if err := collab.Edit(ctx, note.ID, alice.site, alice.doc.SetText(alice.site, "")); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote, got %v", err)
+	}
+	if alice.doc.Text() != "x" || bob.doc.Text() != "x" {
+		t.Errorf("Expected the edit to be undone for everyone, got %q and %q", alice.doc.Text(), bob.doc.Text())
+	}
+	if stored, _ := notes.Get(ctx, note.ID); stored.Content != "x" {
+		t.Errorf("Expected the note to be unchanged, got %q", stored.Content)
+	}
+}
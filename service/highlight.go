@@ -0,0 +1,159 @@
+package service
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/textnorm"
+)
+
+// Highlighting limits.
+const (
+	fragmentSize    = 160 // Length of a fragment of the content, in characters
+	maxFragments    = 3   // Maximum number of fragments of the content per result
+	minHighlightLen = 2   // Minimum length of a highlighted word of the query, in characters
+)
+
+// Highlight is a fragment of a field of a note found by a search, with the places where
+// the query matches it.
+type Highlight struct {
+	Field    string   `json:"field"`             // "title" or "content"
+	Fragment string   `json:"fragment"`          // The whole title, or a part of the content cut at word boundaries
+	Matches  [][2]int `json:"matches,omitempty"` // Start and end of every match in the fragment, in characters (Unicode code points), end excluded
+}
+
+// HTML returns the fragment escaped for HTML, with every match wrapped in <em> tags.
+func (h Highlight) HTML() string {
+	runes := []rune(h.Fragment)
+	var b strings.Builder
+	last := 0
+	for _, match := range h.Matches {
+		b.WriteString(html.EscapeString(string(runes[last:match[0]])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(runes[match[0]:match[1]])))
+		b.WriteString("</em>")
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(string(runes[last:])))
+	return b.String()
+}
+
+// highlight returns the highlights of a note for a query: the title if it matches, and up
+// to maxFragments fragments of the content around its matches. The query matches where
+// it appears as a whole, or where any of its words does, ignoring case and accents, so
+// semantic searches get highlights too when the note shares words with the query.
+func highlight(note *model.Note, query string) []Highlight {
+	terms := highlightTerms(query)
+	var highlights []Highlight
+	if matches := findTerms([]rune(note.Title), terms); len(matches) > 0 {
+		highlights = append(highlights, Highlight{Field: "title", Fragment: note.Title, Matches: matches})
+	}
+	content := []rune(note.Content)
+	matches := findTerms(content, terms)
+	for i, fragments := 0, 0; i < len(matches) && fragments < maxFragments; fragments++ {
+		start, end := fragmentBounds(content, matches[i])
+		h := Highlight{Field: "content", Fragment: string(content[start:end])}
+		for ; i < len(matches) && matches[i][0] < end; i++ {
+			// A match cut by the end of the fragment is highlighted up to it
+			h.Matches = append(h.Matches, [2]int{matches[i][0] - start, min(matches[i][1], end) - start})
+		}
+		highlights = append(highlights, h)
+	}
+	return highlights
+}
+
+// highlightTerms returns the terms of a query that are highlighted, without case and accents
+// (see textnorm.Unaccent): the query itself and its words, longest first, so the longest
+// term matching at a position wins.
+func highlightTerms(query string) [][]rune {
+	seen := make(map[string]bool)
+	var terms [][]rune
+	add := func(term string) {
+		term = textnorm.Unaccent(term)
+		if len([]rune(term)) >= minHighlightLen && !seen[term] {
+			seen[term] = true
+			terms = append(terms, []rune(term))
+		}
+	}
+	add(strings.TrimSpace(query))
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		add(word)
+	}
+	slices.SortStableFunc(terms, func(a, b []rune) int { return len(b) - len(a) })
+	return terms
+}
+
+// findTerms returns the start and end of every match of the terms in a text, ignoring
+// case and accents, in order and without overlaps. A match includes the combining marks
+// that follow it.
+func findTerms(text []rune, terms [][]rune) [][2]int {
+	if len(terms) == 0 {
+		return nil
+	}
+	folded, origins := textnorm.Runes(string(text))
+	// origin returns the character of the text where the folded character at i starts
+	origin := func(i int) int {
+		if i == len(folded) {
+			return len(text)
+		}
+		return origins[i]
+	}
+	var matches [][2]int
+	for i := 0; i < len(folded); {
+		matched := 0
+		for _, term := range terms {
+			if len(term) <= len(folded)-i && slices.Equal(folded[i:i+len(term)], term) {
+				matched = len(term)
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+		// A match can end inside a character that folds to several (ß), which it then includes
+		start := origin(i)
+		matches = append(matches, [2]int{start, max(origin(i+matched), start+1)})
+		i += matched
+	}
+	return matches
+}
+
+// fragmentBounds returns the start and end of the fragment of a text around a match: about
+// fragmentSize characters with the match in the middle, cut at word boundaries.
+func fragmentBounds(text []rune, match [2]int) (int, int) {
+	start := max(0, match[0]-max(0, fragmentSize-(match[1]-match[0]))/2)
+	end := min(len(text), start+fragmentSize)
+	start = max(0, min(start, end-fragmentSize))
+
+	// Don't start or end in the middle of a word, unless the match is there
+	if start > 0 {
+		for i := start; i < match[0]; i++ {
+			if unicode.IsSpace(text[i-1]) {
+				start = i
+				break
+			}
+		}
+	}
+	if end < len(text) {
+		for i := end; i > match[1]; i-- {
+			if unicode.IsSpace(text[i]) {
+				end = i
+				break
+			}
+		}
+	}
+	// Leading and trailing spaces aren't part of the context
+	for start < match[0] && unicode.IsSpace(text[start]) {
+		start++
+	}
+	for end > match[1] && unicode.IsSpace(text[end-1]) {
+		end--
+	}
+	return start, end
+}
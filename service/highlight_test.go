@@ -0,0 +1,61 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+)
+
+// TestHighlight tests the fragments around the matches of a query, ignoring case, and
+// their HTML form
+func TestHighlight(t *testing.T) {
+	note := &model.Note{
+		Title:   "Tomato <garden>",
+		Content: strings.Repeat("filler ", 40) + "Water the Tomatoes in the GARDEN every morning. " + strings.Repeat("more ", 40) + "Tomato soup.",
+	}
+	highlights := highlight(note, "tomato garden")
+	if len(highlights) != 3 {
+		t.Fatalf("Expected the title and 2 fragments of the content, got %+v", highlights)
+	}
+
+	title := highlights[0]
+	if title.Field != "title" || title.Fragment != note.Title || !reflect.DeepEqual(title.Matches, [][2]int{{0, 6}, {8, 14}}) {
+		t.Errorf("Unexpected title highlight: %+v", title)
+	}
+	if html := title.HTML(); html != "<em>Tomato</em> &lt;<em>garden</em>&gt;" {
+		t.Errorf("Unexpected HTML: %s", html)
+	}
+
+	content := highlights[1]
+	runes := []rune(content.Fragment)
+	if content.Field != "content" || len(runes) > fragmentSize || strings.HasPrefix(content.Fragment, " ") ||
+		strings.HasPrefix(content.Fragment, "iller") || len(content.Matches) != 2 {
+		t.Fatalf("Unexpected content highlight: %+v", content)
+	}
+	for i, expected := range []string{"Tomato", "GARDEN"} {
+		match := content.Matches[i]
+		if got := string(runes[match[0]:match[1]]); got != expected {
+			t.Errorf("Expected match %q, got %q", expected, got)
+		}
+	}
+	if last := highlights[2]; !strings.HasSuffix(last.Fragment, "Tomato soup.") || len(last.Matches) != 1 {
+		t.Errorf("Unexpected last highlight: %+v", last)
+	}
+
+	// Offsets are in characters, and the whole query wins over its words
+	highlights = highlight(&model.Note{Title: "Ça été un crème brûlée"}, "CRÈME BRÛLÉE")
+	if len(highlights) != 1 || !reflect.DeepEqual(highlights[0].Matches, [][2]int{{10, 22}}) {
+		t.Errorf("Unexpected highlights: %+v", highlights)
+	}
+
+	// Accents are ignored too, and a match includes the combining accents that follow it
+	highlights = highlight(&model.Note{Title: "Un cafe\u0301 au Café"}, "cafe")
+	if len(highlights) != 1 || !reflect.DeepEqual(highlights[0].Matches, [][2]int{{3, 8}, {12, 16}}) {
+		t.Errorf("Unexpected highlights: %+v", highlights)
+	}
+	if highlights := highlight(note, "a"); len(highlights) != 0 {
+		t.Errorf("Expected no highlights for a single letter, got %+v", highlights)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// linkPattern matches wiki-style links: [[target]] or [[target|label]].
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// ParseLinks returns the targets of the wiki-style links in a note's content, in order of
+// first appearance and without duplicates. A link is written as [[note-id]] or [[title]],
+// optionally followed by a label that is ignored ([[title|label]]).
+func ParseLinks(content string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	for _, match := range linkPattern.FindAllStringSubmatch(content, -1) {
+		target, _, _ := strings.Cut(match[1], "|")
+		target = strings.TrimSpace(target)
+		key := linkKey(target)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// linkKey normalizes a link target, an ID, or a title for matching: links match
+// a note by its ID or, case-insensitively, by its title.
+func linkKey(target string) string {
+	return strings.ToLower(strings.TrimSpace(target))
+}
+
+// LinkGraph indexes the wiki-style links between notes, so the backlinks of a note can be
+// found without reading all notes. The links of every note are parsed when it is written;
+// targets are resolved when backlinks are requested, so a link by title finds the note
+// that has the title at that time, even if it was created after the link.
+//
+// The graph is built from the storage on first use and then kept current by the note
+// service. It also implements events.Subscriber, so it can follow changes published by
+// a change feed, including those of other instances. It is safe for concurrent use.
+type LinkGraph struct {
+	mutex    sync.Mutex
+	loaded   bool                       // Whether the graph has been built from the storage
+	outgoing map[string][]string        // Normalized link targets by ID of the linking note
+	incoming map[string]map[string]bool // IDs of the linking notes by normalized link target
+}
+
+// NewLinkGraph creates an empty link graph, which is built from the storage on first use.
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{}
+}
+
+// Notify updates the links of the note of a created, updated, or deleted event.
+// Events without the note (e.g., from a change feed that only reports IDs) are ignored.
+func (g *LinkGraph) Notify(ctx context.Context, event events.Event) {
+	switch event.Type {
+	case events.NoteCreated, events.NoteUpdated:
+		if event.Note != nil {
+			g.set(event.Note)
+		}
+	case events.NoteDeleted:
+		g.remove(event.NoteID)
+	}
+}
+
+// set replaces the outgoing links of a note with the links in its content.
+// Before the graph is built, changes are ignored, since building reads them from the storage.
+func (g *LinkGraph) set(note *model.Note) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.loaded {
+		g.index(note)
+	}
+}
+
+// remove removes the outgoing links of a note.
+func (g *LinkGraph) remove(id string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.loaded {
+		g.unindex(id)
+	}
+}
+
+// index replaces the outgoing links of a note; the mutex must be held.
+func (g *LinkGraph) index(note *model.Note) {
+	g.unindex(note.ID)
+
+	var targets []string
+	for _, target := range ParseLinks(note.Content) {
+		targets = append(targets, linkKey(target))
+	}
+	if len(targets) == 0 {
+		return
+	}
+	g.outgoing[note.ID] = targets
+	for _, target := range targets {
+		if g.incoming[target] == nil {
+			g.incoming[target] = make(map[string]bool)
+		}
+		g.incoming[target][note.ID] = true
+	}
+}
+
+// unindex removes the outgoing links of a note; the mutex must be held.
+func (g *LinkGraph) unindex(id string) {
+	for _, target := range g.outgoing[id] {
+		delete(g.incoming[target], id)
+		if len(g.incoming[target]) == 0 {
+			delete(g.incoming, target)
+		}
+	}
+	delete(g.outgoing, id)
+}
+
+// linking returns the IDs of the notes linking to a note by its ID or title, sorted,
+// building the graph from the repository first if needed. Writes wait while the graph
+// is being built, so none of them is lost.
+func (g *LinkGraph) linking(ctx context.Context, repository NoteRepository, note *model.Note) ([]string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.loaded {
+		g.outgoing = make(map[string][]string)
+		g.incoming = make(map[string]map[string]bool)
+		err := storage.Stream(ctx, repository, func(n *model.Note) error {
+			g.index(n)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		g.loaded = true
+	}
+
+	var ids []string
+	for _, key := range []string{linkKey(note.ID), linkKey(note.Title)} {
+		for id := range g.incoming[key] {
+			if id != note.ID && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Backlinks returns the notes that link to a note, by its ID or by its title, sorted by
+// title. Links of a note to itself are not included.
+//
+// Returns:
+//   - The linking notes; empty if there are none
+//   - storage.ErrNoteNotFound if the note doesn't exist, or the storage error
+func (s *NoteService) Backlinks(ctx context.Context, id string) ([]*model.Note, error) {
+	note, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.links.linking(ctx, s.repository, note)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notes deleted since they were indexed (e.g., by another instance) are skipped
+	notes, err := storage.GetMany(ctx, s.repository, ids)
+	if err != nil {
+		return nil, err
+	}
+	sortByTitle(notes)
+	return notes, nil
+}
+
+// BacklinksMany returns the notes that link to each of several notes, sorted by title, by
+// note ID, like Backlinks. The linking notes of all of them are read at once.
+//
+// Returns:
+//   - The linking notes of every note; empty for notes without backlinks
+//   - The storage error
+func (s *NoteService) BacklinksMany(ctx context.Context, notes []*model.Note) (map[string][]*model.Note, error) {
+	linking := make(map[string][]string, len(notes))
+	var ids []string
+	for _, note := range notes {
+		noteIDs, err := s.links.linking(ctx, s.repository, note)
+		if err != nil {
+			return nil, err
+		}
+		linking[note.ID] = noteIDs
+		ids = append(ids, noteIDs...)
+	}
+
+	found, err := storage.GetMany(ctx, s.repository, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.Note, len(found))
+	for _, note := range found {
+		byID[note.ID] = note
+	}
+
+	backlinks := make(map[string][]*model.Note, len(notes))
+	for _, note := range notes {
+		list := make([]*model.Note, 0, len(linking[note.ID]))
+		for _, id := range linking[note.ID] {
+			if linked, ok := byID[id]; ok {
+				list = append(list, linked)
+			}
+		}
+		sortByTitle(list)
+		backlinks[note.ID] = list
+	}
+	return backlinks, nil
+}
+
+// sortByTitle sorts notes by title, keeping the order of notes with the same title.
+func sortByTitle(notes []*model.Note) {
+	slices.SortStableFunc(notes, func(a, b *model.Note) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+}
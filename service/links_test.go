@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// TestParseLinks tests parsing wiki-style links from note contents
+func TestParseLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"None", "No links here, not even [single] brackets", nil},
+		{"ID and title", "See [[20230415123045.123456.abcd1234]] and [[Shopping List]].", []string{"20230415123045.123456.abcd1234", "Shopping List"}},
+		{"Label", "[[Shopping List|what to buy]]", []string{"Shopping List"}},
+		{"Whitespace", "[[  Shopping List ]]", []string{"Shopping List"}},
+		{"Duplicates", "[[Todo]] then [[todo]] and [[Todo|again]]", []string{"Todo"}},
+		{"Empty", "[[]] [[ ]] [[|label]]", nil},
+		{"Across lines", "[[Shopping\nList]]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseLinks(tt.content); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// backlinkIDs returns the IDs of the backlinks of a note
+func backlinkIDs(t *testing.T, s *NoteService, id string) []string {
+	t.Helper()
+	notes, err := s.Backlinks(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Backlinks failed: %v", err)
+	}
+	ids := make([]string, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+	}
+	return ids
+}
+
+// TestNoteService_Backlinks tests that backlinks follow the writes made through the service
+func TestNoteService_Backlinks(t *testing.T) {
+	ctx := context.Background()
+	repository := storage.NewInMemoryStorage()
+
+	// Notes stored before the graph is built are indexed on first use
+	existing := &model.Note{ID: "existing", Title: "Existing", Content: "Links to [[Target]]"}
+	if err := repository.Create(ctx, existing); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	s := New(repository)
+
+	target, err := s.Create(ctx, NoteInput{Title: "Target", Content: "Links to [[Target]] itself"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := backlinkIDs(t, s, target.ID); !slices.Equal(got, []string{"existing"}) {
+		t.Errorf("Expected the existing note to link by title, got %v", got)
+	}
+
+	byID, err := s.Create(ctx, NoteInput{Title: "By ID", Content: "Links to [[" + target.ID + "]]"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := backlinkIDs(t, s, target.ID); !slices.Equal(got, []string{byID.ID, "existing"}) {
+		t.Errorf("Expected the new note to link by ID, got %v", got)
+	}
+
+	// Renaming the target breaks the links by title, but not those by ID
+	if _, err := s.Update(ctx, target.ID, NoteInput{Title: "Renamed"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := backlinkIDs(t, s, target.ID); !slices.Equal(got, []string{byID.ID}) {
+		t.Errorf("Expected only the link by ID after renaming, got %v", got)
+	}
+
+	// Removing a link or deleting the linking note removes the backlink
+	if _, err := s.Update(ctx, existing.ID, NoteInput{Content: "Links to [[Renamed]]"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := s.Delete(ctx, byID.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if got := backlinkIDs(t, s, target.ID); !slices.Equal(got, []string{"existing"}) {
+		t.Errorf("Expected only the updated link, got %v", got)
+	}
+
+	if _, err := s.Backlinks(ctx, "missing"); err != storage.ErrNoteNotFound {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+}
+
+// TestLinkGraph_Notify tests that a shared graph follows changes published by others
+func TestLinkGraph_Notify(t *testing.T) {
+	ctx := context.Background()
+	repository := storage.NewInMemoryStorage()
+	links := NewLinkGraph()
+	s := New(repository, WithLinkGraph(links))
+
+	target, err := s.Create(ctx, NoteInput{Title: "Target"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := backlinkIDs(t, s, target.ID); len(got) != 0 {
+		t.Fatalf("Expected no backlinks, got %v", got)
+	}
+
+	// A note written by another instance, reported by a change feed
+	other := &model.Note{ID: "other", Title: "Other", Content: "[[target]]", CreatedAt: time.Now()}
+	if err := repository.Create(ctx, other); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	links.Notify(ctx, events.Event{Type: events.NoteCreated, NoteID: other.ID, Note: other})
+	if got := backlinkIDs(t, s, target.ID); !slices.Equal(got, []string{"other"}) {
+		t.Errorf("Expected the published note to link, got %v", got)
+	}
+
+	if err := repository.Delete(ctx, other.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	links.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: other.ID})
+	if got := backlinkIDs(t, s, target.ID); len(got) != 0 {
+		t.Errorf("Expected no backlinks after the deletion, got %v", got)
+	}
+}
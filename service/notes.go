@@ -0,0 +1,624 @@
+// Package service implements the business logic of notes, shared by the REST and gRPC APIs:
+// validation, ID generation, timestamps, links between notes, and publishing note events.
+// The transports only translate between their wire formats and the service, so both behave
+// the same.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// ErrInvalidNote is returned (wrapped) when a note fails validation.
+// The error message describes what is wrong, so it can be shown to clients.
+var ErrInvalidNote = errors.New("invalid note")
+
+// ErrImmutableField is returned (wrapped) when an update tries to change a field that
+// the server sets once, such as the creation time of a note.
+var ErrImmutableField = errors.New("immutable field")
+
+// NoteInput holds the fields of a note that clients can set.
+type NoteInput struct {
+	Title   string   // Title of the note
+	Content string   // Content/body of the note
+	Color   string   // Color of the note, as #rrggbb (optional)
+	Icon    string   // Icon of the note, one of Icons (optional)
+	Tags    []string // Tags of the note, normalized when the note is stored (optional)
+	Rev     string   // Revision an update is based on, to detect concurrent modifications (optional)
+
+	// UpdatedAt is the update time of the note an update is based on; if set, the update
+	// fails with storage.ErrConflict on any backend if the note was updated since (optional)
+	UpdatedAt time.Time
+
+	// CreatedAt is the creation time a client sent with an update, if any; the update fails
+	// with ErrImmutableField unless it is the note's (optional)
+	CreatedAt time.Time
+}
+
+// NoteService creates, reads, updates, and deletes notes. Storage errors are returned
+// unchanged (e.g., storage.ErrNoteNotFound, storage.ErrConflict, or a
+// storage.CircuitOpenError), so transports can map them to their status codes.
+// It implements the Notes port.
+type NoteService struct {
+	repository NoteRepository // Storage port for notes
+	publisher  EventPublisher // Events port receiving note events (optional)
+	links      *LinkGraph     // Wiki-style links between notes, for backlinks
+	quotas     *Quotas        // Limits of the notes of every owner (optional)
+	summarizer Summarizer     // Summarizer of the notes, for Summarize (optional)
+	views      *ViewCounter   // Counter of the views of the notes (optional)
+
+	now func() time.Time // Clock, replaced in tests
+}
+
+// Option configures optional features of a NoteService.
+type Option func(*NoteService)
+
+// WithPublisher publishes a note event after every successful creation, update, and
+// deletion. Leave it out if another source publishes the changes (e.g., a change feed).
+func WithPublisher(publisher EventPublisher) Option {
+	return func(s *NoteService) {
+		s.publisher = publisher
+	}
+}
+
+// WithLinkGraph indexes the links between notes in the given graph, instead of a graph of
+// the service's own. Share a graph that also follows a change feed (see LinkGraph.Notify)
+// to see the links written by other instances.
+func WithLinkGraph(links *LinkGraph) Option {
+	return func(s *NoteService) {
+		s.links = links
+	}
+}
+
+// WithQuotas limits the number and total size of the notes of every owner. Notes are
+// created for the owner of the request (see ContextWithOwner), and count against the
+// quota of that owner until they are deleted. Writes that would exceed a limit fail
+// with a *QuotaError.
+func WithQuotas(quotas *Quotas) Option {
+	return func(s *NoteService) {
+		s.quotas = quotas
+	}
+}
+
+// New creates a new NoteService on top of a storage backend.
+//
+// Parameters:
+//   - repository: The storage backend (any storage.NoteStorage)
+//   - opts: Optional features to enable (e.g., WithPublisher)
+//
+// Returns:
+//   - A pointer to a new NoteService instance
+func New(repository NoteRepository, opts ...Option) *NoteService {
+	s := &NoteService{repository: repository, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.links == nil {
+		s.links = NewLinkGraph()
+	}
+	return s
+}
+
+// Create creates a note with a generated ID and the current time as its creation
+// and update time, for the owner of the request.
+//
+// Returns:
+//   - The created note
+//   - An error wrapping ErrInvalidNote if the input is invalid, a *QuotaError if the
+//     owner's quota is exceeded, or the storage error
+func (s *NoteService) Create(ctx context.Context, input NoteInput) (*model.Note, error) {
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	note := s.newNote(input)
+	note.Owner = OwnerFromContext(ctx)
+	if err := s.charge(ctx, note.Owner, 1, noteSize(note)); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Create(ctx, note); err != nil {
+		s.refund(ctx, note.Owner, 1, noteSize(note))
+		return nil, err
+	}
+
+	s.links.set(note)
+	s.publish(ctx, events.NoteCreated, note)
+	return note, nil
+}
+
+// Duplicate creates a copy of a note with a generated ID, fresh timestamps, and
+// " (copy)" appended to its title. The copy is published like any created note.
+//
+// Returns:
+//   - The created copy
+//   - storage.ErrNoteNotFound if the note doesn't exist, or the storage error
+func (s *NoteService) Duplicate(ctx context.Context, id string) (*model.Note, error) {
+	original, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	title := "(copy)"
+	if original.Title != "" {
+		title = original.Title + " (copy)"
+	}
+	return s.Create(ctx, NoteInput{Title: title, Content: original.Content, Color: original.Color, Icon: original.Icon,
+		Tags: original.Tags})
+}
+
+// Get retrieves a note by its ID.
+func (s *NoteService) Get(ctx context.Context, id string) (*model.Note, error) {
+	return s.repository.Get(ctx, id)
+}
+
+// View retrieves a note for a client, like Get, and counts the view if views are counted
+// (see WithViewCounter). The note has its views, including this one.
+func (s *NoteService) View(ctx context.Context, id string) (*model.Note, error) {
+	note, err := s.repository.Get(ctx, id)
+	if err != nil || s.views == nil {
+		return note, err
+	}
+	s.views.Record(id)
+	notes := []*model.Note{note}
+	if err := s.views.attach(ctx, notes); err != nil {
+		return nil, err
+	}
+	return notes[0], nil
+}
+
+// Exists reports whether a note exists, without reading it where the storage supports it
+// (see storage.Exists).
+func (s *NoteService) Exists(ctx context.Context, id string) (bool, error) {
+	return storage.Exists(ctx, s.repository, id)
+}
+
+// GetMany retrieves the notes with the given IDs, in the order of the IDs, with a single
+// read where the storage supports it (see storage.GetMany).
+func (s *NoteService) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	return storage.GetMany(ctx, s.repository, ids)
+}
+
+// GetAll retrieves all notes.
+func (s *NoteService) GetAll(ctx context.Context) ([]*model.Note, error) {
+	return s.repository.GetAll(ctx)
+}
+
+// List retrieves the notes matching a list query (see storage.List), with their views if
+// they are counted.
+func (s *NoteService) List(ctx context.Context, opts storage.ListOptions) ([]*model.Note, error) {
+	if s.views == nil {
+		return storage.List(ctx, s.repository, opts)
+	}
+	if opts.Sort != storage.SortMostViewed {
+		notes, err := storage.List(ctx, s.repository, opts)
+		if err != nil {
+			return nil, err
+		}
+		return notes, s.views.attach(ctx, notes)
+	}
+
+	// Every matching note is read, to be sorted and paginated once its views are known
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	notes, err := storage.List(ctx, s.repository, storage.ListOptions{Query: opts.Query, MatchAccents: opts.MatchAccents, Filter: opts.Filter})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.views.attach(ctx, notes); err != nil {
+		return nil, err
+	}
+	page := storage.ListOptions{Sort: opts.Sort, Descending: opts.Descending, Limit: opts.Limit, Offset: opts.Offset}
+	return page.Apply(notes), nil
+}
+
+// Count returns the number of notes matching the query of a list, regardless of its
+// pagination (see storage.Count).
+func (s *NoteService) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	return storage.Count(ctx, s.repository, opts)
+}
+
+// Stats returns statistics about all notes (see storage.Stats).
+func (s *NoteService) Stats(ctx context.Context) (storage.NoteStats, error) {
+	return storage.Stats(ctx, s.repository)
+}
+
+// Stream calls fn for every note, in an unspecified order (see storage.Stream).
+func (s *NoteService) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	return storage.Stream(ctx, s.repository, fn)
+}
+
+// StreamList calls fn for every note matching a list query, one at a time where the query
+// allows it (see storage.StreamList), with their views if they are counted. The views are
+// read for viewBatchSize notes at a time.
+func (s *NoteService) StreamList(ctx context.Context, opts storage.ListOptions, fn func(note *model.Note) error) error {
+	if s.views == nil {
+		return storage.StreamList(ctx, s.repository, opts, fn)
+	}
+	if opts.Sort == storage.SortMostViewed {
+		notes, err := s.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batch := make([]*model.Note, 0, viewBatchSize)
+	send := func() error {
+		if err := s.views.attach(ctx, batch); err != nil {
+			return err
+		}
+		for _, note := range batch {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err := storage.StreamList(ctx, s.repository, opts, func(note *model.Note) error {
+		if batch = append(batch, note); len(batch) == viewBatchSize {
+			return send()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return send()
+}
+
+// Update sets the title and content of an existing note and its update time to now.
+// The creation time is kept, and must be the input's creation time, if it has one.
+// If the input has a revision, the update fails with storage.ErrConflict on backends
+// that track revisions, unless it is still current. If the input has an update time,
+// the note is compared and swapped (see storage.UpdateIf), so the update fails with
+// storage.ErrConflict if the note was updated since.
+//
+// Returns:
+//   - The updated note
+//   - An error wrapping ErrInvalidNote if the input is invalid, an error wrapping
+//     ErrImmutableField if it has another creation time, or the storage error
+//     (storage.ErrNoteNotFound if the note doesn't exist)
+func (s *NoteService) Update(ctx context.Context, id string, input NoteInput) (*model.Note, error) {
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	note, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !input.CreatedAt.IsZero() && !input.CreatedAt.Equal(note.CreatedAt) {
+		return nil, fmt.Errorf("%w: created_at cannot be changed", ErrImmutableField)
+	}
+	// Work on a copy, so a cached note is never modified in place
+	updated := *note
+	updated.Title = input.Title
+	updated.Content = input.Content
+	updated.Color = input.Color
+	updated.Icon = input.Icon
+	updated.Tags = input.Tags
+	// The creation time is kept, in UTC for notes stored by older versions
+	updated.CreatedAt = note.CreatedAt.UTC()
+	updated.UpdatedAt = updateTime(note, s.now())
+	// A summary describes the content it was made from
+	if updated.Content != note.Content {
+		updated.Summary = ""
+	}
+	if input.Rev != "" {
+		updated.Rev = input.Rev
+	}
+
+	// Growth counts against the quota of the note's owner, whoever updates it; it is charged
+	// before the update, so it can be rejected, and shrinking is released after the update
+	grown := noteSize(&updated) - noteSize(note)
+	if err := s.charge(ctx, note.Owner, 0, max(0, grown)); err != nil {
+		return nil, err
+	}
+	if input.UpdatedAt.IsZero() {
+		err = s.repository.Update(ctx, &updated)
+	} else {
+		err = storage.UpdateIf(ctx, s.repository, &updated, input.UpdatedAt)
+	}
+	if err != nil {
+		s.refund(ctx, note.Owner, 0, max(0, grown))
+		return nil, err
+	}
+	s.refund(ctx, note.Owner, 0, max(0, -grown))
+
+	s.links.set(&updated)
+	s.publish(ctx, events.NoteUpdated, &updated)
+	return &updated, nil
+}
+
+// Upsert updates a note like Update, or creates it with the given ID if it doesn't exist,
+// with the current time as its creation and update time. Updates based on a revision or
+// an update time (see NoteInput) expect an existing note, so they are never creations.
+//
+// Returns:
+//   - The stored note
+//   - Whether the note was created
+//   - An error wrapping ErrInvalidNote if the ID or input is invalid, or the storage error
+//     (storage.ErrNoteNotFound only if the update is based on a revision or update time)
+func (s *NoteService) Upsert(ctx context.Context, id string, input NoteInput) (*model.Note, bool, error) {
+	note, err := s.Update(ctx, id, input)
+	if !errors.Is(err, storage.ErrNoteNotFound) || input.Rev != "" || !input.UpdatedAt.IsZero() {
+		return note, false, err
+	}
+	if err := validateID(id); err != nil {
+		return nil, false, err
+	}
+	// Normalize the color and tags again, since Update normalized a copy of the input
+	if err := validateInput(&input); err != nil {
+		return nil, false, err
+	}
+
+	note = s.newNote(input)
+	note.ID = id
+	note.Owner = OwnerFromContext(ctx)
+	if err := s.charge(ctx, note.Owner, 1, noteSize(note)); err != nil {
+		return nil, false, err
+	}
+	// Upserted, so a note created concurrently since the update is replaced rather than failing
+	created, err := storage.Upsert(ctx, s.repository, note)
+	if err != nil {
+		s.refund(ctx, note.Owner, 1, noteSize(note))
+		return nil, false, err
+	}
+
+	s.links.set(note)
+	if created {
+		s.publish(ctx, events.NoteCreated, note)
+	} else {
+		s.publish(ctx, events.NoteUpdated, note)
+	}
+	return note, created, nil
+}
+
+// Delete deletes a note by its ID. With quotas, the note is read first, to release
+// its size from the quota of its owner.
+func (s *NoteService) Delete(ctx context.Context, id string) error {
+	var deleted *model.Note
+	if s.quotas != nil {
+		note, err := s.repository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		deleted = note
+	}
+	if err := s.repository.Delete(ctx, id); err != nil {
+		return err
+	}
+	if deleted != nil {
+		s.refund(ctx, deleted.Owner, 1, noteSize(deleted))
+	}
+
+	s.links.remove(id)
+	if s.publisher != nil {
+		s.publisher.Publish(ctx, events.Event{
+			Type:      events.NoteDeleted,
+			NoteID:    id,
+			Timestamp: time.Now().UTC(),
+		})
+	}
+	return nil
+}
+
+// Purge deletes all notes. Notes are deleted one at a time through Delete, so every
+// deletion is published and decorating storages (e.g., caches) stay consistent. Notes
+// deleted concurrently by someone else are skipped.
+//
+// Returns:
+//   - The number of notes deleted
+//   - The storage error; the notes deleted before it stay deleted
+func (s *NoteService) Purge(ctx context.Context) (int, error) {
+	// Collect the IDs first, so no deletion happens while the storage is being read
+	var ids []string
+	err := s.Stream(ctx, func(note *model.Note) error {
+		ids = append(ids, note.ID)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err := s.Delete(ctx, id); err != nil {
+			if errors.Is(err, storage.ErrNoteNotFound) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Import stores a note with its own ID and timestamps, e.g., one read from an export.
+// A missing creation time defaults to now, and a missing update time to the creation
+// time. Revisions from other databases are discarded.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - note: The note to store; its ID is required
+//   - replace: Whether the note replaces an existing note with the same ID, or is created if there
+//     is none (otherwise it is created, and fails if the ID is taken)
+//
+// A note without an owner is imported for the owner of the request.
+//
+// Returns:
+//   - Whether the note was created; with replace, a note that doesn't exist is created
+//   - An error wrapping ErrInvalidNote if the note is invalid, a *QuotaError if the
+//     owner's quota is exceeded, or the storage error
+func (s *NoteService) Import(ctx context.Context, note *model.Note, replace bool) (bool, error) {
+	if err := validateID(note.ID); err != nil {
+		return false, err
+	}
+	if err := validate(note.Title, note.Content); err != nil {
+		return false, err
+	}
+	color, err := validateAppearance(note.Color, note.Icon)
+	if err != nil {
+		return false, err
+	}
+	note.Color = color
+	if note.Tags, err = normalizeTags(note.Tags); err != nil {
+		return false, err
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = s.now()
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = note.CreatedAt
+	}
+	// Timestamps are stored in UTC, whatever the offset they were exported with
+	note.CreatedAt, note.UpdatedAt = note.CreatedAt.UTC(), note.UpdatedAt.UTC()
+	if note.UpdatedAt.Before(note.CreatedAt) {
+		return false, fmt.Errorf("%w: updated_at must not be before created_at", ErrInvalidNote)
+	}
+	note.Rev = ""
+	// Views are stored apart from the notes (see ViewCounter)
+	note.Views, note.LastAccessedAt = 0, time.Time{}
+	if note.Owner == "" {
+		note.Owner = OwnerFromContext(ctx)
+	}
+
+	if !replace {
+		if err := s.charge(ctx, note.Owner, 1, noteSize(note)); err != nil {
+			return false, err
+		}
+		if err := s.repository.Create(ctx, note); err != nil {
+			s.refund(ctx, note.Owner, 1, noteSize(note))
+			return false, err
+		}
+		s.links.set(note)
+		s.publish(ctx, events.NoteCreated, note)
+		return true, nil
+	}
+	// The replaced note is released from the quota of its owner, and the import charged instead.
+	// The replaced note is read and replaced in one transaction, if the backend supports them,
+	// so the usage released is that of the note that was actually replaced.
+	if err := s.charge(ctx, note.Owner, 1, noteSize(note)); err != nil {
+		return false, err
+	}
+	var replaced *model.Note
+	var created bool
+	err = storage.RunInTransaction(ctx, s.repository, func(ctx context.Context) error {
+		replaced = nil // The transaction may be retried
+		if s.quotas != nil {
+			current, err := s.repository.Get(ctx, note.ID)
+			if err != nil && !errors.Is(err, storage.ErrNoteNotFound) {
+				return err
+			}
+			replaced = current
+		}
+		// Upserted, so a note deleted since the caller checked for it is created instead
+		var err error
+		created, err = storage.Upsert(ctx, s.repository, note)
+		return err
+	})
+	if err != nil {
+		s.refund(ctx, note.Owner, 1, noteSize(note))
+		return false, err
+	}
+	if replaced != nil {
+		s.refund(ctx, replaced.Owner, 1, noteSize(replaced))
+	}
+	s.links.set(note)
+	if created {
+		s.publish(ctx, events.NoteCreated, note)
+	} else {
+		s.publish(ctx, events.NoteUpdated, note)
+	}
+	return created, nil
+}
+
+// newNote creates a note from an input, with a generated ID and the current time as its
+// creation and update time.
+func (s *NoteService) newNote(input NoteInput) *model.Note {
+	note := model.NewNote(input.Title, input.Content)
+	note.Color, note.Icon, note.Tags = input.Color, input.Icon, input.Tags
+	note.CreatedAt = s.now().UTC()
+	note.UpdatedAt = note.CreatedAt
+	return note
+}
+
+// charge adds to the usage of an owner, if quotas are enabled (see Quotas.charge).
+func (s *NoteService) charge(ctx context.Context, owner string, notes int, bytes int64) error {
+	if s.quotas == nil {
+		return nil
+	}
+	return s.quotas.charge(ctx, owner, notes, bytes)
+}
+
+// refund releases usage charged for a write that didn't happen, or for a deleted note.
+// A failure leaves the usage too high, so it is logged rather than failing the request.
+func (s *NoteService) refund(ctx context.Context, owner string, notes int, bytes int64) {
+	if err := s.charge(ctx, owner, -notes, -bytes); err != nil {
+		log.Printf("%sFailed to release the quota usage of owner %q: %v", requestid.LogPrefix(ctx), owner, err)
+	}
+}
+
+// publish publishes an event carrying a copy of the note, if a publisher is configured.
+func (s *NoteService) publish(ctx context.Context, eventType string, note *model.Note) {
+	if s.publisher == nil {
+		return
+	}
+	published := *note
+	published.Tags = slices.Clone(note.Tags)
+	s.publisher.Publish(ctx, events.Event{
+		Type:      eventType,
+		NoteID:    note.ID,
+		Note:      &published,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// validate checks the fields that clients set.
+func validate(title, content string) error {
+	if title == "" && content == "" {
+		return fmt.Errorf("%w: title or content is required", ErrInvalidNote)
+	}
+	return nil
+}
+
+// validateInput checks the input of a note, and normalizes its color (see validateAppearance)
+// and tags (see normalizeTags).
+func validateInput(input *NoteInput) error {
+	if err := validate(input.Title, input.Content); err != nil {
+		return err
+	}
+	color, err := validateAppearance(input.Color, input.Icon)
+	if err != nil {
+		return err
+	}
+	input.Color = color
+	if input.Tags, err = normalizeTags(input.Tags); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateID checks a note ID chosen by a client (e.g., of an imported note), rather than generated.
+func validateID(id string) error {
+	if err := model.ValidateID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidNote, err)
+	}
+	return nil
+}
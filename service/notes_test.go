@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// recorder is a publisher that records the events it receives
+type recorder struct {
+	events []events.Event
+}
+
+// Publish records the event
+func (r *recorder) Publish(ctx context.Context, event events.Event) {
+	r.events = append(r.events, event)
+}
+
+// types returns the types of the recorded events
+func (r *recorder) types() []string {
+	types := make([]string, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+// TestNoteService tests that creations, updates, and deletions are stored and published
+func TestNoteService(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	created, err := s.Create(ctx, NoteInput{Title: "Title", Content: "Content"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.ID == "" || created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected a generated ID and timestamps, got %+v", created)
+	}
+
+	updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Updated"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Title != "Updated" || updated.Content != "" {
+		t.Errorf("Expected the title and content to be replaced, got %+v", updated)
+	}
+	if !updated.CreatedAt.Equal(created.CreatedAt) || updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("Expected the creation time to be kept and the update time to advance, got %+v", updated)
+	}
+	if created.Title != "Title" {
+		t.Errorf("Expected the created note to be left unchanged, got %+v", created)
+	}
+
+	if notes, err := s.GetAll(ctx); err != nil || len(notes) != 1 {
+		t.Errorf("GetAll returned %d notes, %v", len(notes), err)
+	}
+
+	if err := s.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get(ctx, created.ID); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound after Delete, got %v", err)
+	}
+
+	if types := rec.types(); len(types) != 3 || types[0] != events.NoteCreated || types[1] != events.NoteUpdated || types[2] != events.NoteDeleted {
+		t.Fatalf("Expected created, updated, and deleted events, got %v", types)
+	}
+	if event := rec.events[0]; event.Note == nil || event.Note.Title != "Title" {
+		t.Errorf("Expected the created event to carry the note as created, got %+v", event)
+	}
+	if event := rec.events[1]; event.Note == nil || event.Note.Title != "Updated" {
+		t.Errorf("Unexpected update event: %+v", event)
+	}
+	if event := rec.events[2]; event.NoteID != created.ID || event.Note != nil {
+		t.Errorf("Unexpected delete event: %+v", event)
+	}
+	for _, event := range rec.events {
+		if event.Timestamp.IsZero() || event.Timestamp.Location() != time.UTC {
+			t.Errorf("Expected a %s event timestamp in UTC, got %v", event.Type, event.Timestamp)
+		}
+	}
+}
+
+// TestNoteService_UpdateTimes tests that update times always increase and never precede
+// creation times, whatever the clock says
+func TestNoteService_UpdateTimes(t *testing.T) {
+	ctx := context.Background()
+	s := New(storage.NewInMemoryStorage())
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	s.now = func() time.Time { return now }
+
+	created, err := s.Create(ctx, NoteInput{Title: "Title"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !created.CreatedAt.Equal(now) || created.CreatedAt.Location() != time.UTC || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected the creation time from the clock, in UTC, got %+v", created)
+	}
+
+	// Updates within the same millisecond, or with a clock set back, still advance
+	previous := created.UpdatedAt
+	for _, clock := range []time.Time{now, now.Add(-time.Hour)} {
+		now = clock
+		updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Updated"})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if updated.UpdatedAt.Sub(previous) != time.Millisecond || !updated.CreatedAt.Equal(created.CreatedAt) {
+			t.Errorf("Expected the update time a millisecond after %v and the creation time kept, got %+v", previous, updated)
+		}
+		previous = updated.UpdatedAt
+	}
+
+	// A later clock is used as is
+	now = previous.Add(time.Minute)
+	if updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Later"}); err != nil || !updated.UpdatedAt.Equal(now) {
+		t.Errorf("Expected the update time from the clock, got %+v: %v", updated, err)
+	}
+
+	// An imported note created after the clock (e.g., on another instance) isn't updated before its creation
+	future := &model.Note{ID: "future", Title: "Title", CreatedAt: now.Add(time.Hour)}
+	if _, err := s.Import(ctx, future, false); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if updated, err := s.Update(ctx, "future", NoteInput{Title: "Updated"}); err != nil || !updated.UpdatedAt.After(updated.CreatedAt) {
+		t.Errorf("Expected the update time after the creation time, got %+v: %v", updated, err)
+	}
+
+	// The creation time can be sent back, but not changed
+	if _, err := s.Update(ctx, created.ID, NoteInput{Title: "Same", CreatedAt: created.CreatedAt.In(time.Local)}); err != nil {
+		t.Errorf("Expected an update with the creation time of the note to succeed, got %v", err)
+	}
+	if _, err := s.Update(ctx, created.ID, NoteInput{Title: "Changed", CreatedAt: created.CreatedAt.Add(-time.Hour)}); !errors.Is(err, ErrImmutableField) {
+		t.Errorf("Expected ErrImmutableField, got %v", err)
+	}
+}
+
+// TestNoteService_Appearance tests validating, normalizing, and keeping the color and icon of notes
+func TestNoteService_Appearance(t *testing.T) {
+	ctx := context.Background()
+	s := New(storage.NewInMemoryStorage())
+
+	created, err := s.Create(ctx, NoteInput{Title: "Title", Color: "#1E90FF", Icon: "star"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Color != "#1e90ff" || created.Icon != "star" {
+		t.Errorf("Expected the color in lower case and the icon, got %+v", created)
+	}
+
+	for _, input := range []NoteInput{
+		{Title: "Title", Color: "blue"},
+		{Title: "Title", Color: "#123"},
+		{Title: "Title", Color: "#12345g"},
+		{Title: "Title", Icon: "rocket"},
+		{Title: "Title", Icon: "Star"},
+	} {
+		if _, err := s.Create(ctx, input); !errors.Is(err, ErrInvalidNote) {
+			t.Errorf("Create(%+v): expected ErrInvalidNote, got %v", input, err)
+		}
+		if _, err := s.Update(ctx, created.ID, input); !errors.Is(err, ErrInvalidNote) {
+			t.Errorf("Update(%+v): expected ErrInvalidNote, got %v", input, err)
+		}
+	}
+
+	duplicate, err := s.Duplicate(ctx, created.ID)
+	if err != nil || duplicate.Color != created.Color || duplicate.Icon != created.Icon {
+		t.Errorf("Expected the duplicate to keep the color and icon, got %+v: %v", duplicate, err)
+	}
+
+	// Like the title and content, the color and icon are replaced by updates
+	if updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Title", Color: "#ABCDEF"}); err != nil || updated.Color != "#abcdef" || updated.Icon != "" {
+		t.Errorf("Expected the new color without an icon, got %+v: %v", updated, err)
+	}
+	if _, created, err := s.Upsert(ctx, "chosen", NoteInput{Title: "Title", Color: "#ABCDEF", Icon: "pin"}); err != nil || !created {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if note, err := s.Get(ctx, "chosen"); err != nil || note.Color != "#abcdef" || note.Icon != "pin" {
+		t.Errorf("Expected the upserted note with the normalized color, got %+v: %v", note, err)
+	}
+}
+
+// TestNoteService_Tags tests normalizing, validating, and keeping the tags of notes
+func TestNoteService_Tags(t *testing.T) {
+	ctx := context.Background()
+	s := New(storage.NewInMemoryStorage())
+
+	created, err := s.Create(ctx, NoteInput{Title: "Title", Tags: []string{" Work ", "home", "work", "ideas/later"}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if want := []string{"home", "ideas/later", "work"}; !slices.Equal(created.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, created.Tags)
+	}
+
+	tooMany := make([]string, maxTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag-%d", i)
+	}
+	for _, tags := range [][]string{{""}, {"two words"}, {"#work"}, {"-work"}, {strings.Repeat("a", maxTagLength+1)}, tooMany} {
+		if _, err := s.Create(ctx, NoteInput{Title: "Title", Tags: tags}); !errors.Is(err, ErrInvalidNote) {
+			t.Errorf("Create(%q): expected ErrInvalidNote, got %v", tags, err)
+		}
+	}
+
+	duplicate, err := s.Duplicate(ctx, created.ID)
+	if err != nil || !slices.Equal(duplicate.Tags, created.Tags) {
+		t.Errorf("Expected the duplicate to keep the tags, got %+v: %v", duplicate, err)
+	}
+
+	// Like the title and content, the tags are replaced by updates
+	if updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Title"}); err != nil || updated.Tags != nil {
+		t.Errorf("Expected the tags to be removed, got %+v: %v", updated, err)
+	}
+
+	imported := &model.Note{ID: "imported", Title: "Title", Tags: []string{"B", "a"}}
+	if _, err := s.Import(ctx, imported, false); err != nil || !slices.Equal(imported.Tags, []string{"a", "b"}) {
+		t.Errorf("Expected the imported tags to be normalized, got %v: %v", imported.Tags, err)
+	}
+	if _, err := s.Import(ctx, &model.Note{ID: "invalid", Title: "Title", Tags: []string{"a b"}}, false); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote for an invalid imported tag, got %v", err)
+	}
+}
+
+// TestNoteService_Failure tests that invalid notes are rejected and failed operations are not published
+func TestNoteService_Failure(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	if _, err := s.Create(ctx, NoteInput{}); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote from Create, got %v", err)
+	}
+	if _, err := s.Update(ctx, "missing", NoteInput{}); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote from Update, got %v", err)
+	}
+	if _, err := s.Update(ctx, "missing", NoteInput{Title: "Title"}); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound from Update, got %v", err)
+	}
+	if err := s.Delete(ctx, "missing"); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound from Delete, got %v", err)
+	}
+	if len(rec.events) != 0 {
+		t.Errorf("Expected no events, got %+v", rec.events)
+	}
+}
+
+// TestNoteService_Import tests that imported notes keep their ID and timestamps
+func TestNoteService_Import(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	note := &model.Note{ID: "note-1", Rev: "3-abc", Title: "Title", CreatedAt: created}
+	if _, err := s.Import(ctx, note, false); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !note.UpdatedAt.Equal(created) || note.Rev != "" {
+		t.Errorf("Expected the update time to default to the creation time and the revision to be discarded, got %+v", note)
+	}
+
+	// Timestamps exported with an offset are stored in UTC
+	replacement := &model.Note{ID: "note-1", Title: "Replaced", CreatedAt: created, UpdatedAt: created.Add(time.Hour).In(time.FixedZone("CEST", 2*3600))}
+	if _, err := s.Import(ctx, replacement, true); err != nil {
+		t.Fatalf("Import with replace failed: %v", err)
+	}
+	if got, err := s.Get(ctx, "note-1"); err != nil || got.Title != "Replaced" || !got.UpdatedAt.Equal(created.Add(time.Hour)) || got.UpdatedAt.Location() != time.UTC {
+		t.Errorf("Get returned %+v, %v", got, err)
+	}
+
+	invalid := []*model.Note{
+		{Title: "No ID"},
+		{ID: "notes/2", Title: "Title"}, // Rejected in URLs, so it couldn't be read
+		{ID: "note-2"},
+		{ID: "note-2", Title: "Title", CreatedAt: created, UpdatedAt: created.Add(-time.Hour)},
+	}
+	for _, n := range invalid {
+		if _, err := s.Import(ctx, n, false); !errors.Is(err, ErrInvalidNote) {
+			t.Errorf("Expected ErrInvalidNote for %+v, got %v", n, err)
+		}
+	}
+
+	if types := rec.types(); len(types) != 2 || types[0] != events.NoteCreated || types[1] != events.NoteUpdated {
+		t.Errorf("Expected created and updated events, got %v", types)
+	}
+}
+
+// transactor is an in-memory repository that counts the transactions it runs
+type transactor struct {
+	*storage.InMemoryStorage
+	transactions int
+}
+
+// RunInTransaction counts the transaction and runs fn
+func (s *transactor) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.transactions++
+	return fn(ctx)
+}
+
+// TestNoteService_ImportTransaction tests that replacing a note on import reads and
+// replaces it in a single transaction
+func TestNoteService_ImportTransaction(t *testing.T) {
+	ctx := context.Background()
+	repository := &transactor{InMemoryStorage: storage.NewInMemoryStorage()}
+	s := New(repository)
+
+	note := &model.Note{ID: "note-1", Title: "Title"}
+	if _, err := s.Import(ctx, note, false); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if _, err := s.Import(ctx, &model.Note{ID: "note-1", Title: "Replaced"}, true); err != nil {
+		t.Fatalf("Import with replace failed: %v", err)
+	}
+	if repository.transactions != 1 {
+		t.Errorf("Expected the replacement to run in 1 transaction, got %d", repository.transactions)
+	}
+	// A note to replace that doesn't exist (e.g., it was deleted concurrently) is created
+	created, err := s.Import(ctx, &model.Note{ID: "note-2", Title: "Missing"}, true)
+	if err != nil || !created {
+		t.Errorf("Expected the missing note to be created, got %t: %v", created, err)
+	}
+}
+
+// TestNoteService_Upsert tests that upserts update existing notes and create missing ones
+func TestNoteService_Upsert(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	note, created, err := s.Upsert(ctx, "chosen-id", NoteInput{Title: "Title"})
+	if err != nil || !created {
+		t.Fatalf("Expected the note to be created, got %t: %v", created, err)
+	}
+	if note.ID != "chosen-id" || note.CreatedAt.IsZero() || !note.UpdatedAt.Equal(note.CreatedAt) {
+		t.Errorf("Expected the given ID and fresh timestamps, got %+v", note)
+	}
+
+	updated, created, err := s.Upsert(ctx, "chosen-id", NoteInput{Title: "Updated"})
+	if err != nil || created {
+		t.Fatalf("Expected the note to be updated, got %t: %v", created, err)
+	}
+	if updated.Title != "Updated" || !updated.CreatedAt.Equal(note.CreatedAt) {
+		t.Errorf("Expected the update to keep the creation time, got %+v", updated)
+	}
+
+	// Updates based on a revision or an update time expect an existing note
+	if _, _, err := s.Upsert(ctx, "other", NoteInput{Title: "Title", UpdatedAt: note.UpdatedAt}); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound for a conditional update, got %v", err)
+	}
+	for _, id := range []string{"with space", strings.Repeat("x", model.MaxIDLength+1), "invalid@id"} {
+		if _, _, err := s.Upsert(ctx, id, NoteInput{Title: "Title"}); !errors.Is(err, ErrInvalidNote) {
+			t.Errorf("Expected ErrInvalidNote for ID %q, got %v", id, err)
+		}
+	}
+
+	if types := rec.types(); len(types) != 2 || types[0] != events.NoteCreated || types[1] != events.NoteUpdated {
+		t.Errorf("Expected created and updated events, got %v", types)
+	}
+}
+
+// TestNoteService_Purge tests that purging deletes and publishes every note
+func TestNoteService_Purge(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := s.Create(ctx, NoteInput{Title: title}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	rec.events = nil
+
+	deleted, err := s.Purge(ctx)
+	if err != nil || deleted != 3 {
+		t.Fatalf("Purge returned %d, %v", deleted, err)
+	}
+	if notes, err := s.GetAll(ctx); err != nil || len(notes) != 0 {
+		t.Errorf("Expected no notes after Purge, got %d, %v", len(notes), err)
+	}
+	if types := rec.types(); len(types) != 3 || types[0] != events.NoteDeleted {
+		t.Errorf("Expected three deleted events, got %v", types)
+	}
+
+	if deleted, err := s.Purge(ctx); err != nil || deleted != 0 {
+		t.Errorf("Purge of an empty storage returned %d, %v", deleted, err)
+	}
+}
+
+// mapRepository is a minimal NoteRepository, to check that the service needs nothing else from a backend
+type mapRepository map[string]*model.Note
+
+func (r mapRepository) Create(ctx context.Context, note *model.Note) error {
+	r[note.ID] = note
+	return nil
+}
+
+func (r mapRepository) Get(ctx context.Context, id string) (*model.Note, error) {
+	if note, ok := r[id]; ok {
+		return note, nil
+	}
+	return nil, storage.ErrNoteNotFound
+}
+
+func (r mapRepository) GetAll(ctx context.Context) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0, len(r))
+	for _, note := range r {
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+func (r mapRepository) Update(ctx context.Context, note *model.Note) error {
+	r[note.ID] = note
+	return nil
+}
+
+func (r mapRepository) Delete(ctx context.Context, id string) error {
+	delete(r, id)
+	return nil
+}
+
+// TestNoteService_Repository tests listing and streaming on a repository that only implements the storage port
+func TestNoteService_Repository(t *testing.T) {
+	ctx := context.Background()
+	s := New(mapRepository{})
+
+	for _, title := range []string{"b", "a", "c"} {
+		if _, err := s.Create(ctx, NoteInput{Title: title}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	notes, err := s.List(ctx, storage.ListOptions{Sort: storage.SortTitle, Limit: 2})
+	if err != nil || len(notes) != 2 || notes[0].Title != "a" || notes[1].Title != "b" {
+		t.Errorf("List returned %v, %v", notes, err)
+	}
+
+	count := 0
+	if err := s.Stream(ctx, func(note *model.Note) error { count++; return nil }); err != nil || count != 3 {
+		t.Errorf("Stream visited %d notes, %v", count, err)
+	}
+}
+
+// TestNoteService_Duplicate tests that a copy gets its own ID and a suffixed title
+func TestNoteService_Duplicate(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec))
+
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Title", "Title (copy)"},
+		{"", "(copy)"},
+	}
+	for _, tt := range tests {
+		original, err := s.Create(ctx, NoteInput{Title: tt.title, Content: "Content"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		copied, err := s.Duplicate(ctx, original.ID)
+		if err != nil {
+			t.Fatalf("Duplicate failed: %v", err)
+		}
+		if copied.ID == original.ID || copied.Title != tt.want || copied.Content != original.Content {
+			t.Errorf("Unexpected copy of %q: %+v", tt.title, copied)
+		}
+	}
+
+	if _, err := s.Duplicate(ctx, "missing"); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if len(rec.events) != 4 || rec.events[3].Type != events.NoteCreated {
+		t.Errorf("Expected the copies to be published as created, got %v", rec.types())
+	}
+}
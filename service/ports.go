@@ -0,0 +1,221 @@
+// This file contains the ports of the note service. The application follows a
+// ports-and-adapters (hexagonal) layout:
+//
+//   - Domain: the note entity (package model) and the business logic (NoteService)
+//   - Ports: the interfaces below, through which the domain talks to the outside world
+//   - Adapters: the storage backends (package storage), the transports (packages rest
+//     and grpc), and the receivers of note events (packages events, webhook, and broker)
+//
+// The domain only depends on the ports, so a new storage backend, protocol, or message
+// broker plugs in by implementing a port, without touching the business logic.
+package service
+
+import (
+	"context"
+	"io"
+
+	"golang-simple-notes/crdt"
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/vector"
+)
+
+// NoteRepository is the storage port: the operations the service needs from a storage
+// backend. Every storage.NoteStorage implements it. Backends that also implement
+// storage.Lister, storage.Streamer, storage.BatchGetter, storage.Exister,
+// storage.ConditionalUpdater, storage.Upserter, or storage.Transactor run queries, full
+// reads, batch reads, existence checks, conditional updates, upserts, and transactions natively.
+type NoteRepository interface {
+	// Create adds a new note; it fails if a note with the same ID already exists.
+	Create(ctx context.Context, note *model.Note) error
+
+	// Get retrieves a note by its ID, or returns storage.ErrNoteNotFound.
+	Get(ctx context.Context, id string) (*model.Note, error)
+
+	// GetAll retrieves all notes.
+	GetAll(ctx context.Context) ([]*model.Note, error)
+
+	// Update replaces an existing note, or returns storage.ErrNoteNotFound.
+	Update(ctx context.Context, note *model.Note) error
+
+	// Delete removes a note by its ID, or returns storage.ErrNoteNotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+// EventPublisher is the events port: the receiver of note lifecycle events.
+// events.Bus implements it, and fans the events out to watches, webhooks,
+// WebSocket streams, and message brokers.
+type EventPublisher interface {
+	// Publish delivers an event; it must not block the write that caused it.
+	Publish(ctx context.Context, event events.Event)
+}
+
+// Summarizer is the summarization port: the engine that writes the summaries of notes
+// (see package summarizer).
+type Summarizer interface {
+	// Summarize returns a summary of a note with the given title and content.
+	Summarize(ctx context.Context, title, content string) (string, error)
+}
+
+// Embedder is the embedding port: the provider that turns texts into vectors whose
+// distances reflect how related the texts are (see package embedding).
+type Embedder interface {
+	// Embed returns the vector of every text, in the order of the texts.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// VectorIndex is the vector port: the index of the embeddings of the notes, which finds
+// the nearest neighbors of a vector (see package vector).
+type VectorIndex interface {
+	// Upsert adds the vector of a note, or replaces it.
+	Upsert(ctx context.Context, id string, vector []float32) error
+
+	// Delete removes the vector of a note, if it has one.
+	Delete(ctx context.Context, id string) error
+
+	// Search returns the notes with the vectors most similar to a query, most similar first.
+	Search(ctx context.Context, query []float32, limit int) ([]vector.Match, error)
+}
+
+// Notes is the transport port: the operations that transports (REST, gRPC) offer to
+// their clients. NoteService implements it; transports only translate between their
+// wire formats and these calls, so every protocol behaves the same.
+type Notes interface {
+	// Create creates a note with a generated ID and timestamps.
+	Create(ctx context.Context, input NoteInput) (*model.Note, error)
+
+	// Get retrieves a note by its ID.
+	Get(ctx context.Context, id string) (*model.Note, error)
+
+	// View retrieves a note by its ID for a client, counting the view.
+	View(ctx context.Context, id string) (*model.Note, error)
+
+	// Exists reports whether a note exists.
+	Exists(ctx context.Context, id string) (bool, error)
+
+	// GetMany retrieves the notes with the given IDs, skipping IDs without a note.
+	GetMany(ctx context.Context, ids []string) ([]*model.Note, error)
+
+	// Duplicate creates a copy of a note with a generated ID and timestamps.
+	Duplicate(ctx context.Context, id string) (*model.Note, error)
+
+	// GetAll retrieves all notes.
+	GetAll(ctx context.Context) ([]*model.Note, error)
+
+	// List retrieves the notes matching a list query.
+	List(ctx context.Context, opts storage.ListOptions) ([]*model.Note, error)
+
+	// Count returns the number of notes matching the query of a list query.
+	Count(ctx context.Context, opts storage.ListOptions) (int, error)
+
+	// Stats returns statistics about all notes.
+	Stats(ctx context.Context) (storage.NoteStats, error)
+
+	// Stream calls fn for every note, one at a time where the storage supports it.
+	Stream(ctx context.Context, fn func(note *model.Note) error) error
+
+	// StreamList calls fn for every note matching a list query, one at a time where possible.
+	StreamList(ctx context.Context, opts storage.ListOptions, fn func(note *model.Note) error) error
+
+	// Update sets the title and content of an existing note.
+	Update(ctx context.Context, id string, input NoteInput) (*model.Note, error)
+
+	// Upsert updates a note, or creates it with the given ID if it doesn't exist.
+	Upsert(ctx context.Context, id string, input NoteInput) (*model.Note, bool, error)
+
+	// Delete deletes a note by its ID.
+	Delete(ctx context.Context, id string) error
+
+	// Purge deletes all notes and returns how many were deleted.
+	Purge(ctx context.Context) (int, error)
+
+	// Import stores a note with its own ID and timestamps, and reports whether it was created.
+	Import(ctx context.Context, note *model.Note, replace bool) (bool, error)
+
+	// Backlinks retrieves the notes that link to a note.
+	Backlinks(ctx context.Context, id string) ([]*model.Note, error)
+
+	// BacklinksMany retrieves the notes that link to each of several notes, by note ID.
+	BacklinksMany(ctx context.Context, notes []*model.Note) (map[string][]*model.Note, error)
+}
+
+// Summaries is the transport port for summarizing notes. NoteService implements it, if
+// created with a summarizer (see WithSummarizer).
+type Summaries interface {
+	// Summarize summarizes a note and stores the summary on the note.
+	Summarize(ctx context.Context, id string) (*model.Note, error)
+}
+
+// Search is the transport port for searching notes. SearchService implements it.
+type Search interface {
+	// Search returns the notes matching a query, by text or, if enabled, by meaning.
+	Search(ctx context.Context, mode, query string, limit int) ([]SearchResult, error)
+
+	// Semantic reports whether searches by meaning are enabled.
+	Semantic() bool
+
+	// Reindex embeds and indexes every note for searches by meaning.
+	Reindex(ctx context.Context) (int, error)
+}
+
+// Templates is the transport port for note templates. TemplateService implements it.
+type Templates interface {
+	// Create creates a template with a generated ID and timestamps.
+	Create(ctx context.Context, input NoteInput) (*model.Note, error)
+
+	// Get retrieves a template by its ID.
+	Get(ctx context.Context, id string) (*model.Note, error)
+
+	// List retrieves all templates.
+	List(ctx context.Context) ([]*model.Note, error)
+
+	// Update sets the title and content of an existing template.
+	Update(ctx context.Context, id string, input NoteInput) (*model.Note, error)
+
+	// Delete deletes a template by its ID.
+	Delete(ctx context.Context, id string) error
+
+	// Instantiate creates a note from a template, substituting its variables.
+	Instantiate(ctx context.Context, id string, vars TemplateVariables) (*model.Note, error)
+}
+
+// Attachments is the transport port for the files attached to notes. AttachmentService implements it.
+type Attachments interface {
+	// Put stores a file as an attachment of a note, replacing one with the same name.
+	Put(ctx context.Context, noteID string, attachment storage.Attachment, body io.Reader) (storage.Attachment, error)
+
+	// Get returns an attachment of a note with its content.
+	Get(ctx context.Context, noteID, name string) (storage.Attachment, io.ReadCloser, error)
+
+	// Delete removes an attachment of a note.
+	Delete(ctx context.Context, noteID, name string) error
+
+	// List returns the attachments of a note.
+	List(ctx context.Context, noteID string) ([]storage.Attachment, error)
+
+	// ListMany returns the attachments of several notes, by note ID.
+	ListMany(ctx context.Context, noteIDs []string) (map[string][]storage.Attachment, error)
+
+	// Thumbnail returns a thumbnail of an image attachment with its content.
+	Thumbnail(ctx context.Context, noteID, name string, size int) (storage.Attachment, io.ReadCloser, error)
+}
+
+// Collaboration is the transport port for editing notes together. CollabService implements it.
+type Collaboration interface {
+	// Join adds a participant to the editing session of a note; receive gets the edits of the others.
+	Join(ctx context.Context, noteID string, receive func(ops []crdt.Op)) (*CollabState, error)
+
+	// Edit applies the operations of a participant to the document of a note.
+	Edit(ctx context.Context, noteID, site string, ops []crdt.Op) error
+
+	// Leave removes a participant from the editing session of a note.
+	Leave(noteID, site string)
+}
+
+// NoteService, TemplateService, and CollabService must implement the transport ports
+var (
+	_ Notes         = (*NoteService)(nil)
+	_ Templates     = (*TemplateService)(nil)
+	_ Collaboration = (*CollabService)(nil)
+)
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// quotaMaxAttempts is the number of times a usage counter is updated before giving up,
+// if other instances keep updating it concurrently.
+const quotaMaxAttempts = 5
+
+// ErrQuotaExceeded is returned (wrapped in a *QuotaError) when a write would take the
+// owner of a note over one of its limits.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Limits of a quota, as reported by QuotaError.Limit.
+const (
+	QuotaNotes = "notes" // Number of notes
+	QuotaBytes = "bytes" // Total size of the titles and contents of the notes
+)
+
+// QuotaLimits are the limits of every owner. A limit of zero disables it.
+type QuotaLimits struct {
+	MaxNotes int   // Maximum number of notes of an owner
+	MaxBytes int64 // Maximum total size of the titles and contents of the notes of an owner, in bytes
+}
+
+// QuotaUsage is what an owner uses of its quota.
+type QuotaUsage struct {
+	Notes int   `json:"notes"` // Number of notes
+	Bytes int64 `json:"bytes"` // Total size of the titles and contents of the notes, in bytes
+}
+
+// QuotaError describes a write rejected because the owner of the note would exceed a limit.
+type QuotaError struct {
+	Owner     string // Owner whose quota is exceeded (empty for anonymous clients)
+	Limit     string // QuotaNotes or QuotaBytes
+	Max       int64  // The limit
+	Used      int64  // Usage before the write
+	Requested int64  // Amount the write would add
+}
+
+// Error describes the exceeded limit.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %d of %d used, %d more requested", e.Limit, e.Used, e.Max, e.Requested)
+}
+
+// Unwrap makes errors.Is(err, ErrQuotaExceeded) match.
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// Quotas limits the number of notes and the total size of the notes of every owner (see
+// ContextWithOwner). The usage of every owner is counted in a repository of its own, as
+// a document per owner, so it is shared by all instances and survives restarts; counter
+// updates are conditional (see storage.UpdateIf), so concurrent writes by other instances
+// are never lost. Only writes made while quotas are enabled are counted.
+// It is safe for concurrent use.
+type Quotas struct {
+	repository NoteRepository // Usage counters, by owner
+	limits     QuotaLimits    // Limits of every owner
+	mutex      sync.Mutex     // Serializes the counter updates of this instance
+}
+
+// NewQuotas creates a new Quotas.
+//
+// Parameters:
+//   - repository: The storage of the usage counters, separate from the storage of the notes
+//   - limits: The limits of every owner
+//
+// Returns:
+//   - A pointer to a new Quotas instance
+func NewQuotas(repository NoteRepository, limits QuotaLimits) *Quotas {
+	return &Quotas{repository: repository, limits: limits}
+}
+
+// Limits returns the limits of every owner.
+func (q *Quotas) Limits() QuotaLimits {
+	return q.limits
+}
+
+// Usage returns what an owner uses of its quota.
+func (q *Quotas) Usage(ctx context.Context, owner string) (QuotaUsage, error) {
+	usage, _, err := q.load(ctx, owner)
+	return usage, err
+}
+
+// charge adds to the usage of an owner, unless that would exceed one of its limits.
+// Negative amounts release usage and always succeed (without going below zero).
+//
+// Returns:
+//   - A *QuotaError if a limit would be exceeded, or the storage error
+func (q *Quotas) charge(ctx context.Context, owner string, notes int, bytes int64) error {
+	if notes == 0 && bytes == 0 {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var err error
+	for range quotaMaxAttempts {
+		var usage QuotaUsage
+		var stored *model.Note
+		usage, stored, err = q.load(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if err := q.check(owner, usage, notes, bytes); err != nil {
+			return err
+		}
+		usage.Notes = max(0, usage.Notes+notes)
+		usage.Bytes = max(0, usage.Bytes+bytes)
+
+		err = q.save(ctx, owner, usage, stored)
+		if !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+		// Updated by another instance since it was read; read it again
+	}
+	return fmt.Errorf("failed to update the quota usage of owner %q: %w", owner, err)
+}
+
+// check returns a *QuotaError if adding to the usage would exceed a limit.
+func (q *Quotas) check(owner string, usage QuotaUsage, notes int, bytes int64) error {
+	if notes > 0 && q.limits.MaxNotes > 0 && usage.Notes+notes > q.limits.MaxNotes {
+		return &QuotaError{Owner: owner, Limit: QuotaNotes, Max: int64(q.limits.MaxNotes),
+			Used: int64(usage.Notes), Requested: int64(notes)}
+	}
+	if bytes > 0 && q.limits.MaxBytes > 0 && usage.Bytes+bytes > q.limits.MaxBytes {
+		return &QuotaError{Owner: owner, Limit: QuotaBytes, Max: q.limits.MaxBytes,
+			Used: usage.Bytes, Requested: bytes}
+	}
+	return nil
+}
+
+// load reads the usage of an owner, and the document it is stored in (nil if there is none yet).
+func (q *Quotas) load(ctx context.Context, owner string) (QuotaUsage, *model.Note, error) {
+	var usage QuotaUsage
+	stored, err := q.repository.Get(ctx, quotaID(owner))
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		return usage, nil, nil
+	}
+	if err != nil {
+		return usage, nil, err
+	}
+	if err := json.Unmarshal([]byte(stored.Content), &usage); err != nil {
+		return usage, nil, fmt.Errorf("invalid quota usage of owner %q: %w", owner, err)
+	}
+	return usage, stored, nil
+}
+
+// save stores the usage of an owner, if its document is still the one that was read.
+//
+// Returns:
+//   - storage.ErrConflict if the document was created or updated since it was read
+func (q *Quotas) save(ctx context.Context, owner string, usage QuotaUsage, stored *model.Note) error {
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if stored == nil {
+		err := q.repository.Create(ctx, &model.Note{ID: quotaID(owner), Content: string(data), CreatedAt: now, UpdatedAt: now})
+		if err == nil {
+			return nil
+		}
+		// Created by another instance since it was read?
+		if _, getErr := q.repository.Get(ctx, quotaID(owner)); getErr == nil {
+			return storage.ErrConflict
+		}
+		return err
+	}
+
+	updated := *stored
+	updated.Content = string(data)
+	// The update time is the version of the counter, so it must change
+	updated.UpdatedAt = updateTime(stored, now)
+	return storage.UpdateIf(ctx, q.repository, &updated, stored.UpdatedAt)
+}
+
+// quotaID returns the ID of the document holding the usage of an owner.
+func quotaID(owner string) string {
+	if owner == "" {
+		return "anonymous"
+	}
+	return "owner-" + owner
+}
+
+// noteSize returns what a note counts against the size limit of its owner.
+func noteSize(note *model.Note) int64 {
+	return int64(len(note.Title) + len(note.Content))
+}
+
+// ownerKey is the context key of the owner of the request.
+type ownerKey struct{}
+
+// ContextWithOwner returns a context carrying the owner of the request (e.g., the
+// hash of an API key), which notes created with it belong to.
+func ContextWithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// OwnerFromContext returns the owner of the request, or an empty string for anonymous clients.
+func OwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerKey{}).(string)
+	return owner
+}
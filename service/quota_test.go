@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// TestQuotas tests that the notes of every owner are limited in number and size, and
+// that deletions and failed writes release their usage
+func TestQuotas(t *testing.T) {
+	quotas := NewQuotas(storage.NewInMemoryStorage(), QuotaLimits{MaxNotes: 2, MaxBytes: 20})
+	notes := storage.NewInMemoryStorage()
+	s := New(notes, WithQuotas(quotas))
+	alice := ContextWithOwner(context.Background(), "alice")
+	bob := ContextWithOwner(context.Background(), "bob")
+
+	usage := func(ctx context.Context) QuotaUsage {
+		t.Helper()
+		usage, err := quotas.Usage(ctx, OwnerFromContext(ctx))
+		if err != nil {
+			t.Fatalf("Usage failed: %v", err)
+		}
+		return usage
+	}
+	quotaError := func(err error, limit string) {
+		t.Helper()
+		var quotaErr *QuotaError
+		if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) || quotaErr.Limit != limit {
+			t.Errorf("Expected the %s quota to be exceeded, got %v", limit, err)
+		}
+	}
+
+	first, err := s.Create(alice, NoteInput{Title: "One", Content: "1234"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if first.Owner != "alice" {
+		t.Errorf("Expected the note to belong to its creator, got %q", first.Owner)
+	}
+	if _, err := s.Create(alice, NoteInput{Title: "Two"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got := usage(alice); got != (QuotaUsage{Notes: 2, Bytes: 10}) {
+		t.Errorf("Unexpected usage: %+v", got)
+	}
+
+	// Limits apply to every owner separately
+	_, err = s.Create(alice, NoteInput{Title: "Three"})
+	quotaError(err, QuotaNotes)
+	_, err = s.Create(bob, NoteInput{Content: "This is way too long!"})
+	quotaError(err, QuotaBytes)
+	if _, err := s.Create(bob, NoteInput{Title: "Bob's"}); err != nil {
+		t.Errorf("Expected another owner to have a quota of its own, got %v", err)
+	}
+
+	// Growing a note counts against the quota of its owner, whoever updates it
+	_, err = s.Update(bob, first.ID, NoteInput{Title: "One", Content: "1234567890123456"})
+	quotaError(err, QuotaBytes)
+	if _, err := s.Update(bob, first.ID, NoteInput{Title: "One", Content: "12"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := usage(alice); got != (QuotaUsage{Notes: 2, Bytes: 8}) {
+		t.Errorf("Expected shrinking to release bytes, got %+v", got)
+	}
+
+	if err := s.Delete(bob, first.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if got := usage(alice); got != (QuotaUsage{Notes: 1, Bytes: 3}) {
+		t.Errorf("Expected the deletion to be released, got %+v", got)
+	}
+
+	// A failed write doesn't count
+	if _, err := s.Update(alice, "missing", NoteInput{Title: "Missing"}); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if _, err := s.Import(alice, &model.Note{ID: "imported", Title: "Imported"}, false); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got := usage(alice); got != (QuotaUsage{Notes: 2, Bytes: 11}) {
+		t.Errorf("Expected the import to count, got %+v", got)
+	}
+	notes.SetLimits(storage.InMemoryLimits{MaxNotes: 3})
+	if _, err := s.Import(bob, &model.Note{ID: "other", Title: "Other"}, false); !errors.Is(err, storage.ErrStorageFull) {
+		t.Fatalf("Expected the import to fail with ErrStorageFull, got %v", err)
+	}
+	if got := usage(bob); got != (QuotaUsage{Notes: 1, Bytes: 5}) {
+		t.Errorf("Expected the failed import to be released, got %+v", got)
+	}
+}
+
+// TestQuotasConflict tests that a counter updated concurrently by another instance is read again
+func TestQuotasConflict(t *testing.T) {
+	ctx := context.Background()
+	repository := storage.NewInMemoryStorage()
+	quotas := NewQuotas(repository, QuotaLimits{MaxNotes: 10})
+	other := NewQuotas(repository, QuotaLimits{MaxNotes: 10})
+
+	if err := quotas.charge(ctx, "alice", 1, 5); err != nil {
+		t.Fatalf("charge failed: %v", err)
+	}
+	// The counter read by quotas is outdated once the other instance has updated it
+	_, stored, err := quotas.load(ctx, "alice")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := other.charge(ctx, "alice", 1, 5); err != nil {
+		t.Fatalf("charge failed: %v", err)
+	}
+	if err := quotas.save(ctx, "alice", QuotaUsage{Notes: 2, Bytes: 10}, stored); !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("Expected ErrConflict for an outdated counter, got %v", err)
+	}
+
+	if err := quotas.charge(ctx, "alice", 1, 5); err != nil {
+		t.Fatalf("charge failed: %v", err)
+	}
+	if usage, _ := quotas.Usage(ctx, "alice"); usage != (QuotaUsage{Notes: 3, Bytes: 15}) {
+		t.Errorf("Expected every charge to count, got %+v", usage)
+	}
+}
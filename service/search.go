@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// Search modes.
+const (
+	SearchText     = "text"     // Notes whose title or content contains the query
+	SearchSemantic = "semantic" // Notes nearest to the query by the embeddings of their text
+)
+
+// ErrInvalidSearch is returned (wrapped) when a search is invalid, e.g., has an empty query
+// or an unknown mode. The error message describes what is wrong, so it can be shown to clients.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// ErrEmbedder is returned (wrapped) when the embedder fails to embed a query, e.g., because
+// an external API is unavailable.
+var ErrEmbedder = errors.New("embedder failed")
+
+// maxEmbeddedText is the maximum length of the text of a note that is embedded, in bytes;
+// the rest is left out, which keeps requests within the input limits of most models.
+const maxEmbeddedText = 8 << 10
+
+// reindexBatch is the number of notes embedded at once when the index is rebuilt.
+const reindexBatch = 32
+
+// indexRetry is the retry policy of indexing a changed note.
+var indexRetry = storage.RetryPolicy{
+	MaxAttempts:    3,
+	InitialDelay:   time.Second,
+	MaxDelay:       10 * time.Second,
+	AttemptTimeout: 30 * time.Second,
+}
+
+// SearchResult is a note found by a search.
+type SearchResult struct {
+	Note       *model.Note `json:"note"`                 // The note found
+	Score      float64     `json:"score,omitempty"`      // Similarity with the query, for semantic searches (1 is the closest)
+	Highlights []Highlight `json:"highlights,omitempty"` // Fragments of the note where the query matches, if any
+}
+
+// SearchService searches notes: by text, through the storage, and, if enabled, by meaning,
+// through the embeddings of the notes in a vector index. The index follows the note events
+// (see Notify), so it must be subscribed to them. It implements the Search port.
+type SearchService struct {
+	notes *NoteService // Notes searched, and read for their text
+
+	// Semantic search (optional)
+	embedder Embedder     // Embedder of the notes and queries
+	index    VectorIndex  // Index of the embeddings of the notes
+	runner   *jobs.Runner // Runner of the jobs indexing the notes
+}
+
+// SearchOption configures optional features of a SearchService.
+type SearchOption func(*SearchService)
+
+// WithSemanticSearch enables semantic searches: notes are embedded by the embedder and
+// indexed in the index, in "embedding" jobs of the runner, whenever they change.
+func WithSemanticSearch(embedder Embedder, index VectorIndex, runner *jobs.Runner) SearchOption {
+	return func(s *SearchService) {
+		s.embedder = embedder
+		s.index = index
+		s.runner = runner
+	}
+}
+
+// NewSearchService creates a new SearchService.
+//
+// Parameters:
+//   - notes: The note service, whose notes are searched
+//   - opts: Optional features to enable (e.g., WithSemanticSearch)
+//
+// Returns:
+//   - A pointer to a new SearchService instance
+func NewSearchService(notes *NoteService, opts ...SearchOption) *SearchService {
+	s := &SearchService{notes: notes}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Semantic reports whether semantic searches are enabled.
+func (s *SearchService) Semantic() bool {
+	return s.index != nil
+}
+
+// Search returns the notes matching a query, at most limit of them (all if 0). Text
+// searches return the notes containing the query, most recently updated first; semantic
+// searches return the nearest notes, most similar first. Every result has the fragments
+// of its note where the query, or one of its words, appears.
+//
+// Returns:
+//   - The notes found
+//   - An error wrapping ErrInvalidSearch if the query is empty or the mode unknown or not
+//     enabled, an error wrapping ErrEmbedder if the query cannot be embedded, or the
+//     error of the storage or the index
+func (s *SearchService) Search(ctx context.Context, mode, query string, limit int) ([]SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: the query must not be empty", ErrInvalidSearch)
+	}
+	var results []SearchResult
+	var err error
+	switch mode {
+	case "", SearchText:
+		results, err = s.searchText(ctx, query, limit)
+	case SearchSemantic:
+		if !s.Semantic() {
+			return nil, fmt.Errorf("%w: semantic search is not enabled", ErrInvalidSearch)
+		}
+		results, err = s.searchSemantic(ctx, query, limit)
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q (use %s or %s)", ErrInvalidSearch, mode, SearchText, SearchSemantic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Highlights = highlight(results[i].Note, query)
+	}
+	return results, nil
+}
+
+// searchText returns the notes containing a query (see storage.ListOptions.Query).
+func (s *SearchService) searchText(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	notes, err := s.notes.List(ctx, storage.ListOptions{Query: query, Sort: storage.SortUpdatedAt, Descending: true, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(notes))
+	for i, note := range notes {
+		results[i] = SearchResult{Note: note}
+	}
+	return results, nil
+}
+
+// searchSemantic returns the notes nearest to the embedding of a query. Notes deleted
+// since they were indexed are skipped.
+func (s *SearchService) searchSemantic(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEmbedder, err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("%w: expected 1 embedding, got %d", ErrEmbedder, len(vectors))
+	}
+	matches, err := s.index.Search(ctx, vectors[0], limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search the vector index: %w", err)
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	notes, err := s.notes.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.Note, len(notes))
+	for _, note := range notes {
+		byID[note.ID] = note
+	}
+	results := make([]SearchResult, 0, len(matches))
+	for _, match := range matches {
+		if note, ok := byID[match.ID]; ok {
+			results = append(results, SearchResult{Note: note, Score: match.Score})
+		}
+	}
+	return results, nil
+}
+
+// Notify indexes the note of a created or updated event, or removes the note of a deleted
+// event from the index, in an "embedding" job retried a few times if it fails, so the
+// publisher isn't blocked. The job reads the note when it runs, so the index ends up with
+// its latest version even if jobs run out of order.
+func (s *SearchService) Notify(ctx context.Context, event events.Event) {
+	if !s.Semantic() {
+		return
+	}
+	switch event.Type {
+	case events.NoteCreated, events.NoteUpdated, events.NoteDeleted:
+	default:
+		return
+	}
+	_, err := s.runner.Submit(ctx, jobs.Task{
+		Kind:  "embedding",
+		Retry: indexRetry,
+		Run: func(ctx context.Context) (any, error) {
+			return nil, s.indexNote(ctx, event.NoteID)
+		},
+	})
+	if err != nil {
+		log.Printf("%sFailed to index note %s for semantic search: %v",
+			requestid.LogPrefix(ctx), event.NoteID, err)
+	}
+}
+
+// indexNote embeds the current version of a note and indexes it, or removes it from the
+// index if it doesn't exist anymore.
+func (s *SearchService) indexNote(ctx context.Context, id string) error {
+	note, err := s.notes.Get(ctx, id)
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		return s.index.Delete(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	return s.indexNotes(ctx, []*model.Note{note})
+}
+
+// indexNotes embeds notes with a single call of the embedder, and indexes them.
+func (s *SearchService) indexNotes(ctx context.Context, notes []*model.Note) error {
+	texts := make([]string, len(notes))
+	for i, note := range notes {
+		texts[i] = embeddedText(note)
+	}
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed notes: %w", err)
+	}
+	if len(vectors) != len(notes) {
+		return fmt.Errorf("failed to embed notes: expected %d embeddings, got %d", len(notes), len(vectors))
+	}
+	for i, note := range notes {
+		if err := s.index.Upsert(ctx, note.ID, vectors[i]); err != nil {
+			return fmt.Errorf("failed to index note %s: %w", note.ID, err)
+		}
+	}
+	return nil
+}
+
+// Reindex embeds and indexes every note, in batches, e.g., to fill an in-memory index at
+// startup or after the embedding model changed. Notes deleted meanwhile may stay in the
+// index, but are never returned by searches.
+//
+// Returns:
+//   - The number of notes indexed
+//   - The error of the storage, the embedder, or the index; the notes indexed before it
+//     stay indexed
+func (s *SearchService) Reindex(ctx context.Context) (int, error) {
+	if !s.Semantic() {
+		return 0, fmt.Errorf("%w: semantic search is not enabled", ErrInvalidSearch)
+	}
+	indexed := 0
+	batch := make([]*model.Note, 0, reindexBatch)
+	flush := func() error {
+		if err := s.indexNotes(ctx, batch); err != nil {
+			return err
+		}
+		indexed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err := s.notes.Stream(ctx, func(note *model.Note) error {
+		batch = append(batch, note)
+		if len(batch) == reindexBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	return indexed, err
+}
+
+// embeddedText returns the text of a note that is embedded: its title and the start of
+// its content.
+func embeddedText(note *model.Note) string {
+	text := note.Title + "\n\n" + note.Content
+	if len(text) > maxEmbeddedText {
+		text = strings.ToValidUTF8(text[:maxEmbeddedText], "")
+	}
+	return text
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/embedding"
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/vector"
+)
+
+// TestSearchService_Text tests text searches, and that invalid searches are rejected
+func TestSearchService_Text(t *testing.T) {
+	ctx := context.Background()
+	notes := New(storage.NewInMemoryStorage())
+	search := NewSearchService(notes)
+	for _, title := range []string{"Tomatoes", "Peppers", "More tomatoes"} {
+		if _, err := notes.Create(ctx, NoteInput{Title: title, Content: "Content"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	results, err := search.Search(ctx, "", "tomatoes", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Note.Title != "More tomatoes" || results[0].Score != 0 {
+		t.Errorf("Expected the notes about tomatoes, most recent first, got %+v", results)
+	}
+	if results, _ := search.Search(ctx, SearchText, "tomatoes", 1); len(results) != 1 {
+		t.Errorf("Expected 1 note, got %d", len(results))
+	}
+
+	for _, invalid := range []struct{ mode, query string }{{"", " "}, {"fuzzy", "tomatoes"}, {SearchSemantic, "tomatoes"}} {
+		if _, err := search.Search(ctx, invalid.mode, invalid.query, 0); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("Expected ErrInvalidSearch for %+v, got %v", invalid, err)
+		}
+	}
+	if search.Semantic() {
+		t.Error("Expected semantic search to be disabled")
+	}
+}
+
+// TestSearchService_Semantic tests that notes are indexed as they change, found by
+// similarity, and that the index can be rebuilt
+func TestSearchService_Semantic(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewBus()
+	notes := New(storage.NewInMemoryStorage(), WithPublisher(bus))
+	runner := jobs.NewRunner(1, 10)
+	defer runner.Close(ctx)
+	index := vector.NewMemoryIndex()
+	search := NewSearchService(notes, WithSemanticSearch(embedding.NewHashing(256), index, runner))
+	bus.Subscribe("search", search)
+
+	// waitFor waits until the index has the given number of vectors
+	waitFor := func(count int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); index.Len() != count; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d indexed notes, got %d", count, index.Len())
+			}
+		}
+	}
+
+	garden, err := notes.Create(ctx, NoteInput{Title: "Garden", Content: "Water the tomatoes and the peppers every morning"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	budget, err := notes.Create(ctx, NoteInput{Title: "Budget", Content: "Review the quarterly budget with finance"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	waitFor(2)
+
+	results, err := search.Search(ctx, SearchSemantic, "when to water tomatoes", 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Note.ID != garden.ID || results[0].Score <= 0 {
+		t.Errorf("Expected the garden note, got %+v", results)
+	}
+
+	// Deleted notes are removed from the index
+	if err := notes.Delete(ctx, budget.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	waitFor(1)
+
+	// Reindexing fills an empty index
+	index.Delete(ctx, garden.ID)
+	if indexed, err := search.Reindex(ctx); err != nil || indexed != 1 || index.Len() != 1 {
+		t.Errorf("Expected 1 note indexed, got %d: %v", indexed, err)
+	}
+}
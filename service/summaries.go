@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// ErrSummarizer is returned (wrapped) when the summarizer fails to summarize a note,
+// e.g., because an external API is unavailable.
+var ErrSummarizer = errors.New("summarizer failed")
+
+// maxSummaryLength is the maximum length of a stored summary, in bytes; longer summaries
+// (e.g., from a model ignoring its instructions) are cut at a word boundary.
+const maxSummaryLength = 2000
+
+// WithSummarizer enables summarizing notes with Summarize.
+func WithSummarizer(summarizer Summarizer) Option {
+	return func(s *NoteService) {
+		s.summarizer = summarizer
+	}
+}
+
+// Summarize summarizes the content of a note and stores the summary on the note, as an
+// update. The update fails with storage.ErrConflict if the note was changed while it was
+// being summarized, so a summary never describes another version of the content.
+//
+// Returns:
+//   - The note with its summary
+//   - storage.ErrNoteNotFound if the note doesn't exist, an error wrapping ErrSummarizer
+//     if the summarizer failed, or the storage error
+func (s *NoteService) Summarize(ctx context.Context, id string) (*model.Note, error) {
+	if s.summarizer == nil {
+		return nil, fmt.Errorf("%w: no summarizer is configured", ErrSummarizer)
+	}
+	note, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.summarizer.Summarize(ctx, note.Title, note.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSummarizer, err)
+	}
+
+	// Work on a copy, so a cached note is never modified in place
+	updated := *note
+	updated.Summary = truncateSummary(strings.TrimSpace(summary))
+	updated.UpdatedAt = updateTime(note, s.now())
+	if err := storage.UpdateIf(ctx, s.repository, &updated, note.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	s.publish(ctx, events.NoteUpdated, &updated)
+	return &updated, nil
+}
+
+// truncateSummary cuts a summary to maxSummaryLength bytes, at the last space before the
+// limit, and marks the cut with an ellipsis.
+func truncateSummary(summary string) string {
+	if len(summary) <= maxSummaryLength {
+		return summary
+	}
+	cut := summary[:maxSummaryLength-len("…")]
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.ToValidUTF8(cut, "") + "…"
+}
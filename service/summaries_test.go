@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/storage"
+)
+
+// summarizerFunc adapts a function to the Summarizer port
+type summarizerFunc func(ctx context.Context, title, content string) (string, error)
+
+// Summarize calls the function
+func (f summarizerFunc) Summarize(ctx context.Context, title, content string) (string, error) {
+	return f(ctx, title, content)
+}
+
+// TestNoteService_Summarize tests that summaries are stored and published, and cleared
+// when the content changes
+func TestNoteService_Summarize(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	fail := false
+	s := New(storage.NewInMemoryStorage(), WithPublisher(rec), WithSummarizer(summarizerFunc(
+		func(_ context.Context, title, content string) (string, error) {
+			if fail {
+				return "", errors.New("unavailable")
+			}
+			return " " + title + ": " + strings.Repeat(content+" ", 2) + "\n", nil
+		})))
+
+	created, err := s.Create(ctx, NoteInput{Title: "Title", Content: "Content"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	summarized, err := s.Summarize(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summarized.Summary != "Title: Content Content" || summarized.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("Expected the trimmed summary, got %+v", summarized)
+	}
+	if stored, err := s.Get(ctx, created.ID); err != nil || stored.Summary != summarized.Summary {
+		t.Errorf("Expected the summary to be stored, got %+v: %v", stored, err)
+	}
+	if types := rec.types(); len(types) != 2 || types[1] != events.NoteUpdated {
+		t.Errorf("Expected the summary to be published as an update, got %v", types)
+	}
+
+	// Changing the title keeps the summary; changing the content clears it
+	if updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Renamed", Content: "Content"}); err != nil || updated.Summary == "" {
+		t.Errorf("Expected the summary to be kept, got %+v: %v", updated, err)
+	}
+	if updated, err := s.Update(ctx, created.ID, NoteInput{Title: "Renamed", Content: "Changed"}); err != nil || updated.Summary != "" {
+		t.Errorf("Expected the summary to be cleared, got %+v: %v", updated, err)
+	}
+
+	fail = true
+	if _, err := s.Summarize(ctx, created.ID); !errors.Is(err, ErrSummarizer) {
+		t.Errorf("Expected ErrSummarizer, got %v", err)
+	}
+	if _, err := s.Summarize(ctx, "missing"); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if _, err := New(storage.NewInMemoryStorage()).Summarize(ctx, created.ID); !errors.Is(err, ErrSummarizer) {
+		t.Errorf("Expected ErrSummarizer without a summarizer, got %v", err)
+	}
+
+	if got := truncateSummary(strings.Repeat("word ", 1000)); len(got) > maxSummaryLength || !strings.HasSuffix(got, "d…") {
+		t.Errorf("Expected a summary cut at a word, got %d bytes ending with %q", len(got), got[len(got)-8:])
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Limits of the tags of a note.
+const (
+	maxTags      = 20 // Tags of a note
+	maxTagLength = 50 // Bytes of a tag
+)
+
+// tagPattern matches a tag in lower case: letters, digits, and the separators - _ / (e.g., work/reports).
+var tagPattern = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}][\p{Ll}\p{Lo}\p{N}_/-]*$`)
+
+// NormalizeTag returns a tag as it is stored: without surrounding spaces and in lower case,
+// so tags match regardless of how clients wrote them.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags checks the tags of a note, which are optional: at most maxTags tags made
+// of letters, digits, and the separators - _ / (after a letter or digit), of at most
+// maxTagLength bytes each.
+//
+// Returns:
+//   - The tags as they are stored: normalized (see NormalizeTag), sorted, and without
+//     duplicates, or nil without tags
+//   - An error wrapping ErrInvalidNote if a tag is invalid or there are too many
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w: tags must not be longer than %d bytes", ErrInvalidNote, maxTagLength)
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: invalid tag %q: tags are made of letters, digits, -, _, and /", ErrInvalidNote, tag)
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("%w: a note must not have more than %d tags", ErrInvalidNote, maxTags)
+	}
+	return normalized, nil
+}
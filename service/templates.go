@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// templatePlaceholder matches the variables of a template, e.g., {{date}} or {{ title }}.
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// TemplateVariables holds the values substituted into a template when a note is created from it.
+type TemplateVariables struct {
+	Title     string            // Value of {{title}}, typically the subject of the new note (optional)
+	Variables map[string]string // Values of custom variables, by name (optional)
+}
+
+// TemplateService manages note templates and creates notes from them. Templates have the
+// fields of a note, whose title and content may contain variables:
+//
+//   - {{date}}: the current date (e.g., 2024-01-02)
+//   - {{time}}: the current time (e.g., 15:04)
+//   - {{datetime}}: the current date and time in RFC 3339 format
+//   - {{title}}: the title given when creating the note
+//   - any other name: the value of a custom variable, if one is given
+//
+// Variables without a value are kept as written. Templates are stored in a repository of
+// their own (e.g., a separate CouchDB database or MongoDB collection), so they never
+// appear among the notes. It implements the Templates port.
+type TemplateService struct {
+	repository NoteRepository   // Storage of the templates
+	notes      *NoteService     // Service creating the notes from templates
+	now        func() time.Time // Clock for the date and time variables
+}
+
+// NewTemplateService creates a new TemplateService.
+//
+// Parameters:
+//   - repository: The storage of the templates, separate from the storage of the notes
+//   - notes: The note service that creates notes from templates
+//
+// Returns:
+//   - A pointer to a new TemplateService instance
+func NewTemplateService(repository NoteRepository, notes *NoteService) *TemplateService {
+	return &TemplateService{repository: repository, notes: notes, now: time.Now}
+}
+
+// Create creates a template with a generated ID and timestamps.
+//
+// Returns:
+//   - The created template
+//   - An error wrapping ErrInvalidNote if the input is invalid, or the storage error
+func (s *TemplateService) Create(ctx context.Context, input NoteInput) (*model.Note, error) {
+	if err := validate(input.Title, input.Content); err != nil {
+		return nil, err
+	}
+
+	template := model.NewNote(input.Title, input.Content)
+	if err := s.repository.Create(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Get retrieves a template by its ID, or returns storage.ErrNoteNotFound.
+func (s *TemplateService) Get(ctx context.Context, id string) (*model.Note, error) {
+	return s.repository.Get(ctx, id)
+}
+
+// List retrieves all templates, sorted by title.
+func (s *TemplateService) List(ctx context.Context) ([]*model.Note, error) {
+	templates, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(templates, func(a, b *model.Note) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+	return templates, nil
+}
+
+// Update sets the title and content of an existing template and its update time to now,
+// like NoteService.Update.
+//
+// Returns:
+//   - The updated template
+//   - An error wrapping ErrInvalidNote if the input is invalid, or the storage error
+//     (storage.ErrNoteNotFound if the template doesn't exist)
+func (s *TemplateService) Update(ctx context.Context, id string, input NoteInput) (*model.Note, error) {
+	if err := validate(input.Title, input.Content); err != nil {
+		return nil, err
+	}
+
+	template, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *template
+	updated.Title = input.Title
+	updated.Content = input.Content
+	updated.UpdatedAt = updateTime(template, s.now())
+	if input.Rev != "" {
+		updated.Rev = input.Rev
+	}
+
+	if err := s.repository.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete deletes a template by its ID, or returns storage.ErrNoteNotFound.
+// Notes created from the template are kept.
+func (s *TemplateService) Delete(ctx context.Context, id string) error {
+	return s.repository.Delete(ctx, id)
+}
+
+// Instantiate creates a note from a template, substituting the variables in its title and
+// content. The note is created through the note service, so it is validated and published
+// like any other note.
+//
+// Returns:
+//   - The created note
+//   - storage.ErrNoteNotFound if the template doesn't exist, an error wrapping
+//     ErrInvalidNote if the resulting note is empty, or the storage error
+func (s *TemplateService) Instantiate(ctx context.Context, id string, vars TemplateVariables) (*model.Note, error) {
+	template, err := s.repository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	values := map[string]string{
+		"date":     now.Format(time.DateOnly),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format(time.RFC3339),
+		"title":    vars.Title,
+	}
+	for name, value := range vars.Variables {
+		// The built-in variables cannot be overridden
+		if _, ok := values[name]; !ok {
+			values[name] = value
+		}
+	}
+
+	return s.notes.Create(ctx, NoteInput{
+		Title:   expandTemplate(template.Title, values),
+		Content: expandTemplate(template.Content, values),
+	})
+}
+
+// expandTemplate replaces the variables in text with their values, keeping unknown ones.
+func expandTemplate(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/storage"
+)
+
+// TestTemplateService tests managing templates apart from the notes
+func TestTemplateService(t *testing.T) {
+	ctx := context.Background()
+	notes := New(storage.NewInMemoryStorage())
+	templates := NewTemplateService(storage.NewInMemoryStorage(), notes)
+
+	if _, err := templates.Create(ctx, NoteInput{}); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote for an empty template, got %v", err)
+	}
+	meeting, err := templates.Create(ctx, NoteInput{Title: "Meeting", Content: "Agenda"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := templates.Create(ctx, NoteInput{Title: "Journal"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	list, err := templates.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Title != "Journal" || list[1].Title != "Meeting" {
+		t.Errorf("Expected the templates sorted by title, got %+v", list)
+	}
+	if all, _ := notes.GetAll(ctx); len(all) != 0 {
+		t.Errorf("Expected templates not to appear among the notes, got %+v", all)
+	}
+
+	updated, err := templates.Update(ctx, meeting.ID, NoteInput{Title: "Meeting", Content: "Agenda and minutes"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Content != "Agenda and minutes" || !updated.CreatedAt.Equal(meeting.CreatedAt) {
+		t.Errorf("Unexpected updated template: %+v", updated)
+	}
+
+	if err := templates.Delete(ctx, meeting.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := templates.Get(ctx, meeting.ID); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound after Delete, got %v", err)
+	}
+}
+
+// TestTemplateService_Instantiate tests creating notes from templates with variables
+func TestTemplateService_Instantiate(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	templates := NewTemplateService(storage.NewInMemoryStorage(), New(storage.NewInMemoryStorage(), WithPublisher(rec)))
+	templates.now = func() time.Time { return time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC) }
+
+	template, err := templates.Create(ctx, NoteInput{
+		Title:   "{{title}} ({{date}})",
+		Content: "# {{ title }}\nAt {{time}} in {{room}}, by {{author}}\n{{datetime}}",
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	note, err := templates.Instantiate(ctx, template.ID, TemplateVariables{
+		Title:     "Planning",
+		Variables: map[string]string{"room": "Room 1", "date": "ignored"},
+	})
+	if err != nil {
+		t.Fatalf("Instantiate failed: %v", err)
+	}
+	if note.Title != "Planning (2024-01-02)" {
+		t.Errorf("Unexpected title: %q", note.Title)
+	}
+	if want := "# Planning\nAt 15:04 in Room 1, by {{author}}\n2024-01-02T15:04:05Z"; note.Content != want {
+		t.Errorf("Expected content %q, got %q", want, note.Content)
+	}
+	if note.ID == template.ID {
+		t.Error("Expected the note to get an ID of its own")
+	}
+	if types := rec.types(); len(types) != 1 || types[0] != events.NoteCreated {
+		t.Errorf("Expected the note to be published as created, got %v", types)
+	}
+
+	if _, err := templates.Instantiate(ctx, "missing", TemplateVariables{}); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+
+	// A template consisting only of variables without values yields an empty note
+	empty, err := templates.Create(ctx, NoteInput{Title: "{{title}}"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := templates.Instantiate(ctx, empty.ID, TemplateVariables{}); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected ErrInvalidNote, got %v", err)
+	}
+}
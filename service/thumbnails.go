@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/thumbnail"
+)
+
+// ErrNoThumbnail is returned when an attachment has no thumbnail: thumbnails are disabled,
+// or the attachment is not an image they can be created from.
+var ErrNoThumbnail = errors.New("attachment has no thumbnail")
+
+// ErrThumbnailPending is returned when the thumbnails of an attachment are being created;
+// they can be requested again shortly.
+var ErrThumbnailPending = errors.New("thumbnail is being generated")
+
+// thumbnailPrefix starts the names of the attachments holding thumbnails, which are
+// stored next to their image as ".thumbnail-<size>-<name>". Names of uploaded attachments
+// cannot start with a dot, so they never collide.
+const thumbnailPrefix = ".thumbnail-"
+
+// thumbnailRetry is the retry policy of creating thumbnails: a single attempt, since
+// images that cannot be decoded will not be decoded on a second try either.
+var thumbnailRetry = storage.RetryPolicy{
+	MaxAttempts:    1,
+	AttemptTimeout: time.Minute,
+}
+
+// WithThumbnails creates thumbnails of the JPEG, PNG, and GIF attachments in the
+// background, as "thumbnail" jobs of a runner, scaled down to each of the given sizes
+// (in pixels, of the longer side). Without sizes, no thumbnails are created.
+func WithThumbnails(runner *jobs.Runner, sizes []int) AttachmentOption {
+	return func(s *AttachmentService) {
+		if len(sizes) == 0 {
+			return
+		}
+		s.runner = runner
+		s.sizes = slices.Sorted(slices.Values(sizes))
+	}
+}
+
+// Thumbnail returns a thumbnail of an attachment of a note with its content, which the
+// caller must close. A size of 0 selects the smallest thumbnail. Missing thumbnails of an
+// image (e.g., uploaded before thumbnails were enabled) are created in the background.
+//
+// Returns:
+//   - The attachment holding the thumbnail, and its content
+//   - An error wrapping ErrInvalidAttachment if the size is not one of the configured
+//     sizes, ErrNoThumbnail if the attachment has no thumbnail, ErrThumbnailPending if
+//     it is being created, or the storage error (storage.ErrNoteNotFound or
+//     storage.ErrAttachmentNotFound if the note or the attachment doesn't exist)
+func (s *AttachmentService) Thumbnail(ctx context.Context, noteID, name string, size int) (storage.Attachment, io.ReadCloser, error) {
+	if validateAttachmentName(name) != nil {
+		return storage.Attachment{}, nil, storage.ErrAttachmentNotFound
+	}
+	if s.runner == nil {
+		return storage.Attachment{}, nil, ErrNoThumbnail
+	}
+	if size == 0 {
+		size = s.sizes[0]
+	}
+	if !slices.Contains(s.sizes, size) {
+		return storage.Attachment{}, nil, fmt.Errorf("%w: size must be one of %v", ErrInvalidAttachment, s.sizes)
+	}
+
+	attachment, body, err := s.store.GetAttachment(ctx, noteID, thumbnailName(size, name))
+	if !errors.Is(err, storage.ErrAttachmentNotFound) {
+		return attachment, body, err
+	}
+
+	// No thumbnail yet: create them if the attachment is an image
+	source, err := s.findAttachment(ctx, noteID, name)
+	if err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	if !thumbnail.Supported(source.ContentType) {
+		return storage.Attachment{}, nil, ErrNoThumbnail
+	}
+	if digest, ok := s.failed.Load(noteID + "/" + name); ok && digest == source.Digest {
+		return storage.Attachment{}, nil, ErrNoThumbnail
+	}
+	s.submitThumbnails(ctx, noteID, source)
+	return storage.Attachment{}, nil, ErrThumbnailPending
+}
+
+// findAttachment returns the description of an attachment of a note, without its content.
+func (s *AttachmentService) findAttachment(ctx context.Context, noteID, name string) (storage.Attachment, error) {
+	attachments, err := s.store.ListAttachments(ctx, noteID)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	for _, attachment := range attachments {
+		if attachment.Name == name {
+			return attachment, nil
+		}
+	}
+	return storage.Attachment{}, storage.ErrAttachmentNotFound
+}
+
+// submitThumbnails submits a job creating the thumbnails of an image attachment, unless
+// one is already pending for the same content.
+func (s *AttachmentService) submitThumbnails(ctx context.Context, noteID string, source storage.Attachment) {
+	key := noteID + "/" + source.Name + "@" + source.Digest
+	if _, pending := s.pending.LoadOrStore(key, struct{}{}); pending {
+		return
+	}
+	_, err := s.runner.Submit(ctx, jobs.Task{
+		Kind:  "thumbnail",
+		Retry: thumbnailRetry,
+		Run: func(ctx context.Context) (any, error) {
+			return nil, s.createThumbnails(ctx, noteID, source)
+		},
+		Done: func(_ any, err error) {
+			s.pending.Delete(key)
+			if errors.Is(err, thumbnail.ErrUnsupported) {
+				s.failed.Store(noteID+"/"+source.Name, source.Digest)
+			}
+		},
+	})
+	if err != nil {
+		s.pending.Delete(key)
+		log.Printf("%sFailed to create the thumbnails of attachment %s of note %s: %v",
+			requestid.LogPrefix(ctx), source.Name, noteID, err)
+	}
+}
+
+// createThumbnails decodes an image attachment once, and stores its thumbnails of every
+// size. Nothing is done if the attachment was replaced or deleted in the meantime.
+func (s *AttachmentService) createThumbnails(ctx context.Context, noteID string, source storage.Attachment) error {
+	current, body, err := s.store.GetAttachment(ctx, noteID, source.Name)
+	if errors.Is(err, storage.ErrNoteNotFound) || errors.Is(err, storage.ErrAttachmentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if current.Digest != source.Digest {
+		return nil
+	}
+
+	img, format, err := thumbnail.Decode(body)
+	if err != nil {
+		return err
+	}
+	for _, size := range s.sizes {
+		var buf bytes.Buffer
+		contentType, err := thumbnail.Encode(&buf, thumbnail.Scale(img, size), format)
+		if err != nil {
+			return fmt.Errorf("failed to encode the %d pixels thumbnail: %w", size, err)
+		}
+		attachment := storage.Attachment{Name: thumbnailName(size, source.Name), ContentType: contentType}
+		if _, err := s.store.PutAttachment(ctx, noteID, attachment, bytes.NewReader(buf.Bytes())); err != nil {
+			if errors.Is(err, storage.ErrNoteNotFound) {
+				return nil
+			}
+			return fmt.Errorf("failed to store the %d pixels thumbnail: %w", size, err)
+		}
+		s.invalidate(ctx, noteID)
+	}
+	return nil
+}
+
+// removeThumbnails deletes the thumbnails of an attachment that was replaced or deleted.
+// Failures are logged: stale thumbnails are replaced when the attachment is uploaded again.
+func (s *AttachmentService) removeThumbnails(ctx context.Context, noteID, name string) {
+	attachments, err := s.store.ListAttachments(ctx, noteID)
+	if err != nil {
+		log.Printf("%sFailed to list the thumbnails of attachment %s of note %s: %v",
+			requestid.LogPrefix(ctx), name, noteID, err)
+		return
+	}
+	for _, attachment := range attachments {
+		if !isThumbnailOf(attachment.Name, name) {
+			continue
+		}
+		err := s.store.DeleteAttachment(ctx, noteID, attachment.Name)
+		if err != nil && !errors.Is(err, storage.ErrAttachmentNotFound) {
+			log.Printf("%sFailed to delete thumbnail %s of note %s: %v",
+				requestid.LogPrefix(ctx), attachment.Name, noteID, err)
+			continue
+		}
+		s.invalidate(ctx, noteID)
+	}
+}
+
+// thumbnailName returns the name of the attachment holding a thumbnail of an attachment.
+func thumbnailName(size int, name string) string {
+	return thumbnailPrefix + strconv.Itoa(size) + "-" + name
+}
+
+// isThumbnailOf reports whether an attachment holds a thumbnail of any size of another.
+func isThumbnailOf(thumbnailName, name string) bool {
+	rest, ok := strings.CutPrefix(thumbnailName, thumbnailPrefix)
+	if !ok {
+		return false
+	}
+	size, rest, ok := strings.Cut(rest, "-")
+	if _, err := strconv.Atoi(size); err != nil || !ok {
+		return false
+	}
+	return rest == name
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// TestThumbnails tests that thumbnails of image attachments are created in the background,
+// hidden from the list of attachments, and removed with their image
+func TestThumbnails(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewInMemoryStorage()
+	runner := jobs.NewRunner(1, 10)
+	defer runner.Close(ctx)
+	attachments := NewAttachmentService(backend, nil, WithThumbnails(runner, []int{32, 16}))
+	note := model.NewNote("Title", "Content")
+	if err := backend.Create(ctx, note); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 64, 32))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	put := func(name, contentType string, body []byte) {
+		t.Helper()
+		if _, err := attachments.Put(ctx, note.ID, storage.Attachment{Name: name, ContentType: contentType}, bytes.NewReader(body)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	// thumbnail waits until the thumbnails of an attachment have been created
+	thumbnail := func(name string, size int) (image.Config, error) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			attachment, body, err := attachments.Thumbnail(ctx, note.ID, name, size)
+			if errors.Is(err, ErrThumbnailPending) && time.Now().Before(deadline) {
+				continue
+			}
+			if err != nil {
+				return image.Config{}, err
+			}
+			defer body.Close()
+			if attachment.ContentType != "image/png" {
+				t.Errorf("Expected a PNG thumbnail, got %q", attachment.ContentType)
+			}
+			config, err := png.DecodeConfig(body)
+			if err != nil {
+				t.Fatalf("Failed to decode the thumbnail: %v", err)
+			}
+			return config, nil
+		}
+	}
+	put("image.png", "image/png", img.Bytes())
+
+	// The smallest size by default
+	for size, width := range map[int]int{0: 16, 16: 16, 32: 32} {
+		if config, err := thumbnail("image.png", size); err != nil || config.Width != width || config.Height != width/2 {
+			t.Errorf("Size %d: expected a %dx%d thumbnail, got %+v: %v", size, width, width/2, config, err)
+		}
+	}
+	if _, err := thumbnail("image.png", 20); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("Expected ErrInvalidAttachment for an unknown size, got %v", err)
+	}
+	if list, err := attachments.List(ctx, note.ID); err != nil || len(list) != 1 || list[0].Name != "image.png" {
+		t.Errorf("Expected only the image to be listed, got %+v: %v", list, err)
+	}
+
+	// Files that are not images, or cannot be decoded, have no thumbnail
+	put("text.txt", "text/plain", []byte("text"))
+	put("broken.png", "image/png", []byte("not an image"))
+	for _, name := range []string{"text.txt", "broken.png"} {
+		if _, err := thumbnail(name, 0); !errors.Is(err, ErrNoThumbnail) {
+			t.Errorf("%s: expected ErrNoThumbnail, got %v", name, err)
+		}
+	}
+	if _, err := thumbnail("missing.png", 0); !errors.Is(err, storage.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+	}
+
+	// Thumbnails are deleted with their image
+	if err := attachments.Delete(ctx, note.ID, "image.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	stored, err := backend.ListAttachments(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("ListAttachments failed: %v", err)
+	}
+	for _, attachment := range stored {
+		if strings.HasPrefix(attachment.Name, thumbnailPrefix) {
+			t.Errorf("Expected the thumbnails to be deleted, got %s", attachment.Name)
+		}
+	}
+
+	// Without thumbnails, there are none
+	_, _, err = NewAttachmentService(backend, nil).Thumbnail(ctx, note.ID, "broken.png", 0)
+	if !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("Expected ErrNoThumbnail, got %v", err)
+	}
+}
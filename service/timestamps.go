@@ -0,0 +1,24 @@
+package service
+
+import (
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// updateTime returns the update time of a new version of a note: now, in UTC, unless it
+// is less than a millisecond after the note's creation or update time (e.g., for two
+// updates within a millisecond, or a clock behind the clock of another instance), then a
+// millisecond after it. Update times thus always increase and never precede creation
+// times, so conditional updates (see storage.UpdateIf) tell the versions of a note apart;
+// MongoDB keeps milliseconds, hence the step.
+func updateTime(note *model.Note, now time.Time) time.Time {
+	latest := note.UpdatedAt
+	if note.CreatedAt.After(latest) {
+		latest = note.CreatedAt
+	}
+	if next := latest.Add(time.Millisecond); now.Before(next) {
+		return next.UTC()
+	}
+	return now.UTC()
+}
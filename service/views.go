@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// viewBatchSize is the number of notes of a streamed list whose views are read at once.
+const viewBatchSize = 100
+
+// viewsCleanupRetry is the retry policy of removing the views of a deleted note.
+var viewsCleanupRetry = storage.RetryPolicy{
+	MaxAttempts:    3,
+	InitialDelay:   time.Second,
+	MaxDelay:       10 * time.Second,
+	AttemptTimeout: 30 * time.Second,
+}
+
+// ViewCounter counts how many times clients viewed every note, and when they last did.
+// Views are added up in memory and written to a repository of their own by Flush, one
+// write per note however many times it was viewed, so viewing a note doesn't cost a write,
+// and doesn't change the note itself (its update time, its revision) or publish an update.
+// It also implements events.Subscriber, to remove the views of deleted notes. It is safe
+// for concurrent use.
+//
+// Counts are approximate: views not flushed yet are lost if the process stops without
+// flushing, and instances sharing the repository may overwrite each other's flushes of
+// the same note if the backend doesn't track revisions (MongoDB).
+type ViewCounter struct {
+	repository NoteRepository // Storage of the views, one record per viewed note, by note ID
+	runner     *jobs.Runner   // Runs the removal of the views of deleted notes
+
+	mutex   sync.Mutex           // Protects pending
+	pending map[string]noteViews // Views not flushed yet, by note ID
+}
+
+// noteViews are the views of a note, stored as the JSON content of its record.
+type noteViews struct {
+	Count          int64     `json:"count"`
+	LastAccessedAt time.Time `json:"last_accessed_at"`
+}
+
+// add returns the views of both.
+func (v noteViews) add(other noteViews) noteViews {
+	v.Count += other.Count
+	if other.LastAccessedAt.After(v.LastAccessedAt) {
+		v.LastAccessedAt = other.LastAccessedAt
+	}
+	return v
+}
+
+// NewViewCounter creates a new ViewCounter.
+//
+// Parameters:
+//   - repository: The storage of the views, separate from the storage of the notes
+//   - runner: The job runner that removes the views of deleted notes
+//
+// Returns:
+//   - A pointer to a new ViewCounter instance
+func NewViewCounter(repository NoteRepository, runner *jobs.Runner) *ViewCounter {
+	return &ViewCounter{
+		repository: repository,
+		runner:     runner,
+		pending:    make(map[string]noteViews),
+	}
+}
+
+// WithViewCounter counts the views of the notes read with View, and sets the views of the
+// notes returned by View, List, and StreamList. Lists sorted by storage.SortMostViewed
+// read every matching note, to sort them once their views are known.
+func WithViewCounter(views *ViewCounter) Option {
+	return func(s *NoteService) {
+		s.views = views
+	}
+}
+
+// Record counts a view of a note, now.
+func (c *ViewCounter) Record(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pending[id] = c.pending[id].add(noteViews{Count: 1, LastAccessedAt: time.Now().UTC()})
+}
+
+// Flush adds the views recorded since the last flush to the stored views. The views of
+// notes that can't be written are kept for the next flush.
+//
+// Returns:
+//   - The number of notes whose views were written
+//   - The errors of the notes whose views weren't written, joined
+func (c *ViewCounter) Flush(ctx context.Context) (int, error) {
+	c.mutex.Lock()
+	pending := c.pending
+	c.pending = make(map[string]noteViews)
+	c.mutex.Unlock()
+
+	written := 0
+	var errs []error
+	for id, views := range pending {
+		if err := c.write(ctx, id, views); err != nil {
+			errs = append(errs, fmt.Errorf("note %s: %w", id, err))
+			c.mutex.Lock()
+			c.pending[id] = c.pending[id].add(views)
+			c.mutex.Unlock()
+			continue
+		}
+		written++
+	}
+	return written, errors.Join(errs...)
+}
+
+// write adds views to the stored views of a note.
+func (c *ViewCounter) write(ctx context.Context, id string, views noteViews) error {
+	now := time.Now()
+	record, err := c.repository.Get(ctx, id)
+	if errors.Is(err, storage.ErrNoteNotFound) {
+		data, err := json.Marshal(views)
+		if err != nil {
+			return err
+		}
+		return c.repository.Create(ctx, &model.Note{ID: id, Content: string(data), CreatedAt: now, UpdatedAt: now})
+	}
+	if err != nil {
+		return err
+	}
+	stored, err := decodeViews(record)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(stored.add(views))
+	if err != nil {
+		return err
+	}
+	updated := *record
+	updated.Content = string(data)
+	updated.UpdatedAt = updateTime(record, now)
+	return c.repository.Update(ctx, &updated)
+}
+
+// decodeViews decodes the views of a record.
+func decodeViews(record *model.Note) (noteViews, error) {
+	var views noteViews
+	if err := json.Unmarshal([]byte(record.Content), &views); err != nil {
+		return noteViews{}, fmt.Errorf("invalid views record: %w", err)
+	}
+	return views, nil
+}
+
+// Run flushes the views every interval, logging failures, until the context is canceled.
+// It doesn't flush when it stops; call Flush once no more views are recorded.
+func (c *ViewCounter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to write the views of notes, retrying at the next flush: %v", err)
+			}
+		}
+	}
+}
+
+// attach sets the views of notes: the stored ones, and those not flushed yet. Notes that
+// were viewed are replaced with copies, so a cached note is never modified in place.
+func (c *ViewCounter) attach(ctx context.Context, notes []*model.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	ids := make([]string, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+	}
+	records, err := storage.GetMany(ctx, c.repository, ids)
+	if err != nil {
+		return fmt.Errorf("failed to read the views of notes: %w", err)
+	}
+	views := make(map[string]noteViews, len(records))
+	for _, record := range records {
+		if views[record.ID], err = decodeViews(record); err != nil {
+			return fmt.Errorf("failed to read the views of note %s: %w", record.ID, err)
+		}
+	}
+
+	c.mutex.Lock()
+	for _, id := range ids {
+		views[id] = views[id].add(c.pending[id])
+	}
+	c.mutex.Unlock()
+
+	for i, note := range notes {
+		if v := views[note.ID]; v.Count > 0 {
+			viewed := *note
+			viewed.Views, viewed.LastAccessedAt = v.Count, v.LastAccessedAt
+			notes[i] = &viewed
+		}
+	}
+	return nil
+}
+
+// Notify removes the views of the note of a deleted event. The storage is written by a
+// "views-cleanup" job, retried a few times if it fails, so the publisher isn't blocked.
+func (c *ViewCounter) Notify(ctx context.Context, event events.Event) {
+	if event.Type != events.NoteDeleted {
+		return
+	}
+	c.mutex.Lock()
+	delete(c.pending, event.NoteID)
+	c.mutex.Unlock()
+
+	_, err := c.runner.Submit(ctx, jobs.Task{
+		Kind:  "views-cleanup",
+		Retry: viewsCleanupRetry,
+		Run: func(ctx context.Context) (any, error) {
+			if err := c.repository.Delete(ctx, event.NoteID); err != nil && !errors.Is(err, storage.ErrNoteNotFound) {
+				return nil, err
+			}
+			return nil, nil
+		},
+	})
+	if err != nil {
+		log.Printf("%sFailed to delete the views of note %s: %v", requestid.LogPrefix(ctx), event.NoteID, err)
+	}
+}
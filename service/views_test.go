@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// writeCountingStorage is an in-memory storage that counts its writes, and fails them
+// while failing is set
+type writeCountingStorage struct {
+	*storage.InMemoryStorage
+	writes  atomic.Int32
+	failing atomic.Bool
+}
+
+func (s *writeCountingStorage) Create(ctx context.Context, note *model.Note) error {
+	if s.failing.Load() {
+		return errors.New("unavailable")
+	}
+	s.writes.Add(1)
+	return s.InMemoryStorage.Create(ctx, note)
+}
+
+func (s *writeCountingStorage) Update(ctx context.Context, note *model.Note) error {
+	if s.failing.Load() {
+		return errors.New("unavailable")
+	}
+	s.writes.Add(1)
+	return s.InMemoryStorage.Update(ctx, note)
+}
+
+// viewedTitles returns the titles of notes, in order
+func viewedTitles(notes []*model.Note) []string {
+	titles := make([]string, len(notes))
+	for i, note := range notes {
+		titles[i] = note.Title
+	}
+	return titles
+}
+
+// TestViewCounter tests that views are counted by View only, written in batches, and
+// removed with their note
+func TestViewCounter(t *testing.T) {
+	ctx := context.Background()
+	repository := &writeCountingStorage{InMemoryStorage: storage.NewInMemoryStorage()}
+	runner := jobs.NewRunner(1, 10)
+	views := NewViewCounter(repository, runner)
+	notes := New(storage.NewInMemoryStorage(), WithViewCounter(views))
+
+	var created []*model.Note
+	for _, title := range []string{"Rarely", "Often", "Never"} {
+		note, err := notes.Create(ctx, NoteInput{Title: title})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		created = append(created, note)
+	}
+	rarely, often := created[0], created[1]
+	view := func(id string) *model.Note {
+		t.Helper()
+		note, err := notes.View(ctx, id)
+		if err != nil {
+			t.Fatalf("View failed: %v", err)
+		}
+		return note
+	}
+
+	// Views are counted before they are written, and Get doesn't count
+	view(rarely.ID)
+	for i := 0; i < 3; i++ {
+		view(often.ID)
+	}
+	if note := view(often.ID); note.Views != 4 || note.LastAccessedAt.IsZero() {
+		t.Errorf("Expected the view to be counted, got %d views at %v", note.Views, note.LastAccessedAt)
+	}
+	if note, _ := notes.Get(ctx, often.ID); note.Views != 0 {
+		t.Errorf("Expected Get to leave views unset, got %d", note.Views)
+	}
+	if _, err := notes.View(ctx, "missing"); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+
+	// Flushing writes each viewed note once, however often it was viewed
+	if written, err := views.Flush(ctx); err != nil || written != 2 {
+		t.Fatalf("Expected the views of 2 notes to be written, got %d (%v)", written, err)
+	}
+	if writes := repository.writes.Load(); writes != 2 {
+		t.Errorf("Expected 2 writes, got %d", writes)
+	}
+	view(often.ID)
+	if _, err := views.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if note := view(often.ID); note.Views != 6 {
+		t.Errorf("Expected the stored and pending views to add up, got %d", note.Views)
+	}
+
+	// Views that fail to be written are kept for the next flush
+	repository.failing.Store(true)
+	if written, err := views.Flush(ctx); err == nil || written != 0 {
+		t.Errorf("Expected the flush to fail, got %d written (%v)", written, err)
+	}
+	repository.failing.Store(false)
+	if _, err := views.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if note := view(often.ID); note.Views != 7 {
+		t.Errorf("Expected the failed views to be kept, got %d", note.Views)
+	}
+
+	// Lists have the views of their notes, and can be sorted by them
+	list, err := notes.List(ctx, storage.ListOptions{Sort: storage.SortMostViewed})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if got := viewedTitles(list); len(got) != 3 || got[0] != "Often" || got[1] != "Rarely" || got[2] != "Never" {
+		t.Errorf("Expected the most viewed notes first, got %v", got)
+	}
+	if list[0].Views != 7 || list[1].Views != 1 || list[2].Views != 0 {
+		t.Errorf("Expected the views of the notes, got %d, %d, and %d", list[0].Views, list[1].Views, list[2].Views)
+	}
+	page, err := notes.List(ctx, storage.ListOptions{Sort: storage.SortMostViewed, Descending: true, Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if got := viewedTitles(page); len(got) != 1 || got[0] != "Rarely" {
+		t.Errorf("Expected the page to be taken after sorting, got %v", got)
+	}
+	var streamed []string
+	err = notes.StreamList(ctx, storage.ListOptions{Sort: storage.SortTitle}, func(note *model.Note) error {
+		if note.ID == often.ID && note.Views != 7 {
+			t.Errorf("Expected streamed notes to have their views, got %d", note.Views)
+		}
+		streamed = append(streamed, note.Title)
+		return nil
+	})
+	if err != nil || len(streamed) != 3 {
+		t.Errorf("Expected 3 streamed notes, got %v (%v)", streamed, err)
+	}
+
+	// The views are removed with the note
+	if err := notes.Delete(ctx, often.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	views.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: often.ID})
+	if err := runner.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := repository.Get(ctx, often.ID); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected the views to be deleted with the note, got %v", err)
+	}
+	if _, err := repository.Get(ctx, rarely.ID); err != nil {
+		t.Errorf("Expected the views of other notes to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// shutdownHookTimeout is the maximum time a single shutdown hook may take.
+	shutdownHookTimeout = 5 * time.Second
+
+	// shutdownTimeout is the maximum time the shutdown sequence may take, in addition to
+	// the time allowed for draining in-flight requests (Config.ShutdownDrainTimeout).
+	shutdownTimeout = 30 * time.Second
+)
+
+// shutdownHook is a named cleanup function registered with OnShutdown.
+type shutdownHook struct {
+	name    string                          // Name used in log messages
+	fn      func(ctx context.Context) error // Function releasing the component's resources
+	timeout time.Duration                   // Maximum time the hook may take
+}
+
+// OnShutdown registers a function to be called when the application shuts down.
+// Hooks are run in reverse order of registration, so a component registered after
+// the components it depends on (e.g., a server that uses the storage) is stopped first.
+//
+// Each hook gets its own timeout (shutdownHookTimeout). A hook that doesn't return
+// within it is abandoned, so one stuck component cannot block the rest of the shutdown.
+//
+// Parameters:
+//   - name: A short name of the component, used in log messages (e.g., "storage")
+//   - fn: The function releasing the component's resources
+func (a *App) OnShutdown(name string, fn func(ctx context.Context) error) {
+	a.OnShutdownWithTimeout(name, shutdownHookTimeout, fn)
+}
+
+// OnShutdownWithTimeout is like OnShutdown, but gives the hook a timeout of its own,
+// for hooks that may legitimately take longer (e.g., draining in-flight requests).
+// A timeout of zero or less uses the default one of OnShutdown.
+//
+// Parameters:
+//   - name: A short name of the component, used in log messages
+//   - timeout: The maximum time the hook may take
+//   - fn: The function releasing the component's resources
+func (a *App) OnShutdownWithTimeout(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	if timeout <= 0 {
+		timeout = shutdownHookTimeout
+	}
+	a.hooksMutex.Lock()
+	defer a.hooksMutex.Unlock()
+	a.hooks = append(a.hooks, shutdownHook{name: name, fn: fn, timeout: timeout})
+}
+
+// Shutdown runs all registered shutdown hooks in reverse order of registration.
+// Every hook is run even if earlier ones fail; failures and timeouts are logged.
+// Hooks are run only once, so calling Shutdown again is a no-op.
+//
+// Parameters:
+//   - ctx: The context bounding the whole shutdown sequence
+//
+// Returns:
+//   - An error joining the errors of all hooks that failed or timed out, or nil
+func (a *App) Shutdown(ctx context.Context) error {
+	a.hooksMutex.Lock()
+	hooks := a.hooks
+	a.hooks = nil
+	a.hooksMutex.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := runShutdownHook(ctx, hooks[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runShutdownHook runs a single hook with its own timeout and logs the outcome.
+func runShutdownHook(ctx context.Context, hook shutdownHook) error {
+	hookCtx, cancel := context.WithTimeout(ctx, hook.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.fn(hookCtx)
+	}()
+
+	// Don't wait for hooks that ignore their context
+	var err error
+	select {
+	case err = <-done:
+	case <-hookCtx.Done():
+		err = hookCtx.Err()
+	}
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("Shutdown of %s failed after %v: %v", hook.name, elapsed, err)
+		return fmt.Errorf("%s: %w", hook.name, err)
+	}
+	log.Printf("Shutdown of %s completed in %v", hook.name, elapsed)
+	return nil
+}
+
+// drainServers stops the REST and gRPC servers from accepting requests, then waits for
+// the requests in flight on both, logging how many are left while it waits. Both servers
+// stop accepting at once, so clients are not sent from a draining server to one that will
+// shut down next.
+func (a *App) drainServers(ctx context.Context) error {
+	a.restListening.Store(false) // Fail readiness while in-flight requests complete
+
+	// Shutdown closes the listeners right away, then waits for active connections to become idle
+	restDone := make(chan error, 1)
+	go func() { restDone <- a.restServer.Shutdown(ctx) }()
+	grpcErr := a.grpcServer.Shutdown(ctx)
+	restErr := a.restRequests.Wait(ctx, "REST")
+	if err := <-restDone; err != nil && restErr == nil {
+		restErr = err
+	}
+
+	if restErr == nil && grpcErr == nil {
+		log.Println("All in-flight requests completed")
+	}
+	return errors.Join(restErr, grpcErr)
+}
+
+// abort runs the shutdown hooks after the startup has failed, releasing what Initialize
+// has set up, since the application won't run.
+func (a *App) abort(ctx context.Context) {
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	// Failures are logged by the hooks runner
+	_ = a.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/grpc"
+	"golang-simple-notes/rest"
+	"golang-simple-notes/service"
+	"golang-simple-notes/storage"
+)
+
+// TestApp_Shutdown_ReverseOrder tests that hooks run in reverse order of registration, only once
+func TestApp_Shutdown_ReverseOrder(t *testing.T) {
+	app := NewApp(&Config{})
+
+	var order []string
+	for _, name := range []string{"first", "second", "third"} {
+		app.OnShutdown(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if want := []string{"third", "second", "first"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected hooks to run in order %v, got %v", want, order)
+	}
+
+	// A second shutdown must not run the hooks again
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Second shutdown failed: %v", err)
+	}
+	if len(order) != 3 {
+		t.Errorf("Expected hooks to run once, got %v", order)
+	}
+}
+
+// TestApp_Shutdown_Errors tests that a failing hook doesn't stop the remaining hooks
+func TestApp_Shutdown_Errors(t *testing.T) {
+	app := NewApp(&Config{})
+	errStorage := errors.New("connection reset")
+
+	ran := false
+	app.OnShutdown("storage", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	app.OnShutdown("broken", func(ctx context.Context) error {
+		return errStorage
+	})
+
+	logs := captureAppLog(t)
+	err := app.Shutdown(context.Background())
+	if !errors.Is(err, errStorage) {
+		t.Errorf("Expected the hook error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the error to name the hook, got %v", err)
+	}
+	if !ran {
+		t.Error("Expected the remaining hook to run after a failure")
+	}
+	if !strings.Contains(logs.String(), "Shutdown of broken failed") {
+		t.Errorf("Expected the failure to be logged, got %q", logs.String())
+	}
+}
+
+// TestApp_Shutdown_HookTimeout tests that a hook ignoring its context is abandoned after its timeout
+func TestApp_Shutdown_HookTimeout(t *testing.T) {
+	app := NewApp(&Config{})
+
+	ran := make(chan struct{}, 1)
+	app.OnShutdown("storage", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	block := make(chan struct{})
+	defer close(block)
+	app.OnShutdown("stuck", func(ctx context.Context) error {
+		<-block // Ignores the context
+		return nil
+	})
+
+	// The overall deadline is shorter than the per-hook timeout and applies to every hook
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := app.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown waited too long for a stuck hook: %v", elapsed)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Error("Expected the remaining hook to be attempted after a timeout")
+	}
+}
+
+// TestApp_Initialize_RegistersShutdownHooks tests that Initialize registers a hook for every component
+func TestApp_Initialize_RegistersShutdownHooks(t *testing.T) {
+	app := NewApp(&Config{
+		StorageType: "memory",
+		RESTPort:    ":0",
+		GRPCPort:    ":0",
+		DebugAddr:   "localhost:0",
+	})
+	if err := app.Initialize(context.Background()); err != nil {
+		t.Fatalf("Failed to initialize app: %v", err)
+	}
+
+	var names []string
+	for _, hook := range app.hooks {
+		names = append(names, hook.name)
+	}
+	want := []string{"tracing", "template storage", "collaboration storage", "storage", "background jobs",
+		"watch callbacks", "webhook deliveries", "REST and gRPC servers", "debug server"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected hooks %v, got %v", want, names)
+	}
+
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+}
+
+// captureAppLog redirects the standard logger to a buffer for the duration of the test.
+func captureAppLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+// TestApp_DrainServers tests that shutdown waits for in-flight REST requests before returning
+func TestApp_DrainServers(t *testing.T) {
+	app := NewApp(&Config{})
+	app.grpcServer = grpc.NewServer(service.New(storage.NewInMemoryStorage()), 0)
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	app.restServer = &http.Server{Handler: rest.InFlightMiddleware(&app.restRequests)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		}))}
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() { _ = app.restServer.Serve(listener) }()
+
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/api/notes")
+		if err != nil {
+			responded <- 0
+			return
+		}
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+	<-entered
+
+	logs := captureAppLog(t)
+	drained := make(chan error, 1)
+	go func() { drained <- app.drainServers(context.Background()) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("Expected the drain to wait for the in-flight request, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-drained; err != nil {
+		t.Errorf("Drain failed: %v", err)
+	}
+	if code := <-responded; code != http.StatusOK {
+		t.Errorf("Expected the in-flight request to complete, got status %d", code)
+	}
+	if !strings.Contains(logs.String(), "All in-flight requests completed") {
+		t.Errorf("Expected the drain to be logged, got %q", logs.String())
+	}
+}
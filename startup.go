@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// dependency is an external service that must be reachable before requests are served.
+type dependency struct {
+	name  string                          // Name used in log messages (e.g., "storage")
+	check func(ctx context.Context) error // Returns nil once the service is reachable
+}
+
+// dependencies returns the services the application depends on: the storage backend
+// and the message brokers that are enabled.
+func (a *App) dependencies() []dependency {
+	deps := []dependency{{"storage", a.storage.Ping}}
+	if a.kafka != nil {
+		deps = append(deps, dependency{"Kafka", a.kafka.Ping})
+	}
+	if a.nats != nil {
+		deps = append(deps, dependency{"NATS", a.nats.Ping})
+	}
+	if a.rabbitmq != nil {
+		deps = append(deps, dependency{"RabbitMQ", a.rabbitmq.Ping})
+	}
+	return deps
+}
+
+// waitForDependencies waits until every dependency is reachable, checking each one
+// every StartupWaitInterval, for at most StartupWaitTimeout in total. It is called
+// before the servers bind their ports, so a load balancer never routes requests to
+// an instance whose dependencies are still starting. A timeout of zero skips the wait.
+//
+// Returns:
+//   - An error naming the first dependency that isn't reachable in time, or the
+//     context's error if it is canceled first
+func (a *App) waitForDependencies(ctx context.Context) error {
+	if a.config.StartupWaitTimeout <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.config.StartupWaitTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, dep := range a.dependencies() {
+		if err := waitForDependency(ctx, dep, a.config.StartupWaitInterval); err != nil {
+			return err
+		}
+	}
+	log.Printf("All dependencies are reachable (waited %v)", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// waitForDependency checks a dependency until it is reachable or the context ends.
+func waitForDependency(ctx context.Context, dep dependency, interval time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		err := dep.check(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Dependency %s is reachable after %d attempts", dep.name, attempt)
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s is not reachable: %v: %w", dep.name, err, ctx.Err())
+		}
+		log.Printf("Waiting for dependency %s: %v; retrying in %v", dep.name, err, interval)
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return fmt.Errorf("%s is not reachable: %v: %w", dep.name, err, ctx.Err())
+		}
+	}
+}
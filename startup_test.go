@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/broker"
+	"golang-simple-notes/storage"
+)
+
+func TestApp_WaitForDependencies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Disabled", func(t *testing.T) {
+		// The storage isn't even set, so it would fail if it were checked
+		app := NewApp(&Config{})
+		if err := app.waitForDependencies(ctx); err != nil {
+			t.Errorf("Expected no wait, got %v", err)
+		}
+	})
+
+	t.Run("Reachable", func(t *testing.T) {
+		app := NewApp(&Config{StartupWaitTimeout: time.Second, StartupWaitInterval: 10 * time.Millisecond})
+		app.storage = storage.NewInMemoryStorage()
+		if err := app.waitForDependencies(ctx); err != nil {
+			t.Errorf("Expected in-memory storage to be reachable, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		// Nothing listens on port 1
+		kafka, err := broker.NewKafkaPublisher([]string{"127.0.0.1:1"}, "notes.events", "")
+		if err != nil {
+			t.Fatalf("NewKafkaPublisher failed: %v", err)
+		}
+		app := NewApp(&Config{StartupWaitTimeout: 200 * time.Millisecond, StartupWaitInterval: 50 * time.Millisecond})
+		app.storage = storage.NewInMemoryStorage()
+		app.kafka = kafka
+
+		err = app.waitForDependencies(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "Kafka is not reachable") {
+			t.Errorf("Expected Kafka to time out, got %v", err)
+		}
+	})
+}
+
+func TestWaitForDependency(t *testing.T) {
+	attempts := 0
+	dep := dependency{"flaky", func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}
+
+	if err := waitForDependency(context.Background(), dep, time.Millisecond); err != nil {
+		t.Fatalf("Expected the dependency to become reachable, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// A canceled context ends the wait, reporting the last error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err := waitForDependency(ctx, dep, time.Hour)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the wait to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// This file contains the attachments of notes: files stored with a note by backends that
+// support them natively (CouchDB document attachments), without a separate blob store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ErrAttachmentNotFound is returned when a note has no attachment with the requested name.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Attachment describes a file attached to a note.
+type Attachment struct {
+	Name        string `json:"name"`             // Name of the attachment, unique per note
+	ContentType string `json:"content_type"`     // MIME type of the content
+	Size        int64  `json:"size"`             // Size of the content, in bytes; -1 if unknown
+	Digest      string `json:"digest,omitempty"` // Hash of the content reported by the backend (e.g., md5-<base64>), if any
+}
+
+// AttachmentStore is implemented by storage backends that store files with the notes.
+// Attachments belong to their note: they are removed when the note is deleted.
+type AttachmentStore interface {
+	// PutAttachment stores the body as an attachment of a note, replacing an attachment
+	// with the same name. The Name, ContentType, and Size of the attachment describe the
+	// body; the stored attachment is returned. It returns ErrNoteNotFound if the note
+	// doesn't exist.
+	PutAttachment(ctx context.Context, noteID string, attachment Attachment, body io.Reader) (Attachment, error)
+
+	// GetAttachment returns an attachment of a note and its content, which the caller must
+	// close. It returns ErrNoteNotFound if the note doesn't exist, and
+	// ErrAttachmentNotFound if the note has no attachment with the name.
+	GetAttachment(ctx context.Context, noteID, name string) (Attachment, io.ReadCloser, error)
+
+	// DeleteAttachment removes an attachment of a note. It returns ErrNoteNotFound if the
+	// note doesn't exist, and ErrAttachmentNotFound if it has no attachment with the name.
+	DeleteAttachment(ctx context.Context, noteID, name string) error
+
+	// ListAttachments returns the attachments of a note, sorted by name. It returns
+	// ErrNoteNotFound if the note doesn't exist.
+	ListAttachments(ctx context.Context, noteID string) ([]Attachment, error)
+}
+
+// BatchAttachmentLister is implemented by attachment stores that can list the attachments
+// of several notes at once (e.g., with a single database query).
+type BatchAttachmentLister interface {
+	// ListAttachmentsMany returns the attachments of the notes with the given IDs, sorted
+	// by name, by note ID. Notes that don't exist are left out.
+	ListAttachmentsMany(ctx context.Context, noteIDs []string) (map[string][]Attachment, error)
+}
+
+// ListAttachmentsMany returns the attachments of several notes, sorted by name, by note ID.
+// Notes that don't exist are left out. Stores that implement BatchAttachmentLister list
+// them at once; for the others, they are listed note by note.
+func ListAttachmentsMany(ctx context.Context, store AttachmentStore, noteIDs []string) (map[string][]Attachment, error) {
+	if lister, ok := store.(BatchAttachmentLister); ok {
+		return lister.ListAttachmentsMany(ctx, noteIDs)
+	}
+	attachments := make(map[string][]Attachment, len(noteIDs))
+	for _, id := range uniqueIDs(noteIDs) {
+		list, err := store.ListAttachments(ctx, id)
+		if errors.Is(err, ErrNoteNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		attachments[id] = list
+	}
+	return attachments, nil
+}
+
+// sortAttachments sorts attachments by name.
+func sortAttachments(attachments []Attachment) {
+	slices.SortFunc(attachments, func(a, b Attachment) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,88 @@
+// This file contains batch reads: fetching several notes by ID in a single round trip,
+// instead of one Get per note.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"golang-simple-notes/model"
+)
+
+// NoteGetter is the part of NoteStorage that reads a single note. GetMany only needs
+// this, so it also works on repositories that don't expose the whole interface.
+type NoteGetter interface {
+	// Get retrieves a note by its ID, or returns ErrNoteNotFound.
+	Get(ctx context.Context, id string) (*model.Note, error)
+}
+
+// BatchGetter is implemented by storage backends that can read several notes by ID at
+// once (e.g., with a single database query).
+type BatchGetter interface {
+	// GetMany returns the notes with the given IDs, in the order of the IDs. IDs without
+	// a note are skipped, and every note is returned once, even if its ID is repeated.
+	GetMany(ctx context.Context, ids []string) ([]*model.Note, error)
+}
+
+// GetMany returns the notes of the backend with the given IDs, in the order of the IDs.
+// IDs without a note are skipped, and repeated IDs return the note once. Backends that
+// implement BatchGetter read the notes at once; for the others, they are read one by one.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement BatchGetter
+//   - ids: The IDs of the notes
+//
+// Returns:
+//   - The notes found, which may be fewer than the IDs
+//   - An error if the notes cannot be read
+func GetMany(ctx context.Context, backend NoteGetter, ids []string) ([]*model.Note, error) {
+	if len(ids) == 0 {
+		return []*model.Note{}, nil
+	}
+	if getter, ok := backend.(BatchGetter); ok {
+		return getter.GetMany(ctx, ids)
+	}
+
+	notes := make([]*model.Note, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		note, err := backend.Get(ctx, id)
+		if errors.Is(err, ErrNoteNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// uniqueIDs returns the IDs without repetitions, in the order of their first occurrence.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// orderByIDs returns the notes in the order of the IDs, once each, for backends whose
+// batch queries return the notes in an unspecified order.
+func orderByIDs(ids []string, notes []*model.Note) []*model.Note {
+	byID := make(map[string]*model.Note, len(notes))
+	for _, note := range notes {
+		byID[note.ID] = note
+	}
+	ordered := make([]*model.Note, 0, len(notes))
+	for _, id := range uniqueIDs(ids) {
+		if note, ok := byID[id]; ok {
+			ordered = append(ordered, note)
+		}
+	}
+	return ordered
+}
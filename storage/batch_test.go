@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// batchStorage is a NoteStorage that records the reads that reach it, one by one or in batches
+type batchStorage struct {
+	NoteStorage
+	gets    []string
+	batches [][]string
+}
+
+func (s *batchStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	s.gets = append(s.gets, id)
+	return s.NoteStorage.Get(ctx, id)
+}
+
+func (s *batchStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	s.batches = append(s.batches, ids)
+	return GetMany(ctx, s.NoteStorage, ids)
+}
+
+// getterStorage hides the GetMany method of batchStorage, to test the fallback to Get
+type getterStorage struct {
+	NoteStorage
+}
+
+// noteIDList returns the IDs of the notes, in their order
+func noteIDList(notes []*model.Note) []string {
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+// TestGetMany verifies that GetMany delegates to backends implementing BatchGetter,
+// falls back to Get otherwise, and reads only the uncached notes through a cache
+func TestGetMany(t *testing.T) {
+	ctx := context.Background()
+	newBackend := func(t *testing.T) *batchStorage {
+		backend := &batchStorage{NoteStorage: NewInMemoryStorage()}
+		for _, id := range []string{"a", "b", "c"} {
+			if err := backend.Create(ctx, &model.Note{ID: id, Title: id}); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+		return backend
+	}
+
+	t.Run("Fallback", func(t *testing.T) {
+		backend := newBackend(t)
+		notes, err := GetMany(ctx, getterStorage{backend}, []string{"c", "missing", "a", "c"})
+		if err != nil {
+			t.Fatalf("GetMany failed: %v", err)
+		}
+		if got := noteIDList(notes); !reflect.DeepEqual(got, []string{"c", "a"}) {
+			t.Errorf("Expected notes c and a, got %v", got)
+		}
+		if !reflect.DeepEqual(backend.gets, []string{"c", "missing", "a"}) {
+			t.Errorf("Expected one Get per distinct ID, got %v", backend.gets)
+		}
+	})
+
+	t.Run("BatchGetter", func(t *testing.T) {
+		captureLog(t)
+		backend := newBackend(t)
+		decorated := NewLoggingStorage(NewTracingStorage(NewCircuitBreakerStorage(NewSwitchableStorage(backend), "test-batch", 3, time.Second), "test"), "test", false)
+
+		notes, err := GetMany(ctx, decorated, []string{"b", "a"})
+		if err != nil {
+			t.Fatalf("GetMany failed: %v", err)
+		}
+		if got := noteIDList(notes); !reflect.DeepEqual(got, []string{"b", "a"}) {
+			t.Errorf("Expected notes b and a, got %v", got)
+		}
+		if len(backend.batches) != 1 || len(backend.gets) != 0 {
+			t.Errorf("Expected a single batch read, got %v batches and %v gets", backend.batches, backend.gets)
+		}
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		backend := newBackend(t)
+		cached := NewCachedStorage(backend, NewLRUCache(10, time.Minute))
+		if _, err := cached.Get(ctx, "a"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		backend.gets = nil
+
+		notes, err := GetMany(ctx, cached, []string{"c", "a", "b"})
+		if err != nil {
+			t.Fatalf("GetMany failed: %v", err)
+		}
+		if got := noteIDList(notes); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+			t.Errorf("Expected notes c, a, and b, got %v", got)
+		}
+		if !reflect.DeepEqual(backend.batches, [][]string{{"c", "b"}}) {
+			t.Errorf("Expected only the uncached notes to be read, got %v", backend.batches)
+		}
+
+		// The notes read are cached as well
+		if _, err := GetMany(ctx, cached, []string{"b", "c"}); err != nil {
+			t.Fatalf("GetMany failed: %v", err)
+		}
+		if len(backend.batches) != 1 {
+			t.Errorf("Expected the notes to be served from the cache, got %v", backend.batches)
+		}
+	})
+}
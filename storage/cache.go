@@ -0,0 +1,255 @@
+// This file contains a read-through cache decorator for the NoteStorage interface.
+// Read-heavy workloads fetch the same notes and lists over and over; the cache serves
+// repeated reads without a backend round trip, and writes invalidate the affected entries.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// Cache keys: single notes are cached under cacheNotePrefix+ID, list results under cacheListPrefix+query.
+const (
+	cacheNotePrefix = "note:"
+	cacheListPrefix = "list:"
+)
+
+// Cache stores notes for CachedStorage. A single note is stored as a slice of one note.
+// Implementations must be safe for concurrent use, and must not let callers modify
+// cached notes (e.g., by storing and returning copies).
+type Cache interface {
+	// Get returns the notes stored under the key, and whether they were found.
+	Get(ctx context.Context, key string) ([]*model.Note, bool)
+
+	// Set stores notes under the key, replacing any previous entry.
+	Set(ctx context.Context, key string, notes []*model.Note)
+
+	// Delete removes the entries with the given keys.
+	Delete(ctx context.Context, keys ...string)
+
+	// DeletePrefix removes all entries whose key starts with prefix.
+	DeletePrefix(ctx context.Context, prefix string)
+}
+
+// CachedStorage implements NoteStorage by caching the results of Get, GetAll, and List
+// of another NoteStorage implementation.
+//
+// Writes made through CachedStorage invalidate the written note and all cached lists,
+// since any list may contain the note. Writes made by other clients of the backend are
+// only seen once the entries expire, unless they are reported with Invalidate.
+// A read that races with a write may cache the old state until it expires.
+type CachedStorage struct {
+	inner NoteStorage // Backend whose reads are cached
+	cache Cache       // Cache for notes and list results
+}
+
+// NewCachedStorage creates a new read-through cache decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to cache
+//   - cache: The cache holding notes and list results (e.g., an LRUCache)
+//
+// Returns:
+//   - A pointer to a new CachedStorage instance
+func NewCachedStorage(inner NoteStorage, cache Cache) *CachedStorage {
+	return &CachedStorage{
+		inner: inner,
+		cache: cache,
+	}
+}
+
+// Create adds a new note to the wrapped storage and invalidates the cached lists.
+func (s *CachedStorage) Create(ctx context.Context, note *model.Note) error {
+	if err := s.inner.Create(ctx, note); err != nil {
+		return err
+	}
+	s.cache.DeletePrefix(ctx, cacheListPrefix)
+	return nil
+}
+
+// Get retrieves a note from the cache, or from the wrapped storage if it is not cached.
+// Missing notes are not cached.
+func (s *CachedStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	key := cacheNotePrefix + id
+	if cached, ok := s.lookup(ctx, key); ok && len(cached) == 1 {
+		return cached[0], nil
+	}
+
+	note, err := s.inner.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(ctx, key, []*model.Note{note})
+	return note, nil
+}
+
+// GetAll retrieves all notes from the cache, or from the wrapped storage if they are not cached.
+func (s *CachedStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	return s.List(ctx, ListOptions{})
+}
+
+// List runs a list query, returning the cached result if the same query was run before.
+func (s *CachedStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	key := cacheListKey(opts)
+	if cached, ok := s.lookup(ctx, key); ok {
+		return cached, nil
+	}
+
+	var notes []*model.Note
+	var err error
+	if opts == (ListOptions{}) {
+		notes, err = s.inner.GetAll(ctx)
+	} else {
+		notes, err = List(ctx, s.inner, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(ctx, key, notes)
+	return notes, nil
+}
+
+// Stream reads all notes from the wrapped storage one at a time. Streams are meant for
+// reading more notes than fit in the cache, so they bypass it.
+func (s *CachedStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	return Stream(ctx, s.inner, fn)
+}
+
+// Exists reports whether a note exists: cached notes do, and the others are checked in the
+// wrapped storage.
+func (s *CachedStorage) Exists(ctx context.Context, id string) (bool, error) {
+	if cached, ok := s.lookup(ctx, cacheNotePrefix+id); ok && len(cached) == 1 {
+		return true, nil
+	}
+	return Exists(ctx, s.inner, id)
+}
+
+// GetMany retrieves the notes from the cache, reading only the notes that are not cached
+// from the wrapped storage, at once.
+func (s *CachedStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0, len(ids))
+	var missing []string
+	for _, id := range uniqueIDs(ids) {
+		if cached, ok := s.lookup(ctx, cacheNotePrefix+id); ok && len(cached) == 1 {
+			notes = append(notes, cached[0])
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return notes, nil
+	}
+
+	read, err := GetMany(ctx, s.inner, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range read {
+		s.cache.Set(ctx, cacheNotePrefix+note.ID, []*model.Note{note})
+	}
+	return orderByIDs(ids, append(notes, read...)), nil
+}
+
+// Count counts the matching notes in the wrapped storage; counts are not cached.
+func (s *CachedStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	return Count(ctx, s.inner, opts)
+}
+
+// Stats returns statistics about the notes in the wrapped storage; they are not cached.
+func (s *CachedStorage) Stats(ctx context.Context) (NoteStats, error) {
+	return Stats(ctx, s.inner)
+}
+
+// Maintain runs a maintenance task on the wrapped storage. Maintenance doesn't change
+// notes, so the cache is kept.
+func (s *CachedStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	return Maintain(ctx, s.inner, task)
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage, and clears the cache
+// afterwards: notes read in the transaction may have been cached before it was committed
+// or aborted.
+func (s *CachedStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := RunInTransaction(ctx, s.inner, fn)
+	s.Clear(ctx)
+	return err
+}
+
+// Update updates a note in the wrapped storage and invalidates it and the cached lists.
+func (s *CachedStorage) Update(ctx context.Context, note *model.Note) error {
+	err := s.inner.Update(ctx, note)
+	// Even a failed update may have been applied (e.g., on a timeout), so always invalidate
+	s.Invalidate(ctx, note.ID)
+	return err
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged, and invalidates it
+// and the cached lists.
+func (s *CachedStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	err := UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	// Like Update, invalidate even after a failure, which may have been applied
+	s.Invalidate(ctx, note.ID)
+	return err
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, and invalidates it
+// and the cached lists.
+func (s *CachedStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	created, err := Upsert(ctx, s.inner, note)
+	// Like Update, invalidate even after a failure, which may have been applied
+	s.Invalidate(ctx, note.ID)
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage and invalidates it and the cached lists.
+func (s *CachedStorage) Delete(ctx context.Context, id string) error {
+	err := s.inner.Delete(ctx, id)
+	s.Invalidate(ctx, id)
+	return err
+}
+
+// Ping checks the wrapped storage. Health checks always reach the backend.
+func (s *CachedStorage) Ping(ctx context.Context) error {
+	return s.inner.Ping(ctx)
+}
+
+// Close closes the wrapped storage.
+func (s *CachedStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// Invalidate removes a note and all lists from the cache. It is called for writes made
+// through the storage, and can be called for changes made by other clients of the backend
+// (e.g., reported by a change feed).
+func (s *CachedStorage) Invalidate(ctx context.Context, id string) {
+	s.cache.Delete(ctx, cacheNotePrefix+id)
+	s.cache.DeletePrefix(ctx, cacheListPrefix)
+}
+
+// Clear removes all notes and lists from the cache, e.g., after switching to another backend.
+func (s *CachedStorage) Clear(ctx context.Context) {
+	s.cache.DeletePrefix(ctx, "")
+}
+
+// lookup gets an entry from the cache, counting the hit or miss.
+func (s *CachedStorage) lookup(ctx context.Context, key string) ([]*model.Note, bool) {
+	notes, ok := s.cache.Get(ctx, key)
+	if ok {
+		metrics.StorageCacheRequests.WithLabelValues("hit").Inc()
+	} else {
+		metrics.StorageCacheRequests.WithLabelValues("miss").Inc()
+	}
+	return notes, ok
+}
+
+// cacheListKey returns the cache key of a list query. The filter comes after its length,
+// and the free-form query text comes last, so that keys of different queries cannot collide.
+func cacheListKey(opts ListOptions) string {
+	filter := opts.Filter.String()
+	return fmt.Sprintf("%s%s:%t:%d:%d:%t:%d:%s%s", cacheListPrefix, opts.Sort, opts.Descending, opts.Limit, opts.Offset,
+		opts.MatchAccents, len(filter), filter, opts.Query)
+}
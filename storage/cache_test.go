@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// countingStorage is a NoteStorage that counts the reads reaching it
+type countingStorage struct {
+	NoteStorage
+	gets, lists int
+}
+
+func (s *countingStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	s.gets++
+	return s.NoteStorage.Get(ctx, id)
+}
+
+func (s *countingStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	s.lists++
+	return s.NoteStorage.GetAll(ctx)
+}
+
+// newTestCachedStorage creates a cached in-memory storage holding one note
+func newTestCachedStorage(t *testing.T) (*CachedStorage, *countingStorage, *model.Note) {
+	t.Helper()
+	backend := &countingStorage{NoteStorage: NewInMemoryStorage()}
+	note := &model.Note{ID: "cached", Title: "Original"}
+	if err := backend.Create(context.Background(), note); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return NewCachedStorage(backend, NewLRUCache(100, time.Minute)), backend, note
+}
+
+// TestCachedStorageReads verifies that repeated reads are served from the cache
+func TestCachedStorageReads(t *testing.T) {
+	ctx := context.Background()
+	cached, backend, note := newTestCachedStorage(t)
+	hits := testutil.ToFloat64(metrics.StorageCacheRequests.WithLabelValues("hit"))
+
+	for i := 0; i < 3; i++ {
+		if _, err := cached.Get(ctx, note.ID); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if _, err := cached.GetAll(ctx); err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		if _, err := List(ctx, cached, ListOptions{Query: "orig"}); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+	}
+
+	if backend.gets != 1 {
+		t.Errorf("Expected 1 Get on the backend, got %d", backend.gets)
+	}
+	if backend.lists != 2 { // One for GetAll, one for the query
+		t.Errorf("Expected 2 lists on the backend, got %d", backend.lists)
+	}
+	if got := testutil.ToFloat64(metrics.StorageCacheRequests.WithLabelValues("hit")) - hits; got != 6 {
+		t.Errorf("Expected 6 cache hits, got %v", got)
+	}
+
+	// Missing notes are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := cached.Get(ctx, "missing"); !errors.Is(err, ErrNoteNotFound) {
+			t.Fatalf("Expected ErrNoteNotFound, got %v", err)
+		}
+	}
+	if backend.gets != 3 {
+		t.Errorf("Expected missing notes to reach the backend every time, got %d Gets", backend.gets)
+	}
+}
+
+// TestCachedStorageInvalidation verifies that writes invalidate the cached note and lists
+func TestCachedStorageInvalidation(t *testing.T) {
+	ctx := context.Background()
+	cached, backend, note := newTestCachedStorage(t)
+
+	if _, err := cached.Get(ctx, note.ID); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := cached.GetAll(ctx); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		if err := cached.Update(ctx, &model.Note{ID: note.ID, Title: "Updated"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got, _ := cached.Get(ctx, note.ID); got == nil || got.Title != "Updated" {
+			t.Errorf("Expected the updated note, got %+v", got)
+		}
+		if notes, _ := cached.GetAll(ctx); len(notes) != 1 || notes[0].Title != "Updated" {
+			t.Errorf("Expected the updated list, got %v", noteTitles(notes))
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		if err := cached.Create(ctx, &model.Note{ID: "new", Title: "New"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if notes, _ := cached.GetAll(ctx); len(notes) != 2 {
+			t.Errorf("Expected the new note in the list, got %v", noteTitles(notes))
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := cached.Delete(ctx, note.ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := cached.Get(ctx, note.ID); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
+	t.Run("External", func(t *testing.T) {
+		// A change made by another client of the backend is served from the cache until invalidated
+		if _, err := cached.Get(ctx, "new"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if err := backend.Update(ctx, &model.Note{ID: "new", Title: "Changed elsewhere"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got, _ := cached.Get(ctx, "new"); got.Title != "New" {
+			t.Errorf("Expected the cached note, got %+v", got)
+		}
+		cached.Invalidate(ctx, "new")
+		if got, _ := cached.Get(ctx, "new"); got.Title != "Changed elsewhere" {
+			t.Errorf("Expected the changed note after invalidation, got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+// This file contains a MongoDB change stream reader, which reports changes to notes
+// made by any client of the database, including other instances of the application.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Types of changes reported by a ChangeFeed.
+const (
+	ChangeCreated = "created" // A note was inserted
+	ChangeUpdated = "updated" // A note was updated or replaced
+	ChangeDeleted = "deleted" // A note was deleted
+)
+
+// changeStreamRetryDelay is the delay before a failed change stream is reopened.
+const changeStreamRetryDelay = time.Second
+
+// ErrChangeStreamsUnsupported is returned when the MongoDB server is a standalone server;
+// change streams are only available on replica sets and sharded clusters.
+var ErrChangeStreamsUnsupported = errors.New("change streams require a MongoDB replica set")
+
+// errCodeChangeStreamNotSupported is the MongoDB error code for "$changeStream is only
+// supported on replica sets".
+const errCodeChangeStreamNotSupported = 40573
+
+// ChangeFeed reports changes to notes made by any client of the database.
+// It is implemented by ChangeStream (MongoDB) and CouchChangesFeed (CouchDB).
+type ChangeFeed interface {
+	// Run calls handle for every change, one change at a time, until ctx is canceled.
+	Run(ctx context.Context, handle func(Change))
+
+	// Close releases the resources of the feed once Run has returned.
+	Close(ctx context.Context) error
+}
+
+// Change describes a change to a note, as reported by a ChangeFeed.
+type Change struct {
+	Type   string // ChangeCreated, ChangeUpdated, or ChangeDeleted
+	NoteID string // ID of the note that changed
+}
+
+// changeDocument is the part of a change event document that is decoded.
+type changeDocument struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID string `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// changePipeline limits the change stream to events about individual notes.
+var changePipeline = mongo.Pipeline{
+	{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{
+		{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}},
+	}}}}},
+}
+
+// ChangeStream reports changes to the notes collection. The changed notes themselves
+// are not included; they may be encrypted, so they should be read through the storage.
+type ChangeStream struct {
+	collection *mongo.Collection
+	stream     *mongo.ChangeStream
+}
+
+// WatchChanges opens a change stream on the notes collection.
+// Opening the stream before the application starts serving requests makes sure no
+// change made after startup is missed.
+//
+// Parameters:
+//   - ctx: The context for opening the stream
+//
+// Returns:
+//   - A ChangeStream that reports changes once Run is called
+//   - ErrChangeStreamsUnsupported if the server is a standalone server, or another error if opening fails
+func (s *MongoDBStorage) WatchChanges(ctx context.Context) (*ChangeStream, error) {
+	c := &ChangeStream{collection: s.collection}
+	if err := c.open(ctx, nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run calls handle for every change, until ctx is canceled. If the stream fails,
+// e.g., because the connection to the server is lost, it is reopened where it left off.
+//
+// Parameters:
+//   - ctx: The context; canceling it stops Run
+//   - handle: The function called for every change, one change at a time
+func (c *ChangeStream) Run(ctx context.Context, handle func(Change)) {
+	for {
+		for c.stream.Next(ctx) {
+			var doc changeDocument
+			if err := c.stream.Decode(&doc); err != nil {
+				log.Printf("Failed to decode change event: %v", err)
+				continue
+			}
+			if change, ok := doc.change(); ok {
+				handle(change)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		// The stream ended: reopen it after the last change seen. Without an error, the
+		// stream was invalidated (e.g., the collection was dropped), so start afresh.
+		var resumeToken bson.Raw
+		if err := c.stream.Err(); err != nil {
+			log.Printf("Change stream failed: %v; reopening in %v", err, changeStreamRetryDelay)
+			resumeToken = c.stream.ResumeToken()
+		}
+		_ = c.stream.Close(context.Background())
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(changeStreamRetryDelay):
+			}
+			err := c.open(ctx, resumeToken)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to reopen change stream: %v; retrying in %v", err, changeStreamRetryDelay)
+		}
+	}
+}
+
+// Close closes the change stream.
+func (c *ChangeStream) Close(ctx context.Context) error {
+	return c.stream.Close(ctx)
+}
+
+// open opens the change stream, resuming after the given token if it is not nil.
+func (c *ChangeStream) open(ctx context.Context, resumeToken bson.Raw) error {
+	opts := options.ChangeStream()
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+	stream, err := c.collection.Watch(ctx, changePipeline, opts)
+	if err != nil {
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(errCodeChangeStreamNotSupported) {
+			return ErrChangeStreamsUnsupported
+		}
+		return fmt.Errorf("failed to open change stream: %w", err)
+	}
+	c.stream = stream
+	return nil
+}
+
+// change converts a change event document into a Change.
+func (d changeDocument) change() (Change, bool) {
+	var changeType string
+	switch d.OperationType {
+	case "insert":
+		changeType = ChangeCreated
+	case "update", "replace":
+		changeType = ChangeUpdated
+	case "delete":
+		changeType = ChangeDeleted
+	default:
+		return Change{}, false
+	}
+	return Change{Type: changeType, NoteID: d.DocumentKey.ID}, true
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TestChangeDocument tests converting change event documents into changes
+func TestChangeDocument(t *testing.T) {
+	tests := map[string]struct {
+		operation string
+		want      string // Expected change type; empty if the event is ignored
+	}{
+		"Insert":  {"insert", ChangeCreated},
+		"Update":  {"update", ChangeUpdated},
+		"Replace": {"replace", ChangeUpdated},
+		"Delete":  {"delete", ChangeDeleted},
+		"Drop":    {"drop", ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var doc changeDocument
+			doc.OperationType = tt.operation
+			doc.DocumentKey.ID = "note-1"
+
+			change, ok := doc.change()
+			if ok != (tt.want != "") || change.Type != tt.want {
+				t.Fatalf("Expected change type %q, got %q (ok: %t)", tt.want, change.Type, ok)
+			}
+			if ok && change.NoteID != "note-1" {
+				t.Errorf("Expected note ID note-1, got %q", change.NoteID)
+			}
+		})
+	}
+}
+
+// TestMongoDBChangeStream tests that changes are reported by the change stream
+// This test uses the shared MongoDB container from TestMain
+func TestMongoDBChangeStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping MongoDB integration test in short mode")
+	}
+	mongodbEndpoint := getSharedMongoURI()
+	if mongodbEndpoint == "" {
+		t.Skip("Shared MongoDB container not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	storage, err := NewMongoDBStorage(ctx, mongodbEndpoint, "test_notes", "test_change_stream", MongoDBOptions{}, DefaultRetryPolicy())
+	if err != nil {
+		t.Fatalf("Failed to create MongoDB storage: %v", err)
+	}
+	CleanupCloseWithContext(t, ctx, storage)
+
+	changes, err := storage.WatchChanges(ctx)
+	if errors.Is(err, ErrChangeStreamsUnsupported) {
+		t.Skip("The shared MongoDB container is not a replica set")
+	}
+	if err != nil {
+		t.Fatalf("Failed to open change stream: %v", err)
+	}
+	CleanupCloseWithContext(t, ctx, changes)
+
+	received := make(chan Change, 10)
+	go changes.Run(ctx, func(change Change) { received <- change })
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	note.Title = "Updated"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	if err := storage.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+
+	for _, want := range []string{ChangeCreated, ChangeUpdated, ChangeDeleted} {
+		select {
+		case change := <-received:
+			if change.Type != want || change.NoteID != note.ID {
+				t.Errorf("Expected %s of %s, got %+v", want, note.ID, change)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for %s", want)
+		}
+	}
+}
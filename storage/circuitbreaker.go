@@ -0,0 +1,412 @@
+// This file contains a circuit breaker decorator for the NoteStorage interface.
+// When a backend keeps failing (e.g., MongoDB is flapping), every request would
+// otherwise wait for the full driver timeout; the circuit breaker rejects operations
+// immediately instead, and lets a single probe through from time to time to detect
+// when the backend has recovered.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// ErrCircuitOpen is returned (wrapped in a CircuitOpenError) when the circuit breaker
+// rejects an operation without calling the backend. Use errors.Is to check for it.
+var ErrCircuitOpen = errors.New("storage circuit breaker is open")
+
+// CircuitOpenError is returned when the circuit breaker rejects an operation.
+// It matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	Backend    string        // Name of the backend protected by the circuit breaker
+	RetryAfter time.Duration // Time until the next probe of the backend is allowed
+}
+
+// Error returns a human-readable description of the error.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v: %s is failing, retry in %v", ErrCircuitOpen, e.Backend, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+// Circuit breaker states. The values are exported as the notes_storage_circuit_state metric.
+const (
+	// CircuitClosed passes every operation to the backend.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every operation with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe operation through; its outcome closes or reopens the circuit.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitBreakerStorage implements NoteStorage by passing operations to another
+// NoteStorage implementation until it fails too often in a row.
+//
+// After failureThreshold consecutive failures the circuit opens, and operations fail
+// immediately with ErrCircuitOpen. Once openTimeout has passed, the circuit becomes
+// half-open: the next operation is passed through as a probe (others are still rejected),
+// and its outcome either closes the circuit or opens it for another openTimeout.
+//
+// Outcomes that say nothing about the backend's health, such as ErrNoteNotFound,
+// update conflicts, or operations canceled by the caller, are not counted as failures.
+type CircuitBreakerStorage struct {
+	inner            NoteStorage   // Backend protected by the circuit breaker
+	backend          string        // Backend name used in log messages and metrics
+	failureThreshold int           // Number of consecutive failures that opens the circuit
+	openTimeout      time.Duration // How long the circuit stays open before a probe is allowed
+
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the circuit was last opened
+	probing  bool      // Whether the half-open probe is in progress
+
+	now func() time.Time // Clock, replaced in tests
+}
+
+// NewCircuitBreakerStorage creates a new circuit breaker decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to protect
+//   - backend: A short backend name used in log messages and as the "backend" metric label
+//   - failureThreshold: The number of consecutive failures that opens the circuit (at least 1)
+//   - openTimeout: How long the circuit stays open before the backend is probed again
+//
+// Returns:
+//   - A pointer to a new CircuitBreakerStorage instance
+func NewCircuitBreakerStorage(inner NoteStorage, backend string, failureThreshold int, openTimeout time.Duration) *CircuitBreakerStorage {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	metrics.StorageCircuitState.WithLabelValues(backend).Set(float64(CircuitClosed))
+	return &CircuitBreakerStorage{
+		inner:            inner,
+		backend:          backend,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// State returns the current state of the circuit breaker.
+// An open circuit whose timeout has passed is reported as half-open.
+func (s *CircuitBreakerStorage) State() CircuitState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.state == CircuitOpen && s.now().Sub(s.openedAt) >= s.openTimeout {
+		return CircuitHalfOpen
+	}
+	return s.state
+}
+
+// Create adds a new note to the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Create(ctx context.Context, note *model.Note) error {
+	return s.call(ctx, func() error {
+		return s.inner.Create(ctx, note)
+	})
+}
+
+// Get retrieves a note from the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	var note *model.Note
+	err := s.call(ctx, func() error {
+		var err error
+		note, err = s.inner.Get(ctx, id)
+		return err
+	})
+	return note, err
+}
+
+// GetAll retrieves all notes from the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, func() error {
+		var err error
+		notes, err = s.inner.GetAll(ctx)
+		return err
+	})
+	return notes, err
+}
+
+// List runs a list query on the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, func() error {
+		var err error
+		notes, err = List(ctx, s.inner, opts)
+		return err
+	})
+	return notes, err
+}
+
+// Exists checks whether a note exists in the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := s.call(ctx, func() error {
+		var err error
+		exists, err = Exists(ctx, s.inner, id)
+		return err
+	})
+	return exists, err
+}
+
+// GetMany retrieves notes by ID from the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, func() error {
+		var err error
+		notes, err = GetMany(ctx, s.inner, ids)
+		return err
+	})
+	return notes, err
+}
+
+// Count counts the matching notes in the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	var count int
+	err := s.call(ctx, func() error {
+		var err error
+		count, err = Count(ctx, s.inner, opts)
+		return err
+	})
+	return count, err
+}
+
+// Stats returns statistics about the notes in the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Stats(ctx context.Context) (NoteStats, error) {
+	var stats NoteStats
+	err := s.call(ctx, func() error {
+		var err error
+		stats, err = Stats(ctx, s.inner)
+		return err
+	})
+	return stats, err
+}
+
+// Maintain runs a maintenance task on the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	return s.call(ctx, func() error {
+		return Maintain(ctx, s.inner, task)
+	})
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage. The operations in fn
+// go through the circuit breaker on their own, so they fail fast while the circuit is open.
+func (s *CircuitBreakerStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTransaction(ctx, s.inner, fn)
+}
+
+// Stream reads all notes from the wrapped storage one at a time, unless the circuit is open.
+// Errors returned by fn are not failures of the backend, so they don't count towards opening the circuit.
+func (s *CircuitBreakerStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	var fnErr error
+	err := s.call(ctx, func() error {
+		err := Stream(ctx, s.inner, func(note *model.Note) error {
+			fnErr = fn(note)
+			return fnErr
+		})
+		if fnErr != nil {
+			return nil
+		}
+		return err
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// Update updates a note in the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Update(ctx context.Context, note *model.Note) error {
+	return s.call(ctx, func() error {
+		return s.inner.Update(ctx, note)
+	})
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged, unless the circuit is open.
+func (s *CircuitBreakerStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	return s.call(ctx, func() error {
+		return UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	})
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, unless the circuit is open.
+func (s *CircuitBreakerStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	var created bool
+	err := s.call(ctx, func() error {
+		var err error
+		created, err = Upsert(ctx, s.inner, note)
+		return err
+	})
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage, unless the circuit is open.
+func (s *CircuitBreakerStorage) Delete(ctx context.Context, id string) error {
+	return s.call(ctx, func() error {
+		return s.inner.Delete(ctx, id)
+	})
+}
+
+// Ping checks the wrapped storage, unless the circuit is open.
+// Readiness checks therefore fail fast while the circuit is open.
+func (s *CircuitBreakerStorage) Ping(ctx context.Context) error {
+	return s.call(ctx, func() error {
+		return s.inner.Ping(ctx)
+	})
+}
+
+// Close closes the wrapped storage, regardless of the state of the circuit.
+func (s *CircuitBreakerStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// call runs fn if the circuit allows it, and records its outcome.
+func (s *CircuitBreakerStorage) call(ctx context.Context, fn func() error) error {
+	probe, err := s.allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	s.record(probe, isBackendFailure(ctx, err))
+	return err
+}
+
+// allow decides whether an operation may call the backend.
+// It returns whether the operation is the half-open probe, or a CircuitOpenError.
+func (s *CircuitBreakerStorage) allow() (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch s.state {
+	case CircuitClosed:
+		return false, nil
+	case CircuitOpen:
+		remaining := s.openTimeout - s.now().Sub(s.openedAt)
+		if remaining > 0 {
+			return false, s.reject(remaining)
+		}
+		s.transition(CircuitHalfOpen)
+	}
+
+	// Half-open: only one probe at a time
+	if s.probing {
+		return false, s.reject(0)
+	}
+	s.probing = true
+	return true, nil
+}
+
+// reject counts a rejected operation and returns the error describing it.
+// The caller must hold the mutex.
+func (s *CircuitBreakerStorage) reject(retryAfter time.Duration) error {
+	metrics.StorageCircuitRejections.WithLabelValues(s.backend).Inc()
+	return &CircuitOpenError{Backend: s.backend, RetryAfter: retryAfter}
+}
+
+// record updates the state of the circuit with the outcome of an operation.
+func (s *CircuitBreakerStorage) record(probe, failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if probe {
+		s.probing = false
+		if failed {
+			s.open()
+		} else {
+			s.failures = 0
+			s.transition(CircuitClosed)
+		}
+		return
+	}
+
+	// Operations that started before the circuit opened may finish afterwards;
+	// only operations in the closed state count towards opening it
+	if s.state != CircuitClosed {
+		return
+	}
+	if !failed {
+		s.failures = 0
+		return
+	}
+	s.failures++
+	if s.failures >= s.failureThreshold {
+		s.open()
+	}
+}
+
+// open opens the circuit. The caller must hold the mutex.
+func (s *CircuitBreakerStorage) open() {
+	s.failures = 0
+	s.openedAt = s.now()
+	s.transition(CircuitOpen)
+}
+
+// transition changes the state of the circuit, logging and counting the change.
+// The caller must hold the mutex.
+func (s *CircuitBreakerStorage) transition(state CircuitState) {
+	if s.state == state && state != CircuitOpen {
+		return
+	}
+	if state == CircuitOpen {
+		log.Printf("Storage circuit breaker for %s opened, rejecting operations for %v", s.backend, s.openTimeout)
+	} else {
+		log.Printf("Storage circuit breaker for %s is %s", s.backend, state)
+	}
+	s.state = state
+	metrics.StorageCircuitState.WithLabelValues(s.backend).Set(float64(state))
+	metrics.StorageCircuitTransitions.WithLabelValues(s.backend, state.String()).Inc()
+}
+
+// isBackendFailure reports whether an error indicates that the backend is unhealthy.
+// Errors caused by the request itself (a missing note, a conflicting update, an unsupported
+// maintenance task, a full in-memory storage, or the caller giving up) are not failures of the backend.
+func isBackendFailure(ctx context.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNoteNotFound), errors.Is(err, ErrConflict), errors.Is(err, ErrUnsupportedTask),
+		errors.Is(err, ErrStorageFull):
+		return false
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		return false
+	case mongo.IsDuplicateKeyError(err):
+		return false
+	}
+
+	// kivik reports HTTP status 500 for errors without a status (e.g., connection errors)
+	status := kivik.HTTPStatus(err)
+	return status < http.StatusBadRequest || status >= http.StatusInternalServerError
+}
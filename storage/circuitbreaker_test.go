@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// newTestCircuitBreaker wraps a switchable backend in a circuit breaker with a manual clock,
+// so tests can make the backend fail or recover and move time forward
+func newTestCircuitBreaker(t *testing.T, backend string) (*CircuitBreakerStorage, *SwitchableStorage, *time.Time) {
+	t.Helper()
+	captureLog(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	switchable := NewSwitchableStorage(&failingStorage{})
+	breaker := NewCircuitBreakerStorage(switchable, backend, 3, 10*time.Second)
+	breaker.now = func() time.Time { return now }
+	return breaker, switchable, &now
+}
+
+// recoverBackend switches the backend of a test circuit breaker to a working one
+func recoverBackend(t *testing.T, switchable *SwitchableStorage) {
+	t.Helper()
+	if _, err := switchable.Switch(context.Background(), NewInMemoryStorage(), nil); err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+}
+
+// TestCircuitBreakerStorageOpens verifies that consecutive failures open the circuit,
+// and that an open circuit rejects operations without calling the backend
+func TestCircuitBreakerStorageOpens(t *testing.T) {
+	ctx := context.Background()
+	breaker, _, _ := newTestCircuitBreaker(t, "test-opens")
+
+	for i := 0; i < 3; i++ {
+		if err := breaker.Ping(ctx); !errors.Is(err, errFailingStorage) {
+			t.Fatalf("Attempt %d: expected the backend error, got %v", i+1, err)
+		}
+	}
+	if state := breaker.State(); state != CircuitOpen {
+		t.Fatalf("Expected the circuit to be open, got %s", state)
+	}
+
+	_, err := breaker.Get(ctx, "some-id")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.RetryAfter != 10*time.Second {
+		t.Errorf("Expected a CircuitOpenError with the remaining open time, got %#v", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.StorageCircuitState.WithLabelValues("test-opens")); got != float64(CircuitOpen) {
+		t.Errorf("Expected the state metric to be %d, got %v", CircuitOpen, got)
+	}
+	if got := testutil.ToFloat64(metrics.StorageCircuitRejections.WithLabelValues("test-opens")); got != 1 {
+		t.Errorf("Expected 1 rejection, got %v", got)
+	}
+}
+
+// TestCircuitBreakerStorageIgnoresRequestErrors verifies that outcomes caused by the request,
+// not by the backend, don't open the circuit
+func TestCircuitBreakerStorageIgnoresRequestErrors(t *testing.T) {
+	captureLog(t)
+	breaker := NewCircuitBreakerStorage(NewInMemoryStorage(), "test-request-errors", 1, time.Minute)
+
+	if _, err := breaker.Get(context.Background(), "missing"); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected ErrNoteNotFound, got %v", err)
+	}
+	_ = breaker.call(context.Background(), func() error { return fmt.Errorf("%w: stale revision", ErrConflict) })
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := breaker.call(canceled, func() error { return fmt.Errorf("query failed: %w", context.Canceled) })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected the circuit to stay closed, got %s", state)
+	}
+}
+
+// TestCircuitBreakerStorageSuccessResets verifies that only consecutive failures open the circuit
+func TestCircuitBreakerStorageSuccessResets(t *testing.T) {
+	ctx := context.Background()
+	breaker, switchable, _ := newTestCircuitBreaker(t, "test-resets")
+
+	for i := 0; i < 2; i++ {
+		_ = breaker.Ping(ctx)
+	}
+	recoverBackend(t, switchable)
+	if err := breaker.Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if _, err := switchable.Switch(ctx, &failingStorage{}, nil); err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_ = breaker.Ping(ctx)
+	}
+
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected the circuit to stay closed, got %s", state)
+	}
+}
+
+// TestCircuitBreakerStorageHalfOpen verifies that after the open timeout a probe either
+// closes the circuit or opens it again
+func TestCircuitBreakerStorageHalfOpen(t *testing.T) {
+	ctx := context.Background()
+	breaker, switchable, now := newTestCircuitBreaker(t, "test-half-open")
+
+	for i := 0; i < 3; i++ {
+		_ = breaker.Ping(ctx)
+	}
+
+	t.Run("FailedProbe", func(t *testing.T) {
+		*now = now.Add(10 * time.Second)
+		if state := breaker.State(); state != CircuitHalfOpen {
+			t.Fatalf("Expected the circuit to be half-open, got %s", state)
+		}
+		if err := breaker.Ping(ctx); !errors.Is(err, errFailingStorage) {
+			t.Fatalf("Expected the probe to reach the backend, got %v", err)
+		}
+		if err := breaker.Ping(ctx); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected the circuit to open again, got %v", err)
+		}
+	})
+
+	t.Run("SuccessfulProbe", func(t *testing.T) {
+		recoverBackend(t, switchable)
+		*now = now.Add(10 * time.Second)
+		if err := breaker.Create(ctx, &model.Note{ID: "probe", Title: "Probe"}); err != nil {
+			t.Fatalf("Expected the probe to succeed, got %v", err)
+		}
+		if state := breaker.State(); state != CircuitClosed {
+			t.Errorf("Expected the circuit to be closed, got %s", state)
+		}
+		if _, err := breaker.Get(ctx, "probe"); err != nil {
+			t.Errorf("Expected operations to pass through, got %v", err)
+		}
+	})
+
+	if got := testutil.ToFloat64(metrics.StorageCircuitTransitions.WithLabelValues("test-half-open", "half-open")); got != 2 {
+		t.Errorf("Expected 2 transitions to half-open, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.StorageCircuitState.WithLabelValues("test-half-open")); got != float64(CircuitClosed) {
+		t.Errorf("Expected the state metric to be %d, got %v", CircuitClosed, got)
+	}
+}
+
+// TestCircuitBreakerStorageSingleProbe verifies that only one operation probes a half-open circuit
+func TestCircuitBreakerStorageSingleProbe(t *testing.T) {
+	ctx := context.Background()
+	breaker, switchable, now := newTestCircuitBreaker(t, "test-single-probe")
+
+	for i := 0; i < 3; i++ {
+		_ = breaker.Ping(ctx)
+	}
+	*now = now.Add(10 * time.Second)
+
+	// Block the probe in the backend until the other operations have been rejected
+	release := make(chan struct{})
+	blocking := &blockingStorage{NoteStorage: NewInMemoryStorage(), release: release, called: make(chan struct{})}
+	if _, err := switchable.Switch(ctx, blocking, nil); err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := breaker.Ping(ctx); err != nil {
+			t.Errorf("Expected the probe to succeed, got %v", err)
+		}
+	}()
+	<-blocking.called
+
+	if err := breaker.Ping(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected operations during the probe to be rejected, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected the circuit to be closed, got %s", state)
+	}
+}
+
+// blockingStorage is a NoteStorage whose Ping signals called and then blocks until release is closed
+type blockingStorage struct {
+	NoteStorage
+	release chan struct{}
+	called  chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingStorage) Ping(context.Context) error {
+	s.once.Do(func() { close(s.called) })
+	<-s.release
+	return nil
+}
+
+// TestCircuitBreakerStorageStreamCallbackErrors verifies that errors returned by the
+// stream callback (e.g., a client that went away) don't count as backend failures
+func TestCircuitBreakerStorageStreamCallbackErrors(t *testing.T) {
+	ctx := context.Background()
+	breaker, switchable, _ := newTestCircuitBreaker(t, "test-stream")
+	recoverBackend(t, switchable)
+	if err := breaker.Create(ctx, model.NewNote("Title", "Content")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	errClient := errors.New("client went away")
+	for i := 0; i < 3; i++ {
+		err := breaker.Stream(ctx, func(*model.Note) error { return errClient })
+		if !errors.Is(err, errClient) {
+			t.Fatalf("Attempt %d: expected the callback error, got %v", i+1, err)
+		}
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected the circuit to stay closed, got %s", state)
+	}
+}
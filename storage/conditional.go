@@ -0,0 +1,99 @@
+// This file contains cheap existence checks and conditional (compare-and-swap) updates,
+// which backends can run natively instead of reading the whole note.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// NoteUpdater is the part of NoteStorage that reads and updates single notes. UpdateIf
+// only needs this, so it also works on repositories that don't expose the whole interface.
+type NoteUpdater interface {
+	NoteGetter
+
+	// Update replaces an existing note, or returns ErrNoteNotFound.
+	Update(ctx context.Context, note *model.Note) error
+}
+
+// Exister is implemented by storage backends that can check whether a note exists
+// without reading it.
+type Exister interface {
+	// Exists reports whether a note with the given ID exists.
+	Exists(ctx context.Context, id string) (bool, error)
+}
+
+// ConditionalUpdater is implemented by storage backends that can update a note only if
+// it is unchanged, atomically.
+type ConditionalUpdater interface {
+	// UpdateIf replaces an existing note if its update time is still expectedUpdatedAt.
+	// It returns ErrNoteNotFound if the note doesn't exist, and ErrConflict if it was updated since.
+	UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error
+}
+
+// Exists reports whether the backend has a note with the given ID. Backends that
+// implement Exister check it natively; for the others, the note is read.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Exister
+//   - id: The ID of the note
+//
+// Returns:
+//   - Whether the note exists
+//   - An error if the backend cannot be read
+func Exists(ctx context.Context, backend NoteGetter, id string) (bool, error) {
+	if exister, ok := backend.(Exister); ok {
+		return exister.Exists(ctx, id)
+	}
+
+	_, err := backend.Get(ctx, id)
+	if errors.Is(err, ErrNoteNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UpdateIf replaces an existing note, but only if it wasn't updated since the update time
+// the caller based its changes on, so concurrent updates aren't lost. Backends that
+// implement ConditionalUpdater compare and swap atomically; for the others, the note is
+// read first, which leaves a short window in which a concurrent update is overwritten.
+//
+// Update times are compared with time.Time.Equal, at the precision the backend stores
+// them with (e.g., milliseconds in MongoDB), so expectedUpdatedAt should be read from it.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement ConditionalUpdater
+//   - note: The new version of the note
+//   - expectedUpdatedAt: The update time the note must still have
+//
+// Returns:
+//   - ErrNoteNotFound if the note doesn't exist, ErrConflict if it was updated since,
+//     or the error of the update
+func UpdateIf(ctx context.Context, backend NoteUpdater, note *model.Note, expectedUpdatedAt time.Time) error {
+	if updater, ok := backend.(ConditionalUpdater); ok {
+		return updater.UpdateIf(ctx, note, expectedUpdatedAt)
+	}
+
+	current, err := backend.Get(ctx, note.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkUpdatedAt(current, expectedUpdatedAt); err != nil {
+		return err
+	}
+	return backend.Update(ctx, note)
+}
+
+// checkUpdatedAt returns ErrConflict if the note was updated at another time than expected.
+func checkUpdatedAt(note *model.Note, expectedUpdatedAt time.Time) error {
+	if !note.UpdatedAt.Equal(expectedUpdatedAt) {
+		return fmt.Errorf("%w: %s was updated at %s", ErrConflict, note.ID, note.UpdatedAt.Format(time.RFC3339Nano))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TestUpdateIf_Fallback verifies that Exists and UpdateIf read the note on backends
+// that don't implement them natively
+func TestUpdateIf_Fallback(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2025, 1, 10, 9, 30, 0, 0, time.UTC)
+	backend := &batchStorage{NoteStorage: NewInMemoryStorage()}
+	if err := backend.Create(ctx, &model.Note{ID: "a", Title: "Original", UpdatedAt: updatedAt}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	plain := getterStorage{backend}
+
+	if exists, err := Exists(ctx, plain, "a"); err != nil || !exists {
+		t.Errorf("Expected the note to exist, got %t: %v", exists, err)
+	}
+	if exists, err := Exists(ctx, plain, "missing"); err != nil || exists {
+		t.Errorf("Expected no missing note, got %t: %v", exists, err)
+	}
+
+	stale := &model.Note{ID: "a", Title: "Stale", UpdatedAt: updatedAt.Add(time.Hour)}
+	if err := UpdateIf(ctx, plain, stale, updatedAt.Add(-time.Hour)); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	current := &model.Note{ID: "a", Title: "Current", UpdatedAt: updatedAt.Add(time.Hour)}
+	if err := UpdateIf(ctx, plain, current, updatedAt); err != nil {
+		t.Fatalf("UpdateIf failed: %v", err)
+	}
+	if note, _ := backend.Get(ctx, "a"); note.Title != "Current" {
+		t.Errorf("Expected the note to be updated, got %q", note.Title)
+	}
+	if len(backend.gets) != 5 {
+		t.Errorf("Expected the note to be read for every check, got %v", backend.gets)
+	}
+}
@@ -0,0 +1,200 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kivik/kivik/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/storagetest"
+)
+
+// TestInMemoryStorage runs the conformance tests against the in-memory storage
+func TestInMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+		return storage.NewInMemoryStorage()
+	})
+}
+
+// TestInMemoryStorageWithSnapshots runs the conformance tests against the in-memory
+// storage saving snapshots
+func TestInMemoryStorageWithSnapshots(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+		s, err := storage.NewInMemoryStorageWithSnapshots(filepath.Join(t.TempDir(), "notes.json"), time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		return s
+	})
+}
+
+// TestMockStorages runs the conformance tests against the mocks of the database backends
+func TestMockStorages(t *testing.T) {
+	t.Run("MongoDB", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+			return storage.NewMockMongoDBStorage()
+		})
+	})
+	t.Run("CouchDB", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+			return storage.NewMockCouchDBStorage()
+		})
+	})
+}
+
+// TestDecorators runs the conformance tests against every decorator, wrapping in-memory storage
+func TestDecorators(t *testing.T) {
+	tests := map[string]storagetest.Factory{
+		"Cached": func(t *testing.T) storage.NoteStorage {
+			return storage.NewCachedStorage(storage.NewInMemoryStorage(), storage.NewLRUCache(100, time.Minute))
+		},
+		"CircuitBreaker": func(t *testing.T) storage.NoteStorage {
+			return storage.NewCircuitBreakerStorage(storage.NewInMemoryStorage(), "memory", 3, time.Second)
+		},
+		"DualWrite": func(t *testing.T) storage.NoteStorage {
+			return storage.NewDualWriteStorage(storage.NewInMemoryStorage(), storage.NewInMemoryStorage(), nil)
+		},
+		"Encrypted": func(t *testing.T) storage.NoteStorage {
+			return storage.NewEncryptedStorage(storage.NewInMemoryStorage(), storage.NewTestKeyring(t, "k1"), true)
+		},
+		"Instrumented": func(t *testing.T) storage.NoteStorage {
+			return storage.NewInstrumentedStorage(storage.NewInMemoryStorage(), "memory", time.Second)
+		},
+		"Logging": func(t *testing.T) storage.NoteStorage {
+			return storage.NewLoggingStorage(storage.NewInMemoryStorage(), "memory", true)
+		},
+		"Replicated": func(t *testing.T) storage.NoteStorage {
+			return storage.NewReplicatedStorage(storage.NewInMemoryStorage(), storage.NewInMemoryStorage(), 100, 0)
+		},
+		"Switchable": func(t *testing.T) storage.NoteStorage {
+			return storage.NewSwitchableStorage(storage.NewInMemoryStorage())
+		},
+		"Timeout": func(t *testing.T) storage.NoteStorage {
+			return storage.NewTimeoutStorage(storage.NewInMemoryStorage(), time.Second)
+		},
+		"Tracing": func(t *testing.T) storage.NoteStorage {
+			storage.SetupSpanRecorder(t)
+			return storage.NewTracingStorage(storage.NewInMemoryStorage(), "memory")
+		},
+	}
+	for name, factory := range tests {
+		t.Run(name, func(t *testing.T) {
+			storagetest.Run(t, factory)
+		})
+	}
+}
+
+// TestMongoDBStorage runs the conformance tests against MongoDB
+// This test uses the shared MongoDB container from TestMain
+func TestMongoDBStorage(t *testing.T) {
+	// Skip this test if we're not running integration tests
+	if testing.Short() {
+		t.Skip("Skipping MongoDB integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	// Use the shared MongoDB container
+	mongodbEndpoint := storage.SharedMongoURI()
+	if mongodbEndpoint == "" {
+		t.Skip("Shared MongoDB container not available")
+	}
+	dbName := "test_notes"
+	collectionName := "test_notes"
+
+	// Connect to the MongoDB container, to drop the test collection before every case
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongodbEndpoint))
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB container: %v", err)
+	}
+	defer func() {
+		if err := client.Disconnect(ctx); err != nil {
+			t.Logf("Warning: Failed to disconnect from MongoDB container: %v", err)
+		}
+	}()
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("Failed to ping MongoDB container: %v", err)
+	}
+	drop := func() {
+		if err := client.Database(dbName).Collection(collectionName).Drop(ctx); err != nil {
+			t.Logf("Warning: Failed to drop test collection: %v", err)
+		}
+	}
+	defer drop()
+
+	storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+		drop()
+		s, err := storage.NewMongoDBStorage(ctx, mongodbEndpoint, dbName, collectionName, storage.MongoDBOptions{}, storage.DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create MongoDB storage: %v", err)
+		}
+		return s
+	})
+}
+
+// TestCouchDBStorage runs the conformance tests against CouchDB
+// This test uses the shared CouchDB container from TestMain
+func TestCouchDBStorage(t *testing.T) {
+	// Skip this test if we're not running integration tests
+	if testing.Short() {
+		t.Skip("Skipping CouchDB integration test in short mode")
+	}
+
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// Use the shared CouchDB container
+	url := storage.SharedCouchURL()
+	if url == "" {
+		t.Skip("Shared CouchDB container not available")
+	}
+	dbName := "test_notes"
+
+	// Connect to the CouchDB container, to recreate the test database before every case
+	client, err := kivik.New("couch", url)
+	if err != nil {
+		t.Fatalf("Failed to connect to CouchDB container: %v", err)
+	}
+	storage.CleanupClose(t, client)
+
+	// Check if the server is available with retries
+	// Sometimes CouchDB takes a moment to fully initialize authentication even after the port is open
+	maxAttempts := 10
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		_, err = client.AllDBs(ctx)
+		if err == nil {
+			break
+		}
+		if attempt == maxAttempts {
+			t.Fatalf("Failed to list databases in CouchDB container after %d attempts: %v", maxAttempts, err)
+		}
+		t.Logf("Attempt %d: CouchDB not ready yet (err: %v), retrying...", attempt, err)
+		time.Sleep(500 * time.Millisecond)
+	}
+	destroy := func() {
+		if exists, _ := client.DBExists(ctx, dbName); exists {
+			if err := client.DestroyDB(ctx, dbName); err != nil {
+				t.Logf("Warning: Failed to destroy test database: %v", err)
+			}
+		}
+	}
+	defer destroy()
+
+	storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+		destroy()
+		if err := client.CreateDB(ctx, dbName); err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		s, err := storage.NewCouchDBStorage(ctx, url, dbName, "", "", "", storage.DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create CouchDB storage: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,190 @@
+// This file contains the attachments of notes stored in CouchDB, as attachments of the
+// note documents. Every change of an attachment creates a new revision of its document.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4"
+
+	"golang-simple-notes/model"
+)
+
+// couchAttachment is the stub of an attachment, as CouchDB returns it with its document.
+type couchAttachment struct {
+	ContentType string `json:"content_type"`
+	Length      int64  `json:"length"`
+	Digest      string `json:"digest,omitempty"`
+	Stub        bool   `json:"stub"`
+}
+
+// couchAttachments are the attachment stubs of a document, by name. Documents are replaced
+// as a whole, so updates send the stubs back; CouchDB drops the attachments they leave out.
+type couchAttachments map[string]couchAttachment
+
+// couchNote is a note document with its attachment stubs.
+type couchNote struct {
+	model.Note
+	Attachments couchAttachments `json:"_attachments,omitempty"`
+}
+
+// PutAttachment stores an attachment of a note as an attachment of its document, on top of
+// the document's current revision. Conflicts are resolved according to the storage's
+// ConflictPolicy; with last-write-wins, the upload is only retried if the body can be
+// rewound (it implements io.Seeker), and ErrConflict is returned otherwise.
+func (s *CouchDBStorage) PutAttachment(ctx context.Context, noteID string, attachment Attachment, body io.Reader) (Attachment, error) {
+	for attempt := 1; ; attempt++ {
+		rev, err := s.currentRev(ctx, noteID)
+		if err != nil {
+			return Attachment{}, err
+		}
+
+		_, err = s.db.PutAttachment(ctx, noteID, &kivik.Attachment{
+			Filename:    attachment.Name,
+			ContentType: attachment.ContentType,
+			Content:     io.NopCloser(body),
+			Size:        attachment.Size,
+		}, kivik.Rev(rev))
+		if err == nil {
+			break
+		}
+		if kivik.HTTPStatus(err) != http.StatusConflict {
+			return Attachment{}, fmt.Errorf("failed to store attachment: %w", err)
+		}
+		seeker, ok := body.(io.Seeker)
+		if !ok || s.conflicts != ConflictLastWriteWins || attempt == couchConflictAttempts {
+			return Attachment{}, fmt.Errorf("%w: %s is no longer the current revision of %s", ErrConflict, rev, noteID)
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return Attachment{}, fmt.Errorf("failed to rewind attachment: %w", err)
+		}
+	}
+
+	// CouchDB computes the digest, and may compress the content
+	meta, err := s.db.GetAttachmentMeta(ctx, noteID, attachment.Name)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return couchAttachmentOf(attachment.Name, meta), nil
+}
+
+// GetAttachment returns an attachment of a note with its content, streamed from CouchDB.
+// The size is -1 if CouchDB sends the content compressed.
+func (s *CouchDBStorage) GetAttachment(ctx context.Context, noteID, name string) (Attachment, io.ReadCloser, error) {
+	att, err := s.db.GetAttachment(ctx, noteID, name)
+	if err != nil {
+		if kivik.HTTPStatus(err) == http.StatusNotFound {
+			// Either the note or the attachment is missing
+			if _, err := s.currentRev(ctx, noteID); err != nil {
+				return Attachment{}, nil, err
+			}
+			return Attachment{}, nil, ErrAttachmentNotFound
+		}
+		return Attachment{}, nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return couchAttachmentOf(name, att), att.Content, nil
+}
+
+// DeleteAttachment removes an attachment of a note from its document. Conflicts are
+// resolved according to the storage's ConflictPolicy.
+func (s *CouchDBStorage) DeleteAttachment(ctx context.Context, noteID, name string) error {
+	for attempt := 1; ; attempt++ {
+		rev, attachments, err := s.currentDoc(ctx, noteID)
+		if err != nil {
+			return err
+		}
+		if _, ok := attachments[name]; !ok {
+			return ErrAttachmentNotFound
+		}
+
+		_, err = s.db.DeleteAttachment(ctx, noteID, rev, name)
+		switch {
+		case err == nil:
+			return nil
+		case kivik.HTTPStatus(err) == http.StatusNotFound:
+			return ErrAttachmentNotFound
+		case kivik.HTTPStatus(err) != http.StatusConflict:
+			return fmt.Errorf("failed to delete attachment: %w", err)
+		case s.conflicts != ConflictLastWriteWins || attempt == couchConflictAttempts:
+			return fmt.Errorf("%w: %s is no longer the current revision of %s", ErrConflict, rev, noteID)
+		}
+	}
+}
+
+// ListAttachments returns the attachments of a note, from the stubs of its document.
+func (s *CouchDBStorage) ListAttachments(ctx context.Context, noteID string) ([]Attachment, error) {
+	_, stubs, err := s.currentDoc(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return stubs.list(), nil
+}
+
+// ListAttachmentsMany returns the attachments of several notes, from the stubs of their
+// documents, read with a single request to _all_docs.
+func (s *CouchDBStorage) ListAttachmentsMany(ctx context.Context, noteIDs []string) (map[string][]Attachment, error) {
+	rows := s.db.AllDocs(ctx, kivik.Params(map[string]any{
+		"keys":         uniqueIDs(noteIDs),
+		"include_docs": true,
+	}))
+	defer rows.Close()
+
+	attachments := make(map[string][]Attachment, len(noteIDs))
+	for rows.Next() {
+		// Rows of missing documents have no value, and those of deleted ones no document
+		var value struct {
+			Rev     string `json:"rev"`
+			Deleted bool   `json:"deleted"`
+		}
+		if err := rows.ScanValue(&value); err != nil {
+			if kivik.HTTPStatus(err) == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		if value.Rev == "" || value.Deleted {
+			continue
+		}
+
+		var doc struct {
+			ID          string           `json:"_id"`
+			Attachments couchAttachments `json:"_attachments"`
+		}
+		if err := rows.ScanDoc(&doc); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		attachments[doc.ID] = doc.Attachments.list()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// list returns the attachments described by the stubs, sorted by name.
+func (stubs couchAttachments) list() []Attachment {
+	attachments := make([]Attachment, 0, len(stubs))
+	for name, stub := range stubs {
+		attachments = append(attachments, Attachment{
+			Name:        name,
+			ContentType: stub.ContentType,
+			Size:        stub.Length,
+			Digest:      stub.Digest,
+		})
+	}
+	sortAttachments(attachments)
+	return attachments
+}
+
+// couchAttachmentOf converts an attachment returned by Kivik.
+func couchAttachmentOf(name string, att *kivik.Attachment) Attachment {
+	return Attachment{
+		Name:        name,
+		ContentType: att.ContentType,
+		Size:        att.Size,
+		Digest:      att.Digest,
+	}
+}
@@ -0,0 +1,111 @@
+// This file contains a CouchDB changes feed reader, which reports changes to notes
+// made by any client of the database, including other instances of the application.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-kivik/kivik/v4"
+)
+
+// couchChangesHeartbeat is how often CouchDB sends an empty line on an idle changes feed,
+// so that a dead connection is noticed instead of waiting forever.
+const couchChangesHeartbeat = 30 * time.Second
+
+// CouchChangesFeed reports changes to the notes database, read from the continuous
+// _changes feed. The changed notes themselves are not included; they may be encrypted,
+// so they should be read through the storage.
+type CouchChangesFeed struct {
+	db    *kivik.DB
+	since string // Update sequence after which changes are reported
+}
+
+// WatchChanges prepares a changes feed on the notes database, starting at the current
+// update sequence. Preparing the feed before the application starts serving requests
+// makes sure no change made after startup is missed.
+//
+// Parameters:
+//   - ctx: The context for reading the current update sequence
+//
+// Returns:
+//   - A CouchChangesFeed that reports changes once Run is called
+//   - An error if the database cannot be reached
+func (s *CouchDBStorage) WatchChanges(ctx context.Context) (*CouchChangesFeed, error) {
+	stats, err := s.db.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the update sequence: %w", err)
+	}
+	return &CouchChangesFeed{db: s.db, since: stats.UpdateSeq}, nil
+}
+
+// Run calls handle for every change, until ctx is canceled. If the feed fails,
+// e.g., because the connection to the server is lost, it is reopened where it left off.
+//
+// Parameters:
+//   - ctx: The context; canceling it stops Run
+//   - handle: The function called for every change, one change at a time
+func (f *CouchChangesFeed) Run(ctx context.Context, handle func(Change)) {
+	for {
+		err := f.read(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("CouchDB changes feed failed: %v; reopening in %v", err, changeStreamRetryDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(changeStreamRetryDelay):
+		}
+	}
+}
+
+// Close closes the changes feed. The feed holds no connection once Run has returned,
+// so there is nothing to release.
+func (f *CouchChangesFeed) Close(context.Context) error {
+	return nil
+}
+
+// read reads the continuous changes feed after the last change seen, until it fails.
+func (f *CouchChangesFeed) read(ctx context.Context, handle func(Change)) error {
+	changes := f.db.Changes(ctx, kivik.Params(map[string]any{
+		"feed":      "continuous",
+		"since":     f.since,
+		"heartbeat": couchChangesHeartbeat.Milliseconds(),
+		"style":     "main_only",
+	}))
+	defer changes.Close()
+
+	for changes.Next() {
+		if change, ok := couchChange(changes.ID(), changes.Deleted(), changes.Changes()); ok {
+			handle(change)
+		}
+		f.since = changes.Seq()
+	}
+	if err := changes.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("the feed ended")
+}
+
+// couchChange converts an entry of the changes feed into a Change.
+// Design documents are not notes, so changes to them are not reported.
+// A document whose revision is the first one was just created.
+func couchChange(id string, deleted bool, revs []string) (Change, bool) {
+	if id == "" || strings.HasPrefix(id, "_") {
+		return Change{}, false
+	}
+
+	changeType := ChangeUpdated
+	switch {
+	case deleted:
+		changeType = ChangeDeleted
+	case len(revs) > 0 && strings.HasPrefix(revs[0], "1-"):
+		changeType = ChangeCreated
+	}
+	return Change{Type: changeType, NoteID: id}, true
+}
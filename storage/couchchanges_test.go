@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TestCouchChange tests converting changes feed entries into changes
+func TestCouchChange(t *testing.T) {
+	tests := map[string]struct {
+		id      string
+		deleted bool
+		revs    []string
+		want    string // Expected change type; empty if the entry is ignored
+	}{
+		"Created":      {"note-1", false, []string{"1-abc"}, ChangeCreated},
+		"Updated":      {"note-1", false, []string{"2-def"}, ChangeUpdated},
+		"Deleted":      {"note-1", true, []string{"3-ghi"}, ChangeDeleted},
+		"NoRevisions":  {"note-1", false, nil, ChangeUpdated},
+		"DesignDoc":    {"_design/notes-indexes", false, []string{"1-abc"}, ""},
+		"EmptyID":      {"", false, []string{"1-abc"}, ""},
+		"DeletedFirst": {"note-1", true, []string{"1-abc"}, ChangeDeleted},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			change, ok := couchChange(tt.id, tt.deleted, tt.revs)
+			if ok != (tt.want != "") || change.Type != tt.want {
+				t.Fatalf("Expected change type %q, got %q (ok: %t)", tt.want, change.Type, ok)
+			}
+			if ok && change.NoteID != tt.id {
+				t.Errorf("Expected note ID %s, got %q", tt.id, change.NoteID)
+			}
+		})
+	}
+}
+
+// TestCouchDBChangesFeed tests that changes are reported by the changes feed
+// This test uses the shared CouchDB container from TestMain
+func TestCouchDBChangesFeed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping CouchDB integration test in short mode")
+	}
+	url := getSharedCouchURL()
+	if url == "" {
+		t.Skip("Shared CouchDB container not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	storage, err := NewCouchDBStorage(ctx, url, "test_notes_changes", "", "", "", DefaultRetryPolicy())
+	if err != nil {
+		t.Fatalf("Failed to create CouchDB storage: %v", err)
+	}
+	CleanupCloseWithContext(t, ctx, storage)
+
+	changes, err := storage.WatchChanges(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open changes feed: %v", err)
+	}
+	CleanupCloseWithContext(t, ctx, changes)
+
+	received := make(chan Change, 10)
+	go changes.Run(ctx, func(change Change) { received <- change })
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	note.Title = "Updated"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	if err := storage.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+
+	// The feed may coalesce changes to the same document, but always ends with the deletion
+	for {
+		select {
+		case change := <-received:
+			if change.NoteID != note.ID {
+				t.Errorf("Expected a change of %s, got %+v", note.ID, change)
+			}
+			if change.Type == ChangeDeleted {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the deletion")
+		}
+	}
+}
@@ -2,7 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	neturl "net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -12,83 +16,6 @@ import (
 	"golang-simple-notes/model"
 )
 
-// TestCouchDBStorage tests the CouchDB storage implementation
-// This test uses the shared CouchDB container from TestMain
-func TestCouchDBStorage(t *testing.T) {
-	// Skip this test if we're not running integration tests
-	if testing.Short() {
-		t.Skip("Skipping CouchDB integration test in short mode")
-	}
-
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Use the shared CouchDB container
-	url := getSharedCouchURL()
-	if url == "" {
-		t.Skip("Shared CouchDB container not available")
-	}
-	dbName := "test_notes"
-
-	// Connect to the CouchDB container
-	client, err := kivik.New("couch", url)
-	if err != nil {
-		t.Fatalf("Failed to connect to CouchDB container: %v", err)
-	}
-	CleanupClose(t, client)
-
-	// Check if the server is available with retries
-	// Sometimes CouchDB takes a moment to fully initialize authentication even after the port is open
-	maxAttempts := 10
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		_, err = client.AllDBs(ctx)
-		if err == nil {
-			break
-		}
-		if attempt == maxAttempts {
-			t.Fatalf("Failed to list databases in CouchDB container after %d attempts: %v", maxAttempts, err)
-		}
-		t.Logf("Attempt %d: CouchDB not ready yet (err: %v), retrying...", attempt, err)
-		time.Sleep(500 * time.Millisecond)
-	}
-
-	// Clean up any existing test database
-	if exists, _ := client.DBExists(ctx, dbName); exists {
-		if err := client.DestroyDB(ctx, dbName); err != nil {
-			t.Logf("Warning: Failed to destroy test database: %v", err)
-		}
-	}
-
-	// Create the database
-	if err := client.CreateDB(ctx, dbName); err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-
-	// Create a new CouchDB storage
-	storage, err := NewCouchDBStorage(url, dbName)
-	if err != nil {
-		t.Fatalf("Failed to create CouchDB storage: %v", err)
-	}
-
-	// Run the fixed storage tests
-	testNoteStorage(t, storage, ctx)
-
-	// Clean up after the test
-	if err := client.DestroyDB(ctx, dbName); err != nil {
-		t.Logf("Warning: Failed to destroy test database: %v", err)
-	}
-}
-
-// TestCouchDBStorageUnit tests the CouchDB storage implementation with unit tests
-func TestCouchDBStorageUnit(t *testing.T) {
-	// Create a mock implementation of NoteStorage that behaves like CouchDB
-	storage := NewMockCouchDBStorage()
-
-	// Run the fixed storage tests
-	testNoteStorage(t, storage, context.Background())
-}
-
 // MockCouchDBStorage is a mock implementation of NoteStorage that behaves like CouchDB
 type MockCouchDBStorage struct {
 	notes map[string]*model.Note
@@ -109,7 +36,7 @@ func (s *MockCouchDBStorage) Create(_ context.Context, note *model.Note) error {
 	}
 
 	// Store a copy of the note
-	s.notes[note.ID] = note
+	s.notes[note.ID] = copyMockNote(note)
 	return nil
 }
 
@@ -119,7 +46,7 @@ func (s *MockCouchDBStorage) Get(_ context.Context, id string) (*model.Note, err
 	if !exists {
 		return nil, ErrNoteNotFound
 	}
-	return note, nil
+	return copyMockNote(note), nil
 }
 
 // GetAll retrieves all notes from the storage
@@ -130,7 +57,7 @@ func (s *MockCouchDBStorage) GetAll(_ context.Context) ([]*model.Note, error) {
 		if strings.HasPrefix(note.ID, "_design/") || strings.HasPrefix(note.ID, "_") {
 			continue
 		}
-		notes = append(notes, note)
+		notes = append(notes, copyMockNote(note))
 	}
 	return notes, nil
 }
@@ -145,7 +72,7 @@ func (s *MockCouchDBStorage) Update(_ context.Context, note *model.Note) error {
 	note.UpdatedAt = time.Now()
 
 	// Store a copy of the note
-	s.notes[note.ID] = note
+	s.notes[note.ID] = copyMockNote(note)
 	return nil
 }
 
@@ -159,6 +86,11 @@ func (s *MockCouchDBStorage) Delete(_ context.Context, id string) error {
 	return nil
 }
 
+// Ping always succeeds for mock storage
+func (s *MockCouchDBStorage) Ping(_ context.Context) error {
+	return nil
+}
+
 // Close close any resources used by the storage
 func (s *MockCouchDBStorage) Close(_ context.Context) error {
 	// Nothing to close for mock storage
@@ -209,12 +141,40 @@ func TestCouchDBSpecificFeatures(t *testing.T) {
 	}
 
 	// Create a new CouchDB storage
-	storage, err := NewCouchDBStorage(url, dbName)
+	storage, err := NewCouchDBStorage(ctx, url, dbName, "", "", "", DefaultRetryPolicy())
 	if err != nil {
 		t.Fatalf("Failed to create CouchDB storage: %v", err)
 	}
 	CleanupCloseWithContext(t, ctx, storage)
 
+	// Test connecting with credentials passed separately from the URL
+	t.Run("SeparateCredentials", func(t *testing.T) {
+		u, err := neturl.Parse(url)
+		if err != nil {
+			t.Fatalf("Failed to parse URL: %v", err)
+		}
+		if u.User == nil {
+			t.Skip("The shared CouchDB container doesn't require credentials")
+		}
+		user := u.User.Username()
+		password, _ := u.User.Password()
+		u.User = nil
+
+		withCredentials, err := NewCouchDBStorage(ctx, u.String(), dbName, user, password, "", DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create CouchDB storage with separate credentials: %v", err)
+		}
+		if err := withCredentials.Ping(ctx); err != nil {
+			t.Errorf("Ping failed: %v", err)
+		}
+
+		// Wrong credentials are rejected
+		policy := RetryPolicy{MaxAttempts: 1}
+		if _, err := NewCouchDBStorage(ctx, u.String(), dbName, user, "wrong-"+password, "", policy); err == nil {
+			t.Error("Expected an error with a wrong password")
+		}
+	})
+
 	// Test document revision handling
 	t.Run("DocumentRevision", func(t *testing.T) {
 		note := model.NewNote("Test Title", "Test Content")
@@ -243,6 +203,55 @@ func TestCouchDBSpecificFeatures(t *testing.T) {
 		}
 	})
 
+	// Test the conflict resolution policies
+	t.Run("ConflictPolicies", func(t *testing.T) {
+		rejecting, err := NewCouchDBStorage(ctx, url, dbName, "", "", ConflictReject, DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create CouchDB storage: %v", err)
+		}
+		CleanupCloseWithContext(t, ctx, rejecting)
+
+		note := model.NewNote("Original", "Content")
+		if err := storage.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		stale, err := storage.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+
+		// Another client updates the note
+		current := *stale
+		current.Title = "Updated elsewhere"
+		if err := storage.Update(ctx, &current); err != nil {
+			t.Fatalf("Failed to update note: %v", err)
+		}
+		if current.Rev == stale.Rev {
+			t.Errorf("Expected the update to set the new revision, got %s", current.Rev)
+		}
+
+		// An update based on the stale revision is rejected...
+		rejected := *stale
+		rejected.Title = "Stale update"
+		if err := rejecting.Update(ctx, &rejected); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+
+		// ...unless the last write wins
+		if err := storage.Update(ctx, &rejected); err != nil {
+			t.Errorf("Expected the last write to win, got %v", err)
+		}
+		if retrieved, err := storage.Get(ctx, note.ID); err != nil || retrieved.Title != "Stale update" {
+			t.Errorf("Expected the last write to be stored, got %+v (%v)", retrieved, err)
+		}
+
+		// Updating a missing note is not a conflict
+		missing := &model.Note{ID: "missing-note", Rev: stale.Rev, Title: "Missing"}
+		if err := rejecting.Update(ctx, missing); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
 	// Test handling of design documents in GetAll
 	t.Run("SkipDesignDocuments", func(t *testing.T) {
 		// Clean up any existing test database
@@ -258,7 +267,7 @@ func TestCouchDBSpecificFeatures(t *testing.T) {
 		}
 
 		// Create a new CouchDB storage
-		storage, err := NewCouchDBStorage(url, dbName)
+		storage, err := NewCouchDBStorage(ctx, url, dbName, "", "", "", DefaultRetryPolicy())
 		if err != nil {
 			t.Fatalf("Failed to create CouchDB storage: %v", err)
 		}
@@ -322,6 +331,168 @@ func TestCouchDBSpecificFeatures(t *testing.T) {
 		}
 	})
 
+	// Test filtering, sorting, and pagination with Mango queries
+	t.Run("MangoQueries", func(t *testing.T) {
+		mangoDB := "test_notes_mango"
+		if exists, _ := client.DBExists(ctx, mangoDB); exists {
+			if err := client.DestroyDB(ctx, mangoDB); err != nil {
+				t.Logf("Warning: Failed to destroy test database: %v", err)
+			}
+		}
+
+		storage, err := NewCouchDBStorage(ctx, url, mangoDB, "", "", "", DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create CouchDB storage: %v", err)
+		}
+		CleanupCloseWithContext(t, ctx, storage)
+
+		// The JSON indexes are created on startup
+		indexes, err := client.DB(mangoDB).GetIndexes(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get indexes: %v", err)
+		}
+		names := make(map[string]bool)
+		for _, index := range indexes {
+			names[index.Name] = true
+		}
+		for _, field := range couchIndexes {
+			if !names["notes_"+field] {
+				t.Errorf("Expected index notes_%s, got %v", field, names)
+			}
+		}
+
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, title := range []string{"Banana", "apple pie", "Cherry", "Apple juice"} {
+			note := model.NewNote(title, "Content")
+			note.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+			note.Tags = []string{"fruit", "pie"}[:i%3]
+			if err := storage.Create(ctx, note); err != nil {
+				t.Fatalf("Failed to create note: %v", err)
+			}
+		}
+
+		notes, err := storage.List(ctx, ListOptions{Query: "APPLE", Sort: SortCreatedAt, Descending: true})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if got := noteTitles(notes); !reflect.DeepEqual(got, []string{"Apple juice", "apple pie"}) {
+			t.Errorf("Expected the matching notes newest first, got %v", got)
+		}
+
+		notes, err = storage.List(ctx, ListOptions{Sort: SortCreatedAt, Limit: 2, Offset: 1})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if got := noteTitles(notes); !reflect.DeepEqual(got, []string{"apple pie", "Cherry"}) {
+			t.Errorf("Expected the second page of notes, got %v", got)
+		}
+
+		if count, err := storage.Count(ctx, ListOptions{Query: "APPLE"}); err != nil || count != 2 {
+			t.Errorf("Expected 2 matching notes, got %d, %v", count, err)
+		}
+
+		// The statistics view agrees with the notes themselves
+		all, err := storage.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		want := NoteStats{Storage: BackendCouchDB}
+		for _, note := range all {
+			want.add(note)
+		}
+		stats, err := storage.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		if stats.Storage != want.Storage || stats.Notes != want.Notes || stats.ContentBytes != want.ContentBytes ||
+			!stats.Oldest.Equal(want.Oldest.Truncate(time.Millisecond)) || !stats.Newest.Equal(want.Newest.Truncate(time.Millisecond)) {
+			t.Errorf("Expected statistics %+v, got %+v", want, stats)
+		}
+		if !reflect.DeepEqual(stats.Tags, want.Tags) {
+			t.Errorf("Expected notes per tag %v, got %v", want.Tags, stats.Tags)
+		}
+	})
+
+	t.Run("Maintain", func(t *testing.T) {
+		for _, task := range []MaintenanceTask{TaskReindex, TaskCompact} {
+			if err := storage.Maintain(ctx, task); err != nil {
+				t.Errorf("%s failed: %v", task, err)
+			}
+		}
+		// Sorted lists need the recreated indexes
+		if _, err := storage.List(ctx, ListOptions{Sort: SortTitle}); err != nil {
+			t.Errorf("List after reindex failed: %v", err)
+		}
+	})
+
+	// Test attachments, which must survive updates of their note
+	t.Run("Attachments", func(t *testing.T) {
+		note := model.NewNote("With attachments", "Content")
+		if err := storage.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		stale, err := storage.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+
+		attachment, err := storage.PutAttachment(ctx, note.ID, Attachment{Name: "hello.txt", ContentType: "text/plain", Size: 5}, strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("PutAttachment failed: %v", err)
+		}
+		if attachment.Size != 5 || attachment.ContentType != "text/plain" || attachment.Digest == "" {
+			t.Errorf("Unexpected attachment %+v", attachment)
+		}
+
+		// The attachment created a new revision, so updates based on the previous one conflict...
+		rejecting, err := NewCouchDBStorage(ctx, url, dbName, "", "", ConflictReject, DefaultRetryPolicy())
+		if err != nil {
+			t.Fatalf("Failed to create CouchDB storage: %v", err)
+		}
+		CleanupCloseWithContext(t, ctx, rejecting)
+		stale.Title = "Stale update"
+		if err := rejecting.Update(ctx, stale); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+
+		// ...and updates on top of the current revision keep the attachment
+		note.Title = "Updated"
+		if err := storage.Update(ctx, note); err != nil {
+			t.Fatalf("Failed to update note: %v", err)
+		}
+		if err := storage.UpdateIf(ctx, note, note.UpdatedAt); err != nil {
+			t.Fatalf("Failed to update note conditionally: %v", err)
+		}
+		_, body, err := storage.GetAttachment(ctx, note.ID, "hello.txt")
+		if err != nil {
+			t.Fatalf("GetAttachment failed: %v", err)
+		}
+		content, err := io.ReadAll(body)
+		_ = body.Close()
+		if err != nil || string(content) != "hello" {
+			t.Errorf("Expected the attachment to survive the updates, got %q: %v", content, err)
+		}
+		if list, err := storage.ListAttachments(ctx, note.ID); err != nil || len(list) != 1 || list[0].Name != "hello.txt" {
+			t.Errorf("Unexpected attachments %+v: %v", list, err)
+		}
+		if many, err := storage.ListAttachmentsMany(ctx, []string{note.ID, "missing"}); err != nil || len(many) != 1 || len(many[note.ID]) != 1 {
+			t.Errorf("Unexpected attachments of several notes %+v: %v", many, err)
+		}
+
+		if err := storage.DeleteAttachment(ctx, note.ID, "hello.txt"); err != nil {
+			t.Fatalf("DeleteAttachment failed: %v", err)
+		}
+		if _, _, err := storage.GetAttachment(ctx, note.ID, "hello.txt"); !errors.Is(err, ErrAttachmentNotFound) {
+			t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+		}
+		if err := storage.DeleteAttachment(ctx, note.ID, "hello.txt"); !errors.Is(err, ErrAttachmentNotFound) {
+			t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+		}
+		if _, _, err := storage.GetAttachment(ctx, "missing-note", "hello.txt"); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
 	// Test error cases
 	t.Run("ErrorCases", func(t *testing.T) {
 		// Test Create error
@@ -423,3 +594,37 @@ func TestCouchDBSpecificFeatures(t *testing.T) {
 		t.Logf("Warning: Failed to destroy test database: %v", err)
 	}
 }
+
+// TestConflictPolicy tests validation of conflict resolution policies
+func TestConflictPolicy(t *testing.T) {
+	for _, policy := range []ConflictPolicy{ConflictLastWriteWins, ConflictReject} {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", policy, err)
+		}
+	}
+	if err := ConflictPolicy("first-write-wins").Validate(); err == nil {
+		t.Error("Expected an unknown policy to be invalid")
+	}
+
+	// An invalid policy is rejected before connecting
+	if _, err := NewCouchDBStorage(context.Background(), "http://localhost:1", "notes", "", "", "merge", DefaultRetryPolicy()); err == nil ||
+		!strings.Contains(err.Error(), "conflict policy") {
+		t.Errorf("Expected a conflict policy error, got %v", err)
+	}
+}
+
+// TestNewCouchDBStorageCanceled verifies that canceling the context stops connecting
+// right away, instead of retrying until the policy gives up
+func TestNewCouchDBStorageCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err := NewCouchDBStorage(ctx, "http://localhost:1", "notes", "", "", "", DefaultRetryPolicy())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected connecting to stop right away, took %v", elapsed)
+	}
+}
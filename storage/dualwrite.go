@@ -0,0 +1,421 @@
+// This file contains the dual-write decorator used while migrating notes between
+// storage backends, together with an asynchronous verifier that re-reads every
+// written note from both backends and records divergences.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// maxDivergences limits how many divergences the verifier keeps for the report.
+// Older entries are discarded first; the mismatch counter keeps the full total.
+const maxDivergences = 500
+
+// DualWriteStorage implements NoteStorage for migrations between two backends.
+// Reads are served by the primary backend. Writes go to the primary first and are
+// then mirrored to the secondary (the migration target). Failures on the secondary
+// are logged but not returned, so the migration target cannot break the service.
+type DualWriteStorage struct {
+	primary   NoteStorage // Backend that serves reads and is the source of truth
+	secondary NoteStorage // Migration target that receives a copy of every write
+	verifier  *Verifier   // Optional verifier checking each write on both backends
+}
+
+// NewDualWriteStorage creates a new dual-write decorator.
+//
+// Parameters:
+//   - primary: The storage backend that serves reads
+//   - secondary: The storage backend that receives a copy of every write
+//   - verifier: An optional verifier (nil disables verification)
+//
+// Returns:
+//   - A pointer to a new DualWriteStorage instance
+func NewDualWriteStorage(primary, secondary NoteStorage, verifier *Verifier) *DualWriteStorage {
+	return &DualWriteStorage{
+		primary:   primary,
+		secondary: secondary,
+		verifier:  verifier,
+	}
+}
+
+// Create adds a new note to both backends.
+func (s *DualWriteStorage) Create(ctx context.Context, note *model.Note) error {
+	if err := s.primary.Create(ctx, note); err != nil {
+		return err
+	}
+
+	// The secondary gets its own copy; revisions are backend-specific
+	mirrored := *note
+	mirrored.Rev = ""
+	if err := s.secondary.Create(ctx, &mirrored); err != nil {
+		log.Printf("%sdual-write: Create %s on secondary failed: %v", requestid.LogPrefix(ctx), note.ID, err)
+	}
+	s.verify(ctx, "Create", note.ID)
+	return nil
+}
+
+// Get retrieves a note from the primary backend.
+func (s *DualWriteStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	return s.primary.Get(ctx, id)
+}
+
+// GetAll retrieves all notes from the primary backend.
+func (s *DualWriteStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	return s.primary.GetAll(ctx)
+}
+
+// List runs a list query on the primary backend.
+func (s *DualWriteStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	return List(ctx, s.primary, opts)
+}
+
+// Exists checks whether a note exists in the primary backend.
+func (s *DualWriteStorage) Exists(ctx context.Context, id string) (bool, error) {
+	return Exists(ctx, s.primary, id)
+}
+
+// GetMany retrieves notes by ID from the primary backend.
+func (s *DualWriteStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	return GetMany(ctx, s.primary, ids)
+}
+
+// Count counts the matching notes in the primary backend.
+func (s *DualWriteStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	return Count(ctx, s.primary, opts)
+}
+
+// Stats returns statistics about the notes in the primary backend.
+func (s *DualWriteStorage) Stats(ctx context.Context) (NoteStats, error) {
+	return Stats(ctx, s.primary)
+}
+
+// Maintain runs a maintenance task on the primary backend only; the secondary is
+// maintained on its own.
+func (s *DualWriteStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	return Maintain(ctx, s.primary, task)
+}
+
+// RunInTransaction runs fn in a transaction of the primary backend. Writes are mirrored
+// to the secondary as they are made, outside the transaction, so the verifier reports
+// the writes of an aborted transaction as divergences.
+func (s *DualWriteStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTransaction(ctx, s.primary, fn)
+}
+
+// Stream reads all notes from the primary backend one at a time.
+func (s *DualWriteStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	return Stream(ctx, s.primary, fn)
+}
+
+// Update updates a note on both backends.
+func (s *DualWriteStorage) Update(ctx context.Context, note *model.Note) error {
+	if err := s.primary.Update(ctx, note); err != nil {
+		return err
+	}
+
+	mirrored := *note
+	mirrored.Rev = ""
+	if err := s.secondary.Update(ctx, &mirrored); err != nil {
+		log.Printf("%sdual-write: Update %s on secondary failed: %v", requestid.LogPrefix(ctx), note.ID, err)
+	}
+	s.verify(ctx, "Update", note.ID)
+	return nil
+}
+
+// UpdateIf updates a note on the primary backend if it is unchanged there, and mirrors
+// the update to the secondary unconditionally.
+func (s *DualWriteStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	if err := UpdateIf(ctx, s.primary, note, expectedUpdatedAt); err != nil {
+		return err
+	}
+
+	mirrored := *note
+	mirrored.Rev = ""
+	if err := s.secondary.Update(ctx, &mirrored); err != nil {
+		log.Printf("%sdual-write: UpdateIf %s on secondary failed: %v", requestid.LogPrefix(ctx), note.ID, err)
+	}
+	s.verify(ctx, "UpdateIf", note.ID)
+	return nil
+}
+
+// Upsert stores a note on both backends, creating it where needed.
+func (s *DualWriteStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	created, err := Upsert(ctx, s.primary, note)
+	if err != nil {
+		return false, err
+	}
+
+	mirrored := *note
+	mirrored.Rev = ""
+	if _, err := Upsert(ctx, s.secondary, &mirrored); err != nil {
+		log.Printf("%sdual-write: Upsert %s on secondary failed: %v", requestid.LogPrefix(ctx), note.ID, err)
+	}
+	s.verify(ctx, "Upsert", note.ID)
+	return created, nil
+}
+
+// Delete removes a note from both backends.
+func (s *DualWriteStorage) Delete(ctx context.Context, id string) error {
+	if err := s.primary.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.secondary.Delete(ctx, id); err != nil {
+		log.Printf("%sdual-write: Delete %s on secondary failed: %v", requestid.LogPrefix(ctx), id, err)
+	}
+	s.verify(ctx, "Delete", id)
+	return nil
+}
+
+// Ping checks the primary backend, which serves all reads.
+// The secondary is not required: failed secondary writes are only logged.
+func (s *DualWriteStorage) Ping(ctx context.Context) error {
+	return s.primary.Ping(ctx)
+}
+
+// Close closes both backends.
+func (s *DualWriteStorage) Close(ctx context.Context) error {
+	if s.verifier != nil {
+		s.verifier.Stop()
+	}
+	return errors.Join(s.primary.Close(ctx), s.secondary.Close(ctx))
+}
+
+// verify queues a written note for verification, if a verifier is configured.
+func (s *DualWriteStorage) verify(ctx context.Context, op, id string) {
+	if s.verifier != nil {
+		s.verifier.Enqueue(ctx, op, id)
+	}
+}
+
+// Divergence describes a note that differs between the two backends after a write.
+type Divergence struct {
+	NoteID        string    `json:"note_id"`                  // ID of the divergent note
+	Operation     string    `json:"operation"`                // Write operation that was verified
+	PrimaryHash   string    `json:"primary_hash,omitempty"`   // Hash of the note on the primary (empty if missing)
+	SecondaryHash string    `json:"secondary_hash,omitempty"` // Hash of the note on the secondary (empty if missing)
+	Reason        string    `json:"reason"`                   // Human-readable description of the difference
+	RequestID     string    `json:"request_id,omitempty"`     // Request that performed the write
+	DetectedAt    time.Time `json:"detected_at"`              // When the divergence was detected
+}
+
+// DivergenceReport summarizes the verifier's results.
+type DivergenceReport struct {
+	Verified    uint64       `json:"verified"`    // Writes found identical on both backends
+	Mismatched  uint64       `json:"mismatched"`  // Writes found different on the two backends
+	Errors      uint64       `json:"errors"`      // Verifications that failed to read a backend
+	Dropped     uint64       `json:"dropped"`     // Writes not verified because the queue was full
+	Divergences []Divergence `json:"divergences"` // Most recent divergences, oldest first
+}
+
+// verification is a queued request to compare a note on both backends.
+type verification struct {
+	op        string
+	id        string
+	requestID string
+}
+
+// Verifier asynchronously compares notes on two backends after they were written.
+// Verification runs in a background goroutine, so it doesn't slow down writes;
+// if the queue is full, verifications are dropped and counted instead of blocking.
+type Verifier struct {
+	primary   NoteStorage       // Backend treated as the source of truth
+	secondary NoteStorage       // Backend being verified
+	delay     time.Duration     // Time to wait after a write before re-reading it
+	queue     chan verification // Pending verifications
+
+	mutex       sync.Mutex
+	report      DivergenceReport // Counters and recent divergences
+	stopOnce    sync.Once
+	stopped     chan struct{}  // Closed when the verifier is stopped
+	workerGroup sync.WaitGroup // Tracks the background worker
+}
+
+// NewVerifier creates a new verifier and starts its background worker.
+//
+// Parameters:
+//   - primary: The backend treated as the source of truth
+//   - secondary: The backend being verified
+//   - queueSize: The maximum number of pending verifications
+//   - delay: How long to wait after a write before re-reading the note from both backends
+//
+// Returns:
+//   - A pointer to a new, running Verifier instance
+func NewVerifier(primary, secondary NoteStorage, queueSize int, delay time.Duration) *Verifier {
+	v := &Verifier{
+		primary:   primary,
+		secondary: secondary,
+		delay:     delay,
+		queue:     make(chan verification, queueSize),
+		stopped:   make(chan struct{}),
+	}
+	v.workerGroup.Add(1)
+	go v.run()
+	return v
+}
+
+// Enqueue schedules a written note for verification without blocking.
+func (v *Verifier) Enqueue(ctx context.Context, op, id string) {
+	select {
+	case <-v.stopped:
+		return
+	default:
+	}
+
+	select {
+	case v.queue <- verification{op: op, id: id, requestID: requestid.FromContext(ctx)}:
+	default:
+		v.mutex.Lock()
+		v.report.Dropped++
+		v.mutex.Unlock()
+		metrics.DualWriteVerifications.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Report returns a snapshot of the verification counters and recent divergences.
+func (v *Verifier) Report() DivergenceReport {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	report := v.report
+	report.Divergences = make([]Divergence, len(v.report.Divergences))
+	copy(report.Divergences, v.report.Divergences)
+	return report
+}
+
+// Stop stops the background worker after it finishes the verification in progress.
+// Pending verifications are discarded. It is safe to call Stop more than once.
+func (v *Verifier) Stop() {
+	v.stopOnce.Do(func() {
+		close(v.stopped)
+	})
+	v.workerGroup.Wait()
+}
+
+// run processes queued verifications until the verifier is stopped.
+func (v *Verifier) run() {
+	defer v.workerGroup.Done()
+	for {
+		select {
+		case <-v.stopped:
+			return
+		case item := <-v.queue:
+			if v.delay > 0 {
+				select {
+				case <-time.After(v.delay):
+				case <-v.stopped:
+					return
+				}
+			}
+			v.check(item)
+		}
+	}
+}
+
+// check re-reads a note from both backends and records the outcome.
+func (v *Verifier) check(item verification) {
+	ctx := requestid.NewContext(context.Background(), item.requestID)
+
+	primaryHash, primaryErr := v.hash(ctx, v.primary, item.id)
+	secondaryHash, secondaryErr := v.hash(ctx, v.secondary, item.id)
+	if primaryErr != nil || secondaryErr != nil {
+		log.Printf("%sdual-write: verifying %s failed: %v", requestid.LogPrefix(ctx), item.id,
+			errors.Join(primaryErr, secondaryErr))
+		v.mutex.Lock()
+		v.report.Errors++
+		v.mutex.Unlock()
+		metrics.DualWriteVerifications.WithLabelValues("error").Inc()
+		return
+	}
+
+	if primaryHash == secondaryHash {
+		v.mutex.Lock()
+		v.report.Verified++
+		v.mutex.Unlock()
+		metrics.DualWriteVerifications.WithLabelValues("match").Inc()
+		return
+	}
+
+	divergence := Divergence{
+		NoteID:        item.id,
+		Operation:     item.op,
+		PrimaryHash:   primaryHash,
+		SecondaryHash: secondaryHash,
+		RequestID:     item.requestID,
+		DetectedAt:    time.Now().UTC(),
+	}
+	switch {
+	case primaryHash == "":
+		divergence.Reason = "note exists only on the secondary"
+	case secondaryHash == "":
+		divergence.Reason = "note is missing on the secondary"
+	default:
+		divergence.Reason = "note content differs"
+	}
+	log.Printf("%sdual-write: divergence for %s after %s: %s", requestid.LogPrefix(ctx), item.id, item.op, divergence.Reason)
+
+	v.mutex.Lock()
+	v.report.Mismatched++
+	v.report.Divergences = append(v.report.Divergences, divergence)
+	if len(v.report.Divergences) > maxDivergences {
+		v.report.Divergences = v.report.Divergences[len(v.report.Divergences)-maxDivergences:]
+	}
+	v.mutex.Unlock()
+	metrics.DualWriteVerifications.WithLabelValues("mismatch").Inc()
+}
+
+// hash reads a note from a backend and returns its hash,
+// or an empty string if the note doesn't exist there.
+func (v *Verifier) hash(ctx context.Context, s NoteStorage, id string) (string, error) {
+	note, err := s.Get(ctx, id)
+	if errors.Is(err, ErrNoteNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return NoteHash(note), nil
+}
+
+// NoteHash returns a SHA-256 hash of the fields a note must have in common on all backends,
+// including its owner and summary. The backend-specific revision and the views, which are
+// stored apart from the note, are ignored, and timestamps are compared in UTC with
+// millisecond precision, because MongoDB doesn't store finer timestamps.
+func NoteHash(note *model.Note) string {
+	canonical, _ := json.Marshal(struct {
+		ID        string    `json:"id"`
+		Title     string    `json:"title"`
+		Content   string    `json:"content"`
+		Owner     string    `json:"owner,omitempty"` // Left out if empty, so the hashes of older notes don't change
+		Summary   string    `json:"summary,omitempty"`
+		Color     string    `json:"color,omitempty"`
+		Icon      string    `json:"icon,omitempty"`
+		Tags      []string  `json:"tags,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}{
+		ID:        note.ID,
+		Title:     note.Title,
+		Content:   note.Content,
+		Owner:     note.Owner,
+		Summary:   note.Summary,
+		Color:     note.Color,
+		Icon:      note.Icon,
+		Tags:      note.Tags,
+		CreatedAt: note.CreatedAt.UTC().Truncate(time.Millisecond),
+		UpdatedAt: note.UpdatedAt.UTC().Truncate(time.Millisecond),
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// waitForReport polls the verifier until the report satisfies the condition or the test times out
+func waitForReport(t *testing.T, v *Verifier, done func(DivergenceReport) bool) DivergenceReport {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		report := v.Report()
+		if done(report) {
+			return report
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for verification, last report: %+v", report)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestDualWriteStorageMirrorsWrites verifies that writes reach both backends
+func TestDualWriteStorageMirrorsWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	storage := NewDualWriteStorage(primary, secondary, nil)
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	mirrored, err := secondary.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Expected note on the secondary: %v", err)
+	}
+	if mirrored == note {
+		t.Error("Expected the secondary to receive a copy of the note")
+	}
+
+	note.Title = "Updated"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	if mirrored, _ := secondary.Get(ctx, note.ID); mirrored.Title != "Updated" {
+		t.Errorf("Expected updated title on the secondary, got %q", mirrored.Title)
+	}
+
+	if err := storage.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if _, err := secondary.Get(ctx, note.ID); err != ErrNoteNotFound {
+		t.Errorf("Expected note to be deleted on the secondary, got %v", err)
+	}
+}
+
+// TestDualWriteStorageSecondaryFailure verifies that secondary failures don't fail writes
+func TestDualWriteStorageSecondaryFailure(t *testing.T) {
+	ctx := context.Background()
+	captureLog(t)
+	storage := NewDualWriteStorage(NewInMemoryStorage(), &failingStorage{}, nil)
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Errorf("Expected Create to succeed despite the secondary, got %v", err)
+	}
+	if err := storage.Update(ctx, note); err != nil {
+		t.Errorf("Expected Update to succeed despite the secondary, got %v", err)
+	}
+	if err := storage.Delete(ctx, note.ID); err != nil {
+		t.Errorf("Expected Delete to succeed despite the secondary, got %v", err)
+	}
+}
+
+// TestVerifier verifies that matching writes are counted and divergences are reported
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	captureLog(t)
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	verifier := NewVerifier(primary, secondary, 10, 0)
+	storage := NewDualWriteStorage(primary, secondary, verifier)
+	defer func() { _ = storage.Close(ctx) }()
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	waitForReport(t, verifier, func(r DivergenceReport) bool { return r.Verified == 1 })
+
+	// Make the secondary diverge behind the decorator's back
+	diverged := *note
+	diverged.Content = "Something else"
+	if err := secondary.Update(ctx, &diverged); err != nil {
+		t.Fatalf("Failed to update secondary: %v", err)
+	}
+	verifier.Enqueue(ctx, "Update", note.ID)
+	report := waitForReport(t, verifier, func(r DivergenceReport) bool { return r.Mismatched == 1 })
+	if len(report.Divergences) != 1 || report.Divergences[0].Reason != "note content differs" {
+		t.Errorf("Unexpected divergences: %+v", report.Divergences)
+	}
+
+	// A note missing on the secondary is a divergence too
+	if err := secondary.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete from secondary: %v", err)
+	}
+	verifier.Enqueue(ctx, "Update", note.ID)
+	report = waitForReport(t, verifier, func(r DivergenceReport) bool { return r.Mismatched == 2 })
+	if report.Divergences[1].Reason != "note is missing on the secondary" {
+		t.Errorf("Unexpected reason: %q", report.Divergences[1].Reason)
+	}
+}
+
+// TestVerifierErrors verifies that read failures are counted as errors
+func TestVerifierErrors(t *testing.T) {
+	captureLog(t)
+	verifier := NewVerifier(NewInMemoryStorage(), &failingStorage{}, 10, 0)
+	defer verifier.Stop()
+
+	verifier.Enqueue(context.Background(), "Create", "note-1")
+	waitForReport(t, verifier, func(r DivergenceReport) bool { return r.Errors == 1 })
+}
+
+// TestVerifierDropsWhenFull verifies that a full queue drops verifications instead of blocking
+func TestVerifierDropsWhenFull(t *testing.T) {
+	verifier := NewVerifier(NewInMemoryStorage(), NewInMemoryStorage(), 1, time.Hour)
+	defer verifier.Stop()
+
+	for i := 0; i < 5; i++ {
+		verifier.Enqueue(context.Background(), "Create", "note-1")
+	}
+	// One verification is waiting in the worker, one in the queue; the rest are dropped
+	waitForReport(t, verifier, func(r DivergenceReport) bool { return r.Dropped >= 3 })
+}
+
+// TestNoteHash verifies that hashes ignore revisions and sub-millisecond time differences
+func TestNoteHash(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	a := &model.Note{ID: "1", Rev: "1-a", Title: "T", Content: "C", CreatedAt: created, UpdatedAt: created}
+	b := &model.Note{ID: "1", Rev: "2-b", Title: "T", Content: "C", CreatedAt: created.Truncate(time.Millisecond).In(time.FixedZone("X", 3600)), UpdatedAt: created}
+
+	if NoteHash(a) != NoteHash(b) {
+		t.Error("Expected equal hashes for notes differing only in revision and time precision")
+	}
+	for name, change := range map[string]func(*model.Note){
+		"content": func(n *model.Note) { n.Content = "Other" },
+		"owner":   func(n *model.Note) { n.Owner = "alice" },
+		"summary": func(n *model.Note) { n.Summary = "Summary" },
+		"tags":    func(n *model.Note) { n.Tags = []string{"work"} },
+	} {
+		changed := *b
+		change(&changed)
+		if NoteHash(a) == NoteHash(&changed) {
+			t.Errorf("Expected different hashes for a different %s", name)
+		}
+	}
+}
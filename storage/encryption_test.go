@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// testKey returns a deterministic 32-byte AES key filled with the given byte
+func testKey(b byte) []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+// newTestKeyring creates a keyring with the given active key and keys "k1" and "k2"
+func newTestKeyring(t *testing.T, active string) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(active, map[string][]byte{
+		"k1": testKey(1),
+		"k2": testKey(2),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	return keyring
+}
+
+// TestEncryptedStorageCiphertextAtRest verifies that the wrapped backend never sees plaintext
+func TestEncryptedStorageCiphertextAtRest(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryStorage()
+	storage := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false)
+
+	note := model.NewNote("Secret Title", "Secret Content")
+	note.Summary = "Secret Summary"
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// The caller's note must not be modified
+	if note.Title != "Secret Title" || note.Content != "Secret Content" {
+		t.Errorf("Expected caller's note to stay unencrypted, got %q / %q", note.Title, note.Content)
+	}
+
+	stored, err := inner.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Failed to get stored note: %v", err)
+	}
+	if !strings.HasPrefix(stored.Title, "enc:v1:k1:") || !strings.HasPrefix(stored.Content, "enc:v1:k1:") {
+		t.Errorf("Expected fields encrypted with k1, got %q / %q", stored.Title, stored.Content)
+	}
+	if strings.Contains(stored.Content, "Secret") || strings.Contains(stored.Summary, "Secret") {
+		t.Error("Plaintext leaked into stored content")
+	}
+
+	retrieved, err := storage.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if retrieved.Title != "Secret Title" || retrieved.Content != "Secret Content" || retrieved.Summary != "Secret Summary" {
+		t.Errorf("Expected decrypted note, got %q / %q / %q", retrieved.Title, retrieved.Content, retrieved.Summary)
+	}
+}
+
+// TestEncryptedStorageLazyRotation verifies that reads move notes to the active key
+func TestEncryptedStorageLazyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryStorage()
+
+	// Write a note with the old key
+	old := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false)
+	note := model.NewNote("Title", "Content")
+	if err := old.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Read it through a storage whose active key is the new one
+	rotating := NewEncryptedStorage(inner, newTestKeyring(t, "k2"), true)
+	retrieved, err := rotating.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if retrieved.Content != "Content" {
+		t.Errorf("Expected content 'Content', got %q", retrieved.Content)
+	}
+
+	stored, _ := inner.Get(ctx, note.ID)
+	if envelopeKeyID(stored.Content) != "k2" {
+		t.Errorf("Expected note to be re-encrypted with k2, got %q", envelopeKeyID(stored.Content))
+	}
+}
+
+// TestEncryptedStorageNoteGauge verifies that creating and deleting notes keeps the number
+// of notes per key up to date
+func TestEncryptedStorageNoteGauge(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryStorage()
+	notesOf := func(keyID string) float64 {
+		return testutil.ToFloat64(metrics.EncryptionNotes.WithLabelValues(keyID))
+	}
+	k1, k2 := notesOf("k1"), notesOf("k2")
+
+	old := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false)
+	note := model.NewNote("Title", "Content")
+	if err := old.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	current := NewEncryptedStorage(inner, newTestKeyring(t, "k2"), false)
+	if created, err := current.Upsert(ctx, model.NewNote("Other", "Content")); err != nil || !created {
+		t.Fatalf("Failed to upsert note: %v", err)
+	}
+	if got1, got2 := notesOf("k1")-k1, notesOf("k2")-k2; got1 != 1 || got2 != 1 {
+		t.Errorf("Expected one more note on each key, got %v and %v", got1, got2)
+	}
+
+	// The note is counted off the key it was encrypted with, not the active key
+	if err := current.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if got1, got2 := notesOf("k1")-k1, notesOf("k2")-k2; got1 != 0 || got2 != 1 {
+		t.Errorf("Expected the note to be counted off k1, got %v and %v", got1, got2)
+	}
+	if err := current.Delete(ctx, note.ID); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if got := notesOf("k1") - k1; got != 0 {
+		t.Errorf("Expected a failed delete to leave the gauge, got %v", got)
+	}
+}
+
+// TestEncryptedStorageBulkRotation verifies Rotate and KeyUsage
+func TestEncryptedStorageBulkRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := NewInMemoryStorage()
+
+	// One plaintext note written before encryption was enabled
+	legacy := model.NewNote("Legacy", "Written before encryption")
+	if err := inner.Create(ctx, legacy); err != nil {
+		t.Fatalf("Failed to create legacy note: %v", err)
+	}
+
+	// Two notes on the old key
+	old := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false)
+	for _, title := range []string{"Old 1", "Old 2"} {
+		if err := old.Create(ctx, model.NewNote(title, "Content")); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	storage := NewEncryptedStorage(inner, newTestKeyring(t, "k2"), false)
+
+	usage, err := storage.KeyUsage(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get key usage: %v", err)
+	}
+	if usage["k1"].Notes != 2 || usage[plaintextKeyID].Notes != 1 {
+		t.Errorf("Unexpected key usage before rotation: %+v", usage)
+	}
+
+	result, err := storage.Rotate(ctx)
+	if err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if result.Scanned != 3 || result.Rotated != 3 || result.Failed != 0 {
+		t.Errorf("Unexpected rotation result: %+v", result)
+	}
+
+	usage, err = storage.KeyUsage(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get key usage: %v", err)
+	}
+	if len(usage) != 1 || usage["k2"].Notes != 3 {
+		t.Errorf("Expected all notes on k2 after rotation, got %+v", usage)
+	}
+
+	// Running the rotation again is a no-op
+	result, err = storage.Rotate(ctx)
+	if err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if result.Rotated != 0 {
+		t.Errorf("Expected no notes to rotate, got %d", result.Rotated)
+	}
+
+	retrieved, err := storage.Get(ctx, legacy.ID)
+	if err != nil {
+		t.Fatalf("Failed to get legacy note: %v", err)
+	}
+	if retrieved.Content != "Written before encryption" {
+		t.Errorf("Unexpected legacy content after rotation: %q", retrieved.Content)
+	}
+}
+
+// TestEncryptedStorageErrors verifies handling of unknown keys and tampered data
+func TestEncryptedStorageErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		inner := NewInMemoryStorage()
+		writer := NewEncryptedStorage(inner, newTestKeyring(t, "k2"), false)
+		note := model.NewNote("Title", "Content")
+		if err := writer.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+
+		onlyK1, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+		if err != nil {
+			t.Fatalf("Failed to create keyring: %v", err)
+		}
+		reader := NewEncryptedStorage(inner, onlyK1, false)
+		if _, err := reader.Get(ctx, note.ID); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("Expected ErrUnknownKeyID, got %v", err)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		inner := NewInMemoryStorage()
+		storage := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false)
+		note := model.NewNote("Title", "Content")
+		if err := storage.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+
+		// Swap the encrypted title and content; the AAD check must reject it
+		stored, _ := inner.Get(ctx, note.ID)
+		stored.Title, stored.Content = stored.Content, stored.Title
+		if err := inner.Update(ctx, stored); err != nil {
+			t.Fatalf("Failed to update note: %v", err)
+		}
+		if _, err := storage.Get(ctx, note.ID); !errors.Is(err, ErrMalformedEnvelope) {
+			t.Errorf("Expected ErrMalformedEnvelope, got %v", err)
+		}
+	})
+}
+
+// TestNewKeyring covers keyring validation
+func TestNewKeyring(t *testing.T) {
+	cases := []struct {
+		name   string
+		active string
+		keys   map[string][]byte
+		valid  bool
+	}{
+		{"Valid", "k1", map[string][]byte{"k1": testKey(1)}, true},
+		{"NoKeys", "k1", map[string][]byte{}, false},
+		{"MissingActive", "k3", map[string][]byte{"k1": testKey(1)}, false},
+		{"BadKeyLength", "k1", map[string][]byte{"k1": []byte("short")}, false},
+		{"ColonInID", "a:b", map[string][]byte{"a:b": testKey(1)}, false},
+		{"ReservedID", plaintextKeyID, map[string][]byte{plaintextKeyID: testKey(1)}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewKeyring(c.active, c.keys)
+			if (err == nil) != c.valid {
+				t.Errorf("NewKeyring() error = %v, want valid=%v", err, c.valid)
+			}
+		})
+	}
+}
+
+// TestParseKeys covers parsing of the key specification
+func TestParseKeys(t *testing.T) {
+	spec := "k1:" + base64.StdEncoding.EncodeToString(testKey(1)) +
+		", k2:" + base64.StdEncoding.EncodeToString(testKey(2))
+	keys, err := ParseKeys(spec)
+	if err != nil {
+		t.Fatalf("Failed to parse keys: %v", err)
+	}
+	if len(keys) != 2 || len(keys["k1"]) != 32 || len(keys["k2"]) != 32 {
+		t.Errorf("Unexpected parsed keys: %v", keys)
+	}
+
+	// The format of key files: one key per line, with comments
+	file := "# Rotated keys\nk1:" + base64.StdEncoding.EncodeToString(testKey(1)) +
+		"\n\nk2:" + base64.StdEncoding.EncodeToString(testKey(2)) + "\n"
+	if keys, err := ParseKeys(file); err != nil || len(keys) != 2 {
+		t.Errorf("Expected 2 keys from the key file, got %v, %v", keys, err)
+	}
+
+	for _, bad := range []string{"no-separator", "k1:not-base64!"} {
+		if _, err := ParseKeys(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
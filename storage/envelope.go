@@ -0,0 +1,222 @@
+// This file contains envelope encryption for the encryption-at-rest decorator. Notes are
+// encrypted with AES data keys that are generated by the application, and the data keys
+// are encrypted ("wrapped") by a key encryption key that never leaves an external key
+// management service (e.g., AWS KMS, GCP KMS, or Vault transit), reached through a
+// KeyProvider. Every value stores its wrapped data key, so the KMS is only needed to
+// unwrap a data key the first time it is seen.
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// envelopePrefix marks a field value as envelope-encrypted. The full format is
+// "enc:v2:<KMS key ID>:<base64(wrapped data key)>:<base64(nonce || ciphertext)>".
+// KMS key IDs may contain colons (e.g., AWS ARNs), so the value is parsed from the right.
+const envelopePrefix = "enc:v2:"
+
+// dataKeyMaxUses is the number of values encrypted with a data key before a new one is
+// generated, well below the limit for random AES-GCM nonces.
+const dataKeyMaxUses = 1 << 20
+
+// maxUnwrappedKeys bounds the number of unwrapped data keys kept in memory.
+const maxUnwrappedKeys = 1024
+
+// KeyProvider wraps and unwraps data keys with a key encryption key held by an external
+// key management service. Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// KeyID identifies the key that wraps new data keys. It is stored with every value,
+	// so it must stay the same across restarts.
+	KeyID() string
+
+	// WrapKey encrypts a data key with the key identified by KeyID.
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a data key that was wrapped with the key identified by keyID,
+	// which may be a previous key of the provider.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// NewEnvelopeKeyring creates a keyring that encrypts new data with data keys wrapped by
+// a key provider. Local keys, if any, are only used to decrypt data that was encrypted
+// before the provider was configured; it is re-encrypted by key rotation like data on
+// any other old key.
+//
+// Parameters:
+//   - provider: The key provider that wraps the data keys
+//   - keys: A map of key ID to raw key bytes of previously used local keys (may be empty)
+//
+// Returns:
+//   - A pointer to a new Keyring instance
+//   - An error if a local key is invalid
+func NewEnvelopeKeyring(provider KeyProvider, keys map[string][]byte) (*Keyring, error) {
+	if provider == nil {
+		return nil, errors.New("envelope keyring requires a key provider")
+	}
+	aeads, err := newAEADs(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{
+		aeads: aeads,
+		envelope: &envelopeKeys{
+			provider:  provider,
+			unwrapped: make(map[string]cipher.AEAD),
+		},
+	}, nil
+}
+
+// envelopeKeys encrypts values with data keys wrapped by a key provider.
+type envelopeKeys struct {
+	provider KeyProvider
+
+	mutex     sync.Mutex
+	current   *dataKey               // Data key for new values; nil until the first value is encrypted
+	unwrapped map[string]cipher.AEAD // Ciphers of unwrapped data keys, by KMS key ID and wrapped key
+}
+
+// dataKey is the data key that encrypts new values.
+type dataKey struct {
+	aead    cipher.AEAD // Cipher of the data key
+	wrapped string      // Wrapped data key, base64 encoded
+	uses    int         // Number of values encrypted so far
+}
+
+// seal encrypts plaintext with the current data key and returns the envelope.
+func (e *envelopeKeys) seal(ctx context.Context, plaintext string, additionalData []byte) (string, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
+	return envelopePrefix + e.provider.KeyID() + ":" + key.wrapped + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts an envelope (without its prefix) and returns the plaintext along with
+// the ID of the KMS key that wrapped its data key.
+func (e *envelopeKeys) open(ctx context.Context, envelope string, additionalData []byte) (string, string, error) {
+	id, wrapped, encoded := parseEnvelope(envelope)
+	if id == "" {
+		return "", "", ErrMalformedEnvelope
+	}
+	gcm, err := e.unwrap(ctx, id, wrapped)
+	if err != nil {
+		return "", id, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", id, ErrMalformedEnvelope
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return "", id, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	return string(plaintext), id, nil
+}
+
+// dataKey returns the data key for a new value, generating and wrapping a new one on
+// first use and after dataKeyMaxUses values. The lock is held while the provider wraps
+// the key, so concurrent writes don't each generate one.
+func (e *envelopeKeys) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.current == nil || e.current.uses >= dataKeyMaxUses {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		gcm, err := newGCM(raw)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := e.provider.WrapKey(ctx, raw)
+		clear(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap data key with %s: %w", e.provider.KeyID(), err)
+		}
+
+		e.current = &dataKey{aead: gcm, wrapped: base64.StdEncoding.EncodeToString(wrapped)}
+		e.remember(e.provider.KeyID(), e.current.wrapped, gcm)
+	}
+
+	e.current.uses++
+	return e.current, nil
+}
+
+// unwrap returns the cipher of a wrapped data key, asking the provider to unwrap it
+// unless it was seen before.
+func (e *envelopeKeys) unwrap(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	e.mutex.Lock()
+	gcm, ok := e.unwrapped[keyID+":"+wrapped]
+	e.mutex.Unlock()
+	if ok {
+		return gcm, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrMalformedEnvelope
+	}
+	raw, err := e.provider.UnwrapKey(ctx, keyID, decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with %s: %w", keyID, err)
+	}
+	gcm, err = newGCM(raw)
+	clear(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+
+	e.mutex.Lock()
+	e.remember(keyID, wrapped, gcm)
+	e.mutex.Unlock()
+	return gcm, nil
+}
+
+// remember caches the cipher of an unwrapped data key. The cache is emptied when it is
+// full; only the keys in use are unwrapped again. The caller must hold the lock.
+func (e *envelopeKeys) remember(keyID, wrapped string, gcm cipher.AEAD) {
+	if len(e.unwrapped) >= maxUnwrappedKeys {
+		clear(e.unwrapped)
+	}
+	e.unwrapped[keyID+":"+wrapped] = gcm
+}
+
+// parseEnvelope splits an envelope (without its prefix) into the KMS key ID, the wrapped
+// data key, and the sealed value. The key ID is empty if the envelope is malformed.
+func parseEnvelope(envelope string) (string, string, string) {
+	i := strings.LastIndex(envelope, ":")
+	if i < 0 {
+		return "", "", ""
+	}
+	j := strings.LastIndex(envelope[:i], ":")
+	if j < 0 {
+		return "", "", ""
+	}
+	return envelope[:j], envelope[j+1 : i], envelope[i+1:]
+}
+
+// newGCM creates the AES-GCM cipher of a raw AES key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
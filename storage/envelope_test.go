@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang-simple-notes/model"
+)
+
+// fakeKeyProvider wraps data keys with local AES keys, and counts the calls like a KMS would bill them
+type fakeKeyProvider struct {
+	keyID string
+	keks  map[string]cipher.AEAD // Key encryption keys by ID
+
+	mutex   sync.Mutex
+	wraps   int
+	unwraps int
+	fail    bool // Whether the KMS is unavailable
+}
+
+// newFakeKeyProvider creates a provider that wraps with "kms:k1" and can unwrap "kms:k1" and "kms:k2"
+func newFakeKeyProvider(t *testing.T, keyID string) *fakeKeyProvider {
+	t.Helper()
+	keks := make(map[string]cipher.AEAD)
+	for id, b := range map[string]byte{"kms:k1": 11, "kms:k2": 12} {
+		gcm, err := newGCM(testKey(b))
+		if err != nil {
+			t.Fatalf("Failed to create key encryption key: %v", err)
+		}
+		keks[id] = gcm
+	}
+	return &fakeKeyProvider{keyID: keyID, keks: keks}
+}
+
+func (p *fakeKeyProvider) KeyID() string {
+	return p.keyID
+}
+
+func (p *fakeKeyProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.fail {
+		return nil, errors.New("KMS unavailable")
+	}
+	p.wraps++
+	gcm := p.keks[p.keyID]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func (p *fakeKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.fail {
+		return nil, errors.New("KMS unavailable")
+	}
+	p.unwraps++
+	gcm, ok := p.keks[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", keyID)
+	}
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+// TestEnvelopeEncryption verifies that notes are encrypted with wrapped data keys, that the
+// KMS is called once per data key, and that notes on local or old KMS keys are rotated
+func TestEnvelopeEncryption(t *testing.T) {
+	ctx := context.Background()
+	provider := newFakeKeyProvider(t, "kms:k1")
+	keyring, err := NewEnvelopeKeyring(provider, map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	inner := NewInMemoryStorage()
+
+	// A note written before the KMS was configured, with a local key
+	legacy := model.NewNote("Legacy", "Written with a local key")
+	if err := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false).Create(ctx, legacy); err != nil {
+		t.Fatalf("Failed to create legacy note: %v", err)
+	}
+
+	storage := NewEncryptedStorage(inner, keyring, false)
+	if keyring.ActiveKeyID() != "kms:k1" {
+		t.Errorf("Expected the KMS key to be active, got %s", keyring.ActiveKeyID())
+	}
+
+	for i := range 3 {
+		if err := storage.Create(ctx, model.NewNote(fmt.Sprintf("Title %d", i), "Secret")); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	if provider.wraps != 1 || provider.unwraps != 0 {
+		t.Errorf("Expected one data key to be wrapped and none unwrapped, got %d and %d", provider.wraps, provider.unwraps)
+	}
+
+	stored, err := inner.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stored notes: %v", err)
+	}
+	for _, n := range stored {
+		if n.ID != legacy.ID && (!strings.HasPrefix(n.Content, envelopePrefix+"kms:k1:") || strings.Contains(n.Content, "Secret")) {
+			t.Errorf("Expected an envelope on kms:k1, got %q", n.Content)
+		}
+	}
+
+	// Another instance (e.g., after a restart) unwraps the data key once
+	restarted, err := NewEnvelopeKeyring(provider, map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	notes, err := NewEncryptedStorage(inner, restarted, false).GetAll(ctx)
+	if err != nil || len(notes) != 4 {
+		t.Fatalf("GetAll returned %d notes, %v", len(notes), err)
+	}
+	if provider.unwraps != 1 {
+		t.Errorf("Expected the data key to be unwrapped once, got %d", provider.unwraps)
+	}
+
+	// Switching to a new KMS key rotates the notes on the local key and on the old KMS key
+	provider.keyID = "kms:k2"
+	result, err := NewEncryptedStorage(inner, restarted, false).Rotate(ctx)
+	if err != nil || result.Rotated != 4 || result.Failed != 0 {
+		t.Errorf("Rotate returned %+v, %v", result, err)
+	}
+	if usage, err := storage.KeyUsage(ctx); err != nil || usage["kms:k2"].Notes != 4 {
+		t.Errorf("Expected all notes on kms:k2, got %v, %v", usage, err)
+	}
+
+	// Without a provider, envelope-encrypted notes cannot be read
+	if _, err := NewEncryptedStorage(inner, newTestKeyring(t, "k1"), false).Get(ctx, legacy.ID); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Expected ErrUnknownKeyID without a provider, got %v", err)
+	}
+
+	// Cached data keys keep working while the KMS is unavailable, but new data keys can't be created
+	provider.fail = true
+	if _, err := NewEncryptedStorage(inner, restarted, false).Get(ctx, legacy.ID); err != nil {
+		t.Errorf("Expected the cached data key to be used, got %v", err)
+	}
+	fresh, err := NewEnvelopeKeyring(provider, nil)
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	if err := NewEncryptedStorage(inner, fresh, false).Create(ctx, model.NewNote("Title", "")); err == nil {
+		t.Error("Expected Create to fail without the KMS")
+	}
+}
@@ -0,0 +1,10 @@
+package storage
+
+// Test helpers used by the conformance tests (see conformance_test.go), which are in the
+// storage_test package since storagetest imports this package.
+var (
+	SharedMongoURI    = getSharedMongoURI
+	SharedCouchURL    = getSharedCouchURL
+	NewTestKeyring    = newTestKeyring
+	SetupSpanRecorder = setupSpanRecorder
+)
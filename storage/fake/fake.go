@@ -0,0 +1,238 @@
+// Package fake provides a fake of storage.NoteStorage for tests: it stores notes in
+// memory like storage.InMemoryStorage, and can be told to fail or slow down chosen
+// operations, and records the calls it receives. The tests of the rest, grpc, and main
+// packages use it, and so can projects building on these packages:
+//
+//	backend := fake.New(&model.Note{ID: "1", Title: "Existing"})
+//	backend.Fail(errors.New("connection refused"), fake.Update, fake.Delete)
+//	backend.Delay(time.Second, fake.Get)
+//	...
+//	if calls := backend.Calls(); len(calls) != 1 || calls[0] != (fake.Call{Method: fake.Update, ID: "1"}) {
+//		...
+//	}
+package fake
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// Method is an operation of storage.NoteStorage, which faults are injected into.
+type Method string
+
+// The methods of storage.NoteStorage.
+const (
+	Create Method = "Create"
+	Get    Method = "Get"
+	GetAll Method = "GetAll"
+	Update Method = "Update"
+	Delete Method = "Delete"
+	Ping   Method = "Ping"
+	Close  Method = "Close"
+)
+
+// methods are all the methods, which Fail and Delay apply to when given none.
+var methods = []Method{Create, Get, GetAll, Update, Delete, Ping, Close}
+
+// Call is a call received by Storage.
+type Call struct {
+	Method Method
+	ID     string // ID of the note, for the methods taking one
+}
+
+// Storage is a fake storage.NoteStorage. Its notes are stored in a storage.InMemoryStorage,
+// so it behaves like it (e.g., it stores copies of the notes, and rejects creating a note
+// with an existing ID), except for the faults it is told to inject: an operation that is
+// delayed waits before running (and fails with the error of the context if it is
+// canceled first), and an operation that fails returns its error without running. Only
+// the methods of storage.NoteStorage are implemented, so the helpers of the storage
+// package use their fallbacks, which go through the faults as well. It is safe for
+// concurrent use.
+type Storage struct {
+	notes *storage.InMemoryStorage
+
+	mutex  sync.Mutex
+	errs   map[Method]error         // Errors returned by the methods, if any
+	delays map[Method]time.Duration // Latency added to the methods, if any
+	calls  []Call                   // Calls received, in order
+}
+
+// New creates a fake storage holding copies of the given notes.
+func New(notes ...*model.Note) *Storage {
+	s := &Storage{
+		notes:  storage.NewInMemoryStorage(),
+		errs:   make(map[Method]error),
+		delays: make(map[Method]time.Duration),
+	}
+	s.Add(notes...)
+	return s
+}
+
+// Add stores copies of notes, replacing the notes with the same IDs, without going
+// through the faults or being recorded (e.g., to set up the notes of a test).
+func (s *Storage) Add(notes ...*model.Note) {
+	for _, note := range notes {
+		// In-memory storage without limits doesn't fail
+		_, _ = storage.Upsert(context.Background(), s.notes, note)
+	}
+}
+
+// Note returns a copy of the stored note with the given ID, or nil if there is none,
+// without going through the faults or being recorded.
+func (s *Storage) Note(id string) *model.Note {
+	note, err := s.notes.Get(context.Background(), id)
+	if err != nil {
+		return nil
+	}
+	return note
+}
+
+// Notes returns copies of the stored notes, sorted by ID, without going through the
+// faults or being recorded.
+func (s *Storage) Notes() []*model.Note {
+	notes, _ := s.notes.GetAll(context.Background())
+	slices.SortFunc(notes, func(a, b *model.Note) int { return strings.Compare(a.ID, b.ID) })
+	return notes
+}
+
+// Fail makes the given methods (all of them if none is given) return err, until Fail is
+// called again for them; a nil error makes them succeed again.
+func (s *Storage) Fail(err error, methods ...Method) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, method := range orAll(methods) {
+		if err == nil {
+			delete(s.errs, method)
+		} else {
+			s.errs[method] = err
+		}
+	}
+}
+
+// Delay makes the given methods (all of them if none is given) wait for d before running,
+// until Delay is called again for them; a zero delay removes the latency.
+func (s *Storage) Delay(d time.Duration, methods ...Method) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, method := range orAll(methods) {
+		if d <= 0 {
+			delete(s.delays, method)
+		} else {
+			s.delays[method] = d
+		}
+	}
+}
+
+// Calls returns the calls received so far, in order, including those that failed.
+func (s *Storage) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.calls)
+}
+
+// Count returns the number of calls received so far to a method.
+func (s *Storage) Count(method Method) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	count := 0
+	for _, call := range s.calls {
+		if call.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset forgets the calls received so far, and removes every fault.
+func (s *Storage) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls = nil
+	clear(s.errs)
+	clear(s.delays)
+}
+
+// Create adds a copy of a note, unless a note with the same ID exists.
+func (s *Storage) Create(ctx context.Context, note *model.Note) error {
+	if err := s.enter(ctx, Create, note.ID); err != nil {
+		return err
+	}
+	return s.notes.Create(ctx, note)
+}
+
+// Get returns a copy of the note with the given ID, or storage.ErrNoteNotFound.
+func (s *Storage) Get(ctx context.Context, id string) (*model.Note, error) {
+	if err := s.enter(ctx, Get, id); err != nil {
+		return nil, err
+	}
+	return s.notes.Get(ctx, id)
+}
+
+// GetAll returns copies of all notes.
+func (s *Storage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	if err := s.enter(ctx, GetAll, ""); err != nil {
+		return nil, err
+	}
+	return s.notes.GetAll(ctx)
+}
+
+// Update replaces a note with a copy of the given one, or returns storage.ErrNoteNotFound.
+func (s *Storage) Update(ctx context.Context, note *model.Note) error {
+	if err := s.enter(ctx, Update, note.ID); err != nil {
+		return err
+	}
+	return s.notes.Update(ctx, note)
+}
+
+// Delete removes the note with the given ID, or returns storage.ErrNoteNotFound.
+func (s *Storage) Delete(ctx context.Context, id string) error {
+	if err := s.enter(ctx, Delete, id); err != nil {
+		return err
+	}
+	return s.notes.Delete(ctx, id)
+}
+
+// Ping succeeds, unless it is told to fail.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.enter(ctx, Ping, "")
+}
+
+// Close succeeds, unless it is told to fail. The notes are kept, so tests can check
+// them after closing the storage.
+func (s *Storage) Close(ctx context.Context) error {
+	return s.enter(ctx, Close, "")
+}
+
+// enter records a call, and injects the faults of its method: it waits for the delay of
+// the method, and returns its error, if any.
+func (s *Storage) enter(ctx context.Context, method Method, id string) error {
+	s.mutex.Lock()
+	s.calls = append(s.calls, Call{Method: method, ID: id})
+	delay, err := s.delays[method], s.errs[method]
+	s.mutex.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// orAll returns the given methods, or all of them if none is given.
+func orAll(given []Method) []Method {
+	if len(given) == 0 {
+		return methods
+	}
+	return given
+}
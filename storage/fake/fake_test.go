@@ -0,0 +1,80 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+	"golang-simple-notes/storage/storagetest"
+)
+
+// TestStorage runs the conformance tests against the fake without faults
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+		return New()
+	})
+}
+
+// TestStorageFaults tests injecting errors and latency into chosen methods, and recording calls
+func TestStorageFaults(t *testing.T) {
+	ctx := context.Background()
+	s := New(&model.Note{ID: "1", Title: "Existing"})
+	errDown := errors.New("down")
+
+	s.Fail(errDown, Update, Delete)
+	if err := s.Update(ctx, &model.Note{ID: "1", Title: "Updated"}); !errors.Is(err, errDown) {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	if err := s.Delete(ctx, "1"); !errors.Is(err, errDown) {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	if note, err := s.Get(ctx, "1"); err != nil || note.Title != "Existing" {
+		t.Errorf("Expected the other methods to work and the failed ones not to run, got %+v: %v", note, err)
+	}
+
+	s.Fail(nil, Update)
+	if err := s.Update(ctx, &model.Note{ID: "1", Title: "Updated"}); err != nil {
+		t.Errorf("Expected Update to succeed again, got %v", err)
+	}
+	if note := s.Note("1"); note == nil || note.Title != "Updated" {
+		t.Errorf("Expected the updated note, got %+v", note)
+	}
+
+	want := []Call{{Update, "1"}, {Delete, "1"}, {Get, "1"}, {Update, "1"}}
+	if calls := s.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+	if count := s.Count(Update); count != 2 {
+		t.Errorf("Expected 2 calls to Update, got %d", count)
+	}
+
+	// Without methods, every method fails
+	s.Fail(errDown)
+	if err := s.Ping(ctx); !errors.Is(err, errDown) {
+		t.Errorf("Expected Ping to fail, got %v", err)
+	}
+	if _, err := s.GetAll(ctx); !errors.Is(err, errDown) {
+		t.Errorf("Expected GetAll to fail, got %v", err)
+	}
+
+	// A delayed operation stops with its context
+	s.Reset()
+	if len(s.Calls()) != 0 {
+		t.Errorf("Expected no calls after Reset, got %v", s.Calls())
+	}
+	s.Delay(time.Hour, Get)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Get(timeout, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delayed Get to stop with its context, got %v", err)
+	}
+	s.Delay(10*time.Millisecond, Get)
+	start := time.Now()
+	if _, err := s.Get(ctx, "1"); err != nil || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("Expected Get to succeed after the delay, got %v after %v", err, time.Since(start))
+	}
+}
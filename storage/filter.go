@@ -0,0 +1,522 @@
+// This file contains filter expressions of list queries, such as
+// `owner:alice AND (title:report OR created_at>=2024-01-01)`: their parser, their evaluation
+// on notes in memory, and their translation to CouchDB (Mango) selectors and MongoDB filters.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/textnorm"
+)
+
+// ErrInvalidFilter is returned (wrapped) by ParseFilter when an expression is invalid.
+// The error message describes what is wrong and where, so it can be shown to clients.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Limits of filter expressions, which keep the queries sent to the backends small.
+const (
+	maxFilterLength     = 1000 // Bytes of an expression
+	maxFilterConditions = 20   // Conditions of an expression
+	maxFilterDepth      = 10   // Nesting of parentheses and NOTs
+)
+
+// Operators of filter conditions.
+const (
+	filterContains = ":"  // Text fields: contains, ignoring case; exact fields: equals
+	filterEqual    = "="  // Equals, exactly
+	filterNotEqual = "!=" // Doesn't equal
+	filterLess     = "<"  // Timestamps: before
+	filterLessEq   = "<=" // Timestamps: before or at
+	filterGreater  = ">"  // Timestamps: after
+	filterGreatEq  = ">=" // Timestamps: at or after
+)
+
+// filterFields are the fields of notes that conditions can test, with their operators.
+var filterFields = map[string][]string{
+	"title":      {filterContains, filterEqual, filterNotEqual},
+	"content":    {filterContains, filterEqual, filterNotEqual},
+	"summary":    {filterContains, filterEqual, filterNotEqual},
+	"owner":      {filterContains, filterEqual, filterNotEqual},
+	"color":      {filterContains, filterEqual, filterNotEqual},
+	"icon":       {filterContains, filterEqual, filterNotEqual},
+	"tag":        {filterContains, filterEqual, filterNotEqual},
+	"created_at": {filterLess, filterLessEq, filterGreater, filterGreatEq},
+	"updated_at": {filterLess, filterLessEq, filterGreater, filterGreatEq},
+}
+
+// exactFields are the text fields whose values are identifiers rather than text, so ":"
+// compares them exactly instead of searching them.
+var exactFields = map[string]bool{"owner": true, "color": true, "icon": true, "tag": true}
+
+// Kinds of filter nodes.
+const (
+	filterCondition = "" // A condition on a field
+	filterAnd       = "AND"
+	filterOr        = "OR"
+	filterNot       = "NOT"
+)
+
+// Filter is a parsed filter expression (see ParseFilter). A nil *Filter matches every note.
+type Filter struct {
+	kind     string    // filterCondition, filterAnd, filterOr, or filterNot
+	operands []*Filter // Operands of AND and OR, or the single operand of NOT
+
+	// Condition
+	field string    // Field of the note
+	op    string    // Operator (filterContains, ...)
+	value string    // Value, as written (unquoted)
+	lower string    // Value without case and accents (see textnorm.Unaccent), for filterContains on text fields
+	time  time.Time // Value of conditions on timestamps
+}
+
+// ParseFilter parses a filter expression. An expression is made of conditions on fields
+// of notes, combined with AND, OR, NOT, and parentheses; conditions separated by spaces
+// only are combined with AND, and NOT binds tighter than AND, which binds tighter than OR.
+// A condition is a field, an operator, and a value, quoted with double quotes if it has
+// spaces or parentheses:
+//   - title, content, and summary: ":" (contains, ignoring case), "=", or "!=" (e.g., title:"weekly report")
+//   - owner, color, and icon: ":" or "=" (equals), or "!=" (e.g., owner:alice, color:#1e90ff,
+//     which ignores case, or icon:star)
+//   - tag: ":" or "=" (has the tag), or "!=" (doesn't have it), ignoring case (e.g., tag:work)
+//   - created_at and updated_at: "<", "<=", ">", or ">=", with an RFC 3339 time or a date,
+//     which is midnight UTC (e.g., created_at>=2024-01-01)
+//
+// Keywords are case-insensitive, but field names are not.
+//
+// Returns:
+//   - The filter, or nil if the expression is empty
+//   - An error wrapping ErrInvalidFilter if the expression is invalid or too long
+func ParseFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	if len(expression) > maxFilterLength {
+		return nil, fmt.Errorf("%w: the expression must not be longer than %d bytes", ErrInvalidFilter, maxFilterLength)
+	}
+	p := &filterParser{input: expression}
+	filter, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:p.pos+1])
+	}
+	return filter, nil
+}
+
+// UpdatedSince returns a filter matching the notes updated at or after a time, like the
+// expression updated_at>=t.
+func UpdatedSince(t time.Time) *Filter {
+	t = t.UTC()
+	return &Filter{field: "updated_at", op: filterGreatEq, value: t.Format(time.RFC3339Nano), time: t}
+}
+
+// And returns a filter matching the notes that match both filters. A nil filter matches
+// every note, so the other one is returned as is.
+func (f *Filter) And(other *Filter) *Filter {
+	switch {
+	case f == nil:
+		return other
+	case other == nil:
+		return f
+	}
+	return &Filter{kind: filterAnd, operands: []*Filter{f, other}}
+}
+
+// String returns the expression of the filter in a canonical form: keywords in upper case,
+// values quoted when needed, and every AND and OR in parentheses.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	switch f.kind {
+	case filterCondition:
+		value := f.value
+		if value == "" || strings.ContainsFunc(value, func(r rune) bool {
+			return unicode.IsSpace(r) || strings.ContainsRune(`()"\`, r)
+		}) {
+			value = strconv.Quote(value)
+		}
+		return f.field + f.op + value
+	case filterNot:
+		return "NOT " + f.operands[0].String()
+	default:
+		terms := make([]string, len(f.operands))
+		for i, operand := range f.operands {
+			terms[i] = operand.String()
+		}
+		return "(" + strings.Join(terms, " "+f.kind+" ") + ")"
+	}
+}
+
+// Matches reports whether a note matches the filter. A nil filter matches every note.
+func (f *Filter) Matches(note *model.Note) bool {
+	if f == nil {
+		return true
+	}
+	switch f.kind {
+	case filterAnd:
+		for _, operand := range f.operands {
+			if !operand.Matches(note) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, operand := range f.operands {
+			if operand.Matches(note) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !f.operands[0].Matches(note)
+	}
+
+	switch f.field {
+	case "created_at", "updated_at":
+		t := note.CreatedAt
+		if f.field == "updated_at" {
+			t = note.UpdatedAt
+		}
+		switch f.op {
+		case filterLess:
+			return t.Before(f.time)
+		case filterLessEq:
+			return !t.After(f.time)
+		case filterGreater:
+			return t.After(f.time)
+		default:
+			return !t.Before(f.time)
+		}
+	}
+	if f.field == "tag" {
+		return slices.Contains(note.Tags, f.value) != (f.op == filterNotEqual)
+	}
+	value := textField(note, f.field)
+	switch {
+	case f.op == filterContains && !exactFields[f.field]:
+		return strings.Contains(textnorm.Unaccent(value), f.lower)
+	case f.op == filterNotEqual:
+		return value != f.value
+	default:
+		return value == f.value
+	}
+}
+
+// textField returns the value of a text field of a note.
+func textField(note *model.Note, field string) string {
+	switch field {
+	case "title":
+		return note.Title
+	case "content":
+		return note.Content
+	case "summary":
+		return note.Summary
+	case "color":
+		return note.Color
+	case "icon":
+		return note.Icon
+	default:
+		return note.Owner
+	}
+}
+
+// mango returns the Mango selector of the filter. Timestamps are stored as RFC 3339
+// strings, so they are compared as strings, which orders them correctly as long as they
+// are stored in the same time zone.
+func (f *Filter) mango() map[string]any {
+	switch f.kind {
+	case filterAnd, filterOr:
+		operands := make([]any, len(f.operands))
+		for i, operand := range f.operands {
+			operands[i] = operand.mango()
+		}
+		return map[string]any{"$" + strings.ToLower(f.kind): operands}
+	case filterNot:
+		// $not also matches documents without the field, like Matches
+		return map[string]any{"$not": f.operands[0].mango()}
+	}
+
+	if f.field == "tag" {
+		hasTag := map[string]any{"tags": map[string]any{"$elemMatch": map[string]any{"$eq": f.value}}}
+		if f.op == filterNotEqual {
+			return map[string]any{"$not": hasTag}
+		}
+		return hasTag
+	}
+	switch f.op {
+	case filterContains:
+		if exactFields[f.field] {
+			return map[string]any{f.field: map[string]any{"$eq": f.value}}
+		}
+		return map[string]any{f.field: map[string]any{"$regex": textnorm.Pattern(f.value, false)}}
+	case filterEqual:
+		return map[string]any{f.field: map[string]any{"$eq": f.value}}
+	case filterNotEqual:
+		return map[string]any{"$not": map[string]any{f.field: map[string]any{"$eq": f.value}}}
+	default:
+		// Nine fractional digits, so times within the same second compare correctly
+		bound := f.time.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+		return map[string]any{f.field: map[string]any{mongoOperators[f.op]: bound}}
+	}
+}
+
+// mongoOperators are the MongoDB (and Mango) query operators of the comparison operators.
+var mongoOperators = map[string]string{
+	filterLess:    "$lt",
+	filterLessEq:  "$lte",
+	filterGreater: "$gt",
+	filterGreatEq: "$gte",
+}
+
+// mongo returns the MongoDB filter of the filter.
+func (f *Filter) mongo() bson.M {
+	switch f.kind {
+	case filterAnd, filterOr:
+		operands := make(bson.A, len(f.operands))
+		for i, operand := range f.operands {
+			operands[i] = operand.mongo()
+		}
+		return bson.M{"$" + strings.ToLower(f.kind): operands}
+	case filterNot:
+		// MongoDB has no top-level $not
+		return bson.M{"$nor": bson.A{f.operands[0].mongo()}}
+	}
+
+	if f.field == "tag" {
+		// A condition on an array matches if any of its elements does
+		if f.op == filterNotEqual {
+			return bson.M{"tags": bson.M{"$ne": f.value}}
+		}
+		return bson.M{"tags": f.value}
+	}
+	switch f.op {
+	case filterContains:
+		if exactFields[f.field] {
+			return bson.M{f.field: f.value}
+		}
+		return bson.M{f.field: bson.M{"$regex": textnorm.Pattern(f.value, false)}}
+	case filterEqual:
+		return bson.M{f.field: f.value}
+	case filterNotEqual:
+		return bson.M{f.field: bson.M{"$ne": f.value}}
+	default:
+		return bson.M{f.field: bson.M{mongoOperators[f.op]: f.time}}
+	}
+}
+
+// filterParser is a recursive descent parser of filter expressions.
+type filterParser struct {
+	input      string
+	pos        int // Position of the next byte to read
+	conditions int // Conditions read so far
+}
+
+// errorf returns an error wrapping ErrInvalidFilter at the current position.
+func (p *filterParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: at position %d: %s", ErrInvalidFilter, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// skipSpaces skips the spaces at the current position.
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// keyword reads the given keyword, if it is next and followed by a space or parenthesis.
+func (p *filterParser) keyword(keyword string) bool {
+	p.skipSpaces()
+	end := p.pos + len(keyword)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], keyword) {
+		return false
+	}
+	if end < len(p.input) && !unicode.IsSpace(rune(p.input[end])) && p.input[end] != '(' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// parseOr parses operands separated by OR.
+func (p *filterParser) parseOr(depth int) (*Filter, error) {
+	return p.parseList(filterOr, depth, func() (*Filter, error) { return p.parseAnd(depth) })
+}
+
+// parseAnd parses operands separated by AND, or by spaces only.
+func (p *filterParser) parseAnd(depth int) (*Filter, error) {
+	return p.parseList(filterAnd, depth, func() (*Filter, error) { return p.parseUnary(depth) })
+}
+
+// parseList parses operands separated by the keyword of kind; AND may also be implied
+// between operands.
+func (p *filterParser) parseList(kind string, depth int, operand func() (*Filter, error)) (*Filter, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*Filter{first}
+	for {
+		if !p.keyword(kind) {
+			// Another operand follows without AND, unless the list or the expression ends
+			p.skipSpaces()
+			if kind == filterOr || p.pos == len(p.input) || p.input[p.pos] == ')' || p.peekKeyword(filterOr) {
+				break
+			}
+		}
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &Filter{kind: kind, operands: operands}, nil
+}
+
+// peekKeyword reports whether the keyword is next, without reading it.
+func (p *filterParser) peekKeyword(keyword string) bool {
+	pos := p.pos
+	found := p.keyword(keyword)
+	p.pos = pos
+	return found
+}
+
+// parseUnary parses NOT, an expression in parentheses, or a condition.
+func (p *filterParser) parseUnary(depth int) (*Filter, error) {
+	if depth >= maxFilterDepth {
+		return nil, p.errorf("the expression must not be nested more than %d levels deep", maxFilterDepth)
+	}
+	if p.keyword(filterNot) {
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{kind: filterNot, operands: []*Filter{operand}}, nil
+	}
+	p.skipSpaces()
+	if p.pos == len(p.input) {
+		return nil, p.errorf("expected a condition")
+	}
+	if p.input[p.pos] == '(' {
+		p.pos++
+		filter, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpaces(); p.pos == len(p.input) || p.input[p.pos] != ')' {
+			return nil, p.errorf("expected )")
+		}
+		p.pos++
+		return filter, nil
+	}
+	return p.parseCondition()
+}
+
+// parseCondition parses a field, an operator, and a value.
+func (p *filterParser) parseCondition() (*Filter, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] == '_' || p.input[p.pos] >= 'a' && p.input[p.pos] <= 'z' ||
+		p.input[p.pos] >= 'A' && p.input[p.pos] <= 'Z') {
+		p.pos++
+	}
+	field := p.input[start:p.pos]
+	if field == "" {
+		return nil, p.errorf("expected a field name")
+	}
+	if strings.EqualFold(field, filterAnd) || strings.EqualFold(field, filterOr) {
+		p.pos = start
+		return nil, p.errorf("unexpected %s", strings.ToUpper(field))
+	}
+	ops, ok := filterFields[field]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown field %q", field)
+	}
+
+	op := ""
+	for _, candidate := range []string{filterNotEqual, filterLessEq, filterGreatEq, filterContains, filterEqual, filterLess, filterGreater} {
+		if strings.HasPrefix(p.input[p.pos:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, p.errorf("expected an operator after %s", field)
+	}
+	if !slices.Contains(ops, op) {
+		return nil, p.errorf("%s doesn't support %s (use %s)", field, op, strings.Join(ops, ", "))
+	}
+	p.pos += len(op)
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.conditions++; p.conditions > maxFilterConditions {
+		return nil, p.errorf("the expression must not have more than %d conditions", maxFilterConditions)
+	}
+	if field == "color" || field == "tag" {
+		// Colors and tags are stored in lower case
+		value = strings.ToLower(value)
+	}
+	filter := &Filter{field: field, op: op, value: value, lower: textnorm.Unaccent(value)}
+	if field == "created_at" || field == "updated_at" {
+		if filter.time, err = parseFilterTime(value); err != nil {
+			return nil, p.errorf("%s must be an RFC 3339 time or a date (YYYY-MM-DD)", field)
+		}
+	}
+	return filter, nil
+}
+
+// parseValue parses a value: a string in double quotes, with backslash escapes, or the
+// text up to the next space or parenthesis.
+func (p *filterParser) parseValue() (string, error) {
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		var b strings.Builder
+		for i := p.pos + 1; i < len(p.input); i++ {
+			switch c := p.input[i]; {
+			case c == '\\' && i+1 < len(p.input):
+				i++
+				b.WriteByte(p.input[i])
+			case c == '"':
+				p.pos = i + 1
+				if b.Len() == 0 {
+					return "", p.errorf("the value must not be empty")
+				}
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", p.errorf("unterminated quoted value")
+	}
+	start := p.pos
+	for p.pos < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos])) && p.input[p.pos] != '(' && p.input[p.pos] != ')' {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a value")
+	}
+	return p.input[start:p.pos], nil
+}
+
+// parseFilterTime parses an RFC 3339 time, or a date, which is midnight UTC.
+func parseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
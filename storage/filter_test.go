@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mustParseFilter parses a filter expression, failing the test if it is invalid
+func mustParseFilter(t *testing.T, expression string) *Filter {
+	t.Helper()
+	filter, err := ParseFilter(expression)
+	if err != nil {
+		t.Fatalf("ParseFilter(%q) failed: %v", expression, err)
+	}
+	return filter
+}
+
+// TestParseFilter verifies the precedence of the operators, and the canonical form of filters
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", ""},
+		{"title:apple", "title:apple"},
+		{`title:"apple pie"  content!=x`, `(title:"apple pie" AND content!=x)`},
+		{"title:a or title:b and not content:c", "(title:a OR (title:b AND NOT content:c))"},
+		{"(title:a OR title:b) AND created_at>=2024-01-01", "((title:a OR title:b) AND created_at>=2024-01-01)"},
+		{`NOT(owner=alice) summary:"say \"hi\""`, `(NOT owner=alice AND summary:"say \"hi\"")`},
+		{"updated_at<2024-01-01T10:00:00+02:00", "updated_at<2024-01-01T10:00:00+02:00"},
+		{"color:#1E90FF icon!=star", "(color:#1e90ff AND icon!=star)"},
+		{"tag:Work AND created_at>2024-01-01", "(tag:work AND created_at>2024-01-01)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			if got := mustParseFilter(t, tt.expression).String(); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"tag>work":                     "tag doesn't support >",
+		"priority:high":                `unknown field "priority"`,
+		"color>#000000":                "color doesn't support >",
+		"title>a":                      "title doesn't support >",
+		"created_at:2024-01-01":        "created_at doesn't support :",
+		"created_at>yesterday":         "RFC 3339",
+		"title:":                       "expected a value",
+		`title:""`:                     "must not be empty",
+		`title:"open`:                  "unterminated",
+		"(title:a":                     "expected )",
+		"title:a)":                     `unexpected ")"`,
+		"title:a AND":                  "expected a condition",
+		"OR title:a":                   "unexpected OR",
+		"title~a":                      "expected an operator",
+		strings.Repeat("NOT ", 11):     "nested",
+		strings.Repeat("title:a ", 21): "more than 20 conditions",
+		strings.Repeat("x", 1001):      "longer than 1000 bytes",
+	}
+	for expression, message := range invalid {
+		_, err := ParseFilter(expression)
+		if !errors.Is(err, ErrInvalidFilter) || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseFilter(%.20q): expected an error with %q, got %v", expression, message, err)
+		}
+	}
+}
+
+// TestFilterMatches verifies the evaluation of filters in memory, through ListOptions.Apply
+func TestFilterMatches(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{"title:APPLE", []string{"apple pie", "Apple juice"}},
+		{"title=Cherry", []string{"Cherry"}},
+		{"title!=Cherry content:of", []string{"Banana", "apple pie", "Apple juice"}},
+		{"created_at>=2024-01-01T01:00:00Z AND created_at<2024-01-01T03:00:00Z", []string{"apple pie", "Cherry"}},
+		{"updated_at>2024-01-01T03:00:00Z OR title:juice", []string{"Banana", "Apple juice"}},
+		{"NOT title:apple", []string{"Banana", "Cherry"}},
+		{"owner:alice", []string{}},
+		{"owner!=alice summary:x", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			opts := ListOptions{Filter: mustParseFilter(t, tt.expression)}
+			if got := noteTitles(opts.Apply(queryTestNotes())); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// The color and icon match exactly, the color ignoring case
+	notes := queryTestNotes()
+	notes[0].Color, notes[0].Icon = "#1e90ff", "star"
+	notes[2].Color, notes[2].Icon = "#ff0000", "starred"
+	for expression, want := range map[string][]string{
+		"color:#1E90FF":              {"Banana"},
+		"icon:star":                  {"Banana"},
+		"icon!=star color!=#ff0000":  {"apple pie", "Apple juice"},
+		"color=#ff0000 OR icon:star": {"Banana", "Cherry"},
+	} {
+		opts := ListOptions{Filter: mustParseFilter(t, expression)}
+		if got := noteTitles(opts.Apply(notes)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", expression, want, got)
+		}
+	}
+
+	// A tag matches the notes that have it among their tags, ignoring case
+	notes = queryTestNotes()
+	notes[0].Tags = []string{"fruit", "work"}
+	notes[1].Tags = []string{"fruit"}
+	notes[2].Tags = []string{"workshop"}
+	for expression, want := range map[string][]string{
+		"tag:work":              {"Banana"},
+		"tag=FRUIT":             {"Banana", "apple pie"},
+		"tag!=fruit":            {"Cherry", "Apple juice"},
+		"NOT tag:work tag:work": {},
+	} {
+		opts := ListOptions{Filter: mustParseFilter(t, expression)}
+		if got := noteTitles(opts.Apply(notes)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", expression, want, got)
+		}
+	}
+}
+
+// TestFilterCombinations verifies filters built without an expression
+func TestFilterCombinations(t *testing.T) {
+	since := time.Date(2024, 1, 1, 4, 0, 0, 0, time.FixedZone("CET", 3600))
+	recent := UpdatedSince(since)
+	if got, want := recent.String(), "updated_at>=2024-01-01T03:00:00Z"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if recent.And(nil) != recent || (*Filter)(nil).And(recent) != recent {
+		t.Error("Expected And with a nil filter to return the other filter")
+	}
+
+	filter := recent.And(mustParseFilter(t, "title:apple"))
+	if got, want := filter.String(), "(updated_at>=2024-01-01T03:00:00Z AND title:apple)"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	opts := ListOptions{Filter: filter}
+	if got := noteTitles(opts.Apply(queryTestNotes())); !reflect.DeepEqual(got, []string{"apple pie"}) {
+		t.Errorf("Expected the recently updated apple note, got %v", got)
+	}
+}
+
+// TestFilterTranslations verifies the Mango selectors and MongoDB filters of filters
+func TestFilterTranslations(t *testing.T) {
+	filter := mustParseFilter(t, "title:1.2 OR NOT (owner:alice content!=x) created_at>2024-01-01")
+
+	mango, err := json.Marshal(filter.mango())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"$or":[{"title":{"$regex":"1\\.2"}},{"$and":[{"$not":{"$and":[{"owner":{"$eq":"alice"}},` +
+		`{"$not":{"content":{"$eq":"x"}}}]}},{"created_at":{"$gt":"2024-01-01T00:00:00.000000000Z"}}]}]}`
+	if string(mango) != want {
+		t.Errorf("Expected the selector %s, got %s", want, mango)
+	}
+
+	mongo, err := bson.MarshalExtJSON(filter.mongo(), false, false)
+	if err != nil {
+		t.Fatalf("MarshalExtJSON failed: %v", err)
+	}
+	for _, part := range []string{
+		`{"$or":[{"title":{"$regex":"1\\.2"}},{"$and":[{"$nor":[{"$and":[{"owner":"alice"},{"content":{"$ne":"x"}}]}]}`,
+		`{"created_at":{"$gt":{"$date":"2024-01-01T00:00:00Z"}}}`,
+	} {
+		if !strings.Contains(string(mongo), part) {
+			t.Errorf("Expected the filter to contain %s, got %s", part, mongo)
+		}
+	}
+
+	// The color and icon are compared exactly, like the owner
+	filter = mustParseFilter(t, "color:#ABCDEF icon:star")
+	if mango, _ := json.Marshal(filter.mango()); string(mango) != `{"$and":[{"color":{"$eq":"#abcdef"}},{"icon":{"$eq":"star"}}]}` {
+		t.Errorf("Unexpected selector %s", mango)
+	}
+	if mongo, _ := bson.MarshalExtJSON(filter.mongo(), false, false); string(mongo) != `{"$and":[{"color":"#abcdef"},{"icon":"star"}]}` {
+		t.Errorf("Unexpected filter %s", mongo)
+	}
+
+	// Tags are elements of the tags array
+	filter = mustParseFilter(t, "tag:Work tag!=home")
+	if mango, _ := json.Marshal(filter.mango()); string(mango) != `{"$and":[{"tags":{"$elemMatch":{"$eq":"work"}}},`+
+		`{"$not":{"tags":{"$elemMatch":{"$eq":"home"}}}}]}` {
+		t.Errorf("Unexpected selector %s", mango)
+	}
+	if mongo, _ := bson.MarshalExtJSON(filter.mongo(), false, false); string(mongo) != `{"$and":[{"tags":"work"},{"tags":{"$ne":"home"}}]}` {
+		t.Errorf("Unexpected filter %s", mongo)
+	}
+}
@@ -0,0 +1,202 @@
+// This file contains an instrumentation decorator for the NoteStorage interface.
+// It times every storage operation, records the durations as Prometheus histograms
+// by backend and method, and logs operations slower than a threshold, so that slow
+// queries can be spotted without enabling verbose storage logging.
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// InstrumentedStorage implements NoteStorage by measuring the operations performed
+// on another NoteStorage implementation.
+// ErrNoteNotFound is treated as a regular outcome rather than a failure.
+type InstrumentedStorage struct {
+	inner         NoteStorage   // Backend whose operations are measured
+	backend       string        // Backend name used as metric label and in log lines (e.g., "mongodb")
+	slowThreshold time.Duration // Operations taking at least this long are logged (zero disables the log)
+}
+
+// NewInstrumentedStorage creates a new instrumentation decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to wrap
+//   - backend: A short backend name used as the "backend" metric label and in log lines
+//   - slowThreshold: Operations taking at least this long are logged; zero disables the log
+//
+// Returns:
+//   - A pointer to a new InstrumentedStorage instance
+func NewInstrumentedStorage(inner NoteStorage, backend string, slowThreshold time.Duration) *InstrumentedStorage {
+	return &InstrumentedStorage{
+		inner:         inner,
+		backend:       backend,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// Create adds a new note to the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Create(ctx context.Context, note *model.Note) error {
+	start := time.Now()
+	err := s.inner.Create(ctx, note)
+	s.observe(ctx, "Create", note.ID, start, err)
+	return err
+}
+
+// Get retrieves a note from the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	start := time.Now()
+	note, err := s.inner.Get(ctx, id)
+	s.observe(ctx, "Get", id, start, err)
+	return note, err
+}
+
+// GetAll retrieves all notes from the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := s.inner.GetAll(ctx)
+	s.observe(ctx, "GetAll", "", start, err)
+	return notes, err
+}
+
+// List runs a list query on the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := List(ctx, s.inner, opts)
+	s.observe(ctx, "List", "", start, err)
+	return notes, err
+}
+
+// Exists checks whether a note exists in the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Exists(ctx context.Context, id string) (bool, error) {
+	start := time.Now()
+	exists, err := Exists(ctx, s.inner, id)
+	s.observe(ctx, "Exists", id, start, err)
+	return exists, err
+}
+
+// GetMany retrieves notes by ID from the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := GetMany(ctx, s.inner, ids)
+	s.observe(ctx, "GetMany", "", start, err)
+	return notes, err
+}
+
+// Count counts the matching notes in the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	start := time.Now()
+	count, err := Count(ctx, s.inner, opts)
+	s.observe(ctx, "Count", "", start, err)
+	return count, err
+}
+
+// Stats returns statistics about the notes in the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Stats(ctx context.Context) (NoteStats, error) {
+	start := time.Now()
+	stats, err := Stats(ctx, s.inner)
+	s.observe(ctx, "Stats", "", start, err)
+	return stats, err
+}
+
+// Maintain runs a maintenance task on the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	start := time.Now()
+	err := Maintain(ctx, s.inner, task)
+	s.observe(ctx, "Maintain", "", start, err)
+	return err
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage and measures the
+// transaction, including the operations in fn, which are measured on their own as well.
+func (s *InstrumentedStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := RunInTransaction(ctx, s.inner, fn)
+	s.observe(ctx, "RunInTransaction", "", start, err)
+	return err
+}
+
+// Stream reads all notes from the wrapped storage one at a time and measures the operation.
+// The time spent in fn depends on the caller (e.g., a slow client), so it is not counted.
+func (s *InstrumentedStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	start := time.Now()
+	var inFn time.Duration
+	err := Stream(ctx, s.inner, func(note *model.Note) error {
+		fnStart := time.Now()
+		defer func() { inFn += time.Since(fnStart) }()
+		return fn(note)
+	})
+	s.observe(ctx, "Stream", "", start.Add(inFn), err)
+	return err
+}
+
+// Update updates a note in the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Update(ctx context.Context, note *model.Note) error {
+	start := time.Now()
+	err := s.inner.Update(ctx, note)
+	s.observe(ctx, "Update", note.ID, start, err)
+	return err
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged and measures the operation.
+func (s *InstrumentedStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	start := time.Now()
+	err := UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	s.observe(ctx, "UpdateIf", note.ID, start, err)
+	return err
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, and measures the operation.
+func (s *InstrumentedStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	start := time.Now()
+	created, err := Upsert(ctx, s.inner, note)
+	s.observe(ctx, "Upsert", note.ID, start, err)
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.inner.Delete(ctx, id)
+	s.observe(ctx, "Delete", id, start, err)
+	return err
+}
+
+// Ping checks the wrapped storage and measures the operation.
+func (s *InstrumentedStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.inner.Ping(ctx)
+	s.observe(ctx, "Ping", "", start, err)
+	return err
+}
+
+// Close closes the wrapped storage.
+func (s *InstrumentedStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// observe records the duration and outcome of an operation, and logs it if it was slow.
+func (s *InstrumentedStorage) observe(ctx context.Context, op, id string, start time.Time, err error) {
+	elapsed := time.Since(start)
+
+	result := "success"
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNoteNotFound):
+		result = "not_found"
+	default:
+		result = "error"
+	}
+	metrics.StorageOperationDuration.WithLabelValues(s.backend, op, result).Observe(elapsed.Seconds())
+
+	if s.slowThreshold > 0 && elapsed >= s.slowThreshold {
+		log.Printf("%sstorage %s: slow %s %s took %s (threshold %s, result %s)",
+			requestid.LogPrefix(ctx), s.backend, op, id, elapsed, s.slowThreshold, result)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// slowStorage is a NoteStorage whose Get takes at least delay
+type slowStorage struct {
+	NoteStorage
+	delay time.Duration
+}
+
+func (s *slowStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	time.Sleep(s.delay)
+	return s.NoteStorage.Get(ctx, id)
+}
+
+// observations returns the number of durations recorded for a backend, method, and result
+func observations(t *testing.T, backend, method, result string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	observer := metrics.StorageOperationDuration.WithLabelValues(backend, method, result)
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+// TestInstrumentedStorageMetrics verifies that durations are recorded by method and result
+func TestInstrumentedStorageMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := NewInstrumentedStorage(NewInMemoryStorage(), "test-metrics", 0)
+
+	if err := s.Create(ctx, &model.Note{ID: "note-1", Title: "Title"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := s.Get(ctx, "note-1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected ErrNoteNotFound, got %v", err)
+	}
+
+	if got := observations(t, "test-metrics", "Create", "success"); got != 1 {
+		t.Errorf("Expected 1 successful Create, got %d", got)
+	}
+	if got := observations(t, "test-metrics", "Get", "success"); got != 1 {
+		t.Errorf("Expected 1 successful Get, got %d", got)
+	}
+	if got := observations(t, "test-metrics", "Get", "not_found"); got != 1 {
+		t.Errorf("Expected 1 Get of a missing note, got %d", got)
+	}
+
+	failing := NewInstrumentedStorage(&failingStorage{}, "test-metrics-errors", 0)
+	_ = failing.Delete(ctx, "note-1")
+	if got := observations(t, "test-metrics-errors", "Delete", "error"); got != 1 {
+		t.Errorf("Expected 1 failed Delete, got %d", got)
+	}
+}
+
+// TestInstrumentedStorageSlowLog verifies that only operations over the threshold are logged
+func TestInstrumentedStorageSlowLog(t *testing.T) {
+	buf := captureLog(t)
+	ctx := requestid.NewContext(context.Background(), "req-slow")
+	inner := NewInMemoryStorage()
+	if err := inner.Create(ctx, &model.Note{ID: "note-1", Title: "Title"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	s := NewInstrumentedStorage(&slowStorage{NoteStorage: inner, delay: 20 * time.Millisecond}, "memory", 10*time.Millisecond)
+
+	if _, err := s.GetAll(ctx); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected fast operations not to be logged, got %q", buf.String())
+	}
+
+	if _, err := s.Get(ctx, "note-1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	line := buf.String()
+	if !strings.Contains(line, "[req-slow] storage memory: slow Get note-1 took") || !strings.Contains(line, "threshold 10ms") {
+		t.Errorf("Unexpected slow operation log: %q", line)
+	}
+
+	// A zero threshold disables the log
+	buf.Reset()
+	s = NewInstrumentedStorage(&slowStorage{NoteStorage: inner, delay: 20 * time.Millisecond}, "memory", 0)
+	if _, err := s.Get(ctx, "note-1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log with a zero threshold, got %q", buf.String())
+	}
+}
@@ -0,0 +1,192 @@
+// This file contains a logging decorator for the NoteStorage interface.
+// It tags every storage operation with the request ID carried by the context,
+// so storage activity can be correlated with the REST or gRPC request that caused it.
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// LoggingStorage implements NoteStorage by logging operations performed
+// on another NoteStorage implementation.
+// Failed operations are always logged; successful ones only in verbose mode.
+// ErrNoteNotFound is treated as a regular outcome rather than a failure.
+type LoggingStorage struct {
+	inner   NoteStorage // Backend whose operations are logged
+	backend string      // Backend name included in log lines (e.g., "mongodb")
+	verbose bool        // Whether successful operations are logged too
+}
+
+// NewLoggingStorage creates a new logging decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to wrap
+//   - backend: A short backend name used in log lines
+//   - verbose: If true, every operation is logged, not only failures
+//
+// Returns:
+//   - A pointer to a new LoggingStorage instance
+func NewLoggingStorage(inner NoteStorage, backend string, verbose bool) *LoggingStorage {
+	return &LoggingStorage{
+		inner:   inner,
+		backend: backend,
+		verbose: verbose,
+	}
+}
+
+// Create adds a new note to the wrapped storage and logs the operation.
+func (s *LoggingStorage) Create(ctx context.Context, note *model.Note) error {
+	start := time.Now()
+	err := s.inner.Create(ctx, note)
+	s.log(ctx, "Create", note.ID, start, err)
+	return err
+}
+
+// Get retrieves a note from the wrapped storage and logs the operation.
+func (s *LoggingStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	start := time.Now()
+	note, err := s.inner.Get(ctx, id)
+	s.log(ctx, "Get", id, start, err)
+	return note, err
+}
+
+// GetAll retrieves all notes from the wrapped storage and logs the operation.
+func (s *LoggingStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := s.inner.GetAll(ctx)
+	s.log(ctx, "GetAll", "", start, err)
+	return notes, err
+}
+
+// List runs a list query on the wrapped storage and logs the operation.
+func (s *LoggingStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := List(ctx, s.inner, opts)
+	s.log(ctx, "List", "", start, err)
+	return notes, err
+}
+
+// Exists checks whether a note exists in the wrapped storage and logs the operation.
+func (s *LoggingStorage) Exists(ctx context.Context, id string) (bool, error) {
+	start := time.Now()
+	exists, err := Exists(ctx, s.inner, id)
+	s.log(ctx, "Exists", id, start, err)
+	return exists, err
+}
+
+// GetMany retrieves notes by ID from the wrapped storage and logs the operation.
+func (s *LoggingStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	start := time.Now()
+	notes, err := GetMany(ctx, s.inner, ids)
+	s.log(ctx, "GetMany", "", start, err)
+	return notes, err
+}
+
+// Count counts the matching notes in the wrapped storage and logs the operation.
+func (s *LoggingStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	start := time.Now()
+	count, err := Count(ctx, s.inner, opts)
+	s.log(ctx, "Count", "", start, err)
+	return count, err
+}
+
+// Stats returns statistics about the notes in the wrapped storage and logs the operation.
+func (s *LoggingStorage) Stats(ctx context.Context) (NoteStats, error) {
+	start := time.Now()
+	stats, err := Stats(ctx, s.inner)
+	s.log(ctx, "Stats", "", start, err)
+	return stats, err
+}
+
+// Maintain runs a maintenance task on the wrapped storage and logs the operation.
+func (s *LoggingStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	start := time.Now()
+	err := Maintain(ctx, s.inner, task)
+	s.log(ctx, "Maintain", string(task), start, err)
+	return err
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage and logs the transaction.
+func (s *LoggingStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := RunInTransaction(ctx, s.inner, fn)
+	s.log(ctx, "RunInTransaction", "", start, err)
+	return err
+}
+
+// Stream reads all notes from the wrapped storage one at a time and logs the operation.
+func (s *LoggingStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	start := time.Now()
+	err := Stream(ctx, s.inner, fn)
+	s.log(ctx, "Stream", "", start, err)
+	return err
+}
+
+// Update updates a note in the wrapped storage and logs the operation.
+func (s *LoggingStorage) Update(ctx context.Context, note *model.Note) error {
+	start := time.Now()
+	err := s.inner.Update(ctx, note)
+	s.log(ctx, "Update", note.ID, start, err)
+	return err
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged and logs the operation.
+func (s *LoggingStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	start := time.Now()
+	err := UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	s.log(ctx, "UpdateIf", note.ID, start, err)
+	return err
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, and logs the operation.
+func (s *LoggingStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	start := time.Now()
+	created, err := Upsert(ctx, s.inner, note)
+	s.log(ctx, "Upsert", note.ID, start, err)
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage and logs the operation.
+func (s *LoggingStorage) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.inner.Delete(ctx, id)
+	s.log(ctx, "Delete", id, start, err)
+	return err
+}
+
+// Ping checks the wrapped storage.
+// Health checks run frequently, so only failures are logged, even in verbose mode.
+func (s *LoggingStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := s.inner.Ping(ctx)
+	if err != nil {
+		s.log(ctx, "Ping", "", start, err)
+	}
+	return err
+}
+
+// Close closes the wrapped storage.
+func (s *LoggingStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// log writes a single line describing a storage operation.
+func (s *LoggingStorage) log(ctx context.Context, op, id string, start time.Time, err error) {
+	failed := err != nil && !errors.Is(err, ErrNoteNotFound)
+	if !failed && !s.verbose {
+		return
+	}
+
+	elapsed := time.Since(start)
+	if failed {
+		log.Printf("%sstorage %s: %s %s failed after %s: %v", requestid.LogPrefix(ctx), s.backend, op, id, elapsed, err)
+		return
+	}
+	log.Printf("%sstorage %s: %s %s took %s", requestid.LogPrefix(ctx), s.backend, op, id, elapsed)
+}
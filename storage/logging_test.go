@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// failingStorage is a NoteStorage whose operations always fail
+type failingStorage struct{}
+
+var errFailingStorage = errors.New("storage unavailable")
+
+func (s *failingStorage) Create(context.Context, *model.Note) error { return errFailingStorage }
+func (s *failingStorage) Get(context.Context, string) (*model.Note, error) {
+	return nil, errFailingStorage
+}
+func (s *failingStorage) GetAll(context.Context) ([]*model.Note, error) {
+	return nil, errFailingStorage
+}
+func (s *failingStorage) Update(context.Context, *model.Note) error { return errFailingStorage }
+func (s *failingStorage) Delete(context.Context, string) error      { return errFailingStorage }
+func (s *failingStorage) Ping(context.Context) error                { return errFailingStorage }
+func (s *failingStorage) Close(context.Context) error               { return nil }
+
+// captureLog redirects the standard logger into a buffer for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+// TestLoggingStorageRequestID verifies that log lines carry the request ID
+func TestLoggingStorageRequestID(t *testing.T) {
+	buf := captureLog(t)
+	ctx := requestid.NewContext(context.Background(), "req-123")
+
+	t.Run("Verbose", func(t *testing.T) {
+		buf.Reset()
+		storage := NewLoggingStorage(NewInMemoryStorage(), "memory", true)
+		if _, err := storage.GetAll(ctx); err != nil {
+			t.Fatalf("Failed to get notes: %v", err)
+		}
+		if !strings.Contains(buf.String(), "[req-123] storage memory: GetAll") {
+			t.Errorf("Expected request ID in log, got: %s", buf.String())
+		}
+	})
+
+	t.Run("QuietSuccess", func(t *testing.T) {
+		buf.Reset()
+		storage := NewLoggingStorage(NewInMemoryStorage(), "memory", false)
+		if _, err := storage.GetAll(ctx); err != nil {
+			t.Fatalf("Failed to get notes: %v", err)
+		}
+		// Not found is a regular outcome, not a failure
+		if _, err := storage.Get(ctx, "missing"); !errors.Is(err, ErrNoteNotFound) {
+			t.Fatalf("Expected ErrNoteNotFound, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Expected no log output, got: %s", buf.String())
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		buf.Reset()
+		storage := NewLoggingStorage(&failingStorage{}, "fake", false)
+		if err := storage.Delete(ctx, "some-id"); err == nil {
+			t.Fatal("Expected error from failing storage")
+		}
+		if !strings.Contains(buf.String(), "[req-123] storage fake: Delete some-id failed") {
+			t.Errorf("Expected failure log with request ID, got: %s", buf.String())
+		}
+	})
+}
+
+// TestLoggingStoragePing verifies that only failed pings are logged, even in verbose mode
+func TestLoggingStoragePing(t *testing.T) {
+	buf := captureLog(t)
+	ctx := context.Background()
+
+	if err := NewLoggingStorage(NewInMemoryStorage(), "memory", true).Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log output for a successful ping, got: %s", buf.String())
+	}
+
+	if err := NewLoggingStorage(&failingStorage{}, "fake", false).Ping(ctx); !errors.Is(err, errFailingStorage) {
+		t.Fatalf("Expected errFailingStorage, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "storage fake: Ping") {
+		t.Errorf("Expected failure log, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,139 @@
+// This file contains an in-process LRU cache with expiring entries, for use with CachedStorage.
+package storage
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// LRUCache implements Cache in memory. It holds at most size entries, evicting the
+// least recently used one when full, and entries expire ttl after they were stored.
+// Notes are copied when stored and when returned, so callers cannot modify cached notes.
+type LRUCache struct {
+	size int           // Maximum number of entries
+	ttl  time.Duration // How long entries are served after they were stored
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element // Entries by key
+	order   *list.List               // Entries from most to least recently used
+
+	now func() time.Time // Clock, replaced in tests
+}
+
+// lruEntry is an entry of an LRUCache.
+type lruEntry struct {
+	key     string
+	notes   []*model.Note
+	expires time.Time
+}
+
+// NewLRUCache creates a new in-memory LRU cache.
+//
+// Parameters:
+//   - size: The maximum number of entries (at least 1)
+//   - ttl: How long entries are served after they were stored
+//
+// Returns:
+//   - A pointer to a new LRUCache instance
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get returns copies of the notes stored under the key, unless the entry has expired.
+func (c *LRUCache) Get(_ context.Context, key string) ([]*model.Note, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return copyNotes(entry.notes), true
+}
+
+// Set stores copies of the notes under the key, evicting the least recently used entry if the cache is full.
+func (c *LRUCache) Set(_ context.Context, key string, notes []*model.Note) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &lruEntry{key: key, notes: copyNotes(notes), expires: c.now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes the entries with the given keys.
+func (c *LRUCache) Delete(_ context.Context, keys ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+// DeletePrefix removes all entries whose key starts with prefix.
+func (c *LRUCache) DeletePrefix(_ context.Context, prefix string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+		}
+	}
+}
+
+// Len returns the number of entries in the cache, including expired ones not yet removed.
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// remove removes an entry. The caller must hold the mutex.
+func (c *LRUCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).key)
+}
+
+// copyNotes returns a slice of copies of the notes.
+func copyNotes(notes []*model.Note) []*model.Note {
+	copies := make([]*model.Note, len(notes))
+	for i, note := range notes {
+		copied := *note
+		copied.Tags = slices.Clone(note.Tags)
+		copies[i] = &copied
+	}
+	return copies
+}
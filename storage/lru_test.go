@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// newTestLRUCache creates an LRU cache with a manual clock
+func newTestLRUCache(size int, ttl time.Duration) (*LRUCache, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewLRUCache(size, ttl)
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+// TestLRUCacheEviction verifies that the least recently used entry is evicted when the cache is full
+func TestLRUCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestLRUCache(2, time.Minute)
+
+	cache.Set(ctx, "a", []*model.Note{{ID: "a"}})
+	cache.Set(ctx, "b", []*model.Note{{ID: "b"}})
+	if _, ok := cache.Get(ctx, "a"); !ok { // "a" is now the most recently used entry
+		t.Fatal("Expected a to be cached")
+	}
+	cache.Set(ctx, "c", []*model.Note{{ID: "c"}})
+
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(ctx, key); !ok {
+			t.Errorf("Expected %s to be cached", key)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+// TestLRUCacheExpiry verifies that entries are not served after their TTL
+func TestLRUCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	cache, now := newTestLRUCache(10, time.Minute)
+
+	cache.Set(ctx, "a", []*model.Note{{ID: "a"}})
+	*now = now.Add(59 * time.Second)
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatal("Expected a to be cached before its TTL")
+	}
+	*now = now.Add(time.Second)
+	if _, ok := cache.Get(ctx, "a"); ok {
+		t.Error("Expected a to expire after its TTL")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the expired entry to be removed, got %d entries", cache.Len())
+	}
+}
+
+// TestLRUCacheCopies verifies that cached notes cannot be modified through the values stored or returned
+func TestLRUCacheCopies(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestLRUCache(10, time.Minute)
+
+	note := &model.Note{ID: "a", Title: "Original", Tags: []string{"work"}}
+	cache.Set(ctx, "a", []*model.Note{note})
+	note.Title = "Modified after Set"
+	note.Tags[0] = "set"
+
+	cached, _ := cache.Get(ctx, "a")
+	cached[0].Title = "Modified after Get"
+	cached[0].Tags[0] = "get"
+
+	if cached, _ := cache.Get(ctx, "a"); cached[0].Title != "Original" || cached[0].Tags[0] != "work" {
+		t.Errorf("Expected the cached note to be unchanged, got %q %v", cached[0].Title, cached[0].Tags)
+	}
+}
+
+// TestLRUCacheDelete verifies deleting entries by key and by prefix
+func TestLRUCacheDelete(t *testing.T) {
+	ctx := context.Background()
+	cache, _ := newTestLRUCache(10, time.Minute)
+	for _, key := range []string{"note:1", "note:2", "list:a", "list:b"} {
+		cache.Set(ctx, key, nil)
+	}
+
+	cache.Delete(ctx, "note:1", "missing")
+	cache.DeletePrefix(ctx, "list:")
+
+	for key, want := range map[string]bool{"note:1": false, "note:2": true, "list:a": false, "list:b": false} {
+		if _, ok := cache.Get(ctx, key); ok != want {
+			t.Errorf("Expected %s cached: %t, got %t", key, want, ok)
+		}
+	}
+}
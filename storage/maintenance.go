@@ -0,0 +1,52 @@
+// This file contains maintenance tasks that operators run on a storage backend, such as
+// rebuilding indexes or compacting the database. Backends that support them implement
+// Maintainer; decorators pass the tasks on to the backend they wrap.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MaintenanceTask names a maintenance operation on a storage backend.
+type MaintenanceTask string
+
+// Maintenance tasks.
+const (
+	TaskReindex MaintenanceTask = "reindex" // Rebuild the indexes used by queries
+	TaskCompact MaintenanceTask = "compact" // Reclaim the space of deleted and old document revisions
+)
+
+// ErrUnsupportedTask is returned (wrapped) when a backend doesn't support a maintenance task.
+var ErrUnsupportedTask = errors.New("maintenance task is not supported by the storage backend")
+
+// Maintainer is implemented by storage backends that support maintenance tasks.
+type Maintainer interface {
+	// Maintain runs a maintenance task, or returns an error wrapping ErrUnsupportedTask.
+	// Tasks that the database runs in the background (e.g., CouchDB compaction) are
+	// only started.
+	Maintain(ctx context.Context, task MaintenanceTask) error
+}
+
+// Maintain runs a maintenance task on the backend.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Maintainer
+//   - task: The maintenance task to run
+//
+// Returns:
+//   - An error wrapping ErrUnsupportedTask if the backend doesn't support the task,
+//     or an error if the task fails
+func Maintain(ctx context.Context, backend NoteReader, task MaintenanceTask) error {
+	if maintainer, ok := backend.(Maintainer); ok {
+		return maintainer.Maintain(ctx, task)
+	}
+	return unsupportedTask(task)
+}
+
+// unsupportedTask returns the error for a task that a backend doesn't support.
+func unsupportedTask(task MaintenanceTask) error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedTask, task)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// maintainedStorage is an in-memory storage that records the maintenance tasks it runs
+type maintainedStorage struct {
+	*InMemoryStorage
+	tasks []MaintenanceTask
+}
+
+// Maintain records the task, and supports only reindexing
+func (s *maintainedStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	if task != TaskReindex {
+		return unsupportedTask(task)
+	}
+	s.tasks = append(s.tasks, task)
+	return nil
+}
+
+// TestMaintain verifies that decorators pass maintenance tasks on to the backend, and
+// that backends without maintenance report the tasks as unsupported
+func TestMaintain(t *testing.T) {
+	ctx := context.Background()
+
+	if err := Maintain(ctx, NewInMemoryStorage(), TaskReindex); !errors.Is(err, ErrUnsupportedTask) {
+		t.Errorf("Expected ErrUnsupportedTask from the in-memory storage, got %v", err)
+	}
+
+	backend := &maintainedStorage{InMemoryStorage: NewInMemoryStorage()}
+	var storage NoteStorage = NewSwitchableStorage(backend)
+	storage = NewCachedStorage(storage, NewLRUCache(10, time.Minute))
+	storage = NewEncryptedStorage(storage, newTestKeyring(t, "k1"), false)
+	breaker := NewCircuitBreakerStorage(storage, "test", 1, time.Minute)
+
+	if err := Maintain(ctx, breaker, TaskReindex); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+	if len(backend.tasks) != 1 || backend.tasks[0] != TaskReindex {
+		t.Errorf("Expected the backend to reindex, got %v", backend.tasks)
+	}
+
+	// An unsupported task is not a backend failure, so it doesn't open the circuit
+	if err := Maintain(ctx, breaker, TaskCompact); !errors.Is(err, ErrUnsupportedTask) {
+		t.Errorf("Expected ErrUnsupportedTask, got %v", err)
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("Expected the circuit to stay closed, got %s", state)
+	}
+}
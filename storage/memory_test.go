@@ -11,15 +11,6 @@ import (
 	"golang-simple-notes/model"
 )
 
-// TestInMemoryStorage tests the in-memory storage implementation
-func TestInMemoryStorage(t *testing.T) {
-	// Create a new in-memory storage
-	storage := NewInMemoryStorage()
-
-	// Run the fixed storage tests
-	testNoteStorage(t, storage, context.Background())
-}
-
 // TestInMemoryStorageConcurrency tests the thread safety of the in-memory storage
 func TestInMemoryStorageConcurrency(t *testing.T) {
 	storage := NewInMemoryStorage()
@@ -247,6 +238,88 @@ func TestInMemoryStorageConcurrency(t *testing.T) {
 	})
 }
 
+// TestInMemoryStorageCopies tests that the storage keeps its own copies of the notes, so
+// modifying a note that was created, read, or listed neither changes the stored note nor
+// races with other readers. Run with -race to detect sharing:
+//
+//	go test -race ./storage -run TestInMemoryStorageCopies
+func TestInMemoryStorageCopies(t *testing.T) {
+	storage := NewInMemoryStorage()
+	ctx := context.Background()
+
+	note := model.NewNote("Original", "Original content")
+	note.Tags = []string{"original"}
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	note.Title = "Changed after Create"
+	note.Tags[0] = "changed-after-create"
+
+	// Readers modify the notes they got, including their tags, while others read the same note
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			got, err := storage.Get(ctx, note.ID)
+			if err != nil {
+				t.Errorf("Failed to get note: %v", err)
+				return
+			}
+			got.Content = fmt.Sprintf("Changed by reader %d", i)
+			got.Tags[0] = fmt.Sprintf("reader-%d", i)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			notes, err := storage.GetAll(ctx)
+			if err != nil {
+				t.Errorf("Failed to get all notes: %v", err)
+				return
+			}
+			for _, n := range notes {
+				n.Title = fmt.Sprintf("Changed by lister %d", i)
+				n.Tags[0] = fmt.Sprintf("lister-%d", i)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			many, err := storage.GetMany(ctx, []string{note.ID})
+			if err != nil {
+				t.Errorf("Failed to get notes: %v", err)
+				return
+			}
+			listed, err := storage.List(ctx, ListOptions{})
+			if err != nil {
+				t.Errorf("Failed to list notes: %v", err)
+				return
+			}
+			for _, n := range append(many, listed...) {
+				n.Tags[0] = fmt.Sprintf("query-%d", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := storage.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if got.Title != "Original" || got.Content != "Original content" || len(got.Tags) != 1 || got.Tags[0] != "original" {
+		t.Errorf("Expected the stored note to be unchanged, got %q: %q %v", got.Title, got.Content, got.Tags)
+	}
+
+	// A note passed to Update is copied as well
+	updated := *got
+	updated.Content = "Updated content"
+	if err := storage.Update(ctx, &updated); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	updated.Content = "Changed after Update"
+	if got, _ := storage.Get(ctx, note.ID); got.Content != "Updated content" {
+		t.Errorf("Expected the updated content, got %q", got.Content)
+	}
+}
+
 // TestInMemoryStorageEdgeCases tests edge cases for the in-memory storage
 func TestInMemoryStorageEdgeCases(t *testing.T) {
 	storage := NewInMemoryStorage()
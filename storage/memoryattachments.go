@@ -0,0 +1,106 @@
+// This file contains the attachments of notes held by InMemoryStorage.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// memoryAttachment is an attachment held in memory with its content.
+type memoryAttachment struct {
+	Attachment
+	content []byte
+}
+
+// PutAttachment stores a copy of the body as an attachment of a note. Attachments are
+// neither counted against the limits (see SetLimits) nor saved in snapshots.
+func (s *InMemoryStorage) PutAttachment(ctx context.Context, noteID string, attachment Attachment, body io.Reader) (Attachment, error) {
+	// Read the body before locking, so a slow upload doesn't block other operations
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	digest := sha256.Sum256(content)
+	attachment.Size = int64(len(content))
+	attachment.Digest = "sha256-" + base64.StdEncoding.EncodeToString(digest[:])
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.notes[noteID]; !exists {
+		return Attachment{}, ErrNoteNotFound
+	}
+	if s.attachments == nil {
+		s.attachments = make(map[string]map[string]*memoryAttachment)
+	}
+	if s.attachments[noteID] == nil {
+		s.attachments[noteID] = make(map[string]*memoryAttachment)
+	}
+	s.attachments[noteID][attachment.Name] = &memoryAttachment{Attachment: attachment, content: content}
+	return attachment, nil
+}
+
+// GetAttachment returns an attachment of a note with a reader of its content.
+func (s *InMemoryStorage) GetAttachment(ctx context.Context, noteID, name string) (Attachment, io.ReadCloser, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if _, exists := s.notes[noteID]; !exists {
+		return Attachment{}, nil, ErrNoteNotFound
+	}
+	attachment, exists := s.attachments[noteID][name]
+	if !exists {
+		return Attachment{}, nil, ErrAttachmentNotFound
+	}
+	// The content is never modified, only replaced, so it can be read after unlocking
+	return attachment.Attachment, io.NopCloser(bytes.NewReader(attachment.content)), nil
+}
+
+// DeleteAttachment removes an attachment of a note.
+func (s *InMemoryStorage) DeleteAttachment(ctx context.Context, noteID, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.notes[noteID]; !exists {
+		return ErrNoteNotFound
+	}
+	if _, exists := s.attachments[noteID][name]; !exists {
+		return ErrAttachmentNotFound
+	}
+	delete(s.attachments[noteID], name)
+	return nil
+}
+
+// ListAttachments returns the attachments of a note, sorted by name.
+func (s *InMemoryStorage) ListAttachments(ctx context.Context, noteID string) ([]Attachment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if _, exists := s.notes[noteID]; !exists {
+		return nil, ErrNoteNotFound
+	}
+	return s.attachmentsOf(noteID), nil
+}
+
+// ListAttachmentsMany returns the attachments of several notes under a single lock.
+func (s *InMemoryStorage) ListAttachmentsMany(ctx context.Context, noteIDs []string) (map[string][]Attachment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	attachments := make(map[string][]Attachment, len(noteIDs))
+	for _, id := range noteIDs {
+		if _, exists := s.notes[id]; exists {
+			attachments[id] = s.attachmentsOf(id)
+		}
+	}
+	return attachments, nil
+}
+
+// attachmentsOf returns the attachments of a note, sorted by name. The caller must hold the lock.
+func (s *InMemoryStorage) attachmentsOf(noteID string) []Attachment {
+	attachments := make([]Attachment, 0, len(s.attachments[noteID]))
+	for _, attachment := range s.attachments[noteID] {
+		attachments = append(attachments, attachment.Attachment)
+	}
+	sortAttachments(attachments)
+	return attachments
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"golang-simple-notes/model"
+)
+
+// TestInMemoryStorageAttachments tests storing, replacing, listing, and deleting the
+// attachments of a note, and that they are removed with the note
+func TestInMemoryStorageAttachments(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	note := model.NewNote("Title", "Content")
+	if err := s.Create(ctx, note); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	put := func(name, content string) Attachment {
+		t.Helper()
+		attachment, err := s.PutAttachment(ctx, note.ID, Attachment{Name: name, ContentType: "text/plain"}, strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("PutAttachment failed: %v", err)
+		}
+		return attachment
+	}
+	if attachment := put("b.txt", "first"); attachment.Size != 5 || !strings.HasPrefix(attachment.Digest, "sha256-") {
+		t.Errorf("Unexpected attachment %+v", attachment)
+	}
+	put("b.txt", "replaced")
+	put("a.txt", "other")
+
+	attachment, body, err := s.GetAttachment(ctx, note.ID, "b.txt")
+	if err != nil {
+		t.Fatalf("GetAttachment failed: %v", err)
+	}
+	content, _ := io.ReadAll(body)
+	if string(content) != "replaced" || attachment.Size != 8 || attachment.ContentType != "text/plain" {
+		t.Errorf("Expected the replaced attachment, got %+v: %q", attachment, content)
+	}
+
+	// Updates of the note keep its attachments
+	note.Title = "Updated"
+	if err := s.Update(ctx, note); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	list, err := s.ListAttachments(ctx, note.ID)
+	if err != nil || len(list) != 2 || list[0].Name != "a.txt" || list[1].Name != "b.txt" {
+		t.Errorf("Expected both attachments sorted by name, got %+v: %v", list, err)
+	}
+	many, err := ListAttachmentsMany(ctx, s, []string{note.ID, "missing", note.ID})
+	if _, ok := many["missing"]; err != nil || len(many) != 1 || len(many[note.ID]) != 2 || ok {
+		t.Errorf("Expected the attachments of the existing note only, got %+v: %v", many, err)
+	}
+
+	if err := s.DeleteAttachment(ctx, note.ID, "a.txt"); err != nil {
+		t.Fatalf("DeleteAttachment failed: %v", err)
+	}
+	if err := s.DeleteAttachment(ctx, note.ID, "a.txt"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+	}
+	if _, _, err := s.GetAttachment(ctx, note.ID, "a.txt"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+	}
+
+	// Attachments are removed with their note
+	if err := s.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.ListAttachments(ctx, note.ID); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if _, err := s.PutAttachment(ctx, note.ID, Attachment{Name: "c.txt"}, strings.NewReader("")); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected ErrNoteNotFound, got %v", err)
+	}
+	if err := s.Create(ctx, note); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if list, err := s.ListAttachments(ctx, note.ID); err != nil || len(list) != 0 {
+		t.Errorf("Expected a recreated note without attachments, got %+v: %v", list, err)
+	}
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/textnorm"
+)
+
+// minCompaction is the number of documents of changed or deleted notes from which the
+// text index is compacted, once they also outnumber the current ones.
+const minCompaction = 1024
+
+// textIndex is an inverted index of the words of the titles and contents of the notes of
+// InMemoryStorage, without case and accents (see textnorm.Unaccent). It finds the notes
+// that may contain a query without reading them all; the candidates are then checked with
+// the same substring match as ListOptions.Apply, so results don't change, whether accents
+// must match or not.
+//
+// Every version of a note gets a new document number, so posting lists stay sorted by
+// only appending to them. The documents of previous versions are skipped by lookups, and
+// dropped by compact once there are more of them than current ones.
+type textIndex struct {
+	postings map[string][]uint32 // Documents containing each word, in increasing order
+	docs     map[string]uint32   // Current document of each note, by note ID
+	ids      []string            // Note ID of each document; empty once the note changed or was deleted
+	dead     int                 // Number of documents with an empty ID
+}
+
+// newTextIndex creates an empty text index.
+func newTextIndex() *textIndex {
+	return &textIndex{
+		postings: make(map[string][]uint32),
+		docs:     make(map[string]uint32),
+	}
+}
+
+// add indexes a new note, or the new version of a note.
+func (x *textIndex) add(note *model.Note) {
+	x.remove(note.ID)
+	doc := uint32(len(x.ids))
+	x.ids = append(x.ids, note.ID)
+	x.docs[note.ID] = doc
+
+	seen := make(map[string]bool)
+	for _, text := range []string{note.Title, note.Content} {
+		for _, word := range indexWords(textnorm.Unaccent(text)) {
+			if !seen[word] {
+				seen[word] = true
+				x.postings[word] = append(x.postings[word], doc)
+			}
+		}
+	}
+}
+
+// remove drops a note from the index; unknown IDs are ignored.
+func (x *textIndex) remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	delete(x.docs, id)
+	x.ids[doc] = ""
+	x.dead++
+	if x.dead >= minCompaction && x.dead > len(x.docs) {
+		x.compact()
+	}
+}
+
+// compact renumbers the current documents, dropping the others from the posting lists.
+// Numbers keep their order, so the posting lists stay sorted.
+func (x *textIndex) compact() {
+	renumbered := make([]uint32, len(x.ids))
+	ids := make([]string, 0, len(x.docs))
+	for doc, id := range x.ids {
+		if id != "" {
+			renumbered[doc] = uint32(len(ids))
+			ids = append(ids, id)
+		}
+	}
+	for word, docs := range x.postings {
+		current := docs[:0]
+		for _, doc := range docs {
+			if x.ids[doc] != "" {
+				current = append(current, renumbered[doc])
+			}
+		}
+		if len(current) == 0 {
+			delete(x.postings, word)
+		} else {
+			x.postings[word] = slices.Clip(current)
+		}
+	}
+	for doc, id := range ids {
+		x.docs[id] = uint32(doc)
+	}
+	x.ids, x.dead = ids, 0
+}
+
+// candidates returns the IDs of the notes that may contain the query, ignoring case and
+// accents. It returns false if the index can't tell, because the query has no letters or digits.
+func (x *textIndex) candidates(query string) ([]string, bool) {
+	terms := queryTerms(textnorm.Unaccent(query))
+	if len(terms) == 0 {
+		return nil, false
+	}
+	// Whole words are the cheapest to look up, and long words the most selective
+	slices.SortStableFunc(terms, func(a, b queryTerm) int {
+		if a.whole() != b.whole() {
+			if a.whole() {
+				return -1
+			}
+			return 1
+		}
+		return len(b.word) - len(a.word)
+	})
+
+	docs := x.lookup(terms[0])
+	for _, term := range terms[1:] {
+		if len(docs) == 0 {
+			break
+		}
+		docs = intersect(docs, x.lookup(term))
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if id := x.ids[doc]; id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, true
+}
+
+// lookup returns the sorted documents with a word matching the term. Only whole words
+// are found directly; the others scan the vocabulary.
+func (x *textIndex) lookup(term queryTerm) []uint32 {
+	if term.whole() {
+		return x.postings[term.word]
+	}
+
+	var lists [][]uint32
+	for word, docs := range x.postings {
+		if term.matches(word) {
+			lists = append(lists, docs)
+		}
+	}
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	docs := slices.Concat(lists...)
+	slices.Sort(docs)
+	return slices.Compact(docs)
+}
+
+// intersect returns the documents of both sorted lists.
+func intersect(a, b []uint32) []uint32 {
+	var both []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return both
+}
+
+// queryTerm is a word of a query. A word followed by something else in the query must
+// end a word of the text (after), and one preceded by something else must start it
+// (before); the words of "e pi" are found in "apple pie" as the end of "apple" and the
+// start of "pie".
+type queryTerm struct {
+	word   string
+	before bool // The word is preceded by other characters of the query
+	after  bool // The word is followed by other characters of the query
+}
+
+// whole reports whether the term can only match a whole word of the text.
+func (t queryTerm) whole() bool {
+	return t.before && t.after
+}
+
+// matches reports whether an indexed word can contain the term.
+func (t queryTerm) matches(word string) bool {
+	switch {
+	case t.whole():
+		return word == t.word
+	case t.before:
+		return strings.HasPrefix(word, t.word)
+	case t.after:
+		return strings.HasSuffix(word, t.word)
+	default:
+		return strings.Contains(word, t.word)
+	}
+}
+
+// queryTerms splits a normalized query into its words.
+func queryTerms(query string) []queryTerm {
+	var terms []queryTerm
+	start := -1
+	for i, r := range query + " " {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			terms = append(terms, queryTerm{word: query[start:i], before: start > 0, after: i < len(query)})
+			start = -1
+		}
+	}
+	return terms
+}
+
+// indexWords splits a normalized text into its words: the runs of letters and digits.
+func indexWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+}
+
+// isWordRune reports whether a character is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// List returns the notes matching the options. Only the notes that the text index finds
+// for the query are matched, so a query doesn't read every note.
+func (s *InMemoryStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	notes := opts.Apply(s.candidates(opts.Query))
+	for i, note := range notes {
+		copied := *note
+		copied.Tags = slices.Clone(note.Tags)
+		notes[i] = &copied
+	}
+	return notes, nil
+}
+
+// Count returns the number of notes matching the query and the filter of the options,
+// without copying them.
+func (s *InMemoryStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(opts.filterOnly().Apply(s.candidates(opts.Query))), nil
+}
+
+// candidates returns the stored notes that may contain the query: those found by the
+// text index, or all notes. The caller must hold the read lock, and must not modify them.
+func (s *InMemoryStorage) candidates(query string) []*model.Note {
+	if query != "" {
+		if ids, ok := s.index.candidates(query); ok {
+			notes := make([]*model.Note, 0, len(ids))
+			for _, id := range ids {
+				notes = append(notes, s.notes[id])
+			}
+			return notes
+		}
+	}
+	notes := make([]*model.Note, 0, len(s.notes))
+	for _, note := range s.notes {
+		notes = append(notes, note)
+	}
+	return notes
+}
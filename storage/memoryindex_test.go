@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang-simple-notes/model"
+)
+
+// TestInMemoryStorageTextIndex verifies that queries found with the text index match the
+// same notes as a scan of all notes, as notes are created, updated, and deleted
+func TestInMemoryStorageTextIndex(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	notes := []*model.Note{
+		model.NewNote("Apple pie", "Bake at 180 degrees"),
+		model.NewNote("Shopping", "Apples, pineapple, and PIE crust"),
+		model.NewNote("Crème brûlée", "Caramelize the sugar"),
+		model.NewNote("CAFE\u0301", "Straße"),
+		model.NewNote("Numbers", "Call 555-0100 about the 2024 budget"),
+	}
+	for _, note := range notes {
+		if err := s.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	queries := []string{"apple", "ppl", "APPLE PIE", "e pi", "es, pine", "pie", "BRÛLÉE", "creme brulee", "55-01",
+		"2024 bud", "180 degrees", "!", " ", "x", "sugar", "crust", "café", "cafe", "strasse"}
+	check := func(t *testing.T) {
+		t.Helper()
+		all, _ := s.GetAll(ctx)
+		for _, query := range queries {
+			for _, accents := range []bool{false, true} {
+				opts := ListOptions{Query: query, MatchAccents: accents, Sort: SortTitle}
+				got, err := s.List(ctx, opts)
+				if err != nil {
+					t.Fatalf("List(%+v) failed: %v", opts, err)
+				}
+				if want := opts.Apply(slices.Clone(all)); !slices.Equal(noteTitles(got), noteTitles(want)) {
+					t.Errorf("List(%+v): expected %v, got %v", opts, noteTitles(want), noteTitles(got))
+				}
+				if count, _ := s.Count(ctx, opts); count != len(got) {
+					t.Errorf("Count(%+v): expected %d, got %d", opts, len(got), count)
+				}
+			}
+		}
+	}
+	check(t)
+
+	// Updated notes are found by their new words only
+	notes[0].Content = "Sugar and crust"
+	if err := s.Update(ctx, notes[0]); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	if err := s.Delete(ctx, notes[1].ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	check(t)
+
+	// Compacting the index keeps the notes that are still there
+	for i := 0; i < 2*minCompaction; i++ {
+		notes[2].Content = fmt.Sprintf("Caramelize the sugar, take %d", i)
+		if err := s.Update(ctx, notes[2]); err != nil {
+			t.Fatalf("Failed to update note: %v", err)
+		}
+	}
+	if len(s.index.ids) > minCompaction+len(s.notes) {
+		t.Errorf("Expected the index to be compacted, got %d documents", len(s.index.ids))
+	}
+	queries = append(queries, "take 2047", "take 1")
+	check(t)
+}
+
+// benchmarkNotes holds the notes of the benchmarks, which are slow to generate.
+var benchmarkNotes = sync.OnceValue(func() []*model.Note {
+	random := rand.New(rand.NewSource(1))
+	vocabulary := make([]string, 20000)
+	for i := range vocabulary {
+		word := make([]byte, 4+random.Intn(6))
+		for j := range word {
+			word[j] = byte('a' + random.Intn(26))
+		}
+		vocabulary[i] = string(word)
+	}
+	sentence := func(words int) string {
+		parts := make([]string, words)
+		for i := range parts {
+			// A few common words, and a long tail of rare ones
+			parts[i] = vocabulary[min(random.Intn(len(vocabulary)), random.Intn(len(vocabulary)))]
+		}
+		return strings.Join(parts, " ")
+	}
+	notes := make([]*model.Note, 100000)
+	for i := range notes {
+		notes[i] = model.NewNote(sentence(4), sentence(60))
+	}
+	return notes
+})
+
+// benchmarkQueries are searched by the benchmarks: a common word, a rare word, a phrase,
+// and part of a word.
+var benchmarkQueries = []string{"Common", "rare", "two words", "partial"}
+
+// benchmarkStorage returns an in-memory storage with 100,000 notes, and the queries of
+// benchmarkQueries taken from them.
+func benchmarkStorage(b *testing.B) (*InMemoryStorage, map[string]string) {
+	b.Helper()
+	notes := benchmarkNotes()
+	s := NewInMemoryStorage()
+	for _, note := range notes {
+		if err := s.Create(context.Background(), note); err != nil {
+			b.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	common := strings.Fields(notes[0].Content)[0]
+	rare := strings.Fields(notes[len(notes)-1].Content)
+	queries := map[string]string{
+		"Common":    common,
+		"rare":      rare[len(rare)-1],
+		"two words": strings.Join(rare[10:12], " "),
+		"partial":   rare[20][1:4],
+	}
+	return s, queries
+}
+
+// BenchmarkInMemoryListQuery lists the notes matching queries with the text index
+func BenchmarkInMemoryListQuery(b *testing.B) {
+	s, queries := benchmarkStorage(b)
+	ctx := context.Background()
+	for _, name := range benchmarkQueries {
+		b.Run(name, func(b *testing.B) {
+			opts := ListOptions{Query: queries[name], Sort: SortTitle, Limit: 20}
+			for i := 0; i < b.N; i++ {
+				if _, err := s.List(ctx, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkInMemoryListQueryScan lists the notes matching the same queries by scanning
+// all notes, as without the text index
+func BenchmarkInMemoryListQueryScan(b *testing.B) {
+	s, queries := benchmarkStorage(b)
+	ctx := context.Background()
+	for _, name := range benchmarkQueries {
+		b.Run(name, func(b *testing.B) {
+			opts := ListOptions{Query: queries[name], Sort: SortTitle, Limit: 20}
+			for i := 0; i < b.N; i++ {
+				notes, err := s.GetAll(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				opts.Apply(notes)
+			}
+		})
+	}
+}
+
+// BenchmarkInMemoryUpdate updates notes of a storage with 100,000 notes, which indexes
+// their words again
+func BenchmarkInMemoryUpdate(b *testing.B) {
+	s, _ := benchmarkStorage(b)
+	ctx := context.Background()
+	notes := benchmarkNotes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		note := *notes[i%len(notes)]
+		note.Title = fmt.Sprintf("Version %d", i)
+		if err := s.Update(ctx, &note); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+)
+
+// ErrStorageFull is returned when a note cannot be stored because the storage has reached its limits.
+var ErrStorageFull = errors.New("storage is full")
+
+// EvictionPolicy defines what InMemoryStorage does with a write that would exceed its limits.
+type EvictionPolicy string
+
+// Eviction policies.
+const (
+	// EvictionReject rejects the write with ErrStorageFull; notes have to be deleted first.
+	EvictionReject EvictionPolicy = "reject"
+	// EvictionLRU applies the write and deletes the least recently used notes (read with Get,
+	// created, or updated) until the storage is within its limits again.
+	EvictionLRU EvictionPolicy = "lru"
+)
+
+// Validate checks that the policy is a known one.
+func (p EvictionPolicy) Validate() error {
+	switch p {
+	case EvictionReject, EvictionLRU:
+		return nil
+	default:
+		return fmt.Errorf("unknown eviction policy %q (must be %q or %q)", p, EvictionReject, EvictionLRU)
+	}
+}
+
+// InMemoryLimits bounds the memory used by an InMemoryStorage, so that it cannot exhaust
+// the memory of the process. Zero values mean no limit.
+type InMemoryLimits struct {
+	MaxNotes int            // Maximum number of notes
+	MaxBytes int            // Maximum total size of the notes (see noteSize)
+	Eviction EvictionPolicy // What happens to writes beyond the limits; empty means EvictionReject
+}
+
+// enabled reports whether any limit is set.
+func (l InMemoryLimits) enabled() bool {
+	return l.MaxNotes > 0 || l.MaxBytes > 0
+}
+
+// exceeded reports whether a storage with the given number of notes and size is beyond the limits.
+func (l InMemoryLimits) exceeded(count, size int) bool {
+	return (l.MaxNotes > 0 && count > l.MaxNotes) || (l.MaxBytes > 0 && size > l.MaxBytes)
+}
+
+// noteOverhead approximates the memory used by a note besides its text: the struct with
+// its timestamps, the map entry, and the string headers.
+const noteOverhead = 128
+
+// noteSize returns the size a note counts with against InMemoryLimits.MaxBytes: the length
+// of its text plus a fixed overhead.
+func noteSize(note *model.Note) int {
+	size := noteOverhead + len(note.ID) + len(note.Rev) + len(note.Title) + len(note.Content) + len(note.Summary) +
+		len(note.Color) + len(note.Icon)
+	for _, tag := range note.Tags {
+		size += len(tag)
+	}
+	return size
+}
+
+// SetLimits bounds the number and total size of the notes. Notes already stored beyond the
+// limits are evicted, oldest update first, with EvictionLRU; with EvictionReject, they are
+// kept, and writes are rejected until enough notes are deleted.
+// The usage is reported on /metrics while limits are set.
+func (s *InMemoryStorage) SetLimits(limits InMemoryLimits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.limits = limits
+	s.recency.Lock()
+	s.used = make(map[string]*list.Element)
+	s.order = list.New()
+	s.recency.Unlock()
+	if limits.enabled() && limits.Eviction == EvictionLRU {
+		// Without reads yet, the least recently updated notes are evicted first
+		notes := make([]*model.Note, 0, len(s.notes))
+		for _, note := range s.notes {
+			notes = append(notes, note)
+		}
+		slices.SortFunc(notes, func(a, b *model.Note) int { return a.UpdatedAt.Compare(b.UpdatedAt) })
+		for _, note := range notes {
+			s.touch(note.ID)
+		}
+		s.evict("")
+	}
+	s.reportUsage()
+}
+
+// store stores a copy of the note, replacing a note with the same ID, within the limits.
+// The caller must hold the write lock.
+func (s *InMemoryStorage) store(note *model.Note) error {
+	size := noteSize(note)
+	count, total := len(s.notes)+1, s.size+size
+	if old, exists := s.notes[note.ID]; exists {
+		count, total = count-1, total-noteSize(old)
+	}
+	if s.limits.exceeded(count, total) {
+		// Evicting other notes doesn't help a note that doesn't fit on its own
+		if s.limits.Eviction != EvictionLRU || s.limits.exceeded(1, size) {
+			metrics.StorageMemoryRejections.Inc()
+			return ErrStorageFull
+		}
+	}
+
+	stored := *note
+	stored.Tags = slices.Clone(note.Tags) // Not shared with the caller, who may change them
+	s.notes[note.ID] = &stored
+	s.index.add(&stored)
+	s.size = total
+	s.version++
+	s.touch(note.ID)
+	s.evict(note.ID)
+	s.reportUsage()
+	return nil
+}
+
+// remove deletes a note. The caller must hold the write lock.
+func (s *InMemoryStorage) remove(id string) {
+	s.size -= noteSize(s.notes[id])
+	delete(s.notes, id)
+	s.index.remove(id)
+	delete(s.attachments, id)
+	s.version++
+
+	s.recency.Lock()
+	if element, ok := s.used[id]; ok {
+		s.order.Remove(element)
+		delete(s.used, id)
+	}
+	s.recency.Unlock()
+}
+
+// touch marks a note as the most recently used one, if notes are evicted by recency.
+// The caller must hold the read or write lock.
+func (s *InMemoryStorage) touch(id string) {
+	if !s.limits.enabled() || s.limits.Eviction != EvictionLRU {
+		return
+	}
+	s.recency.Lock()
+	defer s.recency.Unlock()
+	if element, ok := s.used[id]; ok {
+		s.order.MoveToFront(element)
+		return
+	}
+	s.used[id] = s.order.PushFront(id)
+}
+
+// evict removes the least recently used notes, except the one with the given ID, until the
+// storage is within its limits. The caller must hold the write lock.
+func (s *InMemoryStorage) evict(keep string) {
+	for s.limits.exceeded(len(s.notes), s.size) {
+		s.recency.Lock()
+		oldest := s.order.Back()
+		s.recency.Unlock()
+		if oldest == nil || oldest.Value.(string) == keep {
+			return
+		}
+		s.remove(oldest.Value.(string))
+		metrics.StorageMemoryEvictions.Inc()
+	}
+}
+
+// reportUsage updates the usage metrics, if limits are set. The caller must hold the write lock.
+func (s *InMemoryStorage) reportUsage() {
+	if !s.limits.enabled() {
+		return
+	}
+	metrics.StorageMemoryNotes.Set(float64(len(s.notes)))
+	metrics.StorageMemoryBytes.Set(float64(s.size))
+}
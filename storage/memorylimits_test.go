@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// createNotes creates notes with the IDs note-0 to note-<n-1>, updated a second apart in that order
+func createNotes(t *testing.T, storage *InMemoryStorage, n int) {
+	t.Helper()
+	start := time.Now()
+	for i := range n {
+		note := &model.Note{ID: fmt.Sprintf("note-%d", i), Title: "Title", UpdatedAt: start.Add(time.Duration(i) * time.Second)}
+		if err := storage.Create(context.Background(), note); err != nil {
+			t.Fatalf("Failed to create %s: %v", note.ID, err)
+		}
+	}
+}
+
+// noteIDs returns the sorted IDs of the stored notes
+func noteIDs(t *testing.T, storage *InMemoryStorage) string {
+	t.Helper()
+	notes, err := storage.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get all notes: %v", err)
+	}
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	slices.Sort(ids)
+	return strings.Join(ids, ",")
+}
+
+// TestInMemoryStorageLimits_Reject tests that writes beyond the limits are rejected
+func TestInMemoryStorageLimits_Reject(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	storage.SetLimits(InMemoryLimits{MaxNotes: 2, Eviction: EvictionReject})
+	createNotes(t, storage, 2)
+
+	if err := storage.Create(ctx, &model.Note{ID: "extra", Title: "Title"}); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Expected ErrStorageFull, got %v", err)
+	}
+	// Updates of stored notes don't add notes
+	if err := storage.Update(ctx, &model.Note{ID: "note-0", Title: "Updated"}); err != nil {
+		t.Errorf("Expected the update to succeed, got %v", err)
+	}
+	// After a deletion, there is room again
+	if err := storage.Delete(ctx, "note-1"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if err := storage.Create(ctx, &model.Note{ID: "extra", Title: "Title"}); err != nil {
+		t.Errorf("Expected the note to fit after a deletion, got %v", err)
+	}
+
+	// The size limit applies to updates that grow notes as well
+	storage = NewInMemoryStorage()
+	storage.SetLimits(InMemoryLimits{MaxBytes: 2 * (noteOverhead + 100)})
+	createNotes(t, storage, 2)
+	grown := &model.Note{ID: "note-0", Title: "Title", Content: strings.Repeat("x", 200)}
+	if err := storage.Update(ctx, grown); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Expected ErrStorageFull for an update beyond the size limit, got %v", err)
+	}
+	if got, _ := storage.Get(ctx, "note-0"); got.Content != "" {
+		t.Errorf("Expected the rejected update not to be stored, got %q", got.Content)
+	}
+}
+
+// TestInMemoryStorageLimits_LRU tests that the least recently used notes are evicted to make room
+func TestInMemoryStorageLimits_LRU(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	createNotes(t, storage, 4)
+
+	// Existing notes beyond the limits are evicted, least recently updated first
+	storage.SetLimits(InMemoryLimits{MaxNotes: 3, Eviction: EvictionLRU})
+	if ids := noteIDs(t, storage); ids != "note-1,note-2,note-3" {
+		t.Fatalf("Expected the oldest note to be evicted, got %s", ids)
+	}
+
+	// Reading a note makes it recently used
+	if _, err := storage.Get(ctx, "note-1"); err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if err := storage.Create(ctx, &model.Note{ID: "note-4", Title: "Title"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if ids := noteIDs(t, storage); ids != "note-1,note-3,note-4" {
+		t.Errorf("Expected the least recently used note to be evicted, got %s", ids)
+	}
+
+	// A note that doesn't fit on its own is rejected rather than emptying the storage
+	storage.SetLimits(InMemoryLimits{MaxBytes: 3 * (noteOverhead + 20), Eviction: EvictionLRU})
+	huge := &model.Note{ID: "huge", Title: "Title", Content: strings.Repeat("x", 4*noteOverhead)}
+	if err := storage.Create(ctx, huge); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("Expected ErrStorageFull for a note larger than the limit, got %v", err)
+	}
+	if ids := noteIDs(t, storage); ids != "note-1,note-3,note-4" {
+		t.Errorf("Expected no evictions for a rejected note, got %s", ids)
+	}
+
+	// Deleting all notes leaves nothing to account for
+	for _, id := range []string{"note-1", "note-3", "note-4"} {
+		if err := storage.Delete(ctx, id); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+	}
+	if storage.size != 0 || storage.order.Len() != 0 || len(storage.used) != 0 {
+		t.Errorf("Expected no usage after deleting all notes, got %d bytes and %d used", storage.size, storage.order.Len())
+	}
+}
+
+// TestEvictionPolicy_Validate tests the validation of eviction policies
+func TestEvictionPolicy_Validate(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictionReject, EvictionLRU} {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", policy, err)
+		}
+	}
+	if err := EvictionPolicy("fifo").Validate(); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
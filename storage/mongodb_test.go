@@ -2,83 +2,93 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"testing"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"golang-simple-notes/model"
 )
 
-// TestMongoDBStorage tests the MongoDB storage implementation
-// This test uses the shared MongoDB container from TestMain
-func TestMongoDBStorage(t *testing.T) {
-	// Skip this test if we're not running integration tests
-	if testing.Short() {
-		t.Skip("Skipping MongoDB integration test in short mode")
-	}
-
-	ctx := context.Background()
-
-	// Use the shared MongoDB container
-	mongodbEndpoint := getSharedMongoURI()
-	if mongodbEndpoint == "" {
-		t.Skip("Shared MongoDB container not available")
-	}
-
-	// Add database and collection names to the URI
-	dbName := "test_notes"
-	collectionName := "test_notes"
-
-	// Connect to the MongoDB container
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongodbEndpoint))
-	if err != nil {
-		t.Fatalf("Failed to connect to MongoDB container: %v", err)
-	}
-	defer func(client *mongo.Client, ctx context.Context) {
-		err := client.Disconnect(ctx)
+// TestMongoDBOptions tests converting the client settings into MongoDB client options
+func TestMongoDBOptions(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		opts, err := MongoDBOptions{
+			MaxPoolSize:    50,
+			MinPoolSize:    5,
+			ReadPreference: "secondaryPreferred",
+			WriteConcern:   "majority",
+		}.clientOptions()
 		if err != nil {
-			t.Logf("Warning: Failed to disconnect from MongoDB container: %v", err)
+			t.Fatalf("Expected valid options, got %v", err)
 		}
-	}(client, ctx)
+		if *opts.MaxPoolSize != 50 || *opts.MinPoolSize != 5 {
+			t.Errorf("Expected pool sizes 50/5, got %d/%d", *opts.MaxPoolSize, *opts.MinPoolSize)
+		}
+		if opts.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+			t.Errorf("Expected read preference secondaryPreferred, got %v", opts.ReadPreference.Mode())
+		}
+		if opts.WriteConcern.W != "majority" {
+			t.Errorf("Expected write concern majority, got %v", opts.WriteConcern.W)
+		}
+	})
 
-	// Ping the MongoDB server to verify connection
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		t.Fatalf("Failed to ping MongoDB container: %v", err)
-	}
+	t.Run("ZeroValue", func(t *testing.T) {
+		// Zero values keep the connection string settings and driver defaults
+		opts, err := MongoDBOptions{}.clientOptions()
+		if err != nil {
+			t.Fatalf("Expected valid options, got %v", err)
+		}
+		if opts.MaxPoolSize != nil || opts.MinPoolSize != nil || opts.ReadPreference != nil || opts.WriteConcern != nil {
+			t.Errorf("Expected no options to be set, got %+v", opts)
+		}
+	})
 
-	// Create a new MongoDB storage
-	storage, err := NewMongoDBStorage(mongodbEndpoint, dbName, collectionName)
-	if err != nil {
-		t.Fatalf("Failed to create MongoDB storage: %v", err)
-	}
+	t.Run("NumericWriteConcern", func(t *testing.T) {
+		opts, err := MongoDBOptions{WriteConcern: "2"}.clientOptions()
+		if err != nil {
+			t.Fatalf("Expected valid options, got %v", err)
+		}
+		if opts.WriteConcern.W != 2 {
+			t.Errorf("Expected write concern 2, got %v", opts.WriteConcern.W)
+		}
+	})
 
-	// Clean up the test collection before starting
-	err = client.Database(dbName).Collection(collectionName).Drop(ctx)
-	if err != nil {
-		t.Logf("Warning: Failed to drop test collection: %v", err)
+	invalid := map[string]MongoDBOptions{
+		"MinAboveMax":    {MaxPoolSize: 5, MinPoolSize: 10},
+		"ReadPreference": {ReadPreference: "fastest"},
+		"WriteConcern":   {WriteConcern: "all"},
+		"NegativeNodes":  {WriteConcern: "-1"},
 	}
-
-	// Run the fixed storage tests
-	testNoteStorage(t, storage, ctx)
-
-	// Clean up after the test
-	err = client.Database(dbName).Collection(collectionName).Drop(ctx)
-	if err != nil {
-		t.Logf("Warning: Failed to drop test collection: %v", err)
+	for name, opts := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := opts.Validate(); err == nil {
+				t.Errorf("Expected an error for %+v", opts)
+			}
+		})
 	}
 }
 
-// TestMongoDBStorageUnit tests the MongoDB storage implementation with unit tests
-func TestMongoDBStorageUnit(t *testing.T) {
-	// Create a mock implementation of NoteStorage that behaves like MongoDB
-	storage := NewMockMongoDBStorage()
+// TestNewMongoDBStorageCanceled verifies that canceling the context stops connecting
+// right away, instead of retrying until the policy gives up
+func TestNewMongoDBStorageCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	// Run the fixed storage tests
-	testNoteStorage(t, storage, context.Background())
+	start := time.Now()
+	_, err := NewMongoDBStorage(ctx, "mongodb://localhost:1", "notes", "notes", MongoDBOptions{}, DefaultRetryPolicy())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected connecting to stop right away, took %v", elapsed)
+	}
 }
 
 // MockMongoDBStorage is a mock implementation of NoteStorage that behaves like MongoDB
@@ -86,6 +96,14 @@ type MockMongoDBStorage struct {
 	notes map[string]*model.Note
 }
 
+// copyMockNote returns a copy of a note that shares nothing with it, the way real
+// backends decode a new note on every read.
+func copyMockNote(note *model.Note) *model.Note {
+	copied := *note
+	copied.Tags = slices.Clone(note.Tags)
+	return &copied
+}
+
 // NewMockMongoDBStorage creates a new instance of MockMongoDBStorage
 func NewMockMongoDBStorage() *MockMongoDBStorage {
 	return &MockMongoDBStorage{
@@ -101,7 +119,7 @@ func (s *MockMongoDBStorage) Create(ctx context.Context, note *model.Note) error
 	}
 
 	// Store a copy of the note
-	s.notes[note.ID] = note
+	s.notes[note.ID] = copyMockNote(note)
 	return nil
 }
 
@@ -111,14 +129,14 @@ func (s *MockMongoDBStorage) Get(ctx context.Context, id string) (*model.Note, e
 	if !exists {
 		return nil, ErrNoteNotFound
 	}
-	return note, nil
+	return copyMockNote(note), nil
 }
 
 // GetAll retrieves all notes from the storage
 func (s *MockMongoDBStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
 	notes := make([]*model.Note, 0, len(s.notes))
 	for _, note := range s.notes {
-		notes = append(notes, note)
+		notes = append(notes, copyMockNote(note))
 	}
 	return notes, nil
 }
@@ -133,7 +151,7 @@ func (s *MockMongoDBStorage) Update(ctx context.Context, note *model.Note) error
 	note.UpdatedAt = time.Now()
 
 	// Store a copy of the note
-	s.notes[note.ID] = note
+	s.notes[note.ID] = copyMockNote(note)
 	return nil
 }
 
@@ -147,6 +165,11 @@ func (s *MockMongoDBStorage) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Ping always succeeds for mock storage
+func (s *MockMongoDBStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Close closes any resources used by the storage
 func (s *MockMongoDBStorage) Close(ctx context.Context) error {
 	// Nothing to close for mock storage
@@ -199,7 +222,7 @@ func TestMongoDBSpecificFeatures(t *testing.T) {
 	}
 
 	// Create a new MongoDB storage
-	storage, err := NewMongoDBStorage(mongodbEndpoint, dbName, collectionName)
+	storage, err := NewMongoDBStorage(ctx, mongodbEndpoint, dbName, collectionName, MongoDBOptions{}, DefaultRetryPolicy())
 	if err != nil {
 		t.Fatalf("Failed to create MongoDB storage: %v", err)
 	}
@@ -230,6 +253,73 @@ func TestMongoDBSpecificFeatures(t *testing.T) {
 		}
 	})
 
+	// Test that the indexes are created, and that creating them again is a no-op
+	t.Run("Indexes", func(t *testing.T) {
+		if err := storage.ensureIndexes(ctx); err != nil {
+			t.Fatalf("Failed to create indexes again: %v", err)
+		}
+
+		specs, err := storage.collection.Indexes().ListSpecifications(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list indexes: %v", err)
+		}
+		names := make(map[string]bool, len(specs))
+		for _, spec := range specs {
+			names[spec.Name] = true
+		}
+		for _, want := range []string{"_id_", "notes_text", "notes_created_at"} {
+			if !names[want] {
+				t.Errorf("Expected index %q, got %v", want, names)
+			}
+		}
+	})
+
+	// Test counting the notes matching a query, and the statistics of all notes
+	t.Run("CountAndStats", func(t *testing.T) {
+		for i, title := range []string{"Count me (a.b)", "count ME too (A.B)", "Not me (axb)"} {
+			note := model.NewNote(title, "Content")
+			note.Tags = []string{"count", "work"}[:i]
+			if err := storage.Create(ctx, note); err != nil {
+				t.Fatalf("Failed to create note: %v", err)
+			}
+		}
+
+		// The query is matched literally and case-insensitively
+		count, err := storage.Count(ctx, ListOptions{Query: "(a.b)"})
+		if err != nil || count != 2 {
+			t.Errorf("Expected 2 matching notes, got %d, %v", count, err)
+		}
+
+		// The aggregation agrees with the notes themselves
+		all, err := storage.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		stats, err := storage.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		if stats.Storage != BackendMongoDB || stats.Notes != len(all) || stats.Oldest == nil {
+			t.Errorf("Unexpected statistics for %d notes: %+v", len(all), stats)
+		}
+		if want := map[string]int{"count": 2, "work": 1}; !maps.Equal(stats.Tags, want) {
+			t.Errorf("Expected notes per tag %v, got %v", want, stats.Tags)
+		}
+	})
+
+	t.Run("Maintain", func(t *testing.T) {
+		if err := storage.Maintain(ctx, TaskReindex); err != nil {
+			t.Fatalf("Reindex failed: %v", err)
+		}
+		// Queries still work on the recreated indexes
+		if _, err := storage.Count(ctx, ListOptions{Query: "count"}); err != nil {
+			t.Errorf("Count after reindex failed: %v", err)
+		}
+		if err := storage.Maintain(ctx, "vacuum"); !errors.Is(err, ErrUnsupportedTask) {
+			t.Errorf("Expected ErrUnsupportedTask, got %v", err)
+		}
+	})
+
 	// Test error cases
 	t.Run("ErrorCases", func(t *testing.T) {
 		// Create a context with a shorter timeout for error cases
@@ -0,0 +1,247 @@
+// This file contains list queries: filtering, sorting, and paginating notes, and counting
+// the matching notes. Backends that can run these queries natively implement Lister and
+// Counter; for the others, List and Count load all notes and apply the query in memory.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/textnorm"
+)
+
+// Fields notes can be sorted by.
+const (
+	SortCreatedAt = "created_at" // Creation time
+	SortUpdatedAt = "updated_at" // Last update time
+	SortTitle     = "title"      // Title
+
+	// SortMostViewed sorts by the Views of the notes, most viewed first. Backends don't
+	// store views, so notes are sorted in memory by whoever sets them (see service.ViewCounter).
+	SortMostViewed = "most_viewed"
+)
+
+// ListOptions describes which notes to list and in which order.
+// The zero value lists all notes in an unspecified order.
+type ListOptions struct {
+	Query        string  // Text the title or content must contain, ignoring case and accents (see textnorm); empty matches every note
+	MatchAccents bool    // Whether the accents of the query must match, so "cafe" doesn't match "café"
+	Filter       *Filter // Filter expression the notes must match too (see ParseFilter); nil matches every note
+	Sort         string  // Field to sort by (SortCreatedAt, SortUpdatedAt, SortTitle, or SortMostViewed); empty leaves the order unspecified
+	Descending   bool    // Whether to sort in descending order
+	Limit        int     // Maximum number of notes to return; 0 means no limit
+	Offset       int     // Number of matching notes to skip
+}
+
+// Validate checks that the options describe a valid query.
+func (o ListOptions) Validate() error {
+	switch o.Sort {
+	case "", SortCreatedAt, SortUpdatedAt, SortTitle, SortMostViewed:
+	default:
+		return fmt.Errorf("unknown sort field %q (must be %s, %s, %s, or %s)", o.Sort, SortCreatedAt, SortUpdatedAt, SortTitle, SortMostViewed)
+	}
+	if o.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	if o.Offset < 0 {
+		return fmt.Errorf("offset must not be negative")
+	}
+	return nil
+}
+
+// Lister is implemented by storage backends that can filter, sort, and paginate notes
+// themselves, rather than returning all notes to be processed in memory.
+type Lister interface {
+	// List returns the notes matching the options, sorted and paginated as requested.
+	List(ctx context.Context, opts ListOptions) ([]*model.Note, error)
+}
+
+// List returns the notes of the backend matching the options, sorted and paginated as requested.
+// Backends that implement Lister run the query themselves; for the others, all notes are
+// loaded and the query is applied in memory.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Lister
+//   - opts: The query to run
+//
+// Returns:
+//   - The matching notes, which may be empty
+//   - An error if the options are invalid or the storage operation fails
+func List(ctx context.Context, backend NoteReader, opts ListOptions) ([]*model.Note, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Sort == SortMostViewed {
+		// Backends can't sort by views, which they don't store
+		notes, err := List(ctx, backend, opts.filterOnly())
+		if err != nil {
+			return nil, err
+		}
+		return opts.pageOnly().Apply(notes), nil
+	}
+	if lister, ok := backend.(Lister); ok {
+		return lister.List(ctx, opts)
+	}
+
+	notes, err := backend.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return opts.Apply(notes), nil
+}
+
+// StreamList calls fn for every note matching a list query, in the order of the query.
+// Queries without sorting and pagination are streamed (see Stream) and filtered as the notes
+// are read, so the notes are never held in memory together; the others are listed first
+// (see List), since sorting needs all matching notes.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Streamer or Lister
+//   - opts: The list query
+//   - fn: The function called for each matching note; an error stops the list
+//
+// Returns:
+//   - The error returned by fn, or an error reading the notes
+func StreamList(ctx context.Context, backend NoteReader, opts ListOptions, fn func(note *model.Note) error) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	if opts.Sort != "" || opts.Limit > 0 || opts.Offset > 0 {
+		notes, err := List(ctx, backend, opts)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	query := opts.normalize(opts.Query)
+	return Stream(ctx, backend, func(note *model.Note) error {
+		if !opts.matches(note, query) {
+			return nil
+		}
+		return fn(note)
+	})
+}
+
+// Counter is implemented by storage backends that can count matching notes themselves,
+// rather than returning all notes to be counted in memory.
+type Counter interface {
+	// Count returns the number of notes matching the query of the options.
+	// Sorting and pagination are ignored.
+	Count(ctx context.Context, opts ListOptions) (int, error)
+}
+
+// filterOnly returns the options that select notes (the query and the filter), without
+// sorting and pagination.
+func (o ListOptions) filterOnly() ListOptions {
+	return ListOptions{Query: o.Query, MatchAccents: o.MatchAccents, Filter: o.Filter}
+}
+
+// pageOnly returns the options that sort and paginate notes, without selecting them.
+func (o ListOptions) pageOnly() ListOptions {
+	return ListOptions{Sort: o.Sort, Descending: o.Descending, Limit: o.Limit, Offset: o.Offset}
+}
+
+// Count returns the number of notes of the backend matching the query of the options,
+// regardless of their pagination, e.g., to render pagination controls for a list.
+// Backends that implement Counter count the notes themselves; for the others, the
+// matching notes are listed and counted.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Counter
+//   - opts: The query whose matches are counted; only the Query and Filter fields are used
+//
+// Returns:
+//   - The number of matching notes
+//   - An error if the storage operation fails
+func Count(ctx context.Context, backend NoteReader, opts ListOptions) (int, error) {
+	filter := opts.filterOnly()
+	if counter, ok := backend.(Counter); ok {
+		return counter.Count(ctx, filter)
+	}
+
+	notes, err := List(ctx, backend, filter)
+	if err != nil {
+		return 0, err
+	}
+	return len(notes), nil
+}
+
+// Apply filters, sorts, and paginates the given notes in memory.
+// The slice passed in may be reordered.
+func (o ListOptions) Apply(notes []*model.Note) []*model.Note {
+	if o.Query != "" || o.Filter != nil {
+		query := o.normalize(o.Query)
+		matching := notes[:0:0]
+		for _, note := range notes {
+			if o.matches(note, query) {
+				matching = append(matching, note)
+			}
+		}
+		notes = matching
+	}
+
+	if o.Sort != "" {
+		less := noteLess(o.Sort)
+		sort.SliceStable(notes, func(i, j int) bool {
+			if o.Descending {
+				return less(notes[j], notes[i])
+			}
+			return less(notes[i], notes[j])
+		})
+	}
+
+	if o.Offset >= len(notes) {
+		return []*model.Note{}
+	}
+	notes = notes[o.Offset:]
+	if o.Limit > 0 && o.Limit < len(notes) {
+		notes = notes[:o.Limit]
+	}
+	return notes
+}
+
+// matches reports whether the note contains the normalized query in its title or content,
+// and matches the filter.
+func (o ListOptions) matches(note *model.Note, query string) bool {
+	if query != "" && !strings.Contains(o.normalize(note.Title), query) &&
+		!strings.Contains(o.normalize(note.Content), query) {
+		return false
+	}
+	return o.Filter.Matches(note)
+}
+
+// normalize returns the form of a text that queries compare: case folded, and without
+// accents unless they must match.
+func (o ListOptions) normalize(text string) string {
+	if o.MatchAccents {
+		return textnorm.Fold(text)
+	}
+	return textnorm.Unaccent(text)
+}
+
+// noteLess returns the ascending order of notes by the given sort field.
+func noteLess(field string) func(a, b *model.Note) bool {
+	switch field {
+	case SortUpdatedAt:
+		return func(a, b *model.Note) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case SortTitle:
+		return func(a, b *model.Note) bool { return a.Title < b.Title }
+	case SortMostViewed:
+		return func(a, b *model.Note) bool { return a.Views > b.Views }
+	default:
+		return func(a, b *model.Note) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	}
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// noteTitles returns the titles of the notes, in order
+func noteTitles(notes []*model.Note) []string {
+	titles := make([]string, 0, len(notes))
+	for _, note := range notes {
+		titles = append(titles, note.Title)
+	}
+	return titles
+}
+
+// queryTestNotes returns notes created an hour apart, and updated in reverse order
+func queryTestNotes() []*model.Note {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	titles := []string{"Banana", "apple pie", "Cherry", "Apple juice"}
+	notes := make([]*model.Note, 0, len(titles))
+	for i, title := range titles {
+		notes = append(notes, &model.Note{
+			ID:        title,
+			Title:     title,
+			Content:   "Content of " + title,
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: base.Add(time.Duration(len(titles)-i) * time.Hour),
+		})
+	}
+	return notes
+}
+
+// TestListOptionsApply verifies filtering, sorting, and pagination in memory
+func TestListOptionsApply(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"ZeroValue", ListOptions{}, []string{"Banana", "apple pie", "Cherry", "Apple juice"}},
+		{"QueryIgnoresCase", ListOptions{Query: "APPLE"}, []string{"apple pie", "Apple juice"}},
+		{"QueryMatchesContent", ListOptions{Query: "of cherry"}, []string{"Cherry"}},
+		{"SortCreatedAtDescending", ListOptions{Sort: SortCreatedAt, Descending: true}, []string{"Apple juice", "Cherry", "apple pie", "Banana"}},
+		{"SortUpdatedAt", ListOptions{Sort: SortUpdatedAt}, []string{"Apple juice", "Cherry", "apple pie", "Banana"}},
+		{"SortTitle", ListOptions{Sort: SortTitle}, []string{"Apple juice", "Banana", "Cherry", "apple pie"}},
+		{"Limit", ListOptions{Sort: SortCreatedAt, Limit: 2}, []string{"Banana", "apple pie"}},
+		{"Offset", ListOptions{Sort: SortCreatedAt, Limit: 2, Offset: 3}, []string{"Apple juice"}},
+		{"OffsetPastEnd", ListOptions{Offset: 10}, []string{}},
+		{"Combined", ListOptions{Query: "apple", Sort: SortCreatedAt, Descending: true, Limit: 1}, []string{"Apple juice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noteTitles(tt.opts.Apply(queryTestNotes())); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestListOptionsAccents verifies that queries ignore case and accents, unless accents
+// must match, however the accents are encoded
+func TestListOptionsAccents(t *testing.T) {
+	notes := []*model.Note{
+		{Title: "Café au lait"},
+		{Title: "CAFE\u0301 NOIR"},
+		{Title: "Cafeteria", Content: "Straße"},
+	}
+	tests := []struct {
+		opts ListOptions
+		want []string
+	}{
+		{ListOptions{Query: "cafe"}, []string{"Café au lait", "CAFE\u0301 NOIR", "Cafeteria"}},
+		{ListOptions{Query: "CAFÉ"}, []string{"Café au lait", "CAFE\u0301 NOIR", "Cafeteria"}},
+		{ListOptions{Query: "café", MatchAccents: true}, []string{"Café au lait", "CAFE\u0301 NOIR"}},
+		{ListOptions{Query: "cafe", MatchAccents: true}, []string{"Cafeteria"}},
+		{ListOptions{Query: "STRASSE"}, []string{"Cafeteria"}},
+	}
+	for _, tt := range tests {
+		if got := noteTitles(tt.opts.Apply(slices.Clone(notes))); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: expected %v, got %v", tt.opts, tt.want, got)
+		}
+	}
+}
+
+// TestListOptionsValidate verifies that invalid options are rejected
+func TestListOptionsValidate(t *testing.T) {
+	valid := []ListOptions{{}, {Sort: SortTitle, Descending: true, Limit: 10, Offset: 20}}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", opts, err)
+		}
+	}
+
+	invalid := []ListOptions{{Sort: "content"}, {Limit: -1}, {Offset: -1}}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", opts)
+		}
+	}
+}
+
+// listerStorage is a NoteStorage that records the list queries it runs itself
+type listerStorage struct {
+	NoteStorage
+	queries []ListOptions
+}
+
+func (s *listerStorage) List(_ context.Context, opts ListOptions) ([]*model.Note, error) {
+	s.queries = append(s.queries, opts)
+	return []*model.Note{}, nil
+}
+
+// TestList verifies that List delegates to backends implementing Lister,
+// falls back to GetAll otherwise, and passes through decorators
+func TestList(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fallback", func(t *testing.T) {
+		memory := NewInMemoryStorage()
+		for _, note := range queryTestNotes() {
+			if err := memory.Create(ctx, note); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+		notes, err := List(ctx, memory, ListOptions{Query: "apple", Sort: SortTitle})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if got := noteTitles(notes); !reflect.DeepEqual(got, []string{"Apple juice", "apple pie"}) {
+			t.Errorf("Expected the matching notes sorted by title, got %v", got)
+		}
+	})
+
+	t.Run("Lister", func(t *testing.T) {
+		captureLog(t)
+		lister := &listerStorage{NoteStorage: NewInMemoryStorage()}
+		decorated := NewLoggingStorage(NewTracingStorage(NewCircuitBreakerStorage(NewSwitchableStorage(lister), "test-list", 3, time.Second), "test"), "test", false)
+
+		opts := ListOptions{Query: "apple", Limit: 5}
+		if _, err := List(ctx, decorated, opts); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if !reflect.DeepEqual(lister.queries, []ListOptions{opts}) {
+			t.Errorf("Expected the query to reach the backend, got %+v", lister.queries)
+		}
+	})
+
+	t.Run("MostViewed", func(t *testing.T) {
+		lister := &listerStorage{NoteStorage: NewInMemoryStorage()}
+		opts := ListOptions{Query: "apple", Sort: SortMostViewed, Limit: 5, Offset: 1}
+		if _, err := List(ctx, lister, opts); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if !reflect.DeepEqual(lister.queries, []ListOptions{{Query: "apple"}}) {
+			t.Errorf("Expected every matching note to be listed by the backend, got %+v", lister.queries)
+		}
+
+		notes := queryTestNotes()
+		for i, note := range notes {
+			note.Views = int64(i % 3)
+		}
+		got := noteTitles(ListOptions{Sort: SortMostViewed, Limit: 2}.Apply(notes))
+		if !reflect.DeepEqual(got, []string{"Cherry", "apple pie"}) {
+			t.Errorf("Expected the most viewed notes first, got %v", got)
+		}
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		lister := &listerStorage{NoteStorage: NewInMemoryStorage()}
+		if _, err := List(ctx, lister, ListOptions{Sort: "content"}); err == nil {
+			t.Error("Expected an error for an unknown sort field")
+		}
+		if len(lister.queries) != 0 {
+			t.Errorf("Expected invalid queries not to reach the backend, got %+v", lister.queries)
+		}
+	})
+}
+
+// TestStreamList verifies that unsorted lists are streamed and filtered, and that the
+// others are listed in the order of the query
+func TestStreamList(t *testing.T) {
+	ctx := context.Background()
+	memory := NewInMemoryStorage()
+	for _, note := range queryTestNotes() {
+		if err := memory.Create(ctx, note); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+	collect := func(opts ListOptions) []string {
+		t.Helper()
+		var notes []*model.Note
+		err := StreamList(ctx, memory, opts, func(note *model.Note) error {
+			notes = append(notes, note)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamList failed: %v", err)
+		}
+		return noteTitles(notes)
+	}
+
+	got := collect(ListOptions{Query: "apple"})
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"Apple juice", "apple pie"}) {
+		t.Errorf("Expected the matching notes, got %v", got)
+	}
+	if got := collect(ListOptions{Sort: SortCreatedAt, Descending: true, Limit: 2}); !reflect.DeepEqual(got, []string{"Apple juice", "Cherry"}) {
+		t.Errorf("Expected the first page in the order of the query, got %v", got)
+	}
+	if err := StreamList(ctx, memory, ListOptions{Limit: -1}, nil); err == nil {
+		t.Error("Expected an error for invalid options")
+	}
+}
+
+// counterStorage is a NoteStorage that records the count queries it runs itself
+type counterStorage struct {
+	NoteStorage
+	queries []ListOptions
+}
+
+func (s *counterStorage) Count(_ context.Context, opts ListOptions) (int, error) {
+	s.queries = append(s.queries, opts)
+	return 42, nil
+}
+
+// TestCount verifies that Count delegates to backends implementing Counter,
+// falls back to listing the notes otherwise, and ignores pagination
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fallback", func(t *testing.T) {
+		memory := NewInMemoryStorage()
+		for _, note := range queryTestNotes() {
+			if err := memory.Create(ctx, note); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+		count, err := Count(ctx, memory, ListOptions{Query: "apple", Limit: 1, Offset: 1})
+		if err != nil || count != 2 {
+			t.Errorf("Expected 2 matching notes, got %d, %v", count, err)
+		}
+		if count, err := Count(ctx, memory, ListOptions{}); err != nil || count != 4 {
+			t.Errorf("Expected 4 notes, got %d, %v", count, err)
+		}
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		captureLog(t)
+		counter := &counterStorage{NoteStorage: NewInMemoryStorage()}
+		decorated := NewLoggingStorage(NewTracingStorage(NewCircuitBreakerStorage(NewSwitchableStorage(counter), "test-count", 3, time.Second), "test"), "test", false)
+
+		count, err := Count(ctx, decorated, ListOptions{Query: "apple", Sort: SortTitle, Limit: 5})
+		if err != nil || count != 42 {
+			t.Fatalf("Expected the backend's count, got %d, %v", count, err)
+		}
+		if !reflect.DeepEqual(counter.queries, []ListOptions{{Query: "apple"}}) {
+			t.Errorf("Expected only the query to reach the backend, got %+v", counter.queries)
+		}
+	})
+}
+
+// TestCouchQuery verifies the Mango queries built for list options
+func TestCouchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ListOptions
+		want string
+	}{
+		{
+			"All",
+			ListOptions{},
+			`{"limit":2147483647,"selector":{"_id":{"$gt":null}}}`,
+		},
+		{
+			"SortedPage",
+			ListOptions{Sort: SortCreatedAt, Descending: true, Limit: 10, Offset: 20},
+			`{"limit":10,"selector":{"created_at":{"$gt":null}},"skip":20,"sort":[{"created_at":"desc"}]}`,
+		},
+		{
+			"Query",
+			ListOptions{Query: "1.2", Sort: SortTitle},
+			`{"limit":2147483647,"selector":{"$or":[{"title":{"$regex":"1\\.2"}},{"content":{"$regex":"1\\.2"}}],"title":{"$gt":null}},"sort":[{"title":"asc"}]}`,
+		},
+		{
+			"Filter",
+			ListOptions{Filter: &Filter{field: "owner", op: filterEqual, value: "alice"}},
+			`{"limit":2147483647,"selector":{"$and":[{"owner":{"$eq":"alice"}}],"_id":{"$gt":null}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(couchQuery(tt.opts))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
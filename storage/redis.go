@@ -0,0 +1,255 @@
+// This file contains a Redis-backed cache for CachedStorage, shared by all instances of the application.
+// Each instance may also keep a local LRU cache in front of Redis; invalidations are published
+// over Redis pub/sub, so that every instance drops the affected local entries too.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"golang-simple-notes/model"
+)
+
+// Redis key namespace and invalidation channel of the cache.
+const (
+	redisCacheKeyPrefix = "notes:cache:"
+	redisCacheChannel   = "notes:cache:invalidations"
+)
+
+// redisCacheTimeout limits connecting to Redis at startup.
+const redisCacheTimeout = 5 * time.Second
+
+// redisScanCount is the number of keys Redis examines per SCAN call when deleting by prefix.
+const redisScanCount = 100
+
+// RedisCache implements Cache with Redis, so cached notes are shared by all instances
+// of the application. Redis errors are logged and treated as cache misses: the cache
+// only reduces the load on the storage backend, it never makes requests fail.
+//
+// With a local cache, reads are served from memory when possible. Every deletion is
+// published to the other instances, which delete the same entries from their local caches.
+// Invalidations published while an instance is disconnected from Redis are missed;
+// its local entries then expire after their TTL.
+type RedisCache struct {
+	client   *redis.Client
+	ttl      time.Duration // How long entries are kept in Redis
+	local    *LRUCache     // Local cache in front of Redis (optional)
+	instance string        // ID of this instance, to ignore its own invalidations
+
+	pubsub *redis.PubSub // Subscription to invalidations, if there is a local cache
+	done   chan struct{} // Closed when the invalidation listener has stopped
+}
+
+// redisInvalidation is the message published when cache entries are deleted.
+type redisInvalidation struct {
+	Instance string   `json:"instance"`           // Instance that deleted the entries
+	Keys     []string `json:"keys,omitempty"`     // Deleted keys
+	Prefixes []string `json:"prefixes,omitempty"` // Prefixes of deleted keys
+}
+
+// NewRedisCache connects to Redis and creates a new Redis-backed cache.
+//
+// Parameters:
+//   - ctx: The context for connecting to Redis
+//   - url: The URL of the Redis server (e.g., "redis://localhost:6379/0")
+//   - ttl: How long entries are kept
+//   - local: A local cache in front of Redis, or nil to always read from Redis
+//
+// Returns:
+//   - A pointer to a new RedisCache instance
+//   - An error if the URL is invalid or Redis cannot be reached
+func NewRedisCache(ctx context.Context, url string, ttl time.Duration, local *LRUCache) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(ctx, redisCacheTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	c := &RedisCache{
+		client:   client,
+		ttl:      ttl,
+		local:    local,
+		instance: newInstanceID(),
+		done:     make(chan struct{}),
+	}
+
+	// Without a local cache, there is nothing to invalidate on this instance
+	if local == nil {
+		close(c.done)
+		return c, nil
+	}
+	c.pubsub = client.Subscribe(ctx, redisCacheChannel)
+	if _, err := c.pubsub.Receive(ctx); err != nil {
+		_ = c.pubsub.Close()
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+	}
+	go c.listen()
+	return c, nil
+}
+
+// Get returns the notes stored under the key, from the local cache if possible.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]*model.Note, bool) {
+	if c.local != nil {
+		if notes, ok := c.local.Get(ctx, key); ok {
+			return notes, true
+		}
+	}
+
+	data, err := c.client.Get(ctx, redisCacheKeyPrefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Redis cache: failed to get %s: %v", key, err)
+		}
+		return nil, false
+	}
+	var notes []*model.Note
+	if err := json.Unmarshal(data, &notes); err != nil {
+		log.Printf("Redis cache: failed to decode %s: %v", key, err)
+		return nil, false
+	}
+
+	if c.local != nil {
+		c.local.Set(ctx, key, notes)
+	}
+	return notes, true
+}
+
+// Set stores notes under the key in Redis and in the local cache.
+func (c *RedisCache) Set(ctx context.Context, key string, notes []*model.Note) {
+	data, err := json.Marshal(notes)
+	if err != nil {
+		log.Printf("Redis cache: failed to encode %s: %v", key, err)
+		return
+	}
+	if err := c.client.Set(ctx, redisCacheKeyPrefix+key, data, c.ttl).Err(); err != nil {
+		log.Printf("Redis cache: failed to set %s: %v", key, err)
+		return
+	}
+	if c.local != nil {
+		c.local.Set(ctx, key, notes)
+	}
+}
+
+// Delete removes the entries with the given keys, and tells the other instances to do the same.
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	if c.local != nil {
+		c.local.Delete(ctx, keys...)
+	}
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = redisCacheKeyPrefix + key
+	}
+	if err := c.client.Del(ctx, redisKeys...).Err(); err != nil {
+		log.Printf("Redis cache: failed to delete %v: %v", keys, err)
+	}
+	c.publish(ctx, redisInvalidation{Keys: keys})
+}
+
+// DeletePrefix removes all entries whose key starts with prefix, and tells the other instances to do the same.
+func (c *RedisCache) DeletePrefix(ctx context.Context, prefix string) {
+	if c.local != nil {
+		c.local.DeletePrefix(ctx, prefix)
+	}
+
+	iter := c.client.Scan(ctx, 0, redisCacheKeyPrefix+escapeRedisPattern(prefix)+"*", redisScanCount).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Redis cache: failed to find keys with prefix %q: %v", prefix, err)
+	}
+	if len(keys) > 0 {
+		if err := c.client.Del(ctx, keys...).Err(); err != nil {
+			log.Printf("Redis cache: failed to delete keys with prefix %q: %v", prefix, err)
+		}
+	}
+	c.publish(ctx, redisInvalidation{Prefixes: []string{prefix}})
+}
+
+// Close stops listening for invalidations and closes the connection to Redis.
+func (c *RedisCache) Close() error {
+	if c.pubsub != nil {
+		_ = c.pubsub.Close()
+	}
+	<-c.done
+	return c.client.Close()
+}
+
+// publish tells the other instances about deleted entries.
+func (c *RedisCache) publish(ctx context.Context, invalidation redisInvalidation) {
+	invalidation.Instance = c.instance
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		log.Printf("Redis cache: failed to encode invalidation: %v", err)
+		return
+	}
+	if err := c.client.Publish(ctx, redisCacheChannel, data).Err(); err != nil {
+		log.Printf("Redis cache: failed to publish invalidation: %v", err)
+	}
+}
+
+// listen applies the invalidations published by other instances to the local cache,
+// until the subscription is closed.
+func (c *RedisCache) listen() {
+	defer close(c.done)
+	ctx := context.Background()
+
+	for msg := range c.pubsub.Channel() {
+		var invalidation redisInvalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+			log.Printf("Redis cache: failed to decode invalidation: %v", err)
+			continue
+		}
+		if invalidation.Instance == c.instance {
+			continue
+		}
+		c.local.Delete(ctx, invalidation.Keys...)
+		for _, prefix := range invalidation.Prefixes {
+			c.local.DeletePrefix(ctx, prefix)
+		}
+	}
+}
+
+// escapeRedisPattern escapes the characters that have a special meaning in Redis glob patterns.
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// newInstanceID returns a random ID identifying this instance in invalidation messages.
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the time; IDs only need to differ between running instances
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
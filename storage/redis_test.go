@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"golang-simple-notes/model"
+)
+
+// startRedis starts a Redis container for the test, skipping the test if Docker is unavailable.
+//
+// Returns:
+//   - The URL of the Redis server
+func startRedis(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping Redis test in short mode")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7-alpine",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Skipf("Failed to start Redis container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("Failed to terminate Redis container: %v", err)
+		}
+	})
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get Redis container host: %v", err)
+	}
+	port, err := container.MappedPort(ctx, "6379/tcp")
+	if err != nil {
+		t.Fatalf("Failed to get Redis container port: %v", err)
+	}
+	return fmt.Sprintf("redis://%s:%s/0", host, port.Port())
+}
+
+func TestRedisCache(t *testing.T) {
+	url := startRedis(t)
+	ctx := context.Background()
+
+	newCache := func(t *testing.T) *RedisCache {
+		c, err := NewRedisCache(ctx, url, time.Minute, NewLRUCache(10, time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to create Redis cache: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := c.Close(); err != nil {
+				t.Errorf("Failed to close Redis cache: %v", err)
+			}
+		})
+		return c
+	}
+	first, second := newCache(t), newCache(t)
+
+	t.Run("SharedEntries", func(t *testing.T) {
+		first.Set(ctx, "note:1", []*model.Note{{ID: "1", Title: "Shared"}})
+
+		notes, ok := second.Get(ctx, "note:1")
+		if !ok || len(notes) != 1 || notes[0].Title != "Shared" {
+			t.Fatalf("Expected the entry set by another instance, got %v (found: %t)", notes, ok)
+		}
+	})
+
+	t.Run("InvalidationReachesOtherInstances", func(t *testing.T) {
+		first.Set(ctx, "note:2", []*model.Note{{ID: "2", Title: "Cached"}})
+		first.Set(ctx, "list:all", []*model.Note{{ID: "2", Title: "Cached"}})
+		// Load both entries into the local cache of the second instance
+		second.Get(ctx, "note:2")
+		second.Get(ctx, "list:all")
+
+		first.Delete(ctx, "note:2")
+		first.DeletePrefix(ctx, "list:")
+
+		deadline := time.Now().Add(5 * time.Second)
+		for second.local.Len() > 1 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, ok := second.Get(ctx, "note:2"); ok {
+			t.Error("Expected the deleted note to be invalidated on the other instance")
+		}
+		if _, ok := second.Get(ctx, "list:all"); ok {
+			t.Error("Expected the deleted list to be invalidated on the other instance")
+		}
+		if _, ok := second.Get(ctx, "note:1"); !ok {
+			t.Error("Expected other entries to stay cached")
+		}
+	})
+
+	t.Run("WithoutLocalCache", func(t *testing.T) {
+		c, err := NewRedisCache(ctx, url, time.Minute, nil)
+		if err != nil {
+			t.Fatalf("Failed to create Redis cache: %v", err)
+		}
+		defer func() { _ = c.Close() }()
+
+		if notes, ok := c.Get(ctx, "note:1"); !ok || notes[0].Title != "Shared" {
+			t.Errorf("Expected the shared entry, got %v (found: %t)", notes, ok)
+		}
+	})
+}
+
+func TestNewRedisCacheErrors(t *testing.T) {
+	if _, err := NewRedisCache(context.Background(), "http://localhost:6379", time.Minute, nil); err == nil {
+		t.Error("Expected an error for a URL with the wrong scheme")
+	}
+	// Nothing listens on port 1
+	if _, err := NewRedisCache(context.Background(), "redis://127.0.0.1:1/0", time.Minute, nil); err == nil {
+		t.Error("Expected an error when Redis is unreachable")
+	}
+}
+
+func TestEscapeRedisPattern(t *testing.T) {
+	tests := map[string]string{
+		"list:":       "list:",
+		"":            "",
+		"list:*?":     `list:\*\?`,
+		`a[b]\c`:      `a\[b\]\\c`,
+		"note:ümlaut": "note:ümlaut",
+	}
+	for in, want := range tests {
+		if got := escapeRedisPattern(in); got != want {
+			t.Errorf("escapeRedisPattern(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// This file contains a replicated storage composite, which mirrors writes from a primary
+// backend to a secondary one asynchronously, for migrations and disaster recovery.
+// Unlike DualWriteStorage, writes don't wait for the secondary; a periodic reconciliation
+// pass repairs whatever asynchronous mirroring missed (dropped or failed writes).
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang-simple-notes/metrics"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// replicationWriteTimeout limits applying a single write to the secondary backend.
+const replicationWriteTimeout = 10 * time.Second
+
+// replicationWrite is a write waiting to be mirrored to the secondary backend.
+type replicationWrite struct {
+	op        string      // "Create", "Update", "Upsert", or "Delete"
+	id        string      // ID of the written note
+	note      *model.Note // Copy of the written note (nil for deletions)
+	requestID string      // Request that performed the write
+	writtenAt time.Time   // When the write was made on the primary
+}
+
+// ReconcileResult counts the notes a reconciliation pass repaired on the secondary backend.
+type ReconcileResult struct {
+	Created int `json:"created"` // Notes missing on the secondary
+	Updated int `json:"updated"` // Notes whose content differed
+	Deleted int `json:"deleted"` // Notes that exist only on the secondary
+}
+
+// ReplicatedStorage implements NoteStorage with a primary backend that serves all
+// operations, and a secondary backend that receives a copy of every write in the background.
+//
+// Writes are mirrored in order by a single worker. If the queue is full, or the secondary
+// fails, the write is counted and left for the next reconciliation pass, which compares
+// all notes on both backends and makes the secondary match the primary.
+type ReplicatedStorage struct {
+	primary   NoteStorage           // Backend that serves all operations and is the source of truth
+	secondary NoteStorage           // Mirror that receives every write asynchronously
+	queue     chan replicationWrite // Writes waiting to be mirrored
+	interval  time.Duration         // Time between reconciliation passes (zero disables them)
+
+	applying sync.Mutex    // Serializes mirroring writes and reconciliation passes
+	stopOnce sync.Once     // Makes stopping idempotent
+	stopping chan struct{} // Closed when the storage is closed
+	done     chan struct{} // Closed when the worker has stopped
+}
+
+// NewReplicatedStorage creates a new replicated storage and starts its background worker.
+//
+// Parameters:
+//   - primary: The storage backend that serves all operations
+//   - secondary: The storage backend that receives a copy of every write
+//   - queueSize: The maximum number of writes waiting to be mirrored
+//   - interval: The time between reconciliation passes (zero disables them)
+//
+// Returns:
+//   - A pointer to a new, running ReplicatedStorage instance
+func NewReplicatedStorage(primary, secondary NoteStorage, queueSize int, interval time.Duration) *ReplicatedStorage {
+	s := &ReplicatedStorage{
+		primary:   primary,
+		secondary: secondary,
+		queue:     make(chan replicationWrite, queueSize),
+		interval:  interval,
+		stopping:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	metrics.ReplicationLag.Set(0)
+	metrics.ReplicationQueueLength.Set(0)
+	go s.run()
+	return s
+}
+
+// Create adds a new note to the primary backend and queues it for the secondary.
+func (s *ReplicatedStorage) Create(ctx context.Context, note *model.Note) error {
+	if err := s.primary.Create(ctx, note); err != nil {
+		return err
+	}
+	s.enqueue(ctx, "Create", note.ID, note)
+	return nil
+}
+
+// Get retrieves a note from the primary backend.
+func (s *ReplicatedStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	return s.primary.Get(ctx, id)
+}
+
+// GetAll retrieves all notes from the primary backend.
+func (s *ReplicatedStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	return s.primary.GetAll(ctx)
+}
+
+// List runs a list query on the primary backend.
+func (s *ReplicatedStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	return List(ctx, s.primary, opts)
+}
+
+// Exists checks whether a note exists in the primary backend.
+func (s *ReplicatedStorage) Exists(ctx context.Context, id string) (bool, error) {
+	return Exists(ctx, s.primary, id)
+}
+
+// GetMany retrieves notes by ID from the primary backend.
+func (s *ReplicatedStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	return GetMany(ctx, s.primary, ids)
+}
+
+// Count counts the matching notes in the primary backend.
+func (s *ReplicatedStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	return Count(ctx, s.primary, opts)
+}
+
+// Stats returns statistics about the notes in the primary backend.
+func (s *ReplicatedStorage) Stats(ctx context.Context) (NoteStats, error) {
+	return Stats(ctx, s.primary)
+}
+
+// Maintain runs a maintenance task on the primary backend only; the secondary is
+// maintained on its own.
+func (s *ReplicatedStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	return Maintain(ctx, s.primary, task)
+}
+
+// RunInTransaction runs fn in a transaction of the primary backend. The writes of an
+// aborted transaction are still queued for the secondary; reconciliation repairs them.
+func (s *ReplicatedStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTransaction(ctx, s.primary, fn)
+}
+
+// Stream reads all notes from the primary backend one at a time.
+func (s *ReplicatedStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	return Stream(ctx, s.primary, fn)
+}
+
+// Update updates a note on the primary backend and queues it for the secondary.
+func (s *ReplicatedStorage) Update(ctx context.Context, note *model.Note) error {
+	if err := s.primary.Update(ctx, note); err != nil {
+		return err
+	}
+	s.enqueue(ctx, "Update", note.ID, note)
+	return nil
+}
+
+// UpdateIf updates a note on the primary backend if it is unchanged there, and queues
+// the update for the secondary.
+func (s *ReplicatedStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	if err := UpdateIf(ctx, s.primary, note, expectedUpdatedAt); err != nil {
+		return err
+	}
+	s.enqueue(ctx, "Update", note.ID, note)
+	return nil
+}
+
+// Upsert stores a note on the primary backend, creating it if needed, and queues it for
+// the secondary.
+func (s *ReplicatedStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	created, err := Upsert(ctx, s.primary, note)
+	if err != nil {
+		return false, err
+	}
+	s.enqueue(ctx, "Upsert", note.ID, note)
+	return created, nil
+}
+
+// Delete removes a note from the primary backend and queues the deletion for the secondary.
+func (s *ReplicatedStorage) Delete(ctx context.Context, id string) error {
+	if err := s.primary.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.enqueue(ctx, "Delete", id, nil)
+	return nil
+}
+
+// Ping checks the primary backend. The secondary is not required: it catches up when it is back.
+func (s *ReplicatedStorage) Ping(ctx context.Context) error {
+	return s.primary.Ping(ctx)
+}
+
+// Close mirrors the writes still in the queue, unless ctx expires first, and closes both backends.
+func (s *ReplicatedStorage) Close(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stopping)
+	})
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		log.Printf("replication: %d writes not mirrored before shutdown", len(s.queue))
+	}
+	return errors.Join(s.primary.Close(ctx), s.secondary.Close(ctx))
+}
+
+// Reconcile compares all notes on both backends and makes the secondary match the primary.
+// Mirroring is paused during the pass, so queued writes are applied after it, in order.
+//
+// Returns:
+//   - The number of notes created, updated, and deleted on the secondary
+//   - An error if a backend cannot be read; errors repairing single notes are logged and skipped
+func (s *ReplicatedStorage) Reconcile(ctx context.Context) (ReconcileResult, error) {
+	s.applying.Lock()
+	defer s.applying.Unlock()
+
+	var result ReconcileResult
+	primaryNotes, err := s.primary.GetAll(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to read the primary: %w", err)
+	}
+	secondaryNotes, err := s.secondary.GetAll(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to read the secondary: %w", err)
+	}
+
+	mirrored := make(map[string]*model.Note, len(secondaryNotes))
+	for _, note := range secondaryNotes {
+		mirrored[note.ID] = note
+	}
+
+	for _, note := range primaryNotes {
+		existing, ok := mirrored[note.ID]
+		delete(mirrored, note.ID)
+		switch {
+		case !ok:
+			if err := s.secondary.Create(ctx, mirrorCopy(note)); err != nil {
+				log.Printf("replication: creating %s on the secondary failed: %v", note.ID, err)
+				continue
+			}
+			result.Created++
+			metrics.ReplicationRepairs.WithLabelValues("created").Inc()
+		case NoteHash(existing) != NoteHash(note):
+			if err := s.secondary.Update(ctx, mirrorCopy(note)); err != nil {
+				log.Printf("replication: updating %s on the secondary failed: %v", note.ID, err)
+				continue
+			}
+			result.Updated++
+			metrics.ReplicationRepairs.WithLabelValues("updated").Inc()
+		}
+	}
+
+	// What remains exists only on the secondary
+	for id := range mirrored {
+		if err := s.secondary.Delete(ctx, id); err != nil && !errors.Is(err, ErrNoteNotFound) {
+			log.Printf("replication: deleting %s on the secondary failed: %v", id, err)
+			continue
+		}
+		result.Deleted++
+		metrics.ReplicationRepairs.WithLabelValues("deleted").Inc()
+	}
+	return result, nil
+}
+
+// enqueue queues a write for the secondary without blocking. If the queue is full,
+// the write is dropped and left for the next reconciliation pass.
+func (s *ReplicatedStorage) enqueue(ctx context.Context, op, id string, note *model.Note) {
+	write := replicationWrite{op: op, id: id, requestID: requestid.FromContext(ctx), writtenAt: time.Now()}
+	if note != nil {
+		write.note = mirrorCopy(note)
+	}
+
+	select {
+	case s.queue <- write:
+		metrics.ReplicationQueueLength.Set(float64(len(s.queue)))
+	default:
+		log.Printf("%sreplication: queue full, %s %s left for reconciliation", requestid.LogPrefix(ctx), op, id)
+		metrics.ReplicationWrites.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run mirrors queued writes and runs reconciliation passes until the storage is closed,
+// then mirrors the writes left in the queue.
+func (s *ReplicatedStorage) run() {
+	defer close(s.done)
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case write := <-s.queue:
+			s.replicate(write)
+		case <-tick:
+			s.reconcile()
+		case <-s.stopping:
+			for {
+				select {
+				case write := <-s.queue:
+					s.replicate(write)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// replicate applies a queued write to the secondary and updates the replication metrics.
+func (s *ReplicatedStorage) replicate(write replicationWrite) {
+	s.applying.Lock()
+	defer s.applying.Unlock()
+
+	ctx, cancel := context.WithTimeout(requestid.NewContext(context.Background(), write.requestID), replicationWriteTimeout)
+	defer cancel()
+
+	if err := s.apply(ctx, write); err != nil {
+		log.Printf("%sreplication: %s %s on the secondary failed: %v", requestid.LogPrefix(ctx), write.op, write.id, err)
+		metrics.ReplicationWrites.WithLabelValues("failed").Inc()
+	} else {
+		metrics.ReplicationWrites.WithLabelValues("applied").Inc()
+	}
+
+	// The lag is how far behind the secondary is: zero once the queue is drained
+	pending := len(s.queue)
+	metrics.ReplicationQueueLength.Set(float64(pending))
+	if pending == 0 {
+		metrics.ReplicationLag.Set(0)
+	} else {
+		metrics.ReplicationLag.Set(time.Since(write.writtenAt).Seconds())
+	}
+}
+
+// apply performs a write on the secondary. Creates and updates are applied as upserts,
+// and deleting a missing note succeeds, so that writes can be replayed after a
+// reconciliation pass or a failure without errors.
+func (s *ReplicatedStorage) apply(ctx context.Context, write replicationWrite) error {
+	if write.op == "Delete" {
+		if err := s.secondary.Delete(ctx, write.id); err != nil && !errors.Is(err, ErrNoteNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	note := *write.note
+	err := s.secondary.Update(ctx, &note)
+	if errors.Is(err, ErrNoteNotFound) {
+		note = *write.note
+		err = s.secondary.Create(ctx, &note)
+	}
+	return err
+}
+
+// reconcile runs a scheduled reconciliation pass and logs its outcome.
+func (s *ReplicatedStorage) reconcile() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+	defer cancel()
+
+	result, err := s.Reconcile(ctx)
+	if err != nil {
+		log.Printf("replication: reconciliation failed: %v", err)
+		return
+	}
+	if result != (ReconcileResult{}) {
+		log.Printf("replication: reconciliation repaired the secondary: %d created, %d updated, %d deleted",
+			result.Created, result.Updated, result.Deleted)
+	}
+}
+
+// mirrorCopy returns a copy of a note to write to the secondary; revisions are backend-specific.
+func mirrorCopy(note *model.Note) *model.Note {
+	mirrored := *note
+	mirrored.Rev = ""
+	return &mirrored
+}
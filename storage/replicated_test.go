@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// waitForNote polls a storage until the note satisfies the condition (nil if missing) or the test times out
+func waitForNote(t *testing.T, s NoteStorage, id string, done func(*model.Note) bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		note, err := s.Get(context.Background(), id)
+		if err != nil && !errors.Is(err, ErrNoteNotFound) {
+			t.Fatalf("Failed to get note %s: %v", id, err)
+		}
+		if done(note) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for note %s, last state: %+v", id, note)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestReplicatedStorageMirrorsWrites verifies that writes reach the secondary in the background
+func TestReplicatedStorageMirrorsWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	storage := NewReplicatedStorage(primary, secondary, 100, 0)
+	defer func() { _ = storage.Close(ctx) }()
+
+	note := model.NewNote("Title", "Content")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	waitForNote(t, secondary, note.ID, func(n *model.Note) bool { return n != nil })
+
+	note.Title = "Updated"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	waitForNote(t, secondary, note.ID, func(n *model.Note) bool { return n != nil && n.Title == "Updated" })
+
+	if err := storage.Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	waitForNote(t, secondary, note.ID, func(n *model.Note) bool { return n == nil })
+}
+
+// TestReplicatedStorageUpsert verifies that an update of a note missing on the secondary creates it there
+func TestReplicatedStorageUpsert(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	note := model.NewNote("Title", "Content")
+	if err := primary.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	storage := NewReplicatedStorage(primary, secondary, 100, 0)
+	note.Title = "Updated"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	// Closing mirrors the queued writes
+	if err := storage.Close(ctx); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+	if mirrored, err := secondary.Get(ctx, note.ID); err != nil || mirrored.Title != "Updated" {
+		t.Errorf("Expected the updated note on the secondary, got %+v (error: %v)", mirrored, err)
+	}
+}
+
+// TestReplicatedStorageReconcile verifies that reconciliation makes the secondary match the primary
+func TestReplicatedStorageReconcile(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	storage := NewReplicatedStorage(primary, secondary, 100, 0)
+	defer func() { _ = storage.Close(ctx) }()
+
+	missing := model.NewNote("Missing", "Only on the primary")
+	stale := model.NewNote("Stale", "Content")
+	extra := model.NewNote("Extra", "Only on the secondary")
+	for _, note := range []*model.Note{missing, stale} {
+		if err := primary.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	staleCopy := *stale
+	staleCopy.Content = "Old content"
+	for _, note := range []*model.Note{&staleCopy, extra} {
+		if err := secondary.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	result, err := storage.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if result != (ReconcileResult{Created: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("Unexpected reconciliation result: %+v", result)
+	}
+
+	for _, note := range []*model.Note{missing, stale} {
+		mirrored, err := secondary.Get(ctx, note.ID)
+		if err != nil || NoteHash(mirrored) != NoteHash(note) {
+			t.Errorf("Expected %q to match on the secondary, got %+v (error: %v)", note.Title, mirrored, err)
+		}
+	}
+	if _, err := secondary.Get(ctx, extra.ID); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected the extra note to be deleted from the secondary, got %v", err)
+	}
+
+	// A second pass finds nothing to repair
+	if result, err := storage.Reconcile(ctx); err != nil || result != (ReconcileResult{}) {
+		t.Errorf("Expected nothing to repair, got %+v (error: %v)", result, err)
+	}
+
+	// A secondary that cannot be read fails the pass
+	failing := NewReplicatedStorage(primary, &failingStorage{}, 100, 0)
+	defer func() { _ = failing.Close(ctx) }()
+	if _, err := failing.Reconcile(ctx); !errors.Is(err, errFailingStorage) {
+		t.Errorf("Expected the secondary error, got %v", err)
+	}
+}
+
+// TestReplicatedStorageScheduledReconcile verifies that reconciliation runs periodically
+func TestReplicatedStorageScheduledReconcile(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewInMemoryStorage(), NewInMemoryStorage()
+	note := model.NewNote("Title", "Written before replication started")
+	if err := primary.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	storage := NewReplicatedStorage(primary, secondary, 100, 10*time.Millisecond)
+	defer func() { _ = storage.Close(ctx) }()
+
+	waitForNote(t, secondary, note.ID, func(n *model.Note) bool { return n != nil })
+}
@@ -0,0 +1,109 @@
+// This file contains the retry policy shared by the storage backend constructors.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how often and how long an operation, such as connecting to a
+// storage backend, is retried. Delays between attempts grow exponentially with random
+// jitter, so that many instances starting at once don't retry in lockstep.
+// The zero value makes a single attempt without time limits.
+type RetryPolicy struct {
+	MaxAttempts    int           // Maximum number of attempts (values below 1 mean a single attempt)
+	InitialDelay   time.Duration // Delay after the first failed attempt, doubled after every further one
+	MaxDelay       time.Duration // Upper bound of the delay between attempts (zero means no bound)
+	AttemptTimeout time.Duration // Maximum duration of a single attempt (zero means no limit)
+	Deadline       time.Duration // Maximum total duration of all attempts and delays (zero means no limit)
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured. It waits
+// up to about a minute, which covers backends started at the same time as the
+// application (e.g., with Docker Compose).
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    10,
+		InitialDelay:   500 * time.Millisecond,
+		MaxDelay:       5 * time.Second,
+		AttemptTimeout: 10 * time.Second,
+		Deadline:       time.Minute,
+	}
+}
+
+// Retry calls fn until it succeeds, the maximum number of attempts is reached,
+// the deadline passes, or the context is canceled. Each call receives a context
+// limited by the attempt timeout.
+//
+// Parameters:
+//   - ctx: The context that cancels the retries
+//   - operation: A description of the operation for log and error messages (e.g., "connect to CouchDB")
+//   - fn: The operation to attempt
+//
+// Returns:
+//   - nil if an attempt succeeded
+//   - The error of the last attempt otherwise, wrapped with the number of attempts
+func (p RetryPolicy) Retry(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = p.attempt(ctx, fn); err == nil {
+			return nil
+		}
+		if attempt == maxAttempts {
+			return fmt.Errorf("failed to %s after %d attempts: %w", operation, attempt, err)
+		}
+		// Neither log nor wait for another attempt if the context is done already
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to %s after %d attempts (%w): %w", operation, attempt, context.Cause(ctx), err)
+		}
+
+		delay := p.Delay(attempt)
+		log.Printf("Attempt %d/%d to %s failed: %v; retrying in %v", attempt, maxAttempts, operation, err, delay.Round(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to %s after %d attempts (%w): %w", operation, attempt, context.Cause(ctx), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt calls fn once, limited by the attempt timeout.
+func (p RetryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.AttemptTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// Delay returns how long to wait after the given failed attempt: the initial delay
+// doubled for every previous attempt, capped by the maximum delay, of which a random
+// part of up to one half is subtracted as jitter.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay) && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay - rand.N(delay/2+1)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRetryPolicy verifies attempts, errors, and timeouts of the retry helper
+func TestRetryPolicy(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	ctx := context.Background()
+
+	t.Run("SucceedsAfterFailures", func(t *testing.T) {
+		captureLog(t)
+		policy := RetryPolicy{MaxAttempts: 5, InitialDelay: time.Millisecond}
+		attempts := 0
+		err := policy.Retry(ctx, "connect", func(context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errUnavailable
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("Expected success after 3 attempts, got %d attempts and %v", attempts, err)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		output := captureLog(t)
+		policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+		attempts := 0
+		err := policy.Retry(ctx, "connect", func(context.Context) error {
+			attempts++
+			return errUnavailable
+		})
+		if !errors.Is(err, errUnavailable) || attempts != 3 {
+			t.Errorf("Expected the last error after 3 attempts, got %d attempts and %v", attempts, err)
+		}
+		if err.Error() != "failed to connect after 3 attempts: unavailable" {
+			t.Errorf("Unexpected error message: %v", err)
+		}
+		if want := "Attempt 2/3 to connect failed: unavailable"; !strings.Contains(output.String(), want) {
+			t.Errorf("Expected failed attempts to be logged, got:\n%s", output.String())
+		}
+	})
+
+	t.Run("ZeroValue", func(t *testing.T) {
+		attempts := 0
+		_ = RetryPolicy{}.Retry(ctx, "connect", func(context.Context) error {
+			attempts++
+			return errUnavailable
+		})
+		if attempts != 1 {
+			t.Errorf("Expected a single attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("AttemptTimeout", func(t *testing.T) {
+		policy := RetryPolicy{AttemptTimeout: 10 * time.Millisecond}
+		err := policy.Retry(ctx, "connect", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the attempt to time out, got %v", err)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		captureLog(t)
+		policy := RetryPolicy{MaxAttempts: 100, InitialDelay: 20 * time.Millisecond, Deadline: 50 * time.Millisecond}
+		start := time.Now()
+		err := policy.Retry(ctx, "connect", func(context.Context) error { return errUnavailable })
+		if !errors.Is(err, errUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline and the last error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected retries to stop at the deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		captureLog(t)
+		ctx, cancel := context.WithCancel(ctx)
+		policy := RetryPolicy{MaxAttempts: 100, InitialDelay: time.Hour}
+		err := policy.Retry(ctx, "connect", func(context.Context) error {
+			cancel()
+			return errUnavailable
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the retries to be canceled, got %v", err)
+		}
+	})
+}
+
+// TestRetryPolicyDelay verifies exponential growth, the upper bound, and the jitter of delays
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tc := range tests {
+		for range 20 {
+			delay := policy.Delay(tc.attempt)
+			if delay < tc.base/2 || delay > tc.base {
+				t.Errorf("Attempt %d: expected a delay between %v and %v, got %v", tc.attempt, tc.base/2, tc.base, delay)
+			}
+		}
+	}
+
+	// Without an upper bound, delays keep growing without overflowing
+	unbounded := RetryPolicy{InitialDelay: time.Second}
+	if delay := unbounded.Delay(200); delay <= 0 {
+		t.Errorf("Expected a positive delay, got %v", delay)
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// snapshotter saves the notes of an InMemoryStorage to a file, periodically and when the
+// storage is closed, so that they survive restarts and crashes (losing at most the writes
+// of the last interval). The file is a JSON array of notes, like a JSON export, so it can
+// also be imported into another backend.
+type snapshotter struct {
+	storage  *InMemoryStorage
+	path     string
+	saving   sync.Mutex    // Serializes snapshots
+	saved    uint64        // Version of the storage in the last snapshot (guarded by saving)
+	stopping chan struct{} // Closed by close to stop the periodic snapshots
+	stopOnce sync.Once
+	done     chan struct{} // Closed when the periodic snapshots have stopped
+}
+
+// NewInMemoryStorageWithSnapshots creates an in-memory storage that loads its notes from
+// the snapshot file at path, if it exists, and saves them there every interval (if they
+// changed) and when the storage is closed.
+//
+// Parameters:
+//   - path: The snapshot file; it is replaced atomically, so a crash while saving leaves the previous snapshot
+//   - interval: Time between snapshots; zero saves only when the storage is closed
+//
+// Returns:
+//   - The storage, with the notes of the snapshot
+//   - An error if the snapshot exists but cannot be read
+func NewInMemoryStorageWithSnapshots(path string, interval time.Duration) (*InMemoryStorage, error) {
+	notes, err := readSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewInMemoryStorage()
+	for _, note := range notes {
+		s.notes[note.ID] = note
+		s.index.add(note)
+		s.size += noteSize(note)
+	}
+	s.snapshots = &snapshotter{
+		storage:  s,
+		path:     path,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.snapshots.run(interval)
+	return s, nil
+}
+
+// readSnapshot reads the notes of a snapshot file; a missing file is an empty snapshot.
+func readSnapshot(path string) ([]*model.Note, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var notes []*model.Note
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return notes, nil
+}
+
+// run saves a snapshot every interval until close is called.
+func (s *snapshotter) run(interval time.Duration) {
+	defer close(s.done)
+	if interval <= 0 {
+		<-s.stopping
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				log.Printf("snapshot: %v", err)
+			}
+		case <-s.stopping:
+			return
+		}
+	}
+}
+
+// save writes the notes to the snapshot file, unless they haven't changed since the last
+// snapshot. The notes are copied under the read lock, so writes only wait for the copy.
+func (s *snapshotter) save() error {
+	s.saving.Lock()
+	defer s.saving.Unlock()
+
+	s.storage.mutex.RLock()
+	version := s.storage.version
+	if version == s.saved {
+		s.storage.mutex.RUnlock()
+		return nil
+	}
+	notes := make([]model.Note, 0, len(s.storage.notes))
+	for _, note := range s.storage.notes {
+		notes = append(notes, *note)
+	}
+	s.storage.mutex.RUnlock()
+
+	// Sorted by ID, so that unchanged notes keep their place in the file
+	slices.SortFunc(notes, func(a, b model.Note) int { return strings.Compare(a.ID, b.ID) })
+	data, err := json.Marshal(notes)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	s.saved = version
+	return nil
+}
+
+// close stops the periodic snapshots and saves a last one.
+func (s *snapshotter) close(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stopping)
+	})
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+	}
+	return s.save()
+}
+
+// writeFileAtomic replaces the file at path with data: the data is written to a temporary
+// file in the same directory, synced, and renamed over the file, so readers and crashes see
+// either the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
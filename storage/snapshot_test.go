@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TestInMemoryStorageSnapshots tests that notes are saved to the snapshot file periodically
+// and on Close, and loaded again by the next storage
+func TestInMemoryStorageSnapshots(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.json")
+
+	// A missing snapshot is an empty storage
+	storage, err := NewInMemoryStorageWithSnapshots(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if notes, _ := storage.GetAll(ctx); len(notes) != 0 {
+		t.Fatalf("Expected no notes, got %d", len(notes))
+	}
+
+	note := model.NewNote("Kept", "Survives restarts")
+	if err := storage.Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// The periodic snapshot contains the note before the storage is closed
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		data, err := os.ReadFile(path)
+		var notes []model.Note
+		if err == nil && json.Unmarshal(data, &notes) == nil && len(notes) == 1 && notes[0].ID == note.ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a periodic snapshot with the note, got %q: %v", data, err)
+		}
+	}
+
+	// The last write is saved on Close, even without a periodic snapshot in between
+	note.Content = "Updated before shutdown"
+	if err := storage.Update(ctx, note); err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	if err := storage.Close(ctx); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	restored, err := NewInMemoryStorageWithSnapshots(path, 0)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	defer restored.Close(ctx)
+	got, err := restored.Get(ctx, note.ID)
+	if err != nil {
+		t.Fatalf("Expected the note to be restored: %v", err)
+	}
+	if got.Title != "Kept" || got.Content != "Updated before shutdown" || !got.CreatedAt.Equal(note.CreatedAt) {
+		t.Errorf("Expected the updated note, got %+v", got)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the snapshot file, got %v: %v", entries, err)
+	}
+}
+
+// TestInMemoryStorageSnapshots_Invalid tests that a snapshot that can't be read is reported
+// instead of starting with an empty storage that would overwrite it
+func TestInMemoryStorageSnapshots_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := NewInMemoryStorageWithSnapshots(path, 0); err == nil {
+		t.Error("Expected an error for an invalid snapshot")
+	}
+}
@@ -0,0 +1,81 @@
+// This file contains statistics about the stored notes. Backends that can compute
+// them natively (e.g., with a MongoDB aggregation or a CouchDB view) implement
+// StatsCollector; for the others, Stats reads every note.
+package storage
+
+import (
+	"context"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// Names of the storage backends, as reported in NoteStats.
+const (
+	BackendMemory  = "memory"  // InMemoryStorage
+	BackendCouchDB = "couchdb" // CouchDBStorage
+	BackendMongoDB = "mongodb" // MongoDBStorage
+)
+
+// NoteStats summarizes the notes of a storage backend.
+type NoteStats struct {
+	Storage      string         `json:"storage"`                     // Backend holding the notes (e.g., BackendCouchDB); empty if unknown
+	Notes        int            `json:"notes"`                       // Number of notes
+	ContentBytes int64          `json:"content_bytes"`               // Total size of the note contents in bytes (UTF-8)
+	Oldest       *time.Time     `json:"oldest_created_at,omitempty"` // Creation time of the oldest note; nil without notes
+	Newest       *time.Time     `json:"newest_created_at,omitempty"` // Creation time of the newest note; nil without notes
+	Tags         map[string]int `json:"tags"`                        // Number of notes per tag; nil without tagged notes
+}
+
+// add includes a note in the statistics.
+func (s *NoteStats) add(note *model.Note) {
+	s.Notes++
+	s.ContentBytes += int64(len(note.Content))
+	created := note.CreatedAt
+	if s.Oldest == nil || created.Before(*s.Oldest) {
+		s.Oldest = &created
+	}
+	if s.Newest == nil || created.After(*s.Newest) {
+		s.Newest = &created
+	}
+	for _, tag := range note.Tags {
+		if s.Tags == nil {
+			s.Tags = make(map[string]int)
+		}
+		s.Tags[tag]++
+	}
+}
+
+// StatsCollector is implemented by storage backends that can compute statistics
+// about their notes themselves, rather than returning all notes to be summarized.
+type StatsCollector interface {
+	// Stats returns statistics about all notes.
+	Stats(ctx context.Context) (NoteStats, error)
+}
+
+// Stats returns statistics about the notes of the backend. Backends that implement
+// StatsCollector compute them themselves; for the others, every note is read (one at
+// a time, if the backend implements Streamer), and the backend is reported as unknown.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement StatsCollector
+//
+// Returns:
+//   - The statistics
+//   - An error if the storage operation fails
+func Stats(ctx context.Context, backend NoteReader) (NoteStats, error) {
+	if collector, ok := backend.(StatsCollector); ok {
+		return collector.Stats(ctx)
+	}
+
+	var stats NoteStats
+	err := Stream(ctx, backend, func(note *model.Note) error {
+		stats.add(note)
+		return nil
+	})
+	if err != nil {
+		return NoteStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"maps"
+	"testing"
+)
+
+// TestStats verifies the statistics of the in-memory backend, the fallback for other
+// backends, and the plaintext content size of encrypted storage
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	memory := NewInMemoryStorage()
+	notes := queryTestNotes()
+	notes[0].Tags = []string{"work"}
+	notes[1].Tags = []string{"home", "work"}
+	tags := map[string]int{"home": 1, "work": 2}
+	var contentBytes int64
+	for _, note := range notes {
+		contentBytes += int64(len(note.Content))
+	}
+	oldest, newest := notes[0].CreatedAt, notes[len(notes)-1].CreatedAt
+
+	check := func(t *testing.T, stats NoteStats, storage string) {
+		t.Helper()
+		if stats.Storage != storage || stats.Notes != len(notes) || stats.ContentBytes != contentBytes {
+			t.Errorf("Unexpected statistics: %+v", stats)
+		}
+		if stats.Oldest == nil || !stats.Oldest.Equal(oldest) || stats.Newest == nil || !stats.Newest.Equal(newest) {
+			t.Errorf("Expected creation times %v to %v, got %v to %v", oldest, newest, stats.Oldest, stats.Newest)
+		}
+		if !maps.Equal(stats.Tags, tags) {
+			t.Errorf("Expected notes per tag %v, got %v", tags, stats.Tags)
+		}
+	}
+
+	if stats, err := Stats(ctx, memory); err != nil || stats.Notes != 0 || stats.Oldest != nil || stats.Tags != nil {
+		t.Errorf("Expected no notes, got %+v, %v", stats, err)
+	}
+
+	encrypted := NewEncryptedStorage(NewSwitchableStorage(memory), newTestKeyring(t, "k1"), false)
+	for _, note := range notes {
+		if err := encrypted.Create(ctx, note); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stats, err := Stats(ctx, memory)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		// The backend holds ciphertext, which is larger than the contents
+		if stats.Storage != BackendMemory || stats.Notes != len(notes) || stats.ContentBytes <= contentBytes {
+			t.Errorf("Unexpected statistics of the encrypted notes: %+v", stats)
+		}
+	})
+
+	t.Run("Encrypted", func(t *testing.T) {
+		stats, err := Stats(ctx, encrypted)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		check(t, stats, BackendMemory)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		// Embedding only the interface hides the backend's own Stats method
+		stats, err := Stats(ctx, struct{ NoteStorage }{encrypted})
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		check(t, stats, "")
+	})
+}
@@ -5,9 +5,13 @@
 package storage
 
 import (
+	"container/list"
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"golang-simple-notes/model"
 )
@@ -18,8 +22,19 @@ import (
 var (
 	// ErrNoteNotFound is returned when a note with the specified ID doesn't exist.
 	ErrNoteNotFound = errors.New("note not found")
+
+	// ErrConflict is returned when a note cannot be updated because it was modified concurrently
+	// (e.g., the revision sent by the client is no longer the current one).
+	ErrConflict = errors.New("note was modified concurrently")
 )
 
+// NoteReader is the part of NoteStorage that reads all notes. List and Stream only need
+// this, so they also work on repositories that don't expose the whole interface.
+type NoteReader interface {
+	// GetAll retrieves all notes from the storage.
+	GetAll(ctx context.Context) ([]*model.Note, error)
+}
+
 // NoteStorage defines the interface for note storage operations.
 // Any storage implementation (in-memory, CouchDB, MongoDB) must implement this interface.
 // This allows the application to switch between different storage backends without
@@ -45,6 +60,10 @@ type NoteStorage interface {
 	// It returns ErrNoteNotFound if no note with the specified ID exists.
 	Delete(ctx context.Context, id string) error
 
+	// Ping checks that the storage backend is reachable.
+	// It returns an error if the backend cannot serve requests (e.g., the database is down).
+	Ping(ctx context.Context) error
+
 	// Close closes any resources used by the storage (e.g., database connections).
 	// It should be called when the application is shutting down.
 	Close(ctx context.Context) error
@@ -53,9 +72,24 @@ type NoteStorage interface {
 // InMemoryStorage implements NoteStorage using an in-memory map.
 // This is the simplest storage implementation, useful for development and testing.
 // It stores notes in memory, so they are lost when the application restarts.
+// Like a database, it stores and returns copies of the notes, so callers can't change
+// the stored notes, or race with each other, by modifying the notes they passed or got.
 type InMemoryStorage struct {
-	notes map[string]*model.Note // Map of note ID to note
-	mutex sync.RWMutex           // Mutex to protect concurrent access to the map
+	notes     map[string]*model.Note // Map of note ID to note
+	mutex     sync.RWMutex           // Mutex to protect concurrent access to the map
+	version   uint64                 // Incremented by every write, so unchanged notes aren't saved again
+	snapshots *snapshotter           // Saves the notes to a file; nil unless created with NewInMemoryStorageWithSnapshots
+	index     *textIndex             // Words of the notes, to find the matches of queries (see memoryindex.go)
+
+	// Attachments by note ID and name (see memoryattachments.go)
+	attachments map[string]map[string]*memoryAttachment
+
+	// Bounds of the memory used (see SetLimits)
+	limits  InMemoryLimits
+	size    int                      // Total size of the notes, as counted against limits.MaxBytes
+	recency sync.Mutex               // Protects used and order, which reads update as well
+	used    map[string]*list.Element // Elements of order by note ID, if notes are evicted by recency
+	order   *list.List               // Note IDs from most to least recently used
 }
 
 // NewInMemoryStorage creates a new instance of InMemoryStorage.
@@ -63,19 +97,25 @@ type InMemoryStorage struct {
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		notes: make(map[string]*model.Note), // Initialize an empty map
+		index: newTextIndex(),
 	}
 }
 
 // Create adds a new note to the storage.
 // In this implementation, it simply adds the note to the map using its ID as the key.
+// Like the database backends, it returns an error (ErrConflict) if a note with the same ID
+// already exists, rather than replacing it.
 // This method is thread-safe due to the use of a mutex.
 func (s *InMemoryStorage) Create(ctx context.Context, note *model.Note) error {
 	s.mutex.Lock()         // Lock for writing
 	defer s.mutex.Unlock() // Ensure the lock is released when the function returns
 
-	// Store the note in the map using its ID as the key
-	s.notes[note.ID] = note
-	return nil
+	if _, exists := s.notes[note.ID]; exists {
+		return fmt.Errorf("%w: note %s already exists", ErrConflict, note.ID)
+	}
+
+	// Store a copy of the note in the map using its ID as the key, within the limits
+	return s.store(note)
 }
 
 // Get retrieves a note by its ID.
@@ -90,7 +130,10 @@ func (s *InMemoryStorage) Get(ctx context.Context, id string) (*model.Note, erro
 	if !exists {
 		return nil, ErrNoteNotFound // Return error if note doesn't exist
 	}
-	return note, nil
+	s.touch(id)
+	copied := *note
+	copied.Tags = slices.Clone(note.Tags)
+	return &copied, nil
 }
 
 // GetAll retrieves all notes from the storage.
@@ -103,14 +146,57 @@ func (s *InMemoryStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
 	// Create a slice with capacity equal to the number of notes
 	notes := make([]*model.Note, 0, len(s.notes))
 
-	// Add each note from the map to the slice
+	// Add a copy of each note from the map to the slice
 	for _, note := range s.notes {
-		notes = append(notes, note)
+		copied := *note
+		copied.Tags = slices.Clone(note.Tags)
+		notes = append(notes, &copied)
 	}
 
 	return notes, nil
 }
 
+// Exists reports whether a note with the given ID exists, without copying it.
+// Checking a note doesn't count as using it for the eviction of the least recently used notes.
+func (s *InMemoryStorage) Exists(ctx context.Context, id string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, exists := s.notes[id]
+	return exists, nil
+}
+
+// GetMany retrieves the notes with the given IDs under a single lock, in the order of the IDs.
+// IDs without a note are skipped.
+func (s *InMemoryStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	notes := make([]*model.Note, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		note, exists := s.notes[id]
+		if !exists {
+			continue
+		}
+		s.touch(id)
+		copied := *note
+		copied.Tags = slices.Clone(note.Tags)
+		notes = append(notes, &copied)
+	}
+	return notes, nil
+}
+
+// Stats returns statistics about the notes in memory, without copying them.
+func (s *InMemoryStorage) Stats(ctx context.Context) (NoteStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := NoteStats{Storage: BackendMemory}
+	for _, note := range s.notes {
+		stats.add(note)
+	}
+	return stats, nil
+}
+
 // Update updates an existing note.
 // It returns ErrNoteNotFound if no note with the specified ID exists.
 // This method is thread-safe due to the use of a mutex.
@@ -123,9 +209,38 @@ func (s *InMemoryStorage) Update(ctx context.Context, note *model.Note) error {
 		return ErrNoteNotFound // Return error if note doesn't exist
 	}
 
-	// Update the note in the map
-	s.notes[note.ID] = note
-	return nil
+	// Replace the note in the map with a copy, within the limits
+	return s.store(note)
+}
+
+// UpdateIf updates an existing note if its update time is still expectedUpdatedAt.
+// It returns ErrNoteNotFound if the note doesn't exist, and ErrConflict if it was updated since.
+// The check and the update happen under the same lock, so they are atomic.
+func (s *InMemoryStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, exists := s.notes[note.ID]
+	if !exists {
+		return ErrNoteNotFound
+	}
+	if err := checkUpdatedAt(current, expectedUpdatedAt); err != nil {
+		return err
+	}
+	return s.store(note)
+}
+
+// Upsert replaces the note with the same ID, or creates it if there is none, under a
+// single lock. It reports whether the note was created.
+func (s *InMemoryStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.notes[note.ID]
+	if err := s.store(note); err != nil {
+		return false, err
+	}
+	return !exists, nil
 }
 
 // Delete removes a note from the storage.
@@ -141,14 +256,23 @@ func (s *InMemoryStorage) Delete(ctx context.Context, id string) error {
 	}
 
 	// Remove the note from the map
-	delete(s.notes, id)
+	s.remove(id)
+	s.reportUsage()
+	return nil
+}
+
+// Ping checks that the storage is reachable.
+// In-memory storage is always available, so this method always returns nil.
+func (s *InMemoryStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
 // Close closes any resources used by the storage.
-// For the in-memory implementation, there are no resources to close,
-// so this method does nothing and always returns nil.
+// For the in-memory implementation, there are no resources to close; if snapshots are
+// enabled, the notes are saved one last time.
 func (s *InMemoryStorage) Close(ctx context.Context) error {
-	// Nothing to close for in-memory storage
+	if s.snapshots != nil {
+		return s.snapshots.close(ctx)
+	}
 	return nil
 }
@@ -0,0 +1,468 @@
+// Package storagetest provides a conformance test suite for implementations of
+// storage.NoteStorage, in the way net/http/httptest helps testing HTTP handlers. The
+// backends and decorators of the storage package run it, and so can third-party backends,
+// to verify that they behave the way the application expects:
+//
+//	func TestMyStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.NoteStorage {
+//			return NewMyStorage(...)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// Factory creates a new, empty storage for a test case. Run closes it at the end of the
+// case; the factory can register cleanups of its own (e.g., dropping a test database)
+// with t.Cleanup, which run after the storage is closed.
+type Factory func(t *testing.T) storage.NoteStorage
+
+// Run runs the conformance test suite as subtests of t, each case on a new storage
+// created by factory. The cases cover:
+//   - Creating, getting, updating, and deleting notes, and the fields they keep
+//   - Creating a note with the ID of an existing one, which must fail and keep the note
+//   - Changing the tags of a note that was created or read, which must not change the stored note
+//   - Getting, updating, and deleting missing notes, which must return storage.ErrNoteNotFound
+//   - The helpers that use optional interfaces or fall back to the basic operations
+//     (storage.GetMany, storage.Exists, storage.UpdateIf, storage.Upsert, storage.Stream,
+//     storage.List, and storage.Count), including sorting and pagination
+//   - Canceled contexts, which must either fail with context.Canceled or be ignored
+//   - Ping and Close
+func Run(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("Ping", func(t *testing.T) {
+		s := open(t, factory)
+		if err := s.Ping(ctx); err != nil {
+			t.Fatalf("Failed to ping storage: %v", err)
+		}
+	})
+
+	t.Run("Create and Get", func(t *testing.T) {
+		s := open(t, factory)
+		note := model.NewNote("Test Title", "Test Content")
+		if err := s.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+
+		retrieved, err := s.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+		if retrieved.ID != note.ID || retrieved.Title != note.Title || retrieved.Content != note.Content {
+			t.Errorf("Expected note %+v, got %+v", note, retrieved)
+		}
+		// Backends may store times with less precision (e.g., in milliseconds)
+		if !sameTime(retrieved.CreatedAt, note.CreatedAt) || !sameTime(retrieved.UpdatedAt, note.UpdatedAt) {
+			t.Errorf("Expected times %v and %v, got %v and %v", note.CreatedAt, note.UpdatedAt, retrieved.CreatedAt, retrieved.UpdatedAt)
+		}
+	})
+
+	t.Run("Tags Not Shared", func(t *testing.T) {
+		s := open(t, factory)
+		note := model.NewNote("Tagged", "Content")
+		note.Tags = []string{"original"}
+		if err := s.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		note.Tags[0] = "changed-after-create"
+
+		retrieved, err := s.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+		retrieved.Tags[0] = "changed-after-get"
+		all, err := s.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get all notes: %v", err)
+		}
+		for _, n := range all {
+			n.Tags[0] = "changed-after-get-all"
+		}
+		many, err := storage.GetMany(ctx, s, []string{note.ID})
+		if err != nil {
+			t.Fatalf("Failed to get notes: %v", err)
+		}
+		listed, err := storage.List(ctx, s, storage.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list notes: %v", err)
+		}
+		for _, n := range append(many, listed...) {
+			n.Tags[0] = "changed-after-list"
+		}
+
+		retrieved, err = s.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+		if !reflect.DeepEqual(retrieved.Tags, []string{"original"}) {
+			t.Errorf("Expected the stored tags to be unchanged, got %v", retrieved.Tags)
+		}
+	})
+
+	t.Run("Create Duplicate", func(t *testing.T) {
+		s := open(t, factory)
+		note := model.NewNote("Original", "Content")
+		if err := s.Create(ctx, note); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+
+		duplicate := model.NewNote("Duplicate", "Content")
+		duplicate.ID = note.ID
+		if err := s.Create(ctx, duplicate); err == nil {
+			t.Error("Expected an error creating a note with the ID of an existing note")
+		}
+		if got, err := s.Get(ctx, note.ID); err != nil || got.Title != "Original" {
+			t.Errorf("Expected the existing note to be kept, got %+v: %v", got, err)
+		}
+	})
+
+	t.Run("Get Non-existent", func(t *testing.T) {
+		s := open(t, factory)
+		if _, err := s.Get(ctx, "non-existent-id"); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		s := open(t, factory)
+		if notes, err := s.GetAll(ctx); err != nil || len(notes) != 0 {
+			t.Fatalf("Expected no notes in a new storage, got %d: %v", len(notes), err)
+		}
+
+		created := createNotes(t, s, 2)
+		notes, err := s.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get all notes: %v", err)
+		}
+		if got := ids(notes); !reflect.DeepEqual(got, ids(created)) {
+			t.Errorf("Expected notes %v, got %v", ids(created), got)
+		}
+	})
+
+	// Natively or through the fallback to Get
+	t.Run("GetMany", func(t *testing.T) {
+		s := open(t, factory)
+		created := createNotes(t, s, 3)
+		note1, note2, deleted := created[0], created[1], created[2]
+		if err := s.Delete(ctx, deleted.ID); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+
+		// In the order of the IDs, once each, without missing or deleted notes
+		notes, err := storage.GetMany(ctx, s, []string{note2.ID, "missing", deleted.ID, note1.ID, note2.ID})
+		if err != nil {
+			t.Fatalf("Failed to get notes: %v", err)
+		}
+		if len(notes) != 2 || notes[0].ID != note2.ID || notes[1].ID != note1.ID {
+			t.Fatalf("Expected notes %s and %s, got %v", note2.ID, note1.ID, notes)
+		}
+		if notes[1].Title != note1.Title || notes[1].Content != note1.Content {
+			t.Errorf("Expected note %+v, got %+v", note1, notes[1])
+		}
+
+		if notes, err := storage.GetMany(ctx, s, nil); err != nil || len(notes) != 0 {
+			t.Errorf("Expected no notes for no IDs, got %v: %v", notes, err)
+		}
+	})
+
+	// Natively or through the fallback to Get
+	t.Run("Exists and UpdateIf", func(t *testing.T) {
+		s := open(t, factory)
+		note := createNotes(t, s, 1)[0]
+		if exists, err := storage.Exists(ctx, s, note.ID); err != nil || !exists {
+			t.Errorf("Expected the note to exist, got %t: %v", exists, err)
+		}
+		if exists, err := storage.Exists(ctx, s, "missing"); err != nil || exists {
+			t.Errorf("Expected no missing note, got %t: %v", exists, err)
+		}
+
+		// The update time as stored (e.g., in milliseconds), like clients get it
+		stored, err := s.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get note: %v", err)
+		}
+		updated := *stored
+		updated.Title = "Updated"
+		updated.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+		if err := storage.UpdateIf(ctx, s, &updated, stored.UpdatedAt); err != nil {
+			t.Fatalf("Expected the update of an unchanged note to succeed, got %v", err)
+		}
+
+		// The same update time is stale now
+		stale := *stored
+		stale.Title = "Stale"
+		if err := storage.UpdateIf(ctx, s, &stale, stored.UpdatedAt); !errors.Is(err, storage.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+		if got, err := s.Get(ctx, note.ID); err != nil || got.Title != "Updated" {
+			t.Errorf("Expected the stale update to be rejected, got %+v: %v", got, err)
+		}
+
+		missing := model.NewNote("Missing", "")
+		if err := storage.UpdateIf(ctx, s, missing, missing.UpdatedAt); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
+	// Natively or through the fallback to Update and Create
+	t.Run("Upsert", func(t *testing.T) {
+		s := open(t, factory)
+		note := model.NewNote("Title", "Content")
+		if created, err := storage.Upsert(ctx, s, note); err != nil || !created {
+			t.Fatalf("Expected the note to be created, got %t: %v", created, err)
+		}
+		replaced := *note
+		replaced.Title = "Replaced"
+		if created, err := storage.Upsert(ctx, s, &replaced); err != nil || created {
+			t.Fatalf("Expected the note to be replaced, got %t: %v", created, err)
+		}
+		if got, err := s.Get(ctx, note.ID); err != nil || got.Title != "Replaced" {
+			t.Errorf("Expected the replaced note, got %+v: %v", got, err)
+		}
+
+		// A deleted note is created again
+		if err := s.Delete(ctx, note.ID); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+		recreated := *note
+		recreated.Rev = ""
+		if created, err := storage.Upsert(ctx, s, &recreated); err != nil || !created {
+			t.Errorf("Expected the deleted note to be created again, got %t: %v", created, err)
+		}
+	})
+
+	// Natively or through the fallback to GetAll
+	t.Run("Stream", func(t *testing.T) {
+		s := open(t, factory)
+		created := make(map[string]bool)
+		for _, note := range createNotes(t, s, 3) {
+			created[note.ID] = true
+		}
+
+		streamed := make(map[string]bool)
+		err := storage.Stream(ctx, s, func(note *model.Note) error {
+			streamed[note.ID] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to stream notes: %v", err)
+		}
+		if !reflect.DeepEqual(streamed, created) {
+			t.Errorf("Expected to stream %v, got %v", created, streamed)
+		}
+
+		// An error returned by the callback stops the stream
+		errStop := errors.New("stop")
+		calls := 0
+		err = storage.Stream(ctx, s, func(*model.Note) error {
+			calls++
+			return errStop
+		})
+		if !errors.Is(err, errStop) || calls != 1 {
+			t.Errorf("Expected the stream to stop after the first note with the callback error, got %d calls and %v", calls, err)
+		}
+	})
+
+	// Natively or through the fallback to GetAll
+	t.Run("Pagination", func(t *testing.T) {
+		s := open(t, factory)
+		createNotes(t, s, 5)
+
+		tests := []struct {
+			opts storage.ListOptions
+			want []string
+		}{
+			{storage.ListOptions{Sort: storage.SortTitle, Limit: 2}, []string{"Title 0", "Title 1"}},
+			{storage.ListOptions{Sort: storage.SortTitle, Limit: 2, Offset: 2}, []string{"Title 2", "Title 3"}},
+			{storage.ListOptions{Sort: storage.SortTitle, Limit: 2, Offset: 4}, []string{"Title 4"}},
+			{storage.ListOptions{Sort: storage.SortTitle, Offset: 5}, nil},
+			{storage.ListOptions{Sort: storage.SortTitle, Descending: true, Limit: 3, Offset: 1}, []string{"Title 3", "Title 2", "Title 1"}},
+			{storage.ListOptions{Sort: storage.SortTitle, Query: "title 3"}, []string{"Title 3"}},
+		}
+		for _, tt := range tests {
+			notes, err := storage.List(ctx, s, tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to list notes with %+v: %v", tt.opts, err)
+			}
+			if got := titles(notes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List with %+v: expected %v, got %v", tt.opts, tt.want, got)
+			}
+		}
+
+		// Counts ignore the pagination
+		count, err := storage.Count(ctx, s, storage.ListOptions{Limit: 2, Offset: 1})
+		if err != nil || count != 5 {
+			t.Errorf("Expected a count of 5, got %d: %v", count, err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		s := open(t, factory)
+		note := createNotes(t, s, 1)[0]
+
+		note.Title = "Updated Title"
+		note.Content = "Updated Content"
+		note.UpdatedAt = time.Now()
+		if err := s.Update(ctx, note); err != nil {
+			t.Fatalf("Failed to update note: %v", err)
+		}
+
+		retrieved, err := s.Get(ctx, note.ID)
+		if err != nil {
+			t.Fatalf("Failed to get updated note: %v", err)
+		}
+		if retrieved.Title != "Updated Title" || retrieved.Content != "Updated Content" {
+			t.Errorf("Expected the updated note, got %+v", retrieved)
+		}
+		if !sameTime(retrieved.UpdatedAt, note.UpdatedAt) {
+			t.Errorf("Expected the update time %v, got %v", note.UpdatedAt, retrieved.UpdatedAt)
+		}
+	})
+
+	t.Run("Update Non-existent", func(t *testing.T) {
+		s := open(t, factory)
+		note := model.NewNote("Non-existent", "This note doesn't exist in storage")
+		if err := s.Update(ctx, note); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+		if _, err := s.Get(ctx, note.ID); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected the update not to create the note, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := open(t, factory)
+		created := createNotes(t, s, 2)
+		if err := s.Delete(ctx, created[0].ID); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+
+		if _, err := s.Get(ctx, created[0].ID); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+		// Deleting again finds no note, and the other note is kept
+		if err := s.Delete(ctx, created[0].ID); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound deleting the note again, got %v", err)
+		}
+		if notes, err := s.GetAll(ctx); err != nil || len(notes) != 1 || notes[0].ID != created[1].ID {
+			t.Errorf("Expected only note %s to be left, got %v: %v", created[1].ID, notes, err)
+		}
+	})
+
+	t.Run("Delete Non-existent", func(t *testing.T) {
+		s := open(t, factory)
+		if err := s.Delete(ctx, "non-existent-id"); !errors.Is(err, storage.ErrNoteNotFound) {
+			t.Errorf("Expected ErrNoteNotFound, got %v", err)
+		}
+	})
+
+	// Backends that wait for a database must stop with the context; those that don't
+	// (e.g., in-memory storage) may complete the operation instead
+	t.Run("Context Cancellation", func(t *testing.T) {
+		s := open(t, factory)
+		existing := createNotes(t, s, 1)[0]
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		check := func(operation string, err error) {
+			t.Helper()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				t.Errorf("%s: expected context.Canceled or no error, got %v", operation, err)
+			}
+		}
+		note := model.NewNote("Canceled", "Content")
+		err := s.Create(canceled, note)
+		check("Create", err)
+		if _, getErr := s.Get(ctx, note.ID); err != nil && !errors.Is(getErr, storage.ErrNoteNotFound) {
+			t.Errorf("Expected a canceled creation not to create the note, got %v", getErr)
+		}
+		_, err = s.Get(canceled, existing.ID)
+		check("Get", err)
+		_, err = s.GetAll(canceled)
+		check("GetAll", err)
+
+		updated := *existing
+		updated.Title = "Canceled"
+		err = s.Update(canceled, &updated)
+		check("Update", err)
+		if got, getErr := s.Get(ctx, existing.ID); err != nil && (getErr != nil || got.Title != existing.Title) {
+			t.Errorf("Expected a canceled update to keep the note, got %+v: %v", got, getErr)
+		}
+		err = s.Delete(canceled, existing.ID)
+		check("Delete", err)
+		if _, getErr := s.Get(ctx, existing.ID); err != nil && getErr != nil {
+			t.Errorf("Expected a canceled deletion to keep the note, got %v", getErr)
+		}
+
+		// The storage still serves other requests
+		if _, err := s.GetAll(ctx); err != nil {
+			t.Errorf("Failed to get notes after canceled operations: %v", err)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		s := factory(t)
+		if err := s.Close(ctx); err != nil {
+			t.Errorf("Failed to close storage: %v", err)
+		}
+	})
+}
+
+// open creates the storage of a test case, closed when the case ends.
+func open(t *testing.T, factory Factory) storage.NoteStorage {
+	t.Helper()
+	s := factory(t)
+	t.Cleanup(func() {
+		if err := s.Close(context.Background()); err != nil {
+			t.Errorf("Failed to close storage: %v", err)
+		}
+	})
+	return s
+}
+
+// createNotes creates n notes titled "Title 0" to "Title n-1", and returns them.
+func createNotes(t *testing.T, s storage.NoteStorage, n int) []*model.Note {
+	t.Helper()
+	notes := make([]*model.Note, n)
+	for i := range notes {
+		notes[i] = model.NewNote(fmt.Sprintf("Title %d", i), fmt.Sprintf("Content %d", i))
+		if err := s.Create(context.Background(), notes[i]); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	return notes
+}
+
+// ids returns the IDs of notes as a set.
+func ids(notes []*model.Note) map[string]bool {
+	set := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		set[note.ID] = true
+	}
+	return set
+}
+
+// titles returns the titles of notes, in order.
+func titles(notes []*model.Note) []string {
+	var titles []string
+	for _, note := range notes {
+		titles = append(titles, note.Title)
+	}
+	return titles
+}
+
+// sameTime reports whether a stored time is the time given, up to the millisecond.
+func sameTime(stored, given time.Time) bool {
+	return stored.Sub(given).Abs() < time.Millisecond
+}
@@ -0,0 +1,45 @@
+// This file contains streaming reads: visiting all notes one at a time, so that
+// large exports don't need to hold every note in memory at once.
+package storage
+
+import (
+	"context"
+
+	"golang-simple-notes/model"
+)
+
+// Streamer is implemented by storage backends that can read notes one at a time
+// (e.g., from a database cursor), rather than returning all notes at once.
+type Streamer interface {
+	// Stream calls fn for every note, in an unspecified order. It stops at the first
+	// error returned by fn, and returns that error.
+	Stream(ctx context.Context, fn func(note *model.Note) error) error
+}
+
+// Stream calls fn for every note of the backend, in an unspecified order.
+// Backends that implement Streamer read the notes one at a time; for the others,
+// all notes are loaded first.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Streamer
+//   - fn: The function called for each note; an error stops the stream
+//
+// Returns:
+//   - The error returned by fn, or an error reading the notes
+func Stream(ctx context.Context, backend NoteReader, fn func(note *model.Note) error) error {
+	if streamer, ok := backend.(Streamer); ok {
+		return streamer.Stream(ctx, fn)
+	}
+
+	notes, err := backend.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
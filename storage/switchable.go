@@ -0,0 +1,180 @@
+// This file contains a NoteStorage whose backend can be replaced at runtime,
+// used to switch back from the in-memory fallback once the configured backend recovers.
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// SwitchableStorage implements NoteStorage by forwarding every operation to a backend
+// that can be replaced while the application is running. Operations hold a read lock,
+// so Switch waits for operations in progress and blocks new ones until it completes.
+type SwitchableStorage struct {
+	mutex   sync.RWMutex
+	backend NoteStorage
+}
+
+// NewSwitchableStorage creates a new switchable storage.
+//
+// Parameters:
+//   - backend: The storage backend used until the first switch
+//
+// Returns:
+//   - A pointer to a new SwitchableStorage instance
+func NewSwitchableStorage(backend NoteStorage) *SwitchableStorage {
+	return &SwitchableStorage{backend: backend}
+}
+
+// Switch replaces the backend. If prepare is not nil, it is called first with the
+// current and the new backend (e.g., to copy notes); no other operation can run
+// until Switch returns, so no write is lost in between.
+//
+// Parameters:
+//   - ctx: The context passed to prepare
+//   - backend: The new storage backend
+//   - prepare: An optional function called before switching
+//
+// Returns:
+//   - The previous backend, which the caller is responsible for closing
+//   - An error returned by prepare, in which case the backend is not switched
+func (s *SwitchableStorage) Switch(ctx context.Context, backend NoteStorage,
+	prepare func(ctx context.Context, current, next NoteStorage) error) (NoteStorage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if prepare != nil {
+		if err := prepare(ctx, s.backend, backend); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := s.backend
+	s.backend = backend
+	return previous, nil
+}
+
+// Create adds a new note to the current backend.
+func (s *SwitchableStorage) Create(ctx context.Context, note *model.Note) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Create(ctx, note)
+}
+
+// Get retrieves a note by its ID from the current backend.
+func (s *SwitchableStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Get(ctx, id)
+}
+
+// GetAll retrieves all notes from the current backend.
+func (s *SwitchableStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.GetAll(ctx)
+}
+
+// List runs a list query on the current backend.
+func (s *SwitchableStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return List(ctx, s.backend, opts)
+}
+
+// Exists checks whether a note exists in the current backend.
+func (s *SwitchableStorage) Exists(ctx context.Context, id string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Exists(ctx, s.backend, id)
+}
+
+// GetMany retrieves notes by ID from the current backend.
+func (s *SwitchableStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return GetMany(ctx, s.backend, ids)
+}
+
+// Count counts the matching notes in the current backend.
+func (s *SwitchableStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Count(ctx, s.backend, opts)
+}
+
+// Stats returns statistics about the notes in the current backend.
+func (s *SwitchableStorage) Stats(ctx context.Context) (NoteStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Stats(ctx, s.backend)
+}
+
+// Maintain runs a maintenance task on the current backend.
+// The backend cannot be switched until the task ends.
+func (s *SwitchableStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Maintain(ctx, s.backend, task)
+}
+
+// RunInTransaction runs fn in a transaction of the current backend.
+// The backend cannot be switched until the transaction ends.
+func (s *SwitchableStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return RunInTransaction(ctx, s.backend, fn)
+}
+
+// Stream reads all notes from the current backend one at a time.
+// The backend cannot be switched until the stream ends.
+func (s *SwitchableStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Stream(ctx, s.backend, fn)
+}
+
+// Update updates an existing note in the current backend.
+func (s *SwitchableStorage) Update(ctx context.Context, note *model.Note) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Update(ctx, note)
+}
+
+// UpdateIf updates an existing note in the current backend if it is unchanged.
+func (s *SwitchableStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return UpdateIf(ctx, s.backend, note, expectedUpdatedAt)
+}
+
+// Upsert stores a note in the current backend, creating it if needed.
+func (s *SwitchableStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Upsert(ctx, s.backend, note)
+}
+
+// Delete removes a note by its ID from the current backend.
+func (s *SwitchableStorage) Delete(ctx context.Context, id string) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Delete(ctx, id)
+}
+
+// Ping checks that the current backend is reachable.
+func (s *SwitchableStorage) Ping(ctx context.Context) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Ping(ctx)
+}
+
+// Close closes the current backend.
+func (s *SwitchableStorage) Close(ctx context.Context) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.backend.Close(ctx)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// TestSwitchableStorageSwitch verifies that operations go to the new backend after a switch
+func TestSwitchableStorageSwitch(t *testing.T) {
+	ctx := context.Background()
+	first, second := NewInMemoryStorage(), NewInMemoryStorage()
+	s := NewSwitchableStorage(first)
+
+	if err := s.Create(ctx, &model.Note{ID: "before", Title: "Before"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	var prepared bool
+	previous, err := s.Switch(ctx, second, func(ctx context.Context, current, next NoteStorage) error {
+		prepared = current == first && next == second
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Switch failed: %v", err)
+	}
+	if !prepared {
+		t.Error("Expected prepare to be called with the current and the new backend")
+	}
+	if previous != first {
+		t.Error("Expected the previous backend to be returned")
+	}
+
+	if err := s.Create(ctx, &model.Note{ID: "after", Title: "After"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if _, err := second.Get(ctx, "after"); err != nil {
+		t.Errorf("Expected the note in the new backend: %v", err)
+	}
+	if _, err := s.Get(ctx, "before"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected notes of the previous backend to be gone, got %v", err)
+	}
+}
+
+// TestSwitchableStoragePrepareFailure verifies that a failed prepare keeps the current backend
+func TestSwitchableStoragePrepareFailure(t *testing.T) {
+	ctx := context.Background()
+	first := NewInMemoryStorage()
+	s := NewSwitchableStorage(first)
+
+	prepareErr := errors.New("replay failed")
+	_, err := s.Switch(ctx, &failingStorage{}, func(context.Context, NoteStorage, NoteStorage) error {
+		return prepareErr
+	})
+	if !errors.Is(err, prepareErr) {
+		t.Fatalf("Expected the prepare error, got %v", err)
+	}
+	if err := s.Ping(ctx); err != nil {
+		t.Errorf("Expected the current backend to be kept, got %v", err)
+	}
+}
+
+// TestSwitchableStorageBlocksDuringSwitch verifies that operations wait until a switch completes
+func TestSwitchableStorageBlocksDuringSwitch(t *testing.T) {
+	ctx := context.Background()
+	second := NewInMemoryStorage()
+	s := NewSwitchableStorage(NewInMemoryStorage())
+
+	preparing, release := make(chan struct{}), make(chan struct{})
+	switched := make(chan struct{})
+	go func() {
+		_, _ = s.Switch(ctx, second, func(context.Context, NoteStorage, NoteStorage) error {
+			close(preparing)
+			<-release
+			return nil
+		})
+		close(switched)
+	}()
+	<-preparing
+
+	created := make(chan error, 1)
+	go func() { created <- s.Create(ctx, &model.Note{ID: "during", Title: "During"}) }()
+
+	select {
+	case <-created:
+		t.Fatal("Expected the write to wait for the switch")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-switched
+	if err := <-created; err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if _, err := second.Get(ctx, "during"); err != nil {
+		t.Errorf("Expected the write to reach the new backend: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+// This file contains a timeout decorator for the NoteStorage interface.
+// Without it, a single slow query (e.g., a MongoDB query that stalls) holds the request
+// that is waiting for it until the server timeout; the decorator bounds every operation
+// with a deadline of its own instead.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// ErrTimeout is returned (wrapped) when a storage operation takes longer than the
+// per-operation timeout of a TimeoutStorage. Use errors.Is to check for it.
+var ErrTimeout = errors.New("storage operation timed out")
+
+// TimeoutStorage implements NoteStorage by bounding every operation performed on another
+// NoteStorage implementation with a timeout. Operations that run out of time fail with
+// ErrTimeout; deadlines and cancellations of the caller's context are reported as they are.
+//
+// Stream and Maintain are not bounded: how long they take depends on the number of notes
+// (and, for Stream, on the caller consuming them), not on a single slow query.
+type TimeoutStorage struct {
+	inner   NoteStorage   // Backend whose operations are bounded
+	timeout time.Duration // Maximum duration of a single operation
+}
+
+// NewTimeoutStorage creates a new timeout decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to wrap
+//   - timeout: The maximum duration of a single operation
+//
+// Returns:
+//   - A pointer to a new TimeoutStorage instance
+func NewTimeoutStorage(inner NoteStorage, timeout time.Duration) *TimeoutStorage {
+	return &TimeoutStorage{
+		inner:   inner,
+		timeout: timeout,
+	}
+}
+
+// Create adds a new note to the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Create(ctx context.Context, note *model.Note) error {
+	return s.call(ctx, "Create", func(ctx context.Context) error {
+		return s.inner.Create(ctx, note)
+	})
+}
+
+// Get retrieves a note from the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	var note *model.Note
+	err := s.call(ctx, "Get", func(ctx context.Context) error {
+		var err error
+		note, err = s.inner.Get(ctx, id)
+		return err
+	})
+	return note, err
+}
+
+// GetAll retrieves all notes from the wrapped storage, within the timeout.
+func (s *TimeoutStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, "GetAll", func(ctx context.Context) error {
+		var err error
+		notes, err = s.inner.GetAll(ctx)
+		return err
+	})
+	return notes, err
+}
+
+// List runs a list query on the wrapped storage, within the timeout.
+func (s *TimeoutStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, "List", func(ctx context.Context) error {
+		var err error
+		notes, err = List(ctx, s.inner, opts)
+		return err
+	})
+	return notes, err
+}
+
+// Exists checks whether a note exists in the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := s.call(ctx, "Exists", func(ctx context.Context) error {
+		var err error
+		exists, err = Exists(ctx, s.inner, id)
+		return err
+	})
+	return exists, err
+}
+
+// GetMany retrieves notes by ID from the wrapped storage, within the timeout.
+func (s *TimeoutStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := s.call(ctx, "GetMany", func(ctx context.Context) error {
+		var err error
+		notes, err = GetMany(ctx, s.inner, ids)
+		return err
+	})
+	return notes, err
+}
+
+// Count counts the matching notes in the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	var count int
+	err := s.call(ctx, "Count", func(ctx context.Context) error {
+		var err error
+		count, err = Count(ctx, s.inner, opts)
+		return err
+	})
+	return count, err
+}
+
+// Stats returns statistics about the notes in the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Stats(ctx context.Context) (NoteStats, error) {
+	var stats NoteStats
+	err := s.call(ctx, "Stats", func(ctx context.Context) error {
+		var err error
+		stats, err = Stats(ctx, s.inner)
+		return err
+	})
+	return stats, err
+}
+
+// Maintain runs a maintenance task on the wrapped storage, without a timeout.
+func (s *TimeoutStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	return Maintain(ctx, s.inner, task)
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage, if it supports them.
+// The transaction as a whole has no timeout; the operations in fn have their own.
+func (s *TimeoutStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTransaction(ctx, s.inner, fn)
+}
+
+// Stream reads all notes from the wrapped storage one at a time, without a timeout.
+func (s *TimeoutStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	return Stream(ctx, s.inner, fn)
+}
+
+// Update updates a note in the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Update(ctx context.Context, note *model.Note) error {
+	return s.call(ctx, "Update", func(ctx context.Context) error {
+		return s.inner.Update(ctx, note)
+	})
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged, within the timeout.
+func (s *TimeoutStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	return s.call(ctx, "UpdateIf", func(ctx context.Context) error {
+		return UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	})
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, within the timeout.
+func (s *TimeoutStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	var created bool
+	err := s.call(ctx, "Upsert", func(ctx context.Context) error {
+		var err error
+		created, err = Upsert(ctx, s.inner, note)
+		return err
+	})
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Delete(ctx context.Context, id string) error {
+	return s.call(ctx, "Delete", func(ctx context.Context) error {
+		return s.inner.Delete(ctx, id)
+	})
+}
+
+// Ping checks the wrapped storage, within the timeout.
+func (s *TimeoutStorage) Ping(ctx context.Context) error {
+	return s.call(ctx, "Ping", func(ctx context.Context) error {
+		return s.inner.Ping(ctx)
+	})
+}
+
+// Close closes the wrapped storage. Shutdown has a deadline of its own.
+func (s *TimeoutStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// call runs an operation with a context that expires after the timeout. If the operation
+// fails because that deadline passed, rather than the deadline of the caller's context,
+// its error is wrapped in ErrTimeout.
+func (s *TimeoutStorage) call(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	opCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := fn(opCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s took longer than %v: %w", ErrTimeout, op, s.timeout, err)
+	}
+	return err
+}
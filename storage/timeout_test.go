@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// stalledStorage is a NoteStorage whose Get blocks until its context is done, like a stalled query
+type stalledStorage struct {
+	NoteStorage
+}
+
+func (s stalledStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestTimeoutStorageExpires verifies that operations running out of time fail with ErrTimeout,
+// while the deadline of the caller is reported as it is
+func TestTimeoutStorageExpires(t *testing.T) {
+	storage := NewTimeoutStorage(stalledStorage{NewInMemoryStorage()}, 20*time.Millisecond)
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := storage.Get(context.Background(), "slow")
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected ErrTimeout wrapping the deadline, got %v", err)
+		}
+	})
+
+	t.Run("CallerDeadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := storage.Get(ctx, "slow")
+		if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected the deadline of the caller, got %v", err)
+		}
+	})
+
+	t.Run("StreamUnbounded", func(t *testing.T) {
+		ctx := context.Background()
+		if err := storage.Create(ctx, &model.Note{ID: "a", Title: "A"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		err := storage.Stream(ctx, func(note *model.Note) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected a slow stream to complete, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+// This file contains a tracing decorator for the NoteStorage interface.
+// It records an OpenTelemetry span for every storage operation, as a child of the
+// REST or gRPC span that caused it. Database client spans (MongoDB commands,
+// CouchDB HTTP requests) in turn become children of these storage spans.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"golang-simple-notes/model"
+)
+
+// tracerName identifies the tracer used for storage spans.
+const tracerName = "golang-simple-notes/storage"
+
+// TracingStorage implements NoteStorage by recording a span for each operation
+// performed on another NoteStorage implementation.
+// ErrNoteNotFound is treated as a regular outcome, so it doesn't mark the span as failed.
+type TracingStorage struct {
+	inner   NoteStorage // Backend whose operations are traced
+	backend string      // Backend name recorded on spans (e.g., "mongodb")
+}
+
+// NewTracingStorage creates a new tracing decorator around the given storage.
+//
+// Parameters:
+//   - inner: The storage backend to wrap
+//   - backend: A short backend name recorded as the "storage.backend" span attribute
+//
+// Returns:
+//   - A pointer to a new TracingStorage instance
+func NewTracingStorage(inner NoteStorage, backend string) *TracingStorage {
+	return &TracingStorage{
+		inner:   inner,
+		backend: backend,
+	}
+}
+
+// Create adds a new note to the wrapped storage within a span.
+func (s *TracingStorage) Create(ctx context.Context, note *model.Note) error {
+	ctx, span := s.start(ctx, "Create", note.ID)
+	defer span.End()
+
+	err := s.inner.Create(ctx, note)
+	s.finish(span, err)
+	return err
+}
+
+// Get retrieves a note from the wrapped storage within a span.
+func (s *TracingStorage) Get(ctx context.Context, id string) (*model.Note, error) {
+	ctx, span := s.start(ctx, "Get", id)
+	defer span.End()
+
+	note, err := s.inner.Get(ctx, id)
+	s.finish(span, err)
+	return note, err
+}
+
+// GetAll retrieves all notes from the wrapped storage within a span.
+func (s *TracingStorage) GetAll(ctx context.Context) ([]*model.Note, error) {
+	ctx, span := s.start(ctx, "GetAll", "")
+	defer span.End()
+
+	notes, err := s.inner.GetAll(ctx)
+	if err == nil {
+		span.SetAttributes(attribute.Int("storage.notes", len(notes)))
+	}
+	s.finish(span, err)
+	return notes, err
+}
+
+// List runs a list query on the wrapped storage within a span.
+func (s *TracingStorage) List(ctx context.Context, opts ListOptions) ([]*model.Note, error) {
+	ctx, span := s.start(ctx, "List", "")
+	defer span.End()
+
+	notes, err := List(ctx, s.inner, opts)
+	if err == nil {
+		span.SetAttributes(attribute.Int("storage.notes", len(notes)))
+	}
+	s.finish(span, err)
+	return notes, err
+}
+
+// Exists checks whether a note exists in the wrapped storage within a span.
+func (s *TracingStorage) Exists(ctx context.Context, id string) (bool, error) {
+	ctx, span := s.start(ctx, "Exists", id)
+	defer span.End()
+
+	exists, err := Exists(ctx, s.inner, id)
+	s.finish(span, err)
+	return exists, err
+}
+
+// GetMany retrieves notes by ID from the wrapped storage within a span.
+func (s *TracingStorage) GetMany(ctx context.Context, ids []string) ([]*model.Note, error) {
+	ctx, span := s.start(ctx, "GetMany", "")
+	defer span.End()
+
+	notes, err := GetMany(ctx, s.inner, ids)
+	if err == nil {
+		span.SetAttributes(attribute.Int("storage.notes", len(notes)))
+	}
+	s.finish(span, err)
+	return notes, err
+}
+
+// Count counts the matching notes in the wrapped storage within a span.
+func (s *TracingStorage) Count(ctx context.Context, opts ListOptions) (int, error) {
+	ctx, span := s.start(ctx, "Count", "")
+	defer span.End()
+
+	count, err := Count(ctx, s.inner, opts)
+	if err == nil {
+		span.SetAttributes(attribute.Int("storage.notes", count))
+	}
+	s.finish(span, err)
+	return count, err
+}
+
+// Stats returns statistics about the notes in the wrapped storage within a span.
+func (s *TracingStorage) Stats(ctx context.Context) (NoteStats, error) {
+	ctx, span := s.start(ctx, "Stats", "")
+	defer span.End()
+
+	stats, err := Stats(ctx, s.inner)
+	if err == nil {
+		span.SetAttributes(attribute.Int("storage.notes", stats.Notes))
+	}
+	s.finish(span, err)
+	return stats, err
+}
+
+// Maintain runs a maintenance task on the wrapped storage within a span.
+func (s *TracingStorage) Maintain(ctx context.Context, task MaintenanceTask) error {
+	ctx, span := s.start(ctx, "Maintain", "")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("storage.task", string(task)))
+	err := Maintain(ctx, s.inner, task)
+	s.finish(span, err)
+	return err
+}
+
+// RunInTransaction runs fn in a transaction of the wrapped storage within a span, which is
+// the parent of the spans of the operations in fn.
+func (s *TracingStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, span := s.start(ctx, "RunInTransaction", "")
+	defer span.End()
+
+	err := RunInTransaction(ctx, s.inner, fn)
+	s.finish(span, err)
+	return err
+}
+
+// Stream reads all notes from the wrapped storage one at a time within a span.
+func (s *TracingStorage) Stream(ctx context.Context, fn func(note *model.Note) error) error {
+	ctx, span := s.start(ctx, "Stream", "")
+	defer span.End()
+
+	count := 0
+	err := Stream(ctx, s.inner, func(note *model.Note) error {
+		count++
+		return fn(note)
+	})
+	span.SetAttributes(attribute.Int("storage.notes", count))
+	s.finish(span, err)
+	return err
+}
+
+// Update updates a note in the wrapped storage within a span.
+func (s *TracingStorage) Update(ctx context.Context, note *model.Note) error {
+	ctx, span := s.start(ctx, "Update", note.ID)
+	defer span.End()
+
+	err := s.inner.Update(ctx, note)
+	s.finish(span, err)
+	return err
+}
+
+// UpdateIf updates a note in the wrapped storage if it is unchanged, within a span.
+func (s *TracingStorage) UpdateIf(ctx context.Context, note *model.Note, expectedUpdatedAt time.Time) error {
+	ctx, span := s.start(ctx, "UpdateIf", note.ID)
+	defer span.End()
+
+	err := UpdateIf(ctx, s.inner, note, expectedUpdatedAt)
+	s.finish(span, err)
+	return err
+}
+
+// Upsert stores a note in the wrapped storage, creating it if needed, within a span.
+func (s *TracingStorage) Upsert(ctx context.Context, note *model.Note) (bool, error) {
+	ctx, span := s.start(ctx, "Upsert", note.ID)
+	defer span.End()
+
+	created, err := Upsert(ctx, s.inner, note)
+	if err == nil {
+		span.SetAttributes(attribute.Bool("storage.created", created))
+	}
+	s.finish(span, err)
+	return created, err
+}
+
+// Delete removes a note from the wrapped storage within a span.
+func (s *TracingStorage) Delete(ctx context.Context, id string) error {
+	ctx, span := s.start(ctx, "Delete", id)
+	defer span.End()
+
+	err := s.inner.Delete(ctx, id)
+	s.finish(span, err)
+	return err
+}
+
+// Ping checks the wrapped storage within a span.
+func (s *TracingStorage) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "Ping", "")
+	defer span.End()
+
+	err := s.inner.Ping(ctx)
+	s.finish(span, err)
+	return err
+}
+
+// Close closes the wrapped storage.
+func (s *TracingStorage) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// start begins a span named after the storage operation (e.g., "storage.Get").
+func (s *TracingStorage) start(ctx context.Context, op, id string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("storage.backend", s.backend)}
+	if id != "" {
+		attrs = append(attrs, attribute.String("note.id", id))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "storage."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// finish records the outcome of an operation on its span.
+func (s *TracingStorage) finish(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, ErrNoteNotFound) {
+		span.SetAttributes(attribute.Bool("note.found", false))
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
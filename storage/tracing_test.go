@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"golang-simple-notes/model"
+)
+
+// setupSpanRecorder installs a tracer provider that records spans in memory
+func setupSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// TestTracingStorageSpans tests the names, attributes, and status of storage spans
+func TestTracingStorageSpans(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+	ctx := context.Background()
+	s := NewTracingStorage(NewInMemoryStorage(), "memory")
+
+	if err := s.Create(ctx, &model.Note{ID: "note-1", Title: "Title"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("Expected ErrNoteNotFound, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "storage.Create" || spans[1].Name() != "storage.Get" {
+		t.Errorf("Unexpected span names: %s, %s", spans[0].Name(), spans[1].Name())
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["storage.backend"] != "memory" || attrs["note.id"] != "note-1" {
+		t.Errorf("Unexpected attributes: %v", attrs)
+	}
+
+	// A missing note is a regular outcome, not a failed span
+	if spans[1].Status().Code == codes.Error {
+		t.Error("Expected not-found span not to be marked as failed")
+	}
+}
+
+// TestTracingStorageFailure tests that failed operations mark their spans as failed
+func TestTracingStorageFailure(t *testing.T) {
+	recorder := setupSpanRecorder(t)
+	s := NewTracingStorage(&failingStorage{}, "mongodb")
+
+	if _, err := s.GetAll(context.Background()); err == nil {
+		t.Fatal("Expected GetAll to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected span status Error, got %v", spans[0].Status().Code)
+	}
+	if len(spans[0].Events()) == 0 {
+		t.Error("Expected the error to be recorded as a span event")
+	}
+}
@@ -0,0 +1,96 @@
+// This file contains support for running several storage operations atomically.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor is implemented by storage backends that can run several operations as
+// a single transaction, so that a failure rolls back the operations before it.
+type Transactor interface {
+	// RunInTransaction calls fn with a context that makes the storage operations performed
+	// with it part of a transaction. The transaction is committed if fn returns nil, and
+	// aborted otherwise. fn may be called more than once if the transaction is retried.
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// RunInTransaction calls fn in a transaction if the backend supports transactions,
+// or directly otherwise, in which case operations that succeeded before a failure are kept.
+//
+// The operations in fn may go through decorators of the backend (e.g., logging or
+// encryption), as long as they are performed with the context passed to fn.
+//
+// Parameters:
+//   - ctx: The context for the transaction
+//   - backend: The storage backend, which may implement Transactor; the decorators of the
+//     storage package forward transactions to the backend they wrap
+//   - fn: The operations to run
+//
+// Returns:
+//   - The error returned by fn, or an error starting or committing the transaction
+func RunInTransaction(ctx context.Context, backend NoteWriter, fn func(ctx context.Context) error) error {
+	if transactor, ok := backend.(Transactor); ok {
+		return transactor.RunInTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+// mongoTopology caches whether a MongoDB deployment supports transactions.
+type mongoTopology struct {
+	mutex        sync.Mutex
+	known        bool // Whether the topology has been determined
+	transactions bool // Whether transactions are supported
+}
+
+// supportsTransactions reports whether the server is a replica set member or a mongos router;
+// standalone servers don't support transactions. The answer is cached once it is known.
+func (s *MongoDBStorage) supportsTransactions(ctx context.Context) (bool, error) {
+	s.topology.mutex.Lock()
+	defer s.topology.mutex.Unlock()
+
+	if !s.topology.known {
+		var hello struct {
+			SetName string `bson:"setName"` // Set on replica set members
+			Msg     string `bson:"msg"`     // "isdbgrid" on mongos routers
+		}
+		err := s.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+		if err != nil {
+			return false, fmt.Errorf("failed to determine the MongoDB topology: %w", err)
+		}
+		s.topology.known = true
+		s.topology.transactions = hello.SetName != "" || hello.Msg == "isdbgrid"
+		if !s.topology.transactions {
+			log.Printf("MongoDB is a standalone server; multi-note writes are not atomic")
+		}
+	}
+	return s.topology.transactions, nil
+}
+
+// RunInTransaction calls fn in a MongoDB transaction, retrying it on transient errors.
+// On a standalone server, which doesn't support transactions, fn is called directly.
+func (s *MongoDBStorage) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := s.supportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(ctx)
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
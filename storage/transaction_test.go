@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang-simple-notes/model"
+)
+
+// recordingTransactor is a NoteStorage that records whether RunInTransaction was used
+type recordingTransactor struct {
+	NoteStorage
+	transactions int
+}
+
+func (s *recordingTransactor) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.transactions++
+	return fn(ctx)
+}
+
+// TestRunInTransaction tests that transactions are used only if the backend supports them
+func TestRunInTransaction(t *testing.T) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	t.Run("WithoutTransactions", func(t *testing.T) {
+		memory := NewInMemoryStorage()
+		err := RunInTransaction(ctx, memory, func(ctx context.Context) error {
+			if err := memory.Create(ctx, &model.Note{ID: "kept", Title: "Kept"}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Expected the error of fn, got %v", err)
+		}
+		// Without transactions, earlier operations are not rolled back
+		if _, err := memory.Get(ctx, "kept"); err != nil {
+			t.Errorf("Expected the note to be kept: %v", err)
+		}
+	})
+
+	t.Run("WithTransactions", func(t *testing.T) {
+		transactor := &recordingTransactor{NoteStorage: NewInMemoryStorage()}
+		var called bool
+		err := RunInTransaction(ctx, transactor, func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		if err != nil || !called {
+			t.Fatalf("Expected fn to be called without error, got %v (called: %t)", err, called)
+		}
+		if transactor.transactions != 1 {
+			t.Errorf("Expected 1 transaction, got %d", transactor.transactions)
+		}
+	})
+}
+
+// TestRunInTransaction_Decorators tests that every decorator forwards transactions to the
+// backend it wraps, so a wrapped backend still runs them
+func TestRunInTransaction_Decorators(t *testing.T) {
+	ctx := context.Background()
+	keys := NewTestKeyring(t, "k1")
+
+	decorators := map[string]func(inner NoteStorage) NoteStorage{
+		"Cached": func(inner NoteStorage) NoteStorage {
+			return NewCachedStorage(inner, NewLRUCache(10, time.Minute))
+		},
+		"CircuitBreaker": func(inner NoteStorage) NoteStorage {
+			return NewCircuitBreakerStorage(inner, "test", 3, time.Second)
+		},
+		"DualWrite": func(inner NoteStorage) NoteStorage {
+			return NewDualWriteStorage(inner, NewInMemoryStorage(), nil)
+		},
+		"Encrypted":    func(inner NoteStorage) NoteStorage { return NewEncryptedStorage(inner, keys, true) },
+		"Instrumented": func(inner NoteStorage) NoteStorage { return NewInstrumentedStorage(inner, "test", time.Second) },
+		"Logging":      func(inner NoteStorage) NoteStorage { return NewLoggingStorage(inner, "test", true) },
+		"Replicated": func(inner NoteStorage) NoteStorage {
+			return NewReplicatedStorage(inner, NewInMemoryStorage(), 10, 0)
+		},
+		"Switchable": func(inner NoteStorage) NoteStorage { return NewSwitchableStorage(inner) },
+		"Timeout":    func(inner NoteStorage) NoteStorage { return NewTimeoutStorage(inner, time.Second) },
+		"Tracing":    func(inner NoteStorage) NoteStorage { return NewTracingStorage(inner, "test") },
+	}
+	for name, decorate := range decorators {
+		t.Run(name, func(t *testing.T) {
+			transactor := &recordingTransactor{NoteStorage: NewInMemoryStorage()}
+			wrapped := decorate(transactor)
+			t.Cleanup(func() { _ = wrapped.Close(ctx) })
+			if _, ok := wrapped.(Transactor); !ok {
+				t.Fatalf("Expected %T to implement Transactor", wrapped)
+			}
+			err := RunInTransaction(ctx, wrapped, func(ctx context.Context) error {
+				return wrapped.Create(ctx, &model.Note{ID: "n", Title: "Title"})
+			})
+			if err != nil {
+				t.Fatalf("Transaction failed: %v", err)
+			}
+			if transactor.transactions != 1 {
+				t.Errorf("Expected the transaction to reach the backend, got %d transactions", transactor.transactions)
+			}
+		})
+	}
+}
+
+// TestMongoDBTransaction tests that a failed transaction rolls back its writes on a replica set,
+// and that operations are applied directly on a standalone server
+// This test uses the shared MongoDB container from TestMain
+func TestMongoDBTransaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping MongoDB integration test in short mode")
+	}
+	mongodbEndpoint := getSharedMongoURI()
+	if mongodbEndpoint == "" {
+		t.Skip("Shared MongoDB container not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	storage, err := NewMongoDBStorage(ctx, mongodbEndpoint, "test_notes", "test_transactions", MongoDBOptions{}, DefaultRetryPolicy())
+	if err != nil {
+		t.Fatalf("Failed to create MongoDB storage: %v", err)
+	}
+	CleanupCloseWithContext(t, ctx, storage)
+
+	supported, err := storage.supportsTransactions(ctx)
+	if err != nil {
+		t.Fatalf("Failed to determine the topology: %v", err)
+	}
+
+	// Decorators pass the transaction context through to the backend
+	decorated := NewLoggingStorage(storage, "mongodb", false)
+	note := model.NewNote("Title", "Content")
+	errAbort := errors.New("abort")
+	err = RunInTransaction(ctx, storage, func(ctx context.Context) error {
+		if err := decorated.Create(ctx, note); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Expected the error of fn, got %v", err)
+	}
+
+	_, err = storage.Get(ctx, note.ID)
+	switch {
+	case supported && !errors.Is(err, ErrNoteNotFound):
+		t.Errorf("Expected the note to be rolled back, got %v", err)
+	case !supported && err != nil:
+		t.Errorf("Expected the note to be kept on a standalone server, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+// This file contains upserts: storing a note whether or not it exists yet.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"golang-simple-notes/model"
+)
+
+// NoteWriter is the part of NoteStorage that creates and updates notes. Upsert only needs
+// this, so it also works on repositories that don't expose the whole interface.
+type NoteWriter interface {
+	// Create adds a new note; it fails if a note with the same ID already exists.
+	Create(ctx context.Context, note *model.Note) error
+
+	// Update replaces an existing note, or returns ErrNoteNotFound.
+	Update(ctx context.Context, note *model.Note) error
+}
+
+// Upserter is implemented by storage backends that can create or replace a note in a
+// single operation.
+type Upserter interface {
+	// Upsert replaces the note with the same ID, or creates it if there is none.
+	// It reports whether the note was created.
+	Upsert(ctx context.Context, note *model.Note) (bool, error)
+}
+
+// Upsert replaces the note of the backend with the same ID, or creates it if there is none.
+// Backends that implement Upserter do it in a single operation; for the others, the note
+// is updated, and created if the update finds no note, so a note created concurrently in
+// between makes the creation fail.
+//
+// Parameters:
+//   - ctx: The context for the operation
+//   - backend: The storage backend, which may implement Upserter
+//   - note: The note to store
+//
+// Returns:
+//   - Whether the note was created (otherwise an existing note was replaced)
+//   - An error if the note cannot be stored
+func Upsert(ctx context.Context, backend NoteWriter, note *model.Note) (bool, error) {
+	if upserter, ok := backend.(Upserter); ok {
+		return upserter.Upsert(ctx, note)
+	}
+
+	err := backend.Update(ctx, note)
+	if !errors.Is(err, ErrNoteNotFound) {
+		return false, err
+	}
+	if err := backend.Create(ctx, note); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"golang-simple-notes/model"
+)
+
+// TestUpsert_Fallback verifies that Upsert updates, and creates missing notes, on backends
+// that don't implement it natively
+func TestUpsert_Fallback(t *testing.T) {
+	ctx := context.Background()
+	backend := NewInMemoryStorage()
+	plain := getterStorage{backend}
+
+	if created, err := Upsert(ctx, plain, &model.Note{ID: "a", Title: "Created"}); err != nil || !created {
+		t.Fatalf("Expected the note to be created, got %t: %v", created, err)
+	}
+	if created, err := Upsert(ctx, plain, &model.Note{ID: "a", Title: "Replaced"}); err != nil || created {
+		t.Fatalf("Expected the note to be replaced, got %t: %v", created, err)
+	}
+	if note, err := backend.Get(ctx, "a"); err != nil || note.Title != "Replaced" {
+		t.Errorf("Expected the replaced note, got %+v: %v", note, err)
+	}
+}
@@ -0,0 +1,116 @@
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxInputBytes is the maximum size of the content sent to an LLM; longer notes are
+// truncated, which keeps requests within the context window of most models.
+const maxInputBytes = 32 << 10
+
+// maxErrorBody is the number of bytes of an error response included in errors.
+const maxErrorBody = 512
+
+// LLM summarizes notes with an external LLM API compatible with OpenAI's chat completions
+// (OpenAI, Azure OpenAI, Ollama, vLLM, LiteLLM, ...).
+type LLM struct {
+	endpoint  string       // Base URL of the API, to which /chat/completions is appended
+	apiKey    string       // Bearer token of the API (optional, e.g., for a local server)
+	model     string       // Model that writes the summaries
+	sentences int          // Maximum number of sentences asked for
+	client    *http.Client // Client of the API, with the request timeout
+}
+
+// NewLLM creates a summarizer backed by an external LLM API. No request is made, so the
+// API doesn't have to be reachable yet.
+//
+// Parameters:
+//   - endpoint: The base URL of the API (e.g., https://api.openai.com/v1)
+//   - apiKey: The API key, sent as a bearer token; empty to send none
+//   - model: The model that writes the summaries (e.g., gpt-4o-mini)
+//   - sentences: The maximum number of sentences of a summary
+//   - timeout: The timeout of every request
+//
+// Returns:
+//   - A pointer to a new LLM summarizer
+func NewLLM(endpoint, apiKey, model string, sentences int, timeout time.Duration) *LLM {
+	return &LLM{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		apiKey:    apiKey,
+		model:     model,
+		sentences: max(1, sentences),
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// chatMessage is a message of a chat completion request or response.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Summarize asks the model for a summary of a note. Notes longer than 32 KiB are
+// truncated first.
+func (l *LLM) Summarize(ctx context.Context, title, content string) (string, error) {
+	if len(content) > maxInputBytes {
+		content = content[:maxInputBytes]
+		// Don't send a partial UTF-8 sequence
+		for !utf8.ValidString(content) {
+			content = content[:len(content)-1]
+		}
+	}
+	body, err := json.Marshal(struct {
+		Model    string        `json:"model"`
+		Messages []chatMessage `json:"messages"`
+	}{
+		Model: l.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: fmt.Sprintf("Summarize the note of the user in at most %d sentences, "+
+				"in the language of the note. Reply with the summary only.", l.sentences)},
+			{Role: "user", Content: title + "\n\n" + content},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return "", fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var completion struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+	if len(completion.Choices) == 0 || strings.TrimSpace(completion.Choices[0].Message.Content) == "" {
+		return "", errors.New("the model returned no summary")
+	}
+	return strings.TrimSpace(completion.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,153 @@
+// Package summarizer implements service.Summarizer: a naive extractive summarizer that
+// picks the most representative sentences of a note, and a client of an external LLM
+// (large language model) API that writes the summary instead.
+package summarizer
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Supported summarizers.
+const (
+	KindExtractive = "extractive" // Extractive summarizer, without external services
+	KindLLM        = "llm"        // External LLM API
+)
+
+// IsKind reports whether name is a supported summarizer.
+func IsKind(name string) bool {
+	return name == KindExtractive || name == KindLLM
+}
+
+// stopWords are frequent English words that say nothing about the subject of a text, so
+// they don't count towards the score of a sentence.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "has": true, "have": true, "i": true,
+	"in": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "we": true, "were": true,
+	"will": true, "with": true, "you": true,
+}
+
+// Extractive summarizes a note with its most representative sentences: those whose words
+// are the most frequent in the note, in the order they appear in. It needs no external
+// service, but only picks sentences, never rephrases them.
+type Extractive struct {
+	sentences int // Maximum number of sentences of a summary
+}
+
+// NewExtractive creates an extractive summarizer.
+//
+// Parameters:
+//   - sentences: The maximum number of sentences of a summary (at least 1)
+//
+// Returns:
+//   - A pointer to a new Extractive summarizer
+func NewExtractive(sentences int) *Extractive {
+	return &Extractive{sentences: max(1, sentences)}
+}
+
+// Summarize returns the most representative sentences of the content of a note, or the
+// whole content if it has no more sentences than the summary. Every word of a sentence
+// scores the number of times it appears in the note (or in its title, which counts
+// twice); sentences are ranked by their average score, so long sentences aren't favored.
+func (e *Extractive) Summarize(_ context.Context, title, content string) (string, error) {
+	sentences := splitSentences(content)
+	if len(sentences) <= e.sentences {
+		return strings.Join(sentences, " "), nil
+	}
+
+	frequency := make(map[string]int)
+	for _, word := range words(content) {
+		frequency[word]++
+	}
+	for _, word := range words(title) {
+		frequency[word] += 2
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	ranked := make([]scored, len(sentences))
+	for i, sentence := range sentences {
+		total, count := 0, 0
+		for _, word := range words(sentence) {
+			total += frequency[word]
+			count++
+		}
+		ranked[i] = scored{index: i}
+		if count > 0 {
+			ranked[i].score = float64(total) / float64(count)
+		}
+	}
+	// Stable, so the earlier of equally ranked sentences is picked
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	picked := make([]int, 0, e.sentences)
+	for _, s := range ranked[:e.sentences] {
+		picked = append(picked, s.index)
+	}
+	slices.Sort(picked)
+	summary := make([]string, len(picked))
+	for i, index := range picked {
+		summary[i] = sentences[index]
+	}
+	return strings.Join(summary, " "), nil
+}
+
+// splitSentences splits a text into sentences, which end with ".", "!", or "?" followed
+// by a space, or at the end of a line. Markdown headings, which are no sentences, and the
+// markers of lists and quotes are dropped.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimLeft(line, ">-*+ ")
+		start := 0
+		for i, r := range line {
+			if (r == '.' || r == '!' || r == '?') && i+1 < len(line) && line[i+1] == ' ' {
+				sentences = appendSentence(sentences, line[start:i+1])
+				start = i + 1
+			}
+		}
+		sentences = appendSentence(sentences, line[start:])
+	}
+	return sentences
+}
+
+// appendSentence appends a sentence, unless it has no letters or digits.
+func appendSentence(sentences []string, sentence string) []string {
+	sentence = strings.TrimSpace(sentence)
+	if strings.IndexFunc(sentence, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return sentences
+	}
+	return append(sentences, sentence)
+}
+
+// words returns the words of a text in lower case, without stop words.
+func words(text string) []string {
+	var result []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[word] {
+			result = append(result, word)
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExtractive tests that the most representative sentences are picked, in their order
+func TestExtractive(t *testing.T) {
+	content := "# Garden\n" +
+		"The tomatoes need water every morning. My neighbor has a new car! " +
+		"Tomatoes and peppers grow best in the sunny corner of the garden.\n" +
+		"- Buy more garden soil for the tomatoes?\n" +
+		"It rained yesterday."
+
+	summary, err := NewExtractive(2).Summarize(context.Background(), "Garden tomatoes", content)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	expected := "Tomatoes and peppers grow best in the sunny corner of the garden. Buy more garden soil for the tomatoes?"
+	if summary != expected {
+		t.Errorf("Expected %q, got %q", expected, summary)
+	}
+
+	// Short notes are their own summary
+	if summary, _ := NewExtractive(3).Summarize(context.Background(), "", "One.  Two!\n\n"); summary != "One. Two!" {
+		t.Errorf("Expected the whole content, got %q", summary)
+	}
+}
+
+// TestLLM tests the requests to an OpenAI-compatible chat completions API, and its errors
+func TestLLM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Model    string        `json:"model"`
+			Messages []chatMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.URL.Path != "/v1/chat/completions" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+			return
+		}
+		if request.Model != "small" || len(request.Messages) != 2 || !strings.Contains(request.Messages[0].Content, "at most 2 sentences") ||
+			request.Messages[1].Content != "Title\n\nContent" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  A summary.\n"}}]}`))
+	}))
+	defer server.Close()
+
+	summary, err := NewLLM(server.URL+"/v1/", "key", "small", 2, time.Second).Summarize(context.Background(), "Title", "Content")
+	if err != nil || summary != "A summary." {
+		t.Errorf("Expected the summary of the model, got %q: %v", summary, err)
+	}
+	_, err = NewLLM(server.URL+"/v1", "wrong", "small", 2, time.Second).Summarize(context.Background(), "Title", "Content")
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("Expected the error of the API, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package textnorm puts texts in the forms searches compare, so a query matches the notes
+// users expect regardless of how either was typed: "Café", "CAFE", and "cafe" followed
+// by a combining acute accent all match "café". Fold keeps diacritics, and Unaccent
+// drops them; Pattern turns a query into a regular expression for the databases that
+// search with one, matching the same texts.
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fold returns a text in Unicode normalization form C (NFC), case folded, so texts that
+// differ only by case or by the encoding of their accents compare equal: "Straße",
+// "STRASSE", and "strasse" all become "strasse".
+func Fold(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	return cases.Fold().String(norm.NFC.String(s))
+}
+
+// Unaccent returns a text case folded and without diacritics (the combining marks of its
+// canonical decomposition): "Crème Brûlée" becomes "creme brulee".
+func Unaccent(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+	folded, _ := Runes(s)
+	return string(folded)
+}
+
+// Runes returns the characters of Unaccent(s), and for each of them the index of the
+// character of s it comes from, to find where matches of Unaccent forms are in s. A
+// character can become several (ß becomes ss), or none (a combining mark).
+func Runes(s string) ([]rune, []int) {
+	folded := make([]rune, 0, len(s))
+	origins := make([]int, 0, len(s))
+	caser := cases.Fold()
+	for i, r := range []rune(s) {
+		if r < utf8.RuneSelf {
+			folded = append(folded, unicode.ToLower(r))
+			origins = append(origins, i)
+			continue
+		}
+		for _, f := range caser.String(stripMarks(r)) {
+			folded = append(folded, f)
+			origins = append(origins, i)
+		}
+	}
+	return folded, origins
+}
+
+// stripMarks returns a character without the combining marks of its canonical
+// decomposition; a combining mark on its own becomes empty.
+func stripMarks(r rune) string {
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// isASCII reports whether a text only has ASCII characters, whose forms are their lower case.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Pattern returns a regular expression matching the texts whose Unaccent form (or Fold
+// form, if accents must match) contains that of the query. Every character of the query
+// becomes the alternatives that have the same form, precomposed or decomposed, in upper
+// or lower case: "é" becomes (?:e|E|é|É|é|...) unless accents must match. The
+// expression only uses literals and groups, so both byte-oriented engines (CouchDB's) and
+// Unicode-aware ones (MongoDB's) run it the same way.
+//
+// The alternatives cover the Latin, Greek, and Cyrillic alphabets; other characters only
+// match themselves, in upper or lower case, and characters whose form has several
+// characters (ß is ss) only match that form. When accents must match, a letter followed
+// by a combining accent (decomposed) still matches the letter without it.
+func Pattern(query string, accents bool) string {
+	form, variants := Unaccent, unaccentVariants()
+	if accents {
+		form, variants = Fold, foldVariants()
+	}
+	var b strings.Builder
+	for _, r := range form(query) {
+		alternatives := variants[r]
+		switch len(alternatives) {
+		case 0:
+			b.WriteString("(?i:" + regexp.QuoteMeta(string(r)) + ")")
+			continue
+		case 1:
+			b.WriteString(regexp.QuoteMeta(alternatives[0]))
+			continue
+		}
+		b.WriteString("(?:")
+		for i, alternative := range alternatives {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			b.WriteString(regexp.QuoteMeta(alternative))
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// patternRanges are the characters that Pattern knows the variants of: Basic Latin, Latin-1
+// Supplement, Latin Extended A and B, Greek, Cyrillic, and Latin Extended Additional.
+var patternRanges = [][2]rune{{0x20, 0x24f}, {0x370, 0x52f}, {0x1e00, 0x1eff}}
+
+// foldVariants and unaccentVariants return, for every character, the characters of
+// patternRanges that have it as their Fold or Unaccent form, and their decompositions.
+var (
+	foldVariants     = sync.OnceValue(func() map[rune][]string { return variantsOf(Fold) })
+	unaccentVariants = sync.OnceValue(func() map[rune][]string { return variantsOf(Unaccent) })
+)
+
+// variantsOf maps the characters of patternRanges by their form, skipping those whose
+// form isn't a single character.
+func variantsOf(form func(string) string) map[rune][]string {
+	variants := make(map[rune][]string)
+	for _, bounds := range patternRanges {
+		for r := bounds[0]; r <= bounds[1]; r++ {
+			if !unicode.IsPrint(r) || unicode.Is(unicode.Mn, r) {
+				continue
+			}
+			formed := []rune(form(string(r)))
+			if len(formed) != 1 {
+				continue
+			}
+			variants[formed[0]] = append(variants[formed[0]], string(r))
+			if decomposed := norm.NFD.String(string(r)); decomposed != string(r) {
+				variants[formed[0]] = append(variants[formed[0]], decomposed)
+			}
+		}
+	}
+	return variants
+}
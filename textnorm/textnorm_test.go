@@ -0,0 +1,68 @@
+package textnorm
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestForms tests the Fold and Unaccent forms of texts
+func TestForms(t *testing.T) {
+	tests := []struct {
+		text     string
+		fold     string
+		unaccent string
+	}{
+		{"Plain ASCII", "plain ascii", "plain ascii"},
+		{"Café", "café", "cafe"},
+		{"CAFÉ", "café", "cafe"},
+		{"Straße", "strasse", "strasse"},
+		{"Crème BRÛLÉE", "crème brûlée", "creme brulee"},
+		{"ΣΟΦΊΑ", "σοφία", "σοφια"},
+		{"Ёлка", "ёлка", "елка"},
+	}
+	for _, tt := range tests {
+		if got := Fold(tt.text); got != tt.fold {
+			t.Errorf("Fold(%q): expected %q, got %q", tt.text, tt.fold, got)
+		}
+		if got := Unaccent(tt.text); got != tt.unaccent {
+			t.Errorf("Unaccent(%q): expected %q, got %q", tt.text, tt.unaccent, got)
+		}
+	}
+
+	// Every character of the form points to the character of the text it comes from
+	folded, origins := Runes("Café ß")
+	if string(folded) != "cafe ss" || !reflect.DeepEqual(origins, []int{0, 1, 2, 3, 5, 6, 6}) {
+		t.Errorf("Unexpected runes %q from %v", string(folded), origins)
+	}
+}
+
+// TestPattern verifies that the patterns of queries match the same texts as their forms
+func TestPattern(t *testing.T) {
+	texts := []string{"Café au lait", "CAFE\u0301 NOIR", "cafe", "Crème brûlée", "creme brulee", "Ελληνικά", "1+1=2"}
+	queries := []string{"café", "CAFE", "café n", "crème", "BRULEE", "ελληνικα", "1+1", "lait"}
+	for _, accents := range []bool{false, true} {
+		form := Unaccent
+		if accents {
+			form = Fold
+		}
+		for _, query := range queries {
+			pattern := regexp.MustCompile(Pattern(query, accents))
+			for _, text := range texts {
+				want := strings.Contains(form(text), form(query))
+				if accents && strings.Contains(Fold(strings.ReplaceAll(text, "\u0301", "")), Fold(query)) {
+					// A letter followed by a combining accent also matches the bare letter
+					want = true
+				}
+				if got := pattern.MatchString(text); got != want {
+					t.Errorf("Pattern(%q, %t) on %q: expected %t, got %t", query, accents, text, want, got)
+				}
+			}
+		}
+	}
+
+	if pattern := Pattern("1.2", false); pattern != `1\.2` {
+		t.Errorf("Expected characters without variants to stay literals, got %s", pattern)
+	}
+}
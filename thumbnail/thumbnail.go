@@ -0,0 +1,121 @@
+// Package thumbnail creates thumbnails of images: it decodes JPEG, PNG, and GIF images,
+// scales them down with an area-averaging (box) filter, and encodes them again. Only the
+// standard library is used, so no image processing service or C library is needed.
+package thumbnail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// ErrUnsupported is returned (wrapped) when an image cannot be decoded, or is too large.
+var ErrUnsupported = errors.New("unsupported image")
+
+// MaxPixels is the maximum number of pixels of a decoded image, which bounds the memory
+// used by a single thumbnail (4 bytes per pixel) whatever the size of the compressed file.
+const MaxPixels = 40_000_000
+
+// jpegQuality is the quality of JPEG thumbnails.
+const jpegQuality = 85
+
+// Supported reports whether thumbnails can be created from images of a content type.
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// Decode decodes an image, checking its dimensions before decoding its pixels.
+//
+// Returns:
+//   - The image and its format ("jpeg", "png", or "gif"); the first frame of animated GIFs
+//   - An error wrapping ErrUnsupported if the image cannot be decoded or has more than
+//     MaxPixels pixels, or the read error
+func Decode(r io.Reader) (image.Image, string, error) {
+	// Peek at the header for the dimensions, then decode from the same buffer
+	buffered := bufio.NewReader(r)
+	var header bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(buffered, &header))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d pixels", ErrUnsupported, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(io.MultiReader(&header, buffered))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return img, format, nil
+}
+
+// Scale scales an image down so that its longer side is at most size pixels, keeping its
+// aspect ratio. Every pixel of the thumbnail is the average of the pixels it covers. Images
+// that already fit are returned as they are, never scaled up.
+func Scale(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	tw, th := size, size
+	if w >= h {
+		th = max(1, h*size/w)
+	} else {
+		tw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := range th {
+		y0, y1 := span(y, th, h)
+		for x := range tw {
+			x0, x1 := span(x, tw, w)
+			// Average the premultiplied 16-bit channels of the covered pixels
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// span returns the range of source pixels covered by pixel i of n, out of total pixels.
+func span(i, n, total int) (int, int) {
+	start, end := i*total/n, (i+1)*total/n
+	return start, max(end, start+1)
+}
+
+// Encode encodes a thumbnail: as JPEG if the original image was a JPEG, which has no
+// transparency, and as PNG otherwise.
+//
+// Returns:
+//   - The content type of the encoded thumbnail
+//   - The encoding error
+func Encode(w io.Writer, img image.Image, format string) (string, error) {
+	if format == "jpeg" {
+		return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return "image/png", png.Encode(w, img)
+}
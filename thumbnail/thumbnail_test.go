@@ -0,0 +1,89 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// TestScale tests that images are scaled down to fit, keeping their aspect ratio, with
+// pixels averaged
+func TestScale(t *testing.T) {
+	// Left half black, right half white
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			if x >= 200 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	thumb := Scale(src, 100)
+	if got := thumb.Bounds(); got.Dx() != 100 || got.Dy() != 50 {
+		t.Fatalf("Expected a 100x50 thumbnail, got %v", got)
+	}
+	if r, _, _, _ := thumb.At(10, 10).RGBA(); r != 0 {
+		t.Errorf("Expected black on the left, got %d", r)
+	}
+	if r, _, _, _ := thumb.At(90, 10).RGBA(); r>>8 != 255 {
+		t.Errorf("Expected white on the right, got %d", r>>8)
+	}
+
+	// A thumbnail of a portrait image fits the height
+	if got := Scale(image.NewRGBA(image.Rect(0, 0, 30, 300)), 100).Bounds(); got.Dx() != 10 || got.Dy() != 100 {
+		t.Errorf("Expected a 10x100 thumbnail, got %v", got)
+	}
+	// Small images are not scaled up
+	if got := Scale(src, 1000); got != image.Image(src) {
+		t.Errorf("Expected the image itself, got %v", got.Bounds())
+	}
+}
+
+// TestDecodeEncode tests that thumbnails keep the format of JPEG images, and that other
+// images and invalid files are handled
+func TestDecodeEncode(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	var jpg, pngData bytes.Buffer
+	if err := jpeg.Encode(&jpg, src, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	if err := png.Encode(&pngData, src); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		data        []byte
+		contentType string
+	}{
+		"JPEG": {jpg.Bytes(), "image/jpeg"},
+		"PNG":  {pngData.Bytes(), "image/png"},
+	} {
+		img, format, err := Decode(bytes.NewReader(tc.data))
+		if err != nil {
+			t.Fatalf("%s: Decode failed: %v", name, err)
+		}
+		var out bytes.Buffer
+		contentType, err := Encode(&out, Scale(img, 16), format)
+		if err != nil || contentType != tc.contentType {
+			t.Errorf("%s: expected a %s thumbnail, got %q: %v", name, tc.contentType, contentType, err)
+		}
+		if config, _, err := image.DecodeConfig(&out); err != nil || config.Width != 16 {
+			t.Errorf("%s: expected a 16 pixels wide thumbnail, got %+v: %v", name, config, err)
+		}
+	}
+
+	if _, _, err := Decode(strings.NewReader("not an image")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+	if !Supported("image/png") || Supported("image/svg+xml") {
+		t.Error("Unexpected supported content types")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// loadTLSConfig builds the TLS configuration of the REST server from the configured
+// certificate files. If a client CA is configured, clients must present a certificate
+// signed by it (mutual TLS).
+//
+// Returns:
+//   - The TLS configuration, or nil if TLS is not enabled
+//   - An error if the configuration is incomplete or a file cannot be loaded
+func (a *App) loadTLSConfig() (*tls.Config, error) {
+	certFile, keyFile, clientCAFile := a.config.RESTTLSCert, a.config.RESTTLSKey, a.config.RESTTLSClientCA
+
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("client certificate verification requires REST_TLS_CERT and REST_TLS_KEY")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both REST_TLS_CERT and REST_TLS_KEY must be set to enable TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// Require and verify client certificates if a client CA is configured
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// setupRedirectServer creates the plain HTTP server that redirects every request
+// to the HTTPS REST server.
+func (a *App) setupRedirectServer() *http.Server {
+	return &http.Server{
+		Addr:              a.config.RESTRedirectAddr,
+		Handler:           httpsRedirectHandler(a.config.RESTPort),
+		ReadHeaderTimeout: a.config.HTTPReadHeaderTimeout,
+	}
+}
+
+// httpsRedirectHandler returns a handler that permanently redirects requests to
+// the same host and path over HTTPS, on the port of the given listen address.
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, port, err := net.SplitHostPort(httpsAddr)
+	if err != nil {
+		log.Printf("Invalid HTTPS address %q, redirecting to the default port: %v", httpsAddr, err)
+		port = ""
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // IPv6 literal without a port
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and key generated for tests.
+type testCertificate struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	tls     tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCertificate generates a certificate signed by parent, or a self-signed CA if parent is nil.
+func newTestCertificate(t *testing.T, name string, parent *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load key pair: %v", err)
+	}
+	return &testCertificate{cert: cert, key: key, tls: tlsCert, certPEM: certPEM, keyPEM: keyPEM}
+}
+
+// writeTestFile writes data to a file in the test's temporary directory and returns its path.
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestApp_LoadTLSConfig(t *testing.T) {
+	ca := newTestCertificate(t, "Test CA", nil)
+	server := newTestCertificate(t, "localhost", ca)
+	certFile := writeTestFile(t, "server.pem", server.certPEM)
+	keyFile := writeTestFile(t, "server-key.pem", server.keyPEM)
+	caFile := writeTestFile(t, "ca.pem", ca.certPEM)
+
+	t.Run("Disabled", func(t *testing.T) {
+		app := NewApp(&Config{})
+		tlsConfig, err := app.loadTLSConfig()
+		if err != nil || tlsConfig != nil {
+			t.Errorf("Expected no TLS configuration, got %v, %v", tlsConfig, err)
+		}
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		app := NewApp(&Config{RESTTLSCert: certFile, RESTTLSKey: keyFile})
+		tlsConfig, err := app.loadTLSConfig()
+		if err != nil {
+			t.Fatalf("Failed to load TLS configuration: %v", err)
+		}
+		if len(tlsConfig.Certificates) != 1 || tlsConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("Unexpected TLS configuration: %+v", tlsConfig)
+		}
+		if tlsConfig.ClientAuth != tls.NoClientCert {
+			t.Errorf("Expected no client certificate verification, got %v", tlsConfig.ClientAuth)
+		}
+	})
+
+	t.Run("MutualTLS", func(t *testing.T) {
+		app := NewApp(&Config{RESTTLSCert: certFile, RESTTLSKey: keyFile, RESTTLSClientCA: caFile})
+		tlsConfig, err := app.loadTLSConfig()
+		if err != nil {
+			t.Fatalf("Failed to load TLS configuration: %v", err)
+		}
+		if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert || tlsConfig.ClientCAs == nil {
+			t.Errorf("Expected client certificate verification, got %v", tlsConfig.ClientAuth)
+		}
+	})
+
+	errorCases := map[string]*Config{
+		"Missing key":            {RESTTLSCert: certFile},
+		"Missing certificate":    {RESTTLSKey: keyFile},
+		"Client CA without TLS":  {RESTTLSClientCA: caFile},
+		"Invalid certificate":    {RESTTLSCert: caFile, RESTTLSKey: keyFile},
+		"Missing client CA file": {RESTTLSCert: certFile, RESTTLSKey: keyFile, RESTTLSClientCA: filepath.Join(t.TempDir(), "missing.pem")},
+		"Empty client CA file":   {RESTTLSCert: certFile, RESTTLSKey: keyFile, RESTTLSClientCA: writeTestFile(t, "empty.pem", nil)},
+	}
+	for name, config := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewApp(config).loadTLSConfig(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// TestApp_MutualTLS tests that the mTLS configuration rejects clients without a trusted certificate
+func TestApp_MutualTLS(t *testing.T) {
+	ca := newTestCertificate(t, "Test CA", nil)
+	server := newTestCertificate(t, "localhost", ca)
+	client := newTestCertificate(t, "client", ca)
+	untrusted := newTestCertificate(t, "client", newTestCertificate(t, "Other CA", nil))
+
+	app := NewApp(&Config{
+		RESTTLSCert:     writeTestFile(t, "server.pem", server.certPEM),
+		RESTTLSKey:      writeTestFile(t, "server-key.pem", server.keyPEM),
+		RESTTLSClientCA: writeTestFile(t, "ca.pem", ca.certPEM),
+	})
+	tlsConfig, err := app.loadTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to load TLS configuration: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) error {
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		resp, err := httpClient.Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get(client.tls); err != nil {
+		t.Errorf("Expected a trusted client certificate to be accepted, got %v", err)
+	}
+	if err := get(); err == nil {
+		t.Error("Expected a client without a certificate to be rejected")
+	}
+	if err := get(untrusted.tls); err == nil {
+		t.Error("Expected an untrusted client certificate to be rejected")
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		host      string
+		target    string
+	}{
+		{":8443", "example.com:8079", "https://example.com:8443/api/notes?limit=1"},
+		{":443", "example.com:8079", "https://example.com/api/notes?limit=1"},
+		{":8443", "example.com", "https://example.com:8443/api/notes?limit=1"},
+		{":8443", "[::1]:8079", "https://[::1]:8443/api/notes?limit=1"},
+		{":8443", "[::1]", "https://[::1]:8443/api/notes?limit=1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://"+tt.host+"/api/notes?limit=1", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		httpsRedirectHandler(tt.httpsAddr).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s via %s: expected status code %d, got %d", tt.host, tt.httpsAddr, http.StatusPermanentRedirect, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != tt.target {
+			t.Errorf("%s via %s: expected redirect to %s, got %s", tt.host, tt.httpsAddr, tt.target, got)
+		}
+	}
+}
+
+func TestApp_InitializeWithTLS(t *testing.T) {
+	ca := newTestCertificate(t, "Test CA", nil)
+	server := newTestCertificate(t, "localhost", ca)
+	certFile := writeTestFile(t, "server.pem", server.certPEM)
+	keyFile := writeTestFile(t, "server-key.pem", server.keyPEM)
+
+	t.Run("Redirect", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory", RESTPort: ":8443", RESTTLSCert: certFile, RESTTLSKey: keyFile, RESTRedirectAddr: ":8079"})
+		if err := app.Initialize(context.Background()); err != nil {
+			t.Fatalf("Failed to initialize app: %v", err)
+		}
+		if app.restServer.TLSConfig == nil {
+			t.Error("Expected the REST server to use TLS")
+		}
+		if app.redirectServer == nil || app.redirectServer.Addr != ":8079" {
+			t.Errorf("Expected a redirect server on :8079, got %+v", app.redirectServer)
+		}
+	})
+
+	t.Run("RedirectWithoutTLS", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory", RESTRedirectAddr: ":8079"})
+		if err := app.Initialize(context.Background()); err == nil {
+			t.Error("Expected an error for a redirect without TLS")
+		}
+	})
+
+	t.Run("InvalidCertificate", func(t *testing.T) {
+		app := NewApp(&Config{StorageType: "memory", RESTTLSCert: certFile})
+		if err := app.Initialize(context.Background()); err == nil {
+			t.Error("Expected an error for an incomplete TLS configuration")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Package tracing configures OpenTelemetry tracing for the Notes API.
+// Spans are exported via OTLP over HTTP, configured by the standard OpenTelemetry
+// environment variables (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER, etc.).
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the default service name reported with spans,
+// used unless OTEL_SERVICE_NAME is set.
+const ServiceName = "golang-simple-notes"
+
+// instrumentationName identifies the tracer used by this application's own spans.
+const instrumentationName = "golang-simple-notes"
+
+// Tracer returns the tracer used for the application's own spans.
+// It uses the global tracer provider, so spans are no-ops until Setup enables tracing.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Enabled reports whether the environment asks for traces to be exported.
+// Tracing is enabled when an OTLP endpoint is configured, unless the SDK is
+// disabled (OTEL_SDK_DISABLED=true) or the traces exporter is set to "none".
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	if strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "none") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider and the W3C Trace Context and Baggage propagators.
+// If tracing is not enabled (see Enabled), only the propagators are installed, so incoming
+// trace context is still passed on to downstream services.
+//
+// Parameters:
+//   - ctx: The context for creating the exporter
+//
+// Returns:
+//   - A function that flushes pending spans and shuts down the tracer provider
+//   - An error if the exporter or resource cannot be created
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the OTEL_EXPORTER_OTLP_* variables (endpoint, headers, timeout, etc.)
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.Merge(
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	// The sampler is configured by OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
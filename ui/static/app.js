@@ -0,0 +1,174 @@
+// Single-page UI for managing notes through the REST API.
+// Note contents are always inserted as text, never as HTML.
+"use strict";
+
+(() => {
+  const api = new URL("../api/notes", document.baseURI);
+  const pageSize = 20;
+
+  const $ = (id) => document.getElementById(id);
+  const state = { offset: 0, total: 0, note: null };
+  let searchTimer;
+
+  // request calls the API and returns the parsed JSON body (null for 204 No Content).
+  // Failed requests throw an Error carrying the response status.
+  async function request(method, url, body) {
+    const options = { method, headers: {} };
+    if (body !== undefined) {
+      options.headers["Content-Type"] = "application/json";
+      options.body = JSON.stringify(body);
+    }
+    const response = await fetch(url, options);
+    if (!response.ok) {
+      const error = new Error((await response.text()).trim() || response.statusText);
+      error.status = response.status;
+      throw error;
+    }
+    if (response.status === 204) {
+      return { body: null, headers: response.headers };
+    }
+    return { body: await response.json(), headers: response.headers };
+  }
+
+  function noteURL(id) {
+    return new URL(encodeURIComponent(id), api.href + "/");
+  }
+
+  function formatTime(value) {
+    return new Date(value).toLocaleString();
+  }
+
+  // loadNotes fetches the current page of the list.
+  async function loadNotes() {
+    const url = new URL(api);
+    const query = $("search").value.trim();
+    if (query) {
+      url.searchParams.set("q", query);
+    }
+    url.searchParams.set("sort", $("sort").value);
+    url.searchParams.set("limit", pageSize);
+    url.searchParams.set("offset", state.offset);
+
+    $("status").textContent = "Loading…";
+    try {
+      const { body, headers } = await request("GET", url);
+      state.total = Number(headers.get("X-Total-Count")) || body.length;
+      renderNotes(body, query);
+    } catch (error) {
+      $("status").textContent = "Failed to load notes: " + error.message;
+    }
+  }
+
+  function renderNotes(notes, query) {
+    const list = $("notes");
+    list.replaceChildren();
+    for (const note of notes) {
+      const item = document.createElement("li");
+      const button = document.createElement("button");
+      button.type = "button";
+      const title = document.createElement("strong");
+      title.textContent = note.title || "(untitled)";
+      const excerpt = document.createElement("span");
+      excerpt.textContent = note.content.slice(0, 140);
+      const time = document.createElement("time");
+      time.dateTime = note.updated_at;
+      time.textContent = formatTime(note.updated_at);
+      button.append(title, excerpt, time);
+      button.addEventListener("click", () => openEditor(note));
+      item.append(button);
+      list.append(item);
+    }
+
+    if (notes.length === 0) {
+      $("status").textContent = query ? "No notes match your search." : "No notes yet.";
+    } else {
+      $("status").textContent = "";
+    }
+    const page = Math.floor(state.offset / pageSize) + 1;
+    const pages = Math.max(1, Math.ceil(state.total / pageSize));
+    $("page").textContent = `Page ${page} of ${pages} (${state.total} notes)`;
+    $("prev").disabled = state.offset === 0;
+    $("next").disabled = state.offset + pageSize >= state.total;
+  }
+
+  // openEditor shows the form for a note, or for a new note if note is null.
+  function openEditor(note) {
+    state.note = note;
+    $("title").value = note ? note.title : "";
+    $("content").value = note ? note.content : "";
+    $("meta").textContent = note
+      ? `Created ${formatTime(note.created_at)}, updated ${formatTime(note.updated_at)}`
+      : "";
+    $("error").textContent = "";
+    $("delete").hidden = !note;
+    $("list-view").hidden = true;
+    $("editor").hidden = false;
+    $("title").focus();
+  }
+
+  function closeEditor() {
+    state.note = null;
+    $("editor").hidden = true;
+    $("list-view").hidden = false;
+    loadNotes();
+  }
+
+  async function saveNote(event) {
+    event.preventDefault();
+    const input = { title: $("title").value, content: $("content").value };
+    $("save").disabled = true;
+    try {
+      if (state.note) {
+        // Send the revision that was loaded, so concurrent changes are not overwritten
+        await request("PUT", noteURL(state.note._id), { ...input, _rev: state.note._rev });
+      } else {
+        await request("POST", api, input);
+      }
+      closeEditor();
+    } catch (error) {
+      $("error").textContent = error.status === 409
+        ? "Someone else changed this note. Reopen it to see the latest version."
+        : "Failed to save: " + error.message;
+    } finally {
+      $("save").disabled = false;
+    }
+  }
+
+  async function deleteNote() {
+    if (!state.note || !confirm(`Delete "${state.note.title || "(untitled)"}"?`)) {
+      return;
+    }
+    try {
+      await request("DELETE", noteURL(state.note._id));
+      closeEditor();
+    } catch (error) {
+      $("error").textContent = "Failed to delete: " + error.message;
+    }
+  }
+
+  $("new-note").addEventListener("click", () => openEditor(null));
+  $("note-form").addEventListener("submit", saveNote);
+  $("cancel").addEventListener("click", closeEditor);
+  $("delete").addEventListener("click", deleteNote);
+  $("sort").addEventListener("change", () => {
+    state.offset = 0;
+    loadNotes();
+  });
+  $("search").addEventListener("input", () => {
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(() => {
+      state.offset = 0;
+      loadNotes();
+    }, 250);
+  });
+  $("prev").addEventListener("click", () => {
+    state.offset = Math.max(0, state.offset - pageSize);
+    loadNotes();
+  });
+  $("next").addEventListener("click", () => {
+    state.offset += pageSize;
+    loadNotes();
+  });
+
+  loadNotes();
+})();
@@ -0,0 +1,56 @@
+// Package ui serves a small single-page web UI for managing notes. The page lists,
+// searches, creates, edits, and deletes notes by calling the REST API from the browser,
+// so it needs no server-side logic of its own; its files are embedded in the binary.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// static holds the files of the UI: index.html, app.js, and style.css.
+//
+//go:embed static
+var static embed.FS
+
+// contentSecurityPolicy only allows the UI's own scripts, styles, and API calls,
+// so note contents can never run as code, even if they contain HTML. It is used
+// unless a policy was already set in front of the handler (e.g., by configuration).
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; " +
+	"img-src 'self' data:; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// Handler returns an HTTP handler serving the UI under the given path prefix
+// (e.g., "/ui/"). The page calls the REST API at "../api/" relative to the prefix.
+// Security headers already set by a middleware are kept; missing ones get restrictive defaults.
+//
+// Parameters:
+//   - prefix: The path under which the handler is mounted, with a trailing slash
+//
+// Returns:
+//   - An http.Handler serving the UI files
+func Handler(prefix string) http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		// The directory is embedded at compile time, so this cannot happen
+		panic(err)
+	}
+	fileServer := http.StripPrefix(prefix, http.FileServerFS(files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		setDefault(header, "Content-Security-Policy", contentSecurityPolicy)
+		setDefault(header, "X-Content-Type-Options", "nosniff")
+		setDefault(header, "Referrer-Policy", "no-referrer")
+		// Embedded files have no modification time, so make browsers revalidate after upgrades
+		header.Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// setDefault sets a header unless it already has a value.
+func setDefault(header http.Header, key, value string) {
+	if header.Get(key) == "" {
+		header.Set(key, value)
+	}
+}
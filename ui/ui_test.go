@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandler tests that the UI files are served under the prefix with restrictive headers
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/ui/", Handler("/ui/"))
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"/ui/", http.StatusOK, "text/html", `<script src="app.js" defer></script>`},
+		{"/ui/app.js", http.StatusOK, "javascript", `new URL("../api/notes"`},
+		{"/ui/style.css", http.StatusOK, "text/css", "--accent"},
+		{"/ui/missing.js", http.StatusNotFound, "text/plain", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.Contains(contentType, tt.contentType) {
+				t.Errorf("Expected Content-Type %s, got %s", tt.contentType, contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("Expected the body to contain %q", tt.contains)
+			}
+			if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self'") {
+				t.Errorf("Expected a content security policy, got %q", csp)
+			}
+		})
+	}
+}
+
+// TestHandlerKeepsPolicy tests that a content security policy set in front of the UI is kept
+func TestHandlerKeepsPolicy(t *testing.T) {
+	handler := Handler("/ui/")
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Security-Policy", "default-src 'self'")
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ui/", nil))
+
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "default-src 'self'" {
+		t.Errorf("Expected the configured policy to be kept, got %q", csp)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected the missing headers to get defaults, got %v", w.Header())
+	}
+}
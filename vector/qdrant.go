@@ -0,0 +1,189 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// qdrantTimeout bounds every request to Qdrant.
+const qdrantTimeout = 10 * time.Second
+
+// maxErrorBody is the number of bytes of an error response included in errors.
+const maxErrorBody = 512
+
+// Qdrant is a vector index in a collection of the Qdrant vector database, through its
+// REST API. The collection is created with the length of the first vector, with the
+// cosine distance. Note IDs are not valid Qdrant point IDs, so every point gets a UUID
+// derived from the ID of its note, which it keeps in its payload.
+type Qdrant struct {
+	endpoint   string       // Base URL of the REST API (e.g., http://qdrant:6333)
+	collection string       // Collection of the vectors
+	apiKey     string       // API key of the database (optional)
+	client     *http.Client // Client of the API, with the request timeout
+
+	mutex   sync.Mutex
+	created bool // Whether the collection is known to exist
+}
+
+// NewQdrant creates a vector index in a Qdrant collection. No request is made, so the
+// database doesn't have to be reachable yet.
+//
+// Parameters:
+//   - endpoint: The base URL of the REST API (e.g., http://qdrant:6333)
+//   - collection: The collection of the vectors, created when the first vector is added
+//   - apiKey: The API key of the database; empty to send none
+//
+// Returns:
+//   - A pointer to a new Qdrant index
+func NewQdrant(endpoint, collection, apiKey string) *Qdrant {
+	return &Qdrant{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		collection: collection,
+		apiKey:     apiKey,
+		client:     &http.Client{Timeout: qdrantTimeout},
+	}
+}
+
+// Upsert adds the vector of a note, or replaces it, creating the collection if needed.
+func (q *Qdrant) Upsert(ctx context.Context, id string, vector []float32) error {
+	point := map[string]any{
+		"id":      pointID(id),
+		"vector":  vector,
+		"payload": map[string]string{"note_id": id},
+	}
+	for retried := false; ; retried = true {
+		if err := q.ensureCollection(ctx, len(vector)); err != nil {
+			return err
+		}
+		found, err := q.do(ctx, http.MethodPut, "/points?wait=true", map[string]any{"points": []any{point}}, nil)
+		if err != nil || found {
+			return err
+		}
+		if retried {
+			return fmt.Errorf("Qdrant collection %s not found", q.collection)
+		}
+		// The collection was deleted since it was created: create it again
+		q.mutex.Lock()
+		q.created = false
+		q.mutex.Unlock()
+	}
+}
+
+// Delete removes the vector of a note, if it has one.
+func (q *Qdrant) Delete(ctx context.Context, id string) error {
+	// Without the collection, there is nothing to delete
+	_, err := q.do(ctx, http.MethodPost, "/points/delete?wait=true", map[string]any{"points": []string{pointID(id)}}, nil)
+	return err
+}
+
+// Search returns the limit vectors most similar to the query, most similar first.
+func (q *Qdrant) Search(ctx context.Context, query []float32, limit int) ([]Match, error) {
+	var response struct {
+		Result []struct {
+			Score   float64           `json:"score"`
+			Payload map[string]string `json:"payload"`
+		} `json:"result"`
+	}
+	found, err := q.do(ctx, http.MethodPost, "/points/search", map[string]any{
+		"vector":       query,
+		"limit":        limit,
+		"with_payload": true,
+	}, &response)
+	if err != nil || !found {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(response.Result))
+	for _, result := range response.Result {
+		if id := result.Payload["note_id"]; id != "" {
+			matches = append(matches, Match{ID: id, Score: result.Score})
+		}
+	}
+	return matches, nil
+}
+
+// ensureCollection creates the collection for vectors of the given length, unless it
+// exists. Collections that exist are never checked or changed.
+func (q *Qdrant) ensureCollection(ctx context.Context, size int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.created {
+		return nil
+	}
+	found, err := q.do(ctx, http.MethodGet, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	if !found {
+		_, err := q.do(ctx, http.MethodPut, "", map[string]any{
+			"vectors": map[string]any{"size": size, "distance": "Cosine"},
+		}, nil)
+		// Another instance may have created it in the meantime
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("failed to create Qdrant collection %s: %w", q.collection, err)
+		}
+	}
+	q.created = true
+	return nil
+}
+
+// do sends a request about the collection and decodes its JSON response into out, if not
+// nil. Responses other than 200 OK are returned as errors, with the start of their body.
+//
+// Returns:
+//   - Whether the collection exists (false on 404 Not Found, which is not an error)
+//   - The error of the request
+func (q *Qdrant) do(ctx context.Context, method, path string, in, out any) (bool, error) {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return false, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, q.endpoint+"/collections/"+url.PathEscape(q.collection)+path, body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.apiKey != "" {
+		req.Header.Set("api-key", q.apiKey)
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return false, fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return false, fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+		}
+	}
+	return true, nil
+}
+
+// pointID returns the Qdrant point ID of a note: a UUID (version 8, custom) made of the
+// SHA-256 hash of the note ID.
+func pointID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	sum[6] = sum[6]&0x0f | 0x80 // Version 8
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
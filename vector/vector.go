@@ -0,0 +1,124 @@
+// Package vector implements service.VectorIndex: indexes of the embeddings of notes that
+// find the nearest neighbors of a query vector by cosine similarity. The in-memory index
+// compares the query with every vector; Qdrant keeps the vectors in an external vector
+// database, shared by all instances and kept across restarts.
+package vector
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Supported vector indexes.
+const (
+	KindMemory = "memory" // In-memory index of this instance
+	KindQdrant = "qdrant" // Qdrant vector database
+)
+
+// IsKind reports whether name is a supported vector index.
+func IsKind(name string) bool {
+	return name == KindMemory || name == KindQdrant
+}
+
+// Match is a nearest neighbor of a query vector.
+type Match struct {
+	ID    string  // ID of the note of the vector
+	Score float64 // Cosine similarity with the query, from -1 to 1 (1 is the same direction)
+}
+
+// MemoryIndex is an in-memory vector index, searched exhaustively: every search compares
+// the query with every vector, which takes a few milliseconds for tens of thousands of
+// notes. The index is lost on restart.
+type MemoryIndex struct {
+	mutex   sync.RWMutex
+	vectors map[string][]float32 // Normalized vectors, by note ID
+}
+
+// NewMemoryIndex creates an empty in-memory vector index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{vectors: make(map[string][]float32)}
+}
+
+// Upsert adds the vector of a note, or replaces it.
+func (m *MemoryIndex) Upsert(_ context.Context, id string, vector []float32) error {
+	normalized := normalized(vector)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.vectors[id] = normalized
+	return nil
+}
+
+// Delete removes the vector of a note, if it has one.
+func (m *MemoryIndex) Delete(_ context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.vectors, id)
+	return nil
+}
+
+// Search returns the limit vectors most similar to the query, most similar first. Vectors
+// of another length than the query (e.g., from a previous embedding model) are skipped.
+func (m *MemoryIndex) Search(_ context.Context, query []float32, limit int) ([]Match, error) {
+	query = normalized(query)
+	m.mutex.RLock()
+	matches := make([]Match, 0, len(m.vectors))
+	for id, vector := range m.vectors {
+		if len(vector) == len(query) {
+			matches = append(matches, Match{ID: id, Score: dot(query, vector)})
+		}
+	}
+	m.mutex.RUnlock()
+
+	// Ties are broken by ID, so results are stable
+	slices.SortFunc(matches, func(a, b Match) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return strings.Compare(a.ID, b.ID)
+		}
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// Len returns the number of vectors in the index.
+func (m *MemoryIndex) Len() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.vectors)
+}
+
+// normalized returns a copy of a vector scaled to unit length, so the dot product of two
+// vectors is their cosine similarity. Zero vectors stay zero.
+func normalized(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	result := make([]float32, len(vector))
+	if sum == 0 {
+		return result
+	}
+	norm := math.Sqrt(sum)
+	for i, v := range vector {
+		result[i] = float32(float64(v) / norm)
+	}
+	return result
+}
+
+// dot returns the dot product of two vectors of the same length.
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
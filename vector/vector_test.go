@@ -0,0 +1,155 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestMemoryIndex tests the order and limit of searches, replacing and deleting vectors
+func TestMemoryIndex(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex()
+	index.Upsert(ctx, "a", []float32{1, 0})
+	index.Upsert(ctx, "b", []float32{1, 1})
+	index.Upsert(ctx, "c", []float32{0, 2})
+	index.Upsert(ctx, "old", []float32{1, 0, 0})
+
+	matches, err := index.Search(ctx, []float32{3, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != "a" || matches[1].ID != "b" {
+		t.Fatalf("Expected a and b, got %v", matches)
+	}
+	if matches[0].Score < 0.999 || matches[1].Score > 0.71 {
+		t.Errorf("Expected cosine similarities, got %v", matches)
+	}
+
+	index.Upsert(ctx, "a", []float32{0, 1})
+	index.Delete(ctx, "b")
+	matches, _ = index.Search(ctx, []float32{0, 1}, 0)
+	if len(matches) != 2 || matches[0].ID != "a" || matches[1].ID != "c" {
+		t.Errorf("Expected a and c, tied and ordered by ID, got %v", matches)
+	}
+	if index.Len() != 3 {
+		t.Errorf("Expected 3 vectors, got %d", index.Len())
+	}
+}
+
+// fakeQdrant is a minimal Qdrant REST API with a single collection.
+type fakeQdrant struct {
+	mutex   sync.Mutex
+	exists  bool
+	size    int
+	apiKey  string
+	payload map[string]string // Note IDs by point ID
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.apiKey = r.Header.Get("api-key")
+	var body struct {
+		Vectors struct {
+			Size int `json:"size"`
+		} `json:"vectors"`
+		Points []json.RawMessage `json:"points"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	path := strings.TrimPrefix(r.URL.Path, "/collections/notes")
+	if !f.exists && !(r.Method == http.MethodPut && path == "") {
+		http.Error(w, `{"status":{"error":"Not found"}}`, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && path == "":
+	case r.Method == http.MethodPut && path == "":
+		f.exists, f.size = true, body.Vectors.Size
+	case r.Method == http.MethodPut && path == "/points":
+		for _, raw := range body.Points {
+			var point struct {
+				ID      string            `json:"id"`
+				Payload map[string]string `json:"payload"`
+			}
+			json.Unmarshal(raw, &point)
+			f.payload[point.ID] = point.Payload["note_id"]
+		}
+	case r.Method == http.MethodPost && path == "/points/delete":
+		for _, raw := range body.Points {
+			var id string
+			json.Unmarshal(raw, &id)
+			delete(f.payload, id)
+		}
+	case r.Method == http.MethodPost && path == "/points/search":
+		// Every point matches, with a score decreasing in the order of the note IDs
+		var ids []string
+		for _, id := range f.payload {
+			ids = append(ids, id)
+		}
+		var result []map[string]any
+		slices.Sort(ids)
+		for i, id := range ids {
+			result = append(result, map[string]any{"score": 1 - float64(i)/10, "payload": map[string]string{"note_id": id}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"result": result})
+		return
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(`{"result":true,"status":"ok"}`))
+}
+
+// TestQdrant tests that the collection is created with the first vector, and recreated
+// if it disappears, and that the note IDs are read from the payloads of the points
+func TestQdrant(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeQdrant{payload: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	index := NewQdrant(server.URL+"/", "notes", "key")
+
+	// Searching a collection that doesn't exist finds nothing
+	if matches, err := index.Search(ctx, []float32{1, 0, 0}, 5); err != nil || len(matches) != 0 {
+		t.Fatalf("Expected no matches, got %v: %v", matches, err)
+	}
+	if err := index.Upsert(ctx, "note-b", []float32{0, 1, 0}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if err := index.Upsert(ctx, "note-a", []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if !fake.exists || fake.size != 3 || fake.apiKey != "key" {
+		t.Errorf("Expected a collection of 3 dimensions created with the API key, got %+v", fake)
+	}
+	matches, err := index.Search(ctx, []float32{1, 0, 0}, 5)
+	if err != nil || len(matches) != 2 || matches[0].ID != "note-a" || matches[1].ID != "note-b" {
+		t.Errorf("Expected note-a and note-b, got %v: %v", matches, err)
+	}
+
+	if err := index.Delete(ctx, "note-a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if matches, _ := index.Search(ctx, []float32{1, 0, 0}, 5); len(matches) != 1 {
+		t.Errorf("Expected note-b only, got %v", matches)
+	}
+
+	// The collection is created again if it was deleted
+	fake.mutex.Lock()
+	fake.exists, fake.payload = false, make(map[string]string)
+	fake.mutex.Unlock()
+	if err := index.Upsert(ctx, "note-c", []float32{0, 0, 1}); err != nil || !fake.exists {
+		t.Errorf("Expected the collection to be created again: %v", err)
+	}
+
+	if pointID("note-a") == pointID("note-b") || len(pointID("note-a")) != 36 {
+		t.Errorf("Expected distinct UUIDs, got %s", pointID("note-a"))
+	}
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+)
+
+// Broadcaster delivers every note event to in-process stream subscribers, regardless
+// of the note, such as WebSocket connections that filter the events themselves.
+// It is safe for concurrent use.
+type Broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan events.Event]struct{}
+	closed      bool // Whether Close has been called; later subscribers get a closed channel
+}
+
+// NewBroadcaster creates a broadcaster without subscribers.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan events.Event]struct{})}
+}
+
+// Subscribe registers a stream subscriber for the events of all notes.
+// Events are delivered on the returned channel until the returned cancel
+// function is called or the broadcaster is closed; in both cases the channel is closed.
+func (b *Broadcaster) Subscribe() (<-chan events.Event, func()) {
+	ch := make(chan events.Event, subscriberBuffer)
+
+	b.mutex.Lock()
+	if b.closed {
+		close(ch)
+	} else {
+		b.subscribers[ch] = struct{}{}
+	}
+	b.mutex.Unlock()
+
+	cancel := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Notify delivers an event to every subscriber.
+// Events for slow subscribers are dropped rather than blocking writers.
+func (b *Broadcaster) Notify(ctx context.Context, event events.Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("%sDropping %s event of note %s for slow subscriber",
+				requestid.LogPrefix(ctx), event.Type, event.NoteID)
+		}
+	}
+}
+
+// Close closes the channels of all subscribers, so they can end their streams
+// (e.g., close their WebSocket connections) when the application shuts down.
+func (b *Broadcaster) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.subscribers {
+		close(ch)
+	}
+	clear(b.subscribers)
+	b.closed = true
+}
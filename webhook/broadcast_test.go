@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"golang-simple-notes/events"
+)
+
+// TestBroadcaster tests that subscribers receive the events of all notes until they cancel
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	ctx := context.Background()
+
+	received, cancel := b.Subscribe()
+	b.Notify(ctx, events.Event{Type: events.NoteCreated, NoteID: "note-1"})
+	b.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: "note-2"})
+
+	if event := <-received; event.Type != events.NoteCreated || event.NoteID != "note-1" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if event := <-received; event.Type != events.NoteDeleted || event.NoteID != "note-2" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Canceling closes the channel; canceling twice is safe
+	cancel()
+	if _, ok := <-received; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	cancel()
+}
+
+// TestBroadcaster_Close tests that closing ends all streams, including later ones
+func TestBroadcaster_Close(t *testing.T) {
+	b := NewBroadcaster()
+
+	received, cancel := b.Subscribe()
+	defer cancel()
+	b.Close()
+	if _, ok := <-received; ok {
+		t.Error("Expected channel to be closed after Close")
+	}
+
+	later, cancelLater := b.Subscribe()
+	defer cancelLater()
+	if _, ok := <-later; ok {
+		t.Error("Expected a closed channel after Close")
+	}
+}
@@ -0,0 +1,381 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/requestid"
+	"golang-simple-notes/storage"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"     // Event type (e.g., "note.created")
+	HeaderDelivery  = "X-Webhook-Delivery"  // ID of the delivery, the same for every attempt
+	HeaderSignature = "X-Webhook-Signature" // "t=<unix time>,v1=<hex HMAC-SHA256>" (see Sign)
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"   // Not delivered yet; attempts are still being made
+	DeliverySucceeded = "succeeded" // The endpoint accepted the event with a 2xx response
+	DeliveryFailed    = "failed"    // Every attempt failed, or delivery was canceled at shutdown
+)
+
+// maxDeliveries is the number of most recent deliveries kept for the delivery status endpoint.
+const maxDeliveries = 200
+
+var (
+	// ErrHookNotFound is returned when a webhook with the specified ID doesn't exist.
+	ErrHookNotFound = errors.New("webhook not found")
+
+	// ErrSecretRequired is returned when a webhook is registered without a secret
+	// and no default secret is configured, so its payloads could not be signed.
+	ErrSecretRequired = errors.New("webhook secret is required")
+
+	// ErrUnknownEvent is returned when a webhook subscribes to an unknown event type.
+	ErrUnknownEvent = errors.New("unknown event type")
+)
+
+// Hook is a webhook registered by an operator, which receives every note event
+// (or the subscribed ones), regardless of the note.
+type Hook struct {
+	ID        string    `json:"id"`               // Unique identifier of the webhook
+	URL       string    `json:"url"`              // URL that receives signed event payloads
+	Events    []string  `json:"events,omitempty"` // Subscribed event types; empty means all
+	Source    string    `json:"source"`           // "config" or "api"
+	CreatedAt time.Time `json:"created_at"`       // When the webhook was registered
+	secret    string    // Key of the payload signatures
+}
+
+// subscribes reports whether the webhook receives events of the given type.
+func (h Hook) subscribes(eventType string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, eventType)
+}
+
+// Delivery is the status of delivering a single event to a single webhook.
+type Delivery struct {
+	ID          string     `json:"id"`                     // Unique identifier, sent in the X-Webhook-Delivery header
+	HookID      string     `json:"hook_id"`                // ID of the receiving webhook
+	Event       string     `json:"event"`                  // Event type
+	NoteID      string     `json:"note_id"`                // ID of the note the event is about
+	Status      string     `json:"status"`                 // DeliveryPending, DeliverySucceeded, or DeliveryFailed
+	Attempts    int        `json:"attempts"`               // Number of attempts made so far
+	StatusCode  int        `json:"status_code,omitempty"`  // HTTP status of the last response, if any
+	LastError   string     `json:"last_error,omitempty"`   // Why the last attempt failed
+	CreatedAt   time.Time  `json:"created_at"`             // When the event was queued
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // When the delivery succeeded or was given up
+}
+
+// Hooks keeps the webhooks registered by operators and delivers note events to them.
+// Payloads are signed with HMAC-SHA256, and every delivery is a background job, retried
+// with exponential backoff. Webhooks registered at runtime are kept in memory, so they
+// are lost when the application restarts. It is safe for concurrent use.
+type Hooks struct {
+	client        *http.Client        // HTTP client used to deliver events
+	private       bool                // Whether webhooks may target private addresses
+	retry         storage.RetryPolicy // Attempts and backoff of every delivery
+	defaultSecret string              // Secret of webhooks registered without one
+	runner        *jobs.Runner        // Runs the deliveries
+
+	deliveries sync.WaitGroup // Deliveries in flight, including their retries
+
+	mutex  sync.RWMutex
+	hooks  []Hook               // Registered webhooks, in registration order
+	status map[string]*Delivery // Recent deliveries by ID
+	recent []string             // IDs of the recent deliveries, oldest first
+}
+
+// NewHooks creates an empty webhook registry. Webhooks targeting private, loopback, and
+// link-local addresses are rejected unless AllowPrivateTargets is called.
+//
+// Parameters:
+//   - retry: The attempts and backoff of every delivery; its attempt timeout limits each request
+//   - defaultSecret: The secret used to sign payloads for webhooks registered without their own
+//   - runner: The job runner that makes the deliveries; closing it abandons the deliveries in flight
+//
+// Returns:
+//   - A pointer to a new Hooks instance
+func NewHooks(retry storage.RetryPolicy, defaultSecret string, runner *jobs.Runner) *Hooks {
+	return &Hooks{
+		client:        newCallbackClient(0, false),
+		retry:         retry,
+		defaultSecret: defaultSecret,
+		runner:        runner,
+		status:        make(map[string]*Delivery),
+	}
+}
+
+// AllowPrivateTargets lets webhooks target private, loopback, and link-local addresses
+// (e.g., services on the same network). It must be called before the hooks are used.
+func (h *Hooks) AllowPrivateTargets() {
+	h.private = true
+	h.client = newCallbackClient(0, true)
+}
+
+// Add registers a webhook.
+//
+// Parameters:
+//   - url: The absolute http(s) URL that receives the events
+//   - eventTypes: The event types to deliver; empty means all
+//   - secret: The key of the payload signatures; empty means the default secret
+//   - source: Where the webhook comes from ("config" or "api")
+//
+// Returns:
+//   - The registered webhook
+//   - ErrInvalidCallbackURL, ErrPrivateCallbackURL, ErrUnknownEvent, or ErrSecretRequired
+//     if the webhook is invalid
+func (h *Hooks) Add(url string, eventTypes []string, secret, source string) (Hook, error) {
+	if !isValidCallbackURL(url) {
+		return Hook{}, ErrInvalidCallbackURL
+	}
+	if !h.private {
+		if err := checkCallbackHost(url); err != nil {
+			return Hook{}, err
+		}
+	}
+	for _, event := range eventTypes {
+		if !slices.Contains(events.Types, event) {
+			return Hook{}, fmt.Errorf("%w %q", ErrUnknownEvent, event)
+		}
+	}
+	if secret == "" {
+		secret = h.defaultSecret
+	}
+	if secret == "" {
+		return Hook{}, ErrSecretRequired
+	}
+
+	hook := Hook{
+		ID:        newWatchID(),
+		URL:       url,
+		Events:    slices.Clone(eventTypes),
+		Source:    source,
+		CreatedAt: time.Now().UTC(),
+		secret:    secret,
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.hooks = append(h.hooks, hook)
+	return hook, nil
+}
+
+// List returns the registered webhooks, in registration order.
+func (h *Hooks) List() []Hook {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return slices.Clone(h.hooks)
+}
+
+// Remove deletes a webhook. Deliveries in flight are still completed.
+// It returns ErrHookNotFound if no webhook with the given ID exists.
+func (h *Hooks) Remove(id string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, hook := range h.hooks {
+		if hook.ID == id {
+			h.hooks = slices.Delete(h.hooks, i, i+1)
+			return nil
+		}
+	}
+	return ErrHookNotFound
+}
+
+// Deliveries returns the most recent deliveries, newest first.
+//
+// Parameters:
+//   - hookID: If not empty, only the deliveries to this webhook are returned
+func (h *Hooks) Deliveries(hookID string) []Delivery {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	deliveries := []Delivery{}
+	for i := len(h.recent) - 1; i >= 0; i-- {
+		delivery := h.status[h.recent[i]]
+		if hookID == "" || delivery.HookID == hookID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+// Notify delivers an event to every webhook subscribed to its type.
+// Deliveries are "webhook" jobs, retried with exponential backoff until they succeed or
+// the retry policy gives up; their status is available from Deliveries. If the job queue
+// is full, the delivery fails right away.
+func (h *Hooks) Notify(ctx context.Context, event events.Event) {
+	h.mutex.RLock()
+	var hooks []Hook
+	for _, hook := range h.hooks {
+		if hook.subscribes(event.Type) {
+			hooks = append(hooks, hook)
+		}
+	}
+	h.mutex.RUnlock()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("%sFailed to encode %s event: %v", requestid.LogPrefix(ctx), event.Type, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		delivery := h.track(hook, event)
+		h.deliveries.Add(1)
+		// The job keeps the request ID for correlation, and runs outside the request lifecycle
+		_, err := h.runner.Submit(ctx, jobs.Task{
+			Kind:  "webhook",
+			Retry: h.retry,
+			Run: func(ctx context.Context) (any, error) {
+				return nil, h.attempt(ctx, hook, delivery, event.Type, payload)
+			},
+			Done: func(_ any, err error) {
+				defer h.deliveries.Done()
+				h.complete(delivery, err)
+			},
+		})
+		if err != nil {
+			log.Printf("%sFailed to queue delivery %s: %v", requestid.LogPrefix(ctx), delivery, err)
+			h.complete(delivery, err)
+			h.deliveries.Done()
+		}
+	}
+}
+
+// Close waits until the deliveries in flight have finished, including their retries.
+// If the context is done first, the context's error is returned; the remaining
+// deliveries are abandoned and marked as failed when the job runner is closed.
+func (h *Hooks) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track records a new pending delivery, discarding the oldest one if too many are kept.
+func (h *Hooks) track(hook Hook, event events.Event) string {
+	delivery := &Delivery{
+		ID:        newWatchID(),
+		HookID:    hook.ID,
+		Event:     event.Type,
+		NoteID:    event.NoteID,
+		Status:    DeliveryPending,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.status[delivery.ID] = delivery
+	h.recent = append(h.recent, delivery.ID)
+	if len(h.recent) > maxDeliveries {
+		delete(h.status, h.recent[0])
+		h.recent = h.recent[1:]
+	}
+	return delivery.ID
+}
+
+// update changes the status of a delivery, if it is still kept.
+func (h *Hooks) update(id string, fn func(delivery *Delivery)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if delivery, ok := h.status[id]; ok {
+		fn(delivery)
+	}
+}
+
+// attempt posts a signed event payload to a webhook once, and records the outcome of the attempt.
+func (h *Hooks) attempt(ctx context.Context, hook Hook, deliveryID, eventType string, payload []byte) error {
+	statusCode, err := h.post(ctx, hook, deliveryID, eventType, payload)
+	h.update(deliveryID, func(delivery *Delivery) {
+		delivery.Attempts++
+		delivery.StatusCode = statusCode
+		delivery.LastError = ""
+		if err != nil {
+			delivery.LastError = err.Error()
+		}
+	})
+	return err
+}
+
+// complete records the outcome of a delivery, once it has succeeded or been given up.
+func (h *Hooks) complete(deliveryID string, err error) {
+	now := time.Now().UTC()
+	h.update(deliveryID, func(delivery *Delivery) {
+		delivery.Status = DeliverySucceeded
+		if err != nil {
+			delivery.Status = DeliveryFailed
+			delivery.LastError = err.Error()
+		}
+		delivery.CompletedAt = &now
+	})
+}
+
+// post makes a single delivery attempt.
+//
+// Returns:
+//   - The HTTP status of the response, or 0 if there was none
+//   - An error if the request failed or the response status is not 2xx
+func (h *Hooks) post(ctx context.Context, hook Hook, deliveryID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderSignature, Sign(hook.secret, time.Now(), payload))
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the X-Webhook-Signature header of a payload: the time of signing and the
+// hex-encoded HMAC-SHA256 of "<unix time>.<payload>", keyed with the webhook secret.
+// Receivers should recompute the signature and reject old timestamps to prevent replays.
+func Sign(secret string, t time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
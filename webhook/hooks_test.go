@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/jobs"
+	"golang-simple-notes/model"
+	"golang-simple-notes/storage"
+)
+
+// testRetryPolicy retries quickly, so failing deliveries don't slow down the tests
+var testRetryPolicy = storage.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, AttemptTimeout: time.Second}
+
+// newTestRunner creates a job runner for the deliveries, closed at the end of the test
+func newTestRunner(t *testing.T) *jobs.Runner {
+	t.Helper()
+	runner := jobs.NewRunner(4, 100)
+	t.Cleanup(func() { _ = runner.Close(context.Background()) })
+	return runner
+}
+
+// waitForDelivery waits until the latest delivery is no longer pending
+func waitForDelivery(t *testing.T, h *Hooks) Delivery {
+	t.Helper()
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	deliveries := h.Deliveries("")
+	if len(deliveries) == 0 {
+		t.Fatal("Expected a delivery")
+	}
+	return deliveries[0]
+}
+
+// TestHooks_Add_Errors tests the validation of registered webhooks
+func TestHooks_Add_Errors(t *testing.T) {
+	h := NewHooks(testRetryPolicy, "", newTestRunner(t))
+
+	if _, err := h.Add("example.com/hook", nil, "secret", "api"); !errors.Is(err, ErrInvalidCallbackURL) {
+		t.Errorf("Expected ErrInvalidCallbackURL, got %v", err)
+	}
+	if _, err := h.Add("https://example.com/hook", []string{"note.archived"}, "secret", "api"); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Expected ErrUnknownEvent, got %v", err)
+	}
+	if _, err := h.Add("http://192.168.1.1/hook", nil, "secret", "api"); !errors.Is(err, ErrPrivateCallbackURL) {
+		t.Errorf("Expected ErrPrivateCallbackURL, got %v", err)
+	}
+	if _, err := h.Add("https://example.com/hook", nil, "", "api"); !errors.Is(err, ErrSecretRequired) {
+		t.Errorf("Expected ErrSecretRequired, got %v", err)
+	}
+
+	hook, err := h.Add("https://example.com/hook", []string{events.NoteCreated}, "secret", "api")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if got := h.List(); len(got) != 1 || got[0].ID != hook.ID {
+		t.Errorf("Expected one webhook, got %+v", got)
+	}
+	if err := h.Remove("missing"); !errors.Is(err, ErrHookNotFound) {
+		t.Errorf("Expected ErrHookNotFound, got %v", err)
+	}
+	if err := h.Remove(hook.ID); err != nil || len(h.List()) != 0 {
+		t.Errorf("Expected the webhook to be removed, got %v %+v", err, h.List())
+	}
+}
+
+// TestHooks_Notify tests that events are delivered with a verifiable signature
+func TestHooks_Notify(t *testing.T) {
+	var received atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		// Verify the signature the way a receiver would
+		signature := r.Header.Get(HeaderSignature)
+		timestamp, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(signature), []byte(Sign("secret", time.Unix(seconds, 0), body))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(HeaderEvent) != events.NoteCreated || r.Header.Get(HeaderDelivery) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received.Store(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	h := NewHooks(testRetryPolicy, "secret", newTestRunner(t))
+	h.AllowPrivateTargets()
+	if _, err := h.Add(server.URL, nil, "", "config"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	h.Notify(context.Background(), events.Event{Type: events.NoteCreated, NoteID: "note-1", Note: &model.Note{ID: "note-1", Title: "Title"}})
+
+	delivery := waitForDelivery(t, h)
+	if delivery.Status != DeliverySucceeded || delivery.Attempts != 1 || delivery.StatusCode != http.StatusNoContent || delivery.CreatedAt.Location() != time.UTC {
+		t.Fatalf("Unexpected delivery: %+v", delivery)
+	}
+	var event events.Event
+	body, _ := received.Load().([]byte)
+	if err := json.Unmarshal(body, &event); err != nil || event.NoteID != "note-1" || event.Note.Title != "Title" {
+		t.Errorf("Unexpected payload %s: %v", body, err)
+	}
+}
+
+// TestHooks_Notify_Retry tests that failed deliveries are retried and their status is recorded
+func TestHooks_Notify_Retry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	h := NewHooks(testRetryPolicy, "secret", newTestRunner(t))
+	h.AllowPrivateTargets()
+	if _, err := h.Add(server.URL, nil, "", "api"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	h.Notify(context.Background(), events.Event{Type: events.NoteUpdated, NoteID: "note-1"})
+
+	if delivery := waitForDelivery(t, h); delivery.Status != DeliverySucceeded || delivery.Attempts != 2 {
+		t.Errorf("Expected a successful second attempt, got %+v", delivery)
+	}
+}
+
+// TestHooks_Notify_GiveUp tests that a delivery fails once the retry policy gives up
+func TestHooks_Notify_GiveUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	h := NewHooks(testRetryPolicy, "secret", newTestRunner(t))
+	h.AllowPrivateTargets()
+	hook, err := h.Add(server.URL, nil, "", "api")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	h.Notify(context.Background(), events.Event{Type: events.NoteDeleted, NoteID: "note-1"})
+
+	delivery := waitForDelivery(t, h)
+	if delivery.Status != DeliveryFailed || delivery.Attempts != testRetryPolicy.MaxAttempts ||
+		delivery.StatusCode != http.StatusBadGateway || delivery.LastError == "" || delivery.CompletedAt == nil {
+		t.Errorf("Expected a failed delivery, got %+v", delivery)
+	}
+	if got := h.Deliveries(hook.ID); len(got) != 1 {
+		t.Errorf("Expected one delivery for the webhook, got %+v", got)
+	}
+	if got := h.Deliveries("other"); len(got) != 0 {
+		t.Errorf("Expected no deliveries for another webhook, got %+v", got)
+	}
+}
+
+// TestHooks_Notify_Subscriptions tests that webhooks only receive the subscribed events
+func TestHooks_Notify_Subscriptions(t *testing.T) {
+	h := NewHooks(testRetryPolicy, "secret", newTestRunner(t))
+	h.AllowPrivateTargets()
+	if _, err := h.Add("http://127.0.0.1:0/hook", []string{events.NoteDeleted}, "", "api"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	h.Notify(context.Background(), events.Event{Type: events.NoteCreated, NoteID: "note-1"})
+
+	if got := h.Deliveries(""); len(got) != 0 {
+		t.Errorf("Expected no deliveries, got %+v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateCallbackURL is returned when a callback URL points to a private, loopback,
+// link-local, or otherwise non-public address (see nonPublicPrefixes), which would let
+// clients make the server send requests into its own network (server-side request forgery).
+var ErrPrivateCallbackURL = errors.New("callback URL must not point to a private, loopback, or link-local address")
+
+// nonPublicPrefixes are the address ranges that don't receive callbacks: the special-purpose
+// ranges of the IANA registries, which are private, shared, loopback, link-local, reserved,
+// for documentation or benchmarks, or translate to IPv4 addresses that may be private.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network", including the unspecified address
+	netip.MustParsePrefix("10.0.0.0/8"),      // Private
+	netip.MustParsePrefix("100.64.0.0/10"),   // Shared address space (carrier-grade NAT)
+	netip.MustParsePrefix("127.0.0.0/8"),     // Loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // Link-local, including cloud metadata services
+	netip.MustParsePrefix("172.16.0.0/12"),   // Private
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation (TEST-NET-1)
+	netip.MustParsePrefix("192.168.0.0/16"),  // Private
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation (TEST-NET-2)
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation (TEST-NET-3)
+	netip.MustParsePrefix("224.0.0.0/4"),     // Multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including the broadcast address
+	netip.MustParsePrefix("::/128"),          // Unspecified
+	netip.MustParsePrefix("::1/128"),         // Loopback
+	netip.MustParsePrefix("::ffff:0:0/96"),   // IPv4-mapped
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64 (well-known prefix)
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // NAT64 (local-use prefix)
+	netip.MustParsePrefix("100::/64"),        // Discard-only
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("fc00::/7"),        // Unique local
+	netip.MustParsePrefix("fe80::/10"),       // Link-local
+	netip.MustParsePrefix("ff00::/8"),        // Multicast
+}
+
+// isPublicAddr reports whether an address may receive callbacks: it is in none of the
+// nonPublicPrefixes. IPv4-mapped IPv6 addresses are checked as the IPv4 addresses they
+// connect to.
+func isPublicAddr(addr netip.Addr) bool {
+	// Prefixes never contain addresses with zones, so the zone is dropped
+	addr = addr.Unmap().WithZone("")
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCallbackHost rejects the hosts of callback URLs that are known to be private
+// without resolving them: IP addresses that are not public, and localhost. Other host
+// names are checked when they are resolved, by the dialer of newCallbackClient.
+func checkCallbackHost(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrInvalidCallbackURL
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateCallbackURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return ErrPrivateCallbackURL
+	}
+	return nil
+}
+
+// newCallbackClient returns the HTTP client delivering callbacks. Unless private targets
+// are allowed, it only connects to public addresses, whatever the host names resolve to
+// when the request is made (so a name can't be pointed at a private address after it was
+// checked), and doesn't use proxies, which would connect on its behalf.
+func newCallbackClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	if allowPrivate {
+		return &http.Client{Timeout: timeout}
+	}
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublicAddr(addrPort.Addr()) {
+				return ErrPrivateCallbackURL
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCheckCallbackHost tests which callback hosts are rejected without resolving them
+func TestCheckCallbackHost(t *testing.T) {
+	tests := []struct {
+		url     string
+		private bool
+	}{
+		{"https://example.com/hook", false},
+		{"http://93.184.216.34/hook", false},
+		{"http://[2606:2800:220:1::]/hook", false},
+		{"http://localhost:8080/hook", true},
+		{"http://LOCALHOST./hook", true},
+		{"http://api.localhost/hook", true},
+		{"http://127.0.0.1/hook", true},
+		{"http://10.1.2.3/hook", true},
+		{"http://172.16.0.1/hook", true},
+		{"http://192.168.0.1/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://0.0.0.0/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://[fe80::1]/hook", true},
+		{"http://[fd00::1]/hook", true},
+		{"http://[::ffff:127.0.0.1]/hook", true},
+		{"http://[::ffff:10.0.0.1]/hook", true},
+		{"http://[fe80::1%25eth0]/hook", true},
+		{"http://0.1.2.3/hook", true},
+		{"http://100.64.0.1/hook", true},
+		{"http://100.127.255.254/hook", true},
+		{"http://100.128.0.1/hook", false},
+		{"http://192.0.0.170/hook", true},
+		{"http://192.0.2.1/hook", true},
+		{"http://198.18.0.1/hook", true},
+		{"http://198.19.255.254/hook", true},
+		{"http://198.20.0.1/hook", false},
+		{"http://198.51.100.1/hook", true},
+		{"http://203.0.113.1/hook", true},
+		{"http://224.0.0.1/hook", true},
+		{"http://240.0.0.1/hook", true},
+		{"http://255.255.255.255/hook", true},
+		{"http://[::]/hook", true},
+		{"http://[::ffff:0:a00:1]/hook", true},
+		{"http://[64:ff9b::a9fe:a9fe]/hook", true},
+		{"http://[64:ff9b::5db8:d822]/hook", true},
+		{"http://[64:ff9b:1::1]/hook", true},
+		{"http://[100::1]/hook", true},
+		{"http://[2001:db8::1]/hook", true},
+		{"http://[ff02::1]/hook", true},
+	}
+
+	for _, tt := range tests {
+		err := checkCallbackHost(tt.url)
+		if tt.private && !errors.Is(err, ErrPrivateCallbackURL) {
+			t.Errorf("checkCallbackHost(%q): expected ErrPrivateCallbackURL, got %v", tt.url, err)
+		}
+		if !tt.private && err != nil {
+			t.Errorf("checkCallbackHost(%q): unexpected error %v", tt.url, err)
+		}
+	}
+}
+
+// TestNewCallbackClient tests that the callback client refuses to connect to private addresses
+// unless they are allowed, whatever host name the request uses
+func TestNewCallbackClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := newCallbackClient(time.Second, false).Get(server.URL); !errors.Is(err, ErrPrivateCallbackURL) {
+		t.Errorf("Expected ErrPrivateCallbackURL, got %v", err)
+	}
+
+	resp, err := newCallbackClient(time.Second, true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the request to succeed with private targets allowed, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,295 @@
+// Package webhook implements outgoing HTTP notifications about note changes.
+// Clients can watch individual notes, either with a callback URL that receives
+// a JSON payload for every change, or with an in-process stream subscription.
+// Operators can register webhooks that receive signed payloads for every note.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/requestid"
+)
+
+const (
+	// maxWatchesPerNote limits how many callback URLs can watch a single note.
+	maxWatchesPerNote = 20
+
+	// subscriberBuffer is the number of events buffered per stream subscriber.
+	// Events for slow subscribers are dropped rather than blocking writers.
+	subscriberBuffer = 16
+)
+
+var (
+	// ErrInvalidCallbackURL is returned when a callback URL is not an absolute http(s) URL.
+	ErrInvalidCallbackURL = errors.New("callback URL must be an absolute http or https URL")
+
+	// ErrTooManyWatches is returned when a note already has the maximum number of watches.
+	ErrTooManyWatches = errors.New("too many watches for this note")
+
+	// ErrWatchNotFound is returned when a watch with the specified ID doesn't exist.
+	ErrWatchNotFound = errors.New("watch not found")
+)
+
+// Watch is a callback URL registered for a single note.
+type Watch struct {
+	ID          string    `json:"id"`           // Unique identifier of the watch
+	NoteID      string    `json:"note_id"`      // ID of the watched note
+	CallbackURL string    `json:"callback_url"` // URL that receives event payloads
+	CreatedAt   time.Time `json:"created_at"`   // When the watch was registered
+	Owner       string    `json:"-"`            // Owner of the request that registered the watch; empty for anonymous clients
+}
+
+// Watchers keeps track of per-note watches and stream subscribers and delivers
+// note events to them. Watches are kept in memory, so they are lost when the
+// application restarts. It is safe for concurrent use.
+type Watchers struct {
+	client     *http.Client   // HTTP client used to deliver callbacks
+	timeout    time.Duration  // Maximum time allowed for delivering a single callback
+	private    bool           // Whether callbacks may target private addresses
+	deliveries sync.WaitGroup // Callback deliveries in flight
+
+	mutex       sync.RWMutex
+	watches     map[string][]Watch                        // Callback watches by note ID
+	subscribers map[string]map[chan events.Event]struct{} // Stream subscribers by note ID
+}
+
+// NewWatchers creates an empty watch registry. Callbacks to private, loopback, and
+// link-local addresses are rejected unless AllowPrivateTargets is called.
+//
+// Parameters:
+//   - timeout: The maximum time allowed for delivering a single callback
+//
+// Returns:
+//   - A pointer to a new Watchers instance
+func NewWatchers(timeout time.Duration) *Watchers {
+	return &Watchers{
+		client:      newCallbackClient(timeout, false),
+		timeout:     timeout,
+		watches:     make(map[string][]Watch),
+		subscribers: make(map[string]map[chan events.Event]struct{}),
+	}
+}
+
+// AllowPrivateTargets lets callbacks target private, loopback, and link-local addresses,
+// for deployments whose clients are trusted (e.g., services on the same network).
+// It must be called before the watchers are used.
+func (w *Watchers) AllowPrivateTargets() {
+	w.private = true
+	w.client = newCallbackClient(w.timeout, true)
+}
+
+// Add registers a callback URL for the note with the given ID on behalf of an owner
+// (see service.OwnerFromContext), which is the only one that can list or remove the watch.
+// It returns ErrInvalidCallbackURL or ErrPrivateCallbackURL if the URL can't be watched,
+// and ErrTooManyWatches if the note has the maximum number of watches.
+func (w *Watchers) Add(noteID, callbackURL, owner string) (Watch, error) {
+	if !isValidCallbackURL(callbackURL) {
+		return Watch{}, ErrInvalidCallbackURL
+	}
+	if !w.private {
+		if err := checkCallbackHost(callbackURL); err != nil {
+			return Watch{}, err
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.watches[noteID]) >= maxWatchesPerNote {
+		return Watch{}, ErrTooManyWatches
+	}
+
+	watch := Watch{
+		ID:          newWatchID(),
+		NoteID:      noteID,
+		CallbackURL: callbackURL,
+		CreatedAt:   time.Now().UTC(),
+		Owner:       owner,
+	}
+	w.watches[noteID] = append(w.watches[noteID], watch)
+	return watch, nil
+}
+
+// List returns the callback watches registered by an owner for the note with the given ID.
+func (w *Watchers) List(noteID, owner string) []Watch {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	watches := make([]Watch, 0, len(w.watches[noteID]))
+	for _, watch := range w.watches[noteID] {
+		if watch.Owner == owner {
+			watches = append(watches, watch)
+		}
+	}
+	return watches
+}
+
+// Remove deletes a single callback watch registered by an owner.
+// It returns ErrWatchNotFound if the owner has no watch of the note with the given ID.
+func (w *Watchers) Remove(noteID, watchID, owner string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	watches := w.watches[noteID]
+	for i, watch := range watches {
+		if watch.ID == watchID && watch.Owner == owner {
+			w.watches[noteID] = append(watches[:i], watches[i+1:]...)
+			if len(w.watches[noteID]) == 0 {
+				delete(w.watches, noteID)
+			}
+			return nil
+		}
+	}
+	return ErrWatchNotFound
+}
+
+// Subscribe registers a stream subscriber for the note with the given ID.
+// Events are delivered on the returned channel until the returned cancel
+// function is called or the note is deleted; in both cases the channel is closed.
+func (w *Watchers) Subscribe(noteID string) (<-chan events.Event, func()) {
+	ch := make(chan events.Event, subscriberBuffer)
+
+	w.mutex.Lock()
+	if w.subscribers[noteID] == nil {
+		w.subscribers[noteID] = make(map[chan events.Event]struct{})
+	}
+	w.subscribers[noteID][ch] = struct{}{}
+	w.mutex.Unlock()
+
+	cancel := func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if subs, ok := w.subscribers[noteID]; ok {
+			if _, ok := subs[ch]; ok {
+				delete(subs, ch)
+				close(ch)
+			}
+			if len(subs) == 0 {
+				delete(w.subscribers, noteID)
+			}
+		}
+	}
+	return ch, cancel
+}
+
+// Notify delivers an event to everyone watching the note.
+// Callbacks are delivered asynchronously; failures are logged and not retried.
+// A delete event also removes all watches and subscriptions of the note.
+// Create events are ignored, since a new note cannot have watchers yet.
+func (w *Watchers) Notify(ctx context.Context, event events.Event) {
+	if event.Type == events.NoteCreated {
+		return
+	}
+
+	w.mutex.Lock()
+	watches := w.watches[event.NoteID]
+	for ch := range w.subscribers[event.NoteID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("%sDropping %s event for slow subscriber of note %s",
+				requestid.LogPrefix(ctx), event.Type, event.NoteID)
+		}
+	}
+	if event.Type == events.NoteDeleted {
+		// Clean up everything that referenced the deleted note
+		for ch := range w.subscribers[event.NoteID] {
+			close(ch)
+		}
+		delete(w.subscribers, event.NoteID)
+		delete(w.watches, event.NoteID)
+	}
+	w.mutex.Unlock()
+
+	if len(watches) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("%sFailed to encode %s event: %v", requestid.LogPrefix(ctx), event.Type, err)
+		return
+	}
+
+	// Deliver outside the request lifecycle, but keep the request ID for correlation
+	deliveryCtx := context.WithoutCancel(ctx)
+	for _, watch := range watches {
+		w.deliveries.Add(1)
+		go func() {
+			defer w.deliveries.Done()
+			w.deliver(deliveryCtx, watch, payload)
+		}()
+	}
+}
+
+// Wait blocks until all callback deliveries in flight have finished,
+// so pending notifications are not lost when the application shuts down.
+// It returns the context's error if the context is done first.
+func (w *Watchers) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver posts an event payload to a single callback URL.
+func (w *Watchers) deliver(ctx context.Context, watch Watch, payload []byte) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, watch.CallbackURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("%sFailed to create callback request for watch %s: %v", requestid.LogPrefix(ctx), watch.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		log.Printf("%sFailed to deliver callback for watch %s: %v", requestid.LogPrefix(ctx), watch.ID, err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("%sCallback for watch %s returned %s", requestid.LogPrefix(ctx), watch.ID, resp.Status)
+	}
+}
+
+// isValidCallbackURL checks that a callback URL is an absolute http(s) URL.
+func isValidCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// newWatchID generates a random identifier for a watch.
+func newWatchID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-simple-notes/events"
+	"golang-simple-notes/model"
+	"golang-simple-notes/requestid"
+)
+
+// TestWatchers_AddListRemove tests registering, listing, and removing watches
+func TestWatchers_AddListRemove(t *testing.T) {
+	w := NewWatchers(time.Second)
+
+	watch, err := w.Add("note-1", "http://example.com/hook", "")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if watch.ID == "" || watch.NoteID != "note-1" || watch.CallbackURL != "http://example.com/hook" || watch.CreatedAt.Location() != time.UTC {
+		t.Errorf("Unexpected watch: %+v", watch)
+	}
+
+	if got := w.List("note-1", ""); len(got) != 1 || got[0].ID != watch.ID {
+		t.Errorf("Expected one watch, got %+v", got)
+	}
+	if got := w.List("note-2", ""); len(got) != 0 {
+		t.Errorf("Expected no watches for another note, got %+v", got)
+	}
+	if got := w.List("note-1", "alice"); len(got) != 0 {
+		t.Errorf("Expected no watches for another owner, got %+v", got)
+	}
+	if err := w.Remove("note-1", watch.ID, "alice"); !errors.Is(err, ErrWatchNotFound) {
+		t.Errorf("Expected ErrWatchNotFound for another owner, got %v", err)
+	}
+
+	if err := w.Remove("note-1", "missing", ""); !errors.Is(err, ErrWatchNotFound) {
+		t.Errorf("Expected ErrWatchNotFound, got %v", err)
+	}
+	if err := w.Remove("note-1", watch.ID, ""); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if got := w.List("note-1", ""); len(got) != 0 {
+		t.Errorf("Expected no watches after removal, got %+v", got)
+	}
+}
+
+// TestWatchers_Add_Errors tests callback URL validation and the per-note limit
+func TestWatchers_Add_Errors(t *testing.T) {
+	w := NewWatchers(time.Second)
+
+	for _, u := range []string{"", "example.com/hook", "ftp://example.com", "http://", "://bad"} {
+		if _, err := w.Add("note-1", u, ""); !errors.Is(err, ErrInvalidCallbackURL) {
+			t.Errorf("Add(%q): expected ErrInvalidCallbackURL, got %v", u, err)
+		}
+	}
+
+	for _, u := range []string{"http://localhost/hook", "http://127.0.0.1:8080", "http://10.0.0.1/hook",
+		"http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://[::ffff:192.168.0.1]/hook"} {
+		if _, err := w.Add("note-1", u, ""); !errors.Is(err, ErrPrivateCallbackURL) {
+			t.Errorf("Add(%q): expected ErrPrivateCallbackURL, got %v", u, err)
+		}
+	}
+
+	for i := 0; i < maxWatchesPerNote; i++ {
+		if _, err := w.Add("note-1", "https://example.com/hook", ""); err != nil {
+			t.Fatalf("Add %d failed: %v", i, err)
+		}
+	}
+	if _, err := w.Add("note-1", "https://example.com/hook", ""); !errors.Is(err, ErrTooManyWatches) {
+		t.Errorf("Expected ErrTooManyWatches, got %v", err)
+	}
+}
+
+// TestWatchers_Notify_Callback tests that callbacks receive events only for the watched note
+func TestWatchers_Notify_Callback(t *testing.T) {
+	received := make(chan *http.Request, 4)
+	bodies := make(chan []byte, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	w := NewWatchers(time.Second)
+	w.AllowPrivateTargets()
+	if _, err := w.Add("note-1", server.URL, ""); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	ctx := requestid.NewContext(context.Background(), "req-123")
+
+	// An event for another note must not be delivered
+	w.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-2"})
+	w.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-1", Note: &model.Note{ID: "note-1", Title: "Updated"}})
+
+	select {
+	case r := <-received:
+		if r.Header.Get(requestid.Header) != "req-123" {
+			t.Errorf("Expected request ID header 'req-123', got %q", r.Header.Get(requestid.Header))
+		}
+		var event events.Event
+		if err := json.Unmarshal(<-bodies, &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.Type != events.NoteUpdated || event.NoteID != "note-1" || event.Note == nil || event.Note.Title != "Updated" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Callback was not delivered")
+	}
+
+	select {
+	case <-received:
+		t.Error("Received an event for a note that is not watched")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestWatchers_Subscribe tests stream subscriptions and their cleanup
+func TestWatchers_Subscribe(t *testing.T) {
+	w := NewWatchers(time.Second)
+	ctx := context.Background()
+
+	received, cancel := w.Subscribe("note-1")
+	defer cancel()
+
+	w.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-2"})
+	w.Notify(ctx, events.Event{Type: events.NoteUpdated, NoteID: "note-1"})
+
+	if event := <-received; event.Type != events.NoteUpdated || event.NoteID != "note-1" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Canceling closes the channel; canceling twice is safe
+	cancel()
+	if _, ok := <-received; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	cancel()
+}
+
+// TestWatchers_Notify_DeleteCleansUp tests that deleting a note removes its watches and subscriptions
+func TestWatchers_Notify_DeleteCleansUp(t *testing.T) {
+	w := NewWatchers(time.Second)
+	w.AllowPrivateTargets()
+	ctx := context.Background()
+
+	if _, err := w.Add("note-1", "http://127.0.0.1:1/hook", ""); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	received, cancel := w.Subscribe("note-1")
+	defer cancel()
+
+	w.Notify(ctx, events.Event{Type: events.NoteDeleted, NoteID: "note-1"})
+
+	if event := <-received; event.Type != events.NoteDeleted {
+		t.Errorf("Expected delete event, got %+v", event)
+	}
+	if _, ok := <-received; ok {
+		t.Error("Expected channel to be closed after delete")
+	}
+	if got := w.List("note-1", ""); len(got) != 0 {
+		t.Errorf("Expected watches to be removed, got %+v", got)
+	}
+}
+
+// TestWatchers_Wait tests waiting for callback deliveries in flight
+func TestWatchers_Wait(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	w := NewWatchers(5 * time.Second)
+	w.AllowPrivateTargets()
+	if err := w.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait without deliveries failed: %v", err)
+	}
+
+	if _, err := w.Add("note-1", server.URL, ""); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	w.Notify(context.Background(), events.Event{Type: events.NoteUpdated, NoteID: "note-1", Timestamp: time.Now()})
+
+	// The delivery is blocked, so waiting must give up when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	close(release)
+	if err := w.Wait(context.Background()); err != nil {
+		t.Errorf("Wait after delivery failed: %v", err)
+	}
+}